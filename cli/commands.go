@@ -0,0 +1,291 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Streams are the standard streams of the command line
+type Streams struct {
+	// In is read by commands with no input file
+	In io.Reader
+	// Out receives the results of commands with no output file
+	Out io.Writer
+	// Err receives errors and usage
+	Err io.Writer
+}
+
+// command is a subcommand of the command line
+type command struct {
+	// name is the name to type to run the command
+	name string
+	// summary describes the command in usage
+	summary string
+	// run runs the command with its arguments, command name excluded
+	run func(ctx context.Context, args []string, streams Streams) error
+}
+
+// commands are the subcommands, in usage order
+var commands []command
+
+func init() {
+	commands = []command{
+		{name: "generate", summary: "generate a random graph (gnp, dgnp, ba, complete)", run: generate},
+		{name: "stats", summary: "basic statistics or degree distribution of a graph", run: stats},
+		{name: "components", summary: "sizes of the connected components of an undirected graph", run: components},
+		{name: "convert", summary: "convert a graph from a file format to another", run: convert},
+		{name: "export", summary: "export a graph for visualization tools (gexf)", run: export},
+		{name: "notebook", summary: "serve a notebook page to run scripts in a browser", run: notebookServer},
+		{name: "view", summary: "serve an interactive drawing of a graph in a browser", run: viewServer},
+		{name: "help", summary: "describe a command", run: help},
+	}
+}
+
+// usageError is an error in the arguments of the command line
+type usageError struct {
+	// message describes the error
+	message string
+}
+
+// Error returns the message
+func (u usageError) Error() string {
+	return u.message
+}
+
+// newUsageError returns a formatted usage error
+func newUsageError(format string, args ...any) error {
+	return usageError{message: fmt.Sprintf(format, args...)}
+}
+
+// Run runs the command line with its arguments (program name excluded).
+// Result is the exit code: 0 for a success, 1 for a failure, 2 for invalid arguments
+func Run(ctx context.Context, args []string, streams Streams) int {
+	if streams.In == nil {
+		streams.In = strings.NewReader("")
+	}
+
+	if streams.Out == nil {
+		streams.Out = io.Discard
+	}
+
+	if streams.Err == nil {
+		streams.Err = io.Discard
+	}
+
+	if len(args) == 0 {
+		usage(streams.Err)
+		return 2
+	}
+
+	var err error
+	if current, found := findCommand(args[0]); !found {
+		err = newUsageError("unknown command %q", args[0])
+	} else {
+		err = current.run(ctx, args[1:], streams)
+	}
+
+	var invalid usageError
+	switch {
+	case err == nil || errors.Is(err, flag.ErrHelp):
+		return 0
+	case errors.As(err, &invalid):
+		fmt.Fprintf(streams.Err, "nodz: %s\nRun 'nodz help' for usage.\n", err)
+		return 2
+	case errors.Is(err, errInvalidFlags):
+		// flag package already printed the error and the usage
+		return 2
+	default:
+		fmt.Fprintf(streams.Err, "nodz %s: %s\n", args[0], err)
+		return 1
+	}
+}
+
+// findCommand returns the command by name
+func findCommand(name string) (command, bool) {
+	for _, current := range commands {
+		if current.name == name {
+			return current, true
+		}
+	}
+
+	return command{}, false
+}
+
+// usage writes the general usage
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: nodz <command> [options]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, current := range commands {
+		fmt.Fprintf(w, "  %-12s %s\n", current.name, current.summary)
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "Readable formats: %s\n", strings.Join(formatNames(false), ", "))
+	fmt.Fprintf(w, "Writable formats: %s\n", strings.Join(formatNames(true), ", "))
+	fmt.Fprintln(w, "Formats are guessed from file extensions, standard streams use json by default.")
+	fmt.Fprintln(w, "Run 'nodz help <command>' for the options of a command.")
+}
+
+// help writes the general usage, or the options of a command
+func help(ctx context.Context, args []string, streams Streams) error {
+	if len(args) == 0 {
+		usage(streams.Out)
+		return nil
+	} else if args[0] == "help" {
+		return newUsageError("help [command] describes a command")
+	} else if current, found := findCommand(args[0]); !found {
+		return newUsageError("unknown command %q", args[0])
+	} else {
+		return current.run(ctx, []string{"-h"}, Streams{In: streams.In, Out: streams.Out, Err: streams.Out})
+	}
+}
+
+// errInvalidFlags is returned when flags parsing fails, flag package reports the error
+var errInvalidFlags = errors.New("invalid flags")
+
+// newFlagSet returns the flags of a command, writing errors and usage in streams.Err
+func newFlagSet(name, arguments, summary string, streams Streams) *flag.FlagSet {
+	set := flag.NewFlagSet(name, flag.ContinueOnError)
+	set.SetOutput(streams.Err)
+	set.Usage = func() {
+		fmt.Fprintf(set.Output(), "Usage: nodz %s %s\n\n%s\n\nOptions:\n", name, arguments, summary)
+		set.PrintDefaults()
+	}
+
+	return set
+}
+
+// parseFlags parses the arguments of a command, no positional argument is accepted
+func parseFlags(set *flag.FlagSet, args []string) error {
+	if err := set.Parse(args); errors.Is(err, flag.ErrHelp) {
+		return err
+	} else if err != nil {
+		return errInvalidFlags
+	} else if set.NArg() != 0 {
+		return newUsageError("%s: unexpected argument %q", set.Name(), set.Arg(0))
+	}
+
+	return nil
+}
+
+// standardStream is the path of standard input or output
+const standardStream = "-"
+
+// defaultStreamFormat is the format of graphs read from stdin or written to stdout
+const defaultStreamFormat = "json"
+
+// input is the source of the graph of a command
+type input struct {
+	// path is the file to read, standard input for "-"
+	path string
+	// format is the file format, guessed from the path if empty
+	format string
+	// directed is the orientation for formats that do not tell (edge lists)
+	directed bool
+}
+
+// register adds the input flags to a command
+func (i *input) register(set *flag.FlagSet) {
+	set.StringVar(&i.path, "i", standardStream, "input file, - for standard input")
+	set.StringVar(&i.format, "from", "", "input format, guessed from the file extension if empty")
+	set.BoolVar(&i.directed, "directed", false, "read links as directed, for formats that do not tell (edges, csv, adj)")
+}
+
+// read returns the graph of the input
+func (i input) read(streams Streams) (*graph, error) {
+	name := i.format
+	if name == "" && i.path == standardStream {
+		name = defaultStreamFormat
+	}
+
+	source, errFormat := findFormat(name, i.path, false)
+	if errFormat != nil {
+		return nil, usageError{message: errFormat.Error()}
+	}
+
+	if i.path == standardStream {
+		return source.read(streams.In, i.directed)
+	}
+
+	file, errOpen := os.Open(i.path)
+	if errOpen != nil {
+		return nil, errOpen
+	}
+
+	defer file.Close()
+	return source.read(file, i.directed)
+}
+
+// output is the destination of a command
+type output struct {
+	// path is the file to write, standard output for "-"
+	path string
+	// format is the format to use
+	format string
+}
+
+// register adds the output path flag to a command
+func (o *output) register(set *flag.FlagSet) {
+	set.StringVar(&o.path, "o", standardStream, "output file, - for standard output")
+}
+
+// registerFormat adds the output format flag to a command writing graphs
+func (o *output) registerFormat(set *flag.FlagSet) {
+	set.StringVar(&o.format, "to", "", "output format, guessed from the file extension if empty")
+}
+
+// registerReport adds the report format flag to a command writing results
+func (o *output) registerReport(set *flag.FlagSet) {
+	set.StringVar(&o.format, "format", "csv", "result format, csv or json")
+}
+
+// graphFormat returns the format of the graph to write, to check it before any work
+func (o output) graphFormat() (format, error) {
+	name := o.format
+	if name == "" && o.path == standardStream {
+		name = defaultStreamFormat
+	}
+
+	destination, errFormat := findFormat(name, o.path, true)
+	if errFormat != nil {
+		return destination, usageError{message: errFormat.Error()}
+	}
+
+	return destination, nil
+}
+
+// writeGraph writes a graph in the output, with the format from graphFormat
+func (o output) writeGraph(ctx context.Context, streams Streams, destination format, g *graph) error {
+	return o.write(streams, func(w io.Writer) error {
+		return destination.write(ctx, w, g)
+	})
+}
+
+// write writes content in the output. A file is removed if content fails
+func (o output) write(streams Streams, content func(io.Writer) error) error {
+	if o.path == standardStream {
+		buffer := bufio.NewWriter(streams.Out)
+		errContent := content(buffer)
+		return errors.Join(errContent, buffer.Flush())
+	}
+
+	file, errCreate := os.Create(o.path)
+	if errCreate != nil {
+		return errCreate
+	}
+
+	buffer := bufio.NewWriter(file)
+	errWrite := errors.Join(content(buffer), buffer.Flush(), file.Close())
+	if errWrite != nil {
+		os.Remove(o.path)
+	}
+
+	return errWrite
+}
@@ -0,0 +1,61 @@
+package cli
+
+import (
+	"context"
+)
+
+// convert reads a graph and writes it in another format
+func convert(ctx context.Context, args []string, streams Streams) error {
+	set := newFlagSet("convert", "[options]",
+		"Read a graph and write it in another format.\n"+
+			"Formats are guessed from file extensions, standard streams use json by default.", streams)
+
+	var source input
+	var destination output
+	source.register(set)
+	destination.register(set)
+	destination.registerFormat(set)
+	if err := parseFlags(set, args); err != nil {
+		return err
+	}
+
+	written, errFormat := destination.graphFormat()
+	if errFormat != nil {
+		return errFormat
+	}
+
+	g, errRead := source.read(streams)
+	if errRead != nil {
+		return errRead
+	}
+
+	return destination.writeGraph(ctx, streams, written, g)
+}
+
+// export writes a graph for visualization tools, only gexf is supported so far
+func export(ctx context.Context, args []string, streams Streams) error {
+	set := newFlagSet("export", "gexf [options]",
+		"Export a graph in the GEXF format of visualization tools such as Gephi.\n"+
+			"Nodes are labelled with their id.", streams)
+
+	var source input
+	var destination output
+	source.register(set)
+	destination.register(set)
+	if len(args) == 0 || args[0] != "gexf" {
+		if len(args) != 0 && (args[0] == "-h" || args[0] == "-help" || args[0] == "--help") {
+			return parseFlags(set, args)
+		}
+
+		return newUsageError("export: expecting gexf as first argument")
+	} else if err := parseFlags(set, args[1:]); err != nil {
+		return err
+	}
+
+	g, errRead := source.read(streams)
+	if errRead != nil {
+		return errRead
+	}
+
+	return destination.writeGraph(ctx, streams, formats["gexf"], g)
+}
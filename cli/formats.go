@@ -0,0 +1,318 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/zefrenchwan/nodz.git/graphs"
+	"github.com/zefrenchwan/nodz.git/internal"
+	"github.com/zefrenchwan/nodz.git/internal/local"
+	"github.com/zefrenchwan/nodz.git/storage"
+	"github.com/zefrenchwan/nodz.git/storage/edgelist"
+	"github.com/zefrenchwan/nodz.git/storage/gexf"
+	"github.com/zefrenchwan/nodz.git/storage/gml"
+	"github.com/zefrenchwan/nodz.git/storage/jsongraph"
+	"github.com/zefrenchwan/nodz.git/storage/nauty"
+	"github.com/zefrenchwan/nodz.git/storage/pajek"
+)
+
+// node is the node of the graphs of the command line: nodes are identified by their id
+type node = internal.IdNode
+
+// link is the link of the graphs of the command line: directed or not, with a weight
+type link = internal.ValuedLink[node, float64]
+
+// graph is a graph read or generated by a command
+type graph struct {
+	// directed is true for a graph of directed links
+	directed bool
+	// content is the graph per se
+	content *local.MapGraph[node, link]
+}
+
+// newGraph returns an empty graph
+func newGraph(directed bool) *graph {
+	content := local.NewMapGraph[node, link]()
+	return &graph{directed: directed, content: &content}
+}
+
+// format is a file format commands read or write
+type format struct {
+	// extensions are the file extensions of the format, to guess it from a path
+	extensions []string
+	// read returns the graph of the content, nil for formats commands cannot read.
+	// Directed is the user choice, for formats that do not tell the orientation
+	read func(reader io.Reader, directed bool) (*graph, error)
+	// write writes the graph, nil for formats commands cannot write
+	write func(ctx context.Context, writer io.Writer, g *graph) error
+}
+
+// formats are the supported formats, by name
+var formats = map[string]format{
+	"edges": {
+		extensions: []string{".edges", ".txt"},
+		read: func(reader io.Reader, directed bool) (*graph, error) {
+			g := newGraph(directed)
+			return g, edgelist.ReadEdgeList(reader, g.content, newNode, g.linkFactory(), edgelist.SNAPOptions())
+		},
+		write: func(_ context.Context, writer io.Writer, g *graph) error {
+			return edgelist.WriteEdgeList(writer, g.content, nil, nil, edgelist.SNAPOptions())
+		},
+	},
+	"csv": {
+		extensions: []string{".csv"},
+		read: func(reader io.Reader, directed bool) (*graph, error) {
+			g := newGraph(directed)
+			return g, edgelist.ReadEdgeList(reader, g.content, newNode, g.linkFactory(), edgelist.CSVOptions(',', true))
+		},
+		write: func(_ context.Context, writer io.Writer, g *graph) error {
+			return edgelist.WriteEdgeList(writer, g.content, nil, linkWeight, edgelist.CSVOptions(',', true))
+		},
+	},
+	"adj": {
+		extensions: []string{".adj"},
+		read: func(reader io.Reader, directed bool) (*graph, error) {
+			g := newGraph(directed)
+			return g, edgelist.ReadAdjacencyList(reader, g.content, newNode, g.linkFactory(), edgelist.SNAPOptions())
+		},
+		write: func(_ context.Context, writer io.Writer, g *graph) error {
+			return edgelist.WriteAdjacencyList(writer, g.content, nil, edgelist.SNAPOptions())
+		},
+	},
+	"json": {
+		extensions: []string{".json"},
+		read: func(reader io.Reader, _ bool) (*graph, error) {
+			return readJSON(reader, jsongraph.ReadNodeLink[node, link])
+		},
+		write: func(_ context.Context, writer io.Writer, g *graph) error {
+			return jsongraph.WriteNodeLink(writer, g.content, g.jsonOptions())
+		},
+	},
+	"jgf": {
+		extensions: []string{".jgf"},
+		read: func(reader io.Reader, _ bool) (*graph, error) {
+			return readJSON(reader, jsongraph.ReadJGF[node, link])
+		},
+		write: func(_ context.Context, writer io.Writer, g *graph) error {
+			return jsongraph.WriteJGF(writer, g.content, g.jsonOptions())
+		},
+	},
+	"gml": {
+		extensions: []string{".gml"},
+		read: func(reader io.Reader, _ bool) (*graph, error) {
+			g := newGraph(false)
+			directed, err := gml.ReadGML(reader, g.content, newNode, newDirectedLink, newUndirectedLink)
+			g.directed = directed
+			return g, err
+		},
+		write: func(_ context.Context, writer io.Writer, g *graph) error {
+			return gml.WriteGML(writer, g.content, nil, linkWeight)
+		},
+	},
+	"net": {
+		extensions: []string{".net"},
+		read: func(reader io.Reader, _ bool) (*graph, error) {
+			g := newGraph(false)
+			err := pajek.ReadPajek(reader, g.content, newNode, newDirectedLink, newUndirectedLink)
+			for current := range g.content.Links() {
+				if current.IsDirected() {
+					g.directed = true
+					break
+				}
+			}
+
+			return g, err
+		},
+		write: func(_ context.Context, writer io.Writer, g *graph) error {
+			return pajek.WritePajek(writer, g.content, nil, linkWeight)
+		},
+	},
+	"g6": {
+		extensions: []string{".g6"},
+		read:       readNauty,
+		write: func(_ context.Context, writer io.Writer, g *graph) error {
+			if g.directed {
+				return errors.New("graph6 applies to undirected graphs, use d6")
+			}
+
+			return writeNauty(writer, g, nauty.EncodeGraph6[node, link])
+		},
+	},
+	"s6": {
+		extensions: []string{".s6"},
+		read:       readNauty,
+		write: func(_ context.Context, writer io.Writer, g *graph) error {
+			if g.directed {
+				return errors.New("sparse6 applies to undirected graphs, use d6")
+			}
+
+			return writeNauty(writer, g, nauty.EncodeSparse6[node, link])
+		},
+	},
+	"d6": {
+		extensions: []string{".d6"},
+		read:       readNauty,
+		write: func(_ context.Context, writer io.Writer, g *graph) error {
+			if !g.directed {
+				return errors.New("digraph6 applies to directed graphs, use g6 or s6")
+			}
+
+			return writeNauty(writer, g, nauty.EncodeDigraph6[node, link])
+		},
+	},
+	"gexf": {
+		extensions: []string{".gexf"},
+		write: func(ctx context.Context, writer io.Writer, g *graph) error {
+			return gexf.WriteDataGraphContext(ctx, writer, g.content, gexf.GexfIdNodeExporter, gexf.GexfLinkBasicSerializer)
+		},
+	},
+}
+
+// formatNames returns the names of the formats, sorted, that may be read (or written)
+func formatNames(writable bool) []string {
+	result := make([]string, 0, len(formats))
+	for name, f := range formats {
+		if (writable && f.write != nil) || (!writable && f.read != nil) {
+			result = append(result, name)
+		}
+	}
+
+	slices.Sort(result)
+	return result
+}
+
+// findFormat returns the format by name, or guessed from the extension of path if name is empty
+func findFormat(name, path string, writable bool) (format, error) {
+	if name == "" {
+		extension := strings.ToLower(filepath.Ext(strings.TrimSuffix(path, ".gz")))
+		for candidate, f := range formats {
+			if slices.Contains(f.extensions, extension) {
+				name = candidate
+				break
+			}
+		}
+	}
+
+	expected := strings.Join(formatNames(writable), ", ")
+	result, found := formats[name]
+	switch {
+	case name == "":
+		return result, fmt.Errorf("cannot guess format of %q, expecting one of %s", path, expected)
+	case !found:
+		return result, fmt.Errorf("unknown format %q, expecting one of %s", name, expected)
+	case writable && result.write == nil:
+		return result, fmt.Errorf("cannot write format %q, expecting one of %s", name, expected)
+	case !writable && result.read == nil:
+		return result, fmt.Errorf("cannot read format %q, expecting one of %s", name, expected)
+	default:
+		return result, nil
+	}
+}
+
+// newNode builds a node from its id
+func newNode(id string) (node, error) {
+	return internal.NewIdNode(id), nil
+}
+
+// newDirectedLink builds a directed link
+func newDirectedLink(source, destination node, weight float64) (link, error) {
+	return internal.NewDirectedValuedLink(source, destination, weight), nil
+}
+
+// newUndirectedLink builds an undirected link
+func newUndirectedLink(source, destination node, weight float64) (link, error) {
+	return internal.NewUndirectedValuedLink(source, destination, weight), nil
+}
+
+// linkWeight returns the weight of a link
+func linkWeight(l link) float64 {
+	return l.Value()
+}
+
+// linkFactory returns the link factory matching the orientation of the graph
+func (g *graph) linkFactory() func(source, destination node, weight float64) (link, error) {
+	if g.directed {
+		return newDirectedLink
+	}
+
+	return newUndirectedLink
+}
+
+// jsonOptions returns the options to write g in json formats
+func (g *graph) jsonOptions() jsongraph.Options[node, link] {
+	return jsongraph.Options[node, link]{
+		Info:    jsongraph.GraphInfo{Directed: g.directed},
+		Weigher: linkWeight,
+	}
+}
+
+// jsonReader reads a json document into a graph
+type jsonReader func(io.Reader, graphs.CentralStructureGraph[node, link], storage.NodeFactory[node], storage.LinkFactory[node, link]) (jsongraph.GraphInfo, error)
+
+// readJSON reads a json document (node link or JGF) twice.
+// Orientation may appear after the links in the document, so first pass finds it, second one reads the graph
+func readJSON(reader io.Reader, read jsonReader) (*graph, error) {
+	decompressed, errDecompress := storage.NewDecompressedReader(reader)
+	if errDecompress != nil {
+		return nil, errDecompress
+	}
+
+	defer decompressed.Close()
+
+	content, errContent := io.ReadAll(decompressed)
+	if errContent != nil {
+		return nil, errContent
+	}
+
+	var orientation struct {
+		// Directed is the orientation of node link documents
+		Directed bool `json:"directed"`
+		// Graph contains the orientation of JGF documents
+		Graph struct {
+			Directed bool `json:"directed"`
+		} `json:"graph"`
+	}
+
+	if err := json.Unmarshal(content, &orientation); err != nil {
+		return nil, err
+	}
+
+	g := newGraph(orientation.Directed || orientation.Graph.Directed)
+	_, errRead := read(bytes.NewReader(content), g.content, newNode, g.linkFactory())
+	return g, errRead
+}
+
+// readNauty reads a graph6, sparse6 or digraph6 content, orientation depends on content
+func readNauty(reader io.Reader, _ bool) (*graph, error) {
+	content, errRead := io.ReadAll(reader)
+	if errRead != nil {
+		return nil, errRead
+	}
+
+	text := strings.TrimSpace(string(content))
+	decoded, errDecode := nauty.Decode(text, newNode, newDirectedLink, newUndirectedLink)
+	if errDecode != nil {
+		return nil, errDecode
+	}
+
+	directed := strings.HasPrefix(strings.TrimPrefix(text, nauty.Digraph6Header), "&")
+	return &graph{directed: directed, content: decoded}, nil
+}
+
+// writeNauty writes the encoding of g as a line
+func writeNauty(writer io.Writer, g *graph, encoder func(graphs.CentralStructureGraph[node, link]) (string, error)) error {
+	content, errEncode := encoder(g.content)
+	if errEncode != nil {
+		return errEncode
+	}
+
+	_, errWrite := io.WriteString(writer, content+"\n")
+	return errWrite
+}
@@ -0,0 +1,100 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math/rand"
+	"strconv"
+
+	"github.com/zefrenchwan/nodz.git/graphs"
+	"github.com/zefrenchwan/nodz.git/internal"
+	"github.com/zefrenchwan/nodz.git/internal/local"
+)
+
+// generate writes a random graph
+func generate(ctx context.Context, args []string, streams Streams) error {
+	set := newFlagSet("generate", "-model <model> [options]",
+		"Generate a graph, nodes are named 0 to n-1. Models are:\n"+
+			"  gnp       undirected, each link exists with probability p\n"+
+			"  dgnp      directed, each link exists with probability p\n"+
+			"  ba        undirected Barabasi Albert, preferential attachment from a complete graph of m nodes\n"+
+			"  complete  undirected complete graph", streams)
+
+	var destination output
+	destination.register(set)
+	destination.registerFormat(set)
+	model := set.String("model", "gnp", "model of the graph: gnp, dgnp, ba or complete")
+	size := set.Int("n", 100, "number of nodes")
+	probability := set.Float64("p", 0.1, "linking probability (gnp, dgnp)")
+	initial := set.Int("m", 3, "size of the initial complete graph (ba)")
+	seed := set.Int64("seed", 0, "seed of random values, random graphs differ at each run if not set")
+	if err := parseFlags(set, args); err != nil {
+		return err
+	}
+
+	var generator local.RandomGenerator[node, link]
+	set.Visit(func(f *flag.Flag) {
+		if f.Name == "seed" {
+			generator.Random = rand.New(rand.NewSource(*seed))
+		}
+	})
+
+	written, errFormat := destination.graphFormat()
+	if errFormat != nil {
+		return errFormat
+	} else if *size < 0 {
+		return newUsageError("generate: invalid size %d", *size)
+	}
+
+	result := newGraph(*model == "dgnp")
+	nodes := nodesSequence()
+	links := result.linkGenerator()
+	var content graphs.CentralStructureGraph[node, link]
+	var errGenerate error
+	switch *model {
+	case "gnp":
+		content, errGenerate = generator.UndirectedGNPContext(ctx, *size, *probability, nodes, links)
+	case "dgnp":
+		content, errGenerate = generator.DirectedGNPContext(ctx, *size, *probability, nodes, links)
+	case "ba":
+		content, errGenerate = generator.UndirectedBarabasiAlbertGraphContext(ctx, *initial, *size, nodes, links)
+	case "complete":
+		complete, errComplete := local.GenerateCompleteUndirectedGraph(*size, nodes, links)
+		content, errGenerate = &complete, errComplete
+	default:
+		return newUsageError("generate: unknown model %q, expecting gnp, dgnp, ba or complete", *model)
+	}
+
+	if errGenerate != nil {
+		return errGenerate
+	} else if mapGraph, ok := content.(*local.MapGraph[node, link]); !ok {
+		return fmt.Errorf("unexpected generated graph")
+	} else {
+		result.content = mapGraph
+	}
+
+	return destination.writeGraph(ctx, streams, written, result)
+}
+
+// nodesSequence returns a generator of nodes named 0, 1, 2...
+func nodesSequence() graphs.RandomNodeGenerator[node] {
+	counter := 0
+	return func() node {
+		counter++
+		return internal.NewIdNode(strconv.Itoa(counter - 1))
+	}
+}
+
+// linkGenerator returns a generator of links of weight 1, matching the orientation of the graph
+func (g *graph) linkGenerator() graphs.RandomLinkGenerator[node, link] {
+	if g.directed {
+		return func(source, destination node) link {
+			return internal.NewDirectedValuedLink(source, destination, 1.0)
+		}
+	}
+
+	return func(source, destination node) link {
+		return internal.NewUndirectedValuedLink(source, destination, 1.0)
+	}
+}
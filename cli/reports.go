@@ -0,0 +1,193 @@
+package cli
+
+import (
+	"cmp"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"slices"
+	"strconv"
+
+	"github.com/zefrenchwan/nodz.git/graphs"
+)
+
+// statsReport is the json result of stats
+type statsReport struct {
+	Nodes         int64   `json:"nodes"`
+	Links         int64   `json:"links"`
+	Directed      bool    `json:"directed"`
+	AverageDegree float64 `json:"average_degree"`
+	Density       float64 `json:"density"`
+	MaxDegree     int64   `json:"max_degree"`
+}
+
+// degreeShare is the json result of stats for a degree
+type degreeShare struct {
+	Degree int64   `json:"degree"`
+	Share  float64 `json:"share"`
+}
+
+// componentsReport is the json result of components
+type componentsReport struct {
+	Count   int     `json:"count"`
+	Largest int64   `json:"largest"`
+	Sizes   []int64 `json:"sizes"`
+}
+
+// stats writes the statistics of a graph
+func stats(ctx context.Context, args []string, streams Streams) error {
+	set := newFlagSet("stats", "[options]",
+		"Write nodes, links, orientation, average degree, density and max degree of a graph.\n"+
+			"With -degrees, write the share of nodes per degree instead.\n"+
+			"Degree counts all links of a node, whatever their direction.", streams)
+
+	var source input
+	var destination output
+	source.register(set)
+	destination.register(set)
+	destination.registerReport(set)
+	degrees := set.Bool("degrees", false, "write the degree distribution")
+	if err := parseFlags(set, args); err != nil {
+		return err
+	} else if err := checkReportFormat(destination.format); err != nil {
+		return err
+	}
+
+	g, errRead := source.read(streams)
+	if errRead != nil {
+		return errRead
+	}
+
+	counter := func(n graphs.Neighborhood[node, link]) int64 {
+		return n.IncomingDegree() + n.OutgoingDegree() + n.UndirectedDegree()
+	}
+
+	statistics, errStats := graphs.CalculateNetworkStatisticsContext(ctx, g.content, counter)
+	if errStats != nil {
+		return errStats
+	}
+
+	if *degrees {
+		shares := make([]degreeShare, 0, len(statistics.DegreeDistribution))
+		for degree, share := range statistics.DegreeDistribution {
+			shares = append(shares, degreeShare{Degree: degree, Share: share})
+		}
+
+		slices.SortFunc(shares, func(a, b degreeShare) int { return cmp.Compare(a.Degree, b.Degree) })
+		rows := make([][]string, len(shares))
+		for index, share := range shares {
+			rows[index] = []string{formatInt(share.Degree), formatFloat(share.Share)}
+		}
+
+		return destination.writeReport(streams, shares, []string{"degree", "share"}, rows)
+	}
+
+	report := statsReport{
+		Nodes:    statistics.NodesSize,
+		Links:    statistics.DirectedSize + statistics.UndirectedSize,
+		Directed: g.directed,
+	}
+
+	if g.directed {
+		report.AverageDegree, report.Density = statistics.AverageDirectedDegree(), statistics.DirectedDensity()
+	} else {
+		report.AverageDegree, report.Density = statistics.AverageUndirectedDegree(), statistics.UndirectedDensity()
+	}
+
+	for degree := range statistics.DegreeDistribution {
+		report.MaxDegree = max(report.MaxDegree, degree)
+	}
+
+	header := []string{"nodes", "links", "directed", "average_degree", "density", "max_degree"}
+	row := []string{
+		formatInt(report.Nodes), formatInt(report.Links), strconv.FormatBool(report.Directed),
+		formatFloat(report.AverageDegree), formatFloat(report.Density), formatInt(report.MaxDegree),
+	}
+
+	return destination.writeReport(streams, report, header, [][]string{row})
+}
+
+// components writes the sizes of the connected components, largest first
+func components(ctx context.Context, args []string, streams Streams) error {
+	set := newFlagSet("components", "[options]",
+		"Write the sizes of the connected components of an undirected graph, largest first.", streams)
+
+	var source input
+	var destination output
+	source.register(set)
+	destination.register(set)
+	destination.registerReport(set)
+	if err := parseFlags(set, args); err != nil {
+		return err
+	} else if err := checkReportFormat(destination.format); err != nil {
+		return err
+	}
+
+	g, errRead := source.read(streams)
+	if errRead != nil {
+		return errRead
+	} else if g.directed {
+		return errors.New("connected components apply to undirected graphs")
+	} else if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	sizes, errSizes := graphs.ParallelConnectedComponentsSize(g.content, graphs.ParallelOptions[node]{})
+	if errSizes != nil {
+		return errSizes
+	}
+
+	report := componentsReport{Count: len(sizes), Sizes: make([]int64, 0, len(sizes))}
+	for _, size := range sizes {
+		report.Sizes = append(report.Sizes, size)
+	}
+
+	slices.SortFunc(report.Sizes, func(a, b int64) int { return cmp.Compare(b, a) })
+	rows := make([][]string, len(report.Sizes))
+	for index, size := range report.Sizes {
+		rows[index] = []string{strconv.Itoa(index + 1), formatInt(size)}
+	}
+
+	if len(report.Sizes) != 0 {
+		report.Largest = report.Sizes[0]
+	}
+
+	return destination.writeReport(streams, report, []string{"component", "size"}, rows)
+}
+
+// checkReportFormat returns an error for unknown report formats
+func checkReportFormat(name string) error {
+	if name != "csv" && name != "json" {
+		return newUsageError("unknown result format %q, expecting csv or json", name)
+	}
+
+	return nil
+}
+
+// writeReport writes value as json, or the rows as csv with a header
+func (o output) writeReport(streams Streams, value any, header []string, rows [][]string) error {
+	return o.write(streams, func(w io.Writer) error {
+		if o.format == "json" {
+			encoder := json.NewEncoder(w)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(value)
+		}
+
+		writer := csv.NewWriter(w)
+		writer.Write(header)
+		writer.WriteAll(rows)
+		return writer.Error()
+	})
+}
+
+// formatInt formats an integer for csv results
+func formatInt(value int64) string {
+	return strconv.FormatInt(value, 10)
+}
+
+// formatFloat formats a float for csv results, with the shortest exact representation
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
@@ -0,0 +1,105 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"github.com/zefrenchwan/nodz.git/notebook"
+	"github.com/zefrenchwan/nodz.git/visualization"
+)
+
+// shutdownTimeout is the maximum duration to finish current requests once command is cancelled
+const shutdownTimeout = 5 * time.Second
+
+// notebookServer serves the notebook page until the command is cancelled
+func notebookServer(ctx context.Context, args []string, streams Streams) error {
+	set := newFlagSet("notebook", "[options]",
+		"Serve a notebook page to run cells of the graph language in a browser.\n"+
+			"Notebooks are saved in the directory, and so are the exports of scripts.", streams)
+
+	address := set.String("addr", "localhost:8888", "address to listen to")
+	directory := set.String("dir", ".", "directory of the notebooks and exports")
+	maxDrawn := set.Int("max-drawn", notebook.DefaultMaxDrawnNodes, "maximum number of nodes of a drawn graph")
+	timeout := set.Duration("timeout", time.Minute, "maximum duration of a cell run")
+	if err := parseFlags(set, args); err != nil {
+		return err
+	}
+
+	options := notebook.Options{Directory: *directory, MaxDrawnNodes: *maxDrawn, RunTimeout: *timeout}
+	if host, _, err := net.SplitHostPort(*address); err == nil && host != "" {
+		// host of the address is accepted, loopback hosts always are
+		options.Hosts = []string{host}
+	}
+
+	handler := notebook.NewServer(options)
+	return serve(ctx, *address, handler, streams)
+}
+
+// viewServer serves a force directed drawing of a graph until the command is cancelled
+func viewServer(ctx context.Context, args []string, streams Streams) error {
+	set := newFlagSet("view", "[options]",
+		"Serve an interactive drawing of a graph in a browser.\n"+
+			"Node sizes grow with degrees, link widths with weights.", streams)
+
+	var source input
+	source.register(set)
+	address := set.String("addr", "localhost:8889", "address to listen to")
+	title := set.String("title", "", "title of the page, the input file name if empty")
+	if err := parseFlags(set, args); err != nil {
+		return err
+	}
+
+	g, errRead := source.read(streams)
+	if errRead != nil {
+		return errRead
+	}
+
+	if *title == "" && source.path != standardStream {
+		*title = filepath.Base(source.path)
+	}
+
+	handler, errHandler := visualization.NewServer(g.content, visualization.Options[node, link]{
+		Title:  *title,
+		Size:   visualization.DegreeSizes(g.content, 4, 16),
+		Weight: func(l link) float64 { return l.Value() },
+	})
+
+	if errHandler != nil {
+		return errHandler
+	}
+
+	defer handler.Close()
+	return serve(ctx, *address, handler, streams)
+}
+
+// serve listens to address and serves handler until ctx is done
+func serve(ctx context.Context, address string, handler http.Handler, streams Streams) error {
+	listener, errListen := net.Listen("tcp", address)
+	if errListen != nil {
+		return errListen
+	}
+
+	fmt.Fprintf(streams.Err, "serving on http://%s/\n", listener.Addr())
+	server := &http.Server{Handler: handler}
+	served := make(chan error, 1)
+	go func() { served <- server.Serve(listener) }()
+
+	select {
+	case err := <-served:
+		return err
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		errShutdown := server.Shutdown(shutdownCtx)
+		if errServe := <-served; !errors.Is(errServe, http.ErrServerClosed) {
+			return errors.Join(errServe, errShutdown)
+		}
+
+		return errShutdown
+	}
+}
@@ -0,0 +1,191 @@
+package cli_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/zefrenchwan/nodz.git/cli"
+)
+
+// run runs the command line with stdin as input, and returns exit code, standard output and standard error
+func run(stdin string, args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := cli.Run(context.Background(), args, cli.Streams{In: strings.NewReader(stdin), Out: &stdout, Err: &stderr})
+	return code, stdout.String(), stderr.String()
+}
+
+func TestGenerateStatsPipeline(t *testing.T) {
+	code, graph, errors := run("", "generate", "-model", "complete", "-n", "5")
+	if code != 0 {
+		t.Fatalf("unexpected code %d: %s", code, errors)
+	}
+
+	code, stats, errors := run(graph, "stats")
+	expected := "nodes,links,directed,average_degree,density,max_degree\n5,10,false,4,1,4\n"
+	if code != 0 {
+		t.Fatalf("unexpected code %d: %s", code, errors)
+	} else if stats != expected {
+		t.Errorf("unexpected stats %q", stats)
+	}
+
+	code, degrees, _ := run(graph, "stats", "-degrees", "-format", "json")
+	var shares []map[string]float64
+	if code != 0 {
+		t.Fatalf("unexpected code %d", code)
+	} else if err := json.Unmarshal([]byte(degrees), &shares); err != nil {
+		t.Fatal(err)
+	} else if len(shares) != 1 || shares[0]["degree"] != 4 || shares[0]["share"] != 1 {
+		t.Errorf("unexpected degrees %v", shares)
+	}
+}
+
+// generateLinks generates a graph and returns its links, sorted
+func generateLinks(model, seed string) string {
+	_, content, _ := run("", "generate", "-model", model, "-n", "40", "-p", "0.2", "-seed", seed, "-to", "edges")
+	lines := strings.Split(strings.TrimSpace(content), "\n")
+	slices.Sort(lines)
+	return strings.Join(lines, "\n")
+}
+
+func TestGenerateSeed(t *testing.T) {
+	for _, model := range []string{"gnp", "dgnp", "ba"} {
+		first, second, other := generateLinks(model, "7"), generateLinks(model, "7"), generateLinks(model, "8")
+		if first == "" || first != second {
+			t.Errorf("%s: same seed should generate the same graph", model)
+		} else if first == other {
+			t.Errorf("%s: different seeds should generate different graphs", model)
+		}
+	}
+
+	_, directed, _ := run("", "generate", "-model", "dgnp", "-n", "10", "-p", "1")
+	if _, stats, _ := run(directed, "stats"); !strings.HasSuffix(stats, "\n10,90,true,9,1,18\n") {
+		t.Errorf("unexpected stats of directed graph %q", stats)
+	}
+}
+
+func TestComponents(t *testing.T) {
+	edges := "a b\nb c\nd e\n# comment\nf g\ng h\nh a\n"
+	code, result, errors := run(edges, "components", "-from", "edges")
+	if code != 0 {
+		t.Fatalf("unexpected code %d: %s", code, errors)
+	} else if result != "component,size\n1,6\n2,2\n" {
+		t.Errorf("unexpected components %q", result)
+	}
+
+	_, result, _ = run(edges, "components", "-from", "edges", "-format", "json")
+	var report struct {
+		Count   int     `json:"count"`
+		Largest int     `json:"largest"`
+		Sizes   []int64 `json:"sizes"`
+	}
+
+	if err := json.Unmarshal([]byte(result), &report); err != nil {
+		t.Fatal(err)
+	} else if report.Count != 2 || report.Largest != 6 || len(report.Sizes) != 2 {
+		t.Errorf("unexpected report %v", report)
+	}
+
+	if code, _, errors := run(edges, "components", "-from", "edges", "-directed"); code != 1 || !strings.Contains(errors, "undirected") {
+		t.Errorf("directed graphs should fail, got %d %q", code, errors)
+	}
+}
+
+func TestConvertFiles(t *testing.T) {
+	directory := t.TempDir()
+	source := filepath.Join(directory, "graph.csv")
+	if err := os.WriteFile(source, []byte("source,target,weight\na,b,2.5\nb,c,1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	formats := []string{"graph.json", "graph.jgf", "graph.gml", "graph.net", "graph.adj", "graph.edges", "graph.d6"}
+	for _, name := range formats {
+		path := filepath.Join(directory, name)
+		if code, _, errors := run("", "convert", "-i", source, "-directed", "-o", path); code != 0 {
+			t.Errorf("%s: unexpected code %d: %s", name, code, errors)
+		} else if _, stats, _ := run("", "stats", "-i", path, "-directed"); !strings.HasSuffix(stats, "\n3,2,true,0.6666666666666666,0.3333333333333333,2\n") {
+			t.Errorf("%s: unexpected stats %q", name, stats)
+		}
+	}
+
+	// weights and orientation survive a round trip
+	roundTrip := filepath.Join(directory, "back.csv")
+	run("", "convert", "-i", filepath.Join(directory, "graph.json"), "-o", roundTrip)
+	if content, err := os.ReadFile(roundTrip); err != nil {
+		t.Fatal(err)
+	} else if !strings.Contains(string(content), "a,b,2.5") {
+		t.Errorf("unexpected content %q", content)
+	}
+
+	// failed conversion does not leave a file
+	failed := filepath.Join(directory, "failed.g6")
+	if code, _, errors := run("", "convert", "-i", source, "-directed", "-o", failed); code != 1 || !strings.Contains(errors, "d6") {
+		t.Errorf("graph6 should reject directed graphs, got %d %q", code, errors)
+	} else if _, err := os.Stat(failed); !os.IsNotExist(err) {
+		t.Error("failed conversion should remove the output file")
+	}
+}
+
+func TestExportGexf(t *testing.T) {
+	code, result, errors := run("a b\nb c\n", "export", "gexf", "-from", "edges")
+	if code != 0 {
+		t.Fatalf("unexpected code %d: %s", code, errors)
+	} else if !strings.Contains(result, "<gexf") || strings.Count(result, "<node ") != 3 {
+		t.Errorf("unexpected gexf %q", result)
+	}
+
+	if code, _, _ := run("", "export", "svg"); code != 2 {
+		t.Errorf("unknown export format should be a usage error, got %d", code)
+	}
+}
+
+func TestUsageErrors(t *testing.T) {
+	cases := [][]string{
+		{},
+		{"unknown"},
+		{"generate", "-model", "unknown"},
+		{"generate", "-n", "ten"},
+		{"generate", "extra"},
+		{"stats", "-format", "xml"},
+		{"convert", "-to", "svg"},
+		{"convert", "-i", "graph.unknown"},
+		{"help", "unknown"},
+		{"notebook", "extra"},
+		{"view", "extra"},
+	}
+
+	for _, args := range cases {
+		if code, _, errors := run("", args...); code != 2 {
+			t.Errorf("%v: expected usage error, got %d", args, code)
+		} else if errors == "" {
+			t.Errorf("%v: expected an error message", args)
+		}
+	}
+
+	if code, help, _ := run("", "help", "generate"); code != 0 || !strings.Contains(help, "-seed") {
+		t.Errorf("unexpected help %d %q", code, help)
+	} else if code, usage, _ := run("", "help"); code != 0 || !strings.Contains(usage, "components") {
+		t.Errorf("unexpected usage %d %q", code, usage)
+	}
+}
+
+func TestInvalidInput(t *testing.T) {
+	if code, _, errors := run("{not json", "stats"); code != 1 || errors == "" {
+		t.Errorf("invalid input should fail, got %d %q", code, errors)
+	} else if code, _, _ := run("", "stats", "-i", filepath.Join(t.TempDir(), "missing.json")); code != 1 {
+		t.Errorf("missing file should fail, got %d", code)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var stderr bytes.Buffer
+	args := []string{"generate", "-n", "100"}
+	if code := cli.Run(ctx, args, cli.Streams{Err: &stderr}); code != 1 || !strings.Contains(stderr.String(), "canceled") {
+		t.Errorf("cancelled generation should fail, got %d %q", code, stderr.String())
+	}
+}
@@ -0,0 +1,464 @@
+package dsl
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/zefrenchwan/nodz.git/graphs"
+	"github.com/zefrenchwan/nodz.git/internal"
+	"github.com/zefrenchwan/nodz.git/internal/local"
+)
+
+// builtin is a function scripts may call
+type builtin struct {
+	// arguments are the names of the arguments, for arity checks and help
+	arguments []string
+	// optional is the number of last arguments that may be omitted
+	optional int
+	// help describes the function
+	help string
+	// apply computes the result of the function
+	apply func(ctx context.Context, args []Value) (Value, error)
+}
+
+// builtins are the functions scripts may call, by name
+var builtins map[string]builtin
+
+func init() {
+	builtins = map[string]builtin{
+		"gnp": {
+			arguments: []string{"size", "probability"},
+			help:      "undirected random graph, each link exists with probability",
+			apply: func(ctx context.Context, args []Value) (Value, error) {
+				return generateGNP(ctx, args, false)
+			},
+		},
+		"dgnp": {
+			arguments: []string{"size", "probability"},
+			help:      "directed random graph, each link exists with probability",
+			apply: func(ctx context.Context, args []Value) (Value, error) {
+				return generateGNP(ctx, args, true)
+			},
+		},
+		"ba": {
+			arguments: []string{"initial", "size"},
+			help:      "undirected Barabasi Albert graph: preferential attachment from a complete graph of initial nodes",
+			apply:     generateBA,
+		},
+		"complete": {
+			arguments: []string{"size"},
+			help:      "undirected complete graph",
+			apply:     generateComplete,
+		},
+		"stats": {
+			arguments: []string{"graph"},
+			help:      "nodes, links, average degree, density and max degree",
+			apply:     statistics,
+		},
+		"components": {
+			arguments: []string{"graph"},
+			help:      "connected components of an undirected graph: count, largest and sizes",
+			apply:     components,
+		},
+		"path": {
+			arguments: []string{"graph", "source", "target"},
+			help:      "nodes of a shortest path, empty list if target is not reachable",
+			apply:     shortestPath,
+		},
+		"distance": {
+			arguments: []string{"graph", "source", "target"},
+			help:      "number of links of a shortest path, -1 if target is not reachable",
+			apply: func(ctx context.Context, args []Value) (Value, error) {
+				path, errPath := shortestPath(ctx, args)
+				if errPath != nil {
+					return nil, errPath
+				}
+
+				return float64(len(path.([]Value)) - 1), nil
+			},
+		},
+		"nodes": {
+			arguments: []string{"graph"},
+			help:      "number of nodes",
+			apply: func(ctx context.Context, args []Value) (Value, error) {
+				g, errGraph := graphArgument(args, 0)
+				if errGraph != nil {
+					return nil, errGraph
+				}
+
+				nodes, _ := g.Size()
+				return float64(nodes), nil
+			},
+		},
+		"links": {
+			arguments: []string{"graph"},
+			help:      "number of links",
+			apply: func(ctx context.Context, args []Value) (Value, error) {
+				g, errGraph := graphArgument(args, 0)
+				if errGraph != nil {
+					return nil, errGraph
+				}
+
+				_, links := g.Size()
+				return float64(links), nil
+			},
+		},
+		"degree": {
+			arguments: []string{"graph", "node"},
+			help:      "number of links of a node, all directions",
+			apply:     degree,
+		},
+		"range": {
+			arguments: []string{"start", "end", "step"},
+			optional:  1,
+			help:      "numbers from start (included) to end (excluded), step is 1 by default",
+			apply:     numbersRange,
+		},
+		"len": {
+			arguments: []string{"list"},
+			help:      "number of elements of a list",
+			apply: func(ctx context.Context, args []Value) (Value, error) {
+				if values, ok := args[0].([]Value); !ok {
+					return nil, fmt.Errorf("expected a list, got %s", typeName(args[0]))
+				} else {
+					return float64(len(values)), nil
+				}
+			},
+		},
+	}
+}
+
+// numberArgument returns the argument at index as a number
+func numberArgument(args []Value, index int) (float64, error) {
+	if value, ok := args[index].(float64); ok {
+		return value, nil
+	}
+
+	return 0, fmt.Errorf("argument %d: expected a number, got %s", index+1, typeName(args[index]))
+}
+
+// integerArgument returns the argument at index as an integer
+func integerArgument(args []Value, index int) (int, error) {
+	value, errValue := numberArgument(args, index)
+	if errValue != nil {
+		return 0, errValue
+	} else if value != math.Trunc(value) || math.Abs(value) > math.MaxInt32 {
+		return 0, fmt.Errorf("argument %d: expected an integer, got %s", index+1, Format(value))
+	}
+
+	return int(value), nil
+}
+
+// graphArgument returns the argument at index as a graph
+func graphArgument(args []Value, index int) (*Graph, error) {
+	if value, ok := args[index].(*Graph); ok {
+		return value, nil
+	}
+
+	return nil, fmt.Errorf("argument %d: expected a graph, got %s", index+1, typeName(args[index]))
+}
+
+// nodeArgument returns the argument at index as a node id
+func nodeArgument(args []Value, index int) (Node, error) {
+	if id, ok := nodeId(args[index]); ok {
+		return internal.NewIdNode(id), nil
+	}
+
+	return Node{}, fmt.Errorf("argument %d: expected a node id, got %s", index+1, typeName(args[index]))
+}
+
+// generators returns the generators of nodes (named 0, 1, 2...) and links for a graph
+func generators(g *Graph) (graphs.RandomNodeGenerator[Node], graphs.RandomLinkGenerator[Node, Link]) {
+	counter := 0
+	nodes := func() Node {
+		counter++
+		return internal.NewIdNode(strconv.Itoa(counter - 1))
+	}
+
+	links := func(source, target Node) Link {
+		return g.newLink(source, target, 1.0)
+	}
+
+	return nodes, links
+}
+
+// generated wraps the result of a generator as a graph
+func generated(g *Graph, result graphs.CentralStructureGraph[Node, Link], err error) (Value, error) {
+	if err != nil {
+		return nil, err
+	} else if content, ok := result.(*local.MapGraph[Node, Link]); !ok {
+		return nil, fmt.Errorf("unexpected generated graph")
+	} else {
+		g.content = content
+		return g, nil
+	}
+}
+
+// generateGNP returns a random gnp graph
+func generateGNP(ctx context.Context, args []Value, directed bool) (Value, error) {
+	size, errSize := integerArgument(args, 0)
+	if errSize != nil {
+		return nil, errSize
+	}
+
+	probability, errProbability := numberArgument(args, 1)
+	if errProbability != nil {
+		return nil, errProbability
+	}
+
+	var generator local.RandomGenerator[Node, Link]
+	result := newGraph(directed)
+	nodes, links := generators(result)
+	if directed {
+		content, err := generator.DirectedGNPContext(ctx, size, probability, nodes, links)
+		return generated(result, content, err)
+	}
+
+	content, err := generator.UndirectedGNPContext(ctx, size, probability, nodes, links)
+	return generated(result, content, err)
+}
+
+// generateBA returns a random Barabasi Albert graph
+func generateBA(ctx context.Context, args []Value) (Value, error) {
+	initial, errInitial := integerArgument(args, 0)
+	if errInitial != nil {
+		return nil, errInitial
+	}
+
+	size, errSize := integerArgument(args, 1)
+	if errSize != nil {
+		return nil, errSize
+	}
+
+	var generator local.RandomGenerator[Node, Link]
+	result := newGraph(false)
+	nodes, links := generators(result)
+	content, err := generator.UndirectedBarabasiAlbertGraphContext(ctx, initial, size, nodes, links)
+	return generated(result, content, err)
+}
+
+// generateComplete returns a complete undirected graph
+func generateComplete(ctx context.Context, args []Value) (Value, error) {
+	size, errSize := integerArgument(args, 0)
+	if errSize != nil {
+		return nil, errSize
+	} else if size < 0 {
+		return nil, fmt.Errorf("invalid size %d", size)
+	}
+
+	result := newGraph(false)
+	nodes, links := generators(result)
+	content, err := local.GenerateCompleteUndirectedGraph(size, nodes, links)
+	if err != nil {
+		return nil, err
+	}
+
+	result.content = &content
+	return result, nil
+}
+
+// statistics returns the basic statistics of a graph
+func statistics(ctx context.Context, args []Value) (Value, error) {
+	g, errGraph := graphArgument(args, 0)
+	if errGraph != nil {
+		return nil, errGraph
+	}
+
+	counter := func(n graphs.Neighborhood[Node, Link]) int64 {
+		return n.IncomingDegree() + n.OutgoingDegree() + n.UndirectedDegree()
+	}
+
+	stats, errStats := graphs.CalculateNetworkStatisticsContext(ctx, g.content, counter)
+	if errStats != nil {
+		return nil, errStats
+	}
+
+	var maxDegree int64
+	for degree := range stats.DegreeDistribution {
+		maxDegree = max(maxDegree, degree)
+	}
+
+	result := newRecord()
+	result.set("nodes", float64(stats.NodesSize))
+	result.set("links", float64(stats.DirectedSize+stats.UndirectedSize))
+	if g.directed {
+		result.set("average_degree", stats.AverageDirectedDegree())
+		result.set("density", stats.DirectedDensity())
+	} else {
+		result.set("average_degree", stats.AverageUndirectedDegree())
+		result.set("density", stats.UndirectedDensity())
+	}
+
+	result.set("max_degree", float64(maxDegree))
+	return result, nil
+}
+
+// components returns the connected components of an undirected graph
+func components(ctx context.Context, args []Value) (Value, error) {
+	g, errGraph := graphArgument(args, 0)
+	if errGraph != nil {
+		return nil, errGraph
+	} else if g.directed {
+		return nil, fmt.Errorf("connected components apply to undirected graphs")
+	}
+
+	stats, errStats := graphs.ConnectedComponentsSizeContext(ctx, g.content,
+		local.HashSetBuilder[Node](nil),
+		func() (graphs.DynamicIterator[Node], error) {
+			it := local.NewDynamicSlicesIterator[Node]()
+			return &it, nil
+		},
+	)
+
+	if errStats != nil {
+		return nil, errStats
+	}
+
+	sizes := make([]int64, 0, len(stats))
+	for _, size := range stats {
+		sizes = append(sizes, size)
+	}
+
+	slices.SortFunc(sizes, func(a, b int64) int { return cmp.Compare(b, a) })
+	values := make([]Value, len(sizes))
+	for index, size := range sizes {
+		values[index] = float64(size)
+	}
+
+	result := newRecord()
+	result.set("count", float64(len(sizes)))
+	if len(sizes) == 0 {
+		result.set("largest", 0.0)
+	} else {
+		result.set("largest", float64(sizes[0]))
+	}
+
+	result.set("sizes", values)
+	return result, nil
+}
+
+// shortestPath returns the ids of the nodes of a shortest path
+func shortestPath(ctx context.Context, args []Value) (Value, error) {
+	g, errGraph := graphArgument(args, 0)
+	if errGraph != nil {
+		return nil, errGraph
+	}
+
+	source, errSource := nodeArgument(args, 1)
+	if errSource != nil {
+		return nil, errSource
+	} else if found, _ := graphs.HasNode(g.content, source); !found {
+		return nil, fmt.Errorf("no node %q", source.Id())
+	}
+
+	target, errTarget := nodeArgument(args, 2)
+	if errTarget != nil {
+		return nil, errTarget
+	} else if found, _ := graphs.HasNode(g.content, target); !found {
+		return nil, fmt.Errorf("no node %q", target.Id())
+	}
+
+	path, errPath := graphs.ShortestPath(g.content, source, target, nil)
+	if errPath != nil {
+		return nil, errPath
+	}
+
+	result := make([]Value, len(path))
+	for index, node := range path {
+		result[index] = node.Id()
+	}
+
+	return result, nil
+}
+
+// degree returns the number of links of a node
+func degree(ctx context.Context, args []Value) (Value, error) {
+	g, errGraph := graphArgument(args, 0)
+	if errGraph != nil {
+		return nil, errGraph
+	}
+
+	node, errNode := nodeArgument(args, 1)
+	if errNode != nil {
+		return nil, errNode
+	}
+
+	neighbors, errNeighbors := g.content.Neighbors(node)
+	if errNeighbors != nil {
+		return nil, errNeighbors
+	} else if neighbors == nil {
+		return nil, fmt.Errorf("no node %q", node.Id())
+	}
+
+	return float64(neighbors.IncomingDegree() + neighbors.OutgoingDegree() + neighbors.UndirectedDegree()), nil
+}
+
+// maxRangeSize is the maximum number of elements of a range, to catch scripts with a wrong step
+const maxRangeSize = 1_000_000
+
+// numbersRange returns the numbers from start to end (excluded) with a step
+func numbersRange(ctx context.Context, args []Value) (Value, error) {
+	start, errStart := numberArgument(args, 0)
+	if errStart != nil {
+		return nil, errStart
+	}
+
+	end, errEnd := numberArgument(args, 1)
+	if errEnd != nil {
+		return nil, errEnd
+	}
+
+	step := 1.0
+	if len(args) > 2 {
+		if value, err := numberArgument(args, 2); err != nil {
+			return nil, err
+		} else if value == 0 {
+			return nil, fmt.Errorf("step should not be 0")
+		} else {
+			step = value
+		}
+	}
+
+	result := make([]Value, 0)
+	// values are computed from start to avoid accumulating rounding errors
+	for index := 0; ; index++ {
+		value := start + float64(index)*step
+		if (step > 0 && value >= end) || (step < 0 && value <= end) {
+			return result, nil
+		} else if index >= maxRangeSize {
+			return nil, fmt.Errorf("range is too large, more than %d values", maxRangeSize)
+		}
+
+		// round to hide floating point noise, such as 0.30000000000000004
+		rounded, _ := strconv.ParseFloat(strconv.FormatFloat(value, 'g', 12, 64), 64)
+		result = append(result, rounded)
+	}
+}
+
+// Help returns the built in functions, one per line, sorted by name
+func Help() string {
+	names := make([]string, 0, len(builtins))
+	for name := range builtins {
+		names = append(names, name)
+	}
+
+	slices.Sort(names)
+	lines := make([]string, len(names))
+	for index, name := range names {
+		function := builtins[name]
+		arguments := function.arguments
+		if function.optional > 0 {
+			required := len(arguments) - function.optional
+			arguments = append(slices.Clone(arguments[:required]), "["+strings.Join(arguments[required:], ", ")+"]")
+		}
+
+		lines[index] = fmt.Sprintf("%s(%s): %s", name, strings.Join(arguments, ", "), function.help)
+	}
+
+	return strings.Join(lines, "\n")
+}
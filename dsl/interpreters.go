@@ -0,0 +1,510 @@
+package dsl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/zefrenchwan/nodz.git/graphs"
+	"github.com/zefrenchwan/nodz.git/internal"
+	"github.com/zefrenchwan/nodz.git/storage/edgelist"
+	"github.com/zefrenchwan/nodz.git/storage/gexf"
+	"github.com/zefrenchwan/nodz.git/storage/jsongraph"
+)
+
+// RuntimeError is an error while running a script, with the position of the failing statement or expression
+type RuntimeError struct {
+	// Position of the error
+	Position Position
+	// Message describes the error
+	Message string
+}
+
+// Error returns line:column: message
+func (re RuntimeError) Error() string {
+	return fmt.Sprintf("%s: %s", re.Position, re.Message)
+}
+
+// Interpreter runs scripts. Variables are kept from one run to the next, as a session
+type Interpreter struct {
+	// Directory is the base directory of export paths, current directory if empty.
+	// Exports outside of it are refused
+	Directory string
+	// Printer receives the values of each print statement, nil to write them formatted to output
+	Printer func(values []Value) error
+	// output receives the printed values
+	output io.Writer
+	// variables are the values of the variables, by name
+	variables map[string]Value
+}
+
+// NewInterpreter returns an interpreter with no variable, printing to output
+func NewInterpreter(output io.Writer) *Interpreter {
+	if output == nil {
+		output = io.Discard
+	}
+
+	return &Interpreter{output: output, variables: make(map[string]Value)}
+}
+
+// Run parses and runs a script. Errors are either ParseError or RuntimeError
+func (i *Interpreter) Run(ctx context.Context, source string) error {
+	program, errParse := Parse(source)
+	if errParse != nil {
+		return errParse
+	}
+
+	return i.Execute(ctx, program)
+}
+
+// Execute runs a parsed script, and stops at the first error
+func (i *Interpreter) Execute(ctx context.Context, program *Program) error {
+	if program == nil {
+		return errors.New("nil program")
+	}
+
+	return i.executeAll(ctx, program.statements)
+}
+
+// Variables returns the names of the variables, sorted
+func (i *Interpreter) Variables() []string {
+	result := make([]string, 0, len(i.variables))
+	for name := range i.variables {
+		result = append(result, name)
+	}
+
+	slices.Sort(result)
+	return result
+}
+
+// Variable returns the value of a variable, if any
+func (i *Interpreter) Variable(name string) (Value, bool) {
+	value, found := i.variables[name]
+	return value, found
+}
+
+// executeAll runs statements in order
+func (i *Interpreter) executeAll(ctx context.Context, statements []statement) error {
+	for _, current := range statements {
+		if err := ctx.Err(); err != nil {
+			return RuntimeError{Position: current.position(), Message: err.Error()}
+		} else if err := i.execute(ctx, current); err != nil {
+			return i.locate(current.position(), err)
+		}
+	}
+
+	return nil
+}
+
+// locate returns err as a runtime error at position, unless it already has a position
+func (i *Interpreter) locate(position Position, err error) error {
+	var runtimeErr RuntimeError
+	if errors.As(err, &runtimeErr) {
+		return err
+	}
+
+	return RuntimeError{Position: position, Message: err.Error()}
+}
+
+// execute runs a statement
+func (i *Interpreter) execute(ctx context.Context, current statement) error {
+	switch s := current.(type) {
+	case letStatement:
+		value, errValue := i.evaluate(ctx, s.value)
+		if errValue != nil {
+			return errValue
+		}
+
+		i.variables[s.name] = value
+	case graphStatement:
+		if s.value == nil {
+			i.variables[s.name] = newGraph(s.directed)
+			return nil
+		}
+
+		value, errValue := i.evaluate(ctx, s.value)
+		if errValue != nil {
+			return errValue
+		} else if g, ok := value.(*Graph); !ok {
+			return fmt.Errorf("expected a graph, got %s", typeName(value))
+		} else if s.directed && !g.directed {
+			return fmt.Errorf("graph %s is declared directed, value is undirected", s.name)
+		} else {
+			i.variables[s.name] = g
+		}
+	case nodeStatement:
+		g, errGraph := i.graph(s.graph)
+		if errGraph != nil {
+			return errGraph
+		}
+
+		for _, expr := range s.nodes {
+			node, errNode := i.evaluateNode(ctx, expr)
+			if errNode != nil {
+				return errNode
+			} else if err := g.content.AddNode(node); err != nil {
+				return err
+			}
+		}
+	case linkStatement:
+		return i.link(ctx, s)
+	case unlinkStatement:
+		g, errGraph := i.graph(s.graph)
+		if errGraph != nil {
+			return errGraph
+		}
+
+		source, errSource := i.evaluateNode(ctx, s.source)
+		if errSource != nil {
+			return errSource
+		}
+
+		target, errTarget := i.evaluateNode(ctx, s.target)
+		if errTarget != nil {
+			return errTarget
+		}
+
+		if existing, found, err := g.findLink(source, target); err != nil {
+			return err
+		} else if !found {
+			return fmt.Errorf("no link from %q to %q", source.Id(), target.Id())
+		} else {
+			return g.content.RemoveLink(existing)
+		}
+	case removeStatement:
+		g, errGraph := i.graph(s.graph)
+		if errGraph != nil {
+			return errGraph
+		}
+
+		node, errNode := i.evaluateNode(ctx, s.node)
+		if errNode != nil {
+			return errNode
+		} else if found, _ := graphs.HasNode(g.content, node); !found {
+			return fmt.Errorf("no node %q", node.Id())
+		}
+
+		return g.content.RemoveNode(node)
+	case printStatement:
+		values := make([]Value, len(s.values))
+		for index, expr := range s.values {
+			value, errValue := i.evaluate(ctx, expr)
+			if errValue != nil {
+				return errValue
+			}
+
+			values[index] = value
+		}
+
+		if i.Printer != nil {
+			return i.Printer(values)
+		}
+
+		parts := make([]string, len(values))
+		for index, value := range values {
+			parts[index] = Format(value)
+		}
+
+		_, errWrite := fmt.Fprintln(i.output, strings.Join(parts, " "))
+		return errWrite
+	case exportStatement:
+		return i.export(ctx, s)
+	case forStatement:
+		value, errValue := i.evaluate(ctx, s.values)
+		if errValue != nil {
+			return errValue
+		}
+
+		values, ok := value.([]Value)
+		if !ok {
+			return fmt.Errorf("expected a list to loop over, got %s", typeName(value))
+		}
+
+		for _, element := range values {
+			i.variables[s.variable] = element
+			if err := i.executeAll(ctx, s.body); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unknown statement %T", current)
+	}
+
+	return nil
+}
+
+// link adds a link, nodes are added if needed. Linking two linked nodes changes the weight of their link
+func (i *Interpreter) link(ctx context.Context, s linkStatement) error {
+	g, errGraph := i.graph(s.graph)
+	if errGraph != nil {
+		return errGraph
+	}
+
+	source, errSource := i.evaluateNode(ctx, s.source)
+	if errSource != nil {
+		return errSource
+	}
+
+	target, errTarget := i.evaluateNode(ctx, s.target)
+	if errTarget != nil {
+		return errTarget
+	}
+
+	weight := 1.0
+	if s.weight != nil {
+		if value, err := i.evaluate(ctx, s.weight); err != nil {
+			return err
+		} else if number, ok := value.(float64); !ok {
+			return RuntimeError{Position: s.weight.position(), Message: "expected a number as weight, got " + typeName(value)}
+		} else {
+			weight = number
+		}
+	}
+
+	if existing, found, err := g.findLink(source, target); err != nil {
+		return err
+	} else if found {
+		if err := g.content.RemoveLink(existing); err != nil {
+			return err
+		}
+	}
+
+	return g.content.AddLink(g.newLink(source, target, weight))
+}
+
+// exportPath returns the path of an export file, that should be in Directory (absolute paths included)
+func (i *Interpreter) exportPath(name string) (string, error) {
+	directory := i.Directory
+	if directory == "" {
+		directory = "."
+	}
+
+	base, errBase := filepath.Abs(directory)
+	if errBase != nil {
+		return "", errBase
+	}
+
+	path := name
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(base, path)
+	}
+
+	if relative, err := filepath.Rel(base, path); err != nil || !filepath.IsLocal(relative) {
+		return "", fmt.Errorf("export path %q is outside of directory %q", name, directory)
+	}
+
+	return path, nil
+}
+
+// export writes a graph in a file, format depends on the extension:
+// .gexf, .json (networkx node link), .jgf, .csv (edge list with header and weights), .txt or .edges (edge list)
+func (i *Interpreter) export(ctx context.Context, s exportStatement) error {
+	g, errGraph := i.graph(s.graph)
+	if errGraph != nil {
+		return errGraph
+	}
+
+	value, errValue := i.evaluate(ctx, s.path)
+	if errValue != nil {
+		return errValue
+	}
+
+	name, ok := value.(string)
+	if !ok {
+		return fmt.Errorf("expected a file path, got %s", typeName(value))
+	}
+
+	path, errPath := i.exportPath(name)
+	if errPath != nil {
+		return errPath
+	}
+
+	options := jsongraph.Options[Node, Link]{
+		Info:    jsongraph.GraphInfo{Directed: g.directed},
+		Weigher: func(l Link) float64 { return l.Value() },
+	}
+
+	var write func(io.Writer) error
+	switch extension := strings.ToLower(filepath.Ext(path)); extension {
+	case ".gexf":
+		write = func(w io.Writer) error {
+			return gexf.WriteDataGraphContext(ctx, w, g.content, gexf.GexfIdNodeExporter, gexf.GexfLinkBasicSerializer)
+		}
+	case ".json":
+		write = func(w io.Writer) error { return jsongraph.WriteNodeLink(w, g.content, options) }
+	case ".jgf":
+		write = func(w io.Writer) error { return jsongraph.WriteJGF(w, g.content, options) }
+	case ".csv":
+		write = func(w io.Writer) error {
+			return edgelist.WriteEdgeList(w, g.content, nil, options.Weigher, edgelist.CSVOptions(',', true))
+		}
+	case ".txt", ".edges":
+		write = func(w io.Writer) error {
+			return edgelist.WriteEdgeList(w, g.content, nil, nil, edgelist.SNAPOptions())
+		}
+	default:
+		return fmt.Errorf("unknown export format %q, expecting .gexf, .json, .jgf, .csv, .txt or .edges", extension)
+	}
+
+	file, errFile := os.Create(path)
+	if errFile != nil {
+		return errFile
+	}
+
+	errWrite := write(file)
+	return errors.Join(errWrite, file.Close())
+}
+
+// graph returns the graph in variable name
+func (i *Interpreter) graph(name string) (*Graph, error) {
+	value, found := i.variables[name]
+	if !found {
+		return nil, fmt.Errorf("unknown graph %s", name)
+	} else if g, ok := value.(*Graph); !ok {
+		return nil, fmt.Errorf("%s is a %s, not a graph", name, typeName(value))
+	} else {
+		return g, nil
+	}
+}
+
+// evaluateNode evaluates an expression as a node
+func (i *Interpreter) evaluateNode(ctx context.Context, expr expression) (Node, error) {
+	value, errValue := i.evaluate(ctx, expr)
+	if errValue != nil {
+		return Node{}, errValue
+	} else if id, ok := nodeId(value); !ok {
+		return Node{}, RuntimeError{Position: expr.position(), Message: "expected a node id, got " + typeName(value)}
+	} else {
+		return internal.NewIdNode(id), nil
+	}
+}
+
+// evaluate returns the value of an expression
+func (i *Interpreter) evaluate(ctx context.Context, expr expression) (Value, error) {
+	switch e := expr.(type) {
+	case numberLiteral:
+		return e.value, nil
+	case stringLiteral:
+		return e.value, nil
+	case variable:
+		if value, found := i.variables[e.name]; found {
+			return value, nil
+		}
+
+		return nil, RuntimeError{Position: e.at, Message: "unknown variable " + e.name}
+	case listLiteral:
+		result := make([]Value, len(e.values))
+		for index, element := range e.values {
+			value, errValue := i.evaluate(ctx, element)
+			if errValue != nil {
+				return nil, errValue
+			}
+
+			result[index] = value
+		}
+
+		return result, nil
+	case negation:
+		value, errValue := i.evaluate(ctx, e.operand)
+		if errValue != nil {
+			return nil, errValue
+		} else if number, ok := value.(float64); ok {
+			return -number, nil
+		}
+
+		return nil, RuntimeError{Position: e.at, Message: "cannot negate a " + typeName(value)}
+	case binary:
+		return i.evaluateBinary(ctx, e)
+	case field:
+		value, errValue := i.evaluate(ctx, e.target)
+		if errValue != nil {
+			return nil, errValue
+		} else if record, ok := value.(Record); !ok {
+			return nil, RuntimeError{Position: e.at, Message: fmt.Sprintf("cannot read field %s of a %s", e.name, typeName(value))}
+		} else if result, found := record.Values[e.name]; !found {
+			return nil, RuntimeError{Position: e.at, Message: fmt.Sprintf("no field %s, expecting one of %s", e.name, strings.Join(record.Fields, ", "))}
+		} else {
+			return result, nil
+		}
+	case call:
+		return i.evaluateCall(ctx, e)
+	default:
+		return nil, fmt.Errorf("unknown expression %T", expr)
+	}
+}
+
+// evaluateBinary returns the result of an operation.
+// Operations apply to numbers, and + concatenates as soon as one side is a string
+func (i *Interpreter) evaluateBinary(ctx context.Context, e binary) (Value, error) {
+	left, errLeft := i.evaluate(ctx, e.left)
+	if errLeft != nil {
+		return nil, errLeft
+	}
+
+	right, errRight := i.evaluate(ctx, e.right)
+	if errRight != nil {
+		return nil, errRight
+	}
+
+	leftNumber, leftOk := left.(float64)
+	rightNumber, rightOk := right.(float64)
+	_, leftString := left.(string)
+	_, rightString := right.(string)
+	switch {
+	case e.operator == "+" && (leftString || rightString):
+		return Format(left) + Format(right), nil
+	case !leftOk || !rightOk:
+		return nil, RuntimeError{Position: e.at, Message: fmt.Sprintf("cannot apply %s to %s and %s", e.operator, typeName(left), typeName(right))}
+	case e.operator == "+":
+		return leftNumber + rightNumber, nil
+	case e.operator == "-":
+		return leftNumber - rightNumber, nil
+	case e.operator == "*":
+		return leftNumber * rightNumber, nil
+	case rightNumber == 0:
+		return nil, RuntimeError{Position: e.at, Message: "division by zero"}
+	default:
+		return leftNumber / rightNumber, nil
+	}
+}
+
+// evaluateCall calls a built in function
+func (i *Interpreter) evaluateCall(ctx context.Context, e call) (Value, error) {
+	function, found := builtins[e.name]
+	if !found {
+		names := make([]string, 0, len(builtins))
+		for name := range builtins {
+			names = append(names, name)
+		}
+
+		slices.Sort(names)
+		return nil, RuntimeError{Position: e.at, Message: fmt.Sprintf("unknown function %s, expecting one of %s", e.name, strings.Join(names, ", "))}
+	}
+
+	expected := len(function.arguments)
+	if len(e.arguments) > expected || len(e.arguments) < expected-function.optional {
+		return nil, RuntimeError{Position: e.at, Message: fmt.Sprintf("%s(%s) expects %d arguments, got %d", e.name, strings.Join(function.arguments, ", "), expected, len(e.arguments))}
+	}
+
+	args := make([]Value, len(e.arguments))
+	for index, argument := range e.arguments {
+		value, errValue := i.evaluate(ctx, argument)
+		if errValue != nil {
+			return nil, errValue
+		}
+
+		args[index] = value
+	}
+
+	result, errCall := function.apply(ctx, args)
+	if errCall != nil {
+		return nil, i.locate(e.at, fmt.Errorf("%s: %w", e.name, errCall))
+	}
+
+	return result, nil
+}
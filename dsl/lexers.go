@@ -0,0 +1,196 @@
+package dsl
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Position is a position in a script, line and column start at 1
+type Position struct {
+	// Line of the position
+	Line int
+	// Column of the position, in runes
+	Column int
+}
+
+// String returns line:column
+func (p Position) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// ParseError is an error in the text of a script, with its position
+type ParseError struct {
+	// Position of the error
+	Position Position
+	// Message describes the error
+	Message string
+}
+
+// Error returns line:column: message
+func (pe ParseError) Error() string {
+	return fmt.Sprintf("%s: %s", pe.Position, pe.Message)
+}
+
+// tokenType is the type of a token
+type tokenType int
+
+const (
+	tokenEOF tokenType = iota
+	tokenNewline
+	tokenIdentifier
+	tokenKeyword
+	tokenNumber
+	tokenString
+	tokenSymbol
+)
+
+// keywords are the identifiers reserved for statements
+var keywords = []string{"let", "graph", "directed", "node", "link", "unlink", "remove", "weight", "print", "export", "for", "in"}
+
+// symbols are the punctuation and operators, longest first
+var symbols = []string{"->", "(", ")", "[", "]", "{", "}", ",", ";", "=", "+", "-", "*", "/", "."}
+
+// token is a lexical unit of a script
+type token struct {
+	// kind is the type of the token
+	kind tokenType
+	// text is the raw text, or the unquoted value for strings
+	text string
+	// number is the value of number tokens
+	number float64
+	// position is the position of the first character
+	position Position
+}
+
+// describe returns the token as written in error messages
+func (t token) describe() string {
+	switch t.kind {
+	case tokenEOF:
+		return "end of script"
+	case tokenNewline:
+		return "end of line"
+	case tokenString:
+		return strconv.Quote(t.text)
+	default:
+		return fmt.Sprintf("%q", t.text)
+	}
+}
+
+// is returns true for a keyword or a symbol with that text
+func (t token) is(text string) bool {
+	return (t.kind == tokenKeyword || t.kind == tokenSymbol) && t.text == text
+}
+
+// lex splits a script into tokens, last one is always tokenEOF.
+// Comments start with # and end with the line
+func lex(source string) ([]token, error) {
+	runes := []rune(source)
+	result := make([]token, 0)
+	line, column := 1, 1
+	index := 0
+
+	// advance moves forward by size runes, on the same line
+	advance := func(size int) {
+		index += size
+		column += size
+	}
+
+	for index < len(runes) {
+		current := runes[index]
+		position := Position{Line: line, Column: column}
+		switch {
+		case current == '\n':
+			result = append(result, token{kind: tokenNewline, text: "\n", position: position})
+			index++
+			line, column = line+1, 1
+		case unicode.IsSpace(current):
+			advance(1)
+		case current == '#':
+			for index < len(runes) && runes[index] != '\n' {
+				advance(1)
+			}
+		case current == '"':
+			end := index + 1
+			for end < len(runes) && runes[end] != '"' && runes[end] != '\n' {
+				if runes[end] == '\\' {
+					end++
+				}
+
+				end++
+			}
+
+			if end >= len(runes) || runes[end] != '"' {
+				return nil, ParseError{Position: position, Message: "unterminated string"}
+			}
+
+			value, errUnquote := strconv.Unquote(string(runes[index : end+1]))
+			if errUnquote != nil {
+				return nil, ParseError{Position: position, Message: "invalid string " + string(runes[index:end+1])}
+			}
+
+			result = append(result, token{kind: tokenString, text: value, position: position})
+			advance(end + 1 - index)
+		case unicode.IsDigit(current):
+			end := index
+			for end < len(runes) && (unicode.IsDigit(runes[end]) || runes[end] == '.' || runes[end] == '_') {
+				end++
+			}
+
+			// exponent, such as 1e-3
+			if end < len(runes) && (runes[end] == 'e' || runes[end] == 'E') {
+				end++
+				if end < len(runes) && (runes[end] == '-' || runes[end] == '+') {
+					end++
+				}
+
+				for end < len(runes) && unicode.IsDigit(runes[end]) {
+					end++
+				}
+			}
+
+			text := string(runes[index:end])
+			value, errParse := strconv.ParseFloat(text, 64)
+			if errParse != nil {
+				return nil, ParseError{Position: position, Message: "invalid number " + text}
+			}
+
+			result = append(result, token{kind: tokenNumber, text: text, number: value, position: position})
+			advance(end - index)
+		case unicode.IsLetter(current) || current == '_':
+			end := index
+			for end < len(runes) && (unicode.IsLetter(runes[end]) || unicode.IsDigit(runes[end]) || runes[end] == '_') {
+				end++
+			}
+
+			text := string(runes[index:end])
+			kind := tokenIdentifier
+			for _, keyword := range keywords {
+				if keyword == text {
+					kind = tokenKeyword
+				}
+			}
+
+			result = append(result, token{kind: kind, text: text, position: position})
+			advance(end - index)
+		default:
+			found := false
+			for _, symbol := range symbols {
+				if strings.HasPrefix(string(runes[index:min(index+len(symbol), len(runes))]), symbol) {
+					result = append(result, token{kind: tokenSymbol, text: symbol, position: position})
+					advance(len([]rune(symbol)))
+					found = true
+					break
+				}
+			}
+
+			if !found {
+				return nil, ParseError{Position: position, Message: fmt.Sprintf("unexpected character %q", current)}
+			}
+		}
+	}
+
+	result = append(result, token{kind: tokenEOF, position: Position{Line: line, Column: column}})
+	return result, nil
+}
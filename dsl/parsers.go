@@ -0,0 +1,534 @@
+package dsl
+
+import "fmt"
+
+// Program is a parsed script, ready to run
+type Program struct {
+	// statements of the script, in order
+	statements []statement
+}
+
+// statement is an instruction of a script
+type statement interface {
+	// position returns the position of the first token of the statement
+	position() Position
+}
+
+// expression is a part of a statement that evaluates to a value
+type expression interface {
+	// position returns the position of the first token of the expression
+	position() Position
+}
+
+// letStatement is "let name = value"
+type letStatement struct {
+	at    Position
+	name  string
+	value expression
+}
+
+// graphStatement is "graph name [directed] [= value]"
+type graphStatement struct {
+	at       Position
+	name     string
+	directed bool
+	value    expression
+}
+
+// nodeStatement is "node graph id, id..."
+type nodeStatement struct {
+	at    Position
+	graph string
+	nodes []expression
+}
+
+// linkStatement is "link graph source -> target [weight value]"
+type linkStatement struct {
+	at     Position
+	graph  string
+	source expression
+	target expression
+	weight expression
+}
+
+// unlinkStatement is "unlink graph source -> target"
+type unlinkStatement struct {
+	at     Position
+	graph  string
+	source expression
+	target expression
+}
+
+// removeStatement is "remove graph id"
+type removeStatement struct {
+	at    Position
+	graph string
+	node  expression
+}
+
+// printStatement is "print value, value..."
+type printStatement struct {
+	at     Position
+	values []expression
+}
+
+// exportStatement is "export graph path"
+type exportStatement struct {
+	at    Position
+	graph string
+	path  expression
+}
+
+// forStatement is "for name in values { body }"
+type forStatement struct {
+	at       Position
+	variable string
+	values   expression
+	body     []statement
+}
+
+func (s letStatement) position() Position    { return s.at }
+func (s graphStatement) position() Position  { return s.at }
+func (s nodeStatement) position() Position   { return s.at }
+func (s linkStatement) position() Position   { return s.at }
+func (s unlinkStatement) position() Position { return s.at }
+func (s removeStatement) position() Position { return s.at }
+func (s printStatement) position() Position  { return s.at }
+func (s exportStatement) position() Position { return s.at }
+func (s forStatement) position() Position    { return s.at }
+
+// numberLiteral is a number, such as 0.5
+type numberLiteral struct {
+	at    Position
+	value float64
+}
+
+// stringLiteral is a quoted string
+type stringLiteral struct {
+	at    Position
+	value string
+}
+
+// variable is the name of a variable
+type variable struct {
+	at   Position
+	name string
+}
+
+// listLiteral is [value, value...]
+type listLiteral struct {
+	at     Position
+	values []expression
+}
+
+// call is name(arguments...), for built in functions
+type call struct {
+	at        Position
+	name      string
+	arguments []expression
+}
+
+// binary is left operator right, for + - * /
+type binary struct {
+	at       Position
+	operator string
+	left     expression
+	right    expression
+}
+
+// negation is -value
+type negation struct {
+	at      Position
+	operand expression
+}
+
+// field is value.name, to read a field of a record
+type field struct {
+	at     Position
+	target expression
+	name   string
+}
+
+func (e numberLiteral) position() Position { return e.at }
+func (e stringLiteral) position() Position { return e.at }
+func (e variable) position() Position      { return e.at }
+func (e listLiteral) position() Position   { return e.at }
+func (e call) position() Position          { return e.at }
+func (e binary) position() Position        { return e.at }
+func (e negation) position() Position      { return e.at }
+func (e field) position() Position         { return e.at }
+
+// parser reads tokens to build a program
+type parser struct {
+	// tokens of the script, last one is tokenEOF
+	tokens []token
+	// index is the index of the current token
+	index int
+	// nesting is the number of open parenthesis and brackets, new lines are ignored within them
+	nesting int
+}
+
+// Parse parses a script. Error is a ParseError with the line and column of the problem
+func Parse(source string) (*Program, error) {
+	tokens, errLex := lex(source)
+	if errLex != nil {
+		return nil, errLex
+	}
+
+	p := parser{tokens: tokens}
+	statements, errParse := p.parseStatements(false)
+	if errParse != nil {
+		return nil, errParse
+	}
+
+	return &Program{statements: statements}, nil
+}
+
+// current returns the current token, skipping new lines within parenthesis and brackets
+func (p *parser) current() token {
+	for p.nesting > 0 && p.tokens[p.index].kind == tokenNewline {
+		p.index++
+	}
+
+	return p.tokens[p.index]
+}
+
+// next returns the current token and moves to the next one
+func (p *parser) next() token {
+	result := p.current()
+	if result.kind != tokenEOF {
+		p.index++
+	}
+
+	return result
+}
+
+// errorAt returns a parse error at the position of a token
+func (p *parser) errorAt(t token, format string, args ...any) error {
+	return ParseError{Position: t.position, Message: fmt.Sprintf(format, args...)}
+}
+
+// expect consumes the keyword or symbol text, or fails
+func (p *parser) expect(text string) (token, error) {
+	if current := p.current(); !current.is(text) {
+		return current, p.errorAt(current, "expected %q, got %s", text, current.describe())
+	}
+
+	return p.next(), nil
+}
+
+// expectIdentifier consumes an identifier, or fails
+func (p *parser) expectIdentifier(role string) (token, error) {
+	if current := p.current(); current.kind != tokenIdentifier {
+		return current, p.errorAt(current, "expected %s, got %s", role, current.describe())
+	}
+
+	return p.next(), nil
+}
+
+// parseStatements parses statements until end of script, or until closing brace for a block
+func (p *parser) parseStatements(block bool) ([]statement, error) {
+	result := make([]statement, 0)
+	for {
+		current := p.current()
+		switch {
+		case current.kind == tokenNewline || current.is(";"):
+			p.next()
+			continue
+		case current.kind == tokenEOF && block:
+			return nil, p.errorAt(current, "expected \"}\", got %s", current.describe())
+		case current.kind == tokenEOF:
+			return result, nil
+		case current.is("}") && block:
+			return result, nil
+		}
+
+		parsed, errParse := p.parseStatement()
+		if errParse != nil {
+			return nil, errParse
+		}
+
+		result = append(result, parsed)
+		// a statement ends with a new line, a semicolon, a closing brace or the end of the script
+		if end := p.current(); end.kind != tokenNewline && end.kind != tokenEOF && !end.is(";") && !end.is("}") {
+			return nil, p.errorAt(end, "unexpected %s after statement", end.describe())
+		}
+	}
+}
+
+// parseStatement parses a statement, based on its first keyword
+func (p *parser) parseStatement() (statement, error) {
+	first := p.current()
+	if first.kind != tokenKeyword {
+		return nil, p.errorAt(first, "expected a statement, got %s", first.describe())
+	}
+
+	p.next()
+	switch first.text {
+	case "let":
+		name, errName := p.expectIdentifier("variable name")
+		if errName != nil {
+			return nil, errName
+		} else if _, err := p.expect("="); err != nil {
+			return nil, err
+		}
+
+		value, errValue := p.parseExpression()
+		return letStatement{at: first.position, name: name.text, value: value}, errValue
+	case "graph":
+		name, errName := p.expectIdentifier("graph name")
+		if errName != nil {
+			return nil, errName
+		}
+
+		result := graphStatement{at: first.position, name: name.text}
+		if p.current().is("directed") {
+			p.next()
+			result.directed = true
+		}
+
+		if p.current().is("=") {
+			p.next()
+			value, errValue := p.parseExpression()
+			if errValue != nil {
+				return nil, errValue
+			}
+
+			result.value = value
+		}
+
+		return result, nil
+	case "node":
+		name, errName := p.expectIdentifier("graph name")
+		if errName != nil {
+			return nil, errName
+		}
+
+		nodes, errNodes := p.parseExpressions()
+		return nodeStatement{at: first.position, graph: name.text, nodes: nodes}, errNodes
+	case "link", "unlink":
+		name, errName := p.expectIdentifier("graph name")
+		if errName != nil {
+			return nil, errName
+		}
+
+		source, errSource := p.parseExpression()
+		if errSource != nil {
+			return nil, errSource
+		} else if _, err := p.expect("->"); err != nil {
+			return nil, err
+		}
+
+		target, errTarget := p.parseExpression()
+		if errTarget != nil {
+			return nil, errTarget
+		} else if first.text == "unlink" {
+			return unlinkStatement{at: first.position, graph: name.text, source: source, target: target}, nil
+		}
+
+		result := linkStatement{at: first.position, graph: name.text, source: source, target: target}
+		if p.current().is("weight") {
+			p.next()
+			weight, errWeight := p.parseExpression()
+			if errWeight != nil {
+				return nil, errWeight
+			}
+
+			result.weight = weight
+		}
+
+		return result, nil
+	case "remove":
+		name, errName := p.expectIdentifier("graph name")
+		if errName != nil {
+			return nil, errName
+		}
+
+		node, errNode := p.parseExpression()
+		return removeStatement{at: first.position, graph: name.text, node: node}, errNode
+	case "print":
+		values, errValues := p.parseExpressions()
+		return printStatement{at: first.position, values: values}, errValues
+	case "export":
+		name, errName := p.expectIdentifier("graph name")
+		if errName != nil {
+			return nil, errName
+		}
+
+		path, errPath := p.parseExpression()
+		return exportStatement{at: first.position, graph: name.text, path: path}, errPath
+	case "for":
+		name, errName := p.expectIdentifier("loop variable")
+		if errName != nil {
+			return nil, errName
+		} else if _, err := p.expect("in"); err != nil {
+			return nil, err
+		}
+
+		values, errValues := p.parseExpression()
+		if errValues != nil {
+			return nil, errValues
+		} else if _, err := p.expect("{"); err != nil {
+			return nil, err
+		}
+
+		body, errBody := p.parseStatements(true)
+		if errBody != nil {
+			return nil, errBody
+		} else if _, err := p.expect("}"); err != nil {
+			return nil, err
+		}
+
+		return forStatement{at: first.position, variable: name.text, values: values, body: body}, nil
+	default:
+		return nil, p.errorAt(first, "expected a statement, got %s", first.describe())
+	}
+}
+
+// parseExpressions parses a comma separated list of at least one expression
+func (p *parser) parseExpressions() ([]expression, error) {
+	result := make([]expression, 0)
+	for {
+		value, errValue := p.parseExpression()
+		if errValue != nil {
+			return nil, errValue
+		}
+
+		result = append(result, value)
+		if !p.current().is(",") {
+			return result, nil
+		}
+
+		// a list may go on next line after a comma
+		p.next()
+		for p.tokens[p.index].kind == tokenNewline {
+			p.index++
+		}
+	}
+}
+
+// parseExpression parses additions and subtractions, lowest precedence
+func (p *parser) parseExpression() (expression, error) {
+	left, errLeft := p.parseTerm()
+	if errLeft != nil {
+		return nil, errLeft
+	}
+
+	for p.current().is("+") || p.current().is("-") {
+		operator := p.next()
+		right, errRight := p.parseTerm()
+		if errRight != nil {
+			return nil, errRight
+		}
+
+		left = binary{at: operator.position, operator: operator.text, left: left, right: right}
+	}
+
+	return left, nil
+}
+
+// parseTerm parses multiplications and divisions
+func (p *parser) parseTerm() (expression, error) {
+	left, errLeft := p.parseUnary()
+	if errLeft != nil {
+		return nil, errLeft
+	}
+
+	for p.current().is("*") || p.current().is("/") {
+		operator := p.next()
+		right, errRight := p.parseUnary()
+		if errRight != nil {
+			return nil, errRight
+		}
+
+		left = binary{at: operator.position, operator: operator.text, left: left, right: right}
+	}
+
+	return left, nil
+}
+
+// parseUnary parses negations and field accesses
+func (p *parser) parseUnary() (expression, error) {
+	if current := p.current(); current.is("-") {
+		p.next()
+		operand, errOperand := p.parseUnary()
+		return negation{at: current.position, operand: operand}, errOperand
+	}
+
+	result, errPrimary := p.parsePrimary()
+	if errPrimary != nil {
+		return nil, errPrimary
+	}
+
+	for p.current().is(".") {
+		dot := p.next()
+		name, errName := p.expectIdentifier("field name")
+		if errName != nil {
+			return nil, errName
+		}
+
+		result = field{at: dot.position, target: result, name: name.text}
+	}
+
+	return result, nil
+}
+
+// parsePrimary parses literals, variables, calls, lists and parenthesis
+func (p *parser) parsePrimary() (expression, error) {
+	current := p.next()
+	switch {
+	case current.kind == tokenNumber:
+		return numberLiteral{at: current.position, value: current.number}, nil
+	case current.kind == tokenString:
+		return stringLiteral{at: current.position, value: current.text}, nil
+	case current.kind == tokenIdentifier && p.current().is("("):
+		arguments, errArguments := p.parseEnclosed("(", ")")
+		return call{at: current.position, name: current.text, arguments: arguments}, errArguments
+	case current.kind == tokenIdentifier:
+		return variable{at: current.position, name: current.text}, nil
+	case current.is("["):
+		p.index--
+		values, errValues := p.parseEnclosed("[", "]")
+		return listLiteral{at: current.position, values: values}, errValues
+	case current.is("("):
+		p.nesting++
+		value, errValue := p.parseExpression()
+		if errValue != nil {
+			return nil, errValue
+		}
+
+		if _, err := p.expect(")"); err != nil {
+			return nil, err
+		}
+
+		p.nesting--
+		return value, nil
+	default:
+		return nil, p.errorAt(current, "expected a value, got %s", current.describe())
+	}
+}
+
+// parseEnclosed parses a possibly empty comma separated list of expressions between open and close
+func (p *parser) parseEnclosed(open, close string) ([]expression, error) {
+	if _, err := p.expect(open); err != nil {
+		return nil, err
+	}
+
+	p.nesting++
+	result := make([]expression, 0)
+	if !p.current().is(close) {
+		values, errValues := p.parseExpressions()
+		if errValues != nil {
+			return nil, errValues
+		}
+
+		result = values
+	}
+
+	if _, err := p.expect(close); err != nil {
+		return nil, err
+	}
+
+	p.nesting--
+	return result, nil
+}
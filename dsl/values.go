@@ -0,0 +1,168 @@
+package dsl
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/zefrenchwan/nodz.git/graphs"
+	"github.com/zefrenchwan/nodz.git/internal"
+	"github.com/zefrenchwan/nodz.git/internal/local"
+)
+
+// Node is the node of script graphs: nodes are identified by their id
+type Node = internal.IdNode
+
+// Link is the link of script graphs: directed or not, with a weight
+type Link = internal.ValuedLink[Node, float64]
+
+// Value is the value of an expression. It is one of:
+// float64 for numbers, string, *Graph, []Value for lists, Record
+type Value any
+
+// Record is a value with named fields, such as statistics
+type Record struct {
+	// Fields are the names of the fields, in display order
+	Fields []string
+	// Values are the values of the fields
+	Values map[string]Value
+}
+
+// newRecord returns an empty record
+func newRecord() Record {
+	return Record{Fields: make([]string, 0), Values: make(map[string]Value)}
+}
+
+// set sets the value of a field, adding the field if needed
+func (r *Record) set(name string, value Value) {
+	if _, found := r.Values[name]; !found {
+		r.Fields = append(r.Fields, name)
+	}
+
+	r.Values[name] = value
+}
+
+// Graph is a graph value of a script
+type Graph struct {
+	// directed is true for a graph of directed links
+	directed bool
+	// content is the graph per se
+	content *local.MapGraph[Node, Link]
+}
+
+// newGraph returns an empty graph
+func newGraph(directed bool) *Graph {
+	content := local.NewMapGraph[Node, Link]()
+	return &Graph{directed: directed, content: &content}
+}
+
+// Directed returns true for a graph of directed links
+func (g *Graph) Directed() bool {
+	return g.directed
+}
+
+// Content returns the graph, for any algorithm of graphs package
+func (g *Graph) Content() graphs.CentralStructureGraph[Node, Link] {
+	return g.content
+}
+
+// Size returns the number of nodes and the number of links, undirected links count once
+func (g *Graph) Size() (int, int) {
+	var nodes, links int
+	for range g.content.All() {
+		nodes++
+	}
+
+	for range g.content.Links() {
+		links++
+	}
+
+	return nodes, links
+}
+
+// newLink returns a link of the graph type
+func (g *Graph) newLink(source, target Node, weight float64) Link {
+	if g.directed {
+		return internal.NewDirectedValuedLink(source, target, weight)
+	}
+
+	return internal.NewUndirectedValuedLink(source, target, weight)
+}
+
+// findLink returns the link from source to target (either way for undirected graphs)
+func (g *Graph) findLink(source, target Node) (Link, bool, error) {
+	return graphs.FindLinkFunc(g.content, source, func(current Link) bool {
+		return current.Source().SameNode(source) && current.Destination().SameNode(target) ||
+			!g.directed && current.Source().SameNode(target) && current.Destination().SameNode(source)
+	})
+}
+
+// Format returns the value as printed by scripts
+func Format(value Value) string {
+	switch v := value.(type) {
+	case nil:
+		return "nothing"
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case string:
+		return v
+	case *Graph:
+		nodes, links := v.Size()
+		kind := "undirected"
+		if v.directed {
+			kind = "directed"
+		}
+
+		return fmt.Sprintf("graph(%s, %d nodes, %d links)", kind, nodes, links)
+	case []Value:
+		parts := make([]string, len(v))
+		for index, element := range v {
+			if text, ok := element.(string); ok {
+				parts[index] = strconv.Quote(text)
+			} else {
+				parts[index] = Format(element)
+			}
+		}
+
+		return "[" + strings.Join(parts, ", ") + "]"
+	case Record:
+		parts := make([]string, len(v.Fields))
+		for index, name := range v.Fields {
+			parts[index] = name + "=" + Format(v.Values[name])
+		}
+
+		return strings.Join(parts, " ")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// typeName returns the name of the type of a value, for error messages
+func typeName(value Value) string {
+	switch value.(type) {
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case *Graph:
+		return "graph"
+	case []Value:
+		return "list"
+	case Record:
+		return "record"
+	default:
+		return "nothing"
+	}
+}
+
+// nodeId returns the id of a node given as a string or an integer
+func nodeId(value Value) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case float64:
+		return Format(v), true
+	default:
+		return "", false
+	}
+}
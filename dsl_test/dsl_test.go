@@ -0,0 +1,286 @@
+package dsl_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/zefrenchwan/nodz.git/dsl"
+)
+
+// run runs a script with a new interpreter, and returns printed lines
+func run(t *testing.T, script string) ([]string, error) {
+	t.Helper()
+	var output bytes.Buffer
+	err := dsl.NewInterpreter(&output).Run(context.Background(), script)
+	return strings.Split(strings.TrimSpace(output.String()), "\n"), err
+}
+
+func TestParseErrorsPositions(t *testing.T) {
+	cases := map[string]dsl.Position{
+		"let x = 1\nlet = 2":          {Line: 2, Column: 5},
+		"print 1 +\n":                 {Line: 1, Column: 10},
+		"graph g\nnode g \"a\n":       {Line: 2, Column: 8},
+		"for x in [1, 2] {\n print x": {Line: 2, Column: 9},
+		"let x = 1 2":                 {Line: 1, Column: 11},
+		"link g \"a\" \"b\"":          {Line: 1, Column: 12},
+		"print 1 @ 2":                 {Line: 1, Column: 9},
+		"x = 1":                       {Line: 1, Column: 1},
+	}
+
+	for script, expected := range cases {
+		_, err := dsl.Parse(script)
+		var parseErr dsl.ParseError
+		if !errors.As(err, &parseErr) {
+			t.Errorf("%q: expected a parse error, got %v", script, err)
+		} else if parseErr.Position != expected {
+			t.Errorf("%q: expected error at %s, got %s", script, expected, err)
+		} else if !strings.HasPrefix(err.Error(), expected.String()+": ") {
+			t.Errorf("%q: message should start with position, got %s", script, err)
+		}
+	}
+}
+
+func TestParseValidScripts(t *testing.T) {
+	script := `
+# a comment
+let p = 0.5 * (1 + 1e-1)   # trailing comment
+graph g directed
+node g "a", "b",
+	"c"
+link g "a" -> "b" weight 2.5; link g "b" -> "c"
+for n in range(
+	1, 3) {
+	print n, stats(g).nodes
+}
+`
+	if _, err := dsl.Parse(script); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInterpreterStatements(t *testing.T) {
+	lines, err := run(t, `
+graph g
+node g "a", "b", 3
+link g "a" -> "b"
+link g "b" -> 3 weight 2
+link g 3 -> "d"
+print g
+print degree(g, "b"), path(g, "a", "d"), distance(g, "d", "a")
+unlink g "b" -> "a"
+remove g "d"
+print nodes(g), links(g), len(path(g, "a", 3))
+link g 3 -> "b" weight 5
+print links(g)
+`)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"graph(undirected, 4 nodes, 3 links)",
+		`2 ["a", "b", "3", "d"] 3`,
+		"3 1 0",
+		"1",
+	}
+
+	if strings.Join(lines, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("unexpected output\n%s", strings.Join(lines, "\n"))
+	}
+}
+
+func TestInterpreterGenerators(t *testing.T) {
+	lines, err := run(t, `
+let k = complete(4)
+print stats(k)
+print components(k)
+graph b = ba(3, 20)
+print nodes(b), links(b), components(b).count
+graph d directed = dgnp(5, 1)
+print stats(d).links, stats(d).density
+print nodes(gnp(6, 0)), links(gnp(6, 1))
+`)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"nodes=4 links=6 average_degree=3 density=1 max_degree=3",
+		"count=1 largest=4 sizes=[4]",
+		"20 20 1",
+		"20 1",
+		"6 15",
+	}
+
+	if strings.Join(lines, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("unexpected output\n%s", strings.Join(lines, "\n"))
+	}
+}
+
+func TestInterpreterLoops(t *testing.T) {
+	lines, err := run(t, `
+let total = 0
+for p in range(0, 0.5, 0.1) {
+	let total = total + p
+	print "p=" + p, nodes(gnp(p * 10, p))
+}
+print total
+for x in [] { print "never" }
+print range(3, 0, -1)
+`)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"p=0 0", "p=0.1 1", "p=0.2 2", "p=0.3 3", "p=0.4 4", "1", "[3, 2, 1]"}
+	if strings.Join(lines, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("unexpected output\n%s", strings.Join(lines, "\n"))
+	}
+}
+
+func TestInterpreterRuntimeErrors(t *testing.T) {
+	cases := map[string]dsl.Position{
+		"print x":                                 {Line: 1, Column: 7},
+		"let x = 1\n\nprint x / 0":                {Line: 3, Column: 9},
+		"graph d directed = gnp(3, 0.5)":          {Line: 1, Column: 1},
+		"graph g\nremove g \"a\"":                 {Line: 2, Column: 1},
+		"let g = complete(3)\nprint stats(g).foo": {Line: 2, Column: 15},
+		"print unknown(1)":                        {Line: 1, Column: 7},
+		"print gnp(1)":                            {Line: 1, Column: 7},
+		"print gnp(10, 2)":                        {Line: 1, Column: 7},
+		"print components(dgnp(3, 1))":            {Line: 1, Column: 7},
+		"for x in 3 { print x }":                  {Line: 1, Column: 1},
+		"let x = 1\nnode x \"a\"":                 {Line: 2, Column: 1},
+		"graph g\nexport g \"out.svg\"":           {Line: 2, Column: 1},
+	}
+
+	for script, expected := range cases {
+		_, err := run(t, script)
+		var runtimeErr dsl.RuntimeError
+		if !errors.As(err, &runtimeErr) {
+			t.Errorf("%q: expected a runtime error, got %v", script, err)
+		} else if runtimeErr.Position != expected {
+			t.Errorf("%q: expected error at %s, got %s", script, expected, err)
+		}
+	}
+}
+
+func TestInterpreterSession(t *testing.T) {
+	var output bytes.Buffer
+	interpreter := dsl.NewInterpreter(&output)
+	if err := interpreter.Run(context.Background(), "graph g = complete(3)"); err != nil {
+		t.Fatal(err)
+	} else if err := interpreter.Run(context.Background(), "let n = nodes(g)\nprint n"); err != nil {
+		t.Fatal(err)
+	} else if output.String() != "3\n" {
+		t.Errorf("unexpected output %q", output.String())
+	}
+
+	if names := interpreter.Variables(); strings.Join(names, ",") != "g,n" {
+		t.Errorf("unexpected variables %v", names)
+	} else if value, found := interpreter.Variable("g"); !found {
+		t.Error("expected graph g")
+	} else if g, ok := value.(*dsl.Graph); !ok || g.Directed() {
+		t.Errorf("unexpected value %v", value)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := interpreter.Run(ctx, "print 1"); !strings.Contains(err.Error(), context.Canceled.Error()) {
+		t.Errorf("expected cancellation, got %v", err)
+	}
+}
+
+func TestInterpreterExports(t *testing.T) {
+	interpreter := dsl.NewInterpreter(nil)
+	interpreter.Directory = t.TempDir()
+	script := `
+graph g = complete(3)
+export g "g.gexf"
+export g "g.json"
+export g "g.jgf"
+export g "g.csv"
+export g "g.edges"
+`
+	if err := interpreter.Run(context.Background(), script); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"g.gexf", "g.json", "g.jgf", "g.csv", "g.edges"} {
+		content, err := os.ReadFile(filepath.Join(interpreter.Directory, name))
+		if err != nil {
+			t.Error(err)
+		} else if len(content) == 0 {
+			t.Errorf("%s should not be empty", name)
+		}
+	}
+
+	content, _ := os.ReadFile(filepath.Join(interpreter.Directory, "g.csv"))
+	if lines := strings.Split(strings.TrimSpace(string(content)), "\n"); len(lines) != 4 || lines[0] != "source,target,weight" {
+		t.Errorf("unexpected csv content %q", content)
+	}
+
+	// exports stay in the directory
+	inside := filepath.Join(interpreter.Directory, "inside.json")
+	if err := interpreter.Run(context.Background(), fmt.Sprintf("export g %q", inside)); err != nil {
+		t.Errorf("absolute path in directory should be accepted: %v", err)
+	}
+
+	for _, path := range []string{"../escaped.json", "sub/../../escaped.json", filepath.Join(filepath.Dir(interpreter.Directory), "escaped.json")} {
+		if err := interpreter.Run(context.Background(), fmt.Sprintf("export g %q", path)); err == nil || !strings.Contains(err.Error(), "outside") {
+			t.Errorf("%s: expected an error, got %v", path, err)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(filepath.Dir(interpreter.Directory), "escaped.json")); err == nil {
+		t.Error("no file should be written outside of directory")
+	}
+}
+
+func TestHelp(t *testing.T) {
+	help := dsl.Help()
+	for _, expected := range []string{"gnp(size, probability)", "range(start, end, [step])", "path(graph, source, target)"} {
+		if !strings.Contains(help, expected) {
+			t.Errorf("help should contain %s", expected)
+		}
+	}
+}
+
+func TestInterpreterPrinter(t *testing.T) {
+	var output bytes.Buffer
+	var printed [][]dsl.Value
+	interpreter := dsl.NewInterpreter(&output)
+	interpreter.Printer = func(values []dsl.Value) error {
+		printed = append(printed, values)
+		return nil
+	}
+
+	if err := interpreter.Run(context.Background(), "graph g = complete(3)\nprint g, stats(g), 1\nprint \"done\""); err != nil {
+		t.Fatal(err)
+	} else if output.Len() != 0 {
+		t.Errorf("printer should replace output, got %q", output.String())
+	} else if len(printed) != 2 || len(printed[0]) != 3 || printed[1][0] != "done" {
+		t.Fatalf("unexpected printed values %v", printed)
+	}
+
+	if _, ok := printed[0][0].(*dsl.Graph); !ok {
+		t.Errorf("expected a graph, got %v", printed[0][0])
+	} else if record, ok := printed[0][1].(dsl.Record); !ok || record.Values["nodes"] != 3.0 {
+		t.Errorf("expected statistics, got %v", printed[0][1])
+	}
+
+	interpreter.Printer = func([]dsl.Value) error { return errors.New("closed") }
+	var runtimeErr dsl.RuntimeError
+	if err := interpreter.Run(context.Background(), "\nprint 1"); !errors.As(err, &runtimeErr) || runtimeErr.Position.Line != 2 {
+		t.Errorf("printer error should be located, got %v", err)
+	}
+}
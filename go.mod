@@ -1,5 +1,5 @@
 module github.com/zefrenchwan/nodz.git
 
-go 1.22.0
+go 1.23.0
 
 require github.com/google/uuid v1.6.0 // direct
@@ -0,0 +1,472 @@
+package graphs
+
+import (
+	"errors"
+)
+
+// ErrorPolicy defines what combinators do with errors (of the source iterator or of the functions they apply)
+type ErrorPolicy int
+
+const (
+	// FailFast stops at the first error: Next returns false and the error, then iterator is done
+	FailFast ErrorPolicy = iota
+	// CollectErrors skips failing elements and goes on.
+	// Errors are joined and returned by the last Next (the one returning false), and by Err
+	CollectErrors
+)
+
+// pullState is the result of pulling an element from a source
+type pullState int
+
+const (
+	// pullEnd means no more element
+	pullEnd pullState = iota
+	// pullValue means an element was pulled
+	pullValue
+	// pullSkip means no element this time, but there may be others
+	pullSkip
+)
+
+// puller returns the next element of a source, with its state and an error, if any.
+// An error may come with any state: with a value, the value is still valid.
+type puller[T any] func() (T, pullState, error)
+
+// pullFrom returns a puller over an iterator
+func pullFrom[T any](it GeneralIterator[T]) puller[T] {
+	return func() (T, pullState, error) {
+		var empty T
+		if it == nil {
+			return empty, pullEnd, nil
+		}
+
+		has, errNext := it.Next()
+		if !has {
+			return empty, pullEnd, errNext
+		}
+
+		value, errValue := it.Value()
+		if errValue != nil {
+			return empty, pullSkip, errors.Join(errNext, errValue)
+		}
+
+		return value, pullValue, errNext
+	}
+}
+
+// Combinator is the iterator the combinators return.
+// It pulls elements on demand, and applies its error policy.
+type Combinator[T any] struct {
+	// pull returns the next element
+	pull puller[T]
+	// policy is the error policy
+	policy ErrorPolicy
+	// current is the current value
+	current T
+	// hasCurrent is true when current is set
+	hasCurrent bool
+	// done is true once iteration is over
+	done bool
+	// errs are the errors so far
+	errs error
+}
+
+// newCombinator returns a combinator over a puller
+func newCombinator[T any](pull puller[T], policy ErrorPolicy) *Combinator[T] {
+	return &Combinator[T]{pull: pull, policy: policy}
+}
+
+// Next moves to the next element, applying the error policy
+func (c *Combinator[T]) Next() (bool, error) {
+	if c == nil {
+		return false, errors.New("nil iterator")
+	}
+
+	c.hasCurrent = false
+	for !c.done {
+		value, state, err := c.pull()
+		if err != nil {
+			c.errs = errors.Join(c.errs, err)
+			if c.policy == FailFast {
+				c.done = true
+				return false, c.errs
+			}
+		}
+
+		switch state {
+		case pullEnd:
+			c.done = true
+		case pullValue:
+			c.current = value
+			c.hasCurrent = true
+			return true, nil
+		}
+	}
+
+	return false, c.errs
+}
+
+// Value returns the current value
+func (c *Combinator[T]) Value() (T, error) {
+	var empty T
+	if c == nil || !c.hasCurrent {
+		return empty, errors.New("no value to return")
+	}
+
+	return c.current, nil
+}
+
+// Err returns the errors so far
+func (c *Combinator[T]) Err() error {
+	if c == nil {
+		return nil
+	}
+
+	return c.errs
+}
+
+// Map applies mapper to each element
+func Map[T, S any](it GeneralIterator[T], mapper func(T) (S, error), policy ErrorPolicy) *Combinator[S] {
+	source := pullFrom(it)
+	return newCombinator(func() (S, pullState, error) {
+		var empty S
+		value, state, err := source()
+		if state != pullValue {
+			return empty, state, err
+		}
+
+		mapped, errMap := mapper(value)
+		if errMap != nil {
+			return empty, pullSkip, errors.Join(err, errMap)
+		}
+
+		return mapped, pullValue, err
+	}, policy)
+}
+
+// Filter keeps elements accepted by predicate
+func Filter[T any](it GeneralIterator[T], predicate func(T) bool, policy ErrorPolicy) *Combinator[T] {
+	source := pullFrom(it)
+	return newCombinator(func() (T, pullState, error) {
+		value, state, err := source()
+		if state == pullValue && !predicate(value) {
+			return value, pullSkip, err
+		}
+
+		return value, state, err
+	}, policy)
+}
+
+// FlatMap maps each element to an iterator, and returns the elements of those iterators, in order
+func FlatMap[T, S any](it GeneralIterator[T], mapper func(T) (GeneralIterator[S], error), policy ErrorPolicy) *Combinator[S] {
+	source := pullFrom(it)
+	var current puller[S]
+	return newCombinator(func() (S, pullState, error) {
+		var empty S
+		if current != nil {
+			value, state, err := current()
+			if state != pullEnd {
+				return value, state, err
+			}
+
+			current = nil
+			if err != nil {
+				return empty, pullSkip, err
+			}
+		}
+
+		value, state, err := source()
+		if state != pullValue {
+			return empty, state, err
+		}
+
+		inner, errMap := mapper(value)
+		if errMap != nil {
+			return empty, pullSkip, errors.Join(err, errMap)
+		}
+
+		current = pullFrom(inner)
+		return empty, pullSkip, err
+	}, policy)
+}
+
+// Take returns the first size elements at most
+func Take[T any](it GeneralIterator[T], size int, policy ErrorPolicy) *Combinator[T] {
+	source := pullFrom(it)
+	taken := 0
+	return newCombinator(func() (T, pullState, error) {
+		var empty T
+		if taken >= size {
+			return empty, pullEnd, nil
+		}
+
+		value, state, err := source()
+		if state == pullValue {
+			taken++
+		}
+
+		return value, state, err
+	}, policy)
+}
+
+// Skip skips the first size elements
+func Skip[T any](it GeneralIterator[T], size int, policy ErrorPolicy) *Combinator[T] {
+	source := pullFrom(it)
+	skipped := 0
+	return newCombinator(func() (T, pullState, error) {
+		value, state, err := source()
+		if state == pullValue && skipped < size {
+			skipped++
+			return value, pullSkip, err
+		}
+
+		return value, state, err
+	}, policy)
+}
+
+// TakeWhile returns elements until predicate rejects one (rejected element is not returned)
+func TakeWhile[T any](it GeneralIterator[T], predicate func(T) bool, policy ErrorPolicy) *Combinator[T] {
+	source := pullFrom(it)
+	over := false
+	return newCombinator(func() (T, pullState, error) {
+		var empty T
+		if over {
+			return empty, pullEnd, nil
+		}
+
+		value, state, err := source()
+		if state == pullValue && !predicate(value) {
+			over = true
+			return empty, pullEnd, err
+		}
+
+		return value, state, err
+	}, policy)
+}
+
+// Distinct returns elements once, elements are compared with equals.
+// Seen elements are kept in a slice, so complexity is quadratic
+func Distinct[T any](it GeneralIterator[T], equals SetEqualsFunction[T], policy ErrorPolicy) *Combinator[T] {
+	source := pullFrom(it)
+	seen := make([]T, 0)
+	return newCombinator(func() (T, pullState, error) {
+		value, state, err := source()
+		if state != pullValue {
+			return value, state, err
+		}
+
+		for _, previous := range seen {
+			if equals(previous, value) {
+				return value, pullSkip, err
+			}
+		}
+
+		seen = append(seen, value)
+		return value, state, err
+	}, policy)
+}
+
+// Chain returns the elements of each iterator, one iterator after the other
+func Chain[T any](policy ErrorPolicy, iterators ...GeneralIterator[T]) *Combinator[T] {
+	index := 0
+	var current puller[T]
+	return newCombinator(func() (T, pullState, error) {
+		var empty T
+		if index >= len(iterators) {
+			return empty, pullEnd, nil
+		} else if current == nil {
+			current = pullFrom(iterators[index])
+		}
+
+		value, state, err := current()
+		if state == pullEnd {
+			index++
+			current = nil
+			return empty, pullSkip, err
+		}
+
+		return value, state, err
+	}, policy)
+}
+
+// Pair is a couple of values, for Zip
+type Pair[A, B any] struct {
+	// First is the value of the first iterator
+	First A
+	// Second is the value of the second iterator
+	Second B
+}
+
+// Zip returns pairs of elements of both iterators, and stops once any iterator is over.
+// A failing element on either side skips the pair
+func Zip[A, B any](first GeneralIterator[A], second GeneralIterator[B], policy ErrorPolicy) *Combinator[Pair[A, B]] {
+	left, right := pullFrom(first), pullFrom(second)
+	return newCombinator(func() (Pair[A, B], pullState, error) {
+		var empty Pair[A, B]
+		a, stateA, errA := left()
+		if stateA == pullEnd {
+			return empty, pullEnd, errA
+		}
+
+		b, stateB, errB := right()
+		err := errors.Join(errA, errB)
+		switch {
+		case stateB == pullEnd:
+			return empty, pullEnd, err
+		case stateA == pullSkip || stateB == pullSkip:
+			return empty, pullSkip, err
+		default:
+			return Pair[A, B]{First: a, Second: b}, pullValue, err
+		}
+	}, policy)
+}
+
+// Chunk groups elements in slices of size elements, last chunk may be smaller
+func Chunk[T any](it GeneralIterator[T], size int, policy ErrorPolicy) *Combinator[[]T] {
+	source := pullFrom(it)
+	size = max(size, 1)
+	over := false
+	return newCombinator(func() ([]T, pullState, error) {
+		if over {
+			return nil, pullEnd, nil
+		}
+
+		var globalErr error
+		chunk := make([]T, 0, size)
+		for len(chunk) < size {
+			value, state, err := source()
+			if err != nil {
+				globalErr = errors.Join(globalErr, err)
+				if policy == FailFast {
+					return nil, pullSkip, globalErr
+				}
+			}
+
+			if state == pullEnd {
+				over = true
+				break
+			} else if state == pullValue {
+				chunk = append(chunk, value)
+			}
+		}
+
+		if len(chunk) == 0 {
+			return nil, pullEnd, globalErr
+		}
+
+		return chunk, pullValue, globalErr
+	}, policy)
+}
+
+// PeekableIterator is an iterator that may read its next value without moving
+type PeekableIterator[T any] struct {
+	// values is the iterator per se
+	values *Combinator[T]
+	// current is the current value
+	current T
+	// hasCurrent is true when current is set
+	hasCurrent bool
+	// peeked is true when next value was read ahead
+	peeked bool
+	// peekedHas is the result of the read ahead
+	peekedHas bool
+	// peekedValue is the value read ahead
+	peekedValue T
+	// peekedErr is the error of the read ahead
+	peekedErr error
+}
+
+// Peekable returns a peekable iterator over it
+func Peekable[T any](it GeneralIterator[T], policy ErrorPolicy) *PeekableIterator[T] {
+	return &PeekableIterator[T]{values: newCombinator(pullFrom(it), policy)}
+}
+
+// Peek returns the next value without moving, and false if there is no next value
+func (pi *PeekableIterator[T]) Peek() (T, bool, error) {
+	if !pi.peeked {
+		pi.peekedHas, pi.peekedErr = pi.values.Next()
+		pi.peekedValue, _ = pi.values.Value()
+		pi.peeked = true
+	}
+
+	return pi.peekedValue, pi.peekedHas, pi.peekedErr
+}
+
+// Next moves to the next value, the peeked one if any
+func (pi *PeekableIterator[T]) Next() (bool, error) {
+	if pi.peeked {
+		pi.peeked = false
+		pi.current, pi.hasCurrent = pi.peekedValue, pi.peekedHas
+		return pi.peekedHas, pi.peekedErr
+	}
+
+	moved, err := pi.values.Next()
+	pi.current, _ = pi.values.Value()
+	pi.hasCurrent = moved
+	return moved, err
+}
+
+// Value returns the current value
+func (pi *PeekableIterator[T]) Value() (T, error) {
+	var empty T
+	if !pi.hasCurrent {
+		return empty, errors.New("no value to return")
+	}
+
+	return pi.current, nil
+}
+
+// Err returns the errors so far
+func (pi *PeekableIterator[T]) Err() error {
+	return pi.values.Err()
+}
+
+// Collect returns all the elements of an iterator.
+// With FailFast, result contains elements before the error
+func Collect[T any](it GeneralIterator[T], policy ErrorPolicy) ([]T, error) {
+	return Reduce(it, make([]T, 0), func(result []T, value T) ([]T, error) {
+		return append(result, value), nil
+	}, policy)
+}
+
+// Reduce folds elements with reducer, from initial value
+func Reduce[T, S any](it GeneralIterator[T], initial S, reducer func(S, T) (S, error), policy ErrorPolicy) (S, error) {
+	result := initial
+	values := newCombinator(pullFrom(it), policy)
+	for has, _ := values.Next(); has; has, _ = values.Next() {
+		value, _ := values.Value()
+		reduced, err := reducer(result, value)
+		if err != nil {
+			values.errs = errors.Join(values.errs, err)
+			if policy == FailFast {
+				return result, values.errs
+			}
+
+			continue
+		}
+
+		result = reduced
+	}
+
+	return result, values.Err()
+}
+
+// Count returns the number of elements
+func Count[T any](it GeneralIterator[T], policy ErrorPolicy) (int64, error) {
+	return Reduce(it, int64(0), func(count int64, _ T) (int64, error) {
+		return count + 1, nil
+	}, policy)
+}
+
+// First returns the first element, and false if there is none.
+// With CollectErrors, failing elements before the first valid one are skipped
+func First[T any](it GeneralIterator[T], policy ErrorPolicy) (T, bool, error) {
+	values := newCombinator(pullFrom(it), policy)
+	has, err := values.Next()
+	if !has {
+		var empty T
+		return empty, false, err
+	}
+
+	value, _ := values.Value()
+	return value, true, values.Err()
+}
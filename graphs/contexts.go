@@ -0,0 +1,41 @@
+package graphs
+
+import (
+	"context"
+	"errors"
+)
+
+// ContextIterator decorates an iterator to stop it once a context is done.
+// Next returns false and the context error when context is cancelled or past its deadline.
+type ContextIterator[T any] struct {
+	// ctx is the context to check before each move
+	ctx context.Context
+	// iterator is the decorated iterator
+	iterator GeneralIterator[T]
+}
+
+// WithContext returns an iterator that stops when ctx is done
+func WithContext[T any](ctx context.Context, iterator GeneralIterator[T]) *ContextIterator[T] {
+	return &ContextIterator[T]{ctx: ctx, iterator: iterator}
+}
+
+// Next returns the context error if context is done, moves decorated iterator otherwise
+func (ci *ContextIterator[T]) Next() (bool, error) {
+	if err := ci.ctx.Err(); err != nil {
+		return false, err
+	} else if ci.iterator == nil {
+		return false, nil
+	}
+
+	return ci.iterator.Next()
+}
+
+// Value returns the current value of decorated iterator
+func (ci *ContextIterator[T]) Value() (T, error) {
+	if ci.iterator == nil {
+		var empty T
+		return empty, errors.New("no value to return")
+	}
+
+	return ci.iterator.Value()
+}
@@ -18,6 +18,8 @@ type GeneralIterator[T any] interface {
 
 // MapFilterIterator composes an iterator with a mapper and a filter.
 // Mapper is not optional, but filter is (nil means no filter).
+// Each value is mapped once, in Next, so mapper errors are reported by Next, with or without filter.
+// Errors of skipped values are joined and returned with the next value.
 type MapFilterIterator[T any, S any] struct {
 	// Iterator is the base iterator to map values for
 	Iterator GeneralIterator[T]
@@ -25,6 +27,10 @@ type MapFilterIterator[T any, S any] struct {
 	Mapper func(T) (S, error)
 	// Filter to exclude some S values
 	Filter func(S) bool
+	// current is the mapped current value
+	current S
+	// hasCurrent is true when current is set
+	hasCurrent bool
 }
 
 // Next finds the next matching, if any
@@ -33,43 +39,46 @@ func (mi *MapFilterIterator[T, S]) Next() (bool, error) {
 		return false, errors.New("nil iterator")
 	} else if mi.Iterator == nil {
 		return false, nil
+	} else if mi.Mapper == nil {
+		return false, errors.New("nil mapper")
 	}
 
 	var globalErr error
+	mi.hasCurrent = false
 
 	for {
 		if has, err := mi.Iterator.Next(); err != nil {
 			globalErr = errors.Join(globalErr, err)
-			continue
+			if !has {
+				return false, globalErr
+			}
 		} else if !has {
 			return false, globalErr
-		} else if v, errV := mi.Iterator.Value(); errV != nil {
+		}
+
+		if v, errV := mi.Iterator.Value(); errV != nil {
 			globalErr = errors.Join(globalErr, errV)
-			continue
-		} else if mi.Filter == nil {
-			return true, globalErr
 		} else if res, errRes := mi.Mapper(v); errRes != nil {
 			globalErr = errors.Join(globalErr, errRes)
-			continue
-		} else if mi.Filter(res) {
+		} else if mi.Filter == nil || mi.Filter(res) {
+			mi.current = res
+			mi.hasCurrent = true
 			return true, globalErr
 		}
 	}
 }
 
-// Value reads value from mi.Iterator and returns mapped value
+// Value returns the mapped current value
 func (mi *MapFilterIterator[T, S]) Value() (S, error) {
 	var empty S
 
 	if mi == nil || mi.Iterator == nil || mi.Mapper == nil {
 		return empty, errors.New("nil iterator")
+	} else if !mi.hasCurrent {
+		return empty, errors.New("no value to return")
 	}
 
-	if value, err := mi.Iterator.Value(); err != nil {
-		return empty, err
-	} else {
-		return mi.Mapper(value)
-	}
+	return mi.current, nil
 }
 
 // CompositeIterator is a composition of iterators.
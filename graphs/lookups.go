@@ -0,0 +1,64 @@
+package graphs
+
+// HasNode returns true if node is in g, that is if it has a neighborhood
+func HasNode[N Node, L Link[N]](g StructuredGraph[N, L], node N) (bool, error) {
+	neighbors, err := g.Neighbors(node)
+	return neighbors != nil, err
+}
+
+// FindNode returns the instance of node in g, and true if node is in g.
+// Neighborhoods may not return the instance of their center, so nodes of g are scanned
+func FindNode[N Node, L Link[N]](g CentralStructureGraph[N, L], node N) (N, bool, error) {
+	var empty N
+	if found, err := HasNode(g, node); err != nil || !found {
+		return empty, false, err
+	}
+
+	nodes, errNodes := g.AllNodes()
+	if errNodes != nil || nodes == nil {
+		return empty, false, errNodes
+	}
+
+	for has, errNext := nodes.Next(); has || errNext != nil; has, errNext = nodes.Next() {
+		if errNext != nil {
+			return empty, false, errNext
+		} else if current, errValue := nodes.Value(); errValue != nil {
+			return empty, false, errValue
+		} else if node.SameNode(current) {
+			return current, true, nil
+		}
+	}
+
+	return empty, false, nil
+}
+
+// FindLink returns the instance of link in g, and true if link is in g
+func FindLink[N Node, L Link[N]](g StructuredGraph[N, L], link L) (L, bool, error) {
+	return FindLinkFunc(g, link.Source(), func(current L) bool { return link.SameLink(current) })
+}
+
+// FindLinkFunc returns the first link in the neighborhood of source that matches, and true if any
+func FindLinkFunc[N Node, L Link[N]](g StructuredGraph[N, L], source N, matches func(L) bool) (L, bool, error) {
+	var empty L
+	neighbors, errNeighbors := g.Neighbors(source)
+	if errNeighbors != nil || neighbors == nil {
+		return empty, false, errNeighbors
+	}
+
+	links, errLinks := neighbors.Links()
+	if errLinks != nil || links == nil {
+		return empty, false, errLinks
+	}
+
+	for has, errNext := links.Next(); has || errNext != nil; has, errNext = links.Next() {
+		if errNext != nil {
+			return empty, false, errNext
+		} else if current, errValue := links.Value(); errValue != nil {
+			return empty, false, errValue
+		} else if matches(current) {
+			return current, true, nil
+		}
+	}
+
+	return empty, false, nil
+}
@@ -0,0 +1,127 @@
+package graphs
+
+// EventType is the type of change in a graph
+type EventType int
+
+const (
+	// NodeAdded is raised when a node is added, explicitly or as the extremity of a new link
+	NodeAdded EventType = iota
+	// NodeRemoved is raised when a node is removed, its removed links are part of the event
+	NodeRemoved
+	// LinkAdded is raised when a new link is added
+	LinkAdded
+	// LinkRemoved is raised when an existing link is removed
+	LinkRemoved
+	// PropertyChanged is raised when a property of a node or a link is set or removed
+	PropertyChanged
+)
+
+// String returns the name of the event type
+func (et EventType) String() string {
+	switch et {
+	case NodeAdded:
+		return "NodeAdded"
+	case NodeRemoved:
+		return "NodeRemoved"
+	case LinkAdded:
+		return "LinkAdded"
+	case LinkRemoved:
+		return "LinkRemoved"
+	case PropertyChanged:
+		return "PropertyChanged"
+	default:
+		return "Unknown"
+	}
+}
+
+// GraphEvent is a change in a graph.
+// Events are raised once the change is done, and only if the graph actually changed.
+type GraphEvent[N Node, L Link[N]] struct {
+	// Sequence is the number of the event in the graph, starting at 1
+	Sequence uint64
+	// Type is the type of change
+	Type EventType
+	// Node is the node for node events, and for property changes of a node
+	Node N
+	// Link is the link for link events, and for property changes of a link
+	Link L
+	// OnLink is true for property changes of a link, false for property changes of a node
+	OnLink bool
+	// CascadedLinks are the links removed with the node, for NodeRemoved
+	CascadedLinks []L
+	// Key is the changed property, for PropertyChanged
+	Key string
+	// OldValue is the previous value of the property, if any
+	OldValue string
+	// NewValue is the new value of the property, empty if property was removed
+	NewValue string
+	// Removed is true if property was removed
+	Removed bool
+}
+
+// Matches returns true if the event is about a node that accepts predicate.
+// Link events match if any extremity matches, NodeRemoved matches if the node or any extremity of its links matches.
+// A nil predicate matches any event.
+func (ge GraphEvent[N, L]) Matches(predicate func(N) bool) bool {
+	if predicate == nil {
+		return true
+	}
+
+	linkMatches := func(link L) bool {
+		return predicate(link.Source()) || predicate(link.Destination())
+	}
+
+	switch {
+	case ge.Type == LinkAdded || ge.Type == LinkRemoved:
+		return linkMatches(ge.Link)
+	case ge.Type == PropertyChanged && ge.OnLink:
+		return linkMatches(ge.Link)
+	case predicate(ge.Node):
+		return true
+	}
+
+	for _, link := range ge.CascadedLinks {
+		if linkMatches(link) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// EventHandler processes an event of a graph
+type EventHandler[N Node, L Link[N]] func(GraphEvent[N, L])
+
+// ObserverOptions defines which events an observer receives, and how
+type ObserverOptions[N Node] struct {
+	// Filter accepts events about some nodes only (see GraphEvent.Matches). Nil means all events.
+	Filter func(N) bool
+	// BufferSize is 0 for a synchronous delivery: handler is called before the change returns.
+	// Otherwise, events are buffered and delivered in order by a dedicated goroutine.
+	// Changes block when buffer is full.
+	BufferSize int
+}
+
+// Observable is a graph that publishes its changes to observers
+type Observable[N Node, L Link[N]] interface {
+	// Subscribe registers a handler and returns its id, to unsubscribe
+	Subscribe(handler EventHandler[N, L], options ObserverOptions[N]) (int, error)
+	// Unsubscribe removes an observer. Buffered events are delivered before it returns
+	Unsubscribe(id int) error
+	// SetNodeProperty sets the property of a node in the graph (node should implement WithProperties)
+	SetNodeProperty(node N, key, value string) error
+	// RemoveNodeProperty removes the property of a node in the graph (node should implement WithProperties)
+	RemoveNodeProperty(node N, key string) error
+	// SetLinkProperty sets the property of a link in the graph (link should implement WithProperties)
+	SetLinkProperty(link L, key, value string) error
+	// RemoveLinkProperty removes the property of a link in the graph (link should implement WithProperties)
+	RemoveLinkProperty(link L, key string) error
+	// Close unsubscribes all observers, once buffered events are delivered
+	Close() error
+}
+
+// ObservableGraph is a central structure graph that publishes its changes
+type ObservableGraph[N Node, L Link[N]] interface {
+	CentralStructureGraph[N, L]
+	Observable[N, L]
+}
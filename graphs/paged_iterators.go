@@ -0,0 +1,174 @@
+package graphs
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// PageFetcher returns the page of values at cursor, and the cursor of the next page.
+// Zero value of the cursor is the first page when passed, and means "no more page" when returned.
+// pageSize is a hint, a page may be shorter.
+type PageFetcher[T any, C comparable] func(ctx context.Context, cursor C, pageSize int) ([]T, C, error)
+
+// PagingOptions configures a paged iterator
+type PagingOptions struct {
+	// PageSize is the expected size of pages, passed to the fetcher. 100 if not positive
+	PageSize int
+	// Prefetch fetches the next page in background while current page is read
+	Prefetch bool
+	// Retries is the number of retries of a failing fetch, for transient errors
+	Retries int
+	// Backoff is the wait before first retry, doubled for each retry. No wait if zero
+	Backoff time.Duration
+	// IsTransient returns true for errors worth a retry. If nil, all errors are retried
+	IsTransient func(error) bool
+}
+
+// pageResult is the result of a fetch
+type pageResult[T any, C comparable] struct {
+	// values of the page
+	values []T
+	// next is the cursor of the next page
+	next C
+	// err is the error of the fetch, after retries
+	err error
+}
+
+// PagedIterator is an iterator over values loaded page by page, from a cursor based source.
+// It is the building block for remote graphs: only one page (two with prefetch) is in memory.
+// Once done with it, call Close to stop any background fetch.
+type PagedIterator[T any, C comparable] struct {
+	// ctx cancels fetches and waits
+	ctx context.Context
+	// cancel stops background fetches
+	cancel context.CancelFunc
+	// fetch loads a page
+	fetch PageFetcher[T, C]
+	// options of the pagination
+	options PagingOptions
+	// page is the current page
+	page []T
+	// index is the index of the current value in page
+	index int
+	// cursor is the cursor of the next page
+	cursor C
+	// started is true once first page was requested
+	started bool
+	// last is true if current page is the last one
+	last bool
+	// pending receives the next page when prefetched
+	pending chan pageResult[T, C]
+	// done is true once iteration is over
+	done bool
+}
+
+// NewPagedIterator returns an iterator over the pages of fetch. No page is fetched before the first Next
+func NewPagedIterator[T any, C comparable](ctx context.Context, fetch PageFetcher[T, C], options PagingOptions) *PagedIterator[T, C] {
+	if options.PageSize <= 0 {
+		options.PageSize = 100
+	}
+
+	child, cancel := context.WithCancel(ctx)
+	return &PagedIterator[T, C]{ctx: child, cancel: cancel, fetch: fetch, options: options, index: -1}
+}
+
+// Next moves to the next value, and fetches the next page if needed
+func (pi *PagedIterator[T, C]) Next() (bool, error) {
+	if pi == nil || pi.fetch == nil {
+		return false, errors.New("nil iterator")
+	} else if pi.done {
+		return false, nil
+	}
+
+	pi.index++
+	for pi.index >= len(pi.page) {
+		if pi.started && pi.last {
+			pi.Close()
+			return false, nil
+		}
+
+		result := pi.nextPage()
+		if result.err != nil {
+			pi.Close()
+			return false, result.err
+		}
+
+		var end C
+		pi.page, pi.index, pi.cursor = result.values, 0, result.next
+		pi.last = result.next == end
+		if pi.options.Prefetch && !pi.last {
+			pi.prefetch()
+		}
+	}
+
+	return true, nil
+}
+
+// Value returns the current value
+func (pi *PagedIterator[T, C]) Value() (T, error) {
+	var empty T
+	if pi == nil || pi.index < 0 || pi.index >= len(pi.page) || pi.done {
+		return empty, errors.New("no value to return")
+	}
+
+	return pi.page[pi.index], nil
+}
+
+// Close stops iteration and background fetches
+func (pi *PagedIterator[T, C]) Close() {
+	if pi == nil || pi.done {
+		return
+	}
+
+	pi.done = true
+	pi.cancel()
+}
+
+// nextPage returns the prefetched page if any, or fetches it
+func (pi *PagedIterator[T, C]) nextPage() pageResult[T, C] {
+	pi.started = true
+	if pending := pi.pending; pending != nil {
+		pi.pending = nil
+		return <-pending
+	}
+
+	return pi.fetchWithRetries(pi.cursor)
+}
+
+// prefetch fetches the page at current cursor in background
+func (pi *PagedIterator[T, C]) prefetch() {
+	pending := make(chan pageResult[T, C], 1)
+	pi.pending = pending
+	cursor := pi.cursor
+	go func() {
+		pending <- pi.fetchWithRetries(cursor)
+	}()
+}
+
+// fetchWithRetries fetches a page, and retries transient errors
+func (pi *PagedIterator[T, C]) fetchWithRetries(cursor C) pageResult[T, C] {
+	var globalErr error
+	wait := pi.options.Backoff
+	for attempt := 0; ; attempt++ {
+		values, next, err := pi.fetch(pi.ctx, cursor, pi.options.PageSize)
+		if err == nil {
+			return pageResult[T, C]{values: values, next: next}
+		}
+
+		globalErr = errors.Join(globalErr, err)
+		if attempt >= pi.options.Retries || (pi.options.IsTransient != nil && !pi.options.IsTransient(err)) {
+			return pageResult[T, C]{err: globalErr}
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-pi.ctx.Done():
+			timer.Stop()
+			return pageResult[T, C]{err: errors.Join(pi.ctx.Err(), globalErr)}
+		case <-timer.C:
+		}
+
+		wait *= 2
+	}
+}
@@ -0,0 +1,540 @@
+package graphs
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"slices"
+	"sync"
+)
+
+// ParallelOptions configures parallel algorithms.
+// Parallel algorithms only read the graph, through Neighbors, from many goroutines at once.
+// So, graph should support concurrent reads, and should not change during the computation.
+type ParallelOptions[N Node] struct {
+	// Workers is the number of goroutines, number of CPUs if not positive
+	Workers int
+	// Key returns a unique key per node, to index and sort nodes.
+	// If nil, nodes should implement WithId, and key is the id.
+	Key func(N) string
+}
+
+// workers returns the actual number of workers
+func (po ParallelOptions[N]) workers() int {
+	if po.Workers <= 0 {
+		return runtime.NumCPU()
+	}
+
+	return po.Workers
+}
+
+// key returns the key of a node, or an error if node has no key
+func (po ParallelOptions[N]) key(node N) (string, error) {
+	if po.Key != nil {
+		return po.Key(node), nil
+	} else if withId, ok := any(node).(WithId); ok {
+		return withId.Id(), nil
+	}
+
+	return "", errors.New("node has no id, and no key function")
+}
+
+// parallelFor calls fn for each index in [0, size), with a static partition of indexes per worker.
+// Worker w gets a contiguous range of indexes, in increasing order, so results are deterministic for a number of workers.
+// Errors are joined in indexes order
+func parallelFor(size, workers int, fn func(worker, index int) error) error {
+	workers = max(min(workers, size), 1)
+	errs := make([]error, workers)
+	var group sync.WaitGroup
+	for worker := 0; worker < workers; worker++ {
+		group.Add(1)
+		go func() {
+			defer group.Done()
+			for index := worker * size / workers; index < (worker+1)*size/workers; index++ {
+				if err := fn(worker, index); err != nil {
+					errs[worker] = errors.Join(errs[worker], err)
+				}
+			}
+		}()
+	}
+
+	group.Wait()
+	return errors.Join(errs...)
+}
+
+// sortedDestinations returns the distinct nodes reachable in one link from node, sorted by key, node excluded.
+// Second result is the keys of those nodes.
+func sortedDestinations[N Node, L Link[N]](g StructuredGraph[N, L], node N, options ParallelOptions[N]) ([]N, []string, error) {
+	neighbors, errNeighbors := g.Neighbors(node)
+	if errNeighbors != nil || neighbors == nil {
+		return nil, nil, errNeighbors
+	}
+
+	links, errLinks := neighbors.Links()
+	if errLinks != nil || links == nil {
+		return nil, nil, errLinks
+	}
+
+	nodeKey, errKey := options.key(node)
+	if errKey != nil {
+		return nil, nil, errKey
+	}
+
+	destinations := make(map[string]N)
+	for has, errNext := links.Next(); has; has, errNext = links.Next() {
+		if errNext != nil {
+			return nil, nil, errNext
+		}
+
+		link, errValue := links.Value()
+		if errValue != nil {
+			return nil, nil, errValue
+		} else if ok, destination := FollowLink(node, link); !ok {
+			continue
+		} else if key, err := options.key(destination); err != nil {
+			return nil, nil, err
+		} else if key != nodeKey {
+			destinations[key] = destination
+		}
+	}
+
+	keys := make([]string, 0, len(destinations))
+	for key := range destinations {
+		keys = append(keys, key)
+	}
+
+	slices.Sort(keys)
+	nodes := make([]N, len(keys))
+	for index, key := range keys {
+		nodes[index] = destinations[key]
+	}
+
+	return nodes, keys, nil
+}
+
+// sortedNodes returns the nodes of the graph sorted by key, and the index of each key
+func sortedNodes[N Node, L Link[N]](g CentralStructureGraph[N, L], options ParallelOptions[N]) ([]N, map[string]int, error) {
+	it, errIt := g.AllNodes()
+	if errIt != nil {
+		return nil, nil, errIt
+	}
+
+	type keyedNode struct {
+		key  string
+		node N
+	}
+
+	values := make([]keyedNode, 0)
+	for has, errNext := it.Next(); has; has, errNext = it.Next() {
+		if errNext != nil {
+			return nil, nil, errNext
+		}
+
+		node, errValue := it.Value()
+		if errValue != nil {
+			return nil, nil, errValue
+		}
+
+		key, errKey := options.key(node)
+		if errKey != nil {
+			return nil, nil, errKey
+		}
+
+		values = append(values, keyedNode{key: key, node: node})
+	}
+
+	slices.SortFunc(values, func(a, b keyedNode) int {
+		switch {
+		case a.key < b.key:
+			return -1
+		case a.key > b.key:
+			return 1
+		default:
+			return 0
+		}
+	})
+
+	nodes := make([]N, len(values))
+	positions := make(map[string]int, len(values))
+	for index, value := range values {
+		if _, found := positions[value.key]; found {
+			return nil, nil, fmt.Errorf("duplicate key %q", value.key)
+		}
+
+		nodes[index] = value.node
+		positions[value.key] = index
+	}
+
+	return nodes, positions, nil
+}
+
+// ParallelNetworkStatistics is CalculateNetworkStatistics with workers reading neighborhoods.
+// Counter is called from many goroutines at once.
+// Result is the same as the sequential version.
+func ParallelNetworkStatistics[N Node, L Link[N]](
+	g CentralStructureGraph[N, L], // graph to get statistics for
+	counter func(Neighborhood[N, L]) int64, // value of a neighborhood for degree distribution
+	options ParallelOptions[N], // number of workers
+) (NetworkStatistics, error) {
+	var result NetworkStatistics
+	result.DegreeDistribution = make(map[int64]float64)
+	if g == nil || counter == nil {
+		return result, errors.New("nil graph or counter")
+	}
+
+	it, errIt := g.AllNodes()
+	if errIt != nil {
+		return result, errIt
+	}
+
+	nodes := make([]N, 0)
+	for has, errNext := it.Next(); has; has, errNext = it.Next() {
+		if errNext != nil {
+			return result, errNext
+		} else if node, errValue := it.Value(); errValue != nil {
+			return result, errValue
+		} else {
+			nodes = append(nodes, node)
+		}
+	}
+
+	// each worker counts on its own, partial results are merged at the end
+	type partial struct {
+		degrees                  map[int64]int64
+		directedSize, undirected int64
+	}
+
+	workers := max(min(options.workers(), len(nodes)), 1)
+	partials := make([]partial, workers)
+	for index := range partials {
+		partials[index].degrees = make(map[int64]int64)
+	}
+
+	globalErr := parallelFor(len(nodes), workers, func(worker, index int) error {
+		neighborhood, err := g.Neighbors(nodes[index])
+		if err != nil {
+			return err
+		} else if neighborhood == nil {
+			return errors.New("node without neighborhood")
+		}
+
+		current := &partials[worker]
+		current.degrees[counter(neighborhood)]++
+		current.directedSize += neighborhood.OutgoingDegree()
+		current.undirected += neighborhood.UndirectedDegree()
+		return nil
+	})
+
+	degreesCounter := make(map[int64]int64)
+	for _, current := range partials {
+		result.DirectedSize += current.directedSize
+		result.UndirectedSize += current.undirected
+		for degree, count := range current.degrees {
+			degreesCounter[degree] += count
+		}
+	}
+
+	result.NodesSize = int64(len(nodes))
+	// undirected links were counted twice
+	result.UndirectedSize /= 2
+	for k, v := range degreesCounter {
+		result.DegreeDistribution[k] = float64(v) / float64(result.NodesSize)
+	}
+
+	return result, globalErr
+}
+
+// LevelSynchronousBFS walks the graph from start, level by level.
+// For each level, workers read the neighbors of the nodes of the level at once,
+// and then next level is built in the order of the current level, with neighbors sorted by key.
+// So, result is deterministic: levels[0] is start, levels[i] contains nodes at distance i.
+// Links are followed as FollowLink does (undirected links both ways, directed links from source to destination).
+func LevelSynchronousBFS[N Node, L Link[N]](
+	g StructuredGraph[N, L], // graph to walk through
+	start N, // first node
+	options ParallelOptions[N], // workers and nodes keys
+) ([][]N, error) {
+	return levelSynchronousBFS(g, start, options, make(map[string]bool))
+}
+
+// levelSynchronousBFS is the BFS per se, with the keys of already visited nodes
+func levelSynchronousBFS[N Node, L Link[N]](
+	g StructuredGraph[N, L], // graph to walk through
+	start N, // first node
+	options ParallelOptions[N], // workers and nodes keys
+	visited map[string]bool, // keys of visited nodes, changed by the walk
+) ([][]N, error) {
+	if g == nil {
+		return nil, errors.New("nil graph")
+	}
+
+	startKey, errKey := options.key(start)
+	if errKey != nil {
+		return nil, errKey
+	} else if neighbors, err := g.Neighbors(start); err != nil {
+		return nil, err
+	} else if neighbors == nil {
+		return nil, nil
+	}
+
+	visited[startKey] = true
+	levels := [][]N{{start}}
+	for frontier := levels[0]; len(frontier) != 0; {
+		destinations := make([][]N, len(frontier))
+		keys := make([][]string, len(frontier))
+		err := parallelFor(len(frontier), options.workers(), func(_, index int) error {
+			var errDestinations error
+			destinations[index], keys[index], errDestinations = sortedDestinations(g, frontier[index], options)
+			return errDestinations
+		})
+
+		if err != nil {
+			return levels, err
+		}
+
+		next := make([]N, 0)
+		for index := range frontier {
+			for position, key := range keys[index] {
+				if !visited[key] {
+					visited[key] = true
+					next = append(next, destinations[index][position])
+				}
+			}
+		}
+
+		if len(next) != 0 {
+			levels = append(levels, next)
+		}
+
+		frontier = next
+	}
+
+	return levels, nil
+}
+
+// ParallelConnectedComponentsSize returns the size of each connected component, as ConnectedComponentsSize does.
+// Components are found with LevelSynchronousBFS, from nodes sorted by key,
+// so component i is the component of the i-th smallest key not in a previous component.
+func ParallelConnectedComponentsSize[N Node, L Link[N]](
+	g CentralStructureGraph[N, L], // graph to find connected components within
+	options ParallelOptions[N], // workers and nodes keys
+) (map[int64]int64, error) {
+	stats := make(map[int64]int64)
+	if g == nil {
+		return stats, errors.New("nil graph")
+	}
+
+	nodes, _, errNodes := sortedNodes(g, options)
+	if errNodes != nil {
+		return stats, errNodes
+	}
+
+	visited := make(map[string]bool, len(nodes))
+	var index int64
+	for _, node := range nodes {
+		if key, _ := options.key(node); visited[key] {
+			continue
+		}
+
+		levels, err := levelSynchronousBFS(g, node, options, visited)
+		if err != nil {
+			return stats, err
+		}
+
+		var size int64
+		for _, level := range levels {
+			size += int64(len(level))
+		}
+
+		stats[index] = size
+		index++
+	}
+
+	return stats, nil
+}
+
+// NodeMetrics are the metrics of a node in a graph
+type NodeMetrics[N Node] struct {
+	// Node is the node the metrics are about
+	Node N
+	// Key is the key of the node (see ParallelOptions)
+	Key string
+	// IncomingDegree is the number of incoming directed links
+	IncomingDegree int64
+	// OutgoingDegree is the number of outgoing directed links
+	OutgoingDegree int64
+	// UndirectedDegree is the number of undirected links
+	UndirectedDegree int64
+	// DegreeCentrality is the number of distinct neighbors (by FollowLink) divided by the other nodes size
+	DegreeCentrality float64
+	// Clustering is the local clustering coefficient:
+	// links between neighbors divided by the max possible number of those links, 0 for less than 2 neighbors
+	Clustering float64
+	// Closeness is the number of reachable nodes divided by the sum of their distances, 0 if none.
+	// Set only if MetricsOptions.PathCentralities
+	Closeness float64
+	// Betweenness is the number of shortest paths going through the node, split for equal length paths.
+	// Paths are counted per ordered pair (undirected paths count twice). Set only if MetricsOptions.PathCentralities
+	Betweenness float64
+}
+
+// MetricsOptions configures CalculateNodeMetrics
+type MetricsOptions[N Node] struct {
+	// ParallelOptions are workers and keys
+	ParallelOptions[N]
+	// PathCentralities computes closeness and betweenness, with a BFS per node.
+	// Complexity is nodes size times links size, so it is not for large graphs
+	PathCentralities bool
+}
+
+// CalculateNodeMetrics returns the metrics of all the nodes, sorted by key.
+// Neighborhoods are read once, by workers. Then, metrics are computed per node, by workers.
+// For a given number of workers, results are always the same, no matter the scheduling.
+func CalculateNodeMetrics[N Node, L Link[N]](
+	g CentralStructureGraph[N, L], // graph to get metrics for
+	options MetricsOptions[N], // workers, keys, and metrics to compute
+) ([]NodeMetrics[N], error) {
+	if g == nil {
+		return nil, errors.New("nil graph")
+	}
+
+	nodes, positions, errNodes := sortedNodes(g, options.ParallelOptions)
+	if errNodes != nil {
+		return nil, errNodes
+	}
+
+	// adjacency[i] are the sorted indexes of the destinations of node i
+	adjacency := make([][]int, len(nodes))
+	result := make([]NodeMetrics[N], len(nodes))
+	workers := max(min(options.workers(), len(nodes)), 1)
+	errAdjacency := parallelFor(len(nodes), workers, func(_, index int) error {
+		node := nodes[index]
+		neighborhood, errNeighbors := g.Neighbors(node)
+		if errNeighbors != nil {
+			return errNeighbors
+		} else if neighborhood == nil {
+			return errors.New("node without neighborhood")
+		}
+
+		_, keys, errDestinations := sortedDestinations(g, node, options.ParallelOptions)
+		if errDestinations != nil {
+			return errDestinations
+		}
+
+		adjacency[index] = make([]int, 0, len(keys))
+		for _, key := range keys {
+			if position, found := positions[key]; found {
+				adjacency[index] = append(adjacency[index], position)
+			}
+		}
+
+		key, _ := options.key(node)
+		result[index] = NodeMetrics[N]{
+			Node:             node,
+			Key:              key,
+			IncomingDegree:   neighborhood.IncomingDegree(),
+			OutgoingDegree:   neighborhood.OutgoingDegree(),
+			UndirectedDegree: neighborhood.UndirectedDegree(),
+		}
+
+		return nil
+	})
+
+	if errAdjacency != nil {
+		return result, errAdjacency
+	}
+
+	others := float64(len(nodes) - 1)
+	parallelFor(len(nodes), workers, func(_, index int) error {
+		neighbors := adjacency[index]
+		size := len(neighbors)
+		if others > 0 {
+			result[index].DegreeCentrality = float64(size) / others
+		}
+
+		if size < 2 {
+			return nil
+		}
+
+		var links int
+		for _, neighbor := range neighbors {
+			for _, other := range adjacency[neighbor] {
+				if _, found := slices.BinarySearch(neighbors, other); found {
+					links++
+				}
+			}
+		}
+
+		result[index].Clustering = float64(links) / float64(size*(size-1))
+		return nil
+	})
+
+	if options.PathCentralities {
+		pathCentralities(adjacency, result, workers)
+	}
+
+	return result, nil
+}
+
+// pathCentralities sets closeness and betweenness with Brandes algorithm, one BFS per source.
+// Each worker accumulates betweenness for its sources, and then accumulators are summed in workers order.
+func pathCentralities[N Node](adjacency [][]int, result []NodeMetrics[N], workers int) {
+	size := len(adjacency)
+	betweenness := make([][]float64, workers)
+	for worker := range betweenness {
+		betweenness[worker] = make([]float64, size)
+	}
+
+	parallelFor(size, workers, func(worker, source int) error {
+		distances := make([]int, size)
+		for index := range distances {
+			distances[index] = -1
+		}
+
+		paths := make([]float64, size)
+		predecessors := make([][]int, size)
+		order := make([]int, 0, size)
+		distances[source] = 0
+		paths[source] = 1
+
+		var sum int
+		for queue := []int{source}; len(queue) != 0; queue = queue[1:] {
+			current := queue[0]
+			order = append(order, current)
+			sum += distances[current]
+			for _, next := range adjacency[current] {
+				if distances[next] < 0 {
+					distances[next] = distances[current] + 1
+					queue = append(queue, next)
+				}
+
+				if distances[next] == distances[current]+1 {
+					paths[next] += paths[current]
+					predecessors[next] = append(predecessors[next], current)
+				}
+			}
+		}
+
+		if sum > 0 {
+			result[source].Closeness = float64(len(order)-1) / float64(sum)
+		}
+
+		dependencies := make([]float64, size)
+		for index := len(order) - 1; index > 0; index-- {
+			current := order[index]
+			for _, previous := range predecessors[current] {
+				dependencies[previous] += paths[previous] / paths[current] * (1 + dependencies[current])
+			}
+
+			betweenness[worker][current] += dependencies[current]
+		}
+
+		return nil
+	})
+
+	for index := range result {
+		for worker := range betweenness {
+			result[index].Betweenness += betweenness[worker][index]
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package graphs
+
+import (
+	"errors"
+	"slices"
+)
+
+// ShortestPath returns the nodes of a shortest path from source to destination, both included.
+// Links are followed in their direction (see FollowLink), length of a path is its number of links (weights are ignored).
+// Result is nil if destination is not reachable, or if source is not in the graph.
+// Key returns a unique key per node, if nil, nodes should implement WithId, and key is the id.
+// Among paths of the same length, result is the first one in keys order, so it is deterministic.
+func ShortestPath[N Node, L Link[N]](g StructuredGraph[N, L], source, destination N, key func(N) string) ([]N, error) {
+	if g == nil {
+		return nil, errors.New("nil graph")
+	}
+
+	options := ParallelOptions[N]{Key: key}
+	sourceKey, errSource := options.key(source)
+	if errSource != nil {
+		return nil, errSource
+	}
+
+	destinationKey, errDestination := options.key(destination)
+	if errDestination != nil {
+		return nil, errDestination
+	}
+
+	if neighbors, err := g.Neighbors(source); err != nil || neighbors == nil {
+		return nil, err
+	} else if sourceKey == destinationKey {
+		return []N{source}, nil
+	}
+
+	// breadth first search, keeping the predecessor of each visited node
+	predecessors := map[string]string{sourceKey: ""}
+	nodes := map[string]N{sourceKey: source}
+	fifo := []N{source}
+	for len(fifo) > 0 {
+		current := fifo[0]
+		fifo = fifo[1:]
+		currentKey, _ := options.key(current)
+
+		destinations, keys, errDestinations := sortedDestinations(g, current, options)
+		if errDestinations != nil {
+			return nil, errDestinations
+		}
+
+		for index, next := range destinations {
+			nextKey := keys[index]
+			if _, visited := predecessors[nextKey]; visited {
+				continue
+			}
+
+			predecessors[nextKey] = currentKey
+			nodes[nextKey] = next
+			if nextKey != destinationKey {
+				fifo = append(fifo, next)
+				continue
+			}
+
+			// destination found, walk back to source
+			path := []N{next}
+			for previous := currentKey; ; previous = predecessors[previous] {
+				path = append(path, nodes[previous])
+				if previous == sourceKey {
+					break
+				}
+			}
+
+			slices.Reverse(path)
+			return path, nil
+		}
+	}
+
+	return nil, nil
+}
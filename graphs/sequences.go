@@ -0,0 +1,141 @@
+package graphs
+
+import (
+	"errors"
+	"iter"
+)
+
+// All adapts an iterator to a range over func sequence of values and errors.
+// Each value comes with a nil error.
+// An error of Value comes with the zero value, and iteration goes on.
+// An error of Next comes with the zero value, and iteration stops if there is no next element.
+// Consumer decides what to do with errors: break, collect, ignore.
+//
+//	for node, err := range graphs.All(it) {
+//		if err != nil {
+//			return err
+//		}
+//		...
+//	}
+func All[T any](it GeneralIterator[T]) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		if it == nil {
+			return
+		}
+
+		var empty T
+		for {
+			has, errNext := it.Next()
+			if errNext != nil && !yield(empty, errNext) {
+				return
+			} else if !has {
+				return
+			}
+
+			if value, errValue := it.Value(); errValue != nil {
+				if !yield(empty, errValue) {
+					return
+				}
+			} else if !yield(value, nil) {
+				return
+			}
+		}
+	}
+}
+
+// Values adapts an iterator to a range over func sequence of values.
+// Sequence stops at the first error, use All to deal with errors.
+func Values[T any](it GeneralIterator[T]) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for value, err := range All(it) {
+			if err != nil || !yield(value) {
+				return
+			}
+		}
+	}
+}
+
+// SeqIterator is an iterator over a range over func sequence.
+// Sequence is pulled one value at a time.
+// If iteration stops before the end of the sequence, call Stop to release the sequence.
+type SeqIterator[T any] struct {
+	// next pulls the next value and error
+	next func() (T, error, bool)
+	// stop releases the sequence
+	stop func()
+	// current is the last pulled value
+	current T
+	// currentErr is the last pulled error
+	currentErr error
+	// started is true once Next was called
+	started bool
+	// done is true once the sequence is exhausted
+	done bool
+}
+
+// FromSeq returns an iterator over the values of a sequence
+func FromSeq[T any](seq iter.Seq[T]) *SeqIterator[T] {
+	return FromSeq2(func(yield func(T, error) bool) {
+		if seq == nil {
+			return
+		}
+
+		for value := range seq {
+			if !yield(value, nil) {
+				return
+			}
+		}
+	})
+}
+
+// FromSeq2 returns an iterator over a sequence of values and errors.
+// For a non nil error, Value returns that error
+func FromSeq2[T any](seq iter.Seq2[T, error]) *SeqIterator[T] {
+	if seq == nil {
+		seq = func(func(T, error) bool) {}
+	}
+
+	next, stop := iter.Pull2(seq)
+	return &SeqIterator[T]{next: next, stop: stop}
+}
+
+// Next pulls the next value of the sequence, if any
+func (si *SeqIterator[T]) Next() (bool, error) {
+	if si == nil {
+		return false, errors.New("nil iterator")
+	} else if si.done {
+		return false, nil
+	}
+
+	si.started = true
+	value, err, ok := si.next()
+	if !ok {
+		si.Stop()
+		return false, nil
+	}
+
+	si.current, si.currentErr = value, err
+	return true, nil
+}
+
+// Value returns the current value, or the error that came with it
+func (si *SeqIterator[T]) Value() (T, error) {
+	var empty T
+	if si == nil || !si.started || si.done {
+		return empty, errors.New("no value to return")
+	} else if si.currentErr != nil {
+		return empty, si.currentErr
+	}
+
+	return si.current, nil
+}
+
+// Stop releases the sequence. Next returns false after Stop
+func (si *SeqIterator[T]) Stop() {
+	if si == nil || si.done {
+		return
+	}
+
+	si.done = true
+	si.stop()
+}
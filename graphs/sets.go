@@ -1,5 +1,7 @@
 package graphs
 
+import "errors"
+
 // AbstractSet defines what a generic set (distributed maybe) should do
 type AbstractSet[T any] interface {
 	// ToIterator returns a new iterator over the elements of the set
@@ -24,3 +26,124 @@ type SetEqualsFunction[T any] func(a, b T) bool
 
 // AbstractSetBuilder builds a new empty set, with the equality
 type AbstractSetBuilder[T any] func(SetEqualsFunction[T]) (AbstractSet[T], error)
+
+// Set algebra works between any implementations of AbstractSet, through their iterators.
+// Result is a set to add elements to, usually a new empty one. It should be distinct from operands.
+
+// Union adds to result the elements of a and b
+func Union[T any](result, a, b AbstractSet[T]) error {
+	if result == nil {
+		return errors.New("nil result")
+	} else if err := eachOf(a, result.Add); err != nil {
+		return err
+	}
+
+	return eachOf(b, result.Add)
+}
+
+// Intersection adds to result the elements of a that are in b
+func Intersection[T any](result, a, b AbstractSet[T]) error {
+	if result == nil {
+		return errors.New("nil result")
+	} else if a == nil || b == nil {
+		return nil
+	} else if b.Size() < a.Size() {
+		// iterate over the smallest set, elements are then those of b
+		a, b = b, a
+	}
+
+	return addIf(result, a, b, true)
+}
+
+// Difference adds to result the elements of a that are not in b
+func Difference[T any](result, a, b AbstractSet[T]) error {
+	if result == nil {
+		return errors.New("nil result")
+	}
+
+	return addIf(result, a, b, false)
+}
+
+// SymmetricDifference adds to result the elements that are in a or b, but not in both
+func SymmetricDifference[T any](result, a, b AbstractSet[T]) error {
+	if err := Difference(result, a, b); err != nil {
+		return err
+	}
+
+	return Difference(result, b, a)
+}
+
+// IsSubset returns true if all the elements of a are in b
+func IsSubset[T any](a, b AbstractSet[T]) (bool, error) {
+	if a == nil {
+		return true, nil
+	} else if b == nil {
+		return a.IsEmpty()
+	} else if a.Size() > b.Size() {
+		return false, nil
+	}
+
+	errMissing := errors.New("missing element")
+	err := eachOf(a, func(element T) error {
+		if has, err := b.Has(element); err != nil {
+			return err
+		} else if !has {
+			return errMissing
+		}
+
+		return nil
+	})
+
+	if errors.Is(err, errMissing) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+// addIf adds to result the elements of a that are (if expected) or are not in b
+func addIf[T any](result, a, b AbstractSet[T], expected bool) error {
+	return eachOf(a, func(element T) error {
+		has := false
+		if b != nil {
+			if found, err := b.Has(element); err != nil {
+				return err
+			} else {
+				has = found
+			}
+		}
+
+		if has != expected {
+			return nil
+		}
+
+		return result.Add(element)
+	})
+}
+
+// eachOf calls fn for each element of a set (nil for none), and stops at the first error
+func eachOf[T any](set AbstractSet[T], fn func(T) error) error {
+	if set == nil {
+		return nil
+	}
+
+	elements, errElements := set.ToIterator()
+	if errElements != nil {
+		return errElements
+	}
+
+	for has, err := elements.Next(); has || err != nil; has, err = elements.Next() {
+		if err != nil {
+			return err
+		}
+
+		element, errElement := elements.Value()
+		if errElement != nil {
+			return errElement
+		} else if err := fn(element); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
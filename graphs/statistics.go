@@ -1,6 +1,9 @@
 package graphs
 
-import "errors"
+import (
+	"context"
+	"errors"
+)
 
 // NetworkStatistics is about the basic statistics of a network: distribution degree, nodes and links counters
 type NetworkStatistics struct {
@@ -66,13 +69,21 @@ func (n NetworkStatistics) UndirectedDensity() float64 {
 // To deal with those situations once, counter maps a neighborhood to an int, and degree distribution is based on its result.
 // Algorithm is to go through all the nodes, so, for large graphs, it may take time.
 func CalculateNetworkStatistics[N Node, L Link[N]](g CentralStructureGraph[N, L], counter func(Neighborhood[N, L]) int64) (NetworkStatistics, error) {
+	return CalculateNetworkStatisticsContext(context.Background(), g, counter)
+}
+
+// CalculateNetworkStatisticsContext is CalculateNetworkStatistics, but it stops as soon as ctx is done.
+// Then, result is the statistics of the nodes seen so far, and error is ctx.Err() joined with errors so far.
+func CalculateNetworkStatisticsContext[N Node, L Link[N]](ctx context.Context, g CentralStructureGraph[N, L], counter func(Neighborhood[N, L]) int64) (NetworkStatistics, error) {
 	var result NetworkStatistics
 	result.DegreeDistribution = make(map[int64]float64)
 
-	it, errIt := g.AllNodes()
+	allNodes, errIt := g.AllNodes()
 	if errIt != nil {
 		return result, errIt
 	}
+
+	it := WithContext(ctx, allNodes)
 	// for a counter value, the number of matching nodes
 	degreesCounter := make(map[int64]int64)
 
@@ -106,5 +117,9 @@ func CalculateNetworkStatistics[N Node, L Link[N]](g CentralStructureGraph[N, L]
 		result.DegreeDistribution[k] = float64(v) / float64(result.NodesSize)
 	}
 
+	if err := ctx.Err(); err != nil {
+		return result, errors.Join(err, globalErr)
+	}
+
 	return result, globalErr
 }
@@ -1,6 +1,7 @@
 package graphs
 
 import (
+	"context"
 	"errors"
 )
 
@@ -92,15 +93,30 @@ func ConnectedComponentsSize[N Node, L Link[N]](
 	dynamicBuilder DynamicIteratorBuilder[N], // to make a dynamic builder able to deal with the graph
 ) (map[int64]int64, // for each connected component, its size
 	error, // for any error
+) {
+	return ConnectedComponentsSizeContext(context.Background(), graph, setBuilder, dynamicBuilder)
+}
+
+// ConnectedComponentsSizeContext is ConnectedComponentsSize, but it stops as soon as ctx is done.
+// Then, result is the components found so far, and error is ctx.Err() joined with errors so far.
+func ConnectedComponentsSizeContext[N Node, L Link[N]](
+	ctx context.Context, // to cancel the search
+	graph CentralStructureGraph[N, L], // graph to find connected components within
+	setBuilder AbstractSetBuilder[N], // to make a set implementation able to deal with the graph
+	dynamicBuilder DynamicIteratorBuilder[N], // to make a dynamic builder able to deal with the graph
+) (map[int64]int64, // for each connected component, its size
+	error, // for any error
 ) {
 	stats := make(map[int64]int64)
 
 	// put all nodes into the set of marked ones
-	itNodes, errItNodes := graph.AllNodes()
+	allNodes, errItNodes := graph.AllNodes()
 	if errItNodes != nil {
 		return stats, errItNodes
 	}
 
+	itNodes := WithContext(ctx, allNodes)
+
 	markedNodes, errSet := setBuilder(func(a, b N) bool { return a.SameNode(b) })
 	if errSet != nil {
 		return stats, errSet
@@ -123,7 +139,9 @@ func ConnectedComponentsSize[N Node, L Link[N]](
 	}
 	// marked ones contains all the nodes of the graph
 
-	if globalErr != nil {
+	if err := ctx.Err(); err != nil {
+		return stats, errors.Join(err, globalErr)
+	} else if globalErr != nil {
 		return stats, globalErr
 	}
 
@@ -160,6 +178,10 @@ func ConnectedComponentsSize[N Node, L Link[N]](
 
 		// find its connected component using a breadth first search
 		for {
+			if err := ctx.Err(); err != nil {
+				return stats, errors.Join(err, globalErr)
+			}
+
 			// node to find connected component for
 			var currentNode N
 			// go until fifo is empty
@@ -193,18 +215,24 @@ func ConnectedComponentsSize[N Node, L Link[N]](
 				neighbors = n
 			}
 
-			for has, errHas := neighbors.Next(); has; has, errHas = neighbors.Next() {
+			has, errHas := neighbors.Next()
+			for ; has; has, errHas = neighbors.Next() {
 				if errHas != nil {
 					globalErr = errors.Join(globalErr, errHas)
 					continue
 				} else if v, errV := neighbors.Value(); errV != nil {
-					globalErr = errors.Join(globalErr, errHas)
+					globalErr = errors.Join(globalErr, errV)
 					continue
 				} else if errAdd := fifo.AddLastValue(v.CenterNode()); errAdd != nil {
 					globalErr = errors.Join(globalErr, errAdd)
 					continue
 				}
 			}
+
+			// combinators return their errors with the last Next
+			if errHas != nil {
+				globalErr = errors.Join(globalErr, errHas)
+			}
 		}
 
 		if globalErr != nil {
@@ -247,17 +275,12 @@ func DestinationNeighbors[N Node, L Link[N]](origin N, graph StructuredGraph[N,
 		return nil, errLinks
 	}
 
-	var result MapFilterIterator[L, Neighborhood[N, L]]
-	result.Iterator = links
-	result.Filter = func(n Neighborhood[N, L]) bool { return !IsIsolatedNeighborhood(n) }
-	result.Mapper = func(link L) (Neighborhood[N, L], error) {
+	destinations := Map(links, func(link L) (Neighborhood[N, L], error) {
 		_, destinationNode := FollowLink(origin, link)
-		if n, err := graph.Neighbors(destinationNode); err != nil {
-			return nil, err
-		} else {
-			return n, nil
-		}
-	}
+		return graph.Neighbors(destinationNode)
+	}, CollectErrors)
 
-	return &result, nil
+	return Filter[Neighborhood[N, L]](destinations, func(n Neighborhood[N, L]) bool {
+		return n != nil && !IsIsolatedNeighborhood(n)
+	}, CollectErrors), nil
 }
@@ -0,0 +1,158 @@
+package graphs
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// MutationType is the type of a change in a batch
+type MutationType int
+
+const (
+	// AddNodeMutation adds a node
+	AddNodeMutation MutationType = iota
+	// RemoveNodeMutation removes a node and its links
+	RemoveNodeMutation
+	// AddLinkMutation adds a link, and its extremities if needed
+	AddLinkMutation
+	// RemoveLinkMutation removes a link
+	RemoveLinkMutation
+)
+
+// String returns the name of the mutation type
+func (mt MutationType) String() string {
+	switch mt {
+	case AddNodeMutation:
+		return "AddNode"
+	case RemoveNodeMutation:
+		return "RemoveNode"
+	case AddLinkMutation:
+		return "AddLink"
+	case RemoveLinkMutation:
+		return "RemoveLink"
+	default:
+		return "Unknown"
+	}
+}
+
+// Mutation is a change to apply to a graph
+type Mutation[N Node, L Link[N]] struct {
+	// Type is the type of change
+	Type MutationType
+	// Node is the node for node mutations
+	Node N
+	// Link is the link for link mutations
+	Link L
+}
+
+// Validate returns an error for a mutation that makes no sense (unknown type, nil value)
+func (m Mutation[N, L]) Validate() error {
+	switch m.Type {
+	case AddNodeMutation, RemoveNodeMutation:
+		if isNil(m.Node) {
+			return fmt.Errorf("%s: nil node", m.Type)
+		}
+	case AddLinkMutation, RemoveLinkMutation:
+		if isNil(m.Link) {
+			return fmt.Errorf("%s: nil link", m.Type)
+		}
+	default:
+		return fmt.Errorf("unknown mutation type %d", m.Type)
+	}
+
+	return nil
+}
+
+// isNil returns true for nil values, including nil pointers
+func isNil(value any) bool {
+	if value == nil {
+		return true
+	}
+
+	current := reflect.ValueOf(value)
+	return current.Kind() == reflect.Pointer && current.IsNil()
+}
+
+// Batch is a list of mutations to apply at once.
+// Mutations are applied in order, so a batch may add a node and then link it.
+type Batch[N Node, L Link[N]] struct {
+	// Mutations are the changes to apply, in order
+	Mutations []Mutation[N, L]
+	// Validator, if any, is called on each mutation before any change.
+	// Any error rejects the whole batch
+	Validator func(Mutation[N, L]) error
+}
+
+// AddNode stages a node addition
+func (b *Batch[N, L]) AddNode(node N) {
+	b.Mutations = append(b.Mutations, Mutation[N, L]{Type: AddNodeMutation, Node: node})
+}
+
+// RemoveNode stages a node removal
+func (b *Batch[N, L]) RemoveNode(node N) {
+	b.Mutations = append(b.Mutations, Mutation[N, L]{Type: RemoveNodeMutation, Node: node})
+}
+
+// AddLink stages a link addition
+func (b *Batch[N, L]) AddLink(link L) {
+	b.Mutations = append(b.Mutations, Mutation[N, L]{Type: AddLinkMutation, Link: link})
+}
+
+// RemoveLink stages a link removal
+func (b *Batch[N, L]) RemoveLink(link L) {
+	b.Mutations = append(b.Mutations, Mutation[N, L]{Type: RemoveLinkMutation, Link: link})
+}
+
+// Validate validates all mutations (see Mutation.Validate) and then applies Validator, if any.
+// Errors are joined, with the index of the invalid mutation
+func (b Batch[N, L]) Validate() error {
+	var globalErr error
+	for index, mutation := range b.Mutations {
+		err := mutation.Validate()
+		if err == nil && b.Validator != nil {
+			err = b.Validator(mutation)
+		}
+
+		if err != nil {
+			globalErr = errors.Join(globalErr, fmt.Errorf("mutation %d: %w", index, err))
+		}
+	}
+
+	return globalErr
+}
+
+// ErrTransactionClosed is returned when using a committed or rolled back transaction
+var ErrTransactionClosed = errors.New("transaction is closed")
+
+// Transaction groups changes of a graph that are kept or undone together.
+// Changes are visible in the graph as soon as they are made (read your writes),
+// Commit keeps them, Rollback undoes them all, including nodes implicitly added by AddLink.
+// Once committed or rolled back, a transaction is closed and returns ErrTransactionClosed.
+type Transaction[N Node, L Link[N]] interface {
+	// AddNode adds a node, as part of the transaction
+	AddNode(N) error
+	// RemoveNode removes a node and its links, as part of the transaction
+	RemoveNode(N) error
+	// AddLink adds a link and its extremities, as part of the transaction
+	AddLink(L) error
+	// RemoveLink removes a link, as part of the transaction
+	RemoveLink(L) error
+	// Commit keeps the changes and closes the transaction
+	Commit() error
+	// Rollback undoes the changes and closes the transaction
+	Rollback() error
+}
+
+// TransactionalGraph is a graph that may apply many changes atomically.
+// For a given graph, at most one transaction is open at a time,
+// and graph should not be changed outside of that transaction until it is closed.
+type TransactionalGraph[N Node, L Link[N]] interface {
+	// a transactional graph is a central structure graph
+	CentralStructureGraph[N, L]
+	// Begin opens a transaction, or returns an error if one is already open
+	Begin() (Transaction[N, L], error)
+	// Apply validates a batch and applies it atomically:
+	// an invalid batch changes nothing, and a failing mutation undoes the previous ones
+	Apply(Batch[N, L]) error
+}
@@ -0,0 +1,155 @@
+package graphs_test
+
+import (
+	"errors"
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/zefrenchwan/nodz.git/graphs"
+	"github.com/zefrenchwan/nodz.git/internal/local"
+)
+
+// ints returns an iterator over values
+func ints(values ...int) graphs.GeneralIterator[int] {
+	it := local.NewSlicesIterator(values)
+	return &it
+}
+
+// parse converts strings, and fails for non numbers
+func parse(value string) (int, error) {
+	return strconv.Atoi(value)
+}
+
+func TestCombinatorsTransformations(t *testing.T) {
+	words := local.NewSlicesIterator([]string{"1", "x", "2", "3"})
+	// collected errors are returned at the end, with all valid values
+	if values, err := graphs.Collect[int](graphs.Map[string](&words, parse, graphs.CollectErrors), graphs.FailFast); err == nil {
+		t.Error("collected errors should be returned at the end")
+	} else if !slices.Equal(values, []int{1, 2, 3}) {
+		t.Errorf("collect errors should skip failing values, got %v", values)
+	}
+
+	even := graphs.Filter(ints(1, 2, 3, 4), func(v int) bool { return v%2 == 0 }, graphs.FailFast)
+	if values, _ := graphs.Collect[int](even, graphs.FailFast); !slices.Equal(values, []int{2, 4}) {
+		t.Errorf("unexpected filter %v", values)
+	}
+
+	repeated := graphs.FlatMap(ints(1, 2, 0, 3), func(v int) (graphs.GeneralIterator[int], error) {
+		values := make([]int, v)
+		for index := range values {
+			values[index] = v
+		}
+
+		return ints(values...), nil
+	}, graphs.FailFast)
+	if values, _ := graphs.Collect[int](repeated, graphs.FailFast); !slices.Equal(values, []int{1, 2, 2, 3, 3, 3}) {
+		t.Errorf("unexpected flat map %v", values)
+	}
+
+	window := graphs.Take[int](graphs.Skip(ints(1, 2, 3, 4, 5), 1, graphs.FailFast), 3, graphs.FailFast)
+	if values, _ := graphs.Collect[int](window, graphs.FailFast); !slices.Equal(values, []int{2, 3, 4}) {
+		t.Errorf("unexpected skip and take %v", values)
+	}
+
+	small := graphs.TakeWhile(ints(1, 2, 5, 1), func(v int) bool { return v < 3 }, graphs.FailFast)
+	if values, _ := graphs.Collect[int](small, graphs.FailFast); !slices.Equal(values, []int{1, 2}) {
+		t.Errorf("unexpected take while %v", values)
+	}
+
+	unique := graphs.Distinct(ints(1, 2, 1, 3, 2), func(a, b int) bool { return a == b }, graphs.FailFast)
+	if values, _ := graphs.Collect[int](unique, graphs.FailFast); !slices.Equal(values, []int{1, 2, 3}) {
+		t.Errorf("unexpected distinct %v", values)
+	}
+
+	chained := graphs.Chain(graphs.FailFast, ints(1), ints(), ints(2, 3))
+	if values, _ := graphs.Collect[int](chained, graphs.FailFast); !slices.Equal(values, []int{1, 2, 3}) {
+		t.Errorf("unexpected chain %v", values)
+	}
+
+	names := local.NewSlicesIterator([]string{"a", "b", "c"})
+	zipped := graphs.Zip[int, string](ints(1, 2), &names, graphs.FailFast)
+	if values, _ := graphs.Collect[graphs.Pair[int, string]](zipped, graphs.FailFast); len(values) != 2 || values[1].First != 2 || values[1].Second != "b" {
+		t.Errorf("unexpected zip %v", values)
+	}
+
+	chunks := graphs.Chunk(ints(1, 2, 3, 4, 5), 2, graphs.FailFast)
+	if values, _ := graphs.Collect[[]int](chunks, graphs.FailFast); len(values) != 3 || !slices.Equal(values[2], []int{5}) {
+		t.Errorf("unexpected chunks %v", values)
+	}
+}
+
+func TestCombinatorsErrorPolicies(t *testing.T) {
+	words := local.NewSlicesIterator([]string{"1", "x", "2", "y"})
+	failFast := graphs.Map[string](&words, parse, graphs.FailFast)
+	if values, err := graphs.Collect[int](failFast, graphs.CollectErrors); err == nil {
+		t.Error("fail fast should stop at first error")
+	} else if !slices.Equal(values, []int{1}) {
+		t.Errorf("fail fast should keep values before error, got %v", values)
+	}
+
+	words = local.NewSlicesIterator([]string{"1", "x", "2", "y"})
+	collect := graphs.Map[string](&words, parse, graphs.CollectErrors)
+	count := 0
+	for has, _ := collect.Next(); has; has, _ = collect.Next() {
+		count++
+	}
+
+	var numError *strconv.NumError
+	if count != 2 {
+		t.Errorf("expected 2 values, got %d", count)
+	} else if err := collect.Err(); !errors.As(err, &numError) {
+		t.Errorf("errors should be collected, got %v", err)
+	}
+
+	// reducer errors follow the policy too
+	sum, errSum := graphs.Reduce(ints(1, 2, 3), 0, func(total, v int) (int, error) {
+		if v == 2 {
+			return total, errors.New("no two")
+		}
+
+		return total + v, nil
+	}, graphs.CollectErrors)
+	if sum != 4 || errSum == nil {
+		t.Errorf("unexpected reduce %d, %v", sum, errSum)
+	}
+
+	if count, err := graphs.Count(ints(4, 5, 6), graphs.FailFast); count != 3 || err != nil {
+		t.Errorf("unexpected count %d", count)
+	}
+
+	words = local.NewSlicesIterator([]string{"x", "7", "8"})
+	if first, found, err := graphs.First[int](graphs.Map[string](&words, parse, graphs.CollectErrors), graphs.FailFast); !found || first != 7 || err != nil {
+		t.Errorf("first should skip failing values, got %d, %v", first, err)
+	}
+
+	words = local.NewSlicesIterator([]string{"x", "7", "8"})
+	if _, found, err := graphs.First[int](graphs.Map[string](&words, parse, graphs.FailFast), graphs.FailFast); found || err == nil {
+		t.Error("first should fail fast")
+	} else if _, found, _ := graphs.First(ints(), graphs.FailFast); found {
+		t.Error("empty iterator has no first")
+	}
+}
+
+func TestPeekable(t *testing.T) {
+	it := graphs.Peekable(ints(1, 2), graphs.FailFast)
+	if v, has, _ := it.Peek(); !has || v != 1 {
+		t.Fatal("expected to peek 1")
+	}
+
+	it.Next()
+	if v, _ := it.Value(); v != 1 {
+		t.Error("expected 1")
+	} else if v, has, _ := it.Peek(); !has || v != 2 {
+		t.Error("expected to peek 2")
+	} else if v, _ := it.Value(); v != 1 {
+		t.Error("peek should not change current value")
+	}
+
+	it.Next()
+	if _, has, _ := it.Peek(); has {
+		t.Error("nothing to peek at the end")
+	} else if has, _ := it.Next(); has {
+		t.Error("iterator should be over")
+	}
+}
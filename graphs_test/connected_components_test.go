@@ -1,7 +1,9 @@
 package graphs_test
 
 import (
+	"errors"
 	"slices"
+	"strings"
 	"testing"
 
 	"github.com/zefrenchwan/nodz.git/graphs"
@@ -58,3 +60,44 @@ func TestGraphConnectedComponents(t *testing.T) {
 		}
 	}
 }
+
+// failingGraph fails to return the neighborhood of a node, and hides it from all nodes: it is reached as a neighbor only
+type failingGraph struct {
+	*local.MapGraph[internal.IdNode, internal.UndirectedSimpleLink[internal.IdNode]]
+	// failing is the id of the failing node
+	failing string
+}
+
+// Neighbors fails for the failing node
+func (fg failingGraph) Neighbors(node internal.IdNode) (graphs.Neighborhood[internal.IdNode, internal.UndirectedSimpleLink[internal.IdNode]], error) {
+	if node.Id() == fg.failing {
+		return nil, errors.New("unavailable node")
+	}
+
+	return fg.MapGraph.Neighbors(node)
+}
+
+// AllNodes returns the nodes but the failing one
+func (fg failingGraph) AllNodes() (graphs.NodesIterator[internal.IdNode], error) {
+	nodes, err := fg.MapGraph.AllNodes()
+	if err != nil {
+		return nil, err
+	}
+
+	return graphs.Filter(nodes, func(node internal.IdNode) bool { return node.Id() != fg.failing }, graphs.FailFast), nil
+}
+
+func TestGraphConnectedComponentsReportsNeighborErrors(t *testing.T) {
+	graph := local.NewMapGraph[internal.IdNode, internal.UndirectedSimpleLink[internal.IdNode]]()
+	graph.AddLink(internal.NewUndirectedSimpleLink(internal.NewIdNode("a"), internal.NewIdNode("b")))
+	setBuilder := local.HashSetBuilder[internal.IdNode](nil)
+	itBuilder := func() (graphs.DynamicIterator[internal.IdNode], error) {
+		result := local.NewDynamicSlicesIterator[internal.IdNode]()
+		return &result, nil
+	}
+
+	// b fails when reached as a neighbor of a
+	if _, err := graphs.ConnectedComponentsSize(failingGraph{&graph, "b"}, setBuilder, itBuilder); err == nil || !strings.Contains(err.Error(), "unavailable node") {
+		t.Errorf("expected neighbor error, got %v", err)
+	}
+}
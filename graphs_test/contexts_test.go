@@ -0,0 +1,67 @@
+package graphs_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/zefrenchwan/nodz.git/graphs"
+	"github.com/zefrenchwan/nodz.git/internal"
+	"github.com/zefrenchwan/nodz.git/internal/local"
+)
+
+func TestContextIterator(t *testing.T) {
+	values := local.NewSlicesIterator([]int{1, 2, 3})
+	ctx, cancel := context.WithCancel(context.Background())
+	it := graphs.WithContext(ctx, &values)
+
+	if has, err := it.Next(); !has || err != nil {
+		t.Fatal("iterator should move before cancellation")
+	} else if v, _ := it.Value(); v != 1 {
+		t.Errorf("expected 1, got %d", v)
+	}
+
+	cancel()
+	if has, err := it.Next(); has || !errors.Is(err, context.Canceled) {
+		t.Error("iterator should stop after cancellation")
+	}
+
+	expired, cancelExpired := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancelExpired()
+	if has, err := graphs.WithContext(expired, &values).Next(); has || !errors.Is(err, context.DeadlineExceeded) {
+		t.Error("iterator should stop after deadline")
+	}
+}
+
+func TestCancelledAlgorithms(t *testing.T) {
+	graph := triangleWithPendant()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	setBuilder := func(f graphs.SetEqualsFunction[internal.IdNode]) (graphs.AbstractSet[internal.IdNode], error) {
+		result := local.NewSlicesSet(f)
+		return &result, nil
+	}
+
+	itBuilder := func() (graphs.DynamicIterator[internal.IdNode], error) {
+		result := local.NewDynamicSlicesIterator[internal.IdNode]()
+		return &result, nil
+	}
+
+	if stats, err := graphs.ConnectedComponentsSizeContext(ctx, graph, setBuilder, itBuilder); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected cancellation, got %v", err)
+	} else if len(stats) != 0 {
+		t.Error("no component should be found")
+	}
+
+	counter := func(n graphs.Neighborhood[internal.IdNode, parallelLink]) int64 { return n.UndirectedDegree() }
+	if _, err := graphs.CalculateNetworkStatisticsContext(ctx, graph, counter); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected cancellation, got %v", err)
+	}
+
+	// wrappers keep working
+	if stats, err := graphs.ConnectedComponentsSize(graph, setBuilder, itBuilder); err != nil || len(stats) != 1 {
+		t.Error("wrapper should find one component")
+	}
+}
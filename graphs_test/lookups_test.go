@@ -0,0 +1,53 @@
+package graphs_test
+
+import (
+	"testing"
+
+	"github.com/zefrenchwan/nodz.git/graphs"
+	"github.com/zefrenchwan/nodz.git/internal"
+	"github.com/zefrenchwan/nodz.git/internal/local"
+)
+
+func TestFindNodeAndLink(t *testing.T) {
+	type propertiesLink = *internal.TypePropertiesLink[*internal.PropertiesNode]
+	graph := local.NewMapGraph[*internal.PropertiesNode, propertiesLink]()
+	stored := internal.NewPropertiesNodeWithId("a")
+	stored.SetProperty("stored", "yes")
+	other := internal.NewPropertiesNodeWithId("b")
+	link := internal.NewTypePropertiesLink("knows", &stored, &other)
+	link.SetProperty("since", "2010")
+	graph.AddLink(&link)
+
+	// same node, other instance
+	copied := internal.NewPropertiesNodeWithId("a")
+	missing := internal.NewPropertiesNodeWithId("missing")
+	if found, err := graphs.HasNode(&graph, &copied); err != nil || !found {
+		t.Errorf("node should be in graph, got %t, %v", found, err)
+	} else if found, _ := graphs.HasNode(&graph, &missing); found {
+		t.Error("missing node should not be in graph")
+	}
+
+	if node, found, err := graphs.FindNode(&graph, &copied); err != nil || !found {
+		t.Fatalf("node should be found, got %t, %v", found, err)
+	} else if value, _ := node.GetProperty("stored"); value != "yes" {
+		t.Error("expected the instance in the graph")
+	} else if _, found, _ := graphs.FindNode(&graph, &missing); found {
+		t.Error("missing node should not be found")
+	}
+
+	search := internal.NewTypePropertiesLink("knows", &copied, &other)
+	if current, found, err := graphs.FindLink(&graph, &search); err != nil || !found {
+		t.Fatalf("link should be found, got %t, %v", found, err)
+	} else if value, _ := current.GetProperty("since"); value != "2010" {
+		t.Error("expected the instance in the graph")
+	}
+
+	reversed := internal.NewTypePropertiesLink("knows", &other, &copied)
+	if _, found, _ := graphs.FindLink(&graph, &reversed); found {
+		t.Error("directed link should not be found in reverse")
+	} else if _, found, _ := graphs.FindLinkFunc(&graph, &copied, func(current propertiesLink) bool {
+		return current.Destination().SameNode(&other)
+	}); !found {
+		t.Error("link should match")
+	}
+}
@@ -0,0 +1,132 @@
+package graphs_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/zefrenchwan/nodz.git/graphs"
+)
+
+// remoteValues simulates a remote source of values, cursor is the index of the next value
+type remoteValues struct {
+	lock     sync.Mutex
+	values   []int
+	cursors  []int
+	failures int
+	fatal    error
+}
+
+func (rv *remoteValues) fetch(ctx context.Context, cursor int, pageSize int) ([]int, int, error) {
+	rv.lock.Lock()
+	defer rv.lock.Unlock()
+	rv.cursors = append(rv.cursors, cursor)
+	if rv.fatal != nil {
+		return nil, 0, rv.fatal
+	} else if rv.failures > 0 {
+		rv.failures--
+		return nil, 0, errors.New("transient")
+	}
+
+	end := min(cursor+pageSize, len(rv.values))
+	next := end
+	if end == len(rv.values) {
+		next = 0
+	}
+
+	return rv.values[cursor:end], next, nil
+}
+
+func (rv *remoteValues) fetchedCursors() []int {
+	rv.lock.Lock()
+	defer rv.lock.Unlock()
+	return slices.Clone(rv.cursors)
+}
+
+func TestPagedIterator(t *testing.T) {
+	for _, prefetch := range []bool{false, true} {
+		remote := &remoteValues{values: []int{1, 2, 3, 4, 5, 6, 7}}
+		it := graphs.NewPagedIterator(context.Background(), remote.fetch, graphs.PagingOptions{PageSize: 3, Prefetch: prefetch})
+		if values, err := graphs.Collect(it, graphs.FailFast); err != nil {
+			t.Fatal(err)
+		} else if !slices.Equal(values, remote.values) {
+			t.Errorf("prefetch %t: expected %v, got %v", prefetch, remote.values, values)
+		}
+
+		if cursors := remote.fetchedCursors(); !slices.Equal(cursors, []int{0, 3, 6}) {
+			t.Errorf("prefetch %t: unexpected fetches %v", prefetch, cursors)
+		}
+
+		if has, err := it.Next(); has || err != nil {
+			t.Error("iterator should be over")
+		}
+	}
+}
+
+func TestPagedIteratorEmpty(t *testing.T) {
+	remote := &remoteValues{}
+	it := graphs.NewPagedIterator(context.Background(), remote.fetch, graphs.PagingOptions{})
+	if has, err := it.Next(); has || err != nil {
+		t.Error("empty source should have no value")
+	} else if _, err := it.Value(); err == nil {
+		t.Error("no value expected")
+	}
+}
+
+func TestPagedIteratorPrefetch(t *testing.T) {
+	remote := &remoteValues{values: []int{1, 2, 3, 4}}
+	it := graphs.NewPagedIterator(context.Background(), remote.fetch, graphs.PagingOptions{PageSize: 2, Prefetch: true})
+	defer it.Close()
+
+	if has, err := it.Next(); !has || err != nil {
+		t.Fatal("iterator should have values")
+	}
+
+	// second page is loading while first one is read, next page after it is the end
+	if values, err := graphs.Collect(it, graphs.FailFast); err != nil {
+		t.Fatal(err)
+	} else if !slices.Equal(values, []int{2, 3, 4}) {
+		t.Errorf("unexpected values %v", values)
+	}
+
+	if cursors := remote.fetchedCursors(); !slices.Equal(cursors, []int{0, 2}) {
+		t.Errorf("unexpected fetches %v", cursors)
+	}
+}
+
+func TestPagedIteratorRetries(t *testing.T) {
+	remote := &remoteValues{values: []int{1, 2, 3}, failures: 2}
+	options := graphs.PagingOptions{PageSize: 2, Retries: 2}
+	if values, err := graphs.Collect(graphs.NewPagedIterator(context.Background(), remote.fetch, options), graphs.FailFast); err != nil {
+		t.Fatal(err)
+	} else if !slices.Equal(values, remote.values) {
+		t.Errorf("unexpected values %v", values)
+	}
+
+	remote = &remoteValues{values: []int{1, 2, 3}, failures: 3}
+	if _, err := graphs.Collect(graphs.NewPagedIterator(context.Background(), remote.fetch, options), graphs.FailFast); err == nil {
+		t.Error("too many failures should raise an error")
+	}
+
+	fatal := errors.New("fatal")
+	remote = &remoteValues{values: []int{1, 2, 3}, fatal: fatal}
+	options.IsTransient = func(err error) bool { return !errors.Is(err, fatal) }
+	if _, err := graphs.Collect(graphs.NewPagedIterator(context.Background(), remote.fetch, options), graphs.FailFast); !errors.Is(err, fatal) {
+		t.Error("expected fatal error")
+	} else if cursors := remote.fetchedCursors(); len(cursors) != 1 {
+		t.Errorf("fatal error should not be retried, got %d fetches", len(cursors))
+	}
+}
+
+func TestPagedIteratorCancel(t *testing.T) {
+	remote := &remoteValues{values: []int{1, 2, 3}, failures: 10}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	it := graphs.NewPagedIterator(ctx, remote.fetch, graphs.PagingOptions{Retries: 5})
+	if has, err := it.Next(); has || !errors.Is(err, context.Canceled) {
+		t.Errorf("expected cancellation, got %v", err)
+	}
+}
@@ -0,0 +1,157 @@
+package graphs_test
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"testing"
+
+	"github.com/zefrenchwan/nodz.git/graphs"
+	"github.com/zefrenchwan/nodz.git/internal"
+	"github.com/zefrenchwan/nodz.git/internal/local"
+)
+
+type parallelLink = internal.UndirectedSimpleLink[internal.IdNode]
+
+// triangleWithPendant returns a - b - c - a and c - d
+func triangleWithPendant() *local.MapGraph[internal.IdNode, parallelLink] {
+	graph := local.NewMapGraph[internal.IdNode, parallelLink]()
+	a, b, c, d := internal.NewIdNode("a"), internal.NewIdNode("b"), internal.NewIdNode("c"), internal.NewIdNode("d")
+	graph.AddLink(internal.NewUndirectedSimpleLink(a, b))
+	graph.AddLink(internal.NewUndirectedSimpleLink(b, c))
+	graph.AddLink(internal.NewUndirectedSimpleLink(c, a))
+	graph.AddLink(internal.NewUndirectedSimpleLink(c, d))
+	return &graph
+}
+
+func TestParallelNetworkStatistics(t *testing.T) {
+	generator := local.RandomGenerator[internal.IdNode, parallelLink]{}
+	graph, errGraph := generator.UndirectedBarabasiAlbertGraph(3, 300, internal.NewRandomIdNode, internal.NewUndirectedSimpleLink)
+	if errGraph != nil {
+		t.Fatal(errGraph)
+	}
+
+	counter := func(n graphs.Neighborhood[internal.IdNode, parallelLink]) int64 { return n.UndirectedDegree() }
+	expected, errExpected := graphs.CalculateNetworkStatistics(graph, counter)
+	if errExpected != nil {
+		t.Fatal(errExpected)
+	}
+
+	for _, workers := range []int{1, 3, 8} {
+		stats, err := graphs.ParallelNetworkStatistics(graph, counter, graphs.ParallelOptions[internal.IdNode]{Workers: workers})
+		if err != nil {
+			t.Fatal(err)
+		} else if stats.NodesSize != expected.NodesSize || stats.UndirectedSize != expected.UndirectedSize || stats.DirectedSize != expected.DirectedSize {
+			t.Errorf("%d workers: sizes differ from sequential version", workers)
+		}
+
+		for degree, value := range expected.DegreeDistribution {
+			if stats.DegreeDistribution[degree] != value {
+				t.Errorf("%d workers: distribution differs for degree %d", workers, degree)
+			}
+		}
+	}
+}
+
+func TestLevelSynchronousBFS(t *testing.T) {
+	graph := triangleWithPendant()
+	graph.AddNode(internal.NewIdNode("isolated"))
+
+	levels, err := graphs.LevelSynchronousBFS(graph, internal.NewIdNode("a"), graphs.ParallelOptions[internal.IdNode]{Workers: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids := make([][]string, 0)
+	for _, level := range levels {
+		current := make([]string, 0)
+		for _, node := range level {
+			current = append(current, node.Id())
+		}
+
+		ids = append(ids, current)
+	}
+
+	expected := [][]string{{"a"}, {"b", "c"}, {"d"}}
+	if !slices.EqualFunc(ids, expected, slices.Equal) {
+		t.Errorf("expected %v, got %v", expected, ids)
+	}
+
+	if levels, err := graphs.LevelSynchronousBFS(graph, internal.NewIdNode("none"), graphs.ParallelOptions[internal.IdNode]{}); err != nil || levels != nil {
+		t.Error("node not in graph should have no level")
+	}
+
+	if stats, err := graphs.ParallelConnectedComponentsSize(graph, graphs.ParallelOptions[internal.IdNode]{Workers: 2}); err != nil {
+		t.Fatal(err)
+	} else if len(stats) != 2 || stats[0] != 4 || stats[1] != 1 {
+		t.Errorf("unexpected components %v", stats)
+	}
+}
+
+func TestCalculateNodeMetrics(t *testing.T) {
+	options := graphs.MetricsOptions[internal.IdNode]{PathCentralities: true}
+	options.Workers = 3
+	metrics, err := graphs.CalculateNodeMetrics(triangleWithPendant(), options)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(metrics) != 4 {
+		t.Fatalf("expected 4 nodes, got %d", len(metrics))
+	}
+
+	expected := []struct {
+		key                                        string
+		degree                                     int64
+		centrality, clustering, closeness, between float64
+	}{
+		{"a", 2, 2.0 / 3.0, 1, 3.0 / 4.0, 0},
+		{"b", 2, 2.0 / 3.0, 1, 3.0 / 4.0, 0},
+		{"c", 3, 1, 1.0 / 3.0, 1, 4},
+		{"d", 1, 1.0 / 3.0, 0, 3.0 / 5.0, 0},
+	}
+
+	for index, value := range expected {
+		current := metrics[index]
+		switch {
+		case current.Key != value.key:
+			t.Errorf("expected %s at %d, got %s", value.key, index, current.Key)
+		case current.UndirectedDegree != value.degree:
+			t.Errorf("%s: invalid degree %d", value.key, current.UndirectedDegree)
+		case math.Abs(current.DegreeCentrality-value.centrality) > 1e-9:
+			t.Errorf("%s: invalid degree centrality %f", value.key, current.DegreeCentrality)
+		case math.Abs(current.Clustering-value.clustering) > 1e-9:
+			t.Errorf("%s: invalid clustering %f", value.key, current.Clustering)
+		case math.Abs(current.Closeness-value.closeness) > 1e-9:
+			t.Errorf("%s: invalid closeness %f", value.key, current.Closeness)
+		case math.Abs(current.Betweenness-value.between) > 1e-9:
+			t.Errorf("%s: invalid betweenness %f", value.key, current.Betweenness)
+		}
+	}
+}
+
+func TestNodeMetricsDeterminism(t *testing.T) {
+	generator := local.RandomGenerator[internal.IdNode, parallelLink]{}
+	graph, errGraph := generator.UndirectedBarabasiAlbertGraph(3, 200, internal.NewRandomIdNode, internal.NewUndirectedSimpleLink)
+	if errGraph != nil {
+		t.Fatal(errGraph)
+	}
+
+	options := graphs.MetricsOptions[internal.IdNode]{PathCentralities: true}
+	options.Workers = 8
+	first, errFirst := graphs.CalculateNodeMetrics(graph, options)
+	second, errSecond := graphs.CalculateNodeMetrics(graph, options)
+	options.Workers = 1
+	sequential, errSequential := graphs.CalculateNodeMetrics(graph, options)
+	if err := errors.Join(errFirst, errSecond, errSequential); err != nil {
+		t.Fatal(err)
+	}
+
+	for index := range first {
+		if first[index] != second[index] {
+			t.Fatalf("same options should give same results for %s", first[index].Key)
+		} else if a, b := first[index], sequential[index]; a.Key != b.Key || a.Clustering != b.Clustering || a.Closeness != b.Closeness {
+			t.Fatalf("results should not depend on workers for %s", a.Key)
+		} else if math.Abs(a.Betweenness-b.Betweenness) > 1e-6 {
+			t.Fatalf("betweenness should not depend on workers for %s", a.Key)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package graphs_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/zefrenchwan/nodz.git/graphs"
+	"github.com/zefrenchwan/nodz.git/internal"
+	"github.com/zefrenchwan/nodz.git/internal/local"
+)
+
+// pathIds returns the ids of the nodes of a path
+func pathIds(path []internal.IdNode) []string {
+	result := make([]string, len(path))
+	for index, node := range path {
+		result[index] = node.Id()
+	}
+
+	return result
+}
+
+func TestShortestPathUndirected(t *testing.T) {
+	graph := triangleWithPendant()
+	a, b, d := internal.NewIdNode("a"), internal.NewIdNode("b"), internal.NewIdNode("d")
+
+	if path, err := graphs.ShortestPath(graph, b, d, nil); err != nil {
+		t.Fatal(err)
+	} else if ids := pathIds(path); !slices.Equal(ids, []string{"b", "c", "d"}) {
+		t.Errorf("unexpected path %v", ids)
+	}
+
+	if path, err := graphs.ShortestPath(graph, a, a, nil); err != nil || !slices.Equal(pathIds(path), []string{"a"}) {
+		t.Errorf("path to itself should be the node, got %v", path)
+	}
+
+	if path, err := graphs.ShortestPath(graph, internal.NewIdNode("z"), a, nil); err != nil || path != nil {
+		t.Error("no path expected from a node not in the graph")
+	}
+}
+
+func TestShortestPathDirected(t *testing.T) {
+	type directedLink = internal.ValuedLink[internal.IdNode, int]
+	graph := local.NewMapGraph[internal.IdNode, directedLink]()
+	a, b, c, d := internal.NewIdNode("a"), internal.NewIdNode("b"), internal.NewIdNode("c"), internal.NewIdNode("d")
+	// two paths of same length from a to d, and a shortcut back from d to a
+	graph.AddLink(internal.NewDirectedValuedLink(a, c, 1))
+	graph.AddLink(internal.NewDirectedValuedLink(c, d, 1))
+	graph.AddLink(internal.NewDirectedValuedLink(a, b, 1))
+	graph.AddLink(internal.NewDirectedValuedLink(b, d, 1))
+	graph.AddLink(internal.NewDirectedValuedLink(d, a, 1))
+
+	for range 10 {
+		if path, err := graphs.ShortestPath(&graph, a, d, nil); err != nil {
+			t.Fatal(err)
+		} else if ids := pathIds(path); !slices.Equal(ids, []string{"a", "b", "d"}) {
+			t.Errorf("expected first path in keys order, got %v", ids)
+		}
+	}
+
+	if path, err := graphs.ShortestPath(&graph, d, b, nil); err != nil || !slices.Equal(pathIds(path), []string{"d", "a", "b"}) {
+		t.Errorf("unexpected path %v", pathIds(path))
+	}
+
+	graph.RemoveLink(internal.NewDirectedValuedLink(d, a, 1))
+	if path, err := graphs.ShortestPath(&graph, d, a, nil); err != nil || path != nil {
+		t.Errorf("links direction should be followed, got %v", pathIds(path))
+	}
+}
@@ -0,0 +1,92 @@
+package graphs_test
+
+import (
+	"errors"
+	"iter"
+	"slices"
+	"testing"
+
+	"github.com/zefrenchwan/nodz.git/graphs"
+	"github.com/zefrenchwan/nodz.git/internal/local"
+)
+
+func TestIteratorToSequence(t *testing.T) {
+	values := local.NewSlicesIterator([]int{1, 2, 3})
+	if result := slices.Collect(graphs.Values[int](&values)); !slices.Equal(result, []int{1, 2, 3}) {
+		t.Errorf("unexpected values %v", result)
+	}
+
+	// errors of values come with the sequence, iteration goes on
+	failing := graphs.MapFilterIterator[int, int]{
+		Iterator: graphs.FromSeq(slices.Values([]int{1, -1, 2})),
+		Mapper: func(v int) (int, error) {
+			if v < 0 {
+				return 0, errors.New("negative")
+			}
+
+			return v * 10, nil
+		},
+		Filter: func(int) bool { return true },
+	}
+
+	var collected []int
+	var errs int
+	for value, err := range graphs.All[int](&failing) {
+		if err != nil {
+			errs++
+		} else {
+			collected = append(collected, value)
+		}
+	}
+
+	if !slices.Equal(collected, []int{10, 20}) || errs != 1 {
+		t.Errorf("unexpected values %v and %d errors", collected, errs)
+	}
+
+	if count := len(slices.Collect(graphs.Values[int](nil))); count != 0 {
+		t.Error("nil iterator should be empty")
+	}
+}
+
+func TestSequenceToIterator(t *testing.T) {
+	it := graphs.FromSeq(slices.Values([]string{"a", "b"}))
+	if _, err := it.Value(); err == nil {
+		t.Error("no value before Next")
+	}
+
+	result := make([]string, 0)
+	for has, err := it.Next(); has; has, err = it.Next() {
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		value, _ := it.Value()
+		result = append(result, value)
+	}
+
+	if !slices.Equal(result, []string{"a", "b"}) {
+		t.Errorf("unexpected values %v", result)
+	}
+
+	// errors of the sequence are returned by Value
+	var seq iter.Seq2[int, error] = func(yield func(int, error) bool) {
+		_ = yield(1, nil) && yield(0, errors.New("broken")) && yield(3, nil)
+	}
+
+	withErrors := graphs.FromSeq2(seq)
+	withErrors.Next()
+	if v, err := withErrors.Value(); v != 1 || err != nil {
+		t.Error("expected 1")
+	}
+
+	withErrors.Next()
+	if _, err := withErrors.Value(); err == nil {
+		t.Error("expected error")
+	}
+
+	// stop before the end
+	withErrors.Stop()
+	if has, _ := withErrors.Next(); has {
+		t.Error("stopped iterator should have no next")
+	}
+}
@@ -0,0 +1,98 @@
+package graphs_test
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/zefrenchwan/nodz.git/graphs"
+	"github.com/zefrenchwan/nodz.git/internal/local"
+)
+
+// hashSetOf returns a hash set of values
+func hashSetOf(values ...int) *local.HashSet[int] {
+	result := local.NewHashSet(func(value int) string { return fmt.Sprint(value) })
+	for _, value := range values {
+		result.Add(value)
+	}
+
+	return &result
+}
+
+// slicesSetOf returns a slices set of values
+func slicesSetOf(values ...int) *local.SlicesSet[int] {
+	result := local.NewSlicesSet(func(a, b int) bool { return a == b })
+	for _, value := range values {
+		result.Add(value)
+	}
+
+	return &result
+}
+
+// sorted returns the sorted elements of a set
+func sorted(t *testing.T, set graphs.AbstractSet[int]) []int {
+	t.Helper()
+	elements, _ := set.ToIterator()
+	result := make([]int, 0)
+	for has, err := elements.Next(); has || err != nil; has, err = elements.Next() {
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		value, _ := elements.Value()
+		result = append(result, value)
+	}
+
+	slices.Sort(result)
+	return result
+}
+
+func TestSetAlgebra(t *testing.T) {
+	a, b := hashSetOf(1, 2, 3, 4), slicesSetOf(3, 4, 5)
+	operations := map[string]func(result, a, b graphs.AbstractSet[int]) error{
+		"union":                graphs.Union[int],
+		"intersection":         graphs.Intersection[int],
+		"difference":           graphs.Difference[int],
+		"symmetric difference": graphs.SymmetricDifference[int],
+	}
+
+	expected := map[string][]int{
+		"union":                {1, 2, 3, 4, 5},
+		"intersection":         {3, 4},
+		"difference":           {1, 2},
+		"symmetric difference": {1, 2, 5},
+	}
+
+	for name, operation := range operations {
+		result := hashSetOf()
+		if err := operation(result, a, b); err != nil {
+			t.Fatal(err)
+		} else if got := sorted(t, result); !slices.Equal(got, expected[name]) {
+			t.Errorf("%s: expected %v, got %v", name, expected[name], got)
+		}
+
+		// implementations may be swapped
+		other := slicesSetOf()
+		if err := operation(other, b, a); err != nil {
+			t.Fatal(err)
+		} else if name != "difference" && !slices.Equal(sorted(t, other), expected[name]) {
+			t.Errorf("%s: operation should be symmetric", name)
+		}
+	}
+
+	if err := graphs.Union(nil, a, b); err == nil {
+		t.Error("nil result should fail")
+	}
+}
+
+func TestIsSubset(t *testing.T) {
+	if subset, err := graphs.IsSubset[int](slicesSetOf(3, 4), hashSetOf(1, 2, 3, 4)); err != nil || !subset {
+		t.Error("expected a subset")
+	} else if subset, _ := graphs.IsSubset[int](hashSetOf(3, 6), slicesSetOf(3, 4, 5)); subset {
+		t.Error("6 is missing")
+	} else if subset, _ := graphs.IsSubset[int](hashSetOf(1, 2, 3), slicesSetOf(1, 2)); subset {
+		t.Error("larger set is not a subset")
+	} else if subset, _ := graphs.IsSubset[int](hashSetOf(), slicesSetOf()); !subset {
+		t.Error("empty set is a subset of any set")
+	}
+}
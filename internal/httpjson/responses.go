@@ -0,0 +1,60 @@
+package httpjson
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// StatusError is an error with the http status to return
+type StatusError struct {
+	// Status is the http status code
+	Status int
+	// Message is the error message, written in the json body
+	Message string
+}
+
+// NewStatusError returns an error with a formatted message
+func NewStatusError(status int, format string, args ...any) StatusError {
+	return StatusError{Status: status, Message: fmt.Sprintf(format, args...)}
+}
+
+// Error returns the message
+func (se StatusError) Error() string {
+	return se.Message
+}
+
+// errorBody is the json body of errors
+type errorBody struct {
+	// Error is the error message
+	Error string `json:"error"`
+	// Status is the http status code, repeated for clients reading the body only
+	Status int `json:"status"`
+}
+
+// WriteError writes err as a json body. Errors that are not StatusError are internal errors
+func WriteError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	var statusErr StatusError
+	if errors.As(err, &statusErr) {
+		status = statusErr.Status
+	}
+
+	WriteJSON(w, status, errorBody{Error: err.Error(), Status: status})
+}
+
+// WriteJSON writes value as the json body of the response.
+// It fails only if value cannot be encoded, and then nothing is written
+func WriteJSON(w http.ResponseWriter, status int, value any) error {
+	content, errContent := json.Marshal(value)
+	if errContent != nil {
+		return errContent
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	// headers are sent, nothing to do if client is gone
+	w.Write(append(content, '\n'))
+	return nil
+}
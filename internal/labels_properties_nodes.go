@@ -44,8 +44,13 @@ func (lpn *LabelsPropertiesNode) SameNode(other graphs.Node) bool {
 
 // NewLabelsPropertiesNode returns a new initialized node
 func NewLabelsPropertiesNode() LabelsPropertiesNode {
+	return NewLabelsPropertiesNodeWithId(graphs.NewUniqueId())
+}
+
+// NewLabelsPropertiesNodeWithId returns a new initialized node with a given id (for instance, read from a file)
+func NewLabelsPropertiesNodeWithId(id string) LabelsPropertiesNode {
 	return LabelsPropertiesNode{
-		nodeId:         graphs.NewUniqueId(),
+		nodeId:         id,
 		nodeLabels:     make(map[string]bool),
 		nodeProperties: make(map[string]string),
 	}
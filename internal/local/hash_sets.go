@@ -0,0 +1,192 @@
+package local
+
+import (
+	"errors"
+	"iter"
+
+	"github.com/zefrenchwan/nodz.git/graphs"
+)
+
+// HashSet is a set based on a map, with O(1) Add, Has and Remove.
+// Elements are any, so they are not map keys: a key function returns their key.
+// Elements are the same for the set if they have the same key, so key should agree with equality.
+// Iteration and Peek follow insertion order, as in SlicesSet
+type HashSet[T any] struct {
+	// key returns the key of an element, nil for ids
+	key func(T) string
+	// elements are the elements and their position in order, by key
+	elements map[string]hashSetEntry[T]
+	// order are the keys by insertion. A key is valid at its position only
+	order []string
+	// head is the position of the first key that may be valid
+	head int
+}
+
+// hashSetEntry is an element of a hash set
+type hashSetEntry[T any] struct {
+	// value is the element
+	value T
+	// position is the position of its key in order
+	position int
+}
+
+// NewHashSet returns a new empty hash set.
+// Key returns the key of an element, if nil, elements should implement graphs.WithId, and key is the id
+func NewHashSet[T any](key func(T) string) HashSet[T] {
+	var result HashSet[T]
+	result.key = key
+	result.elements = make(map[string]hashSetEntry[T])
+	return result
+}
+
+// HashSetBuilder returns a builder of hash sets.
+// Sets compare keys, not elements: equality function of the builder is not used
+func HashSetBuilder[T any](key func(T) string) graphs.AbstractSetBuilder[T] {
+	return func(graphs.SetEqualsFunction[T]) (graphs.AbstractSet[T], error) {
+		result := NewHashSet(key)
+		return &result, nil
+	}
+}
+
+// ToIterator returns an iterator over the elements of the set
+func (s *HashSet[T]) ToIterator() (graphs.GeneralIterator[T], error) {
+	values := make([]T, 0, s.Size())
+	for value := range s.All() {
+		values = append(values, value)
+	}
+
+	result := NewSlicesIterator(values)
+	return &result, nil
+}
+
+// All returns the elements of the set, in insertion order
+func (s *HashSet[T]) All() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		if s == nil {
+			return
+		}
+
+		for position := s.head; position < len(s.order); position++ {
+			if current, valid := s.at(position); valid && !yield(current.value) {
+				return
+			}
+		}
+	}
+}
+
+// IsEmpty returns true for nil or empty set, false otherwise
+func (s *HashSet[T]) IsEmpty() (bool, error) {
+	return s == nil || len(s.elements) == 0, nil
+}
+
+// Add the element if its key is not in the set
+func (s *HashSet[T]) Add(element T) error {
+	if s == nil {
+		return errors.New("nil set")
+	}
+
+	key, errKey := s.keyOf(element)
+	if errKey != nil {
+		return errKey
+	} else if s.elements == nil {
+		s.elements = make(map[string]hashSetEntry[T])
+	} else if _, found := s.elements[key]; found {
+		return nil
+	}
+
+	s.elements[key] = hashSetEntry[T]{value: element, position: len(s.order)}
+	s.order = append(s.order, key)
+	return nil
+}
+
+// Has returns true if the set has an element with the same key
+func (s *HashSet[T]) Has(element T) (bool, error) {
+	if s == nil {
+		return false, nil
+	}
+
+	key, errKey := s.keyOf(element)
+	if errKey != nil {
+		return false, errKey
+	}
+
+	_, found := s.elements[key]
+	return found, nil
+}
+
+// Remove excludes the element with the same key
+func (s *HashSet[T]) Remove(element T) error {
+	if s == nil {
+		return nil
+	}
+
+	key, errKey := s.keyOf(element)
+	if errKey != nil {
+		return errKey
+	}
+
+	delete(s.elements, key)
+	if len(s.order)-s.head > 2*len(s.elements)+16 {
+		s.compact()
+	}
+
+	return nil
+}
+
+// Peek gets the oldest element in the set
+func (s *HashSet[T]) Peek() (T, error) {
+	var empty T
+	if s == nil || s.elements == nil {
+		return empty, errors.New("nil set")
+	} else if len(s.elements) == 0 {
+		return empty, errors.New("empty set")
+	}
+
+	for ; s.head < len(s.order); s.head++ {
+		if current, valid := s.at(s.head); valid {
+			return current.value, nil
+		}
+	}
+
+	return empty, errors.New("empty set")
+}
+
+// Size returns the size of the set
+func (s *HashSet[T]) Size() int64 {
+	if s == nil {
+		return 0
+	}
+
+	return int64(len(s.elements))
+}
+
+// keyOf returns the key of an element: key function if any, id if element implements WithId
+func (s *HashSet[T]) keyOf(element T) (string, error) {
+	if s.key != nil {
+		return s.key(element), nil
+	} else if withId, ok := any(element).(graphs.WithId); ok {
+		return withId.Id(), nil
+	}
+
+	return "", errors.New("element has no id, and no key function")
+}
+
+// at returns the element of the key at position, and true if key is still valid there
+func (s *HashSet[T]) at(position int) (hashSetEntry[T], bool) {
+	current, found := s.elements[s.order[position]]
+	return current, found && current.position == position
+}
+
+// compact removes keys of removed elements from order
+func (s *HashSet[T]) compact() {
+	order := make([]string, 0, len(s.elements))
+	for position := s.head; position < len(s.order); position++ {
+		if current, valid := s.at(position); valid {
+			current.position = len(order)
+			s.elements[s.order[position]] = current
+			order = append(order, s.order[position])
+		}
+	}
+
+	s.order, s.head = order, 0
+}
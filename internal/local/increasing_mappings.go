@@ -51,6 +51,15 @@ func (im *increasingMapping[V]) addValue(value V) int {
 	return index
 }
 
+// appendValue adds a value known to be new, with no test, and returns its index
+func (im *increasingMapping[V]) appendValue(value V) int {
+	index := im.maxIndex
+	im.values[index] = value
+	im.maxIndex = index + 1
+
+	return index
+}
+
 // getValue returns the index of the element if found, 0 and false
 func (im *increasingMapping[V]) getValue(value V) (int, bool) {
 	for k, v := range im.values {
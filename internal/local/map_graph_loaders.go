@@ -0,0 +1,46 @@
+package local
+
+import (
+	"errors"
+
+	"github.com/zefrenchwan/nodz.git/graphs"
+)
+
+// MapGraphLoader builds a map graph from distinct nodes, and links between nodes indexes.
+// Map graphs scan nodes to find their index, so loading a large graph with AddNode and AddLink is quadratic.
+// Loader skips those scans: it is the fast path for formats that store each node once (snapshots, for instance).
+type MapGraphLoader[N graphs.Node, L graphs.Link[N]] struct {
+	// graph is the graph to build
+	graph MapGraph[N, L]
+}
+
+// NewMapGraphLoader returns a loader for a new empty map graph
+func NewMapGraphLoader[N graphs.Node, L graphs.Link[N]]() MapGraphLoader[N, L] {
+	return MapGraphLoader[N, L]{
+		graph: NewMapGraph[N, L](),
+	}
+}
+
+// AppendNode adds a node that is NOT in the graph yet, and returns its index.
+// There is no test, appending a node twice breaks the graph
+func (ml *MapGraphLoader[N, L]) AppendNode(node N) int {
+	return ml.graph.nodes.appendValue(node)
+}
+
+// AppendLink adds a link from the node at sourceIndex to the node at destinationIndex.
+// Indexes are the ones returned by AppendNode
+func (ml *MapGraphLoader[N, L]) AppendLink(sourceIndex, destinationIndex int, link L) error {
+	if _, found := ml.graph.nodes.values[sourceIndex]; !found {
+		return errors.New("invalid source index")
+	} else if _, found := ml.graph.nodes.values[destinationIndex]; !found {
+		return errors.New("invalid destination index")
+	}
+
+	ml.graph.setLink(sourceIndex, destinationIndex, link)
+	return nil
+}
+
+// Graph returns the graph built so far
+func (ml *MapGraphLoader[N, L]) Graph() *MapGraph[N, L] {
+	return &ml.graph
+}
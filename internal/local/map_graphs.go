@@ -2,6 +2,7 @@ package local
 
 import (
 	"errors"
+	"iter"
 	"slices"
 
 	"github.com/zefrenchwan/nodz.git/graphs"
@@ -15,6 +16,8 @@ type MapGraph[N graphs.Node, L graphs.Link[N]] struct {
 	// content is a map, keys are nodes index, values are nodes metadata and their outgoing links.
 	// It does NOT contain all the nodes, just the ones with at least one link.
 	content map[int]mapLine[N, L]
+	// transaction is the open transaction, if any
+	transaction *mapTransaction[N, L]
 }
 
 // NewMapGraph returns a new empty map matrix as a central structure graph
@@ -42,6 +45,26 @@ func (am *MapGraph[N, L]) RemoveNode(node N) error {
 		return nil
 	}
 
+	// directed links from the removed node are incoming links of their destinations
+	for destIndex, links := range am.content[targetIndex].values {
+		if destIndex == targetIndex {
+			continue
+		}
+
+		line, found := am.content[destIndex]
+		if !found {
+			continue
+		}
+
+		for _, link := range links {
+			if link.IsDirected() {
+				line.incomingCounter = line.incomingCounter - 1
+			}
+		}
+
+		am.content[destIndex] = line
+	}
+
 	delete(am.content, targetIndex)
 	for key, lines := range am.content {
 		lines.removeNode(targetIndex)
@@ -143,6 +166,47 @@ func (am *MapGraph[N, L]) AllNodes() (graphs.NodesIterator[N], error) {
 	return am.nodes.toIterator(), nil
 }
 
+// All returns the nodes of the graph, in no particular order
+func (am *MapGraph[N, L]) All() iter.Seq[N] {
+	return func(yield func(N) bool) {
+		for _, node := range am.nodes.values {
+			if !yield(node) {
+				return
+			}
+		}
+	}
+}
+
+// Links returns each link of the graph once, in no particular order.
+// Undirected links are stored twice, they are returned once
+func (am *MapGraph[N, L]) Links() iter.Seq[L] {
+	return func(yield func(L) bool) {
+		for sourceIndex, line := range am.content {
+			for destIndex, links := range line.values {
+				for _, link := range links {
+					if !link.IsDirected() && sourceIndex > destIndex {
+						continue
+					} else if !yield(link) {
+						return
+					}
+				}
+			}
+		}
+	}
+}
+
+// Neighborhoods returns the nodes of the graph with their neighborhood, in no particular order
+func (am *MapGraph[N, L]) Neighborhoods() iter.Seq2[N, graphs.Neighborhood[N, L]] {
+	return func(yield func(N, graphs.Neighborhood[N, L]) bool) {
+		for index, node := range am.nodes.values {
+			line := am.content[index]
+			if !yield(node, line.toNeighborhood(node)) {
+				return
+			}
+		}
+	}
+}
+
 // Neighbors returns the neighborhood of the node (metadata and iterators factory)
 func (am *MapGraph[N, L]) Neighbors(node N) (graphs.Neighborhood[N, L], error) {
 	index, found := am.nodes.getValue(node)
@@ -191,6 +255,67 @@ func ToMatrix[N graphs.Node, L graphs.Link[N], S any](am *MapGraph[N, L], linksM
 	return arrayOfNodes, &result
 }
 
+// FromMatrix is the opposite of ToMatrix: it builds a graph from a matrix and its nodes.
+// N is nodes type
+// L is link type
+// S is type of the elements of the matrix
+// nodes[i] is the node for index i, nodes are distinct and len(nodes) should be the size of the matrix.
+// linksFactory returns the links from i to j for the value at position (i,j), possibly none (for instance for a zero).
+// All nodes are in the result, even with no link.
+// Undirected links appear twice in a symmetric matrix, they are added once.
+func FromMatrix[N graphs.Node, L graphs.Link[N], S any](
+	matrix graphs.Matrix[S], // matrix to read
+	nodes []N, // nodes by index in the matrix
+	linksFactory func(source, destination N, value S) ([]L, error), // links from source to destination for value
+) (*MapGraph[N, L], error) {
+	if matrix == nil || linksFactory == nil {
+		return nil, errors.New("nil matrix or factory")
+	} else if matrix.Size() != len(nodes) {
+		return nil, errors.New("nodes size differs from matrix size")
+	}
+
+	loader := NewMapGraphLoader[N, L]()
+	for _, node := range nodes {
+		loader.AppendNode(node)
+	}
+
+	for i, source := range nodes {
+		line, errLine := matrix.Line(i)
+		if errLine != nil {
+			return nil, errLine
+		} else if line == nil {
+			continue
+		}
+
+		j := 0
+		for has, errNext := line.Next(); has; has, errNext = line.Next() {
+			if errNext != nil {
+				return nil, errNext
+			}
+
+			value, errValue := line.Value()
+			if errValue != nil {
+				return nil, errValue
+			}
+
+			links, errLinks := linksFactory(source, nodes[j], value)
+			if errLinks != nil {
+				return nil, errLinks
+			}
+
+			for _, link := range links {
+				if err := loader.AppendLink(i, j, link); err != nil {
+					return nil, err
+				}
+			}
+
+			j++
+		}
+	}
+
+	return loader.Graph(), nil
+}
+
 // GenerateCompleteUndirectedGraph returns a complete undirected graph with nodesSize nodes
 func GenerateCompleteUndirectedGraph[N graphs.Node, L graphs.Link[N]](
 	nodesSize int, // number of nodes in the result
@@ -238,10 +363,13 @@ func GenerateCompleteUndirectedGraph[N graphs.Node, L graphs.Link[N]](
 // setLink adds a link at a given index
 func (am *MapGraph[N, L]) setLink(sourceIndex, destIndex int, link L) {
 	mapValue := am.content[sourceIndex]
-	mapValue.addLink(destIndex, link)
+	added := mapValue.addLink(destIndex, link)
 	am.content[sourceIndex] = mapValue
 
-	if !link.IsDirected() {
+	// link was already there: counters and the other side are up to date
+	if !added {
+		return
+	} else if !link.IsDirected() {
 		mapValue = am.content[destIndex]
 		mapValue.addLink(sourceIndex, link)
 		am.content[destIndex] = mapValue
@@ -292,8 +420,8 @@ func (a *mapLine[N, L]) removeNode(nodeIndex int) {
 
 	delete(a.values, nodeIndex)
 	// source is not nodeIndex, and we found all links such as destination is nodeIndex.
-	// So, decrease the incoming counter from the source point of view
-	a.incomingCounter = a.incomingCounter - countDirected
+	// So, decrease the outgoing counter from the source point of view
+	a.outgoingCounter = a.outgoingCounter - countDirected
 	a.undirectedCounter = a.undirectedCounter - countUndirected
 }
 
@@ -2,6 +2,7 @@ package local
 
 import (
 	"errors"
+	"iter"
 
 	"github.com/zefrenchwan/nodz.git/graphs"
 )
@@ -89,6 +90,31 @@ func (sm *MapMatrix[V]) Line(i int) (graphs.GeneralIterator[V], error) {
 	return &it, errIt
 }
 
+// All returns the lines of the matrix, by increasing index.
+// Each line is a new slice, with default value for unset positions
+func (sm *MapMatrix[V]) All() iter.Seq2[int, []V] {
+	return func(yield func(int, []V) bool) {
+		if sm == nil {
+			return
+		}
+
+		for i := 0; i < sm.size; i++ {
+			line := make([]V, sm.size)
+			for j := range line {
+				line[j] = sm.defaultValue
+			}
+
+			for j, value := range sm.values.getElement(i) {
+				line[j] = value
+			}
+
+			if !yield(i, line) {
+				return
+			}
+		}
+	}
+}
+
 // Column returns column j as an iterator
 func (sm *MapMatrix[V]) Column(j int) (graphs.GeneralIterator[V], error) {
 	if sm == nil {
@@ -0,0 +1,190 @@
+package local
+
+import (
+	"errors"
+
+	"github.com/zefrenchwan/nodz.git/graphs"
+)
+
+// Begin opens a transaction on the graph.
+// Changes are applied at once, and an undo log keeps what is needed to rollback.
+// Graph should not be changed outside of the transaction until it is closed.
+func (am *MapGraph[N, L]) Begin() (graphs.Transaction[N, L], error) {
+	if am.transaction != nil {
+		return nil, errors.New("transaction already open")
+	}
+
+	am.transaction = &mapTransaction[N, L]{graph: am}
+	return am.transaction, nil
+}
+
+// Apply validates the batch, and then applies all its mutations in a transaction.
+// Graph is not changed if batch is invalid or if a mutation fails
+func (am *MapGraph[N, L]) Apply(batch graphs.Batch[N, L]) error {
+	if err := batch.Validate(); err != nil {
+		return err
+	}
+
+	transaction, errBegin := am.Begin()
+	if errBegin != nil {
+		return errBegin
+	}
+
+	for _, mutation := range batch.Mutations {
+		var err error
+		switch mutation.Type {
+		case graphs.AddNodeMutation:
+			err = transaction.AddNode(mutation.Node)
+		case graphs.RemoveNodeMutation:
+			err = transaction.RemoveNode(mutation.Node)
+		case graphs.AddLinkMutation:
+			err = transaction.AddLink(mutation.Link)
+		case graphs.RemoveLinkMutation:
+			err = transaction.RemoveLink(mutation.Link)
+		}
+
+		if err != nil {
+			return errors.Join(err, transaction.Rollback())
+		}
+	}
+
+	return transaction.Commit()
+}
+
+// mapTransaction is the transaction of a map graph.
+// Each change adds its opposite in an undo log, rollback applies the undo log backwards.
+type mapTransaction[N graphs.Node, L graphs.Link[N]] struct {
+	// graph to change, nil once transaction is closed
+	graph *MapGraph[N, L]
+	// undo contains the changes to undo each change, in the order of the changes
+	undo []func()
+}
+
+// AddNode adds a node if not in the graph
+func (mt *mapTransaction[N, L]) AddNode(node N) error {
+	if mt.graph == nil {
+		return graphs.ErrTransactionClosed
+	} else if err := (graphs.Mutation[N, L]{Type: graphs.AddNodeMutation, Node: node}).Validate(); err != nil {
+		return err
+	} else if mt.graph.nodes.hasValue(node) != -1 {
+		return nil
+	}
+
+	mt.graph.AddNode(node)
+	mt.undo = append(mt.undo, func() { mt.graph.RemoveNode(node) })
+	return nil
+}
+
+// RemoveNode removes a node and its links, and keeps them to restore them
+func (mt *mapTransaction[N, L]) RemoveNode(node N) error {
+	if mt.graph == nil {
+		return graphs.ErrTransactionClosed
+	} else if err := (graphs.Mutation[N, L]{Type: graphs.RemoveNodeMutation, Node: node}).Validate(); err != nil {
+		return err
+	}
+
+	index, found := mt.graph.nodes.getValue(node)
+	if !found {
+		return nil
+	}
+
+	// keep the instance in the graph, its outgoing and undirected links, and its incoming directed links
+	stored := mt.graph.nodes.values[index]
+	links := make([]L, 0)
+	for _, current := range mt.graph.content[index].values {
+		links = append(links, current...)
+	}
+
+	for otherIndex, line := range mt.graph.content {
+		if otherIndex == index {
+			continue
+		}
+
+		for _, current := range line.values[index] {
+			if current.IsDirected() {
+				links = append(links, current)
+			}
+		}
+	}
+
+	mt.graph.RemoveNode(node)
+	mt.undo = append(mt.undo, func() {
+		mt.graph.AddNode(stored)
+		for _, link := range links {
+			mt.graph.AddLink(link)
+		}
+	})
+
+	return nil
+}
+
+// AddLink adds a link, and keeps track of its extremities that were not in the graph
+func (mt *mapTransaction[N, L]) AddLink(link L) error {
+	if mt.graph == nil {
+		return graphs.ErrTransactionClosed
+	} else if err := (graphs.Mutation[N, L]{Type: graphs.AddLinkMutation, Link: link}).Validate(); err != nil {
+		return err
+	} else if _, found, err := graphs.FindLink(mt.graph, link); err != nil || found {
+		return err
+	}
+
+	// nodes implicitly added by the link are removed on rollback, after the link
+	for _, node := range []N{link.Source(), link.Destination()} {
+		if mt.graph.nodes.hasValue(node) == -1 {
+			mt.undo = append(mt.undo, func() { mt.graph.RemoveNode(node) })
+		}
+	}
+
+	mt.graph.AddLink(link)
+	mt.undo = append(mt.undo, func() { mt.graph.RemoveLink(link) })
+	return nil
+}
+
+// RemoveLink removes a link, and keeps its instance to restore it
+func (mt *mapTransaction[N, L]) RemoveLink(link L) error {
+	if mt.graph == nil {
+		return graphs.ErrTransactionClosed
+	} else if err := (graphs.Mutation[N, L]{Type: graphs.RemoveLinkMutation, Link: link}).Validate(); err != nil {
+		return err
+	}
+
+	stored, found, errFind := graphs.FindLink(mt.graph, link)
+	if errFind != nil || !found {
+		return errFind
+	}
+
+	mt.graph.RemoveLink(link)
+	mt.undo = append(mt.undo, func() { mt.graph.AddLink(stored) })
+	return nil
+}
+
+// Commit keeps changes and closes the transaction
+func (mt *mapTransaction[N, L]) Commit() error {
+	if mt.graph == nil {
+		return graphs.ErrTransactionClosed
+	}
+
+	mt.close()
+	return nil
+}
+
+// Rollback undoes changes, last change first, and closes the transaction
+func (mt *mapTransaction[N, L]) Rollback() error {
+	if mt.graph == nil {
+		return graphs.ErrTransactionClosed
+	}
+
+	for index := len(mt.undo) - 1; index >= 0; index-- {
+		mt.undo[index]()
+	}
+
+	mt.close()
+	return nil
+}
+
+// close releases the graph for other transactions
+func (mt *mapTransaction[N, L]) close() {
+	mt.graph.transaction = nil
+	mt.graph = nil
+	mt.undo = nil
+}
@@ -1,6 +1,7 @@
 package local
 
 import (
+	"context"
 	"errors"
 	"math/rand"
 
@@ -10,7 +11,9 @@ import (
 // RandomGenerator generates random graphs.
 // It is a struct in case you want to embed your own random generator.
 type RandomGenerator[N graphs.Node, L graphs.Link[N]] struct {
-	// empty struct so far, using default golang random numbers generator.
+	// Random is the source of random values, nil to use default golang random numbers generator.
+	// Set it (for instance with rand.New(rand.NewSource(seed))) to get reproducible graphs
+	Random *rand.Rand
 }
 
 // DirectedGNP returns a directed GNP graph
@@ -23,7 +26,21 @@ func (rm RandomGenerator[N, L]) DirectedGNP(
 	graphs.CentralStructureGraph[N, L], // random graph
 	error, // error during build
 ) {
-	return rm.gnp(size, probability, true, nodeGenerator, linkGenerator)
+	return rm.gnp(context.Background(), size, probability, true, nodeGenerator, linkGenerator)
+}
+
+// DirectedGNPContext is DirectedGNP, but it stops as soon as ctx is done, and returns the partial graph and ctx.Err()
+func (rm RandomGenerator[N, L]) DirectedGNPContext(
+	ctx context.Context, // to cancel the generation
+	size int, // number of nodes
+	probability float64, // linking probability
+	nodeGenerator graphs.RandomNodeGenerator[N], // generates a new node at each call
+	linkGenerator graphs.RandomLinkGenerator[N, L], // generates a new link at each call
+) (
+	graphs.CentralStructureGraph[N, L], // random graph
+	error, // error during build
+) {
+	return rm.gnp(ctx, size, probability, true, nodeGenerator, linkGenerator)
 }
 
 // UndirectedGNP returns a undirected GNP graph
@@ -36,13 +53,28 @@ func (rm RandomGenerator[N, L]) UndirectedGNP(
 	graphs.CentralStructureGraph[N, L], // random graph
 	error, // error during build
 ) {
-	return rm.gnp(size, probability, false, nodeGenerator, linkGenerator)
+	return rm.gnp(context.Background(), size, probability, false, nodeGenerator, linkGenerator)
+}
+
+// UndirectedGNPContext is UndirectedGNP, but it stops as soon as ctx is done, and returns the partial graph and ctx.Err()
+func (rm RandomGenerator[N, L]) UndirectedGNPContext(
+	ctx context.Context, // to cancel the generation
+	size int, // number of nodes
+	probability float64, // linking probability
+	nodeGenerator graphs.RandomNodeGenerator[N], // generates a new node at each call
+	linkGenerator graphs.RandomLinkGenerator[N, L], // generates a new link at each call
+) (
+	graphs.CentralStructureGraph[N, L], // random graph
+	error, // error during build
+) {
+	return rm.gnp(ctx, size, probability, false, nodeGenerator, linkGenerator)
 }
 
 // gnp returns a random graph of size n and with a probability of p to create links.
 // For directed, we test each couple (source, destination).
 // For undirected, we test EITHER (source, destination) OR (destination, source), never both
 func (rm RandomGenerator[N, L]) gnp(
+	ctx context.Context, // to cancel the generation
 	size int, // number of nodes
 	probability float64, // probability to create a link
 	directedAlgorithm bool, // true for directed, false for undirected
@@ -76,6 +108,10 @@ func (rm RandomGenerator[N, L]) gnp(
 	}
 
 	for i, source := range nodes {
+		if err := ctx.Err(); err != nil {
+			return &result, err
+		}
+
 		for j, dest := range nodes {
 			// exclude links having source == destination
 			if i == j || (!directedAlgorithm && i > j) {
@@ -108,6 +144,20 @@ func (rm RandomGenerator[N, L]) UndirectedBarabasiAlbertGraph(
 	linkGenerator graphs.RandomLinkGenerator[N, L], // generate random undirected links
 ) (graphs.CentralStructureGraph[N, L], // result
 	error, // error if parameters make no sense or linkGenerator makes directed links
+) {
+	return rm.UndirectedBarabasiAlbertGraphContext(context.Background(), initialSize, maxSize, nodeGenerator, linkGenerator)
+}
+
+// UndirectedBarabasiAlbertGraphContext is UndirectedBarabasiAlbertGraph, but it stops as soon as ctx is done.
+// Then, it returns the graph built so far and ctx.Err()
+func (rm RandomGenerator[N, L]) UndirectedBarabasiAlbertGraphContext(
+	ctx context.Context, // to cancel the generation
+	initialSize int, // initial number of nodes for complete base
+	maxSize int, // total number of nodes
+	nodeGenerator graphs.RandomNodeGenerator[N], // generates random nodes
+	linkGenerator graphs.RandomLinkGenerator[N, L], // generate random undirected links
+) (graphs.CentralStructureGraph[N, L], // result
+	error, // error if parameters make no sense or linkGenerator makes directed links
 ) {
 	if initialSize <= 0 || maxSize <= 0 || initialSize > maxSize {
 		return nil, errors.New("invalid size")
@@ -141,6 +191,10 @@ func (rm RandomGenerator[N, L]) UndirectedBarabasiAlbertGraph(
 
 	// initial graph is complete, add links one by one
 	for index := initialSize; index < maxSize; index++ {
+		if err := ctx.Err(); err != nil {
+			return &result, err
+		}
+
 		newNode := nodeGenerator()
 		// not necessary, but doing it allows to get its index without a full rescan
 		newNodeIndex := result.nodes.addValue(newNode)
@@ -152,8 +206,14 @@ func (rm RandomGenerator[N, L]) UndirectedBarabasiAlbertGraph(
 		// When reached, the corresponding node is the destination node.
 		// randomValue is between 0 included and sumDegrees excluded
 		randomValue := rm.nextInt64(sumDegrees - 1)
+		// nodes are walked in index order, so that a given random source always builds the same graph
 		var sum int64
-		for nodeIndex, degree := range degrees {
+		for nodeIndex := 0; nodeIndex < newNodeIndex; nodeIndex++ {
+			degree, found := degrees[nodeIndex]
+			if !found {
+				continue
+			}
+
 			sum += degree
 			if randomValue < sum {
 				destIndex = nodeIndex
@@ -176,16 +236,33 @@ func (rm RandomGenerator[N, L]) UndirectedBarabasiAlbertGraph(
 }
 
 // nextFloat returns a random float between 0.0 and 1.0.
-// This is Golang default implementation.
+// This is Golang default implementation, unless Random is set.
 func (rm RandomGenerator[N, L]) nextFloat() float64 {
+	if rm.Random != nil {
+		return rm.Random.Float64()
+	}
+
 	return rand.Float64()
 }
 
 // nextInt64 returns a new random positive int64 from 0 to max included
 func (rm RandomGenerator[N, L]) nextInt64(max int64) int64 {
+	if rm.Random != nil {
+		return rm.Random.Int63() % (max + 1)
+	}
+
 	return rand.Int63() % (max + 1)
 }
 
+// nextInt returns a new random int from 0 to max excluded
+func (rm RandomGenerator[N, L]) nextInt(max int) int {
+	if rm.Random != nil {
+		return rm.Random.Intn(max)
+	}
+
+	return rand.Intn(max)
+}
+
 // generateDistinctValues returns a slice of size size, with values from 0 to max (included), all different.
 // Of course, it makes no sens if size > max, in case we return nil.
 // About the algorithm, it is not that simple : worst idea is to make random values until we have size different ones.
@@ -199,7 +276,7 @@ func (rm RandomGenerator[N, L]) generateDistinctValues(size, max int) []int {
 	values := make(map[int]bool)
 	count := max + 1
 	for i := count - size; i < count; i++ {
-		newValue := rand.Intn(i + 1)
+		newValue := rm.nextInt(i + 1)
 		if values[newValue] {
 			values[i] = true
 		} else {
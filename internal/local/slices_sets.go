@@ -2,6 +2,7 @@ package local
 
 import (
 	"errors"
+	"iter"
 	"slices"
 
 	"github.com/zefrenchwan/nodz.git/graphs"
@@ -39,6 +40,21 @@ func (s *SlicesSet[T]) ToIterator() (graphs.GeneralIterator[T], error) {
 	return &result, nil
 }
 
+// All returns the elements of the set
+func (s *SlicesSet[T]) All() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		if s == nil {
+			return
+		}
+
+		for _, element := range s.elements {
+			if !yield(element) {
+				return
+			}
+		}
+	}
+}
+
 // IsEmpty returns true for nil or empty set, false otherwise
 func (s *SlicesSet[T]) IsEmpty() (bool, error) {
 	return s == nil || len(s.elements) == 0, nil
@@ -0,0 +1,148 @@
+package local
+
+import (
+	"iter"
+	"slices"
+	"sync"
+
+	"github.com/zefrenchwan/nodz.git/graphs"
+	"github.com/zefrenchwan/nodz.git/internal"
+)
+
+// SyncGraph is a map graph safe for concurrent readers and writers.
+// Changes take a write lock, reads take a read lock.
+// Iterators (nodes, neighbors, links) work on a copy made under the lock,
+// so they are consistent snapshots and never see later changes.
+// Nodes and links themselves are shared: changing their content (properties, for instance) is not synchronized.
+type SyncGraph[N graphs.Node, L graphs.Link[N]] struct {
+	// lock protects graph
+	lock sync.RWMutex
+	// graph is the decorated map graph
+	graph MapGraph[N, L]
+}
+
+// NewSyncGraph returns a new empty concurrency safe graph
+func NewSyncGraph[N graphs.Node, L graphs.Link[N]]() *SyncGraph[N, L] {
+	return &SyncGraph[N, L]{graph: NewMapGraph[N, L]()}
+}
+
+// AddNode adds a node if it did not exist, does nothing otherwise
+func (sg *SyncGraph[N, L]) AddNode(node N) error {
+	sg.lock.Lock()
+	defer sg.lock.Unlock()
+	return sg.graph.AddNode(node)
+}
+
+// RemoveNode removes a node and all its links
+func (sg *SyncGraph[N, L]) RemoveNode(node N) error {
+	sg.lock.Lock()
+	defer sg.lock.Unlock()
+	return sg.graph.RemoveNode(node)
+}
+
+// AddLink adds a link (may be directed or undirected) if not already here
+func (sg *SyncGraph[N, L]) AddLink(link L) error {
+	sg.lock.Lock()
+	defer sg.lock.Unlock()
+	return sg.graph.AddLink(link)
+}
+
+// RemoveLink removes a link if any, does nothing otherwise
+func (sg *SyncGraph[N, L]) RemoveLink(link L) error {
+	sg.lock.Lock()
+	defer sg.lock.Unlock()
+	return sg.graph.RemoveLink(link)
+}
+
+// Apply applies a batch atomically (see MapGraph.Apply).
+// Readers see the graph before or after the batch, never in between
+func (sg *SyncGraph[N, L]) Apply(batch graphs.Batch[N, L]) error {
+	sg.lock.Lock()
+	defer sg.lock.Unlock()
+	return sg.graph.Apply(batch)
+}
+
+// HasLink returns true if link is in the graph
+func (sg *SyncGraph[N, L]) HasLink(link L) bool {
+	sg.lock.RLock()
+	defer sg.lock.RUnlock()
+	return sg.graph.HasLink(link)
+}
+
+// AllNodes returns an iterator over a snapshot of the nodes
+func (sg *SyncGraph[N, L]) AllNodes() (graphs.NodesIterator[N], error) {
+	sg.lock.RLock()
+	defer sg.lock.RUnlock()
+	return sg.graph.AllNodes()
+}
+
+// All returns a snapshot of the nodes
+func (sg *SyncGraph[N, L]) All() iter.Seq[N] {
+	sg.lock.RLock()
+	nodes := slices.Collect(sg.graph.All())
+	sg.lock.RUnlock()
+	return slices.Values(nodes)
+}
+
+// Links returns a snapshot of the links, each link once
+func (sg *SyncGraph[N, L]) Links() iter.Seq[L] {
+	sg.lock.RLock()
+	links := slices.Collect(sg.graph.Links())
+	sg.lock.RUnlock()
+	return slices.Values(links)
+}
+
+// Neighbors returns a snapshot of the neighborhood of the node, nil if node is not in the graph
+func (sg *SyncGraph[N, L]) Neighbors(node N) (graphs.Neighborhood[N, L], error) {
+	sg.lock.RLock()
+	defer sg.lock.RUnlock()
+
+	index, found := sg.graph.nodes.getValue(node)
+	if !found {
+		return nil, nil
+	}
+
+	line := sg.graph.content[index]
+	links := make([]L, 0)
+	for _, current := range line.values {
+		links = append(links, current...)
+	}
+
+	result := internal.NeighborsIterator[N, L]{
+		CurrentNode:       node,
+		IncomingCounter:   line.incomingCounter,
+		OutgoingCounter:   line.outgoingCounter,
+		UndirectedCounter: line.undirectedCounter,
+	}
+
+	result.IteratorsFactory = func() graphs.LinksIterator[N, L] {
+		it := NewSlicesIterator(links)
+		return &it
+	}
+
+	return result, nil
+}
+
+// Snapshot returns a copy of the graph, as a map graph that the caller owns.
+// Use it for long computations on a consistent state, without blocking writers
+func (sg *SyncGraph[N, L]) Snapshot() *MapGraph[N, L] {
+	sg.lock.RLock()
+	defer sg.lock.RUnlock()
+
+	loader := NewMapGraphLoader[N, L]()
+	indexes := make(map[int]int, len(sg.graph.nodes.values))
+	for index, node := range sg.graph.nodes.values {
+		indexes[index] = loader.AppendNode(node)
+	}
+
+	for sourceIndex, line := range sg.graph.content {
+		for destIndex, links := range line.values {
+			for _, link := range links {
+				// undirected links appear on both lines, loader adds them once
+				loader.AppendLink(indexes[sourceIndex], indexes[destIndex], link)
+			}
+		}
+	}
+
+	return loader.Graph()
+}
@@ -0,0 +1,478 @@
+package internal
+
+import (
+	"errors"
+	"slices"
+	"sync"
+
+	"github.com/zefrenchwan/nodz.git/graphs"
+)
+
+// ObservableGraph decorates a central structure graph and publishes its changes to observers.
+// Changes go to the decorated graph, so decorated graph should not be changed directly.
+// Observers list is safe for concurrent use. Changes are serialized, so that concurrent changes publish each event once.
+// Reads go to the decorated graph with no lock: they are as safe as the decorated graph.
+// Buffered observers receive events in sequence order.
+// Synchronous handlers run once the event is published, so concurrent changes may reach them out of order.
+// A buffered handler should not change the graph, nor unsubscribe itself: a full buffer would block it.
+type ObservableGraph[N graphs.Node, L graphs.Link[N]] struct {
+	// graph is the decorated graph
+	graph graphs.CentralStructureGraph[N, L]
+	// mutating serializes changes, with sequence assignment and delivery to buffered observers
+	mutating sync.Mutex
+	// lock protects observers and sequence
+	lock sync.RWMutex
+	// observers are the registered observers, in registration order
+	observers []*graphObserver[N, L]
+	// lastId is the id of the last registered observer
+	lastId int
+	// sequence is the number of the last event
+	sequence uint64
+}
+
+// graphObserver is a registered handler
+type graphObserver[N graphs.Node, L graphs.Link[N]] struct {
+	// id of the observer, to unsubscribe
+	id int
+	// handler processes events
+	handler graphs.EventHandler[N, L]
+	// filter accepts events, nil for all
+	filter func(N) bool
+	// events is nil for synchronous observers, the buffer otherwise
+	events chan graphs.GraphEvent[N, L]
+	// stopping is closed when observer is unsubscribed
+	stopping chan struct{}
+	// stopOnce closes stopping once
+	stopOnce sync.Once
+	// done is closed once all buffered events were delivered
+	done chan struct{}
+}
+
+// NewObservableGraph decorates g
+func NewObservableGraph[N graphs.Node, L graphs.Link[N]](g graphs.CentralStructureGraph[N, L]) *ObservableGraph[N, L] {
+	return &ObservableGraph[N, L]{graph: g}
+}
+
+// Subscribe registers a handler and returns its id
+func (og *ObservableGraph[N, L]) Subscribe(handler graphs.EventHandler[N, L], options graphs.ObserverOptions[N]) (int, error) {
+	if og == nil {
+		return 0, errors.New("nil graph")
+	} else if handler == nil {
+		return 0, errors.New("nil handler")
+	} else if options.BufferSize < 0 {
+		return 0, errors.New("negative buffer size")
+	}
+
+	observer := &graphObserver[N, L]{handler: handler, filter: options.Filter}
+	if options.BufferSize > 0 {
+		observer.events = make(chan graphs.GraphEvent[N, L], options.BufferSize)
+		observer.stopping = make(chan struct{})
+		observer.done = make(chan struct{})
+		go observer.deliver()
+	}
+
+	og.lock.Lock()
+	defer og.lock.Unlock()
+	og.lastId++
+	observer.id = og.lastId
+	og.observers = append(og.observers, observer)
+	return observer.id, nil
+}
+
+// Unsubscribe removes an observer, once its buffered events are delivered
+func (og *ObservableGraph[N, L]) Unsubscribe(id int) error {
+	if og == nil {
+		return errors.New("nil graph")
+	}
+
+	og.lock.Lock()
+	var removed *graphObserver[N, L]
+	for index, observer := range og.observers {
+		if observer.id == id {
+			removed = observer
+			og.observers = append(og.observers[:index:index], og.observers[index+1:]...)
+			break
+		}
+	}
+
+	og.lock.Unlock()
+
+	if removed == nil {
+		return errors.New("no observer for id")
+	}
+
+	removed.stop()
+	return nil
+}
+
+// Close unsubscribes all observers, once their buffered events are delivered
+func (og *ObservableGraph[N, L]) Close() error {
+	if og == nil {
+		return nil
+	}
+
+	og.lock.Lock()
+	observers := og.observers
+	og.observers = nil
+	og.lock.Unlock()
+
+	for _, observer := range observers {
+		observer.stop()
+	}
+
+	return nil
+}
+
+// deliver calls the handler of an asynchronous observer for its buffered events, until it stops.
+// Once stopping, events still in the buffer are delivered
+func (o *graphObserver[N, L]) deliver() {
+	defer close(o.done)
+	for {
+		select {
+		case event := <-o.events:
+			o.handler(event)
+		case <-o.stopping:
+			for {
+				select {
+				case event := <-o.events:
+					o.handler(event)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// send buffers an event for an asynchronous observer, unless it stops meanwhile
+func (o *graphObserver[N, L]) send(event graphs.GraphEvent[N, L]) {
+	select {
+	case o.events <- event:
+	case <-o.stopping:
+	}
+}
+
+// stop stops an asynchronous observer, and waits for the delivery of its buffered events
+func (o *graphObserver[N, L]) stop() {
+	if o.events != nil {
+		o.stopOnce.Do(func() { close(o.stopping) })
+		<-o.done
+	}
+}
+
+// change applies a change to the decorated graph and publishes its events, under mutating lock.
+// So, concurrent changes do not see the same graph state, and each event is published once.
+// Synchronous handlers run once the lock is released, so that they may change the graph
+func (og *ObservableGraph[N, L]) change(apply func() ([]graphs.GraphEvent[N, L], error)) error {
+	og.mutating.Lock()
+	events, err := apply()
+	calls := og.publish(events)
+	og.mutating.Unlock()
+
+	for _, call := range calls {
+		call()
+	}
+
+	return err
+}
+
+// publish assigns sequences to events and sends them to matching buffered observers, caller holds mutating lock.
+// Observers are copied under lock, and events are sent with no lock on observers, so that handlers may subscribe or unsubscribe.
+// It returns the calls of matching synchronous handlers, to run once mutating lock is released
+func (og *ObservableGraph[N, L]) publish(events []graphs.GraphEvent[N, L]) []func() {
+	calls := make([]func(), 0)
+	for _, event := range events {
+		og.lock.Lock()
+		og.sequence++
+		event.Sequence = og.sequence
+		observers := slices.Clone(og.observers)
+		og.lock.Unlock()
+
+		for _, observer := range observers {
+			if !event.Matches(observer.filter) {
+				continue
+			} else if observer.events == nil {
+				calls = append(calls, func() { observer.handler(event) })
+			} else {
+				observer.send(event)
+			}
+		}
+	}
+
+	return calls
+}
+
+// Neighbors returns the neighborhood of a node in the decorated graph
+func (og *ObservableGraph[N, L]) Neighbors(node N) (graphs.Neighborhood[N, L], error) {
+	return og.graph.Neighbors(node)
+}
+
+// AllNodes returns the nodes of the decorated graph
+func (og *ObservableGraph[N, L]) AllNodes() (graphs.NodesIterator[N], error) {
+	return og.graph.AllNodes()
+}
+
+// AddNode adds a node, and raises NodeAdded if it was not in the graph
+func (og *ObservableGraph[N, L]) AddNode(node N) error {
+	return og.change(func() ([]graphs.GraphEvent[N, L], error) {
+		existed, errExists := graphs.HasNode(og.graph, node)
+		if errExists != nil {
+			return nil, errExists
+		} else if err := og.graph.AddNode(node); err != nil || existed {
+			return nil, err
+		}
+
+		return []graphs.GraphEvent[N, L]{{Type: graphs.NodeAdded, Node: node}}, nil
+	})
+}
+
+// RemoveNode removes a node and its links, and raises NodeRemoved with removed links
+func (og *ObservableGraph[N, L]) RemoveNode(node N) error {
+	return og.change(func() ([]graphs.GraphEvent[N, L], error) {
+		neighbors, errNeighbors := og.graph.Neighbors(node)
+		if errNeighbors != nil {
+			return nil, errNeighbors
+		} else if neighbors == nil {
+			// not in the graph, nothing to remove
+			return nil, og.graph.RemoveNode(node)
+		}
+
+		cascaded, errLinks := og.nodeLinks(node, neighbors)
+		if errLinks != nil {
+			return nil, errLinks
+		} else if err := og.graph.RemoveNode(node); err != nil {
+			return nil, err
+		}
+
+		return []graphs.GraphEvent[N, L]{{Type: graphs.NodeRemoved, Node: node, CascadedLinks: cascaded}}, nil
+	})
+}
+
+// AddLink adds a link and raises NodeAdded for new extremities, then LinkAdded if link is new
+func (og *ObservableGraph[N, L]) AddLink(link L) error {
+	return og.change(func() ([]graphs.GraphEvent[N, L], error) {
+		sourceExisted, errSource := graphs.HasNode(og.graph, link.Source())
+		if errSource != nil {
+			return nil, errSource
+		}
+
+		destinationExisted, errDestination := graphs.HasNode(og.graph, link.Destination())
+		if errDestination != nil {
+			return nil, errDestination
+		}
+
+		existed := false
+		if sourceExisted && destinationExisted {
+			if _, found, err := graphs.FindLink(og.graph, link); err != nil {
+				return nil, err
+			} else {
+				existed = found
+			}
+		}
+
+		if err := og.graph.AddLink(link); err != nil {
+			return nil, err
+		}
+
+		events := make([]graphs.GraphEvent[N, L], 0)
+		if !sourceExisted {
+			events = append(events, graphs.GraphEvent[N, L]{Type: graphs.NodeAdded, Node: link.Source()})
+		}
+
+		if !destinationExisted && !link.Source().SameNode(link.Destination()) {
+			events = append(events, graphs.GraphEvent[N, L]{Type: graphs.NodeAdded, Node: link.Destination()})
+		}
+
+		if !existed {
+			events = append(events, graphs.GraphEvent[N, L]{Type: graphs.LinkAdded, Link: link})
+		}
+
+		return events, nil
+	})
+}
+
+// RemoveLink removes a link, and raises LinkRemoved if it was in the graph
+func (og *ObservableGraph[N, L]) RemoveLink(link L) error {
+	return og.change(func() ([]graphs.GraphEvent[N, L], error) {
+		existing, found, errFind := graphs.FindLink(og.graph, link)
+		if errFind != nil {
+			return nil, errFind
+		} else if err := og.graph.RemoveLink(link); err != nil || !found {
+			return nil, err
+		}
+
+		return []graphs.GraphEvent[N, L]{{Type: graphs.LinkRemoved, Link: existing}}, nil
+	})
+}
+
+// SetNodeProperty sets a property of node, that should be in the graph and implement WithProperties.
+// Property is set on the node instance of the graph. It raises PropertyChanged if value changed
+func (og *ObservableGraph[N, L]) SetNodeProperty(node N, key, value string) error {
+	return og.change(func() ([]graphs.GraphEvent[N, L], error) {
+		existing, properties, errProperties := og.nodeProperties(node)
+		if errProperties != nil {
+			return nil, errProperties
+		}
+
+		old, had := properties.GetProperty(key)
+		properties.SetProperty(key, value)
+		if had && old == value {
+			return nil, nil
+		}
+
+		return []graphs.GraphEvent[N, L]{{Type: graphs.PropertyChanged, Node: existing, Key: key, OldValue: old, NewValue: value}}, nil
+	})
+}
+
+// RemoveNodeProperty removes a property of node, that should be in the graph and implement WithProperties.
+// Property is removed from the node instance of the graph. It raises PropertyChanged if property existed
+func (og *ObservableGraph[N, L]) RemoveNodeProperty(node N, key string) error {
+	return og.change(func() ([]graphs.GraphEvent[N, L], error) {
+		existing, properties, errProperties := og.nodeProperties(node)
+		if errProperties != nil {
+			return nil, errProperties
+		}
+
+		old, had := properties.GetProperty(key)
+		if !had {
+			return nil, nil
+		}
+
+		properties.RemoveProperty(key)
+		return []graphs.GraphEvent[N, L]{{Type: graphs.PropertyChanged, Node: existing, Key: key, OldValue: old, Removed: true}}, nil
+	})
+}
+
+// SetLinkProperty sets a property of a link in the graph, that should implement WithProperties.
+// Property is set on the link instance of the graph. It raises PropertyChanged if value changed
+func (og *ObservableGraph[N, L]) SetLinkProperty(link L, key, value string) error {
+	return og.change(func() ([]graphs.GraphEvent[N, L], error) {
+		existing, properties, errProperties := og.linkProperties(link)
+		if errProperties != nil {
+			return nil, errProperties
+		}
+
+		old, had := properties.GetProperty(key)
+		properties.SetProperty(key, value)
+		if had && old == value {
+			return nil, nil
+		}
+
+		return []graphs.GraphEvent[N, L]{{Type: graphs.PropertyChanged, Link: existing, OnLink: true, Key: key, OldValue: old, NewValue: value}}, nil
+	})
+}
+
+// RemoveLinkProperty removes a property of a link in the graph, that should implement WithProperties.
+// It raises PropertyChanged if property existed
+func (og *ObservableGraph[N, L]) RemoveLinkProperty(link L, key string) error {
+	return og.change(func() ([]graphs.GraphEvent[N, L], error) {
+		existing, properties, errProperties := og.linkProperties(link)
+		if errProperties != nil {
+			return nil, errProperties
+		}
+
+		old, had := properties.GetProperty(key)
+		if !had {
+			return nil, nil
+		}
+
+		properties.RemoveProperty(key)
+		return []graphs.GraphEvent[N, L]{{Type: graphs.PropertyChanged, Link: existing, OnLink: true, Key: key, OldValue: old, Removed: true}}, nil
+	})
+}
+
+// nodeLinks returns all the links of node: links from its neighborhood, and incoming directed links.
+// Incoming directed links are not part of the neighborhood, so they are found by scanning the graph if needed
+func (og *ObservableGraph[N, L]) nodeLinks(node N, neighbors graphs.Neighborhood[N, L]) ([]L, error) {
+	result := make([]L, 0)
+	if links, errLinks := neighbors.Links(); errLinks != nil {
+		return nil, errLinks
+	} else if links != nil {
+		for has, errNext := links.Next(); has; has, errNext = links.Next() {
+			if errNext != nil {
+				return nil, errNext
+			} else if link, errValue := links.Value(); errValue != nil {
+				return nil, errValue
+			} else {
+				result = append(result, link)
+			}
+		}
+	}
+
+	if neighbors.IncomingDegree() == 0 {
+		return result, nil
+	}
+
+	nodes, errNodes := og.graph.AllNodes()
+	if errNodes != nil {
+		return nil, errNodes
+	}
+
+	for has, errNext := nodes.Next(); has; has, errNext = nodes.Next() {
+		if errNext != nil {
+			return nil, errNext
+		}
+
+		other, errOther := nodes.Value()
+		if errOther != nil {
+			return nil, errOther
+		} else if other.SameNode(node) {
+			// loops are already in the neighborhood
+			continue
+		}
+
+		otherNeighbors, errNeighbors := og.graph.Neighbors(other)
+		if errNeighbors != nil {
+			return nil, errNeighbors
+		} else if otherNeighbors == nil || otherNeighbors.OutgoingDegree() == 0 {
+			continue
+		}
+
+		links, errLinks := otherNeighbors.Links()
+		if errLinks != nil {
+			return nil, errLinks
+		} else if links == nil {
+			continue
+		}
+
+		for hasLink, errLink := links.Next(); hasLink; hasLink, errLink = links.Next() {
+			if errLink != nil {
+				return nil, errLink
+			} else if link, errValue := links.Value(); errValue != nil {
+				return nil, errValue
+			} else if link.IsDirected() && link.Destination().SameNode(node) && link.Source().SameNode(other) {
+				result = append(result, link)
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// nodeProperties returns the instance of node in the graph, and its properties
+func (og *ObservableGraph[N, L]) nodeProperties(node N) (N, graphs.WithProperties, error) {
+	existing, found, errFind := graphs.FindNode(og.graph, node)
+	if errFind != nil {
+		return existing, nil, errFind
+	} else if !found {
+		return existing, nil, errors.New("node not in graph")
+	} else if properties, ok := any(existing).(graphs.WithProperties); !ok {
+		return existing, nil, errors.New("node has no property")
+	} else {
+		return existing, properties, nil
+	}
+}
+
+// linkProperties returns the instance of link in the graph, and its properties
+func (og *ObservableGraph[N, L]) linkProperties(link L) (L, graphs.WithProperties, error) {
+	existing, found, errFind := graphs.FindLink(og.graph, link)
+	if errFind != nil {
+		return existing, nil, errFind
+	} else if !found {
+		return existing, nil, errors.New("link not in graph")
+	} else if properties, ok := any(existing).(graphs.WithProperties); !ok {
+		return existing, nil, errors.New("link has no property")
+	} else {
+		return existing, properties, nil
+	}
+}
@@ -12,8 +12,13 @@ type PropertiesNode struct {
 
 // NewPropertiesNode returns an empty properies node, with an id
 func NewPropertiesNode() PropertiesNode {
+	return NewPropertiesNodeWithId(graphs.NewUniqueId())
+}
+
+// NewPropertiesNodeWithId returns an empty properties node with a given id (for instance, read from a file)
+func NewPropertiesNodeWithId(id string) PropertiesNode {
 	return PropertiesNode{
-		nodeId:         graphs.NewUniqueId(),
+		nodeId:         id,
 		nodeProperties: make(map[string]string),
 	}
 }
@@ -80,3 +80,8 @@ func (vl ValuedLink[N, V]) Destination() N {
 func (vl ValuedLink[N, V]) IsDirected() bool {
 	return vl.directed
 }
+
+// Value returns the value carried by the link
+func (vl ValuedLink[N, V]) Value() V {
+	return vl.value
+}
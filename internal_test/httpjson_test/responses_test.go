@@ -0,0 +1,39 @@
+package httpjson_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/zefrenchwan/nodz.git/internal/httpjson"
+)
+
+func TestWriteError(t *testing.T) {
+	wrapped := fmt.Errorf("lookup: %w", httpjson.NewStatusError(http.StatusNotFound, "no graph named %q", "g"))
+	recorder := httptest.NewRecorder()
+	httpjson.WriteError(recorder, wrapped)
+	if recorder.Code != http.StatusNotFound {
+		t.Errorf("expected status of the wrapped error, got %d", recorder.Code)
+	} else if body := recorder.Body.String(); body != `{"error":"lookup: no graph named \"g\"","status":404}`+"\n" {
+		t.Errorf("unexpected body %s", body)
+	} else if recorder.Header().Get("Content-Type") != "application/json" {
+		t.Error("expected a json body")
+	}
+
+	recorder = httptest.NewRecorder()
+	httpjson.WriteError(recorder, errors.New("failure"))
+	if recorder.Code != http.StatusInternalServerError {
+		t.Errorf("other errors are internal errors, got %d", recorder.Code)
+	}
+}
+
+func TestWriteJSONInvalidValue(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	if err := httpjson.WriteJSON(recorder, http.StatusOK, func() {}); err == nil {
+		t.Error("functions cannot be encoded")
+	} else if recorder.Body.Len() != 0 {
+		t.Error("nothing should be written on failure")
+	}
+}
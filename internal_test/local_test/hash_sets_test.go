@@ -0,0 +1,76 @@
+package local_test
+
+import (
+	"fmt"
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/zefrenchwan/nodz.git/internal"
+	"github.com/zefrenchwan/nodz.git/internal/local"
+	"github.com/zefrenchwan/nodz.git/internal_test"
+)
+
+func TestHashSetMatchesSlicesSet(t *testing.T) {
+	set := local.NewHashSet(func(value int) string { return fmt.Sprint(value) })
+	expected := local.NewSlicesSet(func(a, b int) bool { return a == b })
+	random := rand.New(rand.NewSource(11))
+	for range 5000 {
+		value := random.Intn(200)
+		if random.Intn(3) == 0 {
+			set.Remove(value)
+			expected.Remove(value)
+		} else {
+			set.Add(value)
+			expected.Add(value)
+		}
+
+		if has, err := set.Has(value); err != nil {
+			t.Fatal(err)
+		} else if want, _ := expected.Has(value); has != want {
+			t.Fatalf("%d: expected %t", value, want)
+		}
+	}
+
+	// same elements, in insertion order
+	if set.Size() != expected.Size() {
+		t.Fatalf("expected size %d, got %d", expected.Size(), set.Size())
+	} else if got, want := slices.Collect(set.All()), slices.Collect(expected.All()); !slices.Equal(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	} else if peeked, _ := set.Peek(); peeked != want[0] {
+		t.Errorf("peek should return the oldest element %d, got %d", want[0], peeked)
+	}
+
+	it, _ := set.ToIterator()
+	if res, err := internal_test.CompareIteratorWithSlice(it, slices.Collect(expected.All()), func(a, b int) bool { return a == b }, true); err != nil || !res {
+		t.Error("iterator differs from elements")
+	}
+
+	for empty, _ := set.IsEmpty(); !empty; empty, _ = set.IsEmpty() {
+		value, _ := set.Peek()
+		set.Remove(value)
+	}
+
+	if _, err := set.Peek(); err == nil {
+		t.Error("empty set should not peek")
+	}
+}
+
+func TestHashSetIds(t *testing.T) {
+	nodes := local.NewHashSet[internal.IdNode](nil)
+	nodes.Add(internal.NewIdNode("a"))
+	nodes.Add(internal.NewIdNode("a"))
+	if nodes.Size() != 1 {
+		t.Errorf("same id, same element, got size %d", nodes.Size())
+	}
+
+	values := local.NewHashSet[int](nil)
+	if err := values.Add(1); err == nil {
+		t.Error("elements without id need a key function")
+	}
+
+	var empty *local.HashSet[int]
+	if isEmpty, _ := empty.IsEmpty(); !isEmpty || empty.Size() != 0 {
+		t.Error("nil set is empty")
+	}
+}
@@ -524,3 +524,148 @@ func TestCompleteGraphGeneration(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestMapGraphFromMatrix(t *testing.T) {
+	a := internal.NewIdNode("a")
+	b := internal.NewIdNode("b")
+	c := internal.NewIdNode("c")
+	alone := internal.NewIdNode("alone")
+
+	matrix, _ := local.NewMapMatrix(4, 0)
+	// a -> b (2 links), symmetric b - c
+	matrix.SetValue(0, 1, 2)
+	matrix.SetValue(1, 2, 1)
+	matrix.SetValue(2, 1, 1)
+
+	factory := func(source, destination internal.IdNode, value int) ([]internal.ValuedLink[internal.IdNode, int], error) {
+		result := make([]internal.ValuedLink[internal.IdNode, int], 0)
+		if source.SameNode(a) {
+			for index := 0; index < value; index++ {
+				result = append(result, internal.NewDirectedValuedLink(source, destination, index))
+			}
+		} else if value != 0 {
+			result = append(result, internal.NewUndirectedValuedLink(source, destination, 0))
+		}
+
+		return result, nil
+	}
+
+	graph, err := local.FromMatrix(&matrix, []internal.IdNode{a, b, c, alone}, factory)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n, _ := graph.Neighbors(alone); n == nil {
+		t.Error("isolated node should be in graph")
+	}
+
+	if n, _ := graph.Neighbors(a); n.OutgoingDegree() != 2 {
+		t.Error("expecting two links from a")
+	}
+
+	if n, _ := graph.Neighbors(b); n.IncomingDegree() != 2 || n.UndirectedDegree() != 1 {
+		t.Error("undirected link should be added once")
+	}
+
+	// back to a matrix
+	counter := func(links []internal.ValuedLink[internal.IdNode, int]) int {
+		return len(links)
+	}
+
+	nodes, result := local.ToMatrix(graph, counter)
+	if len(nodes) != 4 || result.Size() != 4 {
+		t.Fatal("invalid size")
+	}
+
+	for i := range nodes {
+		for j := range nodes {
+			expected, _, _ := matrix.GetValue(i, j)
+			if value, _, _ := result.GetValue(i, j); value != expected {
+				t.Errorf("invalid value at %d, %d", i, j)
+			}
+		}
+	}
+
+	if _, err := local.FromMatrix(&matrix, []internal.IdNode{a}, factory); err == nil {
+		t.Error("nodes size should match matrix size")
+	}
+}
+
+func TestMapGraphRemoveNodeDegrees(t *testing.T) {
+	graph := local.NewMapGraph[internal.IdNode, internal.ValuedLink[internal.IdNode, int]]()
+	a := internal.NewIdNode("a")
+	b := internal.NewIdNode("b")
+	c := internal.NewIdNode("c")
+
+	graph.AddLink(internal.NewDirectedValuedLink(a, b, 1))
+	graph.AddLink(internal.NewDirectedValuedLink(b, c, 1))
+	graph.AddLink(internal.NewUndirectedValuedLink(a, b, 2))
+	graph.RemoveNode(b)
+
+	if n, _ := graph.Neighbors(a); n.OutgoingDegree() != 0 || n.UndirectedDegree() != 0 {
+		t.Error("links to removed node should not count for its source")
+	}
+
+	if n, _ := graph.Neighbors(c); n.IncomingDegree() != 0 {
+		t.Error("links from removed node should not count for its destination")
+	}
+}
+
+func TestMapGraphDuplicateDirectedLink(t *testing.T) {
+	graph := local.NewMapGraph[internal.IdNode, internal.ValuedLink[internal.IdNode, int]]()
+	a := internal.NewIdNode("a")
+	b := internal.NewIdNode("b")
+	ab := internal.NewDirectedValuedLink(a, b, 1)
+
+	graph.AddLink(ab)
+	graph.AddLink(ab)
+	if n, _ := graph.Neighbors(b); n.IncomingDegree() != 1 {
+		t.Errorf("same link added twice should count once, got %d", n.IncomingDegree())
+	}
+
+	graph.RemoveLink(ab)
+	if n, _ := graph.Neighbors(b); n.IncomingDegree() != 0 {
+		t.Errorf("removed link should not count, got %d", n.IncomingDegree())
+	}
+
+	// loader appends links the same way
+	loader := local.NewMapGraphLoader[internal.IdNode, internal.ValuedLink[internal.IdNode, int]]()
+	source, destination := loader.AppendNode(a), loader.AppendNode(b)
+	loader.AppendLink(source, destination, ab)
+	loader.AppendLink(source, destination, ab)
+	if n, _ := loader.Graph().Neighbors(b); n.IncomingDegree() != 1 {
+		t.Errorf("loader: same link added twice should count once, got %d", n.IncomingDegree())
+	}
+}
+
+func TestMapGraphRemoveNodeKeepsOtherDegrees(t *testing.T) {
+	graph := local.NewMapGraph[internal.IdNode, internal.ValuedLink[internal.IdNode, int]]()
+	a := internal.NewIdNode("a")
+	b := internal.NewIdNode("b")
+	c := internal.NewIdNode("c")
+	d := internal.NewIdNode("d")
+
+	// links of b, to remove with it
+	graph.AddLink(internal.NewDirectedValuedLink(a, b, 1))
+	graph.AddLink(internal.NewDirectedValuedLink(a, b, 2))
+	graph.AddLink(internal.NewDirectedValuedLink(b, c, 1))
+	graph.AddLink(internal.NewDirectedValuedLink(c, b, 1))
+	graph.AddLink(internal.NewDirectedValuedLink(b, b, 1))
+	graph.AddLink(internal.NewUndirectedValuedLink(b, d, 1))
+	// links to keep
+	graph.AddLink(internal.NewDirectedValuedLink(a, c, 1))
+	graph.AddLink(internal.NewUndirectedValuedLink(c, d, 1))
+	graph.RemoveNode(b)
+
+	if n, _ := graph.Neighbors(a); n.OutgoingDegree() != 1 || n.IncomingDegree() != 0 {
+		t.Errorf("a: unexpected degrees out %d, in %d", n.OutgoingDegree(), n.IncomingDegree())
+	}
+
+	if n, _ := graph.Neighbors(c); n.OutgoingDegree() != 0 || n.IncomingDegree() != 1 || n.UndirectedDegree() != 1 {
+		t.Errorf("c: unexpected degrees out %d, in %d, undirected %d", n.OutgoingDegree(), n.IncomingDegree(), n.UndirectedDegree())
+	}
+
+	if n, _ := graph.Neighbors(d); n.UndirectedDegree() != 1 {
+		t.Errorf("d: unexpected undirected degree %d", n.UndirectedDegree())
+	}
+}
@@ -0,0 +1,180 @@
+package local_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/zefrenchwan/nodz.git/graphs"
+	"github.com/zefrenchwan/nodz.git/internal"
+	"github.com/zefrenchwan/nodz.git/internal/local"
+	"github.com/zefrenchwan/nodz.git/internal_test"
+)
+
+type transactionNode = internal.IdNode
+type transactionLink = internal.ValuedLink[internal.IdNode, int]
+
+// checkNodes returns true if graph contains exactly those nodes
+func checkNodes(t *testing.T, graph *local.MapGraph[transactionNode, transactionLink], ids ...string) bool {
+	expected := make([]transactionNode, 0, len(ids))
+	for _, id := range ids {
+		expected = append(expected, internal.NewIdNode(id))
+	}
+
+	nodes, _ := graph.AllNodes()
+	same, err := internal_test.CompareIteratorWithSlice(nodes, expected, func(a, b transactionNode) bool { return a.SameNode(b) }, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return same
+}
+
+func TestMapGraphTransactionRollback(t *testing.T) {
+	graph := local.NewMapGraph[transactionNode, transactionLink]()
+	a, b, c, d := internal.NewIdNode("a"), internal.NewIdNode("b"), internal.NewIdNode("c"), internal.NewIdNode("d")
+	ab := internal.NewDirectedValuedLink(a, b, 1)
+	ca := internal.NewDirectedValuedLink(c, a, 2)
+	bc := internal.NewUndirectedValuedLink(b, c, 3)
+	graph.AddLink(ab)
+	graph.AddLink(ca)
+
+	var transactional graphs.TransactionalGraph[transactionNode, transactionLink] = &graph
+	transaction, errBegin := transactional.Begin()
+	if errBegin != nil {
+		t.Fatal(errBegin)
+	} else if _, err := graph.Begin(); err == nil {
+		t.Fatal("only one transaction at a time")
+	}
+
+	// d is implicitly added, a is removed with both its links
+	transaction.AddLink(internal.NewDirectedValuedLink(c, d, 4))
+	transaction.AddLink(bc)
+	transaction.RemoveNode(a)
+	if !checkNodes(t, &graph, "b", "c", "d") {
+		t.Fatal("changes should be visible in the transaction")
+	}
+
+	if err := transaction.Rollback(); err != nil {
+		t.Fatal(err)
+	} else if err := transaction.Commit(); !errors.Is(err, graphs.ErrTransactionClosed) {
+		t.Error("closed transaction should fail")
+	}
+
+	if !checkNodes(t, &graph, "a", "b", "c") {
+		t.Error("rollback should remove implicit nodes and restore removed ones")
+	} else if !graph.HasLink(ab) || !graph.HasLink(ca) || graph.HasLink(bc) {
+		t.Error("rollback should restore links")
+	}
+
+	if n, _ := graph.Neighbors(a); n.OutgoingDegree() != 1 || n.IncomingDegree() != 1 {
+		t.Error("rollback should restore degrees of a")
+	} else if n, _ := graph.Neighbors(c); n.OutgoingDegree() != 1 || n.UndirectedDegree() != 0 {
+		t.Error("rollback should restore degrees of c")
+	}
+
+	// commit keeps changes, and graph accepts a new transaction
+	committed, _ := graph.Begin()
+	committed.RemoveLink(ab)
+	committed.AddNode(d)
+	if err := committed.Commit(); err != nil {
+		t.Fatal(err)
+	} else if graph.HasLink(ab) || !checkNodes(t, &graph, "a", "b", "c", "d") {
+		t.Error("commit should keep changes")
+	}
+}
+
+func TestMapGraphApplyBatch(t *testing.T) {
+	graph := local.NewMapGraph[transactionNode, transactionLink]()
+	a, b := internal.NewIdNode("a"), internal.NewIdNode("b")
+	graph.AddNode(a)
+
+	// rejected batch changes nothing
+	var rejected graphs.Batch[transactionNode, transactionLink]
+	rejected.AddLink(internal.NewDirectedValuedLink(a, b, 1))
+	rejected.AddLink(internal.NewDirectedValuedLink(b, a, -1))
+	rejected.Validator = func(m graphs.Mutation[transactionNode, transactionLink]) error {
+		if m.Type == graphs.AddLinkMutation && m.Link.Value() < 0 {
+			return errors.New("negative value")
+		}
+
+		return nil
+	}
+
+	if err := graph.Apply(rejected); err == nil {
+		t.Fatal("invalid batch should fail")
+	} else if !checkNodes(t, &graph, "a") {
+		t.Error("invalid batch should not change graph")
+	}
+
+	// valid batch is applied, and releases the graph
+	var accepted graphs.Batch[transactionNode, transactionLink]
+	accepted.AddNode(b)
+	accepted.AddLink(internal.NewDirectedValuedLink(a, b, 1))
+	accepted.RemoveNode(a)
+	if err := graph.Apply(accepted); err != nil {
+		t.Fatal(err)
+	} else if !checkNodes(t, &graph, "b") {
+		t.Error("batch should be applied in order")
+	} else if n, _ := graph.Neighbors(b); n.IncomingDegree() != 0 {
+		t.Error("link should be removed with a")
+	}
+
+	if transaction, err := graph.Begin(); err != nil {
+		t.Error("apply should close its transaction")
+	} else {
+		transaction.Rollback()
+	}
+}
+
+func TestMapGraphTypedNilMutations(t *testing.T) {
+	graph := local.NewMapGraph[*internal.PropertiesNode, *internal.TypePropertiesLink[*internal.PropertiesNode]]()
+	a := internal.NewPropertiesNodeWithId("a")
+	graph.AddNode(&a)
+
+	var node *internal.PropertiesNode
+	var link *internal.TypePropertiesLink[*internal.PropertiesNode]
+	if err := (graphs.Mutation[*internal.PropertiesNode, *internal.TypePropertiesLink[*internal.PropertiesNode]]{Type: graphs.AddNodeMutation, Node: node}).Validate(); err == nil {
+		t.Error("typed nil node should be invalid")
+	} else if err := (graphs.Mutation[*internal.PropertiesNode, *internal.TypePropertiesLink[*internal.PropertiesNode]]{Type: graphs.RemoveLinkMutation, Link: link}).Validate(); err == nil {
+		t.Error("typed nil link should be invalid")
+	}
+
+	var batch graphs.Batch[*internal.PropertiesNode, *internal.TypePropertiesLink[*internal.PropertiesNode]]
+	batch.RemoveNode(&a)
+	batch.AddNode(node)
+	if err := graph.Apply(batch); err == nil {
+		t.Fatal("batch with typed nil should fail")
+	} else if n, _ := graph.Neighbors(&a); n == nil {
+		t.Error("invalid batch should not change graph")
+	}
+}
+
+func TestMapGraphTransactionRollbackAfterFailure(t *testing.T) {
+	graph := local.NewMapGraph[*internal.PropertiesNode, *internal.TypePropertiesLink[*internal.PropertiesNode]]()
+	a, b := internal.NewPropertiesNodeWithId("a"), internal.NewPropertiesNodeWithId("b")
+	ab := internal.NewTypePropertiesLink("knows", &a, &b)
+	graph.AddNode(&a)
+
+	transaction, _ := graph.Begin()
+	if err := transaction.AddLink(&ab); err != nil {
+		t.Fatal(err)
+	} else if err := transaction.RemoveNode(&a); err != nil {
+		t.Fatal(err)
+	}
+
+	// failing operation changes nothing, and transaction is still open
+	var node *internal.PropertiesNode
+	if err := transaction.AddNode(node); err == nil {
+		t.Fatal("typed nil node should fail")
+	} else if err := transaction.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, _ := graph.Neighbors(&a); n == nil {
+		t.Error("rollback should restore removed node")
+	} else if n.OutgoingDegree() != 0 {
+		t.Error("rollback should remove link")
+	} else if n, _ := graph.Neighbors(&b); n != nil {
+		t.Error("rollback should remove implicit node")
+	}
+}
@@ -1,6 +1,8 @@
 package local_test
 
 import (
+	"context"
+	"errors"
 	"testing"
 
 	"github.com/zefrenchwan/nodz.git/internal"
@@ -84,3 +86,19 @@ func TestGnpProbaOne(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestCancelledGenerators(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	generator := local.RandomGenerator[internal.IdNode, internal.UndirectedSimpleLink[internal.IdNode]]{}
+	if _, err := generator.UndirectedGNPContext(ctx, 100, 0.5, internal.NewRandomIdNode, internal.NewUndirectedSimpleLink); !errors.Is(err, context.Canceled) {
+		t.Errorf("gnp should be cancelled, got %v", err)
+	}
+
+	if g, err := generator.UndirectedBarabasiAlbertGraphContext(ctx, 3, 100, internal.NewRandomIdNode, internal.NewUndirectedSimpleLink); !errors.Is(err, context.Canceled) {
+		t.Errorf("barabasi albert should be cancelled, got %v", err)
+	} else if g == nil {
+		t.Error("partial graph should be returned")
+	}
+}
@@ -1,8 +1,12 @@
 package local_test
 
 import (
+	"math/rand"
+	"slices"
+	"strconv"
 	"testing"
 
+	"github.com/zefrenchwan/nodz.git/graphs"
 	"github.com/zefrenchwan/nodz.git/internal"
 	"github.com/zefrenchwan/nodz.git/internal/local"
 )
@@ -47,3 +51,44 @@ func TestRandomBarabasiAlbertGraph(t *testing.T) {
 	}
 
 }
+
+// TestSeededGenerators ensures that a given random source always builds the same graphs
+func TestSeededGenerators(t *testing.T) {
+	build := func(seed int64) []string {
+		counter := 0
+		nodes := func() internal.IdNode {
+			counter++
+			return internal.NewIdNode(strconv.Itoa(counter))
+		}
+
+		randomizer := local.RandomGenerator[internal.IdNode, internal.UndirectedSimpleLink[internal.IdNode]]{
+			Random: rand.New(rand.NewSource(seed)),
+		}
+
+		gnp, errGnp := randomizer.UndirectedGNP(30, 0.2, nodes, internal.NewUndirectedSimpleLink)
+		if errGnp != nil {
+			t.Fatal(errGnp)
+		}
+
+		ba, errBa := randomizer.UndirectedBarabasiAlbertGraph(3, 50, nodes, internal.NewUndirectedSimpleLink)
+		if errBa != nil {
+			t.Fatal(errBa)
+		}
+
+		result := make([]string, 0)
+		for _, g := range []graphs.CentralStructureGraph[internal.IdNode, internal.UndirectedSimpleLink[internal.IdNode]]{gnp, ba} {
+			for link := range g.(*local.MapGraph[internal.IdNode, internal.UndirectedSimpleLink[internal.IdNode]]).Links() {
+				result = append(result, link.Source().Id()+"-"+link.Destination().Id())
+			}
+		}
+
+		slices.Sort(result)
+		return result
+	}
+
+	if first, second := build(42), build(42); !slices.Equal(first, second) {
+		t.Error("same seed should build the same graphs")
+	} else if other := build(43); slices.Equal(first, other) {
+		t.Error("different seeds should build different graphs")
+	}
+}
@@ -0,0 +1,75 @@
+package local_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/zefrenchwan/nodz.git/internal"
+	"github.com/zefrenchwan/nodz.git/internal/local"
+)
+
+func TestMapGraphSequences(t *testing.T) {
+	graph := local.NewMapGraph[internal.IdNode, internal.ValuedLink[internal.IdNode, int]]()
+	a, b, c := internal.NewIdNode("a"), internal.NewIdNode("b"), internal.NewIdNode("c")
+	graph.AddLink(internal.NewUndirectedValuedLink(a, b, 1))
+	graph.AddLink(internal.NewDirectedValuedLink(b, c, 2))
+	graph.AddLink(internal.NewDirectedValuedLink(c, b, 3))
+
+	ids := make([]string, 0)
+	for node := range graph.All() {
+		ids = append(ids, node.Id())
+	}
+
+	slices.Sort(ids)
+	if !slices.Equal(ids, []string{"a", "b", "c"}) {
+		t.Errorf("unexpected nodes %v", ids)
+	}
+
+	values := make([]int, 0)
+	for link := range graph.Links() {
+		values = append(values, link.Value())
+	}
+
+	slices.Sort(values)
+	if !slices.Equal(values, []int{1, 2, 3}) {
+		t.Errorf("undirected links should appear once, got %v", values)
+	}
+
+	for node, neighborhood := range graph.Neighborhoods() {
+		if node.Id() == "b" && (neighborhood.UndirectedDegree() != 1 || neighborhood.IncomingDegree() != 1) {
+			t.Error("invalid neighborhood for b")
+		}
+	}
+
+	synchronized := local.NewSyncGraph[internal.IdNode, internal.ValuedLink[internal.IdNode, int]]()
+	synchronized.AddLink(internal.NewUndirectedValuedLink(a, b, 1))
+	if len(slices.Collect(synchronized.All())) != 2 || len(slices.Collect(synchronized.Links())) != 1 {
+		t.Error("invalid sync graph sequences")
+	}
+}
+
+func TestSetAndMatrixSequences(t *testing.T) {
+	set := local.NewSlicesSet(func(a, b int) bool { return a == b })
+	set.Add(1)
+	set.Add(2)
+	set.Add(1)
+	if values := slices.Sorted(set.All()); !slices.Equal(values, []int{1, 2}) {
+		t.Errorf("unexpected set values %v", values)
+	}
+
+	matrix, _ := local.NewMapMatrix(2, 0)
+	matrix.SetValue(0, 1, 5)
+	matrix.SetValue(1, 0, 7)
+	lines := make([][]int, 0)
+	for index, line := range matrix.All() {
+		if index != len(lines) {
+			t.Error("lines should come in order")
+		}
+
+		lines = append(lines, line)
+	}
+
+	if !slices.Equal(lines[0], []int{0, 5}) || !slices.Equal(lines[1], []int{7, 0}) {
+		t.Errorf("unexpected lines %v", lines)
+	}
+}
@@ -0,0 +1,102 @@
+package local_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/zefrenchwan/nodz.git/graphs"
+	"github.com/zefrenchwan/nodz.git/internal"
+	"github.com/zefrenchwan/nodz.git/internal/local"
+)
+
+type syncLink = internal.UndirectedSimpleLink[internal.IdNode]
+
+func TestSyncGraphConcurrentAccess(t *testing.T) {
+	graph := local.NewSyncGraph[internal.IdNode, syncLink]()
+	var central graphs.CentralStructureGraph[internal.IdNode, syncLink] = graph
+	center := internal.NewIdNode("center")
+	central.AddNode(center)
+
+	const writers, readers, links = 4, 8, 50
+	var group sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		group.Add(1)
+		go func() {
+			defer group.Done()
+			for i := 0; i < links; i++ {
+				other := internal.NewIdNode(fmt.Sprintf("%d-%d", w, i))
+				graph.AddLink(internal.NewUndirectedSimpleLink(center, other))
+				if i%5 == 0 {
+					graph.RemoveNode(other)
+				}
+			}
+		}()
+	}
+
+	errs := make(chan error, readers)
+	for r := 0; r < readers; r++ {
+		group.Add(1)
+		go func() {
+			defer group.Done()
+			for i := 0; i < links; i++ {
+				neighbors, _ := graph.Neighbors(center)
+				it, _ := neighbors.Links()
+				count := int64(0)
+				for has, _ := it.Next(); has; has, _ = it.Next() {
+					count++
+				}
+
+				// snapshot is consistent: counters and links are read at once
+				if count != neighbors.UndirectedDegree() {
+					errs <- fmt.Errorf("snapshot has %d links, degree is %d", count, neighbors.UndirectedDegree())
+					return
+				}
+
+				nodes, _ := graph.AllNodes()
+				for has, _ := nodes.Next(); has; has, _ = nodes.Next() {
+					nodes.Value()
+				}
+			}
+		}()
+	}
+
+	group.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	// one link out of five was removed with its node
+	expected := int64(writers * (links - links/5))
+	if neighbors, _ := graph.Neighbors(center); neighbors.UndirectedDegree() != expected {
+		t.Errorf("expected degree %d, got %d", expected, neighbors.UndirectedDegree())
+	}
+}
+
+func TestSyncGraphSnapshot(t *testing.T) {
+	graph := local.NewSyncGraph[internal.IdNode, syncLink]()
+	a, b, c := internal.NewIdNode("a"), internal.NewIdNode("b"), internal.NewIdNode("c")
+	graph.AddLink(internal.NewUndirectedSimpleLink(a, b))
+	graph.AddNode(c)
+
+	neighbors, _ := graph.Neighbors(a)
+	snapshot := graph.Snapshot()
+	graph.AddLink(internal.NewUndirectedSimpleLink(a, c))
+
+	it, _ := neighbors.Links()
+	count := 0
+	for has, _ := it.Next(); has; has, _ = it.Next() {
+		count++
+	}
+
+	if count != 1 {
+		t.Errorf("neighborhood should not see later changes, got %d links", count)
+	} else if snapshot.HasLink(internal.NewUndirectedSimpleLink(a, c)) {
+		t.Error("snapshot should not see later changes")
+	} else if n, _ := snapshot.Neighbors(b); n == nil || n.UndirectedDegree() != 1 {
+		t.Error("snapshot should contain links once")
+	} else if n, _ := snapshot.Neighbors(c); n == nil {
+		t.Error("snapshot should contain isolated nodes")
+	}
+}
@@ -1,6 +1,7 @@
 package internal_test
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/zefrenchwan/nodz.git/graphs"
@@ -43,3 +44,34 @@ func TestMapFilterIterator(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestMapNoFilterIteratorErrors(t *testing.T) {
+	baseIterator := local.NewSlicesIterator([]int{1, 0, 2})
+	calls := 0
+	it := graphs.MapFilterIterator[int, int]{
+		Iterator: &baseIterator,
+		Mapper: func(a int) (int, error) {
+			calls++
+			if a == 0 {
+				return 0, errors.New("zero")
+			}
+
+			return 10 / a, nil
+		},
+	}
+
+	if has, err := it.Next(); !has || err != nil {
+		t.Fatal("expected first value")
+	} else if v, _ := it.Value(); v != 10 {
+		t.Errorf("expected 10, got %d", v)
+	}
+
+	// mapper error is reported with the next value, even with no filter
+	if has, err := it.Next(); !has || err == nil {
+		t.Error("mapper error should be returned by Next")
+	} else if v, _ := it.Value(); v != 5 {
+		t.Errorf("expected 5, got %d", v)
+	} else if calls != 3 {
+		t.Errorf("mapper should be called once per value, got %d calls", calls)
+	}
+}
@@ -0,0 +1,310 @@
+package internal_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zefrenchwan/nodz.git/graphs"
+	"github.com/zefrenchwan/nodz.git/internal"
+	"github.com/zefrenchwan/nodz.git/internal/local"
+)
+
+type observedLink = internal.TypePropertiesLink[*internal.PropertiesNode]
+
+func TestObservableGraphEvents(t *testing.T) {
+	base := local.NewMapGraph[*internal.PropertiesNode, *observedLink]()
+	var graph graphs.ObservableGraph[*internal.PropertiesNode, *observedLink] = internal.NewObservableGraph(&base)
+
+	events := make([]graphs.GraphEvent[*internal.PropertiesNode, *observedLink], 0)
+	if _, err := graph.Subscribe(func(e graphs.GraphEvent[*internal.PropertiesNode, *observedLink]) {
+		events = append(events, e)
+	}, graphs.ObserverOptions[*internal.PropertiesNode]{}); err != nil {
+		t.Fatal(err)
+	}
+
+	a := internal.NewPropertiesNodeWithId("a")
+	b := internal.NewPropertiesNodeWithId("b")
+	c := internal.NewPropertiesNodeWithId("c")
+	ab := internal.NewTypePropertiesLink("knows", &a, &b)
+	cb := internal.NewTypePropertiesLink("knows", &c, &b)
+
+	graph.AddNode(&a)
+	// no event, node exists
+	graph.AddNode(&a)
+	// b added, then link
+	graph.AddLink(&ab)
+	// no event, link exists
+	graph.AddLink(&ab)
+	graph.AddLink(&cb)
+	graph.SetNodeProperty(&a, "age", "42")
+	// no event, same value
+	graph.SetNodeProperty(&a, "age", "42")
+	graph.SetLinkProperty(&ab, "since", "2010")
+	graph.RemoveLink(&ab)
+	// no event, link was removed
+	graph.RemoveLink(&ab)
+	// b is removed with incoming link from c
+	graph.RemoveNode(&b)
+
+	expected := []graphs.EventType{
+		graphs.NodeAdded, graphs.NodeAdded, graphs.LinkAdded,
+		graphs.NodeAdded, graphs.LinkAdded,
+		graphs.PropertyChanged, graphs.PropertyChanged,
+		graphs.LinkRemoved, graphs.NodeRemoved,
+	}
+
+	if len(events) != len(expected) {
+		t.Fatalf("expected %d events, got %d", len(expected), len(events))
+	}
+
+	for index, event := range events {
+		if event.Type != expected[index] {
+			t.Errorf("event %d: expected %s, got %s", index, expected[index], event.Type)
+		} else if event.Sequence != uint64(index+1) {
+			t.Errorf("event %d: invalid sequence", index)
+		}
+	}
+
+	if events[5].OnLink || events[5].Key != "age" || events[5].NewValue != "42" {
+		t.Error("invalid node property event")
+	} else if !events[6].OnLink || events[6].Key != "since" {
+		t.Error("invalid link property event")
+	} else if since, _ := ab.GetProperty("since"); since != "2010" {
+		t.Error("property should be set on link")
+	}
+
+	removal := events[8]
+	if !removal.Node.SameNode(&b) || len(removal.CascadedLinks) != 1 || !removal.CascadedLinks[0].SameLink(&cb) {
+		t.Error("node removal should include incoming link")
+	}
+
+	if err := graph.SetNodeProperty(&b, "k", "v"); err == nil {
+		t.Error("removed node should raise an error")
+	}
+}
+
+func TestObservableGraphFilterAndUnsubscribe(t *testing.T) {
+	base := local.NewMapGraph[internal.IdNode, internal.UndirectedSimpleLink[internal.IdNode]]()
+	graph := internal.NewObservableGraph(&base)
+
+	a := internal.NewIdNode("a")
+	b := internal.NewIdNode("b")
+	c := internal.NewIdNode("c")
+
+	counter := 0
+	id, _ := graph.Subscribe(func(graphs.GraphEvent[internal.IdNode, internal.UndirectedSimpleLink[internal.IdNode]]) {
+		counter++
+	}, graphs.ObserverOptions[internal.IdNode]{Filter: func(n internal.IdNode) bool { return n.SameNode(a) }})
+
+	// NodeAdded a and LinkAdded a-b, NodeAdded b is not about a
+	graph.AddLink(internal.NewUndirectedSimpleLink(a, b))
+	// nothing about a
+	graph.AddLink(internal.NewUndirectedSimpleLink(b, c))
+	// NodeRemoved b matches because of its link with a
+	graph.RemoveNode(b)
+
+	if counter != 3 {
+		t.Errorf("expected 3 events, got %d", counter)
+	}
+
+	if err := graph.Unsubscribe(id); err != nil {
+		t.Fatal(err)
+	} else if err := graph.Unsubscribe(id); err == nil {
+		t.Error("second unsubscribe should fail")
+	}
+
+	graph.RemoveNode(a)
+	if counter != 3 {
+		t.Error("no event expected after unsubscribe")
+	}
+}
+
+func TestObservableGraphBufferedDelivery(t *testing.T) {
+	base := local.NewMapGraph[internal.IdNode, internal.UndirectedSimpleLink[internal.IdNode]]()
+	graph := internal.NewObservableGraph(&base)
+
+	var lock sync.Mutex
+	sequences := make([]uint64, 0)
+	graph.Subscribe(func(e graphs.GraphEvent[internal.IdNode, internal.UndirectedSimpleLink[internal.IdNode]]) {
+		lock.Lock()
+		defer lock.Unlock()
+		sequences = append(sequences, e.Sequence)
+	}, graphs.ObserverOptions[internal.IdNode]{BufferSize: 2})
+
+	for index := 0; index < 50; index++ {
+		graph.AddNode(internal.NewRandomIdNode())
+	}
+
+	// close waits for delivery
+	graph.Close()
+
+	lock.Lock()
+	defer lock.Unlock()
+	if len(sequences) != 50 {
+		t.Fatalf("expected 50 events, got %d", len(sequences))
+	}
+
+	for index, sequence := range sequences {
+		if sequence != uint64(index+1) {
+			t.Error("events should be delivered in order")
+		}
+	}
+}
+
+func TestObservableGraphStoredNodeProperties(t *testing.T) {
+	base := local.NewMapGraph[*internal.PropertiesNode, *observedLink]()
+	graph := internal.NewObservableGraph(&base)
+
+	events := make([]graphs.GraphEvent[*internal.PropertiesNode, *observedLink], 0)
+	graph.Subscribe(func(e graphs.GraphEvent[*internal.PropertiesNode, *observedLink]) {
+		events = append(events, e)
+	}, graphs.ObserverOptions[*internal.PropertiesNode]{})
+
+	stored := internal.NewPropertiesNodeWithId("a")
+	graph.AddNode(&stored)
+
+	// same node, other instance
+	other := internal.NewPropertiesNodeWithId("a")
+	if err := graph.SetNodeProperty(&other, "age", "42"); err != nil {
+		t.Fatal(err)
+	} else if age, _ := stored.GetProperty("age"); age != "42" {
+		t.Error("property should be set on stored node")
+	} else if _, found := other.GetProperty("age"); found {
+		t.Error("property should not be set on caller instance")
+	} else if len(events) != 2 || events[1].Node != &stored {
+		t.Error("event should be about stored node")
+	}
+
+	if err := graph.RemoveNodeProperty(&other, "age"); err != nil {
+		t.Fatal(err)
+	} else if _, found := stored.GetProperty("age"); found {
+		t.Error("property should be removed from stored node")
+	}
+
+	absent := internal.NewPropertiesNodeWithId("b")
+	if err := graph.SetNodeProperty(&absent, "age", "42"); err == nil {
+		t.Error("absent node should raise an error")
+	} else if err := graph.RemoveNodeProperty(&absent, "age"); err == nil {
+		t.Error("absent node should raise an error")
+	} else if len(events) != 3 {
+		t.Errorf("no event expected for absent node, got %d events", len(events))
+	}
+}
+
+func TestObservableGraphHandlerSubscribes(t *testing.T) {
+	base := local.NewMapGraph[internal.IdNode, internal.UndirectedSimpleLink[internal.IdNode]]()
+	graph := internal.NewObservableGraph(&base)
+	handler := func(graphs.GraphEvent[internal.IdNode, internal.UndirectedSimpleLink[internal.IdNode]]) {}
+
+	// buffer is full while handler subscribes and unsubscribes
+	graph.Subscribe(func(graphs.GraphEvent[internal.IdNode, internal.UndirectedSimpleLink[internal.IdNode]]) {
+		id, _ := graph.Subscribe(handler, graphs.ObserverOptions[internal.IdNode]{BufferSize: 1})
+		graph.Unsubscribe(id)
+	}, graphs.ObserverOptions[internal.IdNode]{BufferSize: 1})
+
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		for index := 0; index < 100; index++ {
+			graph.AddNode(internal.NewRandomIdNode())
+		}
+
+		graph.Close()
+	}()
+
+	select {
+	case <-finished:
+	case <-time.After(10 * time.Second):
+		t.Fatal("handler changing observers should not block publication")
+	}
+}
+
+func TestObservableGraphConcurrentOrder(t *testing.T) {
+	graph := internal.NewObservableGraph(local.NewSyncGraph[internal.IdNode, internal.UndirectedSimpleLink[internal.IdNode]]())
+	sequences := make([]uint64, 0)
+	graph.Subscribe(func(e graphs.GraphEvent[internal.IdNode, internal.UndirectedSimpleLink[internal.IdNode]]) {
+		sequences = append(sequences, e.Sequence)
+	}, graphs.ObserverOptions[internal.IdNode]{BufferSize: 1})
+
+	var group sync.WaitGroup
+	for range 4 {
+		group.Add(1)
+		go func() {
+			defer group.Done()
+			for range 50 {
+				graph.AddNode(internal.NewRandomIdNode())
+			}
+		}()
+	}
+
+	group.Wait()
+	graph.Close()
+
+	if len(sequences) != 200 {
+		t.Fatalf("expected 200 events, got %d", len(sequences))
+	}
+
+	for index, sequence := range sequences {
+		if sequence != uint64(index+1) {
+			t.Fatalf("event %d: got sequence %d, events should be delivered in order", index, sequence)
+		}
+	}
+}
+
+func TestObservableGraphConcurrentChanges(t *testing.T) {
+	graph := internal.NewObservableGraph(local.NewSyncGraph[*internal.PropertiesNode, *observedLink]())
+	events := make([]graphs.GraphEvent[*internal.PropertiesNode, *observedLink], 0)
+	graph.Subscribe(func(e graphs.GraphEvent[*internal.PropertiesNode, *observedLink]) {
+		events = append(events, e)
+	}, graphs.ObserverOptions[*internal.PropertiesNode]{BufferSize: 16})
+
+	// all writers add the same nodes and links, and change the same property
+	var group sync.WaitGroup
+	for writer := range 8 {
+		group.Add(1)
+		go func() {
+			defer group.Done()
+			for index := range 20 {
+				source := internal.NewPropertiesNodeWithId(fmt.Sprint(index))
+				destination := internal.NewPropertiesNodeWithId(fmt.Sprint(index + 1))
+				link := internal.NewTypePropertiesLink("next", &source, &destination)
+				graph.AddNode(&source)
+				graph.AddLink(&link)
+				graph.SetNodeProperty(&source, "writer", fmt.Sprint(writer))
+			}
+		}()
+	}
+
+	group.Wait()
+	graph.Close()
+
+	added := make(map[string]int)
+	last := make(map[string]string)
+	for _, event := range events {
+		switch event.Type {
+		case graphs.NodeAdded:
+			added[event.Node.Id()]++
+		case graphs.LinkAdded:
+			added[event.Link.Source().Id()+" -> "+event.Link.Destination().Id()]++
+		case graphs.PropertyChanged:
+			// each change starts from the value of the previous one
+			if event.OldValue != last[event.Node.Id()] {
+				t.Fatalf("node %s: change from %q, previous value was %q", event.Node.Id(), event.OldValue, last[event.Node.Id()])
+			}
+
+			last[event.Node.Id()] = event.NewValue
+		}
+	}
+
+	if len(added) != 41 {
+		t.Errorf("expected 21 nodes and 20 links, got %d", len(added))
+	}
+
+	for element, count := range added {
+		if count != 1 {
+			t.Errorf("%s: added %d times", element, count)
+		}
+	}
+}
@@ -1,9 +1,17 @@
 package main
 
 import (
-	"github.com/zefrenchwan/nodz.git/examples"
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/zefrenchwan/nodz.git/cli"
 )
 
 func main() {
-	examples.GNPCriticalPointAppearance()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	code := cli.Run(ctx, os.Args[1:], cli.Streams{In: os.Stdin, Out: os.Stdout, Err: os.Stderr})
+	stop()
+	os.Exit(code)
 }
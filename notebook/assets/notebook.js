@@ -0,0 +1,370 @@
+"use strict";
+
+// counter numbers the runs of cells, as in the session
+let counter = 0;
+
+const cells = document.getElementById("cells");
+const template = document.getElementById("cell-template");
+const statusBar = document.getElementById("status");
+const svgNamespace = "http://www.w3.org/2000/svg";
+
+// api calls the server, and returns the decoded json body (null for no content)
+async function api(method, path, body) {
+	const options = { method: method, headers: {} };
+	if (body !== undefined) {
+		options.headers["Content-Type"] = "application/json";
+		options.body = JSON.stringify(body);
+	}
+
+	const response = await fetch(path, options);
+	const content = response.status === 204 ? null : await response.json();
+	if (!response.ok) {
+		throw new Error(content && content.error ? content.error : response.statusText);
+	}
+
+	return content;
+}
+
+// report shows a message in the header
+function report(message) {
+	statusBar.textContent = message;
+}
+
+// element creates an element with a text content
+function element(tag, text, className) {
+	const result = document.createElement(tag);
+	if (text !== undefined) {
+		result.textContent = text;
+	}
+
+	if (className) {
+		result.className = className;
+	}
+
+	return result;
+}
+
+// addCell adds a cell after a given cell (at the end if null), and returns it
+function addCell(after, source, output) {
+	const cell = template.content.firstElementChild.cloneNode(true);
+	const textarea = cell.querySelector("textarea");
+	textarea.value = source || "";
+	fitTextarea(textarea);
+	textarea.addEventListener("input", () => fitTextarea(textarea));
+	textarea.addEventListener("keydown", (event) => {
+		if (event.key === "Enter" && event.shiftKey) {
+			event.preventDefault();
+			runCell(cell).then(() => focusNext(cell));
+		}
+	});
+
+	cell.querySelector(".run").addEventListener("click", () => runCell(cell));
+	cell.querySelector(".add").addEventListener("click", () => addCell(cell).querySelector("textarea").focus());
+	cell.querySelector(".delete").addEventListener("click", () => {
+		cell.remove();
+		if (cells.children.length === 0) {
+			addCell(null);
+		}
+	});
+
+	if (after) {
+		after.after(cell);
+	} else {
+		cells.appendChild(cell);
+	}
+
+	if (output) {
+		showOutput(cell, output);
+	}
+
+	return cell;
+}
+
+// fitTextarea resizes a textarea to its content
+function fitTextarea(textarea) {
+	textarea.rows = Math.max(3, textarea.value.split("\n").length);
+}
+
+// focusNext focuses the cell after cell, creating it if needed
+function focusNext(cell) {
+	const next = cell.nextElementSibling || addCell(cell);
+	next.querySelector("textarea").focus();
+}
+
+// runCell runs the source of a cell, and shows its output
+async function runCell(cell) {
+	const source = cell.querySelector("textarea").value;
+	cell.classList.add("running");
+	cell.querySelector(".counter").textContent = "[*]";
+	try {
+		const output = await api("POST", "api/run", { source: source });
+		counter++;
+		cell.querySelector(".counter").textContent = "[" + counter + "]";
+		showOutput(cell, output);
+		report(output.error ? "error" : "");
+		await refreshVariables();
+		return output;
+	} catch (err) {
+		cell.querySelector(".counter").textContent = "[!]";
+		report(err.message);
+		return null;
+	} finally {
+		cell.classList.remove("running");
+	}
+}
+
+// showOutput renders the output of a cell, and keeps it for saves
+function showOutput(cell, output) {
+	cell.output = output;
+	const container = cell.querySelector(".output");
+	container.replaceChildren();
+	for (const display of output.displays || []) {
+		if (display.kind === "text") {
+			container.appendChild(element("pre", display.text));
+		} else if (display.kind === "table") {
+			container.appendChild(renderTable(display.table));
+		} else if (display.kind === "graph") {
+			container.appendChild(renderGraph(display.text, display.graph));
+		}
+	}
+
+	if (output.error) {
+		const position = output.error.line > 0 ? "line " + output.error.line + ", column " + output.error.column + ": " : "";
+		container.appendChild(element("pre", position + output.error.message, "error"));
+	}
+}
+
+// renderTable returns a html table
+function renderTable(table) {
+	const result = element("table");
+	const header = result.createTHead().insertRow();
+	for (const column of table.columns) {
+		header.appendChild(element("th", column));
+	}
+
+	const body = result.createTBody();
+	for (const values of table.rows) {
+		const row = body.insertRow();
+		for (const value of values) {
+			row.appendChild(element("td", value));
+		}
+	}
+
+	return result;
+}
+
+// renderGraph returns a figure with a svg drawing of a graph
+function renderGraph(caption, graph) {
+	const figure = element("figure");
+	const width = 420, height = 300, radius = graph.nodes.length > 50 ? 3 : 5;
+	if (graph.truncated) {
+		figure.appendChild(element("figcaption", caption + ": too large to be drawn"));
+		return figure;
+	}
+
+	const svg = document.createElementNS(svgNamespace, "svg");
+	svg.setAttribute("width", width);
+	svg.setAttribute("height", height);
+	svg.setAttribute("viewBox", "0 0 " + width + " " + height);
+	if (graph.directed) {
+		const defs = document.createElementNS(svgNamespace, "defs");
+		const marker = document.createElementNS(svgNamespace, "marker");
+		marker.setAttribute("id", "arrow");
+		marker.setAttribute("viewBox", "0 0 10 10");
+		marker.setAttribute("refX", 10 + 2 * radius);
+		marker.setAttribute("refY", 5);
+		marker.setAttribute("markerWidth", 6);
+		marker.setAttribute("markerHeight", 6);
+		marker.setAttribute("orient", "auto");
+		const path = document.createElementNS(svgNamespace, "path");
+		path.setAttribute("d", "M 0 0 L 10 5 L 0 10 z");
+		path.setAttribute("fill", "#999");
+		marker.appendChild(path);
+		defs.appendChild(marker);
+		svg.appendChild(defs);
+	}
+
+	const x = (node) => node.x * width;
+	const y = (node) => node.y * height;
+	for (const link of graph.links) {
+		const source = graph.nodes[link.source], target = graph.nodes[link.target];
+		const line = document.createElementNS(svgNamespace, "line");
+		line.setAttribute("x1", x(source));
+		line.setAttribute("y1", y(source));
+		line.setAttribute("x2", x(target));
+		line.setAttribute("y2", y(target));
+		if (graph.directed) {
+			line.setAttribute("marker-end", "url(#arrow)");
+		}
+
+		const title = document.createElementNS(svgNamespace, "title");
+		title.textContent = source.id + (graph.directed ? " -> " : " - ") + target.id + " (" + link.weight + ")";
+		line.appendChild(title);
+		svg.appendChild(line);
+	}
+
+	for (const node of graph.nodes) {
+		const circle = document.createElementNS(svgNamespace, "circle");
+		circle.setAttribute("cx", x(node));
+		circle.setAttribute("cy", y(node));
+		circle.setAttribute("r", radius);
+		const title = document.createElementNS(svgNamespace, "title");
+		title.textContent = node.id;
+		circle.appendChild(title);
+		svg.appendChild(circle);
+	}
+
+	figure.appendChild(svg);
+	figure.appendChild(element("figcaption", caption));
+	return figure;
+}
+
+// refreshVariables shows the variables of the session
+async function refreshVariables() {
+	const variables = await api("GET", "api/variables");
+	const body = document.querySelector("#variables tbody");
+	body.replaceChildren();
+	for (const variable of variables) {
+		const row = body.insertRow();
+		row.appendChild(element("td", variable.name));
+		row.appendChild(element("td", variable.type));
+		row.appendChild(element("td", variable.summary));
+	}
+}
+
+// currentNotebook returns the notebook of the page
+function currentNotebook() {
+	const result = { version: 1, cells: [] };
+	for (const cell of cells.children) {
+		const current = { source: cell.querySelector("textarea").value };
+		if (cell.output) {
+			current.output = cell.output;
+		}
+
+		result.cells.push(current);
+	}
+
+	return result;
+}
+
+// showNotebook replaces the cells of the page
+function showNotebook(notebook) {
+	cells.replaceChildren();
+	for (const cell of notebook.cells) {
+		addCell(null, cell.source, cell.output);
+	}
+
+	if (cells.children.length === 0) {
+		addCell(null);
+	}
+}
+
+// notebookName returns the name typed by the user, or reports that it is missing
+function notebookName() {
+	const name = document.getElementById("name").value.trim();
+	if (name === "") {
+		report("type a notebook name first");
+	}
+
+	return name;
+}
+
+// refreshNotebooks lists the saved notebooks for the name input
+async function refreshNotebooks() {
+	const names = await api("GET", "api/notebooks");
+	const list = document.getElementById("notebooks");
+	list.replaceChildren();
+	for (const name of names) {
+		const option = element("option");
+		option.value = name;
+		list.appendChild(option);
+	}
+}
+
+document.getElementById("open").addEventListener("click", async () => {
+	const name = notebookName();
+	if (name !== "") {
+		try {
+			showNotebook(await api("GET", "api/notebooks/" + encodeURIComponent(name)));
+			report("opened " + name + ", run all to rebuild the session");
+		} catch (err) {
+			report(err.message);
+		}
+	}
+});
+
+document.getElementById("save").addEventListener("click", async () => {
+	const name = notebookName();
+	if (name !== "") {
+		try {
+			await api("PUT", "api/notebooks/" + encodeURIComponent(name), currentNotebook());
+			report("saved " + name);
+			await refreshNotebooks();
+		} catch (err) {
+			report(err.message);
+		}
+	}
+});
+
+document.getElementById("download").addEventListener("click", () => {
+	const name = document.getElementById("name").value.trim() || "notebook";
+	const blob = new Blob([JSON.stringify(currentNotebook(), null, 2)], { type: "application/json" });
+	const link = element("a");
+	link.href = URL.createObjectURL(blob);
+	link.download = name + ".notebook.json";
+	link.click();
+	URL.revokeObjectURL(link.href);
+});
+
+document.getElementById("upload").addEventListener("change", async (event) => {
+	const file = event.target.files[0];
+	if (file) {
+		try {
+			const notebook = JSON.parse(await file.text());
+			if (!Array.isArray(notebook.cells)) {
+				throw new Error("not a notebook");
+			}
+
+			showNotebook(notebook);
+			report("loaded " + file.name + ", run all to rebuild the session");
+		} catch (err) {
+			report(file.name + ": " + err.message);
+		}
+
+		event.target.value = "";
+	}
+});
+
+document.getElementById("run-all").addEventListener("click", async () => {
+	await api("POST", "api/reset");
+	counter = 0;
+	for (const cell of Array.from(cells.children)) {
+		const output = await runCell(cell);
+		if (!output || output.error) {
+			break;
+		}
+	}
+});
+
+document.getElementById("reset").addEventListener("click", async () => {
+	await api("POST", "api/reset");
+	counter = 0;
+	report("session reset");
+	await refreshVariables();
+});
+
+document.getElementById("new").addEventListener("click", () => {
+	document.getElementById("name").value = "";
+	showNotebook({ cells: [] });
+});
+
+document.getElementById("toggle-help").addEventListener("click", () => {
+	const help = document.getElementById("help");
+	help.hidden = !help.hidden;
+});
+
+// initial state: one cell, functions help, saved notebooks and current variables
+addCell(null, "graph g = gnp(30, 0.1)\nprint g, stats(g), components(g)");
+api("GET", "api/help").then((help) => { document.getElementById("functions").textContent = help.functions; });
+refreshNotebooks().catch((err) => report(err.message));
+refreshVariables().catch((err) => report(err.message));
@@ -0,0 +1,106 @@
+package notebook
+
+import (
+	"math"
+)
+
+// layoutIterations is the number of steps of the force directed layout
+const layoutIterations = 150
+
+// layout returns the positions of size nodes linked by links (indexes of nodes), coordinates are from 0 to 1.
+// It is a Fruchterman Reingold force directed layout: linked nodes attract, all nodes repel.
+// Nodes start on a circle, so that a graph always gets the same drawing
+func layout(size int, links [][2]int) [][2]float64 {
+	positions := make([][2]float64, size)
+	if size == 0 {
+		return positions
+	} else if size == 1 {
+		positions[0] = [2]float64{0.5, 0.5}
+		return positions
+	}
+
+	for index := range positions {
+		angle := 2 * math.Pi * float64(index) / float64(size)
+		positions[index] = [2]float64{0.5 + 0.4*math.Cos(angle), 0.5 + 0.4*math.Sin(angle)}
+	}
+
+	// k is the ideal distance between nodes, temperature limits moves and decreases at each step
+	k := math.Sqrt(1.0 / float64(size))
+	temperature := 0.1
+	moves := make([][2]float64, size)
+	for step := 0; step < layoutIterations; step++ {
+		clear(moves)
+		for i := 0; i < size; i++ {
+			for j := i + 1; j < size; j++ {
+				dx, dy, distance := delta(positions[i], positions[j])
+				force := k * k / distance
+				moves[i][0] += dx / distance * force
+				moves[i][1] += dy / distance * force
+				moves[j][0] -= dx / distance * force
+				moves[j][1] -= dy / distance * force
+			}
+		}
+
+		for _, current := range links {
+			source, target := current[0], current[1]
+			if source == target {
+				continue
+			}
+
+			dx, dy, distance := delta(positions[source], positions[target])
+			force := distance * distance / k
+			moves[source][0] -= dx / distance * force
+			moves[source][1] -= dy / distance * force
+			moves[target][0] += dx / distance * force
+			moves[target][1] += dy / distance * force
+		}
+
+		for index, move := range moves {
+			length := math.Max(math.Hypot(move[0], move[1]), 1e-9)
+			limited := math.Min(length, temperature)
+			positions[index][0] += move[0] / length * limited
+			positions[index][1] += move[1] / length * limited
+		}
+
+		temperature *= 0.97
+	}
+
+	return normalize(positions)
+}
+
+// delta returns the difference of two positions and their distance, never 0
+func delta(a, b [2]float64) (float64, float64, float64) {
+	dx, dy := a[0]-b[0], a[1]-b[1]
+	distance := math.Hypot(dx, dy)
+	if distance < 1e-6 {
+		// same position: move apart in an arbitrary but fixed direction
+		return 1e-6, 0, 1e-6
+	}
+
+	return dx, dy, distance
+}
+
+// normalize scales positions so that they fill the square from 0.05 to 0.95, keeping proportions
+func normalize(positions [][2]float64) [][2]float64 {
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, position := range positions {
+		minX, maxX = math.Min(minX, position[0]), math.Max(maxX, position[0])
+		minY, maxY = math.Min(minY, position[1]), math.Max(maxY, position[1])
+	}
+
+	scale := math.Max(maxX-minX, maxY-minY)
+	if scale < 1e-9 {
+		scale = 1
+	}
+
+	// center the smaller dimension
+	offsetX := (scale - (maxX - minX)) / 2
+	offsetY := (scale - (maxY - minY)) / 2
+	for index, position := range positions {
+		positions[index][0] = 0.05 + 0.9*(position[0]-minX+offsetX)/scale
+		positions[index][1] = 0.05 + 0.9*(position[1]-minY+offsetY)/scale
+	}
+
+	return positions
+}
@@ -0,0 +1,140 @@
+package notebook
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Version is the version of the notebook documents this package writes
+const Version = 1
+
+// Notebook is a list of cells, saved as a json document
+type Notebook struct {
+	// Version is the version of the document format
+	Version int `json:"version"`
+	// Cells are the cells, in execution order
+	Cells []Cell `json:"cells"`
+}
+
+// Cell is a script and the output of its last run
+type Cell struct {
+	// Source is the script of the cell
+	Source string `json:"source"`
+	// Output is the result of the last run, nil if cell never ran
+	Output *Output `json:"output,omitempty"`
+}
+
+// Output is the result of the run of a cell
+type Output struct {
+	// Displays are the printed values, in print order
+	Displays []Display `json:"displays"`
+	// Error is the error that stopped the run, if any. Displays are the values printed before
+	Error *CellError `json:"error,omitempty"`
+}
+
+// CellError is an error of a cell run
+type CellError struct {
+	// Message describes the error, position excluded
+	Message string `json:"message"`
+	// Line of the error in the cell, 0 if unknown
+	Line int `json:"line"`
+	// Column of the error in the cell, 0 if unknown
+	Column int `json:"column"`
+}
+
+// Display kinds
+const (
+	// TextDisplay is printed text
+	TextDisplay = "text"
+	// TableDisplay is a table of records, such as statistics
+	TableDisplay = "table"
+	// GraphDisplay is a small drawing of a graph
+	GraphDisplay = "graph"
+)
+
+// Display is a printed value, rendered by the page
+type Display struct {
+	// Kind is TextDisplay, TableDisplay or GraphDisplay
+	Kind string `json:"kind"`
+	// Text is the content of a text, or the caption of a graph
+	Text string `json:"text,omitempty"`
+	// Table is the content of a table
+	Table *Table `json:"table,omitempty"`
+	// Graph is the content of a graph
+	Graph *Drawing `json:"graph,omitempty"`
+}
+
+// Table is a table of values, formatted as script prints them
+type Table struct {
+	// Columns are the names of the columns
+	Columns []string `json:"columns"`
+	// Rows are the values, one per column
+	Rows [][]string `json:"rows"`
+}
+
+// Drawing is a graph with the positions of its nodes
+type Drawing struct {
+	// Directed is true for a graph of directed links
+	Directed bool `json:"directed"`
+	// NodesCount is the number of nodes of the graph
+	NodesCount int `json:"nodes_count"`
+	// LinksCount is the number of links of the graph
+	LinksCount int `json:"links_count"`
+	// Truncated is true for graphs too large to be drawn, then Nodes and Links are empty
+	Truncated bool `json:"truncated"`
+	// Nodes are the nodes with their positions
+	Nodes []DrawnNode `json:"nodes"`
+	// Links are the links, as indexes in Nodes
+	Links []DrawnLink `json:"links"`
+}
+
+// DrawnNode is a node and its position, coordinates are from 0 to 1
+type DrawnNode struct {
+	// Id is the id of the node
+	Id string `json:"id"`
+	// X is the horizontal position
+	X float64 `json:"x"`
+	// Y is the vertical position
+	Y float64 `json:"y"`
+}
+
+// DrawnLink is a link between two drawn nodes
+type DrawnLink struct {
+	// Source is the index of the source node
+	Source int `json:"source"`
+	// Target is the index of the target node
+	Target int `json:"target"`
+	// Weight is the weight of the link
+	Weight float64 `json:"weight"`
+}
+
+// Load reads a notebook document
+func Load(reader io.Reader) (Notebook, error) {
+	var result Notebook
+	decoder := json.NewDecoder(reader)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&result); err != nil {
+		return result, fmt.Errorf("invalid notebook: %w", err)
+	} else if result.Version <= 0 || result.Version > Version {
+		return result, fmt.Errorf("unsupported notebook version %d, expecting 1 to %d", result.Version, Version)
+	}
+
+	if result.Cells == nil {
+		result.Cells = make([]Cell, 0)
+	}
+
+	return result, nil
+}
+
+// Save writes a notebook document, with the current version
+func Save(writer io.Writer, notebook Notebook) error {
+	notebook.Version = Version
+	if notebook.Cells == nil {
+		notebook.Cells = make([]Cell, 0)
+	}
+
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(notebook)
+}
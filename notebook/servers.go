@@ -0,0 +1,297 @@
+package notebook
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/zefrenchwan/nodz.git/dsl"
+	"github.com/zefrenchwan/nodz.git/internal/httpjson"
+)
+
+// notebookExtension is the extension of notebook files
+const notebookExtension = ".notebook.json"
+
+// maxBodySize is the maximum size of a request body, notebooks include their outputs
+const maxBodySize = 8 << 20
+
+// validName matches names of notebooks: no path, no hidden file
+var validName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_\-. ]{0,99}$`)
+
+// Options configures the server
+type Options struct {
+	// Directory contains the notebook files and the exports of scripts, current directory if empty
+	Directory string
+	// MaxDrawnNodes is the maximum number of nodes of a drawn graph, DefaultMaxDrawnNodes if not positive
+	MaxDrawnNodes int
+	// RunTimeout is the maximum duration of a cell run, one minute if not positive
+	RunTimeout time.Duration
+	// Hosts are the accepted hosts (without port) of requests, in addition to loopback ones.
+	// Other hosts are refused, so that pages of other sites cannot reach the server (DNS rebinding)
+	Hosts []string
+}
+
+// Server is a http handler serving the notebook page and running its cells in a session
+type Server struct {
+	// options of the server
+	options Options
+	// session runs the cells
+	session *Session
+	// mux routes requests to handlers
+	mux *http.ServeMux
+}
+
+//go:embed assets
+var assets embed.FS
+
+// NewServer returns a server with an empty session
+func NewServer(options Options) *Server {
+	if options.RunTimeout <= 0 {
+		options.RunTimeout = time.Minute
+	}
+
+	server := &Server{
+		options: options,
+		session: NewSession(options.Directory, options.MaxDrawnNodes),
+		mux:     http.NewServeMux(),
+	}
+
+	content, _ := fs.Sub(assets, "assets")
+	files := http.FileServerFS(content)
+	server.mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			httpjson.WriteError(w, httpjson.NewStatusError(http.StatusMethodNotAllowed, "method %s not allowed for %s", r.Method, r.URL.Path))
+		} else {
+			files.ServeHTTP(w, r)
+		}
+	})
+
+	server.route("POST /api/run", server.run)
+	server.route("POST /api/reset", server.reset)
+	server.route("GET /api/variables", server.variables)
+	server.route("GET /api/help", server.help)
+	server.route("GET /api/notebooks", server.listNotebooks)
+	server.route("GET /api/notebooks/{name}", server.loadNotebook)
+	server.route("PUT /api/notebooks/{name}", server.saveNotebook)
+	server.mux.HandleFunc("/api/", func(w http.ResponseWriter, r *http.Request) {
+		httpjson.WriteError(w, httpjson.NewStatusError(http.StatusNotFound, "no route for %s %s", r.Method, r.URL.Path))
+	})
+
+	return server
+}
+
+// ServeHTTP dispatches the request to the matching handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// handler processes a request, and returns an error instead of writing it
+type handler func(w http.ResponseWriter, r *http.Request) error
+
+// route registers a handler for a pattern, errors are written as json.
+// Requests from other sites are refused: scripts write files
+func (s *Server) route(pattern string, h handler) {
+	s.mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		if err := s.checkOrigin(r); err != nil {
+			httpjson.WriteError(w, err)
+		} else if err := h(w, r); err != nil {
+			httpjson.WriteError(w, err)
+		}
+	})
+}
+
+// checkOrigin returns an error if host of the request is not accepted, or if request comes from a page of another host
+func (s *Server) checkOrigin(r *http.Request) error {
+	host := r.Host
+	if name, _, err := net.SplitHostPort(host); err == nil {
+		host = name
+	}
+
+	host = strings.Trim(host, "[]")
+	accepted := host == "localhost" || strings.HasSuffix(host, ".localhost") || slices.Contains(s.options.Hosts, host)
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		accepted = true
+	}
+
+	if !accepted {
+		return httpjson.NewStatusError(http.StatusForbidden, "host %q not accepted", r.Host)
+	}
+
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		// not sent by browsers for same origin GET requests
+		return nil
+	} else if parsed, err := url.Parse(origin); err != nil || parsed.Host != r.Host {
+		return httpjson.NewStatusError(http.StatusForbidden, "origin %q not accepted", origin)
+	}
+
+	return nil
+}
+
+// RunRequest is the body of a cell run
+type RunRequest struct {
+	// Source is the script of the cell
+	Source string `json:"source"`
+}
+
+// HelpBody is the description of the script language
+type HelpBody struct {
+	// Functions lists the functions scripts may call
+	Functions string `json:"functions"`
+}
+
+// run runs a cell in the session. Script errors are part of the output, not http errors
+func (s *Server) run(w http.ResponseWriter, r *http.Request) error {
+	var request RunRequest
+	if err := readJSON(r, &request); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), s.options.RunTimeout)
+	defer cancel()
+	return httpjson.WriteJSON(w, http.StatusOK, s.session.Run(ctx, request.Source))
+}
+
+// reset removes the variables of the session
+func (s *Server) reset(w http.ResponseWriter, r *http.Request) error {
+	s.session.Reset()
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// variables lists the variables of the session
+func (s *Server) variables(w http.ResponseWriter, r *http.Request) error {
+	return httpjson.WriteJSON(w, http.StatusOK, s.session.Variables())
+}
+
+// help describes the functions of the language
+func (s *Server) help(w http.ResponseWriter, r *http.Request) error {
+	return httpjson.WriteJSON(w, http.StatusOK, HelpBody{Functions: dsl.Help()})
+}
+
+// listNotebooks returns the names of the notebooks of the directory, sorted
+func (s *Server) listNotebooks(w http.ResponseWriter, r *http.Request) error {
+	directory := s.options.Directory
+	if directory == "" {
+		directory = "."
+	}
+
+	entries, errEntries := os.ReadDir(directory)
+	if errEntries != nil {
+		return errEntries
+	}
+
+	names := make([]string, 0)
+	for _, entry := range entries {
+		if name, found := strings.CutSuffix(entry.Name(), notebookExtension); found && !entry.IsDir() && validName.MatchString(name) {
+			names = append(names, name)
+		}
+	}
+
+	slices.Sort(names)
+	return httpjson.WriteJSON(w, http.StatusOK, names)
+}
+
+// loadNotebook returns a notebook file
+func (s *Server) loadNotebook(w http.ResponseWriter, r *http.Request) error {
+	path, errPath := s.notebookPath(r.PathValue("name"))
+	if errPath != nil {
+		return errPath
+	}
+
+	file, errOpen := os.Open(path)
+	if errors.Is(errOpen, fs.ErrNotExist) {
+		return httpjson.NewStatusError(http.StatusNotFound, "no notebook named %q", r.PathValue("name"))
+	} else if errOpen != nil {
+		return errOpen
+	}
+
+	defer file.Close()
+	notebook, errLoad := Load(file)
+	if errLoad != nil {
+		return httpjson.NewStatusError(http.StatusUnprocessableEntity, "%s", errLoad.Error())
+	}
+
+	return httpjson.WriteJSON(w, http.StatusOK, notebook)
+}
+
+// saveNotebook writes a notebook file, replacing any previous version
+func (s *Server) saveNotebook(w http.ResponseWriter, r *http.Request) error {
+	path, errPath := s.notebookPath(r.PathValue("name"))
+	if errPath != nil {
+		return errPath
+	}
+
+	if err := checkContentType(r); err != nil {
+		return err
+	}
+
+	notebook, errLoad := Load(http.MaxBytesReader(w, r.Body, maxBodySize))
+	if errLoad != nil {
+		return httpjson.NewStatusError(http.StatusBadRequest, "%s", errLoad.Error())
+	}
+
+	// content is written in a temporary file first, so that a failure keeps the previous version
+	var content bytes.Buffer
+	if err := Save(&content, notebook); err != nil {
+		return err
+	}
+
+	temporary := path + ".tmp"
+	if err := os.WriteFile(temporary, content.Bytes(), 0o644); err != nil {
+		return err
+	} else if err := os.Rename(temporary, path); err != nil {
+		os.Remove(temporary)
+		return err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// notebookPath returns the path of the file of a notebook
+func (s *Server) notebookPath(name string) (string, error) {
+	if !validName.MatchString(name) {
+		return "", httpjson.NewStatusError(http.StatusBadRequest, "invalid notebook name %q: letters, digits, spaces, _ - and . only", name)
+	}
+
+	return filepath.Join(s.options.Directory, name+notebookExtension), nil
+}
+
+// checkContentType returns an error if body of the request is not json.
+// Browsers send other sites json bodies only after a preflight request, that the server refuses
+func checkContentType(r *http.Request) error {
+	if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mediaType != "application/json" {
+		return httpjson.NewStatusError(http.StatusUnsupportedMediaType, "expected a json body (Content-Type: application/json)")
+	}
+
+	return nil
+}
+
+// readJSON decodes the body of the request into value, unknown fields are errors
+func readJSON(r *http.Request, value any) error {
+	if err := checkContentType(r); err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(http.MaxBytesReader(nil, r.Body, maxBodySize))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(value); err != nil {
+		return httpjson.NewStatusError(http.StatusBadRequest, "invalid body: %s", err.Error())
+	}
+
+	return nil
+}
@@ -0,0 +1,324 @@
+package notebook
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/zefrenchwan/nodz.git/dsl"
+	"github.com/zefrenchwan/nodz.git/graphs"
+	"github.com/zefrenchwan/nodz.git/internal"
+)
+
+// DefaultMaxDrawnNodes is the maximum number of nodes of a drawn graph, if no other value is set
+const DefaultMaxDrawnNodes = 200
+
+// maxSummaryLength is the maximum length of the summary of a variable
+const maxSummaryLength = 200
+
+// Session runs cells one after the other. Variables of a cell are available in the next ones
+type Session struct {
+	// lock ensures that cells run one at a time
+	lock sync.Mutex
+	// directory is the base directory of exports
+	directory string
+	// maxDrawnNodes is the maximum number of nodes of a drawn graph
+	maxDrawnNodes int
+	// interpreter runs the cells and keeps the variables
+	interpreter *dsl.Interpreter
+}
+
+// Variable describes a variable of the session
+type Variable struct {
+	// Name of the variable
+	Name string `json:"name"`
+	// Type is number, string, graph, list or record
+	Type string `json:"type"`
+	// Summary is the value as printed, shortened for long values
+	Summary string `json:"summary"`
+}
+
+// NewSession returns a session with no variable.
+// Exports go to directory (current directory if empty), graphs of more than maxDrawnNodes are not drawn
+func NewSession(directory string, maxDrawnNodes int) *Session {
+	if maxDrawnNodes <= 0 {
+		maxDrawnNodes = DefaultMaxDrawnNodes
+	}
+
+	session := &Session{directory: directory, maxDrawnNodes: maxDrawnNodes}
+	session.reset()
+	return session
+}
+
+// Reset removes all the variables
+func (s *Session) Reset() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.reset()
+}
+
+// reset sets a new interpreter, caller holds the lock
+func (s *Session) reset() {
+	s.interpreter = dsl.NewInterpreter(nil)
+	s.interpreter.Directory = s.directory
+}
+
+// Run runs a cell. Errors of the script are in the output, values printed before an error are kept
+func (s *Session) Run(ctx context.Context, source string) Output {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	result := Output{Displays: make([]Display, 0)}
+	s.interpreter.Printer = func(values []dsl.Value) error {
+		for _, display := range s.render(values) {
+			result.Displays = appendDisplay(result.Displays, display)
+		}
+
+		return nil
+	}
+
+	defer func() { s.interpreter.Printer = nil }()
+	if err := s.interpreter.Run(ctx, source); err != nil {
+		result.Error = newCellError(err)
+	}
+
+	return result
+}
+
+// Variables returns the variables of the session, sorted by name
+func (s *Session) Variables() []Variable {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	names := s.interpreter.Variables()
+	result := make([]Variable, 0, len(names))
+	for _, name := range names {
+		value, _ := s.interpreter.Variable(name)
+		summary := dsl.Format(value)
+		if len(summary) > maxSummaryLength {
+			summary = summary[:maxSummaryLength] + "..."
+		}
+
+		result = append(result, Variable{Name: name, Type: typeName(value), Summary: summary})
+	}
+
+	return result
+}
+
+// newCellError returns the error of a cell, with its position for script errors
+func newCellError(err error) *CellError {
+	var parseErr dsl.ParseError
+	var runtimeErr dsl.RuntimeError
+	switch {
+	case errors.As(err, &parseErr):
+		return &CellError{Message: parseErr.Message, Line: parseErr.Position.Line, Column: parseErr.Position.Column}
+	case errors.As(err, &runtimeErr):
+		return &CellError{Message: runtimeErr.Message, Line: runtimeErr.Position.Line, Column: runtimeErr.Position.Column}
+	default:
+		return &CellError{Message: err.Error()}
+	}
+}
+
+// typeName returns the type of a value, as scripts name it
+func typeName(value dsl.Value) string {
+	switch value.(type) {
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case *dsl.Graph:
+		return "graph"
+	case []dsl.Value:
+		return "list"
+	case dsl.Record:
+		return "record"
+	default:
+		return "nothing"
+	}
+}
+
+// render returns the displays of the values of a print statement.
+// Records (and lists of records) are tables, graphs are drawings, other values are text on a line
+func (s *Session) render(values []dsl.Value) []Display {
+	result := make([]Display, 0)
+	line := make([]string, 0)
+	flush := func() {
+		if len(line) != 0 {
+			result = append(result, Display{Kind: TextDisplay, Text: strings.Join(line, " ")})
+			line = line[:0]
+		}
+	}
+
+	for _, value := range values {
+		if table, ok := recordsTable(value); ok {
+			flush()
+			result = append(result, Display{Kind: TableDisplay, Table: table})
+		} else if g, ok := value.(*dsl.Graph); ok {
+			flush()
+			result = append(result, Display{Kind: GraphDisplay, Text: dsl.Format(g), Graph: s.draw(g)})
+		} else {
+			line = append(line, dsl.Format(value))
+		}
+	}
+
+	flush()
+	return result
+}
+
+// appendDisplay adds a display to the output. Consecutive texts are merged,
+// and so are consecutive tables with the same columns, for statistics printed in loops
+func appendDisplay(displays []Display, display Display) []Display {
+	if len(displays) == 0 {
+		return append(displays, display)
+	}
+
+	last := &displays[len(displays)-1]
+	switch {
+	case last.Kind == TextDisplay && display.Kind == TextDisplay:
+		last.Text += "\n" + display.Text
+	case last.Kind == TableDisplay && display.Kind == TableDisplay && slices.Equal(last.Table.Columns, display.Table.Columns):
+		last.Table.Rows = append(last.Table.Rows, display.Table.Rows...)
+	default:
+		displays = append(displays, display)
+	}
+
+	return displays
+}
+
+// recordsTable returns the table of a record, or of a non empty list of records with the same fields
+func recordsTable(value dsl.Value) (*Table, bool) {
+	var records []dsl.Record
+	switch v := value.(type) {
+	case dsl.Record:
+		records = []dsl.Record{v}
+	case []dsl.Value:
+		for _, element := range v {
+			if record, ok := element.(dsl.Record); !ok {
+				return nil, false
+			} else {
+				records = append(records, record)
+			}
+		}
+	}
+
+	if len(records) == 0 {
+		return nil, false
+	}
+
+	result := &Table{Columns: slices.Clone(records[0].Fields), Rows: make([][]string, 0, len(records))}
+	for _, record := range records {
+		if !slices.Equal(record.Fields, result.Columns) {
+			return nil, false
+		}
+
+		row := make([]string, len(record.Fields))
+		for index, name := range record.Fields {
+			row[index] = dsl.Format(record.Values[name])
+		}
+
+		result.Rows = append(result.Rows, row)
+	}
+
+	return result, true
+}
+
+// draw returns the drawing of a graph, truncated if graph has too many nodes
+func (s *Session) draw(g *dsl.Graph) *Drawing {
+	nodesCount, linksCount := g.Size()
+	result := &Drawing{
+		Directed:   g.Directed(),
+		NodesCount: nodesCount,
+		LinksCount: linksCount,
+		Nodes:      make([]DrawnNode, 0),
+		Links:      make([]DrawnLink, 0),
+	}
+
+	if nodesCount > s.maxDrawnNodes {
+		result.Truncated = true
+		return result
+	}
+
+	nodes, links, err := graphContent(g.Content(), g.Directed())
+	if err != nil {
+		// map graphs of scripts do not fail, but a partial drawing would be misleading
+		result.Truncated = true
+		return result
+	}
+
+	pairs := make([][2]int, len(links))
+	for index, current := range links {
+		pairs[index] = [2]int{current.Source, current.Target}
+	}
+
+	for index, position := range layout(len(nodes), pairs) {
+		result.Nodes = append(result.Nodes, DrawnNode{Id: nodes[index], X: position[0], Y: position[1]})
+	}
+
+	result.Links = links
+	return result
+}
+
+// graphContent returns the ids of the nodes, sorted, and the links once each as indexes of the nodes
+func graphContent(g graphs.CentralStructureGraph[dsl.Node, dsl.Link], directed bool) ([]string, []DrawnLink, error) {
+	all, errAll := g.AllNodes()
+	if errAll != nil {
+		return nil, nil, errAll
+	}
+
+	ids := make([]string, 0)
+	for current, err := range graphs.All(all) {
+		if err != nil {
+			return nil, nil, err
+		}
+
+		ids = append(ids, current.Id())
+	}
+
+	slices.Sort(ids)
+	indexes := make(map[string]int, len(ids))
+	for index, id := range ids {
+		indexes[id] = index
+	}
+
+	links := make([]DrawnLink, 0)
+	seen := make(map[[2]int]bool)
+	for _, id := range ids {
+		neighbors, errNeighbors := g.Neighbors(internal.NewIdNode(id))
+		if errNeighbors != nil {
+			return nil, nil, errNeighbors
+		} else if neighbors == nil {
+			continue
+		}
+
+		it, errIt := neighbors.Links()
+		if errIt != nil {
+			return nil, nil, errIt
+		}
+
+		for current, err := range graphs.All(it) {
+			if err != nil {
+				return nil, nil, err
+			}
+
+			source, target := indexes[current.Source().Id()], indexes[current.Destination().Id()]
+			key := [2]int{source, target}
+			if !directed {
+				key = [2]int{min(source, target), max(source, target)}
+			}
+
+			if !seen[key] {
+				seen[key] = true
+				links = append(links, DrawnLink{Source: source, Target: target, Weight: current.Value()})
+			}
+		}
+	}
+
+	slices.SortFunc(links, func(a, b DrawnLink) int {
+		return cmp.Or(cmp.Compare(a.Source, b.Source), cmp.Compare(a.Target, b.Target))
+	})
+
+	return ids, links, nil
+}
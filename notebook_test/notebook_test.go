@@ -0,0 +1,295 @@
+package notebook_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/zefrenchwan/nodz.git/notebook"
+)
+
+func TestSessionDisplays(t *testing.T) {
+	session := notebook.NewSession(t.TempDir(), 0)
+	output := session.Run(context.Background(), `
+graph g = complete(4)
+print "nodes", nodes(g)
+print "links", links(g)
+print stats(g), g
+for n in [5, 6] { print stats(complete(n)) }
+`)
+
+	if output.Error != nil {
+		t.Fatalf("unexpected error %v", output.Error)
+	} else if len(output.Displays) != 4 {
+		t.Fatalf("unexpected displays %v", output.Displays)
+	}
+
+	if text := output.Displays[0]; text.Kind != notebook.TextDisplay || text.Text != "nodes 4\nlinks 6" {
+		t.Errorf("texts should be merged, got %v", text)
+	}
+
+	if table := output.Displays[1]; table.Kind != notebook.TableDisplay || table.Table.Columns[0] != "nodes" || len(table.Table.Rows) != 1 {
+		t.Errorf("unexpected statistics table %v", table)
+	} else if table.Table.Rows[0][0] != "4" || table.Table.Rows[0][1] != "6" {
+		t.Errorf("unexpected statistics %v", table.Table.Rows)
+	}
+
+	drawing := output.Displays[2]
+	if drawing.Kind != notebook.GraphDisplay || drawing.Text != "graph(undirected, 4 nodes, 6 links)" {
+		t.Errorf("unexpected graph display %v", drawing)
+	} else if g := drawing.Graph; g.Truncated || len(g.Nodes) != 4 || len(g.Links) != 6 || g.NodesCount != 4 {
+		t.Errorf("unexpected drawing %v", g)
+	} else {
+		for _, node := range g.Nodes {
+			if node.X < 0 || node.X > 1 || node.Y < 0 || node.Y > 1 {
+				t.Errorf("node %s out of bounds: %f, %f", node.Id, node.X, node.Y)
+			}
+		}
+	}
+
+	if loop := output.Displays[3]; loop.Kind != notebook.TableDisplay || len(loop.Table.Rows) != 2 {
+		t.Errorf("tables printed in a loop should be merged, got %v", loop)
+	}
+}
+
+func TestSessionVariablesAndErrors(t *testing.T) {
+	session := notebook.NewSession("", 10)
+	if output := session.Run(context.Background(), "graph g directed\nlink g 1 -> 2\nlet big = gnp(11, 0)"); output.Error != nil {
+		t.Fatal(output.Error)
+	}
+
+	output := session.Run(context.Background(), "print g, big\nprint [stats(g), stats(big)]\nprint [1, 2]\nprint missing")
+	if output.Error == nil || output.Error.Line != 4 || output.Error.Column != 7 {
+		t.Errorf("unexpected error %v", output.Error)
+	} else if len(output.Displays) != 4 {
+		t.Fatalf("displays before the error should be kept, got %v", output.Displays)
+	}
+
+	if g := output.Displays[0].Graph; !g.Directed || len(g.Links) != 1 || g.Links[0].Weight != 1 {
+		t.Errorf("unexpected drawing %v", g)
+	} else if big := output.Displays[1].Graph; !big.Truncated || big.NodesCount != 11 || len(big.Nodes) != 0 {
+		t.Errorf("large graph should not be drawn, got %v", big)
+	} else if table := output.Displays[2].Table; table == nil || len(table.Rows) != 2 {
+		t.Errorf("list of records should be a table, got %v", output.Displays[2])
+	} else if output.Displays[3].Text != "[1, 2]" {
+		t.Errorf("unexpected text %v", output.Displays[3])
+	}
+
+	if parse := session.Run(context.Background(), "let = 1"); parse.Error == nil || parse.Error.Line != 1 || parse.Error.Column != 5 {
+		t.Errorf("unexpected parse error %v", parse.Error)
+	}
+
+	variables := session.Variables()
+	if len(variables) != 2 || variables[0].Name != "big" || variables[1].Type != "graph" {
+		t.Errorf("unexpected variables %v", variables)
+	} else if variables[1].Summary != "graph(directed, 2 nodes, 1 links)" {
+		t.Errorf("unexpected summary %q", variables[1].Summary)
+	}
+
+	session.Reset()
+	if variables := session.Variables(); len(variables) != 0 {
+		t.Errorf("reset should remove variables, got %v", variables)
+	}
+}
+
+func TestSessionDrawingIsStable(t *testing.T) {
+	draw := func() *notebook.Drawing {
+		output := notebook.NewSession("", 0).Run(context.Background(), "graph g\nlink g 1 -> 2\nlink g 2 -> 3\nlink g 3 -> 1\nlink g 3 -> 4\nprint g")
+		return output.Displays[0].Graph
+	}
+
+	if first, second := draw(), draw(); !reflect.DeepEqual(first, second) {
+		t.Errorf("same graph should get the same drawing")
+	}
+}
+
+func TestLoadSave(t *testing.T) {
+	source := notebook.Notebook{Cells: []notebook.Cell{
+		{Source: "graph g = complete(3)"},
+		{Source: "print g", Output: &notebook.Output{Displays: []notebook.Display{{Kind: notebook.TextDisplay, Text: "x"}}}},
+	}}
+
+	var content bytes.Buffer
+	if err := notebook.Save(&content, source); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, errLoad := notebook.Load(&content)
+	source.Version = notebook.Version
+	if errLoad != nil {
+		t.Fatal(errLoad)
+	} else if !reflect.DeepEqual(loaded, source) {
+		t.Errorf("unexpected notebook %v", loaded)
+	}
+
+	for _, invalid := range []string{`{"cells": []}`, `{"version": 2, "cells": []}`, `{"version": 1, "cells": [], "extra": 1}`, `[`} {
+		if _, err := notebook.Load(strings.NewReader(invalid)); err == nil {
+			t.Errorf("%s should be invalid", invalid)
+		}
+	}
+}
+
+// call sends a request to handler from the notebook page, and returns status and body
+func call(handler http.Handler, method, path, body string) (int, string) {
+	return callWith(handler, method, path, body, map[string]string{"Host": "localhost:8888", "Content-Type": "application/json"})
+}
+
+// callWith sends a request with headers to handler, and returns status and body
+func callWith(handler http.Handler, method, path, body string, headers map[string]string) (int, string) {
+	request := httptest.NewRequest(method, path, strings.NewReader(body))
+	for name, value := range headers {
+		if name == "Host" {
+			request.Host = value
+		} else {
+			request.Header.Set(name, value)
+		}
+	}
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	content, _ := io.ReadAll(recorder.Result().Body)
+	return recorder.Code, string(content)
+}
+
+func TestServerPage(t *testing.T) {
+	server := notebook.NewServer(notebook.Options{})
+	for _, path := range []string{"/", "/notebook.js", "/notebook.css"} {
+		status, content := call(server, http.MethodGet, path, "")
+		if status != http.StatusOK || content == "" {
+			t.Errorf("%s: unexpected status %d", path, status)
+		}
+
+		// no external resource: the only url is the svg namespace
+		withoutNamespace := strings.ReplaceAll(content, "http://www.w3.org/2000/svg", "")
+		if strings.Contains(withoutNamespace, "http://") || strings.Contains(withoutNamespace, "https://") || strings.Contains(withoutNamespace, "//cdn") {
+			t.Errorf("%s should not load external resources", path)
+		}
+	}
+
+	if status, content := call(server, http.MethodGet, "/api/unknown", ""); status != http.StatusNotFound || !strings.Contains(content, "no route") {
+		t.Errorf("unexpected status %d: %s", status, content)
+	} else if status, content := call(server, http.MethodGet, "/api/help", ""); status != http.StatusOK || !strings.Contains(content, "gnp") {
+		t.Errorf("unexpected help %d: %s", status, content)
+	}
+}
+
+func TestServerRuns(t *testing.T) {
+	server := notebook.NewServer(notebook.Options{Directory: t.TempDir()})
+	status, content := call(server, http.MethodPost, "/api/run", `{"source": "graph g = complete(3)\nprint stats(g).nodes"}`)
+	var output notebook.Output
+	if status != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", status, content)
+	} else if err := json.Unmarshal([]byte(content), &output); err != nil {
+		t.Fatal(err)
+	} else if output.Error != nil || len(output.Displays) != 1 || output.Displays[0].Text != "3" {
+		t.Errorf("unexpected output %s", content)
+	}
+
+	// script errors are outputs, not http errors
+	status, content = call(server, http.MethodPost, "/api/run", `{"source": "print x"}`)
+	if status != http.StatusOK || !strings.Contains(content, `"line":1`) {
+		t.Errorf("unexpected status %d: %s", status, content)
+	}
+
+	if status, _ := call(server, http.MethodPost, "/api/run", `{"script": "print 1"}`); status != http.StatusBadRequest {
+		t.Errorf("unknown fields should be rejected, got %d", status)
+	}
+
+	if _, content := call(server, http.MethodGet, "/api/variables", ""); !strings.Contains(content, `"name":"g"`) {
+		t.Errorf("unexpected variables %s", content)
+	} else if status, _ := call(server, http.MethodPost, "/api/reset", ""); status != http.StatusNoContent {
+		t.Errorf("unexpected reset status %d", status)
+	} else if _, content := call(server, http.MethodGet, "/api/variables", ""); strings.TrimSpace(content) != "[]" {
+		t.Errorf("reset should remove variables, got %s", content)
+	}
+}
+
+func TestServerNotebooks(t *testing.T) {
+	directory := t.TempDir()
+	server := notebook.NewServer(notebook.Options{Directory: directory})
+	document := `{"version": 1, "cells": [{"source": "graph g"}]}`
+	if status, content := call(server, http.MethodPut, "/api/notebooks/first%20try", document); status != http.StatusNoContent {
+		t.Fatalf("unexpected status %d: %s", status, content)
+	} else if _, err := os.Stat(filepath.Join(directory, "first try.notebook.json")); err != nil {
+		t.Errorf("notebook file should exist: %v", err)
+	}
+
+	if _, content := call(server, http.MethodGet, "/api/notebooks", ""); strings.TrimSpace(content) != `["first try"]` {
+		t.Errorf("unexpected notebooks %s", content)
+	}
+
+	status, content := call(server, http.MethodGet, "/api/notebooks/first%20try", "")
+	var loaded notebook.Notebook
+	if status != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", status, content)
+	} else if err := json.Unmarshal([]byte(content), &loaded); err != nil {
+		t.Fatal(err)
+	} else if len(loaded.Cells) != 1 || loaded.Cells[0].Source != "graph g" {
+		t.Errorf("unexpected notebook %s", content)
+	}
+
+	cases := []struct {
+		method, path, body string
+		status             int
+	}{
+		{http.MethodGet, "/api/notebooks/missing", "", http.StatusNotFound},
+		{http.MethodGet, "/api/notebooks/.hidden", "", http.StatusBadRequest},
+		{http.MethodPut, "/api/notebooks/%2E%2E%2Fescape", document, http.StatusBadRequest},
+		{http.MethodPut, "/api/notebooks/invalid", `{"version": 3, "cells": []}`, http.StatusBadRequest},
+	}
+
+	for _, c := range cases {
+		if status, content := call(server, c.method, c.path, c.body); status != c.status {
+			t.Errorf("%s %s: expected %d, got %d: %s", c.method, c.path, c.status, status, content)
+		}
+	}
+
+	if err := os.WriteFile(filepath.Join(directory, "broken.notebook.json"), []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	} else if status, _ := call(server, http.MethodGet, "/api/notebooks/broken", ""); status != http.StatusUnprocessableEntity {
+		t.Errorf("broken notebook should be unprocessable, got %d", status)
+	}
+}
+
+func TestServerRefusesOtherSites(t *testing.T) {
+	parent := t.TempDir()
+	directory := filepath.Join(parent, "notebooks")
+	if err := os.Mkdir(directory, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	server := notebook.NewServer(notebook.Options{Directory: directory, Hosts: []string{"graphs.internal"}})
+	body := `{"source": "graph g = complete(3)\nexport g \"../escaped.json\""}`
+	cases := []struct {
+		headers map[string]string
+		status  int
+	}{
+		{map[string]string{"Host": "localhost:8888", "Origin": "http://evil.example", "Content-Type": "application/json"}, http.StatusForbidden},
+		{map[string]string{"Host": "localhost:8888", "Origin": "http://localhost:8888", "Content-Type": "text/plain"}, http.StatusUnsupportedMediaType},
+		{map[string]string{"Host": "evil.example:8888", "Content-Type": "application/json"}, http.StatusForbidden},
+		{map[string]string{"Host": "127.0.0.1:8888", "Origin": "http://127.0.0.1:8888", "Content-Type": "application/json; charset=utf-8"}, http.StatusOK},
+		{map[string]string{"Host": "graphs.internal:8888", "Content-Type": "application/json"}, http.StatusOK},
+	}
+
+	for _, c := range cases {
+		if status, content := callWith(server, http.MethodPost, "/api/run", body, c.headers); status != c.status {
+			t.Errorf("%v: expected %d, got %d: %s", c.headers, c.status, status, content)
+		} else if status == http.StatusOK && !strings.Contains(content, "outside") {
+			t.Errorf("export outside of directory should fail, got %s", content)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(parent, "escaped.json")); err == nil {
+		t.Error("no file should be written outside of directory")
+	} else if status, _ := callWith(server, http.MethodGet, "/api/variables", "", map[string]string{"Host": "evil.example"}); status != http.StatusForbidden {
+		t.Errorf("other hosts should not read variables, got %d", status)
+	}
+}
@@ -0,0 +1,562 @@
+package server
+
+import (
+	"bytes"
+	"cmp"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/zefrenchwan/nodz.git/graphs"
+	"github.com/zefrenchwan/nodz.git/internal"
+	"github.com/zefrenchwan/nodz.git/internal/httpjson"
+	"github.com/zefrenchwan/nodz.git/internal/local"
+	"github.com/zefrenchwan/nodz.git/storage/gexf"
+	"github.com/zefrenchwan/nodz.git/storage/jsongraph"
+)
+
+// GraphInfo describes a named graph
+type GraphInfo struct {
+	// Name of the graph
+	Name string `json:"name"`
+	// Directed is true if links of the graph are directed
+	Directed bool `json:"directed"`
+	// Nodes is the number of nodes
+	Nodes int `json:"nodes"`
+	// Links is the number of links, undirected links count once
+	Links int `json:"links"`
+}
+
+// CreateRequest is the body to create a graph
+type CreateRequest struct {
+	// Name of the graph, unique
+	Name string `json:"name"`
+	// Directed is true for a graph of directed links
+	Directed bool `json:"directed"`
+}
+
+// GenerateRequest is the body to replace the content of a graph with a random one
+type GenerateRequest struct {
+	// Model is either "gnp" or "ba" (Barabasi Albert)
+	Model string `json:"model"`
+	// Size is the number of nodes
+	Size int `json:"size"`
+	// Probability is the linking probability, for gnp
+	Probability float64 `json:"probability"`
+	// Initial is the size of the initial complete graph, for ba
+	Initial int `json:"initial"`
+}
+
+// NodeBody is a node, as read and written by the server
+type NodeBody struct {
+	// Id of the node. When adding a node, an empty id means a random one
+	Id string `json:"id"`
+}
+
+// LinkBody is a link, as read and written by the server
+type LinkBody struct {
+	// Source is the id of the source node
+	Source string `json:"source"`
+	// Target is the id of the destination node
+	Target string `json:"target"`
+	// Weight of the link, 1.0 if not set
+	Weight *float64 `json:"weight,omitempty"`
+	// Directed is set by the server, depending on the graph
+	Directed bool `json:"directed"`
+}
+
+// NeighborhoodBody is the neighborhood of a node, with a page of its links
+type NeighborhoodBody struct {
+	// Node is the id of the center node
+	Node string `json:"node"`
+	// Incoming is the incoming degree
+	Incoming int64 `json:"incoming"`
+	// Outgoing is the outgoing degree
+	Outgoing int64 `json:"outgoing"`
+	// Undirected is the undirected degree
+	Undirected int64 `json:"undirected"`
+	// Links are the outgoing and undirected links of the node
+	Links Page[LinkBody] `json:"links"`
+}
+
+// StatisticsBody is the NetworkStatistics of a graph, with derived values
+type StatisticsBody struct {
+	// Nodes is the number of nodes
+	Nodes int64 `json:"nodes"`
+	// DirectedLinks is the number of directed links
+	DirectedLinks int64 `json:"directedLinks"`
+	// UndirectedLinks is the number of undirected links
+	UndirectedLinks int64 `json:"undirectedLinks"`
+	// DegreeDistribution is the normalized degree distribution (all links of a node count)
+	DegreeDistribution map[int64]float64 `json:"degreeDistribution"`
+	// AverageDegree depends on the graph, directed or not. -1 for an empty graph
+	AverageDegree float64 `json:"averageDegree"`
+	// Density depends on the graph, directed or not
+	Density float64 `json:"density"`
+}
+
+// ComponentsBody describes the connected components of an undirected graph
+type ComponentsBody struct {
+	// Count is the number of connected components
+	Count int `json:"count"`
+	// Sizes are the sizes of the components, largest first
+	Sizes []int64 `json:"sizes"`
+}
+
+// info returns the description of a graph, caller holds the lock
+func (ng *namedGraph) info(name string) GraphInfo {
+	result := GraphInfo{Name: name, Directed: ng.directed}
+	for range ng.graph.All() {
+		result.Nodes++
+	}
+
+	for range ng.graph.Links() {
+		result.Links++
+	}
+
+	return result
+}
+
+// findLink returns the link from source to target (either way for undirected graphs), caller holds the lock
+func (ng *namedGraph) findLink(source, target node) (link, bool, error) {
+	return graphs.FindLinkFunc(ng.graph, source, func(current link) bool {
+		return current.Source().SameNode(source) && current.Destination().SameNode(target) ||
+			!ng.directed && current.Source().SameNode(target) && current.Destination().SameNode(source)
+	})
+}
+
+// newLink returns a link of the graph type
+func (ng *namedGraph) newLink(source, target node, weight float64) link {
+	if ng.directed {
+		return internal.NewDirectedValuedLink(source, target, weight)
+	}
+
+	return internal.NewUndirectedValuedLink(source, target, weight)
+}
+
+// listGraphs returns the description of all graphs, sorted by name
+func (s *Server) listGraphs(w http.ResponseWriter, r *http.Request) error {
+	s.lock.RLock()
+	names := make([]string, 0, len(s.graphs))
+	for name := range s.graphs {
+		names = append(names, name)
+	}
+	s.lock.RUnlock()
+
+	slices.Sort(names)
+	result := make([]GraphInfo, 0, len(names))
+	for _, name := range names {
+		// graph may be deleted in between, just skip it
+		if graph, err := s.lookup(name); err == nil {
+			graph.lock.RLock()
+			result = append(result, graph.info(name))
+			graph.lock.RUnlock()
+		}
+	}
+
+	return httpjson.WriteJSON(w, http.StatusOK, result)
+}
+
+// createGraph creates an empty graph
+func (s *Server) createGraph(w http.ResponseWriter, r *http.Request) error {
+	var request CreateRequest
+	if err := readJSON(r, &request); err != nil {
+		return err
+	} else if request.Name == "" {
+		return httpjson.NewStatusError(http.StatusBadRequest, "missing graph name")
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, found := s.graphs[request.Name]; found {
+		return httpjson.NewStatusError(http.StatusConflict, "graph %q already exists", request.Name)
+	}
+
+	graph := local.NewMapGraph[node, link]()
+	s.graphs[request.Name] = &namedGraph{directed: request.Directed, graph: &graph}
+	return httpjson.WriteJSON(w, http.StatusCreated, GraphInfo{Name: request.Name, Directed: request.Directed})
+}
+
+// getGraph returns the description of a graph
+func (s *Server) getGraph(w http.ResponseWriter, r *http.Request) error {
+	name := r.PathValue("name")
+	graph, errGraph := s.lookup(name)
+	if errGraph != nil {
+		return errGraph
+	}
+
+	graph.lock.RLock()
+	defer graph.lock.RUnlock()
+	return httpjson.WriteJSON(w, http.StatusOK, graph.info(name))
+}
+
+// deleteGraph removes a graph
+func (s *Server) deleteGraph(w http.ResponseWriter, r *http.Request) error {
+	name := r.PathValue("name")
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, found := s.graphs[name]; !found {
+		return httpjson.NewStatusError(http.StatusNotFound, "no graph named %q", name)
+	}
+
+	delete(s.graphs, name)
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// generate replaces the content of a graph with a random graph
+func (s *Server) generate(w http.ResponseWriter, r *http.Request) error {
+	name := r.PathValue("name")
+	graph, errGraph := s.lookup(name)
+	if errGraph != nil {
+		return errGraph
+	}
+
+	var request GenerateRequest
+	if err := readJSON(r, &request); err != nil {
+		return err
+	} else if request.Size < 0 || request.Size > s.options.MaxNodes {
+		return httpjson.NewStatusError(http.StatusBadRequest, "invalid size %d, expecting 0 to %d", request.Size, s.options.MaxNodes)
+	}
+
+	// nodes are named by their creation index, easier to read than random ids
+	counter := 0
+	nodeGenerator := func() node {
+		counter++
+		return internal.NewIdNode(strconv.Itoa(counter - 1))
+	}
+
+	linkGenerator := func(source, target node) link {
+		return graph.newLink(source, target, 1.0)
+	}
+
+	var generator local.RandomGenerator[node, link]
+	var result graphs.CentralStructureGraph[node, link]
+	var errGenerate error
+	switch request.Model {
+	case "gnp":
+		if graph.directed {
+			result, errGenerate = generator.DirectedGNPContext(r.Context(), request.Size, request.Probability, nodeGenerator, linkGenerator)
+		} else {
+			result, errGenerate = generator.UndirectedGNPContext(r.Context(), request.Size, request.Probability, nodeGenerator, linkGenerator)
+		}
+	case "ba":
+		if graph.directed {
+			return httpjson.NewStatusError(http.StatusBadRequest, "barabasi albert graphs are undirected, graph %q is directed", name)
+		}
+
+		result, errGenerate = generator.UndirectedBarabasiAlbertGraphContext(r.Context(), request.Initial, request.Size, nodeGenerator, linkGenerator)
+	default:
+		return httpjson.NewStatusError(http.StatusBadRequest, "unknown model %q, expecting gnp or ba", request.Model)
+	}
+
+	if errGenerate != nil {
+		return httpjson.NewStatusError(http.StatusBadRequest, "generation failed: %s", errGenerate.Error())
+	}
+
+	generated, ok := result.(*local.MapGraph[node, link])
+	if !ok {
+		return httpjson.NewStatusError(http.StatusInternalServerError, "unexpected generated graph")
+	}
+
+	graph.lock.Lock()
+	defer graph.lock.Unlock()
+	graph.graph = generated
+	return httpjson.WriteJSON(w, http.StatusOK, graph.info(name))
+}
+
+// listNodes returns a page of the nodes ids, sorted
+func (s *Server) listNodes(w http.ResponseWriter, r *http.Request) error {
+	graph, errGraph := s.lookup(r.PathValue("name"))
+	if errGraph != nil {
+		return errGraph
+	}
+
+	graph.lock.RLock()
+	ids := make([]string, 0)
+	for current := range graph.graph.All() {
+		ids = append(ids, current.Id())
+	}
+	graph.lock.RUnlock()
+
+	slices.Sort(ids)
+	page, errPage := paginate(r, s.options.MaxPageSize, ids)
+	if errPage != nil {
+		return errPage
+	}
+
+	return httpjson.WriteJSON(w, http.StatusOK, page)
+}
+
+// addNode adds a node, with a random id if none is provided
+func (s *Server) addNode(w http.ResponseWriter, r *http.Request) error {
+	graph, errGraph := s.lookup(r.PathValue("name"))
+	if errGraph != nil {
+		return errGraph
+	}
+
+	var request NodeBody
+	if err := readJSON(r, &request); err != nil {
+		return err
+	}
+
+	newNode := internal.NewRandomIdNode()
+	if request.Id != "" {
+		newNode = internal.NewIdNode(request.Id)
+	}
+
+	graph.lock.Lock()
+	defer graph.lock.Unlock()
+	if found, _ := graphs.HasNode(graph.graph, newNode); found {
+		return httpjson.NewStatusError(http.StatusConflict, "node %q already exists", newNode.Id())
+	} else if err := graph.graph.AddNode(newNode); err != nil {
+		return err
+	}
+
+	return httpjson.WriteJSON(w, http.StatusCreated, NodeBody{Id: newNode.Id()})
+}
+
+// removeNode removes a node and its links
+func (s *Server) removeNode(w http.ResponseWriter, r *http.Request) error {
+	graph, errGraph := s.lookup(r.PathValue("name"))
+	if errGraph != nil {
+		return errGraph
+	}
+
+	id := r.PathValue("id")
+	graph.lock.Lock()
+	defer graph.lock.Unlock()
+	if found, _ := graphs.HasNode(graph.graph, internal.NewIdNode(id)); !found {
+		return httpjson.NewStatusError(http.StatusNotFound, "no node %q", id)
+	} else if err := graph.graph.RemoveNode(internal.NewIdNode(id)); err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// neighbors returns the neighborhood of a node, with a page of its links
+func (s *Server) neighbors(w http.ResponseWriter, r *http.Request) error {
+	graph, errGraph := s.lookup(r.PathValue("name"))
+	if errGraph != nil {
+		return errGraph
+	}
+
+	id := r.PathValue("id")
+	graph.lock.RLock()
+	neighborhood, errNeighbors := graph.graph.Neighbors(internal.NewIdNode(id))
+	if errNeighbors != nil {
+		graph.lock.RUnlock()
+		return errNeighbors
+	} else if neighborhood == nil {
+		graph.lock.RUnlock()
+		return httpjson.NewStatusError(http.StatusNotFound, "no node %q", id)
+	}
+
+	result := NeighborhoodBody{
+		Node:       id,
+		Incoming:   neighborhood.IncomingDegree(),
+		Outgoing:   neighborhood.OutgoingDegree(),
+		Undirected: neighborhood.UndirectedDegree(),
+	}
+
+	it, errIt := neighborhood.Links()
+	if errIt != nil {
+		graph.lock.RUnlock()
+		return errIt
+	}
+
+	links, errLinks := graphs.Collect(graphs.Map(it, toLinkBody, graphs.FailFast), graphs.FailFast)
+	graph.lock.RUnlock()
+	if errLinks != nil {
+		return errLinks
+	}
+
+	slices.SortFunc(links, func(a, b LinkBody) int {
+		return cmp.Or(cmp.Compare(a.Source, b.Source), cmp.Compare(a.Target, b.Target))
+	})
+
+	page, errPage := paginate(r, s.options.MaxPageSize, links)
+	if errPage != nil {
+		return errPage
+	}
+
+	result.Links = page
+	return httpjson.WriteJSON(w, http.StatusOK, result)
+}
+
+// addLink adds a link between existing nodes. There is at most one link between two nodes
+func (s *Server) addLink(w http.ResponseWriter, r *http.Request) error {
+	graph, errGraph := s.lookup(r.PathValue("name"))
+	if errGraph != nil {
+		return errGraph
+	}
+
+	var request LinkBody
+	if err := readJSON(r, &request); err != nil {
+		return err
+	} else if request.Source == "" || request.Target == "" {
+		return httpjson.NewStatusError(http.StatusBadRequest, "missing source or target")
+	}
+
+	weight := 1.0
+	if request.Weight != nil {
+		weight = *request.Weight
+	}
+
+	source, target := internal.NewIdNode(request.Source), internal.NewIdNode(request.Target)
+	graph.lock.Lock()
+	defer graph.lock.Unlock()
+	if found, _ := graphs.HasNode(graph.graph, source); !found {
+		return httpjson.NewStatusError(http.StatusNotFound, "no node %q", request.Source)
+	} else if found, _ := graphs.HasNode(graph.graph, target); !found {
+		return httpjson.NewStatusError(http.StatusNotFound, "no node %q", request.Target)
+	} else if _, found, err := graph.findLink(source, target); err != nil {
+		return err
+	} else if found {
+		return httpjson.NewStatusError(http.StatusConflict, "link from %q to %q already exists", request.Source, request.Target)
+	}
+
+	newLink := graph.newLink(source, target, weight)
+	if err := graph.graph.AddLink(newLink); err != nil {
+		return err
+	}
+
+	body, _ := toLinkBody(newLink)
+	return httpjson.WriteJSON(w, http.StatusCreated, body)
+}
+
+// removeLink removes the link between two nodes
+func (s *Server) removeLink(w http.ResponseWriter, r *http.Request) error {
+	graph, errGraph := s.lookup(r.PathValue("name"))
+	if errGraph != nil {
+		return errGraph
+	}
+
+	sourceId, targetId := r.PathValue("source"), r.PathValue("target")
+	graph.lock.Lock()
+	defer graph.lock.Unlock()
+	existing, found, errFind := graph.findLink(internal.NewIdNode(sourceId), internal.NewIdNode(targetId))
+	if errFind != nil {
+		return errFind
+	} else if !found {
+		return httpjson.NewStatusError(http.StatusNotFound, "no link from %q to %q", sourceId, targetId)
+	} else if err := graph.graph.RemoveLink(existing); err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// statistics returns the network statistics of a graph
+func (s *Server) statistics(w http.ResponseWriter, r *http.Request) error {
+	graph, errGraph := s.lookup(r.PathValue("name"))
+	if errGraph != nil {
+		return errGraph
+	}
+
+	counter := func(n graphs.Neighborhood[node, link]) int64 {
+		return n.IncomingDegree() + n.OutgoingDegree() + n.UndirectedDegree()
+	}
+
+	graph.lock.RLock()
+	stats, errStats := graphs.CalculateNetworkStatisticsContext(r.Context(), graph.graph, counter)
+	graph.lock.RUnlock()
+	if errStats != nil {
+		return errStats
+	}
+
+	result := StatisticsBody{
+		Nodes:              stats.NodesSize,
+		DirectedLinks:      stats.DirectedSize,
+		UndirectedLinks:    stats.UndirectedSize,
+		DegreeDistribution: stats.DegreeDistribution,
+	}
+
+	if graph.directed {
+		result.AverageDegree, result.Density = stats.AverageDirectedDegree(), stats.DirectedDensity()
+	} else {
+		result.AverageDegree, result.Density = stats.AverageUndirectedDegree(), stats.UndirectedDensity()
+	}
+
+	return httpjson.WriteJSON(w, http.StatusOK, result)
+}
+
+// components returns the sizes of the connected components of an undirected graph
+func (s *Server) components(w http.ResponseWriter, r *http.Request) error {
+	name := r.PathValue("name")
+	graph, errGraph := s.lookup(name)
+	if errGraph != nil {
+		return errGraph
+	} else if graph.directed {
+		return httpjson.NewStatusError(http.StatusBadRequest, "connected components apply to undirected graphs, graph %q is directed", name)
+	}
+
+	graph.lock.RLock()
+	stats, errStats := graphs.ParallelConnectedComponentsSize(graph.graph, graphs.ParallelOptions[node]{})
+	graph.lock.RUnlock()
+	if errStats != nil {
+		return errStats
+	}
+
+	result := ComponentsBody{Count: len(stats), Sizes: make([]int64, 0, len(stats))}
+	for _, size := range stats {
+		result.Sizes = append(result.Sizes, size)
+	}
+
+	slices.SortFunc(result.Sizes, func(a, b int64) int { return cmp.Compare(b, a) })
+	return httpjson.WriteJSON(w, http.StatusOK, result)
+}
+
+// export downloads the graph, format is gexf, json (networkx node link) or jgf (JSON Graph Format)
+func (s *Server) export(w http.ResponseWriter, r *http.Request) error {
+	name := r.PathValue("name")
+	graph, errGraph := s.lookup(name)
+	if errGraph != nil {
+		return errGraph
+	}
+
+	options := jsongraph.Options[node, link]{
+		Info:    jsongraph.GraphInfo{Directed: graph.directed},
+		Weigher: func(l link) float64 { return l.Value() },
+	}
+
+	// content is written in a buffer first, so that an error is still a json error
+	var content bytes.Buffer
+	var contentType, extension string
+	var errExport error
+	graph.lock.RLock()
+	switch format := r.URL.Query().Get("format"); format {
+	case "gexf", "":
+		contentType, extension = "application/gexf+xml", "gexf"
+		errExport = gexf.WriteDataGraphContext(r.Context(), &content, graph.graph, gexf.GexfIdNodeExporter, gexf.GexfLinkBasicSerializer)
+	case "json":
+		contentType, extension = "application/json", "json"
+		errExport = jsongraph.WriteNodeLink(&content, graph.graph, options)
+	case "jgf":
+		contentType, extension = "application/json", "jgf.json"
+		errExport = jsongraph.WriteJGF(&content, graph.graph, options)
+	default:
+		errExport = httpjson.NewStatusError(http.StatusBadRequest, "unknown format %q, expecting gexf, json or jgf", format)
+	}
+	graph.lock.RUnlock()
+
+	if errExport != nil {
+		return errExport
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", "attachment; filename="+strconv.Quote(name+"."+extension))
+	w.WriteHeader(http.StatusOK)
+	// headers are sent, nothing to do if client is gone
+	w.Write(content.Bytes())
+	return nil
+}
+
+// toLinkBody maps a link to its json representation
+func toLinkBody(l link) (LinkBody, error) {
+	weight := l.Value()
+	return LinkBody{Source: l.Source().Id(), Target: l.Destination().Id(), Weight: &weight, Directed: l.IsDirected()}, nil
+}
@@ -0,0 +1,179 @@
+package server
+
+import (
+	_ "embed"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+
+	"github.com/zefrenchwan/nodz.git/internal"
+	"github.com/zefrenchwan/nodz.git/internal/httpjson"
+	"github.com/zefrenchwan/nodz.git/internal/local"
+)
+
+// node is the node of the graphs the server manages: nodes are identified by their id
+type node = internal.IdNode
+
+// link is the link of the graphs the server manages: directed or not, with a weight
+type link = internal.ValuedLink[node, float64]
+
+// Options configures the server
+type Options struct {
+	// MaxNodes is the maximum number of nodes of a generated graph. 100000 if not positive
+	MaxNodes int
+	// MaxPageSize is the maximum number of elements of a page. 1000 if not positive
+	MaxPageSize int
+}
+
+// DefaultPageSize is the size of a page when request does not set any limit
+const DefaultPageSize = 100
+
+// namedGraph is a graph managed by the server
+type namedGraph struct {
+	// lock protects graph, changes take a write lock
+	lock sync.RWMutex
+	// directed is true if links of the graph are directed
+	directed bool
+	// graph is the content of the graph
+	graph *local.MapGraph[node, link]
+}
+
+// Server is a http handler managing named in memory graphs.
+// Routes are described in the OpenAPI document, served at /openapi.json
+type Server struct {
+	// options of the server
+	options Options
+	// lock protects graphs
+	lock sync.RWMutex
+	// graphs are the graphs by name
+	graphs map[string]*namedGraph
+	// mux routes requests to handlers
+	mux *http.ServeMux
+}
+
+//go:embed openapi.json
+var openAPIDocument []byte
+
+// NewServer returns a server with no graph
+func NewServer(options Options) *Server {
+	if options.MaxNodes <= 0 {
+		options.MaxNodes = 100000
+	}
+
+	if options.MaxPageSize <= 0 {
+		options.MaxPageSize = 1000
+	}
+
+	server := &Server{options: options, graphs: make(map[string]*namedGraph), mux: http.NewServeMux()}
+	server.mux.HandleFunc("GET /openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(openAPIDocument)
+	})
+
+	server.route("GET /graphs", server.listGraphs)
+	server.route("POST /graphs", server.createGraph)
+	server.route("GET /graphs/{name}", server.getGraph)
+	server.route("DELETE /graphs/{name}", server.deleteGraph)
+	server.route("POST /graphs/{name}/generate", server.generate)
+	server.route("GET /graphs/{name}/nodes", server.listNodes)
+	server.route("POST /graphs/{name}/nodes", server.addNode)
+	server.route("DELETE /graphs/{name}/nodes/{id}", server.removeNode)
+	server.route("GET /graphs/{name}/nodes/{id}/neighbors", server.neighbors)
+	server.route("POST /graphs/{name}/links", server.addLink)
+	server.route("DELETE /graphs/{name}/links/{source}/{target}", server.removeLink)
+	server.route("GET /graphs/{name}/stats", server.statistics)
+	server.route("GET /graphs/{name}/components", server.components)
+	server.route("GET /graphs/{name}/export", server.export)
+	server.mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		httpjson.WriteError(w, httpjson.NewStatusError(http.StatusNotFound, "no route for %s %s", r.Method, r.URL.Path))
+	})
+
+	return server
+}
+
+// ServeHTTP dispatches the request to the matching handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// handler processes a request, and returns an error instead of writing it
+type handler func(w http.ResponseWriter, r *http.Request) error
+
+// route registers a handler for a pattern, errors are written as json
+func (s *Server) route(pattern string, h handler) {
+	s.mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		if err := h(w, r); err != nil {
+			httpjson.WriteError(w, err)
+		}
+	})
+}
+
+// lookup returns the graph with that name, or a not found error
+func (s *Server) lookup(name string) (*namedGraph, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	if graph, found := s.graphs[name]; found {
+		return graph, nil
+	}
+
+	return nil, httpjson.NewStatusError(http.StatusNotFound, "no graph named %q", name)
+}
+
+// readJSON decodes the body of the request into value, unknown fields are errors
+func readJSON(r *http.Request, value any) error {
+	decoder := json.NewDecoder(http.MaxBytesReader(nil, r.Body, 1<<20))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(value); err != nil {
+		return httpjson.NewStatusError(http.StatusBadRequest, "invalid body: %s", err.Error())
+	}
+
+	return nil
+}
+
+// Page is a page of elements, as returned by paginated routes
+type Page[T any] struct {
+	// Items are the elements of the page
+	Items []T `json:"items"`
+	// Offset is the index of the first element of the page
+	Offset int `json:"offset"`
+	// Limit is the maximum size of the page
+	Limit int `json:"limit"`
+	// Total is the number of elements of all the pages
+	Total int `json:"total"`
+	// Next is the offset of the next page, nil for the last page
+	Next *int `json:"next"`
+}
+
+// paginate returns the page of values matching offset and limit query parameters
+func paginate[T any](r *http.Request, maxSize int, values []T) (Page[T], error) {
+	var result Page[T]
+	offset, limit := 0, DefaultPageSize
+	if raw := r.URL.Query().Get("offset"); raw != "" {
+		if value, err := strconv.Atoi(raw); err != nil || value < 0 {
+			return result, httpjson.NewStatusError(http.StatusBadRequest, "invalid offset %q", raw)
+		} else {
+			offset = value
+		}
+	}
+
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		if value, err := strconv.Atoi(raw); err != nil || value <= 0 || value > maxSize {
+			return result, httpjson.NewStatusError(http.StatusBadRequest, "invalid limit %q, expecting 1 to %d", raw, maxSize)
+		} else {
+			limit = value
+		}
+	}
+
+	// no offset+limit, it may overflow
+	start := min(offset, len(values))
+	end := start + min(limit, len(values)-start)
+	result.Items = slices.Clone(values[start:end])
+	result.Offset, result.Limit, result.Total = offset, limit, len(values)
+	if end < len(values) {
+		result.Next = &end
+	}
+
+	return result, nil
+}
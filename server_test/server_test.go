@@ -0,0 +1,254 @@
+package server_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/zefrenchwan/nodz.git/server"
+)
+
+// call sends a request to handler and returns the response
+func call(t *testing.T, handler http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(method, path, reader))
+	return recorder
+}
+
+// decode reads the json body of a response, and checks its status
+func decode[T any](t *testing.T, response *httptest.ResponseRecorder, status int) T {
+	t.Helper()
+	var result T
+	if response.Code != status {
+		t.Fatalf("expected status %d, got %d: %s", status, response.Code, response.Body.String())
+	} else if err := json.Unmarshal(response.Body.Bytes(), &result); err != nil {
+		t.Fatalf("invalid json %q: %s", response.Body.String(), err)
+	}
+
+	return result
+}
+
+func TestServerGraphLifecycle(t *testing.T) {
+	s := server.NewServer(server.Options{})
+	decode[server.GraphInfo](t, call(t, s, "POST", "/graphs", `{"name":"g"}`), http.StatusCreated)
+	decode[map[string]any](t, call(t, s, "POST", "/graphs", `{"name":"g"}`), http.StatusConflict)
+	decode[map[string]any](t, call(t, s, "POST", "/graphs", `{"name":""}`), http.StatusBadRequest)
+
+	for _, id := range []string{"a", "b", "c"} {
+		decode[server.NodeBody](t, call(t, s, "POST", "/graphs/g/nodes", `{"id":"`+id+`"}`), http.StatusCreated)
+	}
+
+	if random := decode[server.NodeBody](t, call(t, s, "POST", "/graphs/g/nodes", `{}`), http.StatusCreated); random.Id == "" {
+		t.Error("expected a random id")
+	}
+
+	decode[map[string]any](t, call(t, s, "POST", "/graphs/g/nodes", `{"id":"a"}`), http.StatusConflict)
+	decode[server.LinkBody](t, call(t, s, "POST", "/graphs/g/links", `{"source":"a","target":"b"}`), http.StatusCreated)
+	decode[server.LinkBody](t, call(t, s, "POST", "/graphs/g/links", `{"source":"c","target":"a","weight":2.5}`), http.StatusCreated)
+	decode[map[string]any](t, call(t, s, "POST", "/graphs/g/links", `{"source":"b","target":"a"}`), http.StatusConflict)
+	decode[map[string]any](t, call(t, s, "POST", "/graphs/g/links", `{"source":"a","target":"z"}`), http.StatusNotFound)
+
+	info := decode[server.GraphInfo](t, call(t, s, "GET", "/graphs/g", ""), http.StatusOK)
+	if info.Nodes != 4 || info.Links != 2 || info.Directed {
+		t.Errorf("unexpected graph %v", info)
+	}
+
+	neighbors := decode[server.NeighborhoodBody](t, call(t, s, "GET", "/graphs/g/nodes/a/neighbors?limit=1", ""), http.StatusOK)
+	if neighbors.Undirected != 2 || neighbors.Links.Total != 2 || len(neighbors.Links.Items) != 1 {
+		t.Fatalf("unexpected neighborhood %v", neighbors)
+	} else if neighbors.Links.Next == nil || *neighbors.Links.Next != 1 {
+		t.Error("expected a next page")
+	} else if first := neighbors.Links.Items[0]; first.Source != "a" || first.Target != "b" || *first.Weight != 1.0 {
+		t.Errorf("unexpected first link %v", first)
+	}
+
+	neighbors = decode[server.NeighborhoodBody](t, call(t, s, "GET", "/graphs/g/nodes/a/neighbors?offset=1&limit=1", ""), http.StatusOK)
+	if neighbors.Links.Next != nil || len(neighbors.Links.Items) != 1 || *neighbors.Links.Items[0].Weight != 2.5 {
+		t.Errorf("unexpected last page %v", neighbors.Links)
+	}
+
+	decode[map[string]any](t, call(t, s, "GET", "/graphs/g/nodes/a/neighbors?limit=0", ""), http.StatusBadRequest)
+	decode[map[string]any](t, call(t, s, "GET", "/graphs/g/nodes/z/neighbors", ""), http.StatusNotFound)
+
+	// undirected link is found either way
+	if response := call(t, s, "DELETE", "/graphs/g/links/b/a", ""); response.Code != http.StatusNoContent {
+		t.Errorf("unexpected status %d", response.Code)
+	}
+
+	decode[map[string]any](t, call(t, s, "DELETE", "/graphs/g/links/b/a", ""), http.StatusNotFound)
+	if response := call(t, s, "DELETE", "/graphs/g/nodes/c", ""); response.Code != http.StatusNoContent {
+		t.Errorf("unexpected status %d", response.Code)
+	}
+
+	// random id may sort before others
+	nodes := decode[server.Page[string]](t, call(t, s, "GET", "/graphs/g/nodes?limit=2", ""), http.StatusOK)
+	if nodes.Total != 3 || len(nodes.Items) != 2 || nodes.Next == nil {
+		t.Errorf("unexpected nodes %v", nodes)
+	}
+
+	nodes = decode[server.Page[string]](t, call(t, s, "GET", "/graphs/g/nodes", ""), http.StatusOK)
+	if !slices.Contains(nodes.Items, "a") || !slices.Contains(nodes.Items, "b") || slices.Contains(nodes.Items, "c") {
+		t.Errorf("unexpected nodes %v", nodes)
+	}
+
+	if large := decode[server.Page[string]](t, call(t, s, "GET", "/graphs/g/nodes?offset=9223372036854775807", ""), http.StatusOK); len(large.Items) != 0 || large.Next != nil {
+		t.Errorf("offset after the end should return an empty page, got %v", large)
+	}
+
+	info = decode[server.GraphInfo](t, call(t, s, "GET", "/graphs/g", ""), http.StatusOK)
+	if info.Nodes != 3 || info.Links != 0 {
+		t.Errorf("unexpected graph after removals %v", info)
+	}
+
+	if response := call(t, s, "DELETE", "/graphs/g", ""); response.Code != http.StatusNoContent {
+		t.Errorf("unexpected status %d", response.Code)
+	}
+
+	if all := decode[[]server.GraphInfo](t, call(t, s, "GET", "/graphs", ""), http.StatusOK); len(all) != 0 {
+		t.Errorf("expected no graph, got %v", all)
+	}
+}
+
+func TestServerDirectedLinks(t *testing.T) {
+	s := server.NewServer(server.Options{})
+	decode[server.GraphInfo](t, call(t, s, "POST", "/graphs", `{"name":"d","directed":true}`), http.StatusCreated)
+	decode[server.NodeBody](t, call(t, s, "POST", "/graphs/d/nodes", `{"id":"a"}`), http.StatusCreated)
+	decode[server.NodeBody](t, call(t, s, "POST", "/graphs/d/nodes", `{"id":"b"}`), http.StatusCreated)
+	decode[server.LinkBody](t, call(t, s, "POST", "/graphs/d/links", `{"source":"a","target":"b"}`), http.StatusCreated)
+	// opposite direction is another link
+	if link := decode[server.LinkBody](t, call(t, s, "POST", "/graphs/d/links", `{"source":"b","target":"a"}`), http.StatusCreated); !link.Directed {
+		t.Error("expected a directed link")
+	}
+
+	neighbors := decode[server.NeighborhoodBody](t, call(t, s, "GET", "/graphs/d/nodes/a/neighbors", ""), http.StatusOK)
+	if neighbors.Incoming != 1 || neighbors.Outgoing != 1 || neighbors.Links.Total != 1 {
+		t.Errorf("unexpected neighborhood %v", neighbors)
+	}
+
+	decode[map[string]any](t, call(t, s, "GET", "/graphs/d/components", ""), http.StatusBadRequest)
+	decode[map[string]any](t, call(t, s, "POST", "/graphs/d/generate", `{"model":"ba","initial":2,"size":10}`), http.StatusBadRequest)
+}
+
+func TestServerGenerators(t *testing.T) {
+	s := server.NewServer(server.Options{MaxNodes: 50})
+	decode[server.GraphInfo](t, call(t, s, "POST", "/graphs", `{"name":"complete"}`), http.StatusCreated)
+	info := decode[server.GraphInfo](t, call(t, s, "POST", "/graphs/complete/generate", `{"model":"gnp","size":5,"probability":1.0}`), http.StatusOK)
+	if info.Nodes != 5 || info.Links != 10 {
+		t.Errorf("expected a complete graph, got %v", info)
+	}
+
+	stats := decode[server.StatisticsBody](t, call(t, s, "GET", "/graphs/complete/stats", ""), http.StatusOK)
+	if stats.Nodes != 5 || stats.UndirectedLinks != 10 || stats.Density != 1.0 || stats.DegreeDistribution[4] != 1.0 {
+		t.Errorf("unexpected stats %v", stats)
+	}
+
+	components := decode[server.ComponentsBody](t, call(t, s, "GET", "/graphs/complete/components", ""), http.StatusOK)
+	if components.Count != 1 || !slices.Equal(components.Sizes, []int64{5}) {
+		t.Errorf("unexpected components %v", components)
+	}
+
+	decode[server.GraphInfo](t, call(t, s, "POST", "/graphs", `{"name":"ba"}`), http.StatusCreated)
+	info = decode[server.GraphInfo](t, call(t, s, "POST", "/graphs/ba/generate", `{"model":"ba","initial":3,"size":20}`), http.StatusOK)
+	if info.Nodes != 20 || info.Links != 3+17 {
+		t.Errorf("unexpected ba graph %v", info)
+	}
+
+	decode[map[string]any](t, call(t, s, "POST", "/graphs/ba/generate", `{"model":"ba","initial":3,"size":51}`), http.StatusBadRequest)
+	decode[map[string]any](t, call(t, s, "POST", "/graphs/ba/generate", `{"model":"gnp","size":5,"probability":2}`), http.StatusBadRequest)
+	decode[map[string]any](t, call(t, s, "POST", "/graphs/ba/generate", `{"model":"ws","size":5}`), http.StatusBadRequest)
+	decode[map[string]any](t, call(t, s, "POST", "/graphs/ba/generate", `{"model":"gnp","unknown":5}`), http.StatusBadRequest)
+}
+
+func TestServerExports(t *testing.T) {
+	s := server.NewServer(server.Options{})
+	decode[server.GraphInfo](t, call(t, s, "POST", "/graphs", `{"name":"g"}`), http.StatusCreated)
+	decode[server.GraphInfo](t, call(t, s, "POST", "/graphs/g/generate", `{"model":"gnp","size":3,"probability":1.0}`), http.StatusOK)
+
+	response := call(t, s, "GET", "/graphs/g/export", "")
+	if response.Code != http.StatusOK || response.Header().Get("Content-Type") != "application/gexf+xml" {
+		t.Fatalf("unexpected gexf response %d", response.Code)
+	} else if !strings.Contains(response.Body.String(), `label="2"`) {
+		t.Error("expected labels of nodes")
+	} else if !strings.Contains(response.Header().Get("Content-Disposition"), `"g.gexf"`) {
+		t.Error("expected a file name")
+	}
+
+	document := decode[map[string]any](t, call(t, s, "GET", "/graphs/g/export?format=json", ""), http.StatusOK)
+	if nodes, ok := document["nodes"].([]any); !ok || len(nodes) != 3 {
+		t.Errorf("unexpected node link document %v", document)
+	} else if links, ok := document["links"].([]any); !ok || len(links) != 3 {
+		t.Errorf("unexpected links in document %v", document)
+	}
+
+	if jgf := decode[map[string]any](t, call(t, s, "GET", "/graphs/g/export?format=jgf", ""), http.StatusOK); jgf["graph"] == nil {
+		t.Errorf("unexpected jgf document %v", jgf)
+	}
+
+	decode[map[string]any](t, call(t, s, "GET", "/graphs/g/export?format=svg", ""), http.StatusBadRequest)
+}
+
+func TestServerErrors(t *testing.T) {
+	s := server.NewServer(server.Options{})
+	body := decode[map[string]any](t, call(t, s, "GET", "/graphs/missing/stats", ""), http.StatusNotFound)
+	if body["error"] == "" || body["status"] != float64(http.StatusNotFound) {
+		t.Errorf("unexpected error body %v", body)
+	}
+
+	decode[map[string]any](t, call(t, s, "GET", "/nowhere", ""), http.StatusNotFound)
+	decode[map[string]any](t, call(t, s, "POST", "/graphs", `not json`), http.StatusBadRequest)
+}
+
+func TestServerOpenAPI(t *testing.T) {
+	// through a real http server, as clients would
+	httpServer := httptest.NewServer(server.NewServer(server.Options{}))
+	defer httpServer.Close()
+
+	response, errGet := http.Get(httpServer.URL + "/openapi.json")
+	if errGet != nil {
+		t.Fatal(errGet)
+	}
+
+	defer response.Body.Close()
+	var document struct {
+		OpenAPI string                    `json:"openapi"`
+		Paths   map[string]map[string]any `json:"paths"`
+	}
+
+	if err := json.NewDecoder(response.Body).Decode(&document); err != nil {
+		t.Fatal(err)
+	} else if !strings.HasPrefix(document.OpenAPI, "3.") {
+		t.Errorf("unexpected version %q", document.OpenAPI)
+	}
+
+	expected := map[string][]string{
+		"/graphs":                                {"get", "post"},
+		"/graphs/{name}":                         {"get", "delete"},
+		"/graphs/{name}/generate":                {"post"},
+		"/graphs/{name}/nodes":                   {"get", "post"},
+		"/graphs/{name}/nodes/{id}":              {"delete"},
+		"/graphs/{name}/nodes/{id}/neighbors":    {"get"},
+		"/graphs/{name}/links":                   {"post"},
+		"/graphs/{name}/links/{source}/{target}": {"delete"},
+		"/graphs/{name}/stats":                   {"get"},
+		"/graphs/{name}/components":              {"get"},
+		"/graphs/{name}/export":                  {"get"},
+	}
+
+	for path, methods := range expected {
+		for _, method := range methods {
+			if _, found := document.Paths[path][method]; !found {
+				t.Errorf("missing %s %s in openapi document", method, path)
+			}
+		}
+	}
+}
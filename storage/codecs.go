@@ -0,0 +1,451 @@
+package storage
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+
+	"github.com/zefrenchwan/nodz.git/graphs"
+	"github.com/zefrenchwan/nodz.git/internal"
+)
+
+// NodeCodec encodes a node to bytes, and decodes it back.
+// Node types are generic and not comparable, so binary formats need a codec per type.
+type NodeCodec[N graphs.Node] interface {
+	// EncodeNode returns the payload of a node
+	EncodeNode(N) ([]byte, error)
+	// DecodeNode returns the node from its payload
+	DecodeNode([]byte) (N, error)
+}
+
+// LinkCodec encodes a link to bytes, and decodes it back.
+// Extremities of the link are NOT part of the payload, formats store them separately.
+type LinkCodec[N graphs.Node, L graphs.Link[N]] interface {
+	// EncodeLink returns the payload of a link, extremities excluded
+	EncodeLink(L) ([]byte, error)
+	// DecodeLink returns the link from its extremities and payload
+	DecodeLink(source, destination N, payload []byte) (L, error)
+}
+
+// IdNodeCodec is the codec for internal.IdNode: payload is the id
+type IdNodeCodec struct{}
+
+// EncodeNode returns the id of the node
+func (IdNodeCodec) EncodeNode(node internal.IdNode) ([]byte, error) {
+	return []byte(node.Id()), nil
+}
+
+// DecodeNode returns a node with payload as id
+func (IdNodeCodec) DecodeNode(payload []byte) (internal.IdNode, error) {
+	return internal.NewIdNode(string(payload)), nil
+}
+
+// PropertiesNodeCodec is the codec for *internal.PropertiesNode: id and properties
+type PropertiesNodeCodec struct{}
+
+// EncodeNode returns id and properties of the node
+func (PropertiesNodeCodec) EncodeNode(node *internal.PropertiesNode) ([]byte, error) {
+	if node == nil {
+		return nil, errors.New("nil node")
+	}
+
+	var encoder PayloadEncoder
+	encoder.PutString(node.Id())
+	encoder.PutProperties(node)
+	return encoder.Bytes(), nil
+}
+
+// DecodeNode returns the node with its id and properties
+func (PropertiesNodeCodec) DecodeNode(payload []byte) (*internal.PropertiesNode, error) {
+	decoder := NewPayloadDecoder(payload)
+	id := decoder.String()
+	node := internal.NewPropertiesNodeWithId(id)
+	decoder.Properties(&node)
+	return &node, decoder.Err()
+}
+
+// LabelsPropertiesNodeCodec is the codec for *internal.LabelsPropertiesNode: id, labels and properties
+type LabelsPropertiesNodeCodec struct{}
+
+// EncodeNode returns id, labels and properties of the node
+func (LabelsPropertiesNodeCodec) EncodeNode(node *internal.LabelsPropertiesNode) ([]byte, error) {
+	if node == nil {
+		return nil, errors.New("nil node")
+	}
+
+	var encoder PayloadEncoder
+	encoder.PutString(node.Id())
+	encoder.PutStrings(node.Labels())
+	encoder.PutProperties(node)
+	return encoder.Bytes(), nil
+}
+
+// DecodeNode returns the node with its id, labels and properties
+func (LabelsPropertiesNodeCodec) DecodeNode(payload []byte) (*internal.LabelsPropertiesNode, error) {
+	decoder := NewPayloadDecoder(payload)
+	id := decoder.String()
+	node := internal.NewLabelsPropertiesNodeWithId(id)
+	for _, label := range decoder.Strings() {
+		node.AddLabel(label)
+	}
+
+	decoder.Properties(&node)
+	return &node, decoder.Err()
+}
+
+// UndirectedSimpleLinkCodec is the codec for internal.UndirectedSimpleLink: no payload
+type UndirectedSimpleLinkCodec[N graphs.Node] struct{}
+
+// EncodeLink returns an empty payload, extremities are the link
+func (UndirectedSimpleLinkCodec[N]) EncodeLink(internal.UndirectedSimpleLink[N]) ([]byte, error) {
+	return nil, nil
+}
+
+// DecodeLink returns the link between source and destination
+func (UndirectedSimpleLinkCodec[N]) DecodeLink(source, destination N, _ []byte) (internal.UndirectedSimpleLink[N], error) {
+	return internal.NewUndirectedSimpleLink(source, destination), nil
+}
+
+// ValuedLinkCodec is the codec for internal.ValuedLink: direction and value.
+// Values of numeric types, booleans and strings are encoded out of the box.
+// For any other type, set EncodeValue and DecodeValue.
+type ValuedLinkCodec[N graphs.Node, V comparable] struct {
+	// EncodeValue returns the payload of a value, nil for default encoding
+	EncodeValue func(V) ([]byte, error)
+	// DecodeValue returns the value from its payload, nil for default decoding
+	DecodeValue func([]byte) (V, error)
+}
+
+// EncodeLink returns direction and value of the link
+func (vc ValuedLinkCodec[N, V]) EncodeLink(link internal.ValuedLink[N, V]) ([]byte, error) {
+	var encoder PayloadEncoder
+	encoder.PutBool(link.IsDirected())
+
+	var value []byte
+	if vc.EncodeValue != nil {
+		if v, err := vc.EncodeValue(link.Value()); err != nil {
+			return nil, err
+		} else {
+			value = v
+		}
+	} else if v, err := encodeScalar(link.Value()); err != nil {
+		return nil, err
+	} else {
+		value = v
+	}
+
+	encoder.PutBytes(value)
+	return encoder.Bytes(), nil
+}
+
+// DecodeLink returns the link with its direction and value
+func (vc ValuedLinkCodec[N, V]) DecodeLink(source, destination N, payload []byte) (internal.ValuedLink[N, V], error) {
+	var result internal.ValuedLink[N, V]
+	decoder := NewPayloadDecoder(payload)
+	directed := decoder.Bool()
+	rawValue := decoder.Bytes()
+	if err := decoder.Err(); err != nil {
+		return result, err
+	}
+
+	var value V
+	if vc.DecodeValue != nil {
+		if v, err := vc.DecodeValue(rawValue); err != nil {
+			return result, err
+		} else {
+			value = v
+		}
+	} else if err := decodeScalar(rawValue, &value); err != nil {
+		return result, err
+	}
+
+	if directed {
+		return internal.NewDirectedValuedLink(source, destination, value), nil
+	}
+
+	return internal.NewUndirectedValuedLink(source, destination, value), nil
+}
+
+// TypePropertiesLinkCodec is the codec for *internal.TypePropertiesLink: type and properties
+type TypePropertiesLinkCodec[N graphs.Node] struct{}
+
+// EncodeLink returns type and properties of the link
+func (TypePropertiesLinkCodec[N]) EncodeLink(link *internal.TypePropertiesLink[N]) ([]byte, error) {
+	if link == nil {
+		return nil, errors.New("nil link")
+	}
+
+	var encoder PayloadEncoder
+	encoder.PutString(link.LinkType())
+	encoder.PutProperties(link)
+	return encoder.Bytes(), nil
+}
+
+// DecodeLink returns the link with its type and properties
+func (TypePropertiesLinkCodec[N]) DecodeLink(source, destination N, payload []byte) (*internal.TypePropertiesLink[N], error) {
+	decoder := NewPayloadDecoder(payload)
+	linkType := decoder.String()
+	link := internal.NewTypePropertiesLink(linkType, source, destination)
+	decoder.Properties(&link)
+	return &link, decoder.Err()
+}
+
+// PayloadEncoder appends varint length prefixed values to a payload.
+// Zero value is ready to use
+type PayloadEncoder struct {
+	// content is the payload so far
+	content []byte
+}
+
+// Bytes returns the payload
+func (pe *PayloadEncoder) Bytes() []byte {
+	return pe.content
+}
+
+// PutUvarint appends an unsigned varint
+func (pe *PayloadEncoder) PutUvarint(value uint64) {
+	pe.content = binary.AppendUvarint(pe.content, value)
+}
+
+// PutBool appends a boolean as a byte
+func (pe *PayloadEncoder) PutBool(value bool) {
+	if value {
+		pe.content = append(pe.content, 1)
+	} else {
+		pe.content = append(pe.content, 0)
+	}
+}
+
+// PutBytes appends the length of value and then value
+func (pe *PayloadEncoder) PutBytes(value []byte) {
+	pe.PutUvarint(uint64(len(value)))
+	pe.content = append(pe.content, value...)
+}
+
+// PutString appends a string
+func (pe *PayloadEncoder) PutString(value string) {
+	pe.PutBytes([]byte(value))
+}
+
+// PutStrings appends the size of the slice and then each string
+func (pe *PayloadEncoder) PutStrings(values []string) {
+	pe.PutUvarint(uint64(len(values)))
+	for _, value := range values {
+		pe.PutString(value)
+	}
+}
+
+// PutProperties appends properties, sorted by key to get the same payload for the same properties
+func (pe *PayloadEncoder) PutProperties(properties graphs.WithProperties) {
+	keys := properties.PropertyKeys()
+	slices.Sort(keys)
+	pe.PutUvarint(uint64(len(keys)))
+	for _, key := range keys {
+		value, _ := properties.GetProperty(key)
+		pe.PutString(key)
+		pe.PutString(value)
+	}
+}
+
+// PayloadDecoder reads values appended by a PayloadEncoder.
+// First error is kept, and following reads return default values.
+type PayloadDecoder struct {
+	// content is the remaining payload to read
+	content []byte
+	// err is the first error, if any
+	err error
+}
+
+// NewPayloadDecoder returns a decoder over payload
+func NewPayloadDecoder(payload []byte) PayloadDecoder {
+	return PayloadDecoder{content: payload}
+}
+
+// Err returns the first decoding error, if any
+func (pd *PayloadDecoder) Err() error {
+	return pd.err
+}
+
+// Uvarint reads an unsigned varint
+func (pd *PayloadDecoder) Uvarint() uint64 {
+	if pd.err != nil {
+		return 0
+	}
+
+	value, size := binary.Uvarint(pd.content)
+	if size <= 0 {
+		pd.err = errors.New("invalid varint in payload")
+		return 0
+	}
+
+	pd.content = pd.content[size:]
+	return value
+}
+
+// Bool reads a boolean
+func (pd *PayloadDecoder) Bool() bool {
+	if pd.err != nil {
+		return false
+	} else if len(pd.content) == 0 {
+		pd.err = errors.New("truncated payload")
+		return false
+	}
+
+	value := pd.content[0] != 0
+	pd.content = pd.content[1:]
+	return value
+}
+
+// Bytes reads a length prefixed slice of bytes
+func (pd *PayloadDecoder) Bytes() []byte {
+	size := pd.Uvarint()
+	if pd.err != nil {
+		return nil
+	} else if uint64(len(pd.content)) < size {
+		pd.err = errors.New("truncated payload")
+		return nil
+	}
+
+	value := pd.content[:size]
+	pd.content = pd.content[size:]
+	return value
+}
+
+// String reads a string
+func (pd *PayloadDecoder) String() string {
+	return string(pd.Bytes())
+}
+
+// Strings reads a slice of strings
+func (pd *PayloadDecoder) Strings() []string {
+	size := pd.Uvarint()
+	if pd.err != nil {
+		return nil
+	} else if uint64(len(pd.content)) < size {
+		// each string uses at least one byte, it avoids huge allocations for corrupted content
+		pd.err = errors.New("truncated payload")
+		return nil
+	}
+
+	result := make([]string, 0, size)
+	for index := uint64(0); index < size && pd.err == nil; index++ {
+		result = append(result, pd.String())
+	}
+
+	return result
+}
+
+// Properties reads properties and sets them in destination
+func (pd *PayloadDecoder) Properties(destination graphs.WithProperties) {
+	size := pd.Uvarint()
+	for index := uint64(0); index < size && pd.err == nil; index++ {
+		key := pd.String()
+		value := pd.String()
+		if pd.err == nil {
+			destination.SetProperty(key, value)
+		}
+	}
+}
+
+// encodeScalar encodes numbers, booleans and strings
+func encodeScalar(value any) ([]byte, error) {
+	var encoder PayloadEncoder
+	switch v := value.(type) {
+	case string:
+		return []byte(v), nil
+	case bool:
+		encoder.PutBool(v)
+	case int:
+		encoder.content = binary.AppendVarint(nil, int64(v))
+	case int8:
+		encoder.content = binary.AppendVarint(nil, int64(v))
+	case int16:
+		encoder.content = binary.AppendVarint(nil, int64(v))
+	case int32:
+		encoder.content = binary.AppendVarint(nil, int64(v))
+	case int64:
+		encoder.content = binary.AppendVarint(nil, v)
+	case uint:
+		encoder.PutUvarint(uint64(v))
+	case uint8:
+		encoder.PutUvarint(uint64(v))
+	case uint16:
+		encoder.PutUvarint(uint64(v))
+	case uint32:
+		encoder.PutUvarint(uint64(v))
+	case uint64:
+		encoder.PutUvarint(v)
+	case float32:
+		encoder.content = binary.LittleEndian.AppendUint32(nil, math.Float32bits(v))
+	case float64:
+		encoder.content = binary.LittleEndian.AppendUint64(nil, math.Float64bits(v))
+	default:
+		return nil, fmt.Errorf("no default encoding for %T, set value codec", value)
+	}
+
+	return encoder.Bytes(), nil
+}
+
+// decodeScalar decodes a value encoded by encodeScalar in destination
+func decodeScalar[V any](payload []byte, destination *V) error {
+	decoder := NewPayloadDecoder(payload)
+	signed := func() int64 {
+		value, size := binary.Varint(payload)
+		if size <= 0 {
+			decoder.err = errors.New("invalid varint in payload")
+		}
+
+		return value
+	}
+
+	var result any
+	switch any(*destination).(type) {
+	case string:
+		result = string(payload)
+	case bool:
+		result = decoder.Bool()
+	case int:
+		result = int(signed())
+	case int8:
+		result = int8(signed())
+	case int16:
+		result = int16(signed())
+	case int32:
+		result = int32(signed())
+	case int64:
+		result = signed()
+	case uint:
+		result = uint(decoder.Uvarint())
+	case uint8:
+		result = uint8(decoder.Uvarint())
+	case uint16:
+		result = uint16(decoder.Uvarint())
+	case uint32:
+		result = uint32(decoder.Uvarint())
+	case uint64:
+		result = decoder.Uvarint()
+	case float32:
+		if len(payload) != 4 {
+			return errors.New("invalid float32 payload")
+		}
+
+		result = math.Float32frombits(binary.LittleEndian.Uint32(payload))
+	case float64:
+		if len(payload) != 8 {
+			return errors.New("invalid float64 payload")
+		}
+
+		result = math.Float64frombits(binary.LittleEndian.Uint64(payload))
+	default:
+		return fmt.Errorf("no default decoding for %T, set value codec", *destination)
+	}
+
+	if decoder.err != nil {
+		return decoder.err
+	}
+
+	*destination = result.(V)
+	return nil
+}
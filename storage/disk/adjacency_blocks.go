@@ -0,0 +1,297 @@
+package disk
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+// Adjacency blocks are heap records, linked from the node slot:
+//
+//	next block (8 bytes, fixed size so that it changes in place), then entries:
+//	kind (1 byte), other node (unsigned varint), link payload size (unsigned varint), link payload.
+//
+// New entries go to the first block, a new first block is inserted once it is full.
+
+// entryKind is the role of a link from the node point of view
+type entryKind byte
+
+const (
+	// outgoingEntry is a directed link from the node
+	outgoingEntry entryKind = iota + 1
+	// incomingEntry is a directed link to the node. It is not part of the neighborhood, but allows node removal
+	incomingEntry
+	// sourceEntry is an undirected link with the node as source
+	sourceEntry
+	// destinationEntry is an undirected link with the node as destination
+	destinationEntry
+)
+
+// maxBlockSize is the size of a block to stop adding entries to it
+const maxBlockSize = 512
+
+// blockHeaderSize is the size of the header of a block
+const blockHeaderSize = 8
+
+// adjacencyEntry is a link as stored in the blocks of a node
+type adjacencyEntry struct {
+	// kind is the role of the link
+	kind entryKind
+	// other is the node at the other end of the link
+	other uint64
+	// payload is the encoded link
+	payload []byte
+}
+
+// mirror returns the kind of the same link in the blocks of the other node
+func (k entryKind) mirror() entryKind {
+	switch k {
+	case outgoingEntry:
+		return incomingEntry
+	case incomingEntry:
+		return outgoingEntry
+	case sourceEntry:
+		return destinationEntry
+	default:
+		return sourceEntry
+	}
+}
+
+// counterpart tests if entry is the same link as reference, seen from the other node
+func (ae adjacencyEntry) counterpart(reference adjacencyEntry, node uint64) bool {
+	return ae.kind == reference.kind.mirror() && ae.other == node && bytes.Equal(ae.payload, reference.payload)
+}
+
+// encodedSize is the size of the encoded entry
+func (ae adjacencyEntry) encodedSize() int {
+	return 1 + uvarintSize(ae.other) + uvarintSize(uint64(len(ae.payload))) + len(ae.payload)
+}
+
+// adjacencyBlock is the decoded content of a block
+type adjacencyBlock struct {
+	// next is the next block of the node, 0 for the last block
+	next recordId
+	// entries are the links of the block
+	entries []adjacencyEntry
+}
+
+// encode returns the content of the block
+func (ab adjacencyBlock) encode() []byte {
+	result := make([]byte, blockHeaderSize, ab.size())
+	binary.LittleEndian.PutUint64(result, uint64(ab.next))
+	for _, entry := range ab.entries {
+		result = append(result, byte(entry.kind))
+		result = binary.AppendUvarint(result, entry.other)
+		result = binary.AppendUvarint(result, uint64(len(entry.payload)))
+		result = append(result, entry.payload...)
+	}
+
+	return result
+}
+
+// size returns the size of the encoded block
+func (ab adjacencyBlock) size() int {
+	result := blockHeaderSize
+	for _, entry := range ab.entries {
+		result += entry.encodedSize()
+	}
+
+	return result
+}
+
+// decodeBlock reads a block written by encode
+func decodeBlock(content []byte) (adjacencyBlock, error) {
+	var result adjacencyBlock
+	if len(content) < blockHeaderSize {
+		return result, errors.New("truncated adjacency block")
+	}
+
+	result.next = recordId(binary.LittleEndian.Uint64(content))
+	for position := blockHeaderSize; position < len(content); {
+		kind := entryKind(content[position])
+		position++
+		other, otherLength := binary.Uvarint(content[position:])
+		if otherLength <= 0 || kind < outgoingEntry || kind > destinationEntry {
+			return result, errors.New("invalid adjacency entry")
+		}
+
+		position += otherLength
+		size, sizeLength := binary.Uvarint(content[position:])
+		if sizeLength <= 0 || uint64(len(content)-position-sizeLength) < size {
+			return result, errors.New("invalid adjacency payload")
+		}
+
+		position += sizeLength
+		payload := content[position : position+int(size)]
+		position += int(size)
+		result.entries = append(result.entries, adjacencyEntry{kind: kind, other: other, payload: payload})
+	}
+
+	return result, nil
+}
+
+// uvarintSize returns the size of an unsigned varint
+func uvarintSize(value uint64) int {
+	size := 1
+	for ; value >= 0x80; value >>= 7 {
+		size++
+	}
+
+	return size
+}
+
+// adjacency reads and changes the adjacency blocks of nodes
+type adjacency struct {
+	// records stores blocks
+	records heap
+}
+
+// walk calls visitor for each block of a node, from the first one, until visitor returns true.
+// Visitor gets the previous block (0 for the first one), the block and its id
+func (a adjacency) walk(head recordId, visitor func(previous, id recordId, block adjacencyBlock) (bool, error)) error {
+	previous := recordId(0)
+	for current := head; current != 0; {
+		content, errRead := a.records.read(current)
+		if errRead != nil {
+			return errRead
+		}
+
+		block, errBlock := decodeBlock(content)
+		if errBlock != nil {
+			return errBlock
+		} else if stop, err := visitor(previous, current, block); err != nil || stop {
+			return err
+		}
+
+		previous, current = current, block.next
+	}
+
+	return nil
+}
+
+// find returns the first entry of a node that matches, false if none
+func (a adjacency) find(slot nodeSlot, match func(adjacencyEntry) (bool, error)) (adjacencyEntry, bool, error) {
+	var result adjacencyEntry
+	found := false
+	errWalk := a.walk(slot.head, func(_, _ recordId, block adjacencyBlock) (bool, error) {
+		for _, entry := range block.entries {
+			if matches, err := match(entry); err != nil {
+				return true, err
+			} else if matches {
+				result, found = entry, true
+				return true, nil
+			}
+		}
+
+		return false, nil
+	})
+
+	return result, found, errWalk
+}
+
+// add adds an entry to a node, in its first block if it fits. Slot is changed but not written
+func (a adjacency) add(slot *nodeSlot, entry adjacencyEntry) error {
+	if slot.head != 0 {
+		content, errRead := a.records.read(slot.head)
+		if errRead != nil {
+			return errRead
+		}
+
+		block, errBlock := decodeBlock(content)
+		if errBlock != nil {
+			return errBlock
+		} else if block.size()+entry.encodedSize() <= maxBlockSize {
+			block.entries = append(block.entries, entry)
+			head, errUpdate := a.records.update(slot.head, block.encode())
+			slot.head = head
+			return errUpdate
+		}
+	}
+
+	block := adjacencyBlock{next: slot.head, entries: []adjacencyEntry{entry}}
+	head, errInsert := a.records.insert(block.encode())
+	if errInsert != nil {
+		return errInsert
+	}
+
+	slot.head = head
+	return nil
+}
+
+// remove removes the first matching entry of a node, and returns it. Slot is changed but not written
+func (a adjacency) remove(slot *nodeSlot, match func(adjacencyEntry) (bool, error)) (adjacencyEntry, bool, error) {
+	var result adjacencyEntry
+	found := false
+	errWalk := a.walk(slot.head, func(previous, id recordId, block adjacencyBlock) (bool, error) {
+		for index, entry := range block.entries {
+			if matches, err := match(entry); err != nil {
+				return true, err
+			} else if !matches {
+				continue
+			}
+
+			result, found = entry, true
+			result.payload = bytes.Clone(entry.payload)
+			block.entries = append(block.entries[:index], block.entries[index+1:]...)
+			if len(block.entries) == 0 {
+				// empty blocks are removed from the list of blocks
+				if errLink := a.link(slot, previous, block.next); errLink != nil {
+					return true, errLink
+				}
+
+				return true, a.records.remove(id)
+			}
+
+			updated, errUpdate := a.records.update(id, block.encode())
+			if errUpdate != nil || updated == id {
+				return true, errUpdate
+			}
+
+			return true, a.link(slot, previous, updated)
+		}
+
+		return false, nil
+	})
+
+	return result, found, errWalk
+}
+
+// clear removes all the blocks of a node. Slot is changed but not written
+func (a adjacency) clear(slot *nodeSlot) error {
+	blocks := make([]recordId, 0)
+	errWalk := a.walk(slot.head, func(_, id recordId, _ adjacencyBlock) (bool, error) {
+		blocks = append(blocks, id)
+		return false, nil
+	})
+
+	if errWalk != nil {
+		return errWalk
+	}
+
+	for _, id := range blocks {
+		if err := a.records.remove(id); err != nil {
+			return err
+		}
+	}
+
+	slot.head = 0
+	return nil
+}
+
+// link sets the block after previous (or the first block for no previous block)
+func (a adjacency) link(slot *nodeSlot, previous, next recordId) error {
+	if previous == 0 {
+		slot.head = next
+		return nil
+	}
+
+	content, errRead := a.records.read(previous)
+	if errRead != nil {
+		return errRead
+	}
+
+	// next block has a fixed size, so the block keeps its place
+	binary.LittleEndian.PutUint64(content, uint64(next))
+	_, errUpdate := a.records.update(previous, content)
+	return errUpdate
+}
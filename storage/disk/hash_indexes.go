@@ -0,0 +1,307 @@
+package disk
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+)
+
+// Index is a linear hashing table of keys to node numbers.
+// Bucket pages are the next overflow page (4 bytes), the size of entries (2 bytes), and then entries:
+// key size (unsigned varint), key, node number (unsigned varint).
+// There are 2^level + split buckets. Once there are too many keys per bucket, bucket split is split in two.
+
+const (
+	// bucketHeaderSize is the size of the header of a bucket page
+	bucketHeaderSize = 6
+	// maxKeySize is the maximum size of a key, so that an entry always fits in an empty bucket page
+	maxKeySize = 1024
+	// maxBucketLoad is the mean number of keys per bucket that triggers a split
+	maxBucketLoad = 48
+)
+
+// indexEntry is a key and its node
+type indexEntry struct {
+	// key of the node
+	key []byte
+	// node is the node number
+	node uint64
+}
+
+// hashIndex is an on disk hash index of node keys
+type hashIndex struct {
+	// pager reads and writes pages
+	pager *pager
+	// buckets are the first pages of buckets
+	buckets pageArray
+}
+
+// newHashIndex returns the index of the paged file
+func newHashIndex(p *pager) hashIndex {
+	return hashIndex{pager: p, buckets: pageArray{pager: p, root: &p.header.bucketsRoot}}
+}
+
+// find returns the node of a key, false if key is not indexed
+func (hi hashIndex) find(key string) (uint64, bool, error) {
+	first, errBucket := hi.bucket(key, false)
+	if errBucket != nil || first == 0 {
+		return 0, false, errBucket
+	}
+
+	result, found := uint64(0), false
+	errWalk := hi.walk(first, func(data []byte, entries []indexEntry, _ []int) (bool, error) {
+		for _, entry := range entries {
+			if string(entry.key) == key {
+				result, found = entry.node, true
+				return true, nil
+			}
+		}
+
+		return false, nil
+	})
+
+	return result, found, errWalk
+}
+
+// insert adds a key, it should not be indexed yet
+func (hi hashIndex) insert(key string, node uint64) error {
+	if err := checkKeySize(key); err != nil {
+		return err
+	}
+
+	first, errBucket := hi.bucket(key, true)
+	if errBucket != nil {
+		return errBucket
+	} else if err := hi.put(first, indexEntry{key: []byte(key), node: node}); err != nil {
+		return err
+	}
+
+	header := &hi.pager.header
+	header.indexEntries++
+	if header.indexEntries > maxBucketLoad*hi.bucketsCount() {
+		return hi.split()
+	}
+
+	return nil
+}
+
+// checkKeySize returns an error if key is too large for the index
+func checkKeySize(key string) error {
+	if len(key) > maxKeySize {
+		return fmt.Errorf("key size %d exceeds limit %d", len(key), maxKeySize)
+	}
+
+	return nil
+}
+
+// remove removes a key, if indexed
+func (hi hashIndex) remove(key string) error {
+	first, errBucket := hi.bucket(key, false)
+	if errBucket != nil || first == 0 {
+		return errBucket
+	}
+
+	removed := false
+	errWalk := hi.walk(first, func(data []byte, entries []indexEntry, offsets []int) (bool, error) {
+		for index, entry := range entries {
+			if string(entry.key) == key {
+				// following entries are moved over the removed one
+				used := bucketHeaderSize + int(binary.LittleEndian.Uint16(data[4:]))
+				copy(data[offsets[index]:], data[offsets[index+1]:used])
+				binary.LittleEndian.PutUint16(data[4:], uint16(used-bucketHeaderSize-(offsets[index+1]-offsets[index])))
+				removed = true
+				return true, nil
+			}
+		}
+
+		return false, nil
+	})
+
+	if removed {
+		hi.pager.header.indexEntries--
+	}
+
+	return errWalk
+}
+
+// bucketsCount returns the number of buckets
+func (hi hashIndex) bucketsCount() uint64 {
+	return 1<<hi.pager.header.indexLevel + hi.pager.header.indexSplit
+}
+
+// bucketIndex returns the bucket of a key
+func (hi hashIndex) bucketIndex(key []byte) uint64 {
+	hash := fnv.New64a()
+	hash.Write(key)
+	value := hash.Sum64()
+
+	level, split := hi.pager.header.indexLevel, hi.pager.header.indexSplit
+	if index := value & (1<<level - 1); index >= split {
+		return index
+	}
+
+	return value & (1<<(level+1) - 1)
+}
+
+// bucket returns the first page of the bucket of a key
+func (hi hashIndex) bucket(key string, create bool) (pageId, error) {
+	return hi.buckets.get(hi.bucketIndex([]byte(key)), create)
+}
+
+// walk calls visitor for each page of a bucket, with its entries and their offsets (plus the end offset).
+// Page is written back if visitor changes it. Walk stops once visitor returns true
+func (hi hashIndex) walk(first pageId, visitor func(data []byte, entries []indexEntry, offsets []int) (bool, error)) error {
+	for current := first; current != 0; {
+		stop := false
+		errWrite := hi.pager.write(current, func(data []byte) error {
+			entries, offsets, errDecode := decodeBucket(data)
+			if errDecode != nil {
+				return fmt.Errorf("bucket page %d: %w", current, errDecode)
+			}
+
+			var errVisit error
+			stop, errVisit = visitor(data, entries, offsets)
+			current = pageId(binary.LittleEndian.Uint32(data))
+			return errVisit
+		})
+
+		if errWrite != nil || stop {
+			return errWrite
+		}
+	}
+
+	return nil
+}
+
+// put appends an entry to the first page of a bucket with enough room, adding an overflow page if needed
+func (hi hashIndex) put(first pageId, entry indexEntry) error {
+	content := binary.AppendUvarint(nil, uint64(len(entry.key)))
+	content = append(content, entry.key...)
+	content = binary.AppendUvarint(content, entry.node)
+
+	for current := first; ; {
+		placed, next := false, pageId(0)
+		errWrite := hi.pager.write(current, func(data []byte) error {
+			used := bucketHeaderSize + int(binary.LittleEndian.Uint16(data[4:]))
+			next = pageId(binary.LittleEndian.Uint32(data))
+			if used+len(content) <= pageSize {
+				copy(data[used:], content)
+				binary.LittleEndian.PutUint16(data[4:], uint16(used+len(content)-bucketHeaderSize))
+				placed = true
+			}
+
+			return nil
+		})
+
+		if errWrite != nil || placed {
+			return errWrite
+		} else if next != 0 {
+			current = next
+			continue
+		}
+
+		overflow, errAllocate := hi.pager.allocate()
+		if errAllocate != nil {
+			return errAllocate
+		}
+
+		errLink := hi.pager.write(current, func(data []byte) error {
+			binary.LittleEndian.PutUint32(data, uint32(overflow))
+			return nil
+		})
+
+		if errLink != nil {
+			return errLink
+		}
+
+		current = overflow
+	}
+}
+
+// split splits the next bucket to split, and moves its entries between it and the new bucket
+func (hi hashIndex) split() error {
+	header := &hi.pager.header
+	splitIndex := header.indexSplit
+	first, errFirst := hi.buckets.get(splitIndex, true)
+	if errFirst != nil {
+		return errFirst
+	}
+
+	// entries of the split bucket are read, and its overflow pages are freed
+	entries := make([]indexEntry, 0)
+	overflows := make([]pageId, 0)
+	errWalk := hi.walk(first, func(data []byte, pageEntries []indexEntry, _ []int) (bool, error) {
+		for _, entry := range pageEntries {
+			entries = append(entries, indexEntry{key: bytes.Clone(entry.key), node: entry.node})
+		}
+
+		if next := pageId(binary.LittleEndian.Uint32(data)); next != 0 {
+			overflows = append(overflows, next)
+		}
+
+		clear(data)
+		return false, nil
+	})
+
+	if errWalk != nil {
+		return errWalk
+	}
+
+	for _, overflow := range overflows {
+		if err := hi.pager.free(overflow); err != nil {
+			return err
+		}
+	}
+
+	if _, err := hi.buckets.get(splitIndex+1<<header.indexLevel, true); err != nil {
+		return err
+	}
+
+	header.indexSplit++
+	if header.indexSplit == 1<<header.indexLevel {
+		header.indexLevel++
+		header.indexSplit = 0
+	}
+
+	for _, entry := range entries {
+		target, errTarget := hi.buckets.get(hi.bucketIndex(entry.key), true)
+		if errTarget != nil {
+			return errTarget
+		} else if err := hi.put(target, entry); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// decodeBucket returns the entries of a bucket page, and their offsets (plus the end offset)
+func decodeBucket(data []byte) ([]indexEntry, []int, error) {
+	used := bucketHeaderSize + int(binary.LittleEndian.Uint16(data[4:]))
+	if used > pageSize {
+		return nil, nil, fmt.Errorf("invalid bucket size %d", used)
+	}
+
+	entries := make([]indexEntry, 0)
+	offsets := []int{bucketHeaderSize}
+	for position := bucketHeaderSize; position < used; {
+		size, sizeLength := binary.Uvarint(data[position:used])
+		if sizeLength <= 0 || position+sizeLength+int(size) > used {
+			return nil, nil, fmt.Errorf("invalid key at offset %d", position)
+		}
+
+		key := data[position+sizeLength : position+sizeLength+int(size)]
+		position += sizeLength + int(size)
+		node, nodeLength := binary.Uvarint(data[position:used])
+		if nodeLength <= 0 {
+			return nil, nil, fmt.Errorf("invalid node at offset %d", position)
+		}
+
+		position += nodeLength
+		entries = append(entries, indexEntry{key: key, node: node})
+		offsets = append(offsets, position)
+	}
+
+	return entries, offsets, nil
+}
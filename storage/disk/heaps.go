@@ -0,0 +1,406 @@
+package disk
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Heap pages are slotted pages of variable size records:
+//
+//	slots count (2 bytes), data start (2 bytes), then slots: offset (2 bytes) and size (2 bytes).
+//	Data grows from the end of the page, a slot with offset 0 is free.
+//
+// Stored records start with a flag: 0 for content in the page, 1 for content in overflow pages.
+// Then, overflow records are the size of the content (4 bytes) and the first overflow page (4 bytes).
+// Overflow pages are the next overflow page (4 bytes) and then content.
+
+// recordId is the page of a record (high bits) and its slot in the page (16 low bits). 0 means no record
+type recordId uint64
+
+// newRecordId returns the id of a record
+func newRecordId(id pageId, slot int) recordId {
+	return recordId(id)<<16 | recordId(slot)
+}
+
+// page returns the page of the record
+func (ri recordId) page() pageId {
+	return pageId(ri >> 16)
+}
+
+// slot returns the slot of the record in its page
+func (ri recordId) slot() int {
+	return int(ri & 0xffff)
+}
+
+const (
+	// heapHeaderSize is the size of the header of a heap page
+	heapHeaderSize = 4
+	// heapSlotSize is the size of a slot
+	heapSlotSize = 4
+	// maxStoredSize is the maximum size of a stored record in a heap page
+	maxStoredSize = pageSize - heapHeaderSize - heapSlotSize
+	// inlineFlag starts records stored in their heap page
+	inlineFlag = 0
+	// overflowFlag starts records stored in overflow pages
+	overflowFlag = 1
+	// overflowStubSize is the size of a record stored in overflow pages, within its heap page
+	overflowStubSize = 9
+	// overflowHeaderSize is the size of the header of an overflow page
+	overflowHeaderSize = 4
+)
+
+// heap stores records of any size in heap pages.
+// New records go to the tail page, and pages are freed once empty
+type heap struct {
+	// pager reads and writes pages
+	pager *pager
+}
+
+// insert stores a record and returns its id
+func (h heap) insert(content []byte) (recordId, error) {
+	stored, errStored := h.store(content)
+	if errStored != nil {
+		return 0, errStored
+	}
+
+	return h.place(stored)
+}
+
+// read returns a copy of the content of a record
+func (h heap) read(id recordId) ([]byte, error) {
+	var stored []byte
+	errRead := h.pager.read(id.page(), func(data []byte) error {
+		value, errSlot := slotContent(data, id.slot())
+		stored = append([]byte(nil), value...)
+		return errSlot
+	})
+
+	if errRead != nil {
+		return nil, fmt.Errorf("record %d: %w", id, errRead)
+	} else if stored[0] == inlineFlag {
+		return stored[1:], nil
+	}
+
+	size := binary.LittleEndian.Uint32(stored[1:])
+	result := make([]byte, 0, size)
+	for current := pageId(binary.LittleEndian.Uint32(stored[5:])); current != 0; {
+		errOverflow := h.pager.read(current, func(data []byte) error {
+			remaining := min(int(size)-len(result), pageSize-overflowHeaderSize)
+			result = append(result, data[overflowHeaderSize:overflowHeaderSize+remaining]...)
+			current = pageId(binary.LittleEndian.Uint32(data))
+			return nil
+		})
+
+		if errOverflow != nil {
+			return nil, errOverflow
+		}
+	}
+
+	if len(result) != int(size) {
+		return nil, fmt.Errorf("record %d: truncated overflow content", id)
+	}
+
+	return result, nil
+}
+
+// update changes the content of a record, and returns its id.
+// Id is the same if record still fits in its page, for instance if it does not grow
+func (h heap) update(id recordId, content []byte) (recordId, error) {
+	if err := h.freeOverflow(id); err != nil {
+		return 0, err
+	}
+
+	stored, errStored := h.store(content)
+	if errStored != nil {
+		return 0, errStored
+	}
+
+	fits := false
+	errWrite := h.pager.write(id.page(), func(data []byte) error {
+		previous, errSlot := slotContent(data, id.slot())
+		if errSlot != nil {
+			return errSlot
+		} else if len(stored) <= len(previous) {
+			fits = true
+			copy(previous, stored)
+			setSlot(data, id.slot(), slotOffset(data, id.slot()), len(stored))
+			return nil
+		}
+
+		// record moves within its page, if page has enough room once compacted
+		offset, size := slotOffset(data, id.slot()), slotSize(data, id.slot())
+		setSlot(data, id.slot(), 0, 0)
+		if freeSpace(data) < len(stored) {
+			setSlot(data, id.slot(), offset, size)
+			return nil
+		}
+
+		fits = true
+		compact(data)
+		start := dataStart(data) - len(stored)
+		copy(data[start:], stored)
+		setDataStart(data, start)
+		setSlot(data, id.slot(), start, len(stored))
+		return nil
+	})
+
+	if errWrite != nil {
+		return 0, errWrite
+	} else if fits {
+		return id, nil
+	} else if err := h.removeSlot(id); err != nil {
+		return 0, err
+	}
+
+	return h.place(stored)
+}
+
+// remove deletes a record
+func (h heap) remove(id recordId) error {
+	if err := h.freeOverflow(id); err != nil {
+		return err
+	}
+
+	return h.removeSlot(id)
+}
+
+// store returns the stored version of content, in overflow pages if it does not fit in a page
+func (h heap) store(content []byte) ([]byte, error) {
+	if len(content)+1 <= maxStoredSize {
+		return append([]byte{inlineFlag}, content...), nil
+	}
+
+	// pages are allocated from the end, so that each page knows the next one
+	next := pageId(0)
+	chunk := pageSize - overflowHeaderSize
+	for index := (len(content) - 1) / chunk; index >= 0; index-- {
+		start, end := index*chunk, min(len(content), (index+1)*chunk)
+		current, errAllocate := h.pager.allocate()
+		if errAllocate != nil {
+			return nil, errAllocate
+		}
+
+		errWrite := h.pager.write(current, func(data []byte) error {
+			binary.LittleEndian.PutUint32(data, uint32(next))
+			copy(data[overflowHeaderSize:], content[start:end])
+			return nil
+		})
+
+		if errWrite != nil {
+			return nil, errWrite
+		}
+
+		next = current
+	}
+
+	stored := make([]byte, overflowStubSize)
+	stored[0] = overflowFlag
+	binary.LittleEndian.PutUint32(stored[1:], uint32(len(content)))
+	binary.LittleEndian.PutUint32(stored[5:], uint32(next))
+	return stored, nil
+}
+
+// place writes a stored record in the tail page, or in a new tail page if it does not fit
+func (h heap) place(stored []byte) (recordId, error) {
+	result := recordId(0)
+	if tail := h.pager.header.heapTail; tail != 0 {
+		placed := false
+		errWrite := h.pager.write(tail, func(data []byte) error {
+			slot, fits := placeInPage(data, stored)
+			result, placed = newRecordId(tail, slot), fits
+			return nil
+		})
+
+		if errWrite != nil || placed {
+			return result, errWrite
+		}
+	}
+
+	tail, errAllocate := h.pager.allocate()
+	if errAllocate != nil {
+		return 0, errAllocate
+	}
+
+	h.pager.header.heapTail = tail
+	errWrite := h.pager.write(tail, func(data []byte) error {
+		// a stored record always fits in an empty page
+		slot, _ := placeInPage(data, stored)
+		result = newRecordId(tail, slot)
+		return nil
+	})
+
+	return result, errWrite
+}
+
+// removeSlot frees the slot of a record, and the page once empty (except the tail page)
+func (h heap) removeSlot(id recordId) error {
+	empty := false
+	errWrite := h.pager.write(id.page(), func(data []byte) error {
+		if _, err := slotContent(data, id.slot()); err != nil {
+			return err
+		}
+
+		setSlot(data, id.slot(), 0, 0)
+		// trailing free slots are removed, so that an empty page has no slot
+		count := slotsCount(data)
+		for count > 0 && slotOffset(data, count-1) == 0 {
+			count--
+		}
+
+		binary.LittleEndian.PutUint16(data, uint16(count))
+		if count == 0 {
+			setDataStart(data, pageSize)
+			empty = true
+		}
+
+		return nil
+	})
+
+	if errWrite != nil || !empty || id.page() == h.pager.header.heapTail {
+		return errWrite
+	}
+
+	return h.pager.free(id.page())
+}
+
+// freeOverflow frees the overflow pages of a record, if any
+func (h heap) freeOverflow(id recordId) error {
+	first := pageId(0)
+	errRead := h.pager.read(id.page(), func(data []byte) error {
+		stored, errSlot := slotContent(data, id.slot())
+		if errSlot == nil && stored[0] == overflowFlag {
+			first = pageId(binary.LittleEndian.Uint32(stored[5:]))
+		}
+
+		return errSlot
+	})
+
+	if errRead != nil {
+		return errRead
+	}
+
+	for current := first; current != 0; {
+		next := pageId(0)
+		errNext := h.pager.read(current, func(data []byte) error {
+			next = pageId(binary.LittleEndian.Uint32(data))
+			return nil
+		})
+
+		if errNext != nil {
+			return errNext
+		} else if err := h.pager.free(current); err != nil {
+			return err
+		}
+
+		current = next
+	}
+
+	return nil
+}
+
+// placeInPage writes stored in a heap page and returns its slot, false if it does not fit
+func placeInPage(data []byte, stored []byte) (int, bool) {
+	count := slotsCount(data)
+	slot := count
+	for index := 0; index < count; index++ {
+		if slotOffset(data, index) == 0 {
+			slot = index
+			break
+		}
+	}
+
+	needed := len(stored)
+	if slot == count {
+		needed += heapSlotSize
+	}
+
+	if freeSpace(data) < needed {
+		return 0, false
+	} else if dataStart(data)-heapHeaderSize-heapSlotSize*count < needed {
+		compact(data)
+	}
+
+	if slot == count {
+		binary.LittleEndian.PutUint16(data, uint16(count+1))
+	}
+
+	start := dataStart(data) - len(stored)
+	copy(data[start:], stored)
+	setDataStart(data, start)
+	setSlot(data, slot, start, len(stored))
+	return slot, true
+}
+
+// compact moves the records to the end of the page, so that free space is contiguous
+func compact(data []byte) {
+	content := make([]byte, pageSize)
+	start := pageSize
+	for slot := range slotsCount(data) {
+		if offset := slotOffset(data, slot); offset != 0 {
+			size := slotSize(data, slot)
+			start -= size
+			copy(content[start:], data[offset:offset+size])
+			setSlot(data, slot, start, size)
+		}
+	}
+
+	copy(data[start:], content[start:])
+	setDataStart(data, start)
+}
+
+// freeSpace is the space for records and slots in a page, once compacted
+func freeSpace(data []byte) int {
+	used := heapHeaderSize + heapSlotSize*slotsCount(data)
+	for slot := range slotsCount(data) {
+		used += slotSize(data, slot)
+	}
+
+	return pageSize - used
+}
+
+// slotContent returns the stored record of a slot, as a slice of the page
+func slotContent(data []byte, slot int) ([]byte, error) {
+	if slot >= slotsCount(data) || slotOffset(data, slot) == 0 {
+		return nil, errors.New("no such record")
+	}
+
+	offset := slotOffset(data, slot)
+	return data[offset : offset+slotSize(data, slot)], nil
+}
+
+// slotsCount returns the number of slots of a heap page
+func slotsCount(data []byte) int {
+	return int(binary.LittleEndian.Uint16(data))
+}
+
+// dataStart returns the offset of the first record in a heap page
+func dataStart(data []byte) int {
+	if start := int(binary.LittleEndian.Uint16(data[2:])); start != 0 {
+		return start
+	}
+
+	return pageSize
+}
+
+// setDataStart sets the offset of the first record in a heap page.
+// Page size does not fit in 2 bytes, so that it is stored as 0
+func setDataStart(data []byte, start int) {
+	binary.LittleEndian.PutUint16(data[2:], uint16(start%pageSize))
+}
+
+// slotOffset returns the offset of a record, 0 for a free slot
+func slotOffset(data []byte, slot int) int {
+	return int(binary.LittleEndian.Uint16(data[heapHeaderSize+heapSlotSize*slot:]))
+}
+
+// slotSize returns the size of a record
+func slotSize(data []byte, slot int) int {
+	return int(binary.LittleEndian.Uint16(data[heapHeaderSize+heapSlotSize*slot+2:]))
+}
+
+// setSlot sets the offset and size of a record
+func setSlot(data []byte, slot, offset, size int) {
+	binary.LittleEndian.PutUint16(data[heapHeaderSize+heapSlotSize*slot:], uint16(offset))
+	binary.LittleEndian.PutUint16(data[heapHeaderSize+heapSlotSize*slot+2:], uint16(size))
+}
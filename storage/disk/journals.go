@@ -0,0 +1,138 @@
+package disk
+
+import (
+	"encoding/binary"
+	"hash"
+	"hash/crc32"
+	"io"
+
+	"github.com/zefrenchwan/nodz.git/storage"
+)
+
+// A journal is the list of pages of a checkpoint, written and synced before the file changes:
+//
+//	for each page: page id (4 bytes) and content, header page last (id 0),
+//	then trailer: number of pages (4 bytes), crc32 (IEEE) of previous bytes (4 bytes), magic "NZJC".
+//
+// A complete journal means a committed checkpoint: it is written again to the file when opened.
+// An incomplete journal means an interrupted checkpoint, before the file changed: it is discarded.
+
+// journalMagic ends a complete journal
+var journalMagic = []byte("NZJC")
+
+const (
+	// journalEntrySize is the size of a page in the journal
+	journalEntrySize = 4 + pageSize
+	// journalTrailerSize is the size of the trailer of a journal
+	journalTrailerSize = 12
+)
+
+// journalPath returns the path of the journal of a paged file
+func journalPath(path string) string {
+	return path + "-journal"
+}
+
+// scratchPath returns the path of the scratch file of a paged file
+func scratchPath(path string) string {
+	return path + "-scratch"
+}
+
+// journalWriter appends pages to a journal
+type journalWriter struct {
+	// file is the journal
+	file storage.File
+	// checksum of written content
+	checksum hash.Hash32
+	// size is the size of written content
+	size int64
+	// count is the number of written pages
+	count uint32
+}
+
+// write appends a page
+func (jw *journalWriter) write(id pageId, content []byte) error {
+	entry := make([]byte, 4, journalEntrySize)
+	binary.LittleEndian.PutUint32(entry, uint32(id))
+	entry = append(entry, content...)
+	if _, err := jw.file.WriteAt(entry, jw.size); err != nil {
+		return err
+	}
+
+	jw.checksum.Write(entry)
+	jw.size += journalEntrySize
+	jw.count++
+	return nil
+}
+
+// commit writes the trailer and syncs the journal: checkpoint is then committed
+func (jw *journalWriter) commit() error {
+	trailer := binary.LittleEndian.AppendUint32(nil, jw.count)
+	jw.checksum.Write(trailer)
+	trailer = binary.LittleEndian.AppendUint32(trailer, jw.checksum.Sum32())
+	trailer = append(trailer, journalMagic...)
+	if _, err := jw.file.WriteAt(trailer, jw.size); err != nil {
+		return err
+	}
+
+	return jw.file.Sync()
+}
+
+// recoverJournal writes the pages of a complete journal to the file, and empties the journal
+func recoverJournal(file, journal storage.File) error {
+	size, errSize := journal.Size()
+	if errSize != nil || size == 0 {
+		return errSize
+	}
+
+	complete, errCheck := checkJournal(journal, size)
+	if errCheck != nil {
+		return errCheck
+	}
+
+	for position := int64(0); complete && position < size-journalTrailerSize; position += journalEntrySize {
+		entry := make([]byte, journalEntrySize)
+		if _, err := journal.ReadAt(entry, position); err != nil {
+			return err
+		}
+
+		id := int64(binary.LittleEndian.Uint32(entry))
+		if _, err := file.WriteAt(entry[4:], id*pageSize); err != nil {
+			return err
+		}
+	}
+
+	if complete {
+		if err := file.Sync(); err != nil {
+			return err
+		}
+	}
+
+	if err := journal.Truncate(0); err != nil {
+		return err
+	}
+
+	return journal.Sync()
+}
+
+// checkJournal returns true if journal is complete: valid trailer and checksum
+func checkJournal(journal storage.File, size int64) (bool, error) {
+	if size < journalTrailerSize || (size-journalTrailerSize)%journalEntrySize != 0 {
+		return false, nil
+	}
+
+	trailer := make([]byte, journalTrailerSize)
+	if _, err := journal.ReadAt(trailer, size-journalTrailerSize); err != nil {
+		return false, err
+	} else if string(trailer[8:]) != string(journalMagic) {
+		return false, nil
+	} else if int64(binary.LittleEndian.Uint32(trailer))*journalEntrySize != size-journalTrailerSize {
+		return false, nil
+	}
+
+	checksum := crc32.NewIEEE()
+	if _, err := io.Copy(checksum, io.NewSectionReader(journal, 0, size-journalTrailerSize+4)); err != nil {
+		return false, err
+	}
+
+	return checksum.Sum32() == binary.LittleEndian.Uint32(trailer[4:]), nil
+}
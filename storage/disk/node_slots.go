@@ -0,0 +1,134 @@
+package disk
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Node slots are fixed size records, so that a node number gives its position:
+//
+//	state (1 byte, 1 for a used slot), padding, payload record (8 bytes), first adjacency block (8 bytes),
+//	incoming, outgoing and undirected degrees (8 bytes each).
+//
+// A free slot keeps the next free slot plus one in place of the first adjacency block.
+
+const (
+	// nodeSlotSize is the size of a node slot
+	nodeSlotSize = 64
+	// slotsPerPage is the number of node slots in a page
+	slotsPerPage = pageSize / nodeSlotSize
+	// usedSlot is the state of a slot with a node
+	usedSlot = 1
+)
+
+// nodeSlot is the content of a node slot
+type nodeSlot struct {
+	// used is true for a slot with a node
+	used bool
+	// payload is the record of the encoded node
+	payload recordId
+	// head is the first adjacency block, or the next free slot plus one for a free slot
+	head recordId
+	// incoming is the number of directed links to the node
+	incoming int64
+	// outgoing is the number of directed links from the node
+	outgoing int64
+	// undirected is the number of undirected links of the node
+	undirected int64
+}
+
+// nodeSlots reads and writes node slots
+type nodeSlots struct {
+	// pager reads and writes pages
+	pager *pager
+	// pages are the pages of slots
+	pages pageArray
+}
+
+// newNodeSlots returns the node slots of the paged file
+func newNodeSlots(p *pager) nodeSlots {
+	return nodeSlots{pager: p, pages: pageArray{pager: p, root: &p.header.slotsRoot}}
+}
+
+// get returns the slot of a node
+func (ns nodeSlots) get(node uint64) (nodeSlot, error) {
+	var result nodeSlot
+	if node >= ns.pager.header.nextNode {
+		return result, fmt.Errorf("invalid node %d", node)
+	}
+
+	id, errPage := ns.pages.get(node/slotsPerPage, false)
+	if errPage != nil {
+		return result, errPage
+	} else if id == 0 {
+		return result, fmt.Errorf("missing page for node %d", node)
+	}
+
+	errRead := ns.pager.read(id, func(data []byte) error {
+		content := data[(node%slotsPerPage)*nodeSlotSize:]
+		result.used = content[0] == usedSlot
+		result.payload = recordId(binary.LittleEndian.Uint64(content[8:]))
+		result.head = recordId(binary.LittleEndian.Uint64(content[16:]))
+		result.incoming = int64(binary.LittleEndian.Uint64(content[24:]))
+		result.outgoing = int64(binary.LittleEndian.Uint64(content[32:]))
+		result.undirected = int64(binary.LittleEndian.Uint64(content[40:]))
+		return nil
+	})
+
+	return result, errRead
+}
+
+// set writes the slot of a node
+func (ns nodeSlots) set(node uint64, slot nodeSlot) error {
+	id, errPage := ns.pages.get(node/slotsPerPage, true)
+	if errPage != nil {
+		return errPage
+	}
+
+	return ns.pager.write(id, func(data []byte) error {
+		content := data[(node%slotsPerPage)*nodeSlotSize : (node%slotsPerPage+1)*nodeSlotSize]
+		clear(content)
+		if slot.used {
+			content[0] = usedSlot
+		}
+
+		binary.LittleEndian.PutUint64(content[8:], uint64(slot.payload))
+		binary.LittleEndian.PutUint64(content[16:], uint64(slot.head))
+		binary.LittleEndian.PutUint64(content[24:], uint64(slot.incoming))
+		binary.LittleEndian.PutUint64(content[32:], uint64(slot.outgoing))
+		binary.LittleEndian.PutUint64(content[40:], uint64(slot.undirected))
+		return nil
+	})
+}
+
+// allocate returns a free slot number, reusing freed slots first
+func (ns nodeSlots) allocate() (uint64, error) {
+	header := &ns.pager.header
+	if header.freeNode == 0 {
+		header.nextNode++
+		return header.nextNode - 1, nil
+	}
+
+	node := header.freeNode - 1
+	slot, errSlot := ns.get(node)
+	if errSlot != nil {
+		return 0, errSlot
+	} else if slot.used {
+		return 0, errors.New("corrupted list of free node slots")
+	}
+
+	header.freeNode = uint64(slot.head)
+	return node, nil
+}
+
+// free adds a slot to the free slots
+func (ns nodeSlots) free(node uint64) error {
+	header := &ns.pager.header
+	if err := ns.set(node, nodeSlot{head: recordId(header.freeNode)}); err != nil {
+		return err
+	}
+
+	header.freeNode = node + 1
+	return nil
+}
@@ -0,0 +1,68 @@
+package disk
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// entriesPerDirectory is the number of page ids in a directory page
+const entriesPerDirectory = pageSize / 4
+
+// maxArraySize is the maximum number of pages of a page array
+const maxArraySize = entriesPerDirectory * entriesPerDirectory
+
+// pageArray maps indexes to pages, with two levels of directory pages.
+// Root page has the ids of directory pages, and each directory page has the ids of the pages
+type pageArray struct {
+	// pager reads and writes pages
+	pager *pager
+	// root is the header field with the root page, 0 for an empty array
+	root *pageId
+}
+
+// get returns the page at index, 0 if there is none.
+// If create is true, missing pages are allocated (zeroed), so that result is never 0
+func (pa pageArray) get(index uint64, create bool) (pageId, error) {
+	if index >= maxArraySize {
+		return 0, fmt.Errorf("index %d exceeds page array capacity", index)
+	} else if *pa.root == 0 && !create {
+		return 0, nil
+	} else if *pa.root == 0 {
+		root, errRoot := pa.pager.allocate()
+		if errRoot != nil {
+			return 0, errRoot
+		}
+
+		*pa.root = root
+	}
+
+	directory, errDirectory := pa.entry(*pa.root, int(index/entriesPerDirectory), create)
+	if errDirectory != nil || directory == 0 {
+		return 0, errDirectory
+	}
+
+	return pa.entry(directory, int(index%entriesPerDirectory), create)
+}
+
+// entry returns the page id at position in a directory page, allocating it if needed and create is true
+func (pa pageArray) entry(directory pageId, position int, create bool) (pageId, error) {
+	result := pageId(0)
+	errRead := pa.pager.read(directory, func(data []byte) error {
+		result = pageId(binary.LittleEndian.Uint32(data[4*position:]))
+		return nil
+	})
+
+	if errRead != nil || result != 0 || !create {
+		return result, errRead
+	}
+
+	allocated, errAllocate := pa.pager.allocate()
+	if errAllocate != nil {
+		return 0, errAllocate
+	}
+
+	return allocated, pa.pager.write(directory, func(data []byte) error {
+		binary.LittleEndian.PutUint32(data[4*position:], uint32(allocated))
+		return nil
+	})
+}
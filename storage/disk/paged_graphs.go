@@ -0,0 +1,762 @@
+package disk
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/zefrenchwan/nodz.git/graphs"
+	"github.com/zefrenchwan/nodz.git/internal"
+	"github.com/zefrenchwan/nodz.git/storage"
+)
+
+// Options are the settings of a paged graph
+type Options[N graphs.Node] struct {
+	// Key returns the key of a node in the index.
+	// Nil means the id of nodes implementing graphs.WithId, and the encoded node otherwise
+	Key func(N) string
+	// CachePages is the number of pages kept in memory, DefaultCachePages if not positive
+	CachePages int
+	// FileSystem opens the files of the graph, the operating system file system if nil
+	FileSystem storage.FileSystem
+}
+
+// PagedGraph is a central structure graph stored in a paged file, so that it may be larger than memory.
+// Nodes are fixed size slots with their degrees, their payload and their links are records of the file.
+// Links of a node are in adjacency blocks, and node keys are resolved to slots with an on disk hash index.
+// Pages are read and written through a least recently used cache.
+//
+// Changes are in the file once flushed (Flush, Checkpoint or Close), and flushes are atomic:
+// after a crash, the file is the graph as of the last flush. For durable changes, use a write-ahead log (package wal).
+// Graph is safe for concurrent use, operations are serialized.
+// Iterators read the file lazily: they should not be used across changes of the graph.
+type PagedGraph[N graphs.Node, L graphs.Link[N]] struct {
+	// lock serializes operations, reads included because they change the cache
+	lock sync.Mutex
+	// pager reads and writes pages of the file
+	pager *pager
+	// index maps node keys to slots
+	index hashIndex
+	// slots are the node slots
+	slots nodeSlots
+	// records stores node payloads and adjacency blocks
+	records heap
+	// links reads and changes adjacency blocks
+	links adjacency
+	// nodeCodec encodes nodes
+	nodeCodec storage.NodeCodec[N]
+	// linkCodec encodes links
+	linkCodec storage.LinkCodec[N, L]
+	// key returns the key of a node
+	key func(N) (string, error)
+	// closed is true once the file is closed
+	closed bool
+}
+
+// Open opens a paged graph file, and creates it if it does not exist.
+// Codecs and key function should be the same each time a file is opened
+func Open[N graphs.Node, L graphs.Link[N]](
+	path string, // path of the file
+	nodeCodec storage.NodeCodec[N], // encodes nodes
+	linkCodec storage.LinkCodec[N, L], // encodes links
+	options Options[N], // settings, zero value for default ones
+) (*PagedGraph[N, L], error) {
+	if nodeCodec == nil || linkCodec == nil {
+		return nil, errors.New("nil codec")
+	}
+
+	p, errOpen := openPager(options.FileSystem, path, options.CachePages)
+	if errOpen != nil {
+		return nil, errOpen
+	}
+
+	records := heap{pager: p}
+	slots := newNodeSlots(p)
+	result := &PagedGraph[N, L]{
+		pager:     p,
+		index:     newHashIndex(p),
+		slots:     slots,
+		records:   records,
+		links:     adjacency{records: records},
+		nodeCodec: nodeCodec,
+		linkCodec: linkCodec,
+	}
+
+	switch {
+	case options.Key != nil:
+		result.key = func(node N) (string, error) { return options.Key(node), nil }
+	default:
+		result.key = func(node N) (string, error) {
+			if identified, ok := any(node).(graphs.WithId); ok {
+				return identified.Id(), nil
+			}
+
+			payload, err := nodeCodec.EncodeNode(node)
+			return string(payload), err
+		}
+	}
+
+	return result, nil
+}
+
+// Flush writes all changes to the file
+func (pg *PagedGraph[N, L]) Flush() error {
+	pg.lock.Lock()
+	defer pg.lock.Unlock()
+	if pg.closed {
+		return errors.New("closed graph")
+	}
+
+	return pg.pager.flush()
+}
+
+// Checkpoint writes all changes to the file, as changes up to offset in a write-ahead log
+func (pg *PagedGraph[N, L]) Checkpoint(offset uint64) error {
+	pg.lock.Lock()
+	defer pg.lock.Unlock()
+	if pg.closed {
+		return errors.New("closed graph")
+	}
+
+	previous := pg.pager.header.checkpoint
+	pg.pager.header.checkpoint = offset
+	if err := pg.pager.flush(); err != nil {
+		pg.pager.header.checkpoint = previous
+		return err
+	}
+
+	return nil
+}
+
+// CheckpointOffset returns the offset of the last checkpoint, 0 if none
+func (pg *PagedGraph[N, L]) CheckpointOffset() uint64 {
+	pg.lock.Lock()
+	defer pg.lock.Unlock()
+	return pg.pager.header.checkpoint
+}
+
+// Close flushes changes and closes the file. Graph should not be used after
+func (pg *PagedGraph[N, L]) Close() error {
+	pg.lock.Lock()
+	defer pg.lock.Unlock()
+	if pg.closed {
+		return nil
+	}
+
+	pg.closed = true
+	return pg.pager.close()
+}
+
+// Discard closes the file without writing changes since last flush, as a crash would.
+// Graph should not be used after
+func (pg *PagedGraph[N, L]) Discard() error {
+	pg.lock.Lock()
+	defer pg.lock.Unlock()
+	if pg.closed {
+		return nil
+	}
+
+	pg.closed = true
+	return pg.pager.discard()
+}
+
+// NodesCount returns the number of nodes
+func (pg *PagedGraph[N, L]) NodesCount() int64 {
+	pg.lock.Lock()
+	defer pg.lock.Unlock()
+	return int64(pg.pager.header.nodesCount)
+}
+
+// LinksCount returns the number of links, undirected links count once
+func (pg *PagedGraph[N, L]) LinksCount() int64 {
+	pg.lock.Lock()
+	defer pg.lock.Unlock()
+	return int64(pg.pager.header.linksCount)
+}
+
+// CacheStats returns the counters of the page cache since the file was opened
+func (pg *PagedGraph[N, L]) CacheStats() CacheStats {
+	pg.lock.Lock()
+	defer pg.lock.Unlock()
+	return pg.pager.stats
+}
+
+// AddNode adds a node if it did not exist, does nothing otherwise
+func (pg *PagedGraph[N, L]) AddNode(node N) error {
+	pg.lock.Lock()
+	defer pg.lock.Unlock()
+	if pg.closed {
+		return errors.New("closed graph")
+	}
+
+	_, err := pg.upsertNode(node)
+	return err
+}
+
+// ValidateNode returns an error if node cannot be stored: its key is too large, or it cannot be encoded
+func (pg *PagedGraph[N, L]) ValidateNode(node N) error {
+	if _, err := pg.nodeKey(node); err != nil {
+		return err
+	}
+
+	_, err := pg.nodeCodec.EncodeNode(node)
+	return err
+}
+
+// ValidateLink returns an error if link cannot be stored: one of its extremities cannot, or it cannot be encoded
+func (pg *PagedGraph[N, L]) ValidateLink(link L) error {
+	if err := pg.ValidateNode(link.Source()); err != nil {
+		return err
+	} else if err := pg.ValidateNode(link.Destination()); err != nil {
+		return err
+	}
+
+	_, err := pg.linkCodec.EncodeLink(link)
+	return err
+}
+
+// RemoveNode removes a node and all its links
+func (pg *PagedGraph[N, L]) RemoveNode(node N) error {
+	pg.lock.Lock()
+	defer pg.lock.Unlock()
+	if pg.closed {
+		return errors.New("closed graph")
+	}
+
+	key, errKey := pg.key(node)
+	if errKey != nil {
+		return errKey
+	}
+
+	number, found, errFind := pg.index.find(key)
+	if errFind != nil || !found {
+		return errFind
+	}
+
+	slot, errSlot := pg.slots.get(number)
+	if errSlot != nil {
+		return errSlot
+	}
+
+	// entries are read first, because removing the counterparts changes blocks
+	entries := make([]adjacencyEntry, 0)
+	errWalk := pg.links.walk(slot.head, func(_, _ recordId, block adjacencyBlock) (bool, error) {
+		for _, entry := range block.entries {
+			entry.payload = bytes.Clone(entry.payload)
+			entries = append(entries, entry)
+		}
+
+		return false, nil
+	})
+
+	if errWalk != nil {
+		return errWalk
+	}
+
+	for _, entry := range entries {
+		if entry.other == number {
+			// loops are in the blocks of the node only, and counted once as an outgoing or undirected link
+			if entry.kind != incomingEntry {
+				pg.pager.header.linksCount--
+			}
+
+			continue
+		}
+
+		pg.pager.header.linksCount--
+		other, errOther := pg.slots.get(entry.other)
+		if errOther != nil {
+			return errOther
+		}
+
+		_, removed, errRemove := pg.links.remove(&other, func(candidate adjacencyEntry) (bool, error) {
+			return candidate.counterpart(entry, number), nil
+		})
+
+		if errRemove != nil {
+			return errRemove
+		} else if !removed {
+			return fmt.Errorf("missing link from node %d in node %d", number, entry.other)
+		}
+
+		changeDegree(&other, entry.kind.mirror(), -1)
+		if err := pg.slots.set(entry.other, other); err != nil {
+			return err
+		}
+	}
+
+	if err := pg.links.clear(&slot); err != nil {
+		return err
+	} else if err := pg.records.remove(slot.payload); err != nil {
+		return err
+	} else if err := pg.index.remove(key); err != nil {
+		return err
+	} else if err := pg.slots.free(number); err != nil {
+		return err
+	}
+
+	pg.pager.header.nodesCount--
+	return nil
+}
+
+// AddLink adds a link (and its extremities) if not already here
+func (pg *PagedGraph[N, L]) AddLink(link L) error {
+	pg.lock.Lock()
+	defer pg.lock.Unlock()
+	if pg.closed {
+		return errors.New("closed graph")
+	}
+
+	// destination is checked before source is added, so that a refused link adds no node
+	if _, err := pg.nodeKey(link.Destination()); err != nil {
+		return err
+	}
+
+	source, errSource := pg.upsertNode(link.Source())
+	if errSource != nil {
+		return errSource
+	}
+
+	destination, errDestination := pg.upsertNode(link.Destination())
+	if errDestination != nil {
+		return errDestination
+	}
+
+	sourceSlot, errSlot := pg.slots.get(source)
+	if errSlot != nil {
+		return errSlot
+	}
+
+	if _, found, err := pg.links.find(sourceSlot, pg.sameLink(source, destination, link)); err != nil || found {
+		return err
+	}
+
+	payload, errPayload := pg.linkCodec.EncodeLink(link)
+	if errPayload != nil {
+		return errPayload
+	}
+
+	entry := adjacencyEntry{kind: sourceEntry, other: destination, payload: payload}
+	if link.IsDirected() {
+		entry.kind = outgoingEntry
+	}
+
+	if err := pg.links.add(&sourceSlot, entry); err != nil {
+		return err
+	}
+
+	changeDegree(&sourceSlot, entry.kind, 1)
+	pg.pager.header.linksCount++
+	// undirected loops are stored once, directed loops are also an incoming link of the node
+	if source == destination && !link.IsDirected() {
+		return pg.slots.set(source, sourceSlot)
+	} else if source == destination {
+		counterpart := adjacencyEntry{kind: incomingEntry, other: source, payload: payload}
+		if err := pg.links.add(&sourceSlot, counterpart); err != nil {
+			return err
+		}
+
+		changeDegree(&sourceSlot, incomingEntry, 1)
+		return pg.slots.set(source, sourceSlot)
+	} else if err := pg.slots.set(source, sourceSlot); err != nil {
+		return err
+	}
+
+	destinationSlot, errDestinationSlot := pg.slots.get(destination)
+	if errDestinationSlot != nil {
+		return errDestinationSlot
+	}
+
+	counterpart := adjacencyEntry{kind: entry.kind.mirror(), other: source, payload: payload}
+	if err := pg.links.add(&destinationSlot, counterpart); err != nil {
+		return err
+	}
+
+	changeDegree(&destinationSlot, counterpart.kind, 1)
+	return pg.slots.set(destination, destinationSlot)
+}
+
+// RemoveLink removes a link if any, does nothing otherwise
+func (pg *PagedGraph[N, L]) RemoveLink(link L) error {
+	pg.lock.Lock()
+	defer pg.lock.Unlock()
+	if pg.closed {
+		return errors.New("closed graph")
+	}
+
+	source, destination, found, errFind := pg.extremities(link)
+	if errFind != nil || !found {
+		return errFind
+	}
+
+	sourceSlot, errSlot := pg.slots.get(source)
+	if errSlot != nil {
+		return errSlot
+	}
+
+	entry, removed, errRemove := pg.links.remove(&sourceSlot, pg.sameLink(source, destination, link))
+	if errRemove != nil || !removed {
+		return errRemove
+	}
+
+	changeDegree(&sourceSlot, entry.kind, -1)
+	pg.pager.header.linksCount--
+	if source == destination && !link.IsDirected() {
+		return pg.slots.set(source, sourceSlot)
+	}
+
+	// counterpart is in the same slot for a loop
+	otherSlot := &sourceSlot
+	if source != destination {
+		if err := pg.slots.set(source, sourceSlot); err != nil {
+			return err
+		}
+
+		destinationSlot, errDestinationSlot := pg.slots.get(destination)
+		if errDestinationSlot != nil {
+			return errDestinationSlot
+		}
+
+		otherSlot = &destinationSlot
+	}
+
+	_, removedCounterpart, errCounterpart := pg.links.remove(otherSlot, func(candidate adjacencyEntry) (bool, error) {
+		return candidate.counterpart(entry, source), nil
+	})
+
+	if errCounterpart != nil {
+		return errCounterpart
+	} else if !removedCounterpart {
+		return fmt.Errorf("missing link from node %d in node %d", source, destination)
+	}
+
+	changeDegree(otherSlot, entry.kind.mirror(), -1)
+	return pg.slots.set(destination, *otherSlot)
+}
+
+// HasLink returns true if the graph contains the same link
+func (pg *PagedGraph[N, L]) HasLink(link L) (bool, error) {
+	pg.lock.Lock()
+	defer pg.lock.Unlock()
+	if pg.closed {
+		return false, errors.New("closed graph")
+	}
+
+	source, destination, found, errFind := pg.extremities(link)
+	if errFind != nil || !found {
+		return false, errFind
+	}
+
+	sourceSlot, errSlot := pg.slots.get(source)
+	if errSlot != nil {
+		return false, errSlot
+	}
+
+	_, found, errLink := pg.links.find(sourceSlot, pg.sameLink(source, destination, link))
+	return found, errLink
+}
+
+// Neighbors returns the neighborhood of a node, nil if node is not in the graph.
+// Links are read from the file while iterating
+func (pg *PagedGraph[N, L]) Neighbors(node N) (graphs.Neighborhood[N, L], error) {
+	pg.lock.Lock()
+	defer pg.lock.Unlock()
+	if pg.closed {
+		return nil, errors.New("closed graph")
+	}
+
+	number, found, errFind := pg.find(node)
+	if errFind != nil || !found {
+		return nil, errFind
+	}
+
+	slot, errSlot := pg.slots.get(number)
+	if errSlot != nil {
+		return nil, errSlot
+	}
+
+	return internal.NeighborsIterator[N, L]{
+		CurrentNode:       node,
+		IncomingCounter:   slot.incoming,
+		OutgoingCounter:   slot.outgoing,
+		UndirectedCounter: slot.undirected,
+		IteratorsFactory: func() graphs.LinksIterator[N, L] {
+			return &pagedLinksIterator[N, L]{graph: pg, center: node, number: number, next: slot.head}
+		},
+	}, nil
+}
+
+// AllNodes returns an iterator over nodes, read from the file while iterating
+func (pg *PagedGraph[N, L]) AllNodes() (graphs.NodesIterator[N], error) {
+	pg.lock.Lock()
+	defer pg.lock.Unlock()
+	if pg.closed {
+		return nil, errors.New("closed graph")
+	}
+
+	return &pagedNodesIterator[N, L]{graph: pg}, nil
+}
+
+// nodeKey returns the key of a node, or an error if the index cannot hold it
+func (pg *PagedGraph[N, L]) nodeKey(node N) (string, error) {
+	key, errKey := pg.key(node)
+	if errKey != nil {
+		return "", errKey
+	}
+
+	return key, checkKeySize(key)
+}
+
+// upsertNode returns the slot of a node, and adds it if it was not in the graph.
+// Node is checked before any change, so that a refused node is not partially added
+func (pg *PagedGraph[N, L]) upsertNode(node N) (uint64, error) {
+	key, errKey := pg.nodeKey(node)
+	if errKey != nil {
+		return 0, errKey
+	}
+
+	number, found, errFind := pg.index.find(key)
+	if errFind != nil || found {
+		return number, errFind
+	}
+
+	payload, errPayload := pg.nodeCodec.EncodeNode(node)
+	if errPayload != nil {
+		return 0, errPayload
+	}
+
+	record, errInsert := pg.records.insert(payload)
+	if errInsert != nil {
+		return 0, errInsert
+	}
+
+	number, errAllocate := pg.slots.allocate()
+	if errAllocate != nil {
+		return 0, errAllocate
+	} else if err := pg.slots.set(number, nodeSlot{used: true, payload: record}); err != nil {
+		return 0, err
+	} else if err := pg.index.insert(key, number); err != nil {
+		return 0, err
+	}
+
+	pg.pager.header.nodesCount++
+	return number, nil
+}
+
+// find returns the slot of a node, false if node is not in the graph
+func (pg *PagedGraph[N, L]) find(node N) (uint64, bool, error) {
+	key, errKey := pg.key(node)
+	if errKey != nil {
+		return 0, false, errKey
+	}
+
+	return pg.index.find(key)
+}
+
+// extremities returns the slots of source and destination of a link, false if one is not in the graph
+func (pg *PagedGraph[N, L]) extremities(link L) (uint64, uint64, bool, error) {
+	source, foundSource, errSource := pg.find(link.Source())
+	if errSource != nil || !foundSource {
+		return 0, 0, false, errSource
+	}
+
+	destination, foundDestination, errDestination := pg.find(link.Destination())
+	return source, destination, foundDestination, errDestination
+}
+
+// sameLink returns a matcher of the entries of source that are the same as link
+func (pg *PagedGraph[N, L]) sameLink(source, destination uint64, link L) func(adjacencyEntry) (bool, error) {
+	return func(entry adjacencyEntry) (bool, error) {
+		if entry.other != destination || (entry.kind == outgoingEntry) != link.IsDirected() || entry.kind == incomingEntry {
+			return false, nil
+		}
+
+		stored, errStored := pg.decodeLink(source, link.Source(), entry)
+		if errStored != nil {
+			return false, errStored
+		}
+
+		return link.SameLink(stored), nil
+	}
+}
+
+// decodeLink returns the link of an entry of a node
+func (pg *PagedGraph[N, L]) decodeLink(number uint64, node N, entry adjacencyEntry) (L, error) {
+	var empty L
+	other := node
+	if entry.other != number {
+		decoded, errOther := pg.decodeNode(entry.other)
+		if errOther != nil {
+			return empty, errOther
+		}
+
+		other = decoded
+	}
+
+	switch entry.kind {
+	case outgoingEntry, sourceEntry:
+		return pg.linkCodec.DecodeLink(node, other, entry.payload)
+	default:
+		return pg.linkCodec.DecodeLink(other, node, entry.payload)
+	}
+}
+
+// decodeNode returns the node of a slot
+func (pg *PagedGraph[N, L]) decodeNode(number uint64) (N, error) {
+	var empty N
+	slot, errSlot := pg.slots.get(number)
+	if errSlot != nil {
+		return empty, errSlot
+	} else if !slot.used {
+		return empty, fmt.Errorf("no node in slot %d", number)
+	}
+
+	payload, errPayload := pg.records.read(slot.payload)
+	if errPayload != nil {
+		return empty, errPayload
+	}
+
+	return pg.nodeCodec.DecodeNode(payload)
+}
+
+// changeDegree changes the degree of a slot for an entry kind
+func changeDegree(slot *nodeSlot, kind entryKind, delta int64) {
+	switch kind {
+	case outgoingEntry:
+		slot.outgoing += delta
+	case incomingEntry:
+		slot.incoming += delta
+	default:
+		slot.undirected += delta
+	}
+}
+
+// pagedNodesIterator iterates over node slots
+type pagedNodesIterator[N graphs.Node, L graphs.Link[N]] struct {
+	// graph to read nodes from
+	graph *PagedGraph[N, L]
+	// next is the next slot to read
+	next uint64
+	// current is the current node
+	current N
+	// hasCurrent is true when current is set
+	hasCurrent bool
+}
+
+// Next moves to the next used slot, if any
+func (it *pagedNodesIterator[N, L]) Next() (bool, error) {
+	it.graph.lock.Lock()
+	defer it.graph.lock.Unlock()
+	it.hasCurrent = false
+	if it.graph.closed {
+		return false, errors.New("closed graph")
+	}
+
+	for ; it.next < it.graph.pager.header.nextNode; it.next++ {
+		slot, errSlot := it.graph.slots.get(it.next)
+		if errSlot != nil {
+			return false, errSlot
+		} else if !slot.used {
+			continue
+		}
+
+		it.next++
+		payload, errPayload := it.graph.records.read(slot.payload)
+		if errPayload != nil {
+			return false, errPayload
+		}
+
+		node, errNode := it.graph.nodeCodec.DecodeNode(payload)
+		if errNode != nil {
+			return false, errNode
+		}
+
+		it.current, it.hasCurrent = node, true
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// Value returns the current node
+func (it *pagedNodesIterator[N, L]) Value() (N, error) {
+	if !it.hasCurrent {
+		var empty N
+		return empty, errors.New("no value to return")
+	}
+
+	return it.current, nil
+}
+
+// pagedLinksIterator iterates over the links of a node, block by block
+type pagedLinksIterator[N graphs.Node, L graphs.Link[N]] struct {
+	// graph to read links from
+	graph *PagedGraph[N, L]
+	// center is the node of the neighborhood
+	center N
+	// number is the slot of center
+	number uint64
+	// next is the next block to read
+	next recordId
+	// links are the links of the current block
+	links []L
+	// index is the position of the current link in links
+	index int
+}
+
+// Next moves to the next link, reading the next block when current one is over
+func (it *pagedLinksIterator[N, L]) Next() (bool, error) {
+	it.index++
+	if it.index < len(it.links) {
+		return true, nil
+	}
+
+	it.graph.lock.Lock()
+	defer it.graph.lock.Unlock()
+	if it.graph.closed {
+		return false, errors.New("closed graph")
+	}
+
+	for it.next != 0 {
+		content, errRead := it.graph.records.read(it.next)
+		if errRead != nil {
+			return false, errRead
+		}
+
+		block, errBlock := decodeBlock(content)
+		if errBlock != nil {
+			return false, errBlock
+		}
+
+		it.next, it.links, it.index = block.next, make([]L, 0, len(block.entries)), 0
+		for _, entry := range block.entries {
+			if entry.kind == incomingEntry {
+				continue
+			}
+
+			link, errLink := it.graph.decodeLink(it.number, it.center, entry)
+			if errLink != nil {
+				return false, errLink
+			}
+
+			it.links = append(it.links, link)
+		}
+
+		if len(it.links) != 0 {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// Value returns the current link
+func (it *pagedLinksIterator[N, L]) Value() (L, error) {
+	if it.index < 0 || it.index >= len(it.links) {
+		var empty L
+		return empty, errors.New("no value to return")
+	}
+
+	return it.links[it.index], nil
+}
@@ -0,0 +1,520 @@
+package disk
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"slices"
+
+	"github.com/zefrenchwan/nodz.git/storage"
+)
+
+// Binary layout of a paged file: fixed size pages, page 0 is the header.
+// Other pages are either free (first 4 bytes are the next free page),
+// heap pages of records, directory pages of page arrays, node slots pages or index buckets.
+// All integers are little endian.
+//
+// The file only changes at checkpoints, so that it is always the content of the last checkpoint.
+// Between checkpoints, changed pages evicted from the cache go to a scratch file.
+// At checkpoint, changed pages are first written to a journal, and then to the file.
+
+// pageSize is the size of any page of the file
+const pageSize = 4096
+
+// magic is the first bytes of any paged file
+var magic = []byte("NZPG")
+
+// FormatVersion is the version of the paged file format
+const FormatVersion byte = 2
+
+// DefaultCachePages is the number of pages kept in memory when not set (4 MB)
+const DefaultCachePages = 1024
+
+// pageId is the number of a page in the file. Page 0 is the header, so 0 also means no page
+type pageId uint32
+
+// header is the content of page 0, kept in memory and written at each flush
+type header struct {
+	// pagesCount is the number of pages of the file, header included
+	pagesCount uint32
+	// freePage is the first page of the list of free pages, 0 for none
+	freePage pageId
+	// slotsRoot is the root page of the array of node slots pages
+	slotsRoot pageId
+	// bucketsRoot is the root page of the array of index buckets
+	bucketsRoot pageId
+	// heapTail is the heap page new records go to
+	heapTail pageId
+	// nodesCount is the number of nodes of the graph
+	nodesCount uint64
+	// linksCount is the number of links of the graph, undirected links count once
+	linksCount uint64
+	// nextNode is the number of node slots ever used
+	nextNode uint64
+	// freeNode is the first free node slot plus one, 0 for none
+	freeNode uint64
+	// indexLevel is the level of the linear hashing index: there are at least 2^level buckets
+	indexLevel uint64
+	// indexSplit is the next bucket to split
+	indexSplit uint64
+	// indexEntries is the number of keys in the index
+	indexEntries uint64
+	// checkpoint is the offset of the last change in the file, for write-ahead logs
+	checkpoint uint64
+}
+
+// headerSize is the size of the encoded header, checksum included
+const headerSize = 100
+
+// encode writes the header at the beginning of content
+func (h header) encode(content []byte) {
+	clear(content[:headerSize])
+	copy(content, magic)
+	content[4] = FormatVersion
+	binary.LittleEndian.PutUint32(content[8:], pageSize)
+	binary.LittleEndian.PutUint32(content[12:], h.pagesCount)
+	binary.LittleEndian.PutUint32(content[16:], uint32(h.freePage))
+	binary.LittleEndian.PutUint32(content[20:], uint32(h.slotsRoot))
+	binary.LittleEndian.PutUint32(content[24:], uint32(h.bucketsRoot))
+	binary.LittleEndian.PutUint32(content[28:], uint32(h.heapTail))
+	for index, value := range []uint64{h.nodesCount, h.linksCount, h.nextNode, h.freeNode, h.indexLevel, h.indexSplit, h.indexEntries, h.checkpoint} {
+		binary.LittleEndian.PutUint64(content[32+8*index:], value)
+	}
+
+	binary.LittleEndian.PutUint32(content[headerSize-4:], crc32.ChecksumIEEE(content[:headerSize-4]))
+}
+
+// decodeHeader reads a header written by encode
+func decodeHeader(content []byte) (header, error) {
+	var result header
+	if len(content) < headerSize || string(content[:len(magic)]) != string(magic) {
+		return result, errors.New("not a nodz paged file")
+	} else if version := content[4]; version != FormatVersion {
+		return result, fmt.Errorf("unsupported paged file version %d", version)
+	} else if size := binary.LittleEndian.Uint32(content[8:]); size != pageSize {
+		return result, fmt.Errorf("unsupported page size %d", size)
+	} else if binary.LittleEndian.Uint32(content[headerSize-4:]) != crc32.ChecksumIEEE(content[:headerSize-4]) {
+		return result, errors.New("checksum mismatch, corrupted header")
+	}
+
+	result.pagesCount = binary.LittleEndian.Uint32(content[12:])
+	result.freePage = pageId(binary.LittleEndian.Uint32(content[16:]))
+	result.slotsRoot = pageId(binary.LittleEndian.Uint32(content[20:]))
+	result.bucketsRoot = pageId(binary.LittleEndian.Uint32(content[24:]))
+	result.heapTail = pageId(binary.LittleEndian.Uint32(content[28:]))
+	values := []*uint64{&result.nodesCount, &result.linksCount, &result.nextNode, &result.freeNode, &result.indexLevel, &result.indexSplit, &result.indexEntries, &result.checkpoint}
+	for index, value := range values {
+		*value = binary.LittleEndian.Uint64(content[32+8*index:])
+	}
+
+	return result, nil
+}
+
+// CacheStats are the counters of the page cache
+type CacheStats struct {
+	// Hits is the number of pages found in cache
+	Hits int64
+	// Misses is the number of pages read from the file
+	Misses int64
+	// Evictions is the number of pages removed from the cache to make room
+	Evictions int64
+	// Spills is the number of changed pages written to the scratch file when evicted
+	Spills int64
+	// Writes is the number of pages written to the file at checkpoints
+	Writes int64
+}
+
+// page is a page of the file, in cache
+type page struct {
+	// id of the page
+	id pageId
+	// data is the content of the page
+	data []byte
+	// dirty is true if data changed since last checkpoint
+	dirty bool
+	// pins is the number of current uses, pinned pages are not evicted
+	pins int
+	// newer and older are the neighbors in the recency list
+	newer, older *page
+}
+
+// pager reads and writes pages of a file through a least recently used cache.
+// Dirty pages go to the scratch file when evicted, and all of them to the file at checkpoint
+type pager struct {
+	// files opens the scratch file
+	files storage.FileSystem
+	// path is the path of the file
+	path string
+	// file is the paged file
+	file storage.File
+	// journal is the journal of checkpoints
+	journal storage.File
+	// scratch is the scratch file, nil until a page is spilled
+	scratch storage.File
+	// header is the content of page 0
+	header header
+	// capacity is the number of pages to keep in cache, pinned pages may exceed it
+	capacity int
+	// pages are the cached pages
+	pages map[pageId]*page
+	// spilled are the positions of dirty pages in the scratch file
+	spilled map[pageId]int64
+	// newest and oldest are the extremities of the recency list
+	newest, oldest *page
+	// stats counts cache events
+	stats CacheStats
+}
+
+// openPager opens a paged file, and creates it if it does not exist.
+// An interrupted checkpoint is completed or discarded first, depending on its journal
+func openPager(files storage.FileSystem, path string, capacity int) (*pager, error) {
+	if files == nil {
+		files = storage.OSFileSystem{}
+	}
+
+	if capacity <= 0 {
+		capacity = DefaultCachePages
+	}
+
+	file, errOpen := files.OpenFile(path)
+	if errOpen != nil {
+		return nil, errOpen
+	}
+
+	journal, errJournal := files.OpenFile(journalPath(path))
+	if errJournal != nil {
+		file.Close()
+		return nil, errJournal
+	}
+
+	result := &pager{
+		files:    files,
+		path:     path,
+		file:     file,
+		journal:  journal,
+		capacity: capacity,
+		pages:    make(map[pageId]*page),
+		spilled:  make(map[pageId]int64),
+	}
+
+	if err := result.load(); err != nil {
+		return nil, errors.Join(err, file.Close(), journal.Close())
+	}
+
+	return result, nil
+}
+
+// load recovers the journal, and reads the header (or writes it for a new file)
+func (p *pager) load() error {
+	if err := recoverJournal(p.file, p.journal); err != nil {
+		return err
+	}
+
+	size, errSize := p.file.Size()
+	if errSize != nil {
+		return errSize
+	} else if size == 0 {
+		// new file: header is written at once, so that the file is valid
+		p.header.pagesCount = 1
+		return p.flush()
+	}
+
+	content := make([]byte, headerSize)
+	if _, err := p.file.ReadAt(content, 0); err != nil {
+		return fmt.Errorf("not a nodz paged file: %w", err)
+	}
+
+	decoded, errDecode := decodeHeader(content)
+	p.header = decoded
+	return errDecode
+}
+
+// read calls reader with the content of a page, content should not be kept
+func (p *pager) read(id pageId, reader func(data []byte) error) error {
+	current, errGet := p.get(id)
+	if errGet != nil {
+		return errGet
+	}
+
+	defer p.release(current)
+	return reader(current.data)
+}
+
+// write calls writer with the content of a page to change it, page is marked as dirty
+func (p *pager) write(id pageId, writer func(data []byte) error) error {
+	current, errGet := p.get(id)
+	if errGet != nil {
+		return errGet
+	}
+
+	defer p.release(current)
+	current.dirty = true
+	return writer(current.data)
+}
+
+// get returns a pinned page, from cache, from the scratch file or from the file
+func (p *pager) get(id pageId) (*page, error) {
+	if id == 0 || uint32(id) >= p.header.pagesCount {
+		return nil, fmt.Errorf("invalid page %d", id)
+	}
+
+	if current, found := p.pages[id]; found {
+		p.stats.Hits++
+		p.unlink(current)
+		p.pushNewest(current)
+		current.pins++
+		return current, nil
+	}
+
+	p.stats.Misses++
+	if err := p.makeRoom(); err != nil {
+		return nil, err
+	}
+
+	current := &page{id: id, pins: 1}
+	content, errContent := p.content(id)
+	if errContent != nil {
+		return nil, errContent
+	}
+
+	_, current.dirty = p.spilled[id]
+	current.data = content
+	p.pages[id] = current
+	p.pushNewest(current)
+	return current, nil
+}
+
+// content returns the current content of a page, from cache, from the scratch file or from the file
+func (p *pager) content(id pageId) ([]byte, error) {
+	if current, found := p.pages[id]; found {
+		return current.data, nil
+	}
+
+	result := make([]byte, pageSize)
+	source, position := p.file, int64(id)*pageSize
+	if spilled, found := p.spilled[id]; found {
+		source, position = p.scratch, spilled
+	}
+
+	// pages allocated after the last checkpoint may be after the end of the file
+	if _, err := source.ReadAt(result, position); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// release unpins a page
+func (p *pager) release(current *page) {
+	current.pins--
+}
+
+// allocate returns a new zeroed page, reusing free pages first
+func (p *pager) allocate() (pageId, error) {
+	if id := p.header.freePage; id != 0 {
+		errWrite := p.write(id, func(data []byte) error {
+			p.header.freePage = pageId(binary.LittleEndian.Uint32(data))
+			clear(data)
+			return nil
+		})
+
+		return id, errWrite
+	}
+
+	if err := p.makeRoom(); err != nil {
+		return 0, err
+	}
+
+	id := pageId(p.header.pagesCount)
+	p.header.pagesCount++
+	current := &page{id: id, data: make([]byte, pageSize), dirty: true}
+	p.pages[id] = current
+	p.pushNewest(current)
+	return id, nil
+}
+
+// free adds a page to the list of free pages
+func (p *pager) free(id pageId) error {
+	return p.write(id, func(data []byte) error {
+		clear(data)
+		binary.LittleEndian.PutUint32(data, uint32(p.header.freePage))
+		p.header.freePage = id
+		return nil
+	})
+}
+
+// makeRoom evicts the least recently used pages not in use, until there is room for a page
+func (p *pager) makeRoom() error {
+	for current := p.oldest; current != nil && len(p.pages) >= p.capacity; {
+		newer := current.newer
+		if current.pins == 0 {
+			if err := p.spill(current); err != nil {
+				return err
+			}
+
+			p.unlink(current)
+			delete(p.pages, current.id)
+			p.stats.Evictions++
+		}
+
+		current = newer
+	}
+
+	return nil
+}
+
+// spill writes a dirty page to the scratch file, the file itself only changes at checkpoint
+func (p *pager) spill(current *page) error {
+	if !current.dirty {
+		return nil
+	} else if p.scratch == nil {
+		scratch, errScratch := p.files.OpenFile(scratchPath(p.path))
+		if errScratch != nil {
+			return errScratch
+		} else if err := scratch.Truncate(0); err != nil {
+			return errors.Join(err, scratch.Close())
+		}
+
+		p.scratch = scratch
+	}
+
+	position, found := p.spilled[current.id]
+	if !found {
+		position = int64(len(p.spilled)) * pageSize
+	}
+
+	if _, err := p.scratch.WriteAt(current.data, position); err != nil {
+		return err
+	}
+
+	p.spilled[current.id] = position
+	p.stats.Spills++
+	return nil
+}
+
+// flush is a checkpoint: dirty pages and header are written to the journal, then to the file.
+// Once the journal is synced, checkpoint is complete even if writing the file fails (see recoverJournal)
+func (p *pager) flush() error {
+	ids := make([]pageId, 0, len(p.spilled))
+	for id := range p.spilled {
+		ids = append(ids, id)
+	}
+
+	for id, current := range p.pages {
+		if _, found := p.spilled[id]; current.dirty && !found {
+			ids = append(ids, id)
+		}
+	}
+
+	slices.Sort(ids)
+	headerContent := make([]byte, pageSize)
+	p.header.encode(headerContent)
+
+	journal := journalWriter{file: p.journal, checksum: crc32.NewIEEE()}
+	if err := p.journal.Truncate(0); err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		content, errContent := p.content(id)
+		if errContent != nil {
+			return errContent
+		} else if err := journal.write(id, content); err != nil {
+			return err
+		}
+	}
+
+	if err := journal.write(0, headerContent); err != nil {
+		return err
+	} else if err := journal.commit(); err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		content, errContent := p.content(id)
+		if errContent != nil {
+			return errContent
+		} else if _, err := p.file.WriteAt(content, int64(id)*pageSize); err != nil {
+			return err
+		}
+	}
+
+	if _, err := p.file.WriteAt(headerContent, 0); err != nil {
+		return err
+	} else if err := p.file.Sync(); err != nil {
+		return err
+	} else if err := p.journal.Truncate(0); err != nil {
+		return err
+	} else if err := p.journal.Sync(); err != nil {
+		return err
+	}
+
+	for _, current := range p.pages {
+		current.dirty = false
+	}
+
+	p.stats.Writes += int64(len(ids)) + 1
+	clear(p.spilled)
+	if p.scratch != nil {
+		return p.scratch.Truncate(0)
+	}
+
+	return nil
+}
+
+// close flushes and closes the files. Journal and scratch file are removed once empty
+func (p *pager) close() error {
+	errFlush := p.flush()
+	if errClose := p.discard(); errFlush != nil || errClose != nil {
+		return errors.Join(errFlush, errClose)
+	} else if p.scratch != nil {
+		if err := p.files.Remove(scratchPath(p.path)); err != nil {
+			return err
+		}
+	}
+
+	return p.files.Remove(journalPath(p.path))
+}
+
+// discard closes the files without flush
+func (p *pager) discard() error {
+	result := errors.Join(p.file.Close(), p.journal.Close())
+	if p.scratch != nil {
+		result = errors.Join(result, p.scratch.Close())
+	}
+
+	return result
+}
+
+// pushNewest adds a page at the newest end of the recency list
+func (p *pager) pushNewest(current *page) {
+	current.older = p.newest
+	current.newer = nil
+	if p.newest != nil {
+		p.newest.newer = current
+	}
+
+	p.newest = current
+	if p.oldest == nil {
+		p.oldest = current
+	}
+}
+
+// unlink removes a page from the recency list
+func (p *pager) unlink(current *page) {
+	if current.newer != nil {
+		current.newer.older = current.older
+	} else {
+		p.newest = current.older
+	}
+
+	if current.older != nil {
+		current.older.newer = current.newer
+	} else {
+		p.oldest = current.newer
+	}
+
+	current.newer, current.older = nil, nil
+}
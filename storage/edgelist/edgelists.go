@@ -0,0 +1,411 @@
+package edgelist
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/zefrenchwan/nodz.git/graphs"
+	"github.com/zefrenchwan/nodz.git/storage"
+)
+
+// Options defines the text layout of edge lists and adjacency lists.
+// Public datasets (SNAP, KONECT) use whitespace separated values with comments starting with # or %.
+type Options struct {
+	// Delimiter separates values on a line. Zero value means any whitespace
+	Delimiter rune
+	// CommentPrefixes are the prefixes of lines to ignore. Empty lines are always ignored
+	CommentPrefixes []string
+	// Weighted is true to read or write a third column as the weight of the link
+	Weighted bool
+	// Header is true when first non comment line is a header (to skip when reading, to write when writing)
+	Header bool
+}
+
+// SNAPOptions returns the options for SNAP and KONECT datasets: whitespace, # and % comments
+func SNAPOptions() Options {
+	return Options{
+		CommentPrefixes: []string{"#", "%"},
+	}
+}
+
+// CSVOptions returns the options for a csv file with a header, and a given delimiter (',' if 0)
+func CSVOptions(delimiter rune, weighted bool) Options {
+	if delimiter == 0 {
+		delimiter = ','
+	}
+
+	return Options{
+		Delimiter:       delimiter,
+		CommentPrefixes: []string{"#"},
+		Weighted:        weighted,
+		Header:          true,
+	}
+}
+
+// ReadEdgeList reads an edge list and streams its content into g.
+// Each line is "source destination [weight]", nodes are built once per textual id via nodes factory.
+// Gzip compressed content is detected and decompressed on the fly.
+func ReadEdgeList[N graphs.Node, L graphs.Link[N]](
+	reader io.Reader, // content to read
+	g graphs.CentralStructureGraph[N, L], // graph to fill
+	nodes storage.NodeFactory[N], // builds a node from its id
+	links storage.LinkFactory[N, L], // builds a link from its extremities and weight
+	options Options, // text layout
+) error {
+	if g == nil {
+		return errors.New("nil graph")
+	} else if nodes == nil || links == nil {
+		return errors.New("nil factory")
+	}
+
+	registry := storage.NewNodesRegistry(nodes)
+	return readLines(reader, options, func(lineNumber int, values []string) error {
+		if len(values) < 2 {
+			return fmt.Errorf("line %d: expecting source and destination", lineNumber)
+		} else if len(values[0]) == 0 {
+			return fmt.Errorf("line %d: empty source", lineNumber)
+		} else if len(values[1]) == 0 {
+			return fmt.Errorf("line %d: empty destination", lineNumber)
+		}
+
+		weight := storage.DefaultLinkWeight
+		if options.Weighted && len(values) >= 3 {
+			if w, errW := strconv.ParseFloat(values[2], 64); errW != nil {
+				return fmt.Errorf("line %d: invalid weight %q", lineNumber, values[2])
+			} else {
+				weight = w
+			}
+		}
+
+		source, _, errSource := registry.Get(values[0])
+		if errSource != nil {
+			return fmt.Errorf("line %d: %w", lineNumber, errSource)
+		}
+
+		destination, _, errDest := registry.Get(values[1])
+		if errDest != nil {
+			return fmt.Errorf("line %d: %w", lineNumber, errDest)
+		}
+
+		link, errLink := links(source, destination, weight)
+		if errLink != nil {
+			return fmt.Errorf("line %d: %w", lineNumber, errLink)
+		}
+
+		return g.AddLink(link)
+	})
+}
+
+// ReadAdjacencyList reads an adjacency list and streams its content into g.
+// Each line is "node neighbor1 neighbor2 ...", so a line with one value is an isolated node.
+// Links are built with the default weight.
+func ReadAdjacencyList[N graphs.Node, L graphs.Link[N]](
+	reader io.Reader, // content to read
+	g graphs.CentralStructureGraph[N, L], // graph to fill
+	nodes storage.NodeFactory[N], // builds a node from its id
+	links storage.LinkFactory[N, L], // builds a link from its extremities
+	options Options, // text layout, weight option is ignored
+) error {
+	if g == nil {
+		return errors.New("nil graph")
+	} else if nodes == nil || links == nil {
+		return errors.New("nil factory")
+	}
+
+	registry := storage.NewNodesRegistry(nodes)
+	return readLines(reader, options, func(lineNumber int, values []string) error {
+		if len(values[0]) == 0 {
+			return fmt.Errorf("line %d: empty node", lineNumber)
+		}
+
+		source, _, errSource := registry.Get(values[0])
+		if errSource != nil {
+			return fmt.Errorf("line %d: %w", lineNumber, errSource)
+		} else if err := g.AddNode(source); err != nil {
+			return err
+		}
+
+		for _, value := range values[1:] {
+			if len(value) == 0 {
+				return fmt.Errorf("line %d: empty neighbor", lineNumber)
+			}
+
+			destination, _, errDest := registry.Get(value)
+			if errDest != nil {
+				return fmt.Errorf("line %d: %w", lineNumber, errDest)
+			}
+
+			link, errLink := links(source, destination, storage.DefaultLinkWeight)
+			if errLink != nil {
+				return fmt.Errorf("line %d: %w", lineNumber, errLink)
+			} else if err := g.AddLink(link); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// WriteEdgeList writes each link of g as a line "source destination [weight]".
+// Isolated nodes do not appear in an edge list, use adjacency lists to keep them.
+// Nil identifier uses default naming (see storage.NodesNames), nil weigher writes default weight.
+func WriteEdgeList[N graphs.Node, L graphs.Link[N]](
+	writer io.Writer, // destination of the content
+	g graphs.CentralStructureGraph[N, L], // graph to write
+	identifier storage.NodeIdentifier[N], // names nodes, may be nil
+	weigher storage.LinkWeigher[N, L], // weight of links, may be nil
+	options Options, // text layout
+) error {
+	names, errNames := storage.NewNodesNames(g, identifier)
+	if errNames != nil {
+		return errNames
+	}
+
+	lines := newLineWriter(writer, options)
+	if options.Header {
+		header := []string{"source", "target"}
+		if options.Weighted {
+			header = append(header, "weight")
+		}
+
+		if err := lines.write(header); err != nil {
+			return err
+		}
+	}
+
+	errVisit := storage.VisitLinks(g, func(link L) error {
+		values := make([]string, 0, 3)
+		if source, err := names.Name(link.Source()); err != nil {
+			return err
+		} else {
+			values = append(values, source)
+		}
+
+		if destination, err := names.Name(link.Destination()); err != nil {
+			return err
+		} else {
+			values = append(values, destination)
+		}
+
+		if options.Weighted {
+			weight := storage.DefaultLinkWeight
+			if weigher != nil {
+				weight = weigher(link)
+			}
+
+			values = append(values, strconv.FormatFloat(weight, 'g', -1, 64))
+		}
+
+		return lines.write(values)
+	})
+
+	if errVisit != nil {
+		return errVisit
+	}
+
+	return lines.flush()
+}
+
+// WriteAdjacencyList writes each node of g followed by the destinations of its links.
+// Undirected links appear once, on the line of their source.
+func WriteAdjacencyList[N graphs.Node, L graphs.Link[N]](
+	writer io.Writer, // destination of the content
+	g graphs.CentralStructureGraph[N, L], // graph to write
+	identifier storage.NodeIdentifier[N], // names nodes, may be nil
+	options Options, // text layout, weight and header options are ignored
+) error {
+	names, errNames := storage.NewNodesNames(g, identifier)
+	if errNames != nil {
+		return errNames
+	}
+
+	// lines per node index, first value is the node itself
+	lines := make([][]string, len(names.Nodes()))
+	for index, name := range names.Names() {
+		lines[index] = []string{name}
+	}
+
+	errVisit := storage.VisitLinks(g, func(link L) error {
+		sourceIndex := names.Index(link.Source())
+		if sourceIndex < 0 {
+			return errors.New("link source not in graph")
+		}
+
+		if destination, err := names.Name(link.Destination()); err != nil {
+			return err
+		} else {
+			lines[sourceIndex] = append(lines[sourceIndex], destination)
+		}
+
+		return nil
+	})
+
+	if errVisit != nil {
+		return errVisit
+	}
+
+	content := newLineWriter(writer, options)
+	for _, line := range lines {
+		if err := content.write(line); err != nil {
+			return err
+		}
+	}
+
+	return content.flush()
+}
+
+// readLines reads the content line by line, skips comments, header and empty lines, and splits values.
+// With a delimiter, lines are csv records: values may be quoted, and contain the delimiter.
+// Processor is called with the line number (starting at 1) and the values of the line.
+// Values keep their positions: empty csv fields are empty values, only trailing ones are dropped
+func readLines(reader io.Reader, options Options, processor func(int, []string) error) error {
+	content, errContent := storage.NewDecompressedReader(reader)
+	if errContent != nil {
+		return errContent
+	}
+
+	defer content.Close()
+	if options.Delimiter != 0 {
+		return readRecords(content, options, processor)
+	}
+
+	scanner := bufio.NewScanner(content)
+	// some datasets have very long adjacency lines
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+
+	lineNumber := 0
+	headerSkipped := !options.Header
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || isComment(line, options.CommentPrefixes) {
+			continue
+		} else if !headerSkipped {
+			headerSkipped = true
+			continue
+		}
+
+		values := splitLine(line)
+		if len(values) == 0 {
+			continue
+		} else if err := processor(lineNumber, values); err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
+
+// isComment returns true if line starts with any prefix
+func isComment(line string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if len(prefix) != 0 && strings.HasPrefix(line, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// readRecords reads the content as csv records separated by options delimiter, and skips comments, header and empty records.
+// Processor is called with the line number of the record (starting at 1) and its values, empty fields included but trailing ones
+func readRecords(content io.Reader, options Options, processor func(int, []string) error) error {
+	records := csv.NewReader(content)
+	records.Comma = options.Delimiter
+	records.FieldsPerRecord = -1
+	records.LazyQuotes = true
+	records.TrimLeadingSpace = true
+	records.ReuseRecord = true
+
+	headerSkipped := !options.Header
+	for {
+		record, errRecord := records.Read()
+		if errors.Is(errRecord, io.EOF) {
+			return nil
+		} else if errRecord != nil {
+			return errRecord
+		}
+
+		// empty fields keep their position, so that columns are not shifted
+		values := make([]string, len(record))
+		for index, value := range record {
+			values[index] = strings.TrimSpace(value)
+		}
+
+		for len(values) != 0 && len(values[len(values)-1]) == 0 {
+			values = values[:len(values)-1]
+		}
+
+		lineNumber, _ := records.FieldPos(0)
+		if len(values) == 0 || isComment(values[0], options.CommentPrefixes) {
+			continue
+		} else if !headerSkipped {
+			headerSkipped = true
+			continue
+		} else if err := processor(lineNumber, values); err != nil {
+			return err
+		}
+	}
+}
+
+// splitLine splits a line by whitespaces, and removes quotes
+func splitLine(line string) []string {
+	raw := strings.Fields(line)
+	result := make([]string, 0, len(raw))
+	for _, value := range raw {
+		if len(value) >= 2 && strings.HasPrefix(value, `"`) && strings.HasSuffix(value, `"`) {
+			value = value[1 : len(value)-1]
+		}
+
+		if len(value) != 0 {
+			result = append(result, value)
+		}
+	}
+
+	return result
+}
+
+// lineWriter writes lines of values: csv records with a delimiter, space separated values otherwise
+type lineWriter struct {
+	// buffer writes space separated values, nil with a delimiter
+	buffer *bufio.Writer
+	// records writes csv records, nil without delimiter
+	records *csv.Writer
+}
+
+// newLineWriter returns a line writer to writer, for options delimiter
+func newLineWriter(writer io.Writer, options Options) lineWriter {
+	if options.Delimiter == 0 {
+		return lineWriter{buffer: bufio.NewWriter(writer)}
+	}
+
+	records := csv.NewWriter(writer)
+	records.Comma = options.Delimiter
+	return lineWriter{records: records}
+}
+
+// write writes the values of a line
+func (lw lineWriter) write(values []string) error {
+	if lw.records != nil {
+		return lw.records.Write(values)
+	}
+
+	_, err := lw.buffer.WriteString(strings.Join(values, " ") + "\n")
+	return err
+}
+
+// flush writes buffered lines
+func (lw lineWriter) flush() error {
+	if lw.records != nil {
+		lw.records.Flush()
+		return lw.records.Error()
+	}
+
+	return lw.buffer.Flush()
+}
@@ -0,0 +1,264 @@
+package eventlog
+
+import (
+	"errors"
+	"io"
+	"time"
+
+	"github.com/zefrenchwan/nodz.git/graphs"
+	"github.com/zefrenchwan/nodz.git/storage"
+)
+
+// LogOptions defines how a logged graph writes its records
+type LogOptions struct {
+	// Format of the records
+	Format Format
+	// Clock returns the timestamp of records, time.Now if nil
+	Clock func() time.Time
+	// LastOffset is the offset of the last record already in the log, 0 for a new log.
+	// When not 0, log is appended: binary header is not written again.
+	LastOffset uint64
+}
+
+// LoggedGraph decorates a central structure graph and writes a record for each successful change.
+// Changes go to the decorated graph first, record is written if change succeeded and changed the graph.
+// Properties are changed on the instances of the graph.
+// It is not safe for concurrent use.
+type LoggedGraph[N graphs.Node, L graphs.Link[N]] struct {
+	// graph is the decorated graph
+	graph graphs.CentralStructureGraph[N, L]
+	// records writes the log
+	records *RecordWriter
+	// nodeCodec encodes nodes in records
+	nodeCodec storage.NodeCodec[N]
+	// linkCodec encodes links in records
+	linkCodec storage.LinkCodec[N, L]
+	// clock returns timestamps
+	clock func() time.Time
+	// offset is the offset of the last record
+	offset uint64
+}
+
+// NewLoggedGraph decorates g, records go to writer
+func NewLoggedGraph[N graphs.Node, L graphs.Link[N]](
+	g graphs.CentralStructureGraph[N, L], // decorated graph
+	writer io.Writer, // log destination
+	nodeCodec storage.NodeCodec[N], // encodes nodes
+	linkCodec storage.LinkCodec[N, L], // encodes links
+	options LogOptions, // format, clock and offset
+) (*LoggedGraph[N, L], error) {
+	if g == nil {
+		return nil, errors.New("nil graph")
+	} else if nodeCodec == nil || linkCodec == nil {
+		return nil, errors.New("nil codec")
+	}
+
+	var records *RecordWriter
+	var errRecords error
+	if options.LastOffset == 0 {
+		records, errRecords = NewRecordWriter(writer, options.Format)
+	} else {
+		records, errRecords = NewAppendingRecordWriter(writer, options.Format)
+	}
+
+	if errRecords != nil {
+		return nil, errRecords
+	}
+
+	clock := options.Clock
+	if clock == nil {
+		clock = time.Now
+	}
+
+	return &LoggedGraph[N, L]{
+		graph:     g,
+		records:   records,
+		nodeCodec: nodeCodec,
+		linkCodec: linkCodec,
+		clock:     clock,
+		offset:    options.LastOffset,
+	}, nil
+}
+
+// Offset returns the offset of the last written record
+func (lg *LoggedGraph[N, L]) Offset() uint64 {
+	return lg.offset
+}
+
+// Neighbors returns the neighborhood of a node in the decorated graph
+func (lg *LoggedGraph[N, L]) Neighbors(node N) (graphs.Neighborhood[N, L], error) {
+	return lg.graph.Neighbors(node)
+}
+
+// AllNodes returns the nodes of the decorated graph
+func (lg *LoggedGraph[N, L]) AllNodes() (graphs.NodesIterator[N], error) {
+	return lg.graph.AllNodes()
+}
+
+// AddNode adds a node and logs it, if it was not in the graph
+func (lg *LoggedGraph[N, L]) AddNode(node N) error {
+	existed, errExists := graphs.HasNode(lg.graph, node)
+	if errExists != nil {
+		return errExists
+	} else if err := lg.graph.AddNode(node); err != nil || existed {
+		return err
+	}
+
+	return lg.writeNode(AddNode, node)
+}
+
+// RemoveNode removes a node (and its links) and logs it, if it was in the graph
+func (lg *LoggedGraph[N, L]) RemoveNode(node N) error {
+	existed, errExists := graphs.HasNode(lg.graph, node)
+	if errExists != nil {
+		return errExists
+	} else if err := lg.graph.RemoveNode(node); err != nil || !existed {
+		return err
+	}
+
+	return lg.writeNode(RemoveNode, node)
+}
+
+// AddLink adds a link and logs it, if it was not in the graph
+func (lg *LoggedGraph[N, L]) AddLink(link L) error {
+	_, existed, errFind := graphs.FindLink(lg.graph, link)
+	if errFind != nil {
+		return errFind
+	} else if err := lg.graph.AddLink(link); err != nil || existed {
+		return err
+	}
+
+	return lg.writeLink(Record{Operation: AddLink}, link)
+}
+
+// RemoveLink removes a link and logs it, if it was in the graph
+func (lg *LoggedGraph[N, L]) RemoveLink(link L) error {
+	existing, existed, errFind := graphs.FindLink(lg.graph, link)
+	if errFind != nil {
+		return errFind
+	} else if err := lg.graph.RemoveLink(link); err != nil || !existed {
+		return err
+	}
+
+	return lg.writeLink(Record{Operation: RemoveLink}, existing)
+}
+
+// SetNodeProperty sets a property of node (that should be in the graph and implement WithProperties) and logs it, if value changed
+func (lg *LoggedGraph[N, L]) SetNodeProperty(node N, key, value string) error {
+	return lg.changeNodeProperty(node, key, value, false)
+}
+
+// RemoveNodeProperty removes a property of node (that should be in the graph and implement WithProperties) and logs it, if property existed
+func (lg *LoggedGraph[N, L]) RemoveNodeProperty(node N, key string) error {
+	return lg.changeNodeProperty(node, key, "", true)
+}
+
+// SetLinkProperty sets a property of link (that should be in the graph and implement WithProperties) and logs it, if value changed
+func (lg *LoggedGraph[N, L]) SetLinkProperty(link L, key, value string) error {
+	return lg.changeLinkProperty(link, key, value, false)
+}
+
+// RemoveLinkProperty removes a property of link (that should be in the graph and implement WithProperties) and logs it, if property existed
+func (lg *LoggedGraph[N, L]) RemoveLinkProperty(link L, key string) error {
+	return lg.changeLinkProperty(link, key, "", true)
+}
+
+// changeNodeProperty sets or removes a property of the node instance in the graph, and logs it if it changed
+func (lg *LoggedGraph[N, L]) changeNodeProperty(node N, key, value string, removed bool) error {
+	existing, found, errFind := graphs.FindNode(lg.graph, node)
+	if errFind != nil {
+		return errFind
+	} else if !found {
+		return errors.New("node not in graph")
+	}
+
+	properties, ok := any(existing).(graphs.WithProperties)
+	if !ok {
+		return errors.New("node has no property")
+	} else if !changeProperty(properties, key, value, removed) {
+		return nil
+	}
+
+	payload, errPayload := lg.nodeCodec.EncodeNode(existing)
+	if errPayload != nil {
+		return errPayload
+	}
+
+	return lg.write(Record{Operation: SetProperty, Node: payload, Key: key, Value: value, Removed: removed})
+}
+
+// changeLinkProperty sets or removes a property of the link instance in the graph, and logs it if it changed
+func (lg *LoggedGraph[N, L]) changeLinkProperty(link L, key, value string, removed bool) error {
+	existing, found, errFind := graphs.FindLink(lg.graph, link)
+	if errFind != nil {
+		return errFind
+	} else if !found {
+		return errors.New("link not in graph")
+	}
+
+	properties, ok := any(existing).(graphs.WithProperties)
+	if !ok {
+		return errors.New("link has no property")
+	} else if !changeProperty(properties, key, value, removed) {
+		return nil
+	}
+
+	return lg.writeLink(Record{Operation: SetProperty, OnLink: true, Key: key, Value: value, Removed: removed}, existing)
+}
+
+// writeNode writes a record for a node operation
+func (lg *LoggedGraph[N, L]) writeNode(operation Operation, node N) error {
+	payload, errPayload := lg.nodeCodec.EncodeNode(node)
+	if errPayload != nil {
+		return errPayload
+	}
+
+	return lg.write(Record{Operation: operation, Node: payload})
+}
+
+// writeLink completes record with link payloads, and writes it
+func (lg *LoggedGraph[N, L]) writeLink(record Record, link L) error {
+	if source, err := lg.nodeCodec.EncodeNode(link.Source()); err != nil {
+		return err
+	} else {
+		record.Source = source
+	}
+
+	if destination, err := lg.nodeCodec.EncodeNode(link.Destination()); err != nil {
+		return err
+	} else {
+		record.Destination = destination
+	}
+
+	if payload, err := lg.linkCodec.EncodeLink(link); err != nil {
+		return err
+	} else {
+		record.Link = payload
+	}
+
+	return lg.write(record)
+}
+
+// write sets offset and timestamp of record, and writes it
+func (lg *LoggedGraph[N, L]) write(record Record) error {
+	record.Offset = lg.offset + 1
+	record.Timestamp = lg.clock()
+	if err := lg.records.Write(record); err != nil {
+		return err
+	}
+
+	lg.offset = record.Offset
+	return nil
+}
+
+// changeProperty sets or removes a property, and returns true if it changed
+func changeProperty(properties graphs.WithProperties, key, value string, removed bool) bool {
+	old, had := properties.GetProperty(key)
+	if removed {
+		properties.RemoveProperty(key)
+		return had
+	}
+
+	properties.SetProperty(key, value)
+	return !had || old != value
+}
@@ -0,0 +1,323 @@
+package eventlog
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"time"
+
+	"github.com/zefrenchwan/nodz.git/storage"
+)
+
+// A log is a sequence of records, one per change of a graph.
+// Nodes and links in records are payloads of storage codecs, so records do not depend on graph types.
+//
+// NDJSON format is one JSON record per line, payloads are base64 strings.
+// Binary format is a header (magic "NZLG", format version) and then, for each record:
+// body size (unsigned varint), body, crc32 (IEEE, little endian, 4 bytes) of the body.
+// A log is read the same way no matter its format: format is detected from the first bytes.
+
+// Operation is the change of a record
+type Operation string
+
+const (
+	// AddNode adds Node
+	AddNode Operation = "add_node"
+	// RemoveNode removes Node and its links
+	RemoveNode Operation = "remove_node"
+	// AddLink adds Link from Source to Destination
+	AddLink Operation = "add_link"
+	// RemoveLink removes Link from Source to Destination
+	RemoveLink Operation = "remove_link"
+	// SetProperty sets (or removes) property Key of Node, or of Link if OnLink
+	SetProperty Operation = "set_property"
+)
+
+// Format is the encoding of records
+type Format int
+
+const (
+	// NDJSON writes a JSON record per line, readable and easy to feed downstream
+	NDJSON Format = iota
+	// Binary writes compact records with checksums
+	Binary
+)
+
+// binaryMagic starts binary logs
+var binaryMagic = []byte("NZLG")
+
+// BinaryVersion is the version of the binary format
+const BinaryVersion byte = 1
+
+// maxRecordSize limits the size of a binary record, to detect corrupted content before allocating
+const maxRecordSize = 1 << 30
+
+// Record is a change in a graph
+type Record struct {
+	// Offset is the position of the record in the log, starting at 1
+	Offset uint64 `json:"offset"`
+	// Timestamp is the time of the change
+	Timestamp time.Time `json:"timestamp"`
+	// Operation is the change
+	Operation Operation `json:"op"`
+	// Node is the node payload, for node operations and node properties
+	Node []byte `json:"node,omitempty"`
+	// Source is the payload of the source of the link, for link operations and link properties
+	Source []byte `json:"source,omitempty"`
+	// Destination is the payload of the destination of the link, for link operations and link properties
+	Destination []byte `json:"destination,omitempty"`
+	// Link is the payload of the link, for link operations and link properties
+	Link []byte `json:"link,omitempty"`
+	// OnLink is true for a link property, false for a node property
+	OnLink bool `json:"on_link,omitempty"`
+	// Key is the property key
+	Key string `json:"key,omitempty"`
+	// Value is the new value of the property
+	Value string `json:"value,omitempty"`
+	// Removed is true if the property is removed
+	Removed bool `json:"removed,omitempty"`
+}
+
+// validate returns an error for an unknown operation.
+// Payloads may be empty (for instance, links with no value), so they are not tested
+func (r Record) validate() error {
+	switch r.Operation {
+	case AddNode, RemoveNode, AddLink, RemoveLink, SetProperty:
+		return nil
+	default:
+		return fmt.Errorf("offset %d: unknown operation %q", r.Offset, r.Operation)
+	}
+}
+
+// RecordWriter appends records to a writer, one write per record
+type RecordWriter struct {
+	// writer is the destination
+	writer io.Writer
+	// format of the records
+	format Format
+	// started is true once binary header is written
+	started bool
+}
+
+// NewRecordWriter returns a writer of records in format.
+// For binary format, header is written with the first record.
+// To append to an existing binary log, use NewAppendingRecordWriter.
+func NewRecordWriter(writer io.Writer, format Format) (*RecordWriter, error) {
+	if writer == nil {
+		return nil, errors.New("nil writer")
+	} else if format != NDJSON && format != Binary {
+		return nil, errors.New("unknown format")
+	}
+
+	return &RecordWriter{writer: writer, format: format}, nil
+}
+
+// NewAppendingRecordWriter returns a writer that appends records to an existing log (header is not written again)
+func NewAppendingRecordWriter(writer io.Writer, format Format) (*RecordWriter, error) {
+	result, err := NewRecordWriter(writer, format)
+	if result != nil {
+		result.started = true
+	}
+
+	return result, err
+}
+
+// Write appends a record
+func (rw *RecordWriter) Write(record Record) error {
+	if err := record.validate(); err != nil {
+		return err
+	}
+
+	content := make([]byte, 0, 64)
+	if rw.format == NDJSON {
+		line, errJSON := json.Marshal(record)
+		if errJSON != nil {
+			return errJSON
+		}
+
+		content = append(line, '\n')
+	} else {
+		if !rw.started {
+			content = AppendBinaryHeader(content)
+		}
+
+		content = AppendBinaryRecord(content, record)
+	}
+
+	if _, err := rw.writer.Write(content); err != nil {
+		return err
+	}
+
+	rw.started = true
+	return nil
+}
+
+// AppendBinaryHeader appends the header of a binary log (magic and version) to content
+func AppendBinaryHeader(content []byte) []byte {
+	content = append(content, binaryMagic...)
+	return append(content, BinaryVersion)
+}
+
+// AppendBinaryRecord appends the binary encoding of record (size, body, checksum) to content
+func AppendBinaryRecord(content []byte, record Record) []byte {
+	var encoder storage.PayloadEncoder
+	encoder.PutUvarint(record.Offset)
+	encoder.PutUvarint(uint64(record.Timestamp.UnixNano()))
+	encoder.PutString(string(record.Operation))
+	encoder.PutBytes(record.Node)
+	encoder.PutBytes(record.Source)
+	encoder.PutBytes(record.Destination)
+	encoder.PutBytes(record.Link)
+	encoder.PutBool(record.OnLink)
+	encoder.PutString(record.Key)
+	encoder.PutString(record.Value)
+	encoder.PutBool(record.Removed)
+	body := encoder.Bytes()
+
+	content = binary.AppendUvarint(content, uint64(len(body)))
+	content = append(content, body...)
+	return binary.LittleEndian.AppendUint32(content, crc32.ChecksumIEEE(body))
+}
+
+// ReadBinaryRecord reads the next binary record.
+// It returns io.EOF if there is no more record, io.ErrUnexpectedEOF for a partial record
+func ReadBinaryRecord(reader *bufio.Reader) (Record, error) {
+	var record Record
+	size, errSize := binary.ReadUvarint(reader)
+	if errSize == io.EOF {
+		return record, io.EOF
+	} else if errSize != nil {
+		return record, io.ErrUnexpectedEOF
+	} else if size > maxRecordSize {
+		return record, errors.New("invalid record size")
+	}
+
+	content := make([]byte, size+4)
+	if _, err := io.ReadFull(reader, content); err != nil {
+		return record, io.ErrUnexpectedEOF
+	}
+
+	body := content[:size]
+	if binary.LittleEndian.Uint32(content[size:]) != crc32.ChecksumIEEE(body) {
+		return record, errors.New("invalid record checksum")
+	}
+
+	decoder := storage.NewPayloadDecoder(body)
+	record.Offset = decoder.Uvarint()
+	record.Timestamp = time.Unix(0, int64(decoder.Uvarint()))
+	record.Operation = Operation(decoder.String())
+	record.Node = nilIfEmpty(decoder.Bytes())
+	record.Source = nilIfEmpty(decoder.Bytes())
+	record.Destination = nilIfEmpty(decoder.Bytes())
+	record.Link = nilIfEmpty(decoder.Bytes())
+	record.OnLink = decoder.Bool()
+	record.Key = decoder.String()
+	record.Value = decoder.String()
+	record.Removed = decoder.Bool()
+	return record, decoder.Err()
+}
+
+// nilIfEmpty returns nil for empty payloads, so that binary and json records are equal
+func nilIfEmpty(payload []byte) []byte {
+	if len(payload) == 0 {
+		return nil
+	}
+
+	return payload
+}
+
+// RecordReader iterates over the records of a log, in any format
+type RecordReader struct {
+	// reader is the content of the log
+	reader *bufio.Reader
+	// format is the detected format
+	format Format
+	// lineNumber is the current line for NDJSON
+	lineNumber int
+	// current is the last read record
+	current Record
+	// hasCurrent is true once a record was read
+	hasCurrent bool
+}
+
+// NewRecordReader returns an iterator over the records of reader, format is detected.
+// An empty content is a valid empty log
+func NewRecordReader(reader io.Reader) (*RecordReader, error) {
+	if reader == nil {
+		return nil, errors.New("nil reader")
+	}
+
+	result := &RecordReader{reader: bufio.NewReader(reader), format: NDJSON}
+	header, errPeek := result.reader.Peek(len(binaryMagic) + 1)
+	if len(header) >= len(binaryMagic) && bytes.Equal(header[:len(binaryMagic)], binaryMagic) {
+		if errPeek != nil {
+			return nil, errors.New("invalid binary header")
+		} else if header[len(binaryMagic)] != BinaryVersion {
+			return nil, fmt.Errorf("unsupported log version %d", header[len(binaryMagic)])
+		}
+
+		result.reader.Discard(len(header))
+		result.format = Binary
+	}
+
+	return result, nil
+}
+
+// Format returns the format of the log
+func (rr *RecordReader) Format() Format {
+	return rr.format
+}
+
+// Next reads the next record, and returns false at the end of the log
+func (rr *RecordReader) Next() (bool, error) {
+	rr.hasCurrent = false
+	if rr.format == Binary {
+		record, err := ReadBinaryRecord(rr.reader)
+		if err == io.EOF {
+			return false, nil
+		} else if err != nil {
+			return false, err
+		} else if err := record.validate(); err != nil {
+			return false, err
+		}
+
+		rr.current, rr.hasCurrent = record, true
+		return true, nil
+	}
+
+	for {
+		line, errLine := rr.reader.ReadBytes('\n')
+		if errLine != nil && errLine != io.EOF {
+			return false, errLine
+		}
+
+		rr.lineNumber++
+		if content := bytes.TrimSpace(line); len(content) != 0 {
+			var record Record
+			if err := json.Unmarshal(content, &record); err != nil {
+				return false, fmt.Errorf("line %d: %w", rr.lineNumber, err)
+			} else if err := record.validate(); err != nil {
+				return false, fmt.Errorf("line %d: %w", rr.lineNumber, err)
+			}
+
+			rr.current, rr.hasCurrent = record, true
+			return true, nil
+		} else if errLine == io.EOF {
+			return false, nil
+		}
+	}
+}
+
+// Value returns the current record
+func (rr *RecordReader) Value() (Record, error) {
+	if !rr.hasCurrent {
+		return Record{}, errors.New("no current record")
+	}
+
+	return rr.current, nil
+}
@@ -0,0 +1,278 @@
+package eventlog
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/zefrenchwan/nodz.git/graphs"
+	"github.com/zefrenchwan/nodz.git/internal/local"
+	"github.com/zefrenchwan/nodz.git/storage"
+	"github.com/zefrenchwan/nodz.git/storage/snapshot"
+)
+
+// ReplayOptions limits the records to replay. Default value replays all the records
+type ReplayOptions struct {
+	// AfterOffset skips records until this offset (included), for instance records already in a snapshot
+	AfterOffset uint64
+	// UpToOffset stops after this offset (included), 0 for no limit
+	UpToOffset uint64
+	// UpTo stops after this time (included), zero time for no limit
+	UpTo time.Time
+}
+
+// accepts returns 0 to apply record, -1 to skip it, 1 to stop
+func (ro ReplayOptions) accepts(record Record) int {
+	switch {
+	case ro.UpToOffset != 0 && record.Offset > ro.UpToOffset:
+		return 1
+	case !ro.UpTo.IsZero() && record.Timestamp.After(ro.UpTo):
+		return 1
+	case record.Offset <= ro.AfterOffset:
+		return -1
+	default:
+		return 0
+	}
+}
+
+// Replay applies the records of a log to g (see Applier), and returns the offset of the last applied record.
+func Replay[N graphs.Node, L graphs.Link[N]](
+	reader io.Reader, // log content, any format
+	g graphs.CentralStructureGraph[N, L], // graph to change, may not be empty (loaded from a snapshot, for instance)
+	nodeCodec storage.NodeCodec[N], // decodes nodes
+	linkCodec storage.LinkCodec[N, L], // decodes links
+	options ReplayOptions, // limits of the replay
+) (uint64, error) {
+	applier, errApplier := NewApplier(g, nodeCodec, linkCodec)
+	if errApplier != nil {
+		return 0, errApplier
+	}
+
+	records, errRecords := NewRecordReader(reader)
+	if errRecords != nil {
+		return 0, errRecords
+	}
+
+	var last uint64
+	for {
+		has, errNext := records.Next()
+		if errNext != nil {
+			return last, errNext
+		} else if !has {
+			return last, nil
+		}
+
+		record, errRecord := records.Value()
+		if errRecord != nil {
+			return last, errRecord
+		}
+
+		switch options.accepts(record) {
+		case 1:
+			return last, nil
+		case -1:
+			continue
+		}
+
+		if err := applier.Apply(record); err != nil {
+			return last, fmt.Errorf("offset %d: %w", record.Offset, err)
+		}
+
+		last = record.Offset
+	}
+}
+
+// Compact replays a log into a new map graph, and saves it as a snapshot.
+// Result is the offset of the last record in the snapshot: next log should start after it.
+func Compact[N graphs.Node, L graphs.Link[N]](
+	reader io.Reader, // log content, any format
+	writer io.Writer, // snapshot destination
+	nodeCodec storage.NodeCodec[N], // codec of nodes
+	linkCodec storage.LinkCodec[N, L], // codec of links
+	options ReplayOptions, // limits of the records to compact
+) (uint64, error) {
+	g := local.NewMapGraph[N, L]()
+	last, errReplay := Replay(reader, &g, nodeCodec, linkCodec, options)
+	if errReplay != nil {
+		return last, errReplay
+	}
+
+	return last, snapshot.Save(writer, &g, nodeCodec, linkCodec)
+}
+
+// Applier applies records to a graph.
+// Nodes of records are matched with nodes already in the graph by id (if they implement WithId),
+// so that links and properties apply to instances in the graph.
+// Applying a record twice is idempotent: existing nodes and links are not added again.
+type Applier[N graphs.Node, L graphs.Link[N]] struct {
+	// graph to change
+	graph graphs.CentralStructureGraph[N, L]
+	// nodeCodec decodes nodes
+	nodeCodec storage.NodeCodec[N]
+	// linkCodec decodes links
+	linkCodec storage.LinkCodec[N, L]
+	// nodes are the nodes in the graph by id, for nodes implementing WithId
+	nodes map[string]N
+}
+
+// NewApplier returns an applier for g, nodes already in g are indexed
+func NewApplier[N graphs.Node, L graphs.Link[N]](
+	g graphs.CentralStructureGraph[N, L], // graph to change
+	nodeCodec storage.NodeCodec[N], // decodes nodes
+	linkCodec storage.LinkCodec[N, L], // decodes links
+) (*Applier[N, L], error) {
+	if g == nil {
+		return nil, errors.New("nil graph")
+	} else if nodeCodec == nil || linkCodec == nil {
+		return nil, errors.New("nil codec")
+	}
+
+	result := &Applier[N, L]{graph: g, nodeCodec: nodeCodec, linkCodec: linkCodec, nodes: make(map[string]N)}
+	if err := result.indexNodes(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// indexNodes indexes nodes already in the graph
+func (r *Applier[N, L]) indexNodes() error {
+	it, errIt := r.graph.AllNodes()
+	if errIt != nil || it == nil {
+		return errIt
+	}
+
+	for has, errNext := it.Next(); has; has, errNext = it.Next() {
+		if errNext != nil {
+			return errNext
+		} else if node, errValue := it.Value(); errValue != nil {
+			return errValue
+		} else {
+			r.register(node)
+		}
+	}
+
+	return nil
+}
+
+// register indexes a node in the graph
+func (r *Applier[N, L]) register(node N) {
+	if withId, ok := any(node).(graphs.WithId); ok {
+		r.nodes[withId.Id()] = node
+	}
+}
+
+// resolve decodes a node, and returns the instance in the graph if any
+func (r *Applier[N, L]) resolve(payload []byte) (N, error) {
+	node, errNode := r.nodeCodec.DecodeNode(payload)
+	if errNode != nil {
+		return node, errNode
+	} else if withId, ok := any(node).(graphs.WithId); ok {
+		if existing, found := r.nodes[withId.Id()]; found {
+			return existing, nil
+		}
+	}
+
+	return node, nil
+}
+
+// resolveLink decodes a link between instances of the graph
+func (r *Applier[N, L]) resolveLink(record Record) (L, error) {
+	var empty L
+	source, errSource := r.resolve(record.Source)
+	if errSource != nil {
+		return empty, errSource
+	}
+
+	destination, errDestination := r.resolve(record.Destination)
+	if errDestination != nil {
+		return empty, errDestination
+	}
+
+	return r.linkCodec.DecodeLink(source, destination, record.Link)
+}
+
+// Apply changes the graph for a record
+func (r *Applier[N, L]) Apply(record Record) error {
+	switch {
+	case record.Operation == AddNode:
+		node, err := r.resolve(record.Node)
+		if err != nil {
+			return err
+		} else if neighbors, err := r.graph.Neighbors(node); err != nil {
+			return err
+		} else if neighbors != nil {
+			// already in the graph
+			return nil
+		} else if err := r.graph.AddNode(node); err != nil {
+			return err
+		}
+
+		r.register(node)
+	case record.Operation == RemoveNode:
+		node, err := r.resolve(record.Node)
+		if err != nil {
+			return err
+		} else if err := r.graph.RemoveNode(node); err != nil {
+			return err
+		} else if withId, ok := any(node).(graphs.WithId); ok {
+			delete(r.nodes, withId.Id())
+		}
+	case record.Operation == AddLink:
+		link, err := r.resolveLink(record)
+		if err != nil {
+			return err
+		} else if _, found, err := graphs.FindLink(r.graph, link); err != nil {
+			return err
+		} else if found {
+			// already in the graph
+			return nil
+		} else if err := r.graph.AddLink(link); err != nil {
+			return err
+		}
+
+		r.register(link.Source())
+		r.register(link.Destination())
+	case record.Operation == RemoveLink:
+		link, err := r.resolveLink(record)
+		if err != nil {
+			return err
+		}
+
+		return r.graph.RemoveLink(link)
+	case record.Operation == SetProperty && record.OnLink:
+		link, err := r.resolveLink(record)
+		if err != nil {
+			return err
+		}
+
+		existing, found, errFind := graphs.FindLink(r.graph, link)
+		if errFind != nil {
+			return errFind
+		} else if !found {
+			return errors.New("link not in graph")
+		} else if properties, ok := any(existing).(graphs.WithProperties); !ok {
+			return errors.New("link has no property")
+		} else {
+			changeProperty(properties, record.Key, record.Value, record.Removed)
+		}
+	case record.Operation == SetProperty:
+		node, err := r.resolve(record.Node)
+		if err != nil {
+			return err
+		} else if neighbors, err := r.graph.Neighbors(node); err != nil {
+			return err
+		} else if neighbors == nil {
+			return errors.New("node not in graph")
+		} else if properties, ok := any(node).(graphs.WithProperties); !ok {
+			return errors.New("node has no property")
+		} else {
+			changeProperty(properties, record.Key, record.Value, record.Removed)
+		}
+	default:
+		return fmt.Errorf("unknown operation %q", record.Operation)
+	}
+
+	return nil
+}
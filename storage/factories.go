@@ -0,0 +1,280 @@
+package storage
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+
+	"github.com/zefrenchwan/nodz.git/graphs"
+)
+
+// NodeFactory builds a node from its textual id, as read in a file.
+// Readers call it once per distinct id, so it is fine to generate new nodes from it.
+type NodeFactory[N graphs.Node] func(id string) (N, error)
+
+// LinkFactory builds a link from a source to a destination, as read in a file.
+// Weight is the weight read in the file, if any. Default weight is 1.0
+type LinkFactory[N graphs.Node, L graphs.Link[N]] func(source, destination N, weight float64) (L, error)
+
+// NodeIdentifier returns the textual id of a node, to write it in a file
+type NodeIdentifier[N graphs.Node] func(N) string
+
+// LinkWeigher returns the weight of a link, to write it in a file
+type LinkWeigher[N graphs.Node, L graphs.Link[N]] func(L) float64
+
+// DefaultLinkWeight is the weight of links when files do not provide any
+const DefaultLinkWeight = 1.0
+
+// NewDecompressedReader detects gzip content and returns a reader over decompressed data.
+// If content is not gzip compressed, it returns a reader over the raw content.
+// Detection is based on gzip magic number, so no need for a file extension.
+// Closing the result releases the decompressor, it does not close reader
+func NewDecompressedReader(reader io.Reader) (io.ReadCloser, error) {
+	if reader == nil {
+		return nil, errors.New("nil reader")
+	}
+
+	buffered := bufio.NewReader(reader)
+	header, errPeek := buffered.Peek(2)
+	if errPeek != nil && !errors.Is(errPeek, io.EOF) {
+		return nil, errPeek
+	} else if len(header) == 2 && header[0] == 0x1f && header[1] == 0x8b {
+		return gzip.NewReader(buffered)
+	}
+
+	return io.NopCloser(buffered), nil
+}
+
+// NodesRegistry deals with nodes read in a file.
+// Files reference nodes by textual id, and the same id should always match the same node.
+// Node is not comparable, so registry keeps the mapping between ids and nodes.
+type NodesRegistry[N graphs.Node] struct {
+	// factory builds a node the first time an id is read
+	factory NodeFactory[N]
+	// nodes are the nodes read so far, by textual id
+	nodes map[string]N
+}
+
+// NewNodesRegistry returns an empty registry building nodes with factory
+func NewNodesRegistry[N graphs.Node](factory NodeFactory[N]) NodesRegistry[N] {
+	return NodesRegistry[N]{
+		factory: factory,
+		nodes:   make(map[string]N),
+	}
+}
+
+// Get returns the node for that id, building it if necessary.
+// Second result is true if node was just built, false if it already existed
+func (nr *NodesRegistry[N]) Get(id string) (N, bool, error) {
+	var empty N
+	if nr == nil || nr.factory == nil {
+		return empty, false, errors.New("nil nodes factory")
+	}
+
+	if node, found := nr.nodes[id]; found {
+		return node, false, nil
+	}
+
+	node, errNode := nr.factory(id)
+	if errNode != nil {
+		return empty, false, errNode
+	}
+
+	nr.nodes[id] = node
+	return node, true, nil
+}
+
+// NodesNames deals with nodes to write in a file.
+// It returns the name of a node, using the identifier if any.
+// With no identifier, WithId nodes use their id, and other nodes use their index in the graph.
+type NodesNames[N graphs.Node] struct {
+	// identifier is the user defined naming function, may be nil
+	identifier NodeIdentifier[N]
+	// nodes are the nodes of the graph, in order of the iteration
+	nodes []N
+	// names are names of nodes, same index as nodes
+	names []string
+	// indexes are the indexes of WithId nodes, by id, to avoid a full scan
+	indexes map[string]int
+	// positions are the indexes of other nodes with a comparable type (pointers, for instance), by node
+	positions map[any]int
+}
+
+// NewNodesNames reads all the nodes of g and names them
+func NewNodesNames[N graphs.Node, L graphs.Link[N]](g graphs.CentralStructureGraph[N, L], identifier NodeIdentifier[N]) (NodesNames[N], error) {
+	var result NodesNames[N]
+	result.identifier = identifier
+	result.nodes = make([]N, 0)
+	result.names = make([]string, 0)
+	result.indexes = make(map[string]int)
+	result.positions = make(map[any]int)
+
+	if g == nil {
+		return result, errors.New("nil graph")
+	}
+
+	it, errIt := g.AllNodes()
+	if errIt != nil {
+		return result, errIt
+	}
+
+	var globalErr error
+	for has, errHas := it.Next(); has; has, errHas = it.Next() {
+		if errHas != nil {
+			globalErr = errors.Join(globalErr, errHas)
+			continue
+		}
+
+		node, errNode := it.Value()
+		if errNode != nil {
+			globalErr = errors.Join(globalErr, errNode)
+			continue
+		}
+
+		index := len(result.nodes)
+		result.nodes = append(result.nodes, node)
+		result.names = append(result.names, result.nameNode(node, index))
+		if withId, ok := any(node).(graphs.WithId); ok {
+			result.indexes[withId.Id()] = index
+		} else if isComparable(node) {
+			result.positions[node] = index
+		}
+	}
+
+	return result, globalErr
+}
+
+// Nodes returns the nodes, in the order they were read
+func (nn NodesNames[N]) Nodes() []N {
+	return nn.nodes
+}
+
+// Names returns the names of the nodes, same order as Nodes
+func (nn NodesNames[N]) Names() []string {
+	return nn.names
+}
+
+// Index returns the index of node in Nodes, -1 if not found.
+// WithId nodes are found by id, nodes with a comparable type by value, other nodes by a full scan
+func (nn NodesNames[N]) Index(node N) int {
+	if withId, ok := any(node).(graphs.WithId); ok {
+		if index, found := nn.indexes[withId.Id()]; found && node.SameNode(nn.nodes[index]) {
+			return index
+		}
+	} else if isComparable(node) {
+		if index, found := nn.positions[node]; found {
+			return index
+		}
+	}
+
+	for index, other := range nn.nodes {
+		if node.SameNode(other) {
+			return index
+		}
+	}
+
+	return -1
+}
+
+// Name returns the name of the node, or an error if node was not in the graph
+func (nn NodesNames[N]) Name(node N) (string, error) {
+	if nn.identifier != nil {
+		return nn.identifier(node), nil
+	} else if withId, ok := any(node).(graphs.WithId); ok {
+		return withId.Id(), nil
+	} else if index := nn.Index(node); index >= 0 {
+		return nn.names[index], nil
+	}
+
+	return "", errors.New("node not in graph")
+}
+
+// isComparable returns true if node may be a map key.
+// Structs and arrays may contain interfaces holding values that are not, so they are excluded
+func isComparable(node any) bool {
+	if node == nil {
+		return false
+	}
+
+	switch current := reflect.TypeOf(node); current.Kind() {
+	case reflect.Struct, reflect.Array, reflect.Interface:
+		return false
+	default:
+		return current.Comparable()
+	}
+}
+
+// nameNode returns the name of a node given its index
+func (nn NodesNames[N]) nameNode(node N, index int) string {
+	if nn.identifier != nil {
+		return nn.identifier(node)
+	} else if withId, ok := any(node).(graphs.WithId); ok {
+		return withId.Id()
+	}
+
+	return fmt.Sprintf("%d", index)
+}
+
+// VisitLinks calls visitor once per link in the graph.
+// Graphs return undirected links from both extremities, so undirected links are visited from their source only.
+// Directed links are returned from their source, so it applies too.
+func VisitLinks[N graphs.Node, L graphs.Link[N]](g graphs.CentralStructureGraph[N, L], visitor func(L) error) error {
+	if g == nil {
+		return errors.New("nil graph")
+	}
+
+	it, errIt := g.AllNodes()
+	if errIt != nil {
+		return errIt
+	}
+
+	var globalErr error
+	for has, errHas := it.Next(); has; has, errHas = it.Next() {
+		if errHas != nil {
+			globalErr = errors.Join(globalErr, errHas)
+			continue
+		}
+
+		node, errNode := it.Value()
+		if errNode != nil {
+			globalErr = errors.Join(globalErr, errNode)
+			continue
+		}
+
+		neighbors, errNeighbors := g.Neighbors(node)
+		if errNeighbors != nil {
+			globalErr = errors.Join(globalErr, errNeighbors)
+			continue
+		} else if neighbors == nil {
+			continue
+		}
+
+		links, errLinks := neighbors.Links()
+		if errLinks != nil {
+			globalErr = errors.Join(globalErr, errLinks)
+			continue
+		}
+
+		for hasLink, errHasLink := links.Next(); hasLink; hasLink, errHasLink = links.Next() {
+			if errHasLink != nil {
+				globalErr = errors.Join(globalErr, errHasLink)
+				continue
+			}
+
+			link, errLink := links.Value()
+			if errLink != nil {
+				globalErr = errors.Join(globalErr, errLink)
+				continue
+			} else if !link.Source().SameNode(node) {
+				continue
+			} else if err := visitor(link); err != nil {
+				return errors.Join(globalErr, err)
+			}
+		}
+	}
+
+	return globalErr
+}
@@ -0,0 +1,62 @@
+package storage
+
+import (
+	"io"
+	"os"
+)
+
+// File is a file of a persistent store, read and written at any position
+type File interface {
+	io.ReaderAt
+	io.WriterAt
+	// Size returns the size of the file
+	Size() (int64, error)
+	// Truncate changes the size of the file
+	Truncate(size int64) error
+	// Sync commits the content of the file to stable storage
+	Sync() error
+	// Close closes the file
+	Close() error
+}
+
+// FileSystem opens files of persistent stores.
+// Stores use it for all their writes, so that tests may inject failures or simulate crashes
+type FileSystem interface {
+	// OpenFile opens a file to read and write it, and creates it if it does not exist
+	OpenFile(name string) (File, error)
+	// Remove removes a file
+	Remove(name string) error
+}
+
+// OSFileSystem is the file system of the operating system
+type OSFileSystem struct{}
+
+// OpenFile opens or creates a file of the operating system
+func (OSFileSystem) OpenFile(name string) (File, error) {
+	file, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	return osFile{file}, nil
+}
+
+// Remove removes a file of the operating system
+func (OSFileSystem) Remove(name string) error {
+	return os.Remove(name)
+}
+
+// osFile is a file of the operating system
+type osFile struct {
+	*os.File
+}
+
+// Size returns the size of the file
+func (of osFile) Size() (int64, error) {
+	info, err := of.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	return info.Size(), nil
+}
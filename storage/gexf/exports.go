@@ -2,9 +2,11 @@ package gexf
 
 import (
 	"bytes"
+	"context"
 	"embed"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"slices"
 	"strings"
@@ -23,6 +25,14 @@ func GexfBlankNodeExporter[N graphs.Node](N, int) (string, map[string]string) {
 	return "", nil
 }
 
+// GexfIdNodeExporter labels nodes with their id, and exports no property
+func GexfIdNodeExporter[N interface {
+	graphs.Node
+	graphs.WithId
+}](node N, _ int) (string, map[string]string) {
+	return node.Id(), nil
+}
+
 // GexfLinkSerializer exports a link to its gexf link representation.
 // Edges model in GEXF allows label, properties, types, weights.
 // It would not make sense to
@@ -47,6 +57,59 @@ func ExportDataGraph[N graphs.Node, L graphs.Link[N]](
 	nodesExporter GexfNodeExporter[N], // to export nodes to something gexf understands
 	linksSerializer GexfLinkSerializer[N, L], // to serialize edges directly in GEXF format
 ) error {
+	return ExportDataGraphContext(context.Background(), path, g, nodesExporter, linksSerializer)
+}
+
+// ExportDataGraphContext is ExportDataGraph, but it stops as soon as ctx is done.
+// Then, no file is written, and error is ctx.Err() joined with errors so far.
+func ExportDataGraphContext[N graphs.Node, L graphs.Link[N]](
+	ctx context.Context, // to cancel the export
+	path string, // output path
+	g graphs.CentralStructureGraph[N, L], // graph to export
+	nodesExporter GexfNodeExporter[N], // to export nodes to something gexf understands
+	linksSerializer GexfLinkSerializer[N, L], // to serialize edges directly in GEXF format
+) error {
+	// generate content to write, file is written only if export succeeds
+	var localWriter bytes.Buffer
+	if err := WriteDataGraphContext(ctx, &localWriter, g, nodesExporter, linksSerializer); err != nil {
+		return err
+	}
+
+	// make output file
+	if _, err := os.Stat(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	} else if err == nil {
+		if errRemove := os.Remove(path); errRemove != nil {
+			return errRemove
+		}
+	}
+
+	return os.WriteFile(path, localWriter.Bytes(), 0777)
+}
+
+// WriteDataGraph writes a graph as gexf xml with the "data" structure, to any writer (a http response, for instance)
+func WriteDataGraph[N graphs.Node, L graphs.Link[N]](
+	writer io.Writer, // destination of the xml content
+	g graphs.CentralStructureGraph[N, L], // graph to export
+	nodesExporter GexfNodeExporter[N], // to export nodes to something gexf understands
+	linksSerializer GexfLinkSerializer[N, L], // to serialize edges directly in GEXF format
+) error {
+	return WriteDataGraphContext(context.Background(), writer, g, nodesExporter, linksSerializer)
+}
+
+// WriteDataGraphContext is WriteDataGraph, but it stops as soon as ctx is done.
+// Then, nothing is written, and error is ctx.Err() joined with errors so far.
+func WriteDataGraphContext[N graphs.Node, L graphs.Link[N]](
+	ctx context.Context, // to cancel the export
+	writer io.Writer, // destination of the xml content
+	g graphs.CentralStructureGraph[N, L], // graph to export
+	nodesExporter GexfNodeExporter[N], // to export nodes to something gexf understands
+	linksSerializer GexfLinkSerializer[N, L], // to serialize edges directly in GEXF format
+) error {
+	if writer == nil {
+		return errors.New("nil writer")
+	}
+
 	// load template
 	var dataTemplate *template.Template
 	if dp, errDp := dataPattern.ReadFile("data_pattern.xml"); errDp != nil {
@@ -61,11 +124,12 @@ func ExportDataGraph[N graphs.Node, L graphs.Link[N]](
 	// 1. Serialize each node
 	// 2. For each node, find its neighbors. We may not know yet the indexes of the nodes
 	// 3. Once all nodes index are known, then calculate edges
-	it, errIt := g.AllNodes()
+	allNodes, errIt := g.AllNodes()
 	if errIt != nil {
 		return errIt
 	}
 
+	it := graphs.WithContext(ctx, allNodes)
 	var globalErr error
 
 	attributesIndex := 0
@@ -110,7 +174,9 @@ func ExportDataGraph[N graphs.Node, L graphs.Link[N]](
 		nodes = append(nodes, node)
 	}
 
-	if globalErr != nil {
+	if err := ctx.Err(); err != nil {
+		return errors.Join(err, globalErr)
+	} else if globalErr != nil {
 		return globalErr
 	}
 
@@ -120,11 +186,13 @@ func ExportDataGraph[N graphs.Node, L graphs.Link[N]](
 	}
 
 	// then, go for edges.
-	it, errIt = g.AllNodes()
+	allNodes, errIt = g.AllNodes()
 	if errIt != nil {
 		return errIt
 	}
 
+	it = graphs.WithContext(ctx, allNodes)
+
 	// For each edge, find source and destination index, and then, make the link
 	for has, errHas := it.Next(); has; has, errHas = it.Next() {
 		if errHas != nil {
@@ -171,30 +239,19 @@ func ExportDataGraph[N graphs.Node, L graphs.Link[N]](
 		}
 	}
 
-	if globalErr != nil {
+	if err := ctx.Err(); err != nil {
+		return errors.Join(err, globalErr)
+	} else if globalErr != nil {
 		return globalErr
 	}
 
 	// generate content to write
-	var localWriter bytes.Buffer
 	var content gexfDataContent
 	content.Attributes = strings.Join(attributeValues, "\n")
 	content.Nodes = strings.Join(nodeValues, "\n")
 	content.Edges = strings.Join(linkValues, "\n")
 
-	// write it in buffer
-	dataTemplate.Execute(&localWriter, content)
-
-	// make output file
-	if _, err := os.Stat(path); err != nil && !errors.Is(err, os.ErrNotExist) {
-		return err
-	} else if err == nil {
-		if errRemove := os.Remove(path); errRemove != nil {
-			return errRemove
-		}
-	}
-
-	return os.WriteFile(path, localWriter.Bytes(), 0777)
+	return dataTemplate.Execute(writer, content)
 }
 
 // gexfDataContent goes with the pattern to form a complete gexf data file
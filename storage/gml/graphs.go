@@ -0,0 +1,357 @@
+package gml
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/zefrenchwan/nodz.git/graphs"
+	"github.com/zefrenchwan/nodz.git/storage"
+)
+
+// validKey is the format of GML keys
+var validKey = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// nodeReservedKeys are the keys of nodes that are not properties
+var nodeReservedKeys = []string{"id", "label", "labels"}
+
+// edgeReservedKeys are the keys of edges that are not properties
+var edgeReservedKeys = []string{"id", "source", "target", "weight", "value"}
+
+// ReadGML reads a GML graph and streams its content into g.
+// Nodes are built from their label, or from their id if they have no label.
+// Attributes of nodes and edges are set as properties (if they implement WithProperties),
+// nested attributes use dots in keys (for instance "graphics.x").
+// Graph is undirected unless it has "directed 1", and links are built with the matching factory.
+// Result is true for a directed graph.
+func ReadGML[N graphs.Node, L graphs.Link[N]](
+	reader io.Reader, // content to read, may be gzip compressed
+	g graphs.CentralStructureGraph[N, L], // graph to fill
+	nodes storage.NodeFactory[N], // builds a node from its label
+	directedLinks storage.LinkFactory[N, L], // builds links of directed graphs
+	undirectedLinks storage.LinkFactory[N, L], // builds links of undirected graphs
+) (bool, error) {
+	if g == nil {
+		return false, errors.New("nil graph")
+	} else if nodes == nil || directedLinks == nil || undirectedLinks == nil {
+		return false, errors.New("nil factory")
+	}
+
+	content, errContent := storage.NewDecompressedReader(reader)
+	if errContent != nil {
+		return false, errContent
+	}
+
+	defer content.Close()
+
+	lx := newLexer(content)
+	// find the graph key
+	for {
+		current, err := lx.next()
+		if err != nil {
+			return false, err
+		} else if current.kind == endToken {
+			return false, errors.New("no graph in content")
+		} else if current.kind == keyToken && current.text == "graph" {
+			break
+		}
+	}
+
+	if open, err := lx.next(); err != nil {
+		return false, err
+	} else if open.kind != openToken {
+		return false, fmt.Errorf("line %d, column %d: expecting graph list", open.line, open.column)
+	}
+
+	directed := false
+	// nodes by GML id
+	nodesById := make(map[string]N)
+	// edges may appear before their nodes, and before the directed flag: process them at the end
+	edges := make([]value, 0)
+	for {
+		current, err := lx.next()
+		if err != nil {
+			return directed, err
+		} else if current.kind == closeToken {
+			break
+		} else if current.kind != keyToken {
+			return directed, fmt.Errorf("line %d, column %d: expecting a key", current.line, current.column)
+		}
+
+		content, errValue := lx.readValue()
+		if errValue != nil {
+			return directed, errValue
+		}
+
+		switch current.text {
+		case "directed":
+			directed = content.text == "1"
+		case "node":
+			id, found := content.first("id")
+			if !found || id.isList {
+				return directed, fmt.Errorf("line %d: node without id", current.line)
+			}
+
+			name := id.text
+			if label, hasLabel := content.first("label"); hasLabel && !label.isList {
+				name = label.text
+			}
+
+			node, errNode := nodes(name)
+			if errNode != nil {
+				return directed, fmt.Errorf("line %d: %w", current.line, errNode)
+			}
+
+			applyAttributes(node, content, nodeReservedKeys)
+			if err := g.AddNode(node); err != nil {
+				return directed, err
+			}
+
+			nodesById[id.text] = node
+		case "edge":
+			edges = append(edges, content)
+		}
+	}
+
+	factory := undirectedLinks
+	if directed {
+		factory = directedLinks
+	}
+
+	for index, edge := range edges {
+		source, errSource := edgeExtremity(edge, "source", nodesById)
+		if errSource != nil {
+			return directed, fmt.Errorf("edge %d: %w", index, errSource)
+		}
+
+		target, errTarget := edgeExtremity(edge, "target", nodesById)
+		if errTarget != nil {
+			return directed, fmt.Errorf("edge %d: %w", index, errTarget)
+		}
+
+		weight := storage.DefaultLinkWeight
+		for _, key := range []string{"weight", "value"} {
+			if raw, found := edge.first(key); found && !raw.isList {
+				if w, errW := strconv.ParseFloat(raw.text, 64); errW != nil {
+					return directed, fmt.Errorf("edge %d: invalid weight %q", index, raw.text)
+				} else {
+					weight = w
+				}
+
+				break
+			}
+		}
+
+		link, errLink := factory(source, target, weight)
+		if errLink != nil {
+			return directed, fmt.Errorf("edge %d: %w", index, errLink)
+		}
+
+		applyAttributes(link, edge, edgeReservedKeys)
+		if err := g.AddLink(link); err != nil {
+			return directed, err
+		}
+	}
+
+	return directed, nil
+}
+
+// WriteGML writes g as a GML graph.
+// Nodes ids are their index, labels are their names (see storage.NodesNames).
+// Properties (WithProperties) are written as string attributes, and labels (WithLabels) as a "labels" attribute.
+// Keys with dots are written as nested lists, as ReadGML reads them.
+// Graph is directed if any link is directed.
+func WriteGML[N graphs.Node, L graphs.Link[N]](
+	writer io.Writer, // destination of the content
+	g graphs.CentralStructureGraph[N, L], // graph to write
+	identifier storage.NodeIdentifier[N], // names nodes, may be nil
+	weigher storage.LinkWeigher[N, L], // weight of links, may be nil
+) error {
+	names, errNames := storage.NewNodesNames(g, identifier)
+	if errNames != nil {
+		return errNames
+	}
+
+	// stops as soon as a directed link is found
+	errFound := errors.New("directed link found")
+	directed := false
+	if err := storage.VisitLinks(g, func(link L) error {
+		if link.IsDirected() {
+			return errFound
+		}
+
+		return nil
+	}); errors.Is(err, errFound) {
+		directed = true
+	} else if err != nil {
+		return err
+	}
+
+	buffer := bufio.NewWriter(writer)
+	buffer.WriteString("graph [\n")
+	if directed {
+		buffer.WriteString("  directed 1\n")
+	} else {
+		buffer.WriteString("  directed 0\n")
+	}
+
+	for index, node := range names.Nodes() {
+		attributes := make([]string, 0)
+		attributes = append(attributes, fmt.Sprintf("id %d", index))
+		attributes = append(attributes, "label "+quote(names.Names()[index]))
+		if withLabels, ok := any(node).(graphs.WithLabels); ok && len(withLabels.Labels()) != 0 {
+			attributes = append(attributes, "labels "+quote(graphs.JoinLabels(withLabels)))
+		}
+
+		if properties, err := propertiesAttributes(node, nodeReservedKeys); err != nil {
+			return err
+		} else {
+			attributes = append(attributes, properties...)
+		}
+
+		writeRecord(buffer, "node", attributes)
+	}
+
+	errVisit := storage.VisitLinks(g, func(link L) error {
+		source := names.Index(link.Source())
+		target := names.Index(link.Destination())
+		if source < 0 || target < 0 {
+			return errors.New("link extremity not in graph")
+		}
+
+		attributes := []string{fmt.Sprintf("source %d", source), fmt.Sprintf("target %d", target)}
+		if weigher != nil {
+			attributes = append(attributes, "weight "+strconv.FormatFloat(weigher(link), 'g', -1, 64))
+		}
+
+		if properties, err := propertiesAttributes(link, edgeReservedKeys); err != nil {
+			return err
+		} else {
+			attributes = append(attributes, properties...)
+		}
+
+		writeRecord(buffer, "edge", attributes)
+		return nil
+	})
+
+	if errVisit != nil {
+		return errVisit
+	}
+
+	buffer.WriteString("]\n")
+	return buffer.Flush()
+}
+
+// edgeExtremity returns the node of an edge for key (source or target)
+func edgeExtremity[N graphs.Node](edge value, key string, nodesById map[string]N) (N, error) {
+	var empty N
+	if id, found := edge.first(key); !found || id.isList {
+		return empty, fmt.Errorf("missing %s", key)
+	} else if node, exists := nodesById[id.text]; !exists {
+		return empty, fmt.Errorf("unknown %s %s", key, id.text)
+	} else {
+		return node, nil
+	}
+}
+
+// applyAttributes sets attributes of a record as properties (and labels) of element, if it accepts them
+func applyAttributes(element any, record value, reserved []string) {
+	if withProperties, ok := element.(graphs.WithProperties); ok {
+		attributes := make(map[string]string)
+		record.flatten("", attributes)
+		for key, v := range attributes {
+			if !slices.Contains(reserved, key) {
+				withProperties.SetProperty(key, v)
+			}
+		}
+	}
+
+	if withLabels, ok := element.(graphs.WithLabels); ok {
+		if labels, found := record.first("labels"); found && !labels.isList && len(labels.text) != 0 {
+			for _, label := range strings.Split(labels.text, ",") {
+				withLabels.AddLabel(label)
+			}
+		}
+	}
+}
+
+// propertiesAttributes returns properties of element as GML attributes, sorted by key.
+// Keys that are not valid GML keys raise an error
+func propertiesAttributes(element any, reserved []string) ([]string, error) {
+	withProperties, ok := element.(graphs.WithProperties)
+	if !ok {
+		return nil, nil
+	}
+
+	values := make(map[string]string)
+	for _, key := range withProperties.PropertyKeys() {
+		if !slices.Contains(reserved, key) {
+			values[key], _ = withProperties.GetProperty(key)
+		}
+	}
+
+	return nestedAttributes(values, 0)
+}
+
+// nestedAttributes returns values as GML attributes at depth, sorted by key.
+// Dotted keys are nested lists, as ReadGML reads them: "graphics.x" is x in a graphics list
+func nestedAttributes(values map[string]string, depth int) ([]string, error) {
+	scalars := make(map[string]string)
+	lists := make(map[string]map[string]string)
+	for key, v := range values {
+		head, rest, nested := strings.Cut(key, ".")
+		if !validKey.MatchString(head) {
+			return nil, fmt.Errorf("invalid GML key %q", key)
+		} else if !nested {
+			scalars[head] = v
+		} else if _, found := lists[head]; found {
+			lists[head][rest] = v
+		} else {
+			lists[head] = map[string]string{rest: v}
+		}
+	}
+
+	keys := slices.AppendSeq(slices.Collect(maps.Keys(scalars)), maps.Keys(lists))
+	slices.Sort(keys)
+	// lists are indented one level deeper than their key, records attributes are at 4 spaces
+	indent := strings.Repeat("  ", depth+2)
+	result := make([]string, 0, len(keys))
+	for _, key := range slices.Compact(keys) {
+		if v, found := scalars[key]; found {
+			result = append(result, key+" "+quote(v))
+		}
+
+		if nested, found := lists[key]; found {
+			content, err := nestedAttributes(nested, depth+1)
+			if err != nil {
+				return nil, err
+			}
+
+			result = append(result, key+" [\n"+indent+"  "+strings.Join(content, "\n"+indent+"  ")+"\n"+indent+"]")
+		}
+	}
+
+	return result, nil
+}
+
+// writeRecord writes a list with its attributes, one per line
+func writeRecord(buffer *bufio.Writer, key string, attributes []string) {
+	buffer.WriteString("  " + key + " [\n")
+	for _, attribute := range attributes {
+		buffer.WriteString("    " + attribute + "\n")
+	}
+
+	buffer.WriteString("  ]\n")
+}
+
+// quote returns a GML string: quotes and ampersands are html escaped
+func quote(text string) string {
+	return `"` + html.EscapeString(text) + `"`
+}
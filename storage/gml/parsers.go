@@ -0,0 +1,268 @@
+package gml
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// GML content is a list of key value pairs.
+// Values are integers, reals, strings (between quotes) or lists of key value pairs (between brackets).
+// For instance:
+//
+//	graph [
+//	  directed 1
+//	  node [ id 1 label "a" graphics [ x 1.0 y 2.0 ] ]
+//	  edge [ source 1 target 1 weight 2.5 ]
+//	]
+
+// value is a GML value: either a scalar (text) or a list
+type value struct {
+	// text is the value of a scalar, unquoted
+	text string
+	// isList is true for lists
+	isList bool
+	// list is the content of a list, keys may appear many times
+	list []pair
+}
+
+// pair is a key and its value
+type pair struct {
+	// key is an identifier
+	key string
+	// value is the value for that key
+	value value
+}
+
+// first returns the first value for key in a list, and true if found
+func (v value) first(key string) (value, bool) {
+	for _, p := range v.list {
+		if p.key == key {
+			return p.value, true
+		}
+	}
+
+	return value{}, false
+}
+
+// flatten returns the scalars of a list as a map, nested keys are joined with dots
+func (v value) flatten(prefix string, result map[string]string) {
+	for _, p := range v.list {
+		key := p.key
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+
+		if p.value.isList {
+			p.value.flatten(key, result)
+		} else {
+			result[key] = p.value.text
+		}
+	}
+}
+
+// tokenKind is the kind of a token
+type tokenKind int
+
+const (
+	// endToken is the end of the content
+	endToken tokenKind = iota
+	// keyToken is an identifier
+	keyToken
+	// scalarToken is a number
+	scalarToken
+	// stringToken is a quoted string
+	stringToken
+	// openToken is [
+	openToken
+	// closeToken is ]
+	closeToken
+)
+
+// token is a lexical element of a GML content, with its position
+type token struct {
+	// kind of the token
+	kind tokenKind
+	// text is the content of the token, unquoted and unescaped for strings
+	text string
+	// line is the line of the token, starting at 1
+	line int
+	// column is the column of the token, starting at 1
+	column int
+}
+
+// lexer splits a GML content into tokens
+type lexer struct {
+	// reader is the source of the content
+	reader *bufio.Reader
+	// line is the current line, starting at 1
+	line int
+	// column is the column of the last read rune
+	column int
+	// lastColumn is the column before last new line, to unread
+	lastColumn int
+}
+
+// newLexer returns a lexer over reader
+func newLexer(reader io.Reader) *lexer {
+	return &lexer{reader: bufio.NewReader(reader), line: 1}
+}
+
+// errorf returns an error at the current position
+func (lx *lexer) errorf(format string, args ...any) error {
+	return fmt.Errorf("line %d, column %d: %s", lx.line, lx.column, fmt.Sprintf(format, args...))
+}
+
+// read returns next rune, or an error (io.EOF at the end)
+func (lx *lexer) read() (rune, error) {
+	r, _, err := lx.reader.ReadRune()
+	if err != nil {
+		return r, err
+	}
+
+	if r == '\n' {
+		lx.line++
+		lx.lastColumn = lx.column
+		lx.column = 0
+	} else {
+		lx.column++
+	}
+
+	return r, nil
+}
+
+// unread goes back one rune
+func (lx *lexer) unread(r rune) {
+	lx.reader.UnreadRune()
+	if r == '\n' {
+		lx.line--
+		lx.column = lx.lastColumn
+	} else {
+		lx.column--
+	}
+}
+
+// next returns the next token
+func (lx *lexer) next() (token, error) {
+	// skip spaces and comments
+	var r rune
+	for {
+		current, err := lx.read()
+		if err == io.EOF {
+			return token{kind: endToken, line: lx.line, column: lx.column}, nil
+		} else if err != nil {
+			return token{}, err
+		} else if current == '#' {
+			for current != '\n' {
+				if current, err = lx.read(); err == io.EOF {
+					return token{kind: endToken, line: lx.line, column: lx.column}, nil
+				} else if err != nil {
+					return token{}, err
+				}
+			}
+		} else if !unicode.IsSpace(current) {
+			r = current
+			break
+		}
+	}
+
+	result := token{line: lx.line, column: lx.column}
+	switch {
+	case r == '[':
+		result.kind = openToken
+	case r == ']':
+		result.kind = closeToken
+	case r == '"':
+		var builder strings.Builder
+		for {
+			current, err := lx.read()
+			if err == io.EOF {
+				return result, fmt.Errorf("line %d, column %d: unterminated string", result.line, result.column)
+			} else if err != nil {
+				return result, err
+			} else if current == '"' {
+				break
+			}
+
+			builder.WriteRune(current)
+		}
+
+		result.kind = stringToken
+		result.text = html.UnescapeString(builder.String())
+	case unicode.IsLetter(r) || r == '_':
+		result.kind = keyToken
+		result.text = lx.readWhile(r, func(c rune) bool { return unicode.IsLetter(c) || unicode.IsDigit(c) || c == '_' })
+	case unicode.IsDigit(r) || r == '-' || r == '+' || r == '.':
+		result.kind = scalarToken
+		result.text = lx.readWhile(r, func(c rune) bool {
+			return unicode.IsDigit(c) || c == '.' || c == 'e' || c == 'E' || c == '-' || c == '+'
+		})
+	default:
+		return result, lx.errorf("unexpected character %q", r)
+	}
+
+	return result, nil
+}
+
+// readWhile reads runes from first while accept is true
+func (lx *lexer) readWhile(first rune, accept func(rune) bool) string {
+	var builder strings.Builder
+	builder.WriteRune(first)
+	for {
+		current, err := lx.read()
+		if err != nil {
+			return builder.String()
+		} else if !accept(current) {
+			lx.unread(current)
+			return builder.String()
+		}
+
+		builder.WriteRune(current)
+	}
+}
+
+// readValue reads the value after a key
+func (lx *lexer) readValue() (value, error) {
+	current, err := lx.next()
+	if err != nil {
+		return value{}, err
+	}
+
+	switch current.kind {
+	case scalarToken, stringToken:
+		return value{text: current.text}, nil
+	case openToken:
+		return lx.readList()
+	default:
+		return value{}, fmt.Errorf("line %d, column %d: expecting a value", current.line, current.column)
+	}
+}
+
+// readList reads pairs until the closing bracket
+func (lx *lexer) readList() (value, error) {
+	result := value{isList: true, list: make([]pair, 0)}
+	for {
+		current, err := lx.next()
+		if err != nil {
+			return result, err
+		}
+
+		switch current.kind {
+		case closeToken:
+			return result, nil
+		case keyToken:
+			if v, errValue := lx.readValue(); errValue != nil {
+				return result, errValue
+			} else {
+				result.list = append(result.list, pair{key: current.text, value: v})
+			}
+		case endToken:
+			return result, fmt.Errorf("line %d, column %d: unterminated list", current.line, current.column)
+		default:
+			return result, fmt.Errorf("line %d, column %d: expecting a key", current.line, current.column)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package ingestion
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Checkpointer stores the offset of the last applied record, so that a restarted process resumes after it
+type Checkpointer interface {
+	// Load returns the last saved offset, 0 if none
+	Load() (uint64, error)
+	// Save stores offset as the last applied one
+	Save(offset uint64) error
+}
+
+// MemoryCheckpointer keeps the offset in memory, for tests or processes that replay everything at start
+type MemoryCheckpointer struct {
+	// lock protects offset
+	lock sync.Mutex
+	// offset is the last saved offset
+	offset uint64
+}
+
+// Load returns the last saved offset
+func (mc *MemoryCheckpointer) Load() (uint64, error) {
+	mc.lock.Lock()
+	defer mc.lock.Unlock()
+	return mc.offset, nil
+}
+
+// Save stores offset
+func (mc *MemoryCheckpointer) Save(offset uint64) error {
+	mc.lock.Lock()
+	defer mc.lock.Unlock()
+	mc.offset = offset
+	return nil
+}
+
+// FileCheckpointer stores the offset in a file, as text.
+// File is replaced atomically (write to a temporary file, then rename), so a crash never leaves a partial offset.
+type FileCheckpointer struct {
+	// path of the checkpoint file
+	path string
+}
+
+// NewFileCheckpointer returns a checkpointer for path. File is created on first save
+func NewFileCheckpointer(path string) (*FileCheckpointer, error) {
+	if len(path) == 0 {
+		return nil, errors.New("empty path")
+	}
+
+	return &FileCheckpointer{path: path}, nil
+}
+
+// Load returns the offset in the file, 0 if file does not exist
+func (fc *FileCheckpointer) Load() (uint64, error) {
+	content, errRead := os.ReadFile(fc.path)
+	if errors.Is(errRead, os.ErrNotExist) {
+		return 0, nil
+	} else if errRead != nil {
+		return 0, errRead
+	}
+
+	return strconv.ParseUint(strings.TrimSpace(string(content)), 10, 64)
+}
+
+// Save writes offset in the file
+func (fc *FileCheckpointer) Save(offset uint64) error {
+	temporary, errCreate := os.CreateTemp(filepath.Dir(fc.path), filepath.Base(fc.path)+".*.tmp")
+	if errCreate != nil {
+		return errCreate
+	}
+
+	_, errWrite := temporary.WriteString(strconv.FormatUint(offset, 10) + "\n")
+	if errWrite == nil {
+		errWrite = temporary.Sync()
+	}
+
+	if errClose := temporary.Close(); errWrite == nil {
+		errWrite = errClose
+	}
+
+	if errWrite != nil {
+		os.Remove(temporary.Name())
+		return errWrite
+	}
+
+	return os.Rename(temporary.Name(), fc.path)
+}
@@ -0,0 +1,102 @@
+package ingestion
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/zefrenchwan/nodz.git/graphs"
+	"github.com/zefrenchwan/nodz.git/storage"
+	"github.com/zefrenchwan/nodz.git/storage/eventlog"
+)
+
+// Options defines how records are applied
+type Options struct {
+	// CheckpointEvery is the number of applied records between two checkpoints, 1 if not positive.
+	// Larger values are faster, but more records are applied again after a restart
+	CheckpointEvery int
+}
+
+// Ingest applies the records of source to g, until source is exhausted or a record fails.
+// Delivery is at least once: checkpoint is saved after records are applied,
+// so records after the last checkpoint are applied again after a restart.
+// That is safe because applying is idempotent (see eventlog.Applier),
+// and records with an offset up to the checkpoint are skipped (records with no offset, 0, are always applied).
+// Result is the offset of the last applied record, also saved as checkpoint.
+func Ingest[N graphs.Node, L graphs.Link[N]](
+	source Source, // records to apply
+	g graphs.CentralStructureGraph[N, L], // graph to change
+	nodeCodec storage.NodeCodec[N], // decodes nodes
+	linkCodec storage.LinkCodec[N, L], // decodes links
+	checkpointer Checkpointer, // stores progress
+	options Options, // checkpoints frequency
+) (uint64, error) {
+	if source == nil {
+		return 0, errors.New("nil source")
+	} else if checkpointer == nil {
+		return 0, errors.New("nil checkpointer")
+	}
+
+	applier, errApplier := eventlog.NewApplier(g, nodeCodec, linkCodec)
+	if errApplier != nil {
+		return 0, errApplier
+	}
+
+	last, errLoad := checkpointer.Load()
+	if errLoad != nil {
+		return 0, errLoad
+	}
+
+	if seeker, ok := source.(Seeker); ok {
+		if err := seeker.Seek(last); err != nil {
+			return last, err
+		}
+	}
+
+	every := max(options.CheckpointEvery, 1)
+	saved := last
+	pending := 0
+	// save checkpoint for records applied so far, even if ingestion fails
+	checkpoint := func() error {
+		if last == saved {
+			return nil
+		} else if err := checkpointer.Save(last); err != nil {
+			return err
+		}
+
+		saved = last
+		pending = 0
+		return nil
+	}
+
+	for {
+		has, errNext := source.Next()
+		if errNext != nil {
+			return last, errors.Join(errNext, checkpoint())
+		} else if !has {
+			return last, checkpoint()
+		}
+
+		record, errRecord := source.Value()
+		if errRecord != nil {
+			return last, errors.Join(errRecord, checkpoint())
+		} else if record.Offset != 0 && record.Offset <= last {
+			// duplicate or already applied before restart
+			continue
+		}
+
+		if err := applier.Apply(record); err != nil {
+			return last, errors.Join(fmt.Errorf("offset %d: %w", record.Offset, err), checkpoint())
+		}
+
+		if record.Offset != 0 {
+			last = record.Offset
+			pending++
+		}
+
+		if pending >= every {
+			if err := checkpoint(); err != nil {
+				return last, err
+			}
+		}
+	}
+}
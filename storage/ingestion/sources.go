@@ -0,0 +1,83 @@
+package ingestion
+
+import (
+	"errors"
+	"io"
+
+	"github.com/zefrenchwan/nodz.git/graphs"
+	"github.com/zefrenchwan/nodz.git/storage/eventlog"
+)
+
+// Source provides change records, in order.
+// Next blocks until a record is available, and returns false when source is exhausted.
+// A message broker client (Kafka, for instance) would implement it over a topic.
+type Source interface {
+	graphs.GeneralIterator[eventlog.Record]
+}
+
+// Seeker is a source that may start after an offset.
+// Sources that do not implement it are read from the beginning, and records before the checkpoint are skipped.
+type Seeker interface {
+	// Seek moves the source after offset (included)
+	Seek(offset uint64) error
+}
+
+// ReaderSource reads records from a log content, in any format of eventlog
+type ReaderSource struct {
+	// records reads the log
+	records *eventlog.RecordReader
+}
+
+// NewReaderSource returns a source over the records of reader
+func NewReaderSource(reader io.Reader) (*ReaderSource, error) {
+	records, err := eventlog.NewRecordReader(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ReaderSource{records: records}, nil
+}
+
+// Next reads the next record, if any
+func (rs *ReaderSource) Next() (bool, error) {
+	return rs.records.Next()
+}
+
+// Value returns the current record
+func (rs *ReaderSource) Value() (eventlog.Record, error) {
+	return rs.records.Value()
+}
+
+// ChannelSource reads records from a channel, until the channel is closed
+type ChannelSource struct {
+	// records is the channel to read
+	records <-chan eventlog.Record
+	// current is the last received record
+	current eventlog.Record
+	// hasCurrent is true once a record was received
+	hasCurrent bool
+}
+
+// NewChannelSource returns a source over a channel
+func NewChannelSource(records <-chan eventlog.Record) *ChannelSource {
+	return &ChannelSource{records: records}
+}
+
+// Next waits for the next record, and returns false once the channel is closed
+func (cs *ChannelSource) Next() (bool, error) {
+	if cs.records == nil {
+		return false, errors.New("nil channel")
+	}
+
+	cs.current, cs.hasCurrent = <-cs.records
+	return cs.hasCurrent, nil
+}
+
+// Value returns the current record
+func (cs *ChannelSource) Value() (eventlog.Record, error) {
+	if !cs.hasCurrent {
+		return eventlog.Record{}, errors.New("no current record")
+	}
+
+	return cs.current, nil
+}
@@ -0,0 +1,229 @@
+package jsongraph
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/zefrenchwan/nodz.git/graphs"
+	"github.com/zefrenchwan/nodz.git/storage"
+)
+
+// GraphInfo is the graph level information of a json document
+type GraphInfo struct {
+	// Directed is true for directed graphs
+	Directed bool
+	// Multigraph is true if many links may share same source and destination
+	Multigraph bool
+	// Attributes are the graph attributes (networkx "graph" or JGF "metadata")
+	Attributes map[string]string
+}
+
+// Options define the graph level information to write, and how to export links
+type Options[N graphs.Node, L graphs.Link[N]] struct {
+	// Info is the graph level information to write
+	Info GraphInfo
+	// Identifier names nodes, nil for default naming (see storage.NodesNames)
+	Identifier storage.NodeIdentifier[N]
+	// Weigher returns the weight of a link, nil to write no weight
+	Weigher storage.LinkWeigher[N, L]
+}
+
+// reserved keys are not attributes, but structure of the document
+var nodeLinkReservedKeys = []string{"id", "source", "target", "key", "labels"}
+
+// nodeAttributes returns the attributes of a node: its properties and labels if any.
+// Node is not required to implement WithProperties or WithLabels.
+func nodeAttributes[N graphs.Node](node N) (map[string]string, []string) {
+	var properties map[string]string
+	var labels []string
+
+	if withProperties, ok := any(node).(graphs.WithProperties); ok {
+		properties = propertiesToMap(withProperties)
+	}
+
+	if withLabels, ok := any(node).(graphs.WithLabels); ok {
+		labels = withLabels.Labels()
+	}
+
+	return properties, labels
+}
+
+// linkAttributes returns the properties of a link, if any
+func linkAttributes[N graphs.Node, L graphs.Link[N]](link L) map[string]string {
+	if withProperties, ok := any(link).(graphs.WithProperties); ok {
+		return propertiesToMap(withProperties)
+	}
+
+	return nil
+}
+
+// propertiesToMap returns all the properties as a map
+func propertiesToMap(properties graphs.WithProperties) map[string]string {
+	result := make(map[string]string)
+	for _, key := range properties.PropertyKeys() {
+		if value, found := properties.GetProperty(key); found {
+			result[key] = value
+		}
+	}
+
+	return result
+}
+
+// applyAttributes sets attributes as properties and labels on element, if it accepts them.
+// Reserved keys are excluded
+func applyAttributes(element any, attributes map[string]any, labels []string, reserved []string) {
+	if withProperties, ok := element.(graphs.WithProperties); ok {
+		for key, value := range attributes {
+			if !slices.Contains(reserved, key) {
+				withProperties.SetProperty(key, attributeToString(value))
+			}
+		}
+	}
+
+	if withLabels, ok := element.(graphs.WithLabels); ok {
+		for _, label := range labels {
+			withLabels.AddLabel(label)
+		}
+	}
+}
+
+// attributeToString returns a json value as a string: strings as is, other values as json
+func attributeToString(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case nil:
+		return ""
+	default:
+		if content, err := json.Marshal(v); err == nil {
+			return string(content)
+		}
+
+		return fmt.Sprint(v)
+	}
+}
+
+// labelsFromAttribute returns labels from a json value: either an array of strings, or a comma separated string
+func labelsFromAttribute(value any) []string {
+	switch v := value.(type) {
+	case []any:
+		result := make([]string, 0, len(v))
+		for _, label := range v {
+			result = append(result, attributeToString(label))
+		}
+
+		return result
+	case string:
+		if len(v) == 0 {
+			return nil
+		}
+
+		return strings.Split(v, ",")
+	default:
+		return nil
+	}
+}
+
+// attributesToInfo reads a map of attributes as graph attributes
+func attributesToInfo(attributes map[string]any) map[string]string {
+	result := make(map[string]string)
+	for key, value := range attributes {
+		result[key] = attributeToString(value)
+	}
+
+	return result
+}
+
+// weightOf returns the weight in attributes, if any, or default weight
+func weightOf(attributes map[string]any, defaultWeight float64) (float64, error) {
+	value, found := attributes["weight"]
+	if !found {
+		return defaultWeight, nil
+	}
+
+	switch v := value.(type) {
+	case json.Number:
+		return v.Float64()
+	case float64:
+		return v, nil
+	default:
+		return defaultWeight, fmt.Errorf("invalid weight %v", value)
+	}
+}
+
+// readObject reads an object token by token, and calls processor for each key.
+// Processor has to consume the value of the key
+func readObject(decoder *json.Decoder, processor func(key string) error) error {
+	if err := expectDelimiter(decoder, '{'); err != nil {
+		return err
+	}
+
+	for decoder.More() {
+		token, errToken := decoder.Token()
+		if errToken != nil {
+			return errToken
+		}
+
+		key, ok := token.(string)
+		if !ok {
+			return fmt.Errorf("expecting key, got %v", token)
+		} else if err := processor(key); err != nil {
+			return err
+		}
+	}
+
+	return expectDelimiter(decoder, '}')
+}
+
+// readArray reads an array token by token and calls processor for each element.
+// Processor has to consume the element
+func readArray(decoder *json.Decoder, processor func() error) error {
+	if err := expectDelimiter(decoder, '['); err != nil {
+		return err
+	}
+
+	for decoder.More() {
+		if err := processor(); err != nil {
+			return err
+		}
+	}
+
+	return expectDelimiter(decoder, ']')
+}
+
+// expectDelimiter reads next token and raises an error if it is not the expected delimiter
+func expectDelimiter(decoder *json.Decoder, expected json.Delim) error {
+	token, errToken := decoder.Token()
+	if errToken != nil {
+		return errToken
+	} else if delimiter, ok := token.(json.Delim); !ok || delimiter != expected {
+		return fmt.Errorf("expecting %v, got %v", expected, token)
+	}
+
+	return nil
+}
+
+// skipValue consumes the next value, no matter its type
+func skipValue(decoder *json.Decoder) error {
+	var ignored json.RawMessage
+	return decoder.Decode(&ignored)
+}
+
+// idToString returns the id of a node read as a json value (string or number)
+func idToString(value any) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case nil:
+		return "", errors.New("missing id")
+	default:
+		return "", fmt.Errorf("invalid id %v", value)
+	}
+}
@@ -0,0 +1,254 @@
+package jsongraph
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/zefrenchwan/nodz.git/graphs"
+	"github.com/zefrenchwan/nodz.git/storage"
+)
+
+// jgfReservedKeys are the keys of JGF records that are not metadata
+var jgfReservedKeys = []string{"id", "label", "source", "target", "directed", "relation", "metadata"}
+
+// WriteJGF writes g using the JSON Graph Format (version 2, nodes are an object keyed by id).
+// Labels of nodes (WithLabels) are joined in the "label" field, properties (WithProperties) are metadata.
+func WriteJGF[N graphs.Node, L graphs.Link[N]](writer io.Writer, g graphs.CentralStructureGraph[N, L], options Options[N, L]) error {
+	names, errNames := storage.NewNodesNames(g, options.Identifier)
+	if errNames != nil {
+		return errNames
+	}
+
+	stream := newStreamWriter(writer)
+	stream.raw(`{"graph":{"directed":`)
+	stream.value(options.Info.Directed)
+	if options.Info.Multigraph {
+		stream.raw(`,"type":"multigraph"`)
+	}
+
+	stream.raw(`,"metadata":`)
+	stream.value(nonNilAttributes(options.Info.Attributes))
+	stream.raw(`,"nodes":{`)
+
+	for index, node := range names.Nodes() {
+		record := make(map[string]any)
+		properties, labels := nodeAttributes(node)
+		if len(properties) != 0 {
+			record["metadata"] = properties
+		}
+
+		if withLabels, ok := any(node).(graphs.WithLabels); ok && len(labels) != 0 {
+			record["label"] = graphs.JoinLabels(withLabels)
+		}
+
+		if index != 0 {
+			stream.raw(",")
+		}
+
+		stream.value(names.Names()[index])
+		stream.raw(":")
+		stream.value(record)
+	}
+
+	stream.raw(`},"edges":[`)
+
+	first := true
+	errVisit := storage.VisitLinks(g, func(link L) error {
+		record := make(map[string]any)
+		metadata := make(map[string]any)
+		for key, value := range linkAttributes(link) {
+			metadata[key] = value
+		}
+
+		if options.Weigher != nil {
+			metadata["weight"] = options.Weigher(link)
+		}
+
+		if len(metadata) != 0 {
+			record["metadata"] = metadata
+		}
+
+		if source, err := names.Name(link.Source()); err != nil {
+			return err
+		} else {
+			record["source"] = source
+		}
+
+		if target, err := names.Name(link.Destination()); err != nil {
+			return err
+		} else {
+			record["target"] = target
+		}
+
+		record["directed"] = link.IsDirected()
+		if !first {
+			stream.raw(",")
+		}
+
+		first = false
+		stream.value(record)
+		return stream.err
+	})
+
+	if errVisit != nil {
+		return errVisit
+	}
+
+	stream.raw("]}}\n")
+	return stream.flush()
+}
+
+// ReadJGF reads a JSON Graph Format document and streams its content into g.
+// Both version 1 (nodes as an array) and version 2 (nodes as an object) are accepted.
+// Only the "graph" element is read, documents with a "graphs" list are not supported.
+func ReadJGF[N graphs.Node, L graphs.Link[N]](
+	reader io.Reader, // content to read, may be gzip compressed
+	g graphs.CentralStructureGraph[N, L], // graph to fill
+	nodes storage.NodeFactory[N], // builds a node from its id
+	links storage.LinkFactory[N, L], // builds a link from its extremities and weight
+) (GraphInfo, error) {
+	var info GraphInfo
+	info.Attributes = make(map[string]string)
+
+	if g == nil {
+		return info, errors.New("nil graph")
+	} else if nodes == nil || links == nil {
+		return info, errors.New("nil factory")
+	}
+
+	content, errContent := storage.NewDecompressedReader(reader)
+	if errContent != nil {
+		return info, errContent
+	}
+
+	defer content.Close()
+
+	registry := storage.NewNodesRegistry(nodes)
+	decoder := json.NewDecoder(content)
+	decoder.UseNumber()
+
+	// addNode adds a node given its id and its JGF record
+	addNode := func(id string, record map[string]any) error {
+		node, _, errNode := registry.Get(id)
+		if errNode != nil {
+			return errNode
+		}
+
+		metadata, _ := record["metadata"].(map[string]any)
+		applyAttributes(node, metadata, labelsFromAttribute(record["label"]), nil)
+		return g.AddNode(node)
+	}
+
+	found := false
+	errRead := readObject(decoder, func(key string) error {
+		if key == "graphs" {
+			return errors.New("multiple graphs documents are not supported")
+		} else if key != "graph" {
+			return skipValue(decoder)
+		}
+
+		found = true
+		return readObject(decoder, func(graphKey string) error {
+			switch graphKey {
+			case "directed":
+				return decoder.Decode(&info.Directed)
+			case "type":
+				var graphType string
+				if err := decoder.Decode(&graphType); err != nil {
+					return err
+				}
+
+				info.Multigraph = graphType == "multigraph"
+				return nil
+			case "metadata":
+				var attributes map[string]any
+				if err := decoder.Decode(&attributes); err != nil {
+					return err
+				}
+
+				info.Attributes = attributesToInfo(attributes)
+				return nil
+			case "nodes":
+				return readJGFNodes(decoder, addNode)
+			case "edges":
+				return readArray(decoder, func() error {
+					var record map[string]any
+					if err := decoder.Decode(&record); err != nil {
+						return err
+					}
+
+					// weight and properties are metadata in JGF
+					linkRecord, _ := record["metadata"].(map[string]any)
+					if linkRecord == nil {
+						linkRecord = make(map[string]any)
+					}
+
+					linkRecord["source"] = record["source"]
+					linkRecord["target"] = record["target"]
+					return addLinkRecord(g, &registry, links, linkRecord, jgfReservedKeys)
+				})
+			default:
+				return skipValue(decoder)
+			}
+		})
+	})
+
+	if errRead == nil && !found {
+		errRead = errors.New("no graph in document")
+	}
+
+	return info, errRead
+}
+
+// readJGFNodes reads nodes, either as an object keyed by id (version 2) or as an array (version 1)
+func readJGFNodes(decoder *json.Decoder, addNode func(string, map[string]any) error) error {
+	token, errToken := decoder.Token()
+	if errToken != nil {
+		return errToken
+	}
+
+	delimiter, ok := token.(json.Delim)
+	switch {
+	case ok && delimiter == '{':
+		for decoder.More() {
+			keyToken, errKey := decoder.Token()
+			if errKey != nil {
+				return errKey
+			}
+
+			id, isString := keyToken.(string)
+			if !isString {
+				return fmt.Errorf("expecting node id, got %v", keyToken)
+			}
+
+			var record map[string]any
+			if err := decoder.Decode(&record); err != nil {
+				return err
+			} else if err := addNode(id, record); err != nil {
+				return err
+			}
+		}
+
+		return expectDelimiter(decoder, '}')
+	case ok && delimiter == '[':
+		for index := 0; decoder.More(); index++ {
+			var record map[string]any
+			if err := decoder.Decode(&record); err != nil {
+				return err
+			}
+
+			id, errId := idToString(record["id"])
+			if errId != nil {
+				return fmt.Errorf("node %d: %w", index, errId)
+			} else if err := addNode(id, record); err != nil {
+				return err
+			}
+		}
+
+		return expectDelimiter(decoder, ']')
+	default:
+		return fmt.Errorf("invalid nodes, got %v", token)
+	}
+}
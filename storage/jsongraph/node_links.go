@@ -0,0 +1,267 @@
+package jsongraph
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/zefrenchwan/nodz.git/graphs"
+	"github.com/zefrenchwan/nodz.git/storage"
+)
+
+// WriteNodeLink writes g using the networkx node_link_data layout.
+// Nodes and links are encoded one by one, so no need to build the whole document in memory.
+// Properties of nodes and links (WithProperties) are attributes, labels of nodes (WithLabels) are the "labels" attribute.
+func WriteNodeLink[N graphs.Node, L graphs.Link[N]](writer io.Writer, g graphs.CentralStructureGraph[N, L], options Options[N, L]) error {
+	names, errNames := storage.NewNodesNames(g, options.Identifier)
+	if errNames != nil {
+		return errNames
+	}
+
+	stream := newStreamWriter(writer)
+	stream.raw(`{"directed":`)
+	stream.value(options.Info.Directed)
+	stream.raw(`,"multigraph":`)
+	stream.value(options.Info.Multigraph)
+	stream.raw(`,"graph":`)
+	stream.value(nonNilAttributes(options.Info.Attributes))
+	stream.raw(`,"nodes":[`)
+
+	for index, node := range names.Nodes() {
+		record := make(map[string]any)
+		properties, labels := nodeAttributes(node)
+		for key, value := range properties {
+			record[key] = value
+		}
+
+		if len(labels) != 0 {
+			record["labels"] = labels
+		}
+
+		record["id"] = names.Names()[index]
+		if index != 0 {
+			stream.raw(",")
+		}
+
+		stream.value(record)
+	}
+
+	stream.raw(`],"links":[`)
+
+	first := true
+	errVisit := storage.VisitLinks(g, func(link L) error {
+		record := make(map[string]any)
+		for key, value := range linkAttributes(link) {
+			record[key] = value
+		}
+
+		if options.Weigher != nil {
+			record["weight"] = options.Weigher(link)
+		}
+
+		if source, err := names.Name(link.Source()); err != nil {
+			return err
+		} else {
+			record["source"] = source
+		}
+
+		if target, err := names.Name(link.Destination()); err != nil {
+			return err
+		} else {
+			record["target"] = target
+		}
+
+		if !first {
+			stream.raw(",")
+		}
+
+		first = false
+		stream.value(record)
+		return stream.err
+	})
+
+	if errVisit != nil {
+		return errVisit
+	}
+
+	stream.raw("]}\n")
+	return stream.flush()
+}
+
+// ReadNodeLink reads a networkx node_link_data document and streams its content into g.
+// Both "links" and "edges" keys are accepted for links.
+// Nodes and links accepting properties (WithProperties) and labels (WithLabels) get their attributes.
+// Result is the graph level information of the document.
+func ReadNodeLink[N graphs.Node, L graphs.Link[N]](
+	reader io.Reader, // content to read, may be gzip compressed
+	g graphs.CentralStructureGraph[N, L], // graph to fill
+	nodes storage.NodeFactory[N], // builds a node from its id
+	links storage.LinkFactory[N, L], // builds a link from its extremities and weight
+) (GraphInfo, error) {
+	var info GraphInfo
+	info.Attributes = make(map[string]string)
+
+	if g == nil {
+		return info, errors.New("nil graph")
+	} else if nodes == nil || links == nil {
+		return info, errors.New("nil factory")
+	}
+
+	content, errContent := storage.NewDecompressedReader(reader)
+	if errContent != nil {
+		return info, errContent
+	}
+
+	defer content.Close()
+
+	registry := storage.NewNodesRegistry(nodes)
+	decoder := json.NewDecoder(content)
+	decoder.UseNumber()
+
+	errRead := readObject(decoder, func(key string) error {
+		switch key {
+		case "directed":
+			return decoder.Decode(&info.Directed)
+		case "multigraph":
+			return decoder.Decode(&info.Multigraph)
+		case "graph":
+			var attributes map[string]any
+			if err := decoder.Decode(&attributes); err != nil {
+				return err
+			}
+
+			info.Attributes = attributesToInfo(attributes)
+			return nil
+		case "nodes":
+			return readArray(decoder, func() error {
+				var record map[string]any
+				if err := decoder.Decode(&record); err != nil {
+					return err
+				}
+
+				id, errId := idToString(record["id"])
+				if errId != nil {
+					return errId
+				}
+
+				node, _, errNode := registry.Get(id)
+				if errNode != nil {
+					return errNode
+				}
+
+				applyAttributes(node, record, labelsFromAttribute(record["labels"]), nodeLinkReservedKeys)
+				return g.AddNode(node)
+			})
+		case "links", "edges":
+			return readArray(decoder, func() error {
+				var record map[string]any
+				if err := decoder.Decode(&record); err != nil {
+					return err
+				}
+
+				return addLinkRecord(g, &registry, links, record, nodeLinkReservedKeys)
+			})
+		default:
+			return skipValue(decoder)
+		}
+	})
+
+	return info, errRead
+}
+
+// addLinkRecord builds a link from a json record and adds it in the graph.
+// Record has a source, a target, and may have a weight
+func addLinkRecord[N graphs.Node, L graphs.Link[N]](
+	g graphs.CentralStructureGraph[N, L],
+	registry *storage.NodesRegistry[N],
+	links storage.LinkFactory[N, L],
+	record map[string]any,
+	reserved []string,
+) error {
+	sourceId, errSourceId := idToString(record["source"])
+	if errSourceId != nil {
+		return fmt.Errorf("link source: %w", errSourceId)
+	}
+
+	targetId, errTargetId := idToString(record["target"])
+	if errTargetId != nil {
+		return fmt.Errorf("link target: %w", errTargetId)
+	}
+
+	weight, errWeight := weightOf(record, storage.DefaultLinkWeight)
+	if errWeight != nil {
+		return errWeight
+	}
+
+	source, _, errSource := registry.Get(sourceId)
+	if errSource != nil {
+		return errSource
+	}
+
+	target, _, errTarget := registry.Get(targetId)
+	if errTarget != nil {
+		return errTarget
+	}
+
+	link, errLink := links(source, target, weight)
+	if errLink != nil {
+		return errLink
+	}
+
+	applyAttributes(link, record, nil, reserved)
+	return g.AddLink(link)
+}
+
+// nonNilAttributes returns attributes, or an empty map for nil (to write {} and not null)
+func nonNilAttributes(attributes map[string]string) map[string]string {
+	if attributes == nil {
+		return make(map[string]string)
+	}
+
+	return attributes
+}
+
+// streamWriter writes a json document piece by piece.
+// First error is kept, and next writes do nothing
+type streamWriter struct {
+	// buffer is the buffered writer, shared by raw writes and encoder
+	buffer *bufio.Writer
+	// encoder encodes values in the buffer
+	encoder *json.Encoder
+	// err is the first error that happened
+	err error
+}
+
+// newStreamWriter returns a stream writer over writer
+func newStreamWriter(writer io.Writer) *streamWriter {
+	buffer := bufio.NewWriter(writer)
+	return &streamWriter{
+		buffer:  buffer,
+		encoder: json.NewEncoder(buffer),
+	}
+}
+
+// raw writes content as is
+func (sw *streamWriter) raw(content string) {
+	if sw.err == nil {
+		_, sw.err = sw.buffer.WriteString(content)
+	}
+}
+
+// value writes the json encoding of value
+func (sw *streamWriter) value(value any) {
+	if sw.err == nil {
+		sw.err = sw.encoder.Encode(value)
+	}
+}
+
+// flush writes buffered content and returns the first error, if any
+func (sw *streamWriter) flush() error {
+	if sw.err != nil {
+		return sw.err
+	}
+
+	return sw.buffer.Flush()
+}
@@ -0,0 +1,308 @@
+package matrixmarket
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/zefrenchwan/nodz.git/graphs"
+	"github.com/zefrenchwan/nodz.git/internal/local"
+	"github.com/zefrenchwan/nodz.git/storage"
+)
+
+// Matrix Market coordinate files are:
+//
+//	%%MatrixMarket matrix coordinate real general
+//	% comments
+//	3 3 2
+//	1 2 1.5
+//	3 1 -2
+//
+// Header gives the field (real, integer or pattern) and the symmetry (general or symmetric).
+// Size line is the number of rows, the number of columns and the number of entries.
+// Entries are row, column (starting at 1) and value (no value for pattern).
+// Symmetric matrices only store the lower triangle.
+// Only square matrices are supported, because graphs.Matrix is square.
+
+// Field is the type of values in a Matrix Market file
+type Field string
+
+// Symmetry tells which entries are stored in a Matrix Market file
+type Symmetry string
+
+const (
+	// Real values are floats
+	Real Field = "real"
+	// Integer values are integers
+	Integer Field = "integer"
+	// Pattern has no value, entries are ones
+	Pattern Field = "pattern"
+)
+
+const (
+	// General stores all entries
+	General Symmetry = "general"
+	// Symmetric stores the lower triangle (and the diagonal)
+	Symmetric Symmetry = "symmetric"
+)
+
+// banner starts any Matrix Market file
+const banner = "%%MatrixMarket"
+
+// Header describes a Matrix Market file
+type Header struct {
+	// Field is the type of values
+	Field Field
+	// Symmetry is the storage of entries
+	Symmetry Symmetry
+}
+
+// ReadMatrix reads a square coordinate matrix, missing entries are zeros.
+// Pattern entries are ones. Result is the matrix and the header of the file.
+func ReadMatrix(reader io.Reader) (*local.MapMatrix[float64], Header, error) {
+	var header Header
+	content, errContent := storage.NewDecompressedReader(reader)
+	if errContent != nil {
+		return nil, header, errContent
+	}
+
+	defer content.Close()
+
+	scanner := bufio.NewScanner(content)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	lineNumber := 0
+	// first line is the banner
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return nil, header, err
+		}
+
+		return nil, header, errors.New("empty content")
+	}
+
+	lineNumber++
+	fields := strings.Fields(strings.ToLower(scanner.Text()))
+	if len(fields) != 5 || fields[0] != strings.ToLower(banner) || fields[1] != "matrix" {
+		return nil, header, errors.New("line 1: invalid Matrix Market banner")
+	} else if fields[2] != "coordinate" {
+		return nil, header, fmt.Errorf("line 1: unsupported format %s", fields[2])
+	}
+
+	header.Field = Field(fields[3])
+	header.Symmetry = Symmetry(fields[4])
+	if err := header.validate(); err != nil {
+		return nil, header, fmt.Errorf("line 1: %w", err)
+	}
+
+	var matrix *local.MapMatrix[float64]
+	expected, found := 0, 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "%") {
+			continue
+		}
+
+		values := strings.Fields(line)
+		if matrix == nil {
+			// size line
+			if len(values) != 3 {
+				return nil, header, fmt.Errorf("line %d: expecting rows, columns and entries", lineNumber)
+			}
+
+			sizes := make([]int, 3)
+			for index, value := range values {
+				if size, err := strconv.Atoi(value); err != nil || size < 0 {
+					return nil, header, fmt.Errorf("line %d: invalid size %q", lineNumber, value)
+				} else {
+					sizes[index] = size
+				}
+			}
+
+			if sizes[0] != sizes[1] {
+				return nil, header, fmt.Errorf("line %d: matrix is not square", lineNumber)
+			}
+
+			result, errMatrix := local.NewMapMatrix(sizes[0], 0.0)
+			if errMatrix != nil {
+				return nil, header, fmt.Errorf("line %d: %w", lineNumber, errMatrix)
+			}
+
+			matrix = &result
+			expected = sizes[2]
+			continue
+		}
+
+		i, j, value, errEntry := header.parseEntry(values)
+		if errEntry != nil {
+			return nil, header, fmt.Errorf("line %d: %w", lineNumber, errEntry)
+		} else if header.Symmetry == Symmetric && j > i {
+			return nil, header, fmt.Errorf("line %d: symmetric entry above the diagonal", lineNumber)
+		} else if err := matrix.SetValue(i, j, value); err != nil {
+			return nil, header, fmt.Errorf("line %d: %w", lineNumber, err)
+		} else if header.Symmetry == Symmetric {
+			matrix.SetValue(j, i, value)
+		}
+
+		found++
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, header, err
+	} else if matrix == nil {
+		return nil, header, errors.New("missing size line")
+	} else if found != expected {
+		return nil, header, fmt.Errorf("expecting %d entries, found %d", expected, found)
+	}
+
+	return matrix, header, nil
+}
+
+// WriteMatrix writes matrix as a coordinate file, with non zero values as entries.
+// Integer field raises an error for non integer values.
+// Symmetric raises an error if matrix is not symmetric.
+func WriteMatrix(writer io.Writer, matrix graphs.Matrix[float64], header Header) error {
+	if matrix == nil {
+		return errors.New("nil matrix")
+	} else if err := header.validate(); err != nil {
+		return err
+	}
+
+	// first walkthrough counts entries (and validates all of them), second one writes them.
+	// No need to keep entries in memory
+	entries := 0
+	errCount := visitEntries(matrix, Header{Field: header.Field, Symmetry: General}, func(i, j int, value float64) error {
+		if header.Field == Integer && value != math.Trunc(value) {
+			return fmt.Errorf("non integer value at %d, %d", i+1, j+1)
+		} else if header.Symmetry != Symmetric {
+			entries++
+		} else if other, _, err := matrix.GetValue(j, i); err != nil {
+			return err
+		} else if other != value {
+			return fmt.Errorf("matrix is not symmetric at %d, %d", i+1, j+1)
+		} else if j <= i {
+			entries++
+		}
+
+		return nil
+	})
+
+	if errCount != nil {
+		return errCount
+	}
+
+	buffer := bufio.NewWriter(writer)
+	fmt.Fprintf(buffer, "%s matrix coordinate %s %s\n", banner, header.Field, header.Symmetry)
+	fmt.Fprintf(buffer, "%d %d %d\n", matrix.Size(), matrix.Size(), entries)
+	errWrite := visitEntries(matrix, header, func(i, j int, value float64) error {
+		var err error
+		switch header.Field {
+		case Pattern:
+			_, err = fmt.Fprintf(buffer, "%d %d\n", i+1, j+1)
+		case Integer:
+			_, err = fmt.Fprintf(buffer, "%d %d %d\n", i+1, j+1, int64(value))
+		default:
+			_, err = fmt.Fprintf(buffer, "%d %d %s\n", i+1, j+1, strconv.FormatFloat(value, 'g', -1, 64))
+		}
+
+		return err
+	})
+
+	if errWrite != nil {
+		return errWrite
+	}
+
+	return buffer.Flush()
+}
+
+// validate returns an error for unsupported fields or symmetries
+func (h Header) validate() error {
+	switch h.Field {
+	case Real, Integer, Pattern:
+	default:
+		return fmt.Errorf("unsupported field %s", h.Field)
+	}
+
+	switch h.Symmetry {
+	case General, Symmetric:
+	default:
+		return fmt.Errorf("unsupported symmetry %s", h.Symmetry)
+	}
+
+	return nil
+}
+
+// parseEntry returns the indexes (starting at 0) and the value of an entry
+func (h Header) parseEntry(values []string) (int, int, float64, error) {
+	expected := 3
+	if h.Field == Pattern {
+		expected = 2
+	}
+
+	if len(values) != expected {
+		return 0, 0, 0, fmt.Errorf("expecting %d values", expected)
+	}
+
+	i, errI := strconv.Atoi(values[0])
+	j, errJ := strconv.Atoi(values[1])
+	if errI != nil || errJ != nil {
+		return 0, 0, 0, errors.New("invalid indexes")
+	}
+
+	switch h.Field {
+	case Pattern:
+		return i - 1, j - 1, 1.0, nil
+	case Integer:
+		value, err := strconv.ParseInt(values[2], 10, 64)
+		if err != nil {
+			return 0, 0, 0, fmt.Errorf("invalid integer %q", values[2])
+		}
+
+		return i - 1, j - 1, float64(value), nil
+	default:
+		value, err := strconv.ParseFloat(values[2], 64)
+		if err != nil {
+			return 0, 0, 0, fmt.Errorf("invalid real %q", values[2])
+		}
+
+		return i - 1, j - 1, value, nil
+	}
+}
+
+// visitEntries calls visitor for each non zero value, line by line.
+// For symmetric matrices, only the lower triangle is visited
+func visitEntries(matrix graphs.Matrix[float64], header Header, visitor func(i, j int, value float64) error) error {
+	for i := 0; i < matrix.Size(); i++ {
+		line, errLine := matrix.Line(i)
+		if errLine != nil {
+			return errLine
+		} else if line == nil {
+			continue
+		}
+
+		j := -1
+		for has, errNext := line.Next(); has; has, errNext = line.Next() {
+			j++
+			if errNext != nil {
+				return errNext
+			} else if header.Symmetry == Symmetric && j > i {
+				break
+			}
+
+			value, errValue := line.Value()
+			if errValue != nil {
+				return errValue
+			} else if value == 0 {
+				continue
+			} else if err := visitor(i, j, value); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
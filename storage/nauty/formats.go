@@ -0,0 +1,525 @@
+package nauty
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/zefrenchwan/nodz.git/graphs"
+	"github.com/zefrenchwan/nodz.git/internal/local"
+	"github.com/zefrenchwan/nodz.git/storage"
+)
+
+// Formats are defined in https://users.cecs.anu.edu.au/~bdm/data/formats.txt.
+// Each format is a printable string: N(n) is the number of nodes, R(x) is a bit vector,
+// six bits per character, each character being 63 + value.
+//
+//	graph6: N(n) R(upper triangle of the adjacency matrix, column by column)
+//	digraph6: '&' N(n) R(adjacency matrix, row by row)
+//	sparse6: ':' N(n) R(list of edges, see encodeSparse6)
+//
+// Nodes are numbered from 0 to n-1, node factory gets the number as its id.
+// When encoding, nodes are sorted by name (see storage.NodesNames), numeric names by value.
+
+// Optional headers at the beginning of files
+const (
+	// Graph6Header may start a graph6 file
+	Graph6Header = ">>graph6<<"
+	// Sparse6Header may start a sparse6 file
+	Sparse6Header = ">>sparse6<<"
+	// Digraph6Header may start a digraph6 file
+	Digraph6Header = ">>digraph6<<"
+)
+
+// maxNodes is the max number of nodes in nauty formats (36 bits)
+const maxNodes = 68719476735
+
+// EncodeGraph6 returns the graph6 string of g.
+// Direction of links is ignored. Loops and multiple links cannot be represented in graph6, loops raise an error.
+func EncodeGraph6[N graphs.Node, L graphs.Link[N]](g graphs.CentralStructureGraph[N, L]) (string, error) {
+	size, pairs, errPairs := indexedLinks(g)
+	if errPairs != nil {
+		return "", errPairs
+	}
+
+	adjacency := make(map[[2]int]bool)
+	for _, p := range pairs {
+		if p.source == p.destination {
+			return "", errors.New("graph6 does not support loops, use sparse6")
+		}
+
+		adjacency[[2]int{min(p.source, p.destination), max(p.source, p.destination)}] = true
+	}
+
+	var bits bitWriter
+	for j := 1; j < size; j++ {
+		for i := 0; i < j; i++ {
+			bits.write(adjacency[[2]int{i, j}])
+		}
+	}
+
+	return encodeSize(size) + bits.encode(false), nil
+}
+
+// EncodeDigraph6 returns the digraph6 string of g.
+// Undirected links are set in both directions, multiple links are represented once.
+func EncodeDigraph6[N graphs.Node, L graphs.Link[N]](g graphs.CentralStructureGraph[N, L]) (string, error) {
+	size, pairs, errPairs := indexedLinks(g)
+	if errPairs != nil {
+		return "", errPairs
+	}
+
+	adjacency := make(map[[2]int]bool)
+	for _, p := range pairs {
+		adjacency[[2]int{p.source, p.destination}] = true
+		if !p.directed {
+			adjacency[[2]int{p.destination, p.source}] = true
+		}
+	}
+
+	var bits bitWriter
+	for i := 0; i < size; i++ {
+		for j := 0; j < size; j++ {
+			bits.write(adjacency[[2]int{i, j}])
+		}
+	}
+
+	return "&" + encodeSize(size) + bits.encode(false), nil
+}
+
+// EncodeSparse6 returns the sparse6 string of g.
+// Direction of links is ignored, loops and multiple links are supported.
+func EncodeSparse6[N graphs.Node, L graphs.Link[N]](g graphs.CentralStructureGraph[N, L]) (string, error) {
+	size, pairs, errPairs := indexedLinks(g)
+	if errPairs != nil {
+		return "", errPairs
+	}
+
+	k := sparse6Width(size)
+	// edges as (greater extremity, lower extremity), sorted
+	edges := make([][2]int, 0, len(pairs))
+	for _, p := range pairs {
+		edges = append(edges, [2]int{max(p.source, p.destination), min(p.source, p.destination)})
+	}
+
+	slices.SortFunc(edges, func(a, b [2]int) int {
+		if a[0] != b[0] {
+			return a[0] - b[0]
+		}
+
+		return a[1] - b[1]
+	})
+
+	var bits bitWriter
+	current := 0
+	for _, edge := range edges {
+		v, u := edge[0], edge[1]
+		switch {
+		case v == current:
+			bits.write(false)
+			bits.writeValue(u, k)
+		case v == current+1:
+			current = v
+			bits.write(true)
+			bits.writeValue(u, k)
+		default:
+			current = v
+			bits.write(true)
+			bits.writeValue(v, k)
+			bits.write(false)
+			bits.writeValue(u, k)
+		}
+	}
+
+	// padding with ones could be read as a new edge to n-1 in that special case
+	padding := (6 - bits.size%6) % 6
+	if k < 6 && size == 1<<k && padding >= k && current < size-1 {
+		bits.write(false)
+	}
+
+	return ":" + encodeSize(size) + bits.encode(true), nil
+}
+
+// Decode detects the format of content (graph6, sparse6 or digraph6) and decodes it.
+// Links of graph6 and sparse6 are built with undirected factory, links of digraph6 with directed factory.
+func Decode[N graphs.Node, L graphs.Link[N]](
+	content string, // graph as a string, optional header accepted
+	nodes storage.NodeFactory[N], // builds node from its number
+	directedLinks storage.LinkFactory[N, L], // builds directed links (digraph6)
+	undirectedLinks storage.LinkFactory[N, L], // builds undirected links (graph6 and sparse6)
+) (*local.MapGraph[N, L], error) {
+	content = strings.TrimSpace(content)
+	for _, header := range []string{Graph6Header, Sparse6Header, Digraph6Header} {
+		content = strings.TrimPrefix(content, header)
+	}
+
+	switch {
+	case strings.HasPrefix(content, ":"):
+		return DecodeSparse6(content, nodes, undirectedLinks)
+	case strings.HasPrefix(content, "&"):
+		return DecodeDigraph6(content, nodes, directedLinks)
+	default:
+		return DecodeGraph6(content, nodes, undirectedLinks)
+	}
+}
+
+// DecodeGraph6 returns the graph for a graph6 string
+func DecodeGraph6[N graphs.Node, L graphs.Link[N]](content string, nodes storage.NodeFactory[N], links storage.LinkFactory[N, L]) (*local.MapGraph[N, L], error) {
+	content = strings.TrimPrefix(strings.TrimSpace(content), Graph6Header)
+	size, data, errSize := decodeSize(content)
+	if errSize != nil {
+		return nil, errSize
+	}
+
+	expected := size * (size - 1) / 2
+	if len(data) != (expected+5)/6 {
+		return nil, fmt.Errorf("invalid graph6 length for %d nodes", size)
+	}
+
+	loader, indexes, errNodes := loadNodes[N, L](size, nodes)
+	if errNodes != nil {
+		return nil, errNodes
+	}
+
+	bits := newBitReader(data)
+	for j := 1; j < size; j++ {
+		for i := 0; i < j; i++ {
+			if bit, _ := bits.read(); bit {
+				if err := appendLink(&loader, indexes, i, j, links); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	return loader.Graph(), nil
+}
+
+// DecodeDigraph6 returns the graph for a digraph6 string
+func DecodeDigraph6[N graphs.Node, L graphs.Link[N]](content string, nodes storage.NodeFactory[N], links storage.LinkFactory[N, L]) (*local.MapGraph[N, L], error) {
+	content = strings.TrimPrefix(strings.TrimSpace(content), Digraph6Header)
+	if !strings.HasPrefix(content, "&") {
+		return nil, errors.New("digraph6 starts with &")
+	}
+
+	size, data, errSize := decodeSize(content[1:])
+	if errSize != nil {
+		return nil, errSize
+	}
+
+	if len(data) != (size*size+5)/6 {
+		return nil, fmt.Errorf("invalid digraph6 length for %d nodes", size)
+	}
+
+	loader, indexes, errNodes := loadNodes[N, L](size, nodes)
+	if errNodes != nil {
+		return nil, errNodes
+	}
+
+	bits := newBitReader(data)
+	for i := 0; i < size; i++ {
+		for j := 0; j < size; j++ {
+			if bit, _ := bits.read(); bit {
+				if err := appendLink(&loader, indexes, i, j, links); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	return loader.Graph(), nil
+}
+
+// DecodeSparse6 returns the graph for a sparse6 string.
+// Incremental sparse6 (starting with ;) is not supported.
+func DecodeSparse6[N graphs.Node, L graphs.Link[N]](content string, nodes storage.NodeFactory[N], links storage.LinkFactory[N, L]) (*local.MapGraph[N, L], error) {
+	content = strings.TrimPrefix(strings.TrimSpace(content), Sparse6Header)
+	if !strings.HasPrefix(content, ":") {
+		return nil, errors.New("sparse6 starts with :")
+	}
+
+	size, data, errSize := decodeSize(content[1:])
+	if errSize != nil {
+		return nil, errSize
+	}
+
+	loader, indexes, errNodes := loadNodes[N, L](size, nodes)
+	if errNodes != nil {
+		return nil, errNodes
+	}
+
+	k := sparse6Width(size)
+	bits := newBitReader(data)
+	current := 0
+	for {
+		b, hasB := bits.read()
+		x, hasX := bits.readValue(k)
+		if !hasB || !hasX {
+			break
+		}
+
+		if b {
+			current++
+		}
+
+		// padding with ones leads to values out of range
+		if x >= size || current >= size {
+			break
+		} else if x > current {
+			current = x
+		} else if err := appendLink(&loader, indexes, x, current, links); err != nil {
+			return nil, err
+		}
+	}
+
+	return loader.Graph(), nil
+}
+
+// indexedPair is a link as indexes of its extremities
+type indexedPair struct {
+	// source is the index of the source
+	source int
+	// destination is the index of the destination
+	destination int
+	// directed is the direction of the link
+	directed bool
+}
+
+// indexedLinks returns the number of nodes of g, and its links as indexes
+func indexedLinks[N graphs.Node, L graphs.Link[N]](g graphs.CentralStructureGraph[N, L]) (int, []indexedPair, error) {
+	names, errNames := storage.NewNodesNames(g, nil)
+	if errNames != nil {
+		return 0, nil, errNames
+	}
+
+	size := len(names.Nodes())
+	if size > maxNodes {
+		return 0, nil, errors.New("too many nodes for nauty formats")
+	}
+
+	// nodes are numbered by name, so that decoding and encoding again gives the same string
+	order := make([]int, size)
+	for index := range order {
+		order[index] = index
+	}
+
+	slices.SortFunc(order, func(a, b int) int {
+		return compareNames(names.Names()[a], names.Names()[b])
+	})
+
+	positions := make([]int, size)
+	for position, index := range order {
+		positions[index] = position
+	}
+
+	pairs := make([]indexedPair, 0)
+	errVisit := storage.VisitLinks(g, func(link L) error {
+		source := names.Index(link.Source())
+		destination := names.Index(link.Destination())
+		if source < 0 || destination < 0 {
+			return errors.New("link extremity not in graph")
+		}
+
+		pairs = append(pairs, indexedPair{source: positions[source], destination: positions[destination], directed: link.IsDirected()})
+		return nil
+	})
+
+	return size, pairs, errVisit
+}
+
+// compareNames sorts numbers first (by value), and then other names
+func compareNames(a, b string) int {
+	numberA, errA := strconv.Atoi(a)
+	numberB, errB := strconv.Atoi(b)
+	switch {
+	case errA == nil && errB == nil:
+		return numberA - numberB
+	case errA == nil:
+		return -1
+	case errB == nil:
+		return 1
+	default:
+		return strings.Compare(a, b)
+	}
+}
+
+// loadNodes builds size nodes numbered from 0, and returns the loader and the index of each node in the loader
+func loadNodes[N graphs.Node, L graphs.Link[N]](size int, nodes storage.NodeFactory[N]) (local.MapGraphLoader[N, L], []N, error) {
+	loader := local.NewMapGraphLoader[N, L]()
+	if nodes == nil {
+		return loader, nil, errors.New("nil node factory")
+	}
+
+	result := make([]N, size)
+	for index := 0; index < size; index++ {
+		node, errNode := nodes(strconv.Itoa(index))
+		if errNode != nil {
+			return loader, nil, errNode
+		}
+
+		loader.AppendNode(node)
+		result[index] = node
+	}
+
+	return loader, result, nil
+}
+
+// appendLink builds the link from node i to node j and adds it.
+// Loader indexes nodes in order, so index in loader is index in file
+func appendLink[N graphs.Node, L graphs.Link[N]](loader *local.MapGraphLoader[N, L], nodes []N, i, j int, links storage.LinkFactory[N, L]) error {
+	if links == nil {
+		return errors.New("nil link factory")
+	}
+
+	link, errLink := links(nodes[i], nodes[j], storage.DefaultLinkWeight)
+	if errLink != nil {
+		return errLink
+	}
+
+	return loader.AppendLink(i, j, link)
+}
+
+// sparse6Width returns k, the number of bits to write a node number
+func sparse6Width(size int) int {
+	k := 1
+	for 1<<k < size {
+		k++
+	}
+
+	return k
+}
+
+// encodeSize returns N(n)
+func encodeSize(size int) string {
+	switch {
+	case size <= 62:
+		return string([]byte{byte(size + 63)})
+	case size <= 258047:
+		return string([]byte{126, byte(size>>12&63 + 63), byte(size>>6&63 + 63), byte(size&63 + 63)})
+	default:
+		result := []byte{126, 126}
+		for shift := 30; shift >= 0; shift -= 6 {
+			result = append(result, byte(size>>shift&63+63))
+		}
+
+		return string(result)
+	}
+}
+
+// decodeSize reads N(n) and returns n and the remaining data, as 6 bits values
+func decodeSize(content string) (int, []byte, error) {
+	data := make([]byte, len(content))
+	for index := 0; index < len(content); index++ {
+		if content[index] < 63 || content[index] > 126 {
+			return 0, nil, fmt.Errorf("invalid character at position %d", index)
+		}
+
+		data[index] = content[index] - 63
+	}
+
+	switch {
+	case len(data) == 0:
+		return 0, nil, errors.New("empty content")
+	case data[0] < 63:
+		return int(data[0]), data[1:], nil
+	case len(data) >= 4 && data[1] < 63:
+		return int(data[1])<<12 | int(data[2])<<6 | int(data[3]), data[4:], nil
+	case len(data) >= 8 && data[1] == 63:
+		size := 0
+		for _, value := range data[2:8] {
+			size = size<<6 | int(value)
+		}
+
+		return size, data[8:], nil
+	default:
+		return 0, nil, errors.New("invalid size")
+	}
+}
+
+// bitWriter appends bits, to encode them six by six
+type bitWriter struct {
+	// bits are the bits so far
+	bits []bool
+	// size is the number of bits so far
+	size int
+}
+
+// write appends a bit
+func (bw *bitWriter) write(bit bool) {
+	bw.bits = append(bw.bits, bit)
+	bw.size++
+}
+
+// writeValue appends value on width bits, most significant first
+func (bw *bitWriter) writeValue(value, width int) {
+	for shift := width - 1; shift >= 0; shift-- {
+		bw.write(value>>shift&1 == 1)
+	}
+}
+
+// encode pads bits to a multiple of six (with ones if padWithOnes, zeros otherwise) and returns R(x)
+func (bw *bitWriter) encode(padWithOnes bool) string {
+	for bw.size%6 != 0 {
+		bw.write(padWithOnes)
+	}
+
+	result := make([]byte, 0, bw.size/6)
+	for index := 0; index < bw.size; index += 6 {
+		var value byte
+		for _, bit := range bw.bits[index : index+6] {
+			value <<= 1
+			if bit {
+				value |= 1
+			}
+		}
+
+		result = append(result, value+63)
+	}
+
+	return string(result)
+}
+
+// bitReader reads bits from 6 bits values
+type bitReader struct {
+	// data are the 6 bits values
+	data []byte
+	// position is the index of the next bit to read
+	position int
+}
+
+// newBitReader returns a reader over data
+func newBitReader(data []byte) bitReader {
+	return bitReader{data: data}
+}
+
+// read returns the next bit, and false if there is no more bit
+func (br *bitReader) read() (bool, bool) {
+	if br.position >= 6*len(br.data) {
+		return false, false
+	}
+
+	value := br.data[br.position/6]
+	bit := value>>(5-br.position%6)&1 == 1
+	br.position++
+	return bit, true
+}
+
+// readValue reads a value on width bits, and false if there are not enough bits
+func (br *bitReader) readValue(width int) (int, bool) {
+	result := 0
+	for index := 0; index < width; index++ {
+		bit, has := br.read()
+		if !has {
+			return 0, false
+		}
+
+		result <<= 1
+		if bit {
+			result |= 1
+		}
+	}
+
+	return result, true
+}
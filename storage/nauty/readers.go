@@ -0,0 +1,136 @@
+package nauty
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/zefrenchwan/nodz.git/graphs"
+	"github.com/zefrenchwan/nodz.git/internal/local"
+	"github.com/zefrenchwan/nodz.git/storage"
+)
+
+// GraphsReader iterates over the graphs of a file, one graph per line.
+// Lines may mix graph6, sparse6 and digraph6. Empty lines and headers are skipped.
+// Graphs are decoded one at a time, file is never fully loaded.
+type GraphsReader[N graphs.Node, L graphs.Link[N]] struct {
+	// content is the decompressed content, closed at the end of the iteration
+	content io.ReadCloser
+	// scanner reads lines
+	scanner *bufio.Scanner
+	// nodes builds nodes from their number
+	nodes storage.NodeFactory[N]
+	// directedLinks builds links of digraph6 graphs
+	directedLinks storage.LinkFactory[N, L]
+	// undirectedLinks builds links of graph6 and sparse6 graphs
+	undirectedLinks storage.LinkFactory[N, L]
+	// lineNumber is the number of the current line, starting at 1
+	lineNumber int
+	// current is the last decoded graph
+	current *local.MapGraph[N, L]
+	// currentError is the error for the current line, if any
+	currentError error
+}
+
+// NewGraphsReader returns an iterator over the graphs of reader (possibly gzip compressed).
+// Close it when iteration stops before the end
+func NewGraphsReader[N graphs.Node, L graphs.Link[N]](
+	reader io.Reader, // content to read, one graph per line
+	nodes storage.NodeFactory[N], // builds nodes from their number
+	directedLinks storage.LinkFactory[N, L], // builds directed links (digraph6)
+	undirectedLinks storage.LinkFactory[N, L], // builds undirected links (graph6 and sparse6)
+) (*GraphsReader[N, L], error) {
+	if nodes == nil || directedLinks == nil || undirectedLinks == nil {
+		return nil, errors.New("nil factory")
+	}
+
+	content, errContent := storage.NewDecompressedReader(reader)
+	if errContent != nil {
+		return nil, errContent
+	}
+
+	scanner := bufio.NewScanner(content)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	return &GraphsReader[N, L]{
+		content:         content,
+		scanner:         scanner,
+		nodes:           nodes,
+		directedLinks:   directedLinks,
+		undirectedLinks: undirectedLinks,
+	}, nil
+}
+
+// Next decodes the next graph, if any.
+// A line that cannot be decoded returns true and an error, so that iteration may go on with next line.
+// Read errors return false and the error.
+func (gr *GraphsReader[N, L]) Next() (bool, error) {
+	gr.current = nil
+	gr.currentError = nil
+	for gr.scanner.Scan() {
+		gr.lineNumber++
+		line := strings.TrimSpace(gr.scanner.Text())
+		for _, header := range []string{Graph6Header, Sparse6Header, Digraph6Header} {
+			line = strings.TrimPrefix(line, header)
+		}
+
+		if len(line) == 0 {
+			continue
+		}
+
+		gr.current, gr.currentError = Decode(line, gr.nodes, gr.directedLinks, gr.undirectedLinks)
+		if gr.currentError != nil {
+			gr.currentError = fmt.Errorf("line %d: %w", gr.lineNumber, gr.currentError)
+		}
+
+		return true, gr.currentError
+	}
+
+	return false, errors.Join(gr.scanner.Err(), gr.Close())
+}
+
+// Close releases the decompressed content. Next calls it at the end of the iteration
+func (gr *GraphsReader[N, L]) Close() error {
+	return gr.content.Close()
+}
+
+// Value returns the current graph, or the error of its line
+func (gr *GraphsReader[N, L]) Value() (*local.MapGraph[N, L], error) {
+	if gr.currentError != nil {
+		return nil, gr.currentError
+	} else if gr.current == nil {
+		return nil, errors.New("no current graph")
+	}
+
+	return gr.current, nil
+}
+
+// WriteGraphs writes each graph of an iterator on its own line, with encoder (for instance EncodeGraph6)
+func WriteGraphs[N graphs.Node, L graphs.Link[N], G graphs.CentralStructureGraph[N, L]](
+	writer io.Writer, // destination of the content
+	iterator graphs.GeneralIterator[G], // graphs to write
+	encoder func(graphs.CentralStructureGraph[N, L]) (string, error), // encodes a graph
+) error {
+	buffer := bufio.NewWriter(writer)
+	for has, errNext := iterator.Next(); has || errNext != nil; has, errNext = iterator.Next() {
+		if errNext != nil {
+			return errNext
+		}
+
+		g, errValue := iterator.Value()
+		if errValue != nil {
+			return errValue
+		}
+
+		line, errEncode := encoder(g)
+		if errEncode != nil {
+			return errEncode
+		}
+
+		buffer.WriteString(line)
+		buffer.WriteByte('\n')
+	}
+
+	return buffer.Flush()
+}
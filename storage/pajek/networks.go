@@ -0,0 +1,334 @@
+package pajek
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/zefrenchwan/nodz.git/graphs"
+	"github.com/zefrenchwan/nodz.git/storage"
+)
+
+// Pajek .net files are made of sections:
+//
+//	*Vertices 3
+//	1 "a"
+//	2 "b"
+//	3 "c"
+//	*Arcs
+//	1 2 1.5
+//	*Edges
+//	2 3
+//
+// Vertices are numbered from 1, arcs are directed links and edges are undirected links.
+// Third value of arcs and edges, if any, is the weight.
+// Vertices with no line are implicit: *Vertices 3 alone declares vertices 1, 2 and 3.
+// *Arcslist and *Edgeslist sections have a source and all its destinations on each line:
+//
+//	*Arcslist
+//	1 2 3
+
+// section is the current section while reading a file
+type section int
+
+const (
+	// noSection is before the first section
+	noSection section = iota
+	// verticesSection is the *Vertices section
+	verticesSection
+	// arcsSection is the *Arcs section, directed links
+	arcsSection
+	// edgesSection is the *Edges section, undirected links
+	edgesSection
+	// arcsListSection is the *Arcslist section, directed links from the first vertex of each line
+	arcsListSection
+	// edgesListSection is the *Edgeslist section, undirected links from the first vertex of each line
+	edgesListSection
+)
+
+// ReadPajek reads a pajek network and streams its content into g.
+// Nodes are built from their label, or from their number if they have no label.
+// Arcs are built with arcs factory, edges with edges factory.
+// Unsupported sections (matrices, for instance) raise an error.
+func ReadPajek[N graphs.Node, L graphs.Link[N]](
+	reader io.Reader, // content to read, may be gzip compressed
+	g graphs.CentralStructureGraph[N, L], // graph to fill
+	nodes storage.NodeFactory[N], // builds a node from its label
+	arcs storage.LinkFactory[N, L], // builds directed links
+	edges storage.LinkFactory[N, L], // builds undirected links
+) error {
+	if g == nil {
+		return errors.New("nil graph")
+	} else if nodes == nil || arcs == nil || edges == nil {
+		return errors.New("nil factory")
+	}
+
+	content, errContent := storage.NewDecompressedReader(reader)
+	if errContent != nil {
+		return errContent
+	}
+
+	defer content.Close()
+
+	// vertices by number
+	vertices := make(map[int]N)
+	vertexByNumber := func(lineNumber int, value string) (N, error) {
+		var empty N
+		number, errNumber := strconv.Atoi(value)
+		if errNumber != nil {
+			return empty, fmt.Errorf("line %d: invalid vertex number %q", lineNumber, value)
+		} else if node, found := vertices[number]; !found {
+			return empty, fmt.Errorf("line %d: undeclared vertex %d", lineNumber, number)
+		} else {
+			return node, nil
+		}
+	}
+
+	// declared is the number of vertices of *Vertices, vertices with no line are added at the end of the section
+	declared := 0
+	addImplicitVertices := func() error {
+		for number := 1; number <= declared; number++ {
+			if _, found := vertices[number]; found {
+				continue
+			}
+
+			node, errNode := nodes(strconv.Itoa(number))
+			if errNode != nil {
+				return fmt.Errorf("vertex %d: %w", number, errNode)
+			} else if err := g.AddNode(node); err != nil {
+				return err
+			}
+
+			vertices[number] = node
+		}
+
+		declared = 0
+		return nil
+	}
+
+	// addLink adds the link built by the factory of the section
+	addLink := func(lineNumber int, section section, source, destination N, weight float64) error {
+		factory := arcs
+		if section == edgesSection || section == edgesListSection {
+			factory = edges
+		}
+
+		if link, errLink := factory(source, destination, weight); errLink != nil {
+			return fmt.Errorf("line %d: %w", lineNumber, errLink)
+		} else {
+			return g.AddLink(link)
+		}
+	}
+
+	scanner := bufio.NewScanner(content)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	current := noSection
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "%") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "*") {
+			if err := addImplicitVertices(); err != nil {
+				return err
+			}
+
+			fields := strings.Fields(line)
+			switch strings.ToLower(fields[0]) {
+			case "*vertices":
+				current = verticesSection
+				if len(fields) > 1 {
+					size, errSize := strconv.Atoi(fields[1])
+					if errSize != nil || size < 0 {
+						return fmt.Errorf("line %d: invalid vertices size %q", lineNumber, fields[1])
+					}
+
+					declared = size
+				}
+			case "*arcs":
+				current = arcsSection
+			case "*edges":
+				current = edgesSection
+			case "*arcslist":
+				current = arcsListSection
+			case "*edgeslist":
+				current = edgesListSection
+			case "*network":
+				current = noSection
+			default:
+				return fmt.Errorf("line %d: unsupported section %s", lineNumber, fields[0])
+			}
+
+			continue
+		}
+
+		values := splitPajekLine(line)
+		switch current {
+		case verticesSection:
+			number, errNumber := strconv.Atoi(values[0])
+			if errNumber != nil {
+				return fmt.Errorf("line %d: invalid vertex number %q", lineNumber, values[0])
+			}
+
+			id := values[0]
+			if len(values) > 1 {
+				id = values[1]
+			}
+
+			node, errNode := nodes(id)
+			if errNode != nil {
+				return fmt.Errorf("line %d: %w", lineNumber, errNode)
+			} else if err := g.AddNode(node); err != nil {
+				return err
+			}
+
+			vertices[number] = node
+		case arcsSection, edgesSection:
+			if len(values) < 2 {
+				return fmt.Errorf("line %d: expecting source and destination", lineNumber)
+			}
+
+			source, errSource := vertexByNumber(lineNumber, values[0])
+			if errSource != nil {
+				return errSource
+			}
+
+			destination, errDest := vertexByNumber(lineNumber, values[1])
+			if errDest != nil {
+				return errDest
+			}
+
+			weight := storage.DefaultLinkWeight
+			if len(values) > 2 {
+				if w, errW := strconv.ParseFloat(values[2], 64); errW != nil {
+					return fmt.Errorf("line %d: invalid weight %q", lineNumber, values[2])
+				} else {
+					weight = w
+				}
+			}
+
+			if err := addLink(lineNumber, current, source, destination, weight); err != nil {
+				return err
+			}
+		case arcsListSection, edgesListSection:
+			source, errSource := vertexByNumber(lineNumber, values[0])
+			if errSource != nil {
+				return errSource
+			}
+
+			for _, value := range values[1:] {
+				if destination, err := vertexByNumber(lineNumber, value); err != nil {
+					return err
+				} else if err := addLink(lineNumber, current, source, destination, storage.DefaultLinkWeight); err != nil {
+					return err
+				}
+			}
+		case noSection:
+			return fmt.Errorf("line %d: content outside of a section", lineNumber)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	return addImplicitVertices()
+}
+
+// WritePajek writes g as a pajek network.
+// Nodes are labelled with their name (see storage.NodesNames), directed links are arcs and undirected links are edges.
+// Weights are written if weigher is not nil.
+func WritePajek[N graphs.Node, L graphs.Link[N]](
+	writer io.Writer, // destination of the content
+	g graphs.CentralStructureGraph[N, L], // graph to write
+	identifier storage.NodeIdentifier[N], // names nodes, may be nil
+	weigher storage.LinkWeigher[N, L], // weight of links, may be nil
+) error {
+	names, errNames := storage.NewNodesNames(g, identifier)
+	if errNames != nil {
+		return errNames
+	}
+
+	buffer := bufio.NewWriter(writer)
+	fmt.Fprintf(buffer, "*Vertices %d\n", len(names.Nodes()))
+	for index, name := range names.Names() {
+		fmt.Fprintf(buffer, "%d %s\n", index+1, quote(name))
+	}
+
+	// arcs first, and then edges: two walkthroughs, no need to keep links in memory
+	for _, directed := range []bool{true, false} {
+		if directed {
+			buffer.WriteString("*Arcs\n")
+		} else {
+			buffer.WriteString("*Edges\n")
+		}
+
+		errVisit := storage.VisitLinks(g, func(link L) error {
+			if link.IsDirected() != directed {
+				return nil
+			}
+
+			source := names.Index(link.Source())
+			destination := names.Index(link.Destination())
+			if source < 0 || destination < 0 {
+				return errors.New("link extremity not in graph")
+			}
+
+			if weigher == nil {
+				_, err := fmt.Fprintf(buffer, "%d %d\n", source+1, destination+1)
+				return err
+			}
+
+			weight := strconv.FormatFloat(weigher(link), 'g', -1, 64)
+			_, err := fmt.Fprintf(buffer, "%d %d %s\n", source+1, destination+1, weight)
+			return err
+		})
+
+		if errVisit != nil {
+			return errVisit
+		}
+	}
+
+	return buffer.Flush()
+}
+
+// splitPajekLine splits a line on whitespaces, but keeps quoted values as one value (with no quote)
+func splitPajekLine(line string) []string {
+	result := make([]string, 0)
+	var current strings.Builder
+	quoted := false
+	inValue := false
+	for _, r := range line {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			inValue = true
+		case !quoted && (r == ' ' || r == '\t'):
+			if inValue {
+				result = append(result, current.String())
+				current.Reset()
+				inValue = false
+			}
+		default:
+			current.WriteRune(r)
+			inValue = true
+		}
+	}
+
+	if inValue {
+		result = append(result, current.String())
+	}
+
+	return result
+}
+
+// quote returns the name between quotes, pajek does not escape quotes so they are removed
+func quote(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, "") + `"`
+}
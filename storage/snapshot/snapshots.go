@@ -0,0 +1,322 @@
+package snapshot
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+
+	"github.com/zefrenchwan/nodz.git/graphs"
+	"github.com/zefrenchwan/nodz.git/internal/local"
+	"github.com/zefrenchwan/nodz.git/storage"
+)
+
+// Binary layout of a snapshot, all integers are unsigned varints unless specified:
+//
+//	header: magic "NODZ", format version (one byte), flags (one byte, reserved)
+//	nodes: number of nodes, then for each node: payload size, payload
+//	adjacency: for each node, in the same order: number of links from that node,
+//	then for each link: destination node index, payload size, payload
+//	trailer: crc32 (IEEE, little endian, 4 bytes) of all previous bytes
+//
+// Undirected links are stored once, from their source.
+
+// magic is the first bytes of any snapshot
+var magic = []byte("NODZ")
+
+// FormatVersion is the version of the snapshot format written by Save
+const FormatVersion byte = 1
+
+// maxPayloadSize limits the size of a payload, to detect corrupted content before allocating
+const maxPayloadSize = 1 << 30
+
+// Save writes a snapshot of g, encoding nodes and links with codecs.
+func Save[N graphs.Node, L graphs.Link[N]](
+	writer io.Writer, // destination of the snapshot
+	g graphs.CentralStructureGraph[N, L], // graph to save
+	nodeCodec storage.NodeCodec[N], // encodes nodes
+	linkCodec storage.LinkCodec[N, L], // encodes links
+) error {
+	if writer == nil {
+		return errors.New("nil writer")
+	} else if nodeCodec == nil || linkCodec == nil {
+		return errors.New("nil codec")
+	}
+
+	names, errNames := storage.NewNodesNames(g, nil)
+	if errNames != nil {
+		return errNames
+	}
+
+	buffer := bufio.NewWriter(writer)
+	checksum := crc32.NewIEEE()
+	output := io.MultiWriter(buffer, checksum)
+
+	content := make([]byte, 0, 64)
+	content = append(content, magic...)
+	content = append(content, FormatVersion, 0)
+	content = binary.AppendUvarint(content, uint64(len(names.Nodes())))
+	if _, err := output.Write(content); err != nil {
+		return err
+	}
+
+	for _, node := range names.Nodes() {
+		payload, errPayload := nodeCodec.EncodeNode(node)
+		if errPayload != nil {
+			return errPayload
+		} else if err := writePayload(output, payload); err != nil {
+			return err
+		}
+	}
+
+	for _, node := range names.Nodes() {
+		links, errLinks := outgoingLinks(g, node)
+		if errLinks != nil {
+			return errLinks
+		}
+
+		content = binary.AppendUvarint(content[:0], uint64(len(links)))
+		for _, link := range links {
+			destinationIndex := names.Index(link.Destination())
+			if destinationIndex < 0 {
+				return errors.New("link destination not in graph")
+			}
+
+			payload, errPayload := linkCodec.EncodeLink(link)
+			if errPayload != nil {
+				return errPayload
+			}
+
+			content = binary.AppendUvarint(content, uint64(destinationIndex))
+			content = binary.AppendUvarint(content, uint64(len(payload)))
+			content = append(content, payload...)
+		}
+
+		if _, err := output.Write(content); err != nil {
+			return err
+		}
+	}
+
+	if _, err := buffer.Write(binary.LittleEndian.AppendUint32(nil, checksum.Sum32())); err != nil {
+		return err
+	}
+
+	return buffer.Flush()
+}
+
+// Load reads a snapshot and adds its content to g.
+// It works for any graph, but it uses AddNode and AddLink.
+// To load a map graph, LoadMapGraph is way faster.
+func Load[N graphs.Node, L graphs.Link[N]](
+	reader io.Reader, // snapshot content
+	g graphs.CentralStructureGraph[N, L], // graph to fill
+	nodeCodec storage.NodeCodec[N], // decodes nodes
+	linkCodec storage.LinkCodec[N, L], // decodes links
+) error {
+	if g == nil {
+		return errors.New("nil graph")
+	}
+
+	// indexes do not matter, links are added with their nodes
+	return read(reader, nodeCodec, linkCodec,
+		func(node N) (int, error) {
+			return 0, g.AddNode(node)
+		},
+		func(_, _ int, link L) error {
+			return g.AddLink(link)
+		},
+	)
+}
+
+// LoadMapGraph reads a snapshot and returns it as a new map graph
+func LoadMapGraph[N graphs.Node, L graphs.Link[N]](
+	reader io.Reader, // snapshot content
+	nodeCodec storage.NodeCodec[N], // decodes nodes
+	linkCodec storage.LinkCodec[N, L], // decodes links
+) (*local.MapGraph[N, L], error) {
+	loader := local.NewMapGraphLoader[N, L]()
+	errRead := read(reader, nodeCodec, linkCodec,
+		func(node N) (int, error) {
+			return loader.AppendNode(node), nil
+		},
+		loader.AppendLink,
+	)
+
+	if errRead != nil {
+		return nil, errRead
+	}
+
+	return loader.Graph(), nil
+}
+
+// read decodes a snapshot.
+// For each node, in order, it calls onNode that returns the index of the node in the destination.
+// For each link, it calls onLink with destination indexes of its extremities
+func read[N graphs.Node, L graphs.Link[N]](
+	reader io.Reader,
+	nodeCodec storage.NodeCodec[N],
+	linkCodec storage.LinkCodec[N, L],
+	onNode func(N) (int, error),
+	onLink func(int, int, L) error,
+) error {
+	if reader == nil {
+		return errors.New("nil reader")
+	} else if nodeCodec == nil || linkCodec == nil {
+		return errors.New("nil codec")
+	}
+
+	input := checksumReader{reader: bufio.NewReader(reader), checksum: crc32.NewIEEE()}
+
+	header, errHeader := input.readFull(len(magic) + 2)
+	if errHeader != nil {
+		return fmt.Errorf("invalid header: %w", errHeader)
+	} else if string(header[:len(magic)]) != string(magic) {
+		return errors.New("not a nodz snapshot")
+	} else if version := header[len(magic)]; version != FormatVersion {
+		return fmt.Errorf("unsupported snapshot version %d", version)
+	}
+
+	nodesCount, errCount := binary.ReadUvarint(&input)
+	if errCount != nil {
+		return errCount
+	}
+
+	// nodes by snapshot index, and their index in destination
+	nodes := make([]N, 0)
+	indexes := make([]int, 0)
+	for index := uint64(0); index < nodesCount; index++ {
+		payload, errPayload := input.readPayload()
+		if errPayload != nil {
+			return fmt.Errorf("node %d: %w", index, errPayload)
+		}
+
+		node, errNode := nodeCodec.DecodeNode(payload)
+		if errNode != nil {
+			return fmt.Errorf("node %d: %w", index, errNode)
+		}
+
+		destinationIndex, errAdd := onNode(node)
+		if errAdd != nil {
+			return errAdd
+		}
+
+		nodes = append(nodes, node)
+		indexes = append(indexes, destinationIndex)
+	}
+
+	for sourceIndex := range nodes {
+		linksCount, errLinks := binary.ReadUvarint(&input)
+		if errLinks != nil {
+			return errLinks
+		}
+
+		for linkIndex := uint64(0); linkIndex < linksCount; linkIndex++ {
+			destinationIndex, errDestination := binary.ReadUvarint(&input)
+			if errDestination != nil {
+				return errDestination
+			} else if destinationIndex >= nodesCount {
+				return fmt.Errorf("invalid destination index %d", destinationIndex)
+			}
+
+			payload, errPayload := input.readPayload()
+			if errPayload != nil {
+				return errPayload
+			}
+
+			link, errLink := linkCodec.DecodeLink(nodes[sourceIndex], nodes[destinationIndex], payload)
+			if errLink != nil {
+				return errLink
+			} else if err := onLink(indexes[sourceIndex], indexes[destinationIndex], link); err != nil {
+				return err
+			}
+		}
+	}
+
+	expected := input.checksum.Sum32()
+	trailer := make([]byte, 4)
+	if _, err := io.ReadFull(input.reader, trailer); err != nil {
+		return fmt.Errorf("missing checksum: %w", err)
+	} else if binary.LittleEndian.Uint32(trailer) != expected {
+		return errors.New("checksum mismatch, corrupted snapshot")
+	}
+
+	return nil
+}
+
+// outgoingLinks returns the links of the neighborhood of node having node as source
+func outgoingLinks[N graphs.Node, L graphs.Link[N]](g graphs.CentralStructureGraph[N, L], node N) ([]L, error) {
+	result := make([]L, 0)
+	neighbors, errNeighbors := g.Neighbors(node)
+	if errNeighbors != nil || neighbors == nil {
+		return result, errNeighbors
+	}
+
+	it, errIt := neighbors.Links()
+	if errIt != nil {
+		return result, errIt
+	}
+
+	for has, errHas := it.Next(); has; has, errHas = it.Next() {
+		if errHas != nil {
+			return result, errHas
+		} else if link, errLink := it.Value(); errLink != nil {
+			return result, errLink
+		} else if link.Source().SameNode(node) {
+			result = append(result, link)
+		}
+	}
+
+	return result, nil
+}
+
+// writePayload writes size of payload and then payload
+func writePayload(writer io.Writer, payload []byte) error {
+	content := binary.AppendUvarint(make([]byte, 0, len(payload)+binary.MaxVarintLen64), uint64(len(payload)))
+	content = append(content, payload...)
+	_, err := writer.Write(content)
+	return err
+}
+
+// checksumReader reads content and updates checksum with read bytes
+type checksumReader struct {
+	// reader is the source of the content
+	reader *bufio.Reader
+	// checksum is the crc32 of read bytes so far
+	checksum hash.Hash32
+}
+
+// ReadByte reads a byte, so that checksum reader is an io.ByteReader (for varints)
+func (cr *checksumReader) ReadByte() (byte, error) {
+	value, err := cr.reader.ReadByte()
+	if err == nil {
+		cr.checksum.Write([]byte{value})
+	}
+
+	return value, err
+}
+
+// readFull reads exactly size bytes
+func (cr *checksumReader) readFull(size int) ([]byte, error) {
+	result := make([]byte, size)
+	if _, err := io.ReadFull(cr.reader, result); err != nil {
+		return nil, err
+	}
+
+	cr.checksum.Write(result)
+	return result, nil
+}
+
+// readPayload reads size of payload and then payload
+func (cr *checksumReader) readPayload() ([]byte, error) {
+	size, errSize := binary.ReadUvarint(cr)
+	if errSize != nil {
+		return nil, errSize
+	} else if size > maxPayloadSize {
+		return nil, fmt.Errorf("payload size %d exceeds limit", size)
+	}
+
+	return cr.readFull(int(size))
+}
@@ -0,0 +1,251 @@
+package spill
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+
+	"github.com/zefrenchwan/nodz.git/graphs"
+)
+
+// queueValueOverhead is the estimated size of a value in memory, encoded value excluded
+const queueValueOverhead = 24
+
+// Queue is an out of core dynamic iterator, such as the fifo of a breadth first search.
+// Values read are, in order: values added with AddNextValue (the last added first),
+// then values added with AddLastValue (the first added first).
+// Past the memory budget, last values are appended to a file, and read back by chunks.
+// Values added with AddNextValue stay in memory
+type Queue[T any] struct {
+	// workspace has the file of the queue
+	workspace *Workspace
+	// codec encodes values in the file
+	codec Codec[T]
+	// front are the values added with AddNextValue, the last one is the next one
+	front []T
+	// head are the oldest last values, read from the file
+	head []T
+	// file has the last values after head and before tail, nil until first spill
+	file *os.File
+	// read is the position of the next value in the file
+	read int64
+	// written is the size of the file
+	written int64
+	// tail are the newest last values, encoded
+	tail [][]byte
+	// tailSize is the estimated size of tail
+	tailSize int
+	// current is the current value
+	current T
+	// started is true when there is a current value
+	started bool
+}
+
+// NewQueue returns an empty queue with its file in workspace
+func NewQueue[T any](workspace *Workspace, codec Codec[T]) (*Queue[T], error) {
+	if workspace == nil {
+		return nil, errors.New("nil workspace")
+	} else if codec == nil {
+		return nil, errors.New("nil codec")
+	}
+
+	return &Queue[T]{workspace: workspace, codec: codec}, nil
+}
+
+// QueueBuilder returns a builder of queues in workspace
+func QueueBuilder[T any](workspace *Workspace, codec Codec[T]) graphs.DynamicIteratorBuilder[T] {
+	return func() (graphs.DynamicIterator[T], error) {
+		return NewQueue(workspace, codec)
+	}
+}
+
+// Next moves to the next value, if any
+func (q *Queue[T]) Next() (bool, error) {
+	if q == nil {
+		return false, errors.New("nil iterator")
+	}
+
+	q.started = false
+	if len(q.front) != 0 {
+		q.current = q.front[len(q.front)-1]
+		q.front = q.front[:len(q.front)-1]
+		q.started = true
+		return true, nil
+	}
+
+	if len(q.head) == 0 && q.read < q.written {
+		if err := q.load(); err != nil {
+			return false, err
+		}
+	}
+
+	if len(q.head) == 0 && len(q.tail) != 0 {
+		// file is empty: tail is next
+		for _, content := range q.tail {
+			value, err := q.codec.Decode(content)
+			if err != nil {
+				return false, err
+			}
+
+			q.head = append(q.head, value)
+		}
+
+		q.tail, q.tailSize = nil, 0
+	}
+
+	if len(q.head) == 0 {
+		return false, q.reset()
+	}
+
+	q.current = q.head[0]
+	q.head = q.head[1:]
+	q.started = true
+	return true, nil
+}
+
+// Value returns the current value
+func (q *Queue[T]) Value() (T, error) {
+	var empty T
+	if q == nil {
+		return empty, errors.New("nil iterator")
+	} else if !q.started {
+		return empty, errors.New("no current value")
+	}
+
+	return q.current, nil
+}
+
+// AddNextValue adds the value to read at next Next
+func (q *Queue[T]) AddNextValue(value T) error {
+	if q == nil {
+		return errors.New("nil iterator")
+	}
+
+	q.front = append(q.front, value)
+	return nil
+}
+
+// AddLastValue adds the value to read last
+func (q *Queue[T]) AddLastValue(value T) error {
+	if q == nil {
+		return errors.New("nil iterator")
+	}
+
+	content, errContent := q.codec.Encode(value)
+	if errContent != nil {
+		return errContent
+	}
+
+	q.tail = append(q.tail, content)
+	q.tailSize += len(content) + queueValueOverhead
+	if q.tailSize <= q.workspace.budget {
+		return nil
+	}
+
+	return q.spill()
+}
+
+// Halt removes all values
+func (q *Queue[T]) Halt() error {
+	if q == nil {
+		return nil
+	}
+
+	q.front, q.head, q.tail, q.tailSize, q.started = nil, nil, nil, 0, false
+	q.read = q.written
+	return q.reset()
+}
+
+// Close removes the file of the queue, and empties it
+func (q *Queue[T]) Close() error {
+	if q == nil {
+		return nil
+	}
+
+	err := q.Halt()
+	if q.file != nil {
+		err = errors.Join(err, q.workspace.remove(q.file))
+		q.file = nil
+	}
+
+	return err
+}
+
+// spill appends tail to the file
+func (q *Queue[T]) spill() error {
+	if q.file == nil {
+		file, err := q.workspace.create("queue")
+		if err != nil {
+			return err
+		}
+
+		q.file = file
+	}
+
+	content := make([]byte, 0, q.tailSize)
+	for _, value := range q.tail {
+		content = binary.AppendUvarint(content, uint64(len(value)))
+		content = append(content, value...)
+	}
+
+	if _, err := q.file.WriteAt(content, q.written); err != nil {
+		return err
+	}
+
+	q.written += int64(len(content))
+	q.tail, q.tailSize = nil, 0
+	q.workspace.spilled(int64(len(content)))
+	return nil
+}
+
+// load reads a chunk of values from the file into head, at least one value
+func (q *Queue[T]) load() error {
+	chunk := make([]byte, min(q.written-q.read, int64(max(q.workspace.budget/2, 1<<12))))
+	if _, err := q.file.ReadAt(chunk, q.read); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+
+	for position := 0; position < len(chunk); {
+		size, header := binary.Uvarint(chunk[position:])
+		var content []byte
+		switch end := position + header + int(size); {
+		case header <= 0 && len(q.head) != 0:
+			// next chunk starts with this value
+			return nil
+		case header <= 0:
+			return errors.New("invalid queue file")
+		case end <= len(chunk):
+			content = chunk[position+header : end]
+		case len(q.head) != 0:
+			return nil
+		default:
+			// value larger than a chunk
+			content = make([]byte, size)
+			if _, err := q.file.ReadAt(content, q.read+int64(header)); err != nil {
+				return err
+			}
+		}
+
+		value, errValue := q.codec.Decode(content)
+		if errValue != nil {
+			return errValue
+		}
+
+		q.head = append(q.head, value)
+		q.read += int64(header) + int64(size)
+		position += header + int(size)
+	}
+
+	return nil
+}
+
+// reset empties the file once all its values are read
+func (q *Queue[T]) reset() error {
+	if q.file == nil || q.written == 0 || q.read < q.written {
+		return nil
+	}
+
+	q.read, q.written = 0, 0
+	return q.file.Truncate(0)
+}
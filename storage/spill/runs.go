@@ -0,0 +1,267 @@
+package spill
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"sort"
+)
+
+// A run is a file of entries sorted by key, each key once:
+//
+//	key size (uvarint), key, removed flag (1 byte), value size (uvarint), value.
+//
+// A removed entry hides the entries of older runs with the same key.
+// A sparse index in memory (a key every runIndexEvery entries) gives the part of the file to read for a key.
+
+// runIndexEvery is the number of entries between indexed keys
+const runIndexEvery = 32
+
+// entry is a key with its encoded value, or a removed key
+type entry struct {
+	// key of the value
+	key string
+	// value is the encoded value, nil for a removed key
+	value []byte
+	// removed is true for a removed key
+	removed bool
+}
+
+// indexEntry is an indexed key and its position in the run
+type indexEntry struct {
+	// key is the key of the entry at offset
+	key string
+	// offset is the position of the entry in the file
+	offset int64
+}
+
+// run is a sorted file of entries
+type run struct {
+	// file is the content
+	file *os.File
+	// size is the size of the content
+	size int64
+	// index is the sparse index of keys
+	index []indexEntry
+}
+
+// writeRun writes sorted entries to a new file of the workspace
+func writeRun(workspace *Workspace, entries func(yield func(entry) error) error) (*run, error) {
+	file, errFile := workspace.create("run")
+	if errFile != nil {
+		return nil, errFile
+	}
+
+	result := &run{file: file}
+	writer := bufio.NewWriter(file)
+	count := 0
+	errWrite := entries(func(e entry) error {
+		if count%runIndexEvery == 0 {
+			result.index = append(result.index, indexEntry{key: e.key, offset: result.size})
+		}
+
+		count++
+		content := appendEntry(nil, e)
+		result.size += int64(len(content))
+		_, err := writer.Write(content)
+		return err
+	})
+
+	if errWrite == nil {
+		errWrite = writer.Flush()
+	}
+
+	if errWrite != nil {
+		return nil, errors.Join(errWrite, workspace.remove(file))
+	}
+
+	workspace.spilled(result.size)
+	return result, nil
+}
+
+// appendEntry appends the bytes of an entry
+func appendEntry(content []byte, e entry) []byte {
+	content = binary.AppendUvarint(content, uint64(len(e.key)))
+	content = append(content, e.key...)
+	if e.removed {
+		content = append(content, 1)
+	} else {
+		content = append(content, 0)
+	}
+
+	content = binary.AppendUvarint(content, uint64(len(e.value)))
+	return append(content, e.value...)
+}
+
+// readEntry reads the next entry, io.EOF at the end
+func readEntry(reader *bufio.Reader) (entry, error) {
+	var result entry
+	keySize, errKey := binary.ReadUvarint(reader)
+	if errKey != nil {
+		return result, errKey
+	}
+
+	key := make([]byte, keySize)
+	if _, err := io.ReadFull(reader, key); err != nil {
+		return result, io.ErrUnexpectedEOF
+	}
+
+	result.key = string(key)
+	if flag, err := reader.ReadByte(); err != nil {
+		return result, io.ErrUnexpectedEOF
+	} else {
+		result.removed = flag == 1
+	}
+
+	valueSize, errValue := binary.ReadUvarint(reader)
+	if errValue != nil {
+		return result, io.ErrUnexpectedEOF
+	}
+
+	if !result.removed {
+		result.value = make([]byte, valueSize)
+		if _, err := io.ReadFull(reader, result.value); err != nil {
+			return result, io.ErrUnexpectedEOF
+		}
+	}
+
+	return result, nil
+}
+
+// find returns the entry of a key in the run, if any
+func (r *run) find(key string) (entry, bool, error) {
+	// last indexed key not after key
+	position := sort.Search(len(r.index), func(i int) bool { return r.index[i].key > key }) - 1
+	if position < 0 {
+		return entry{}, false, nil
+	}
+
+	end := r.size
+	if position+1 < len(r.index) {
+		end = r.index[position+1].offset
+	}
+
+	start := r.index[position].offset
+	reader := bufio.NewReaderSize(io.NewSectionReader(r.file, start, end-start), int(min(end-start, 1<<16)))
+	for {
+		current, err := readEntry(reader)
+		if errors.Is(err, io.EOF) {
+			return entry{}, false, nil
+		} else if err != nil {
+			return entry{}, false, err
+		} else if current.key == key {
+			return current, true, nil
+		} else if current.key > key {
+			return entry{}, false, nil
+		}
+	}
+}
+
+// cursor reads sorted entries
+type cursor interface {
+	// next returns the next entry, false at the end
+	next() (entry, bool, error)
+}
+
+// runCursor reads the entries of a run in order
+type runCursor struct {
+	// reader reads the file of the run
+	reader *bufio.Reader
+}
+
+// newRunCursor returns a cursor at the start of a run
+func newRunCursor(r *run) *runCursor {
+	return &runCursor{reader: bufio.NewReader(io.NewSectionReader(r.file, 0, r.size))}
+}
+
+// next reads the next entry
+func (rc *runCursor) next() (entry, bool, error) {
+	result, err := readEntry(rc.reader)
+	if errors.Is(err, io.EOF) {
+		return result, false, nil
+	} else if err != nil {
+		return result, false, err
+	}
+
+	return result, true, nil
+}
+
+// sliceCursor reads sorted entries in memory
+type sliceCursor struct {
+	// entries to read
+	entries []entry
+}
+
+// next returns the first entry left
+func (sc *sliceCursor) next() (entry, bool, error) {
+	if len(sc.entries) == 0 {
+		return entry{}, false, nil
+	}
+
+	result := sc.entries[0]
+	sc.entries = sc.entries[1:]
+	return result, true, nil
+}
+
+// merger merges cursors, from the oldest to the newest: for a key, the newest entry wins
+type merger struct {
+	// cursors to merge
+	cursors []cursor
+	// heads are the current entries of cursors
+	heads []entry
+	// active is true for cursors with a current entry
+	active []bool
+	// started is true once heads are read
+	started bool
+}
+
+// newMerger returns a merger of cursors, the oldest first
+func newMerger(cursors []cursor) *merger {
+	return &merger{cursors: cursors, heads: make([]entry, len(cursors)), active: make([]bool, len(cursors))}
+}
+
+// next returns the entry with the smallest key, removed or not
+func (m *merger) next() (entry, bool, error) {
+	if !m.started {
+		m.started = true
+		for index := range m.cursors {
+			if err := m.advance(index); err != nil {
+				return entry{}, false, err
+			}
+		}
+	}
+
+	winner := -1
+	for index := range m.cursors {
+		if !m.active[index] {
+			continue
+		} else if winner < 0 || m.heads[index].key <= m.heads[winner].key {
+			// newer cursors are after older ones: equal keys go to the newest
+			winner = index
+		}
+	}
+
+	if winner < 0 {
+		return entry{}, false, nil
+	}
+
+	result := m.heads[winner]
+	for index := range m.cursors {
+		if m.active[index] && m.heads[index].key == result.key {
+			if err := m.advance(index); err != nil {
+				return entry{}, false, err
+			}
+		}
+	}
+
+	return result, true, nil
+}
+
+// advance reads the next entry of a cursor
+func (m *merger) advance(index int) error {
+	current, has, err := m.cursors[index].next()
+	m.heads[index], m.active[index] = current, has
+	return err
+}
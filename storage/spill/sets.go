@@ -0,0 +1,399 @@
+package spill
+
+import (
+	"errors"
+	"maps"
+	"math/bits"
+	"slices"
+
+	"github.com/zefrenchwan/nodz.git/graphs"
+)
+
+// mergeFactor is the number of consecutive runs of the same tier merged into one
+const mergeFactor = 4
+
+// memoryEntryOverhead is the estimated size of an entry in memory, key and value excluded
+const memoryEntryOverhead = 64
+
+// Set is an out of core set.
+// Elements are indexed by key in a memory segment. Past the memory budget, segment is written as a run (file sorted by key).
+// Has and Remove read a part of each run. Runs are merged by tiers of similar sizes:
+// mergeFactor consecutive runs of a tier are merged into a run of a bigger tier,
+// so that the number of runs grows with the logarithm of the size of the set, and each element is merged a logarithmic number of times.
+// Elements are the same for the set if they have the same key, so key should agree with equality
+type Set[T any] struct {
+	// workspace has the files of the set
+	workspace *Workspace
+	// codec encodes elements in files
+	codec Codec[T]
+	// key returns the key of an element, nil for ids
+	key func(T) string
+	// memory is the memory segment, by key. Removed keys hide older runs
+	memory map[string]entry
+	// memorySize is the estimated size of the memory segment
+	memorySize int
+	// runs are the files, the oldest first
+	runs []*run
+	// size is the number of elements
+	size int64
+	// generation changes when runs are merged: iterators on previous runs are invalid
+	generation int64
+	// peeked reads elements for Peek, nil when an element was added since
+	peeked *SetIterator[T]
+}
+
+// NewSet returns an empty set with its files in workspace.
+// Key returns the key of an element, if nil, elements should implement graphs.WithId, and key is the id
+func NewSet[T any](workspace *Workspace, codec Codec[T], key func(T) string) (*Set[T], error) {
+	if workspace == nil {
+		return nil, errors.New("nil workspace")
+	} else if codec == nil {
+		return nil, errors.New("nil codec")
+	}
+
+	return &Set[T]{workspace: workspace, codec: codec, key: key, memory: make(map[string]entry)}, nil
+}
+
+// SetBuilder returns a builder of sets in workspace.
+// Sets compare keys, not elements: equality function of the builder is not used
+func SetBuilder[T any](workspace *Workspace, codec Codec[T], key func(T) string) graphs.AbstractSetBuilder[T] {
+	return func(graphs.SetEqualsFunction[T]) (graphs.AbstractSet[T], error) {
+		return NewSet(workspace, codec, key)
+	}
+}
+
+// ToIterator returns an iterator over the elements, in keys order.
+// Changes after the call may be missed, and iterator fails if runs are merged meanwhile
+func (s *Set[T]) ToIterator() (graphs.GeneralIterator[T], error) {
+	if s == nil {
+		return graphs.EmptyIterator[T]{}, nil
+	}
+
+	return s.iterator(), nil
+}
+
+// IsEmpty returns true for nil or empty set
+func (s *Set[T]) IsEmpty() (bool, error) {
+	return s == nil || s.size == 0, nil
+}
+
+// Add adds an element if its key is not in the set
+func (s *Set[T]) Add(element T) error {
+	if s == nil {
+		return errors.New("nil set")
+	}
+
+	key, errKey := keyOf(s.key, element)
+	if errKey != nil {
+		return errKey
+	}
+
+	if has, err := s.lookup(key); err != nil || has {
+		return err
+	}
+
+	value, errValue := s.codec.Encode(element)
+	if errValue != nil {
+		return errValue
+	}
+
+	s.put(entry{key: key, value: value})
+	s.size++
+	s.peeked = nil
+	return s.spill()
+}
+
+// Has returns true if the key of element is in the set
+func (s *Set[T]) Has(element T) (bool, error) {
+	if s == nil {
+		return false, nil
+	}
+
+	key, errKey := keyOf(s.key, element)
+	if errKey != nil {
+		return false, errKey
+	}
+
+	return s.lookup(key)
+}
+
+// Remove removes the element with the same key, if any
+func (s *Set[T]) Remove(element T) error {
+	if s == nil {
+		return nil
+	}
+
+	key, errKey := keyOf(s.key, element)
+	if errKey != nil {
+		return errKey
+	}
+
+	if has, err := s.lookup(key); err != nil || !has {
+		return err
+	} else if len(s.runs) == 0 {
+		s.memorySize -= memorySize(s.memory[key])
+		delete(s.memory, key)
+	} else {
+		s.put(entry{key: key, removed: true})
+	}
+
+	s.size--
+	return s.spill()
+}
+
+// Peek returns an element of the set, the one with the smallest key since last Add
+func (s *Set[T]) Peek() (T, error) {
+	var empty T
+	if s == nil {
+		return empty, errors.New("nil set")
+	} else if s.size == 0 {
+		return empty, errors.New("empty set")
+	}
+
+	if s.peeked == nil || s.peeked.generation != s.generation {
+		s.peeked = s.iterator()
+	}
+
+	for {
+		// elements before the current one were removed
+		if s.peeked.started {
+			if has, err := s.lookup(s.peeked.currentKey); err != nil {
+				return empty, err
+			} else if has {
+				return s.peeked.Value()
+			}
+		}
+
+		if next, err := s.peeked.Next(); err != nil {
+			return empty, err
+		} else if !next {
+			return empty, errors.New("empty set")
+		}
+	}
+}
+
+// Size returns the number of elements
+func (s *Set[T]) Size() int64 {
+	if s == nil {
+		return 0
+	}
+
+	return s.size
+}
+
+// Close removes the files of the set, and empties it
+func (s *Set[T]) Close() error {
+	if s == nil {
+		return nil
+	}
+
+	var result error
+	for _, r := range s.runs {
+		result = errors.Join(result, s.workspace.remove(r.file))
+	}
+
+	s.runs, s.memory, s.memorySize, s.size, s.peeked = nil, make(map[string]entry), 0, 0, nil
+	s.generation++
+	return result
+}
+
+// put sets the entry of a key in memory
+func (s *Set[T]) put(e entry) {
+	if previous, found := s.memory[e.key]; found {
+		s.memorySize -= memorySize(previous)
+	}
+
+	s.memorySize += memorySize(e)
+	s.memory[e.key] = e
+}
+
+// memorySize returns the estimated size of an entry in memory
+func memorySize(e entry) int {
+	return len(e.key) + len(e.value) + memoryEntryOverhead
+}
+
+// lookup returns true if key is in the set: newest entry of the key is not removed
+func (s *Set[T]) lookup(key string) (bool, error) {
+	if current, found := s.memory[key]; found {
+		return !current.removed, nil
+	}
+
+	for index := len(s.runs) - 1; index >= 0; index-- {
+		if current, found, err := s.runs[index].find(key); err != nil {
+			return false, err
+		} else if found {
+			return !current.removed, nil
+		}
+	}
+
+	return false, nil
+}
+
+// sorted returns the memory entries, sorted by key
+func (s *Set[T]) sorted() []entry {
+	return slices.SortedFunc(maps.Values(s.memory), func(a, b entry) int {
+		if a.key < b.key {
+			return -1
+		} else if a.key > b.key {
+			return 1
+		}
+
+		return 0
+	})
+}
+
+// spill writes the memory segment as a run past the memory budget, and merges runs if there are too many
+func (s *Set[T]) spill() error {
+	if s.memorySize <= s.workspace.budget {
+		return nil
+	}
+
+	created, errRun := writeRun(s.workspace, func(yield func(entry) error) error {
+		for _, current := range s.sorted() {
+			if err := yield(current); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	if errRun != nil {
+		return errRun
+	}
+
+	s.runs = append(s.runs, created)
+	s.memory, s.memorySize = make(map[string]entry), 0
+	for from := s.mergeable(); from >= 0; from = s.mergeable() {
+		if err := s.compact(from, from+mergeFactor); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// tier returns the tier of a run, the logarithm of its size in base mergeFactor (a power of 2)
+func tier(r *run) int {
+	return bits.Len64(uint64(r.size)) / bits.TrailingZeros(mergeFactor)
+}
+
+// mergeable returns the first of the newest mergeFactor consecutive runs of the same tier, -1 if none
+func (s *Set[T]) mergeable() int {
+	for from := len(s.runs) - mergeFactor; from >= 0; from-- {
+		same := true
+		for _, r := range s.runs[from+1 : from+mergeFactor] {
+			same = same && tier(r) == tier(s.runs[from])
+		}
+
+		if same {
+			return from
+		}
+	}
+
+	return -1
+}
+
+// compact merges runs from start (included) to end (excluded) into one, in their place.
+// Removed keys hide older runs: they are not needed anymore if merge includes the oldest run
+func (s *Set[T]) compact(start, end int) error {
+	cursors := make([]cursor, 0, end-start)
+	for _, r := range s.runs[start:end] {
+		cursors = append(cursors, newRunCursor(r))
+	}
+
+	merged := newMerger(cursors)
+	created, errRun := writeRun(s.workspace, func(yield func(entry) error) error {
+		for {
+			current, has, err := merged.next()
+			if err != nil || !has {
+				return err
+			} else if current.removed && start == 0 {
+				continue
+			} else if err := yield(current); err != nil {
+				return err
+			}
+		}
+	})
+
+	if errRun != nil {
+		return errRun
+	}
+
+	var result error
+	for _, r := range s.runs[start:end] {
+		result = errors.Join(result, s.workspace.remove(r.file))
+	}
+
+	s.runs = slices.Replace(s.runs, start, end, created)
+	s.generation++
+	s.workspace.compacted()
+	return result
+}
+
+// iterator returns an iterator over the current runs and a copy of the memory segment
+func (s *Set[T]) iterator() *SetIterator[T] {
+	cursors := make([]cursor, 0, len(s.runs)+1)
+	for _, r := range s.runs {
+		cursors = append(cursors, newRunCursor(r))
+	}
+
+	cursors = append(cursors, &sliceCursor{entries: s.sorted()})
+	return &SetIterator[T]{set: s, merged: newMerger(cursors), generation: s.generation}
+}
+
+// SetIterator iterates over the elements of a set, in keys order
+type SetIterator[T any] struct {
+	// set to iterate over
+	set *Set[T]
+	// merged reads the entries
+	merged *merger
+	// generation is the generation of the set when created
+	generation int64
+	// current is the current element
+	current T
+	// currentKey is the key of the current element
+	currentKey string
+	// started is true when there is a current element
+	started bool
+}
+
+// Next moves to the next element, if any
+func (si *SetIterator[T]) Next() (bool, error) {
+	if si == nil {
+		return false, errors.New("nil iterator")
+	} else if si.set.generation != si.generation {
+		return false, errors.New("set changed during iteration")
+	}
+
+	for {
+		current, has, err := si.merged.next()
+		if err != nil || !has {
+			si.started = false
+			return false, err
+		} else if current.removed {
+			continue
+		}
+
+		value, errValue := si.set.codec.Decode(current.value)
+		if errValue != nil {
+			si.started = false
+			return false, errValue
+		}
+
+		si.current, si.currentKey, si.started = value, current.key, true
+		return true, nil
+	}
+}
+
+// Value returns the current element
+func (si *SetIterator[T]) Value() (T, error) {
+	var empty T
+	if si == nil {
+		return empty, errors.New("nil iterator")
+	} else if !si.started {
+		return empty, errors.New("no current value")
+	}
+
+	return si.current, nil
+}
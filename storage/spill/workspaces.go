@@ -0,0 +1,170 @@
+package spill
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/zefrenchwan/nodz.git/graphs"
+	"github.com/zefrenchwan/nodz.git/storage"
+)
+
+// Out of core structures keep a bounded part of their content in memory, and spill the rest to temporary files.
+// Files live in a workspace: a temporary directory, removed with all its files when workspace is closed.
+// Builders of a workspace are the AbstractSetBuilder and DynamicIteratorBuilder of algorithms (such as graphs.ConnectedComponentsSize),
+// so that they run on graphs whose visited nodes do not fit in memory.
+
+// DefaultMemoryBudget is the memory budget (in bytes) of a structure when not set
+const DefaultMemoryBudget = 32 << 20
+
+// Codec encodes a value to bytes, and decodes it back
+type Codec[T any] interface {
+	// Encode returns the bytes of a value
+	Encode(T) ([]byte, error)
+	// Decode returns the value from its bytes
+	Decode([]byte) (T, error)
+}
+
+// NodeCodec adapts a node codec to a codec of values
+type NodeCodec[N graphs.Node] struct {
+	// Codec is the node codec
+	Codec storage.NodeCodec[N]
+}
+
+// Encode returns the payload of a node
+func (nc NodeCodec[N]) Encode(node N) ([]byte, error) {
+	return nc.Codec.EncodeNode(node)
+}
+
+// Decode returns the node from its payload
+func (nc NodeCodec[N]) Decode(payload []byte) (N, error) {
+	return nc.Codec.DecodeNode(payload)
+}
+
+// Options are the settings of a workspace
+type Options struct {
+	// Directory is the parent of the temporary directory, default directory for temporary files if empty
+	Directory string
+	// MemoryBudget is the size (in bytes) of the content a structure keeps in memory, DefaultMemoryBudget if not positive
+	MemoryBudget int
+}
+
+// Stats are the counters of a workspace, for all its structures
+type Stats struct {
+	// Spills is the number of times a structure wrote its memory content to a file
+	Spills int64
+	// SpilledBytes is the size of spilled content
+	SpilledBytes int64
+	// Compactions is the number of merges of the files of a set
+	Compactions int64
+}
+
+// Workspace is a temporary directory for the files of out of core structures.
+// A workspace may be shared by goroutines, but each structure should be used by one goroutine at a time
+type Workspace struct {
+	// lock protects counters and files
+	lock sync.Mutex
+	// directory is the temporary directory
+	directory string
+	// budget is the memory budget of each structure
+	budget int
+	// files is the number of created files, to name them
+	files int64
+	// stats are the counters so far
+	stats Stats
+	// closed is true once closed
+	closed bool
+}
+
+// NewWorkspace creates a temporary directory for out of core structures
+func NewWorkspace(options Options) (*Workspace, error) {
+	directory, err := os.MkdirTemp(options.Directory, "nodz-spill-")
+	if err != nil {
+		return nil, err
+	}
+
+	budget := options.MemoryBudget
+	if budget <= 0 {
+		budget = DefaultMemoryBudget
+	}
+
+	return &Workspace{directory: directory, budget: budget}, nil
+}
+
+// Stats returns the counters so far
+func (w *Workspace) Stats() Stats {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.stats
+}
+
+// Close removes the directory and all files of the structures. Structures are then unusable
+func (w *Workspace) Close() error {
+	if w == nil {
+		return nil
+	}
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.closed {
+		return nil
+	}
+
+	w.closed = true
+	return os.RemoveAll(w.directory)
+}
+
+// create creates a new file in the directory
+func (w *Workspace) create(kind string) (*os.File, error) {
+	if w == nil {
+		return nil, errors.New("nil workspace")
+	}
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.closed {
+		return nil, errors.New("closed workspace")
+	}
+
+	w.files++
+	return os.OpenFile(filepath.Join(w.directory, fmt.Sprintf("%s-%d", kind, w.files)), os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o600)
+}
+
+// remove closes and removes a file of the directory, if still there
+func (w *Workspace) remove(file *os.File) error {
+	errClose := file.Close()
+	if err := os.Remove(file.Name()); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return errors.Join(errClose, err)
+	}
+
+	return errClose
+}
+
+// spilled counts a spill of size bytes
+func (w *Workspace) spilled(size int64) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.stats.Spills++
+	w.stats.SpilledBytes += size
+}
+
+// compacted counts a compaction
+func (w *Workspace) compacted() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.stats.Compactions++
+}
+
+// keyOf returns the key of a value: key function if any, id if value implements WithId
+func keyOf[T any](key func(T) string, value T) (string, error) {
+	if key != nil {
+		return key(value), nil
+	} else if withId, ok := any(value).(graphs.WithId); ok {
+		return withId.Id(), nil
+	}
+
+	return "", errors.New("value has no id, and no key function")
+}
@@ -0,0 +1,461 @@
+package wal
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/zefrenchwan/nodz.git/graphs"
+	"github.com/zefrenchwan/nodz.git/storage"
+	"github.com/zefrenchwan/nodz.git/storage/eventlog"
+)
+
+// A write-ahead log is a binary event log (see eventlog), with the changes since the last checkpoint of a store.
+// Each change is appended and synced before it is applied to the store.
+// At checkpoint, store writes its changes with the offset of the last record, and the log is emptied.
+// On opening, records after the checkpoint are applied again, and a torn last record is truncated.
+
+// DefaultCheckpointEvery is the number of records between checkpoints when not set
+const DefaultCheckpointEvery = 1000
+
+// Store is a persistent graph with checkpoints, such as disk.PagedGraph
+type Store[N graphs.Node, L graphs.Link[N]] interface {
+	graphs.CentralStructureGraph[N, L]
+	// Checkpoint makes all changes durable, as the changes up to offset in the log
+	Checkpoint(offset uint64) error
+	// CheckpointOffset returns the offset of the last checkpoint, 0 if none
+	CheckpointOffset() uint64
+	// Close makes all changes durable and closes the store
+	Close() error
+	// Discard closes the store without writing changes since last checkpoint
+	Discard() error
+	// ValidateNode returns an error if store would refuse to add node
+	ValidateNode(node N) error
+	// ValidateLink returns an error if store would refuse to add link
+	ValidateLink(link L) error
+}
+
+// Options are the settings of a durable graph
+type Options struct {
+	// CheckpointEvery is the number of records between checkpoints.
+	// DefaultCheckpointEvery if 0, negative for explicit checkpoints only
+	CheckpointEvery int
+	// FileSystem opens the log, the operating system file system if nil
+	FileSystem storage.FileSystem
+	// Clock returns the timestamp of records, time.Now if nil
+	Clock func() time.Time
+}
+
+// DurableGraph decorates a store: changes are in the log before they are applied, so they survive a crash.
+// A change is committed once its method returns without error.
+// Added nodes and links are validated by the store first, so that a refused one never reaches the log.
+// Once a write fails, graph refuses changes: close it, and open it again to recover.
+// Logged changes are structural (nodes and links), properties of stored nodes and links are their payloads.
+type DurableGraph[N graphs.Node, L graphs.Link[N]] struct {
+	// lock serializes changes
+	lock sync.Mutex
+	// store is the decorated store
+	store Store[N, L]
+	// file is the log
+	file storage.File
+	// nodeCodec encodes nodes in records
+	nodeCodec storage.NodeCodec[N]
+	// linkCodec encodes links in records
+	linkCodec storage.LinkCodec[N, L]
+	// clock returns timestamps
+	clock func() time.Time
+	// checkpointEvery is the number of records between checkpoints, no automatic checkpoint if not positive
+	checkpointEvery int
+	// offset is the offset of the last record
+	offset uint64
+	// size is the size of the log
+	size int64
+	// pending is the number of records since the last checkpoint
+	pending int
+	// failure is the first failed write, if any
+	failure error
+	// closed is true once closed
+	closed bool
+}
+
+// Open opens the log at path (created if needed), and recovers store: records after its last checkpoint are applied.
+// Store should not be changed but through the result
+func Open[N graphs.Node, L graphs.Link[N]](
+	path string, // path of the log
+	store Store[N, L], // store to decorate
+	nodeCodec storage.NodeCodec[N], // codec of nodes
+	linkCodec storage.LinkCodec[N, L], // codec of links
+	options Options, // settings, zero value for default ones
+) (*DurableGraph[N, L], error) {
+	if store == nil {
+		return nil, errors.New("nil store")
+	} else if nodeCodec == nil || linkCodec == nil {
+		return nil, errors.New("nil codec")
+	}
+
+	files := options.FileSystem
+	if files == nil {
+		files = storage.OSFileSystem{}
+	}
+
+	file, errFile := files.OpenFile(path)
+	if errFile != nil {
+		return nil, errFile
+	}
+
+	result := &DurableGraph[N, L]{
+		store:           store,
+		file:            file,
+		nodeCodec:       nodeCodec,
+		linkCodec:       linkCodec,
+		clock:           options.Clock,
+		checkpointEvery: options.CheckpointEvery,
+		offset:          store.CheckpointOffset(),
+	}
+
+	if result.clock == nil {
+		result.clock = time.Now
+	}
+
+	if result.checkpointEvery == 0 {
+		result.checkpointEvery = DefaultCheckpointEvery
+	}
+
+	if err := result.recover(); err != nil {
+		return nil, errors.Join(err, file.Close())
+	}
+
+	return result, nil
+}
+
+// recover applies records after checkpoint, truncates a torn last record, and starts a new log after a checkpoint
+func (dg *DurableGraph[N, L]) recover() error {
+	size, errSize := dg.file.Size()
+	if errSize != nil {
+		return errSize
+	}
+
+	header := eventlog.AppendBinaryHeader(nil)
+	headerSize := int64(len(header))
+	if size == headerSize {
+		// log is empty, checkpoint is up to date
+		dg.size = size
+		return nil
+	} else if size > headerSize {
+		content := make([]byte, headerSize)
+		if _, err := dg.file.ReadAt(content, 0); err != nil {
+			return err
+		} else if string(content) != string(header) {
+			return errors.New("not a write-ahead log")
+		}
+
+		valid, errReplay := dg.replay(io.NewSectionReader(dg.file, headerSize, size-headerSize))
+		if errReplay != nil {
+			return errReplay
+		} else if headerSize+valid < size {
+			// torn last record
+			if err := dg.file.Truncate(headerSize + valid); err != nil {
+				return err
+			} else if err := dg.file.Sync(); err != nil {
+				return err
+			}
+		}
+	}
+
+	// a shorter log is new, or its header is torn: it has no record
+	return dg.checkpoint()
+}
+
+// replay applies the records after checkpoint, and returns the size of valid records
+func (dg *DurableGraph[N, L]) replay(reader io.Reader) (int64, error) {
+	counter := &countingReader{reader: reader}
+	buffered := bufio.NewReader(counter)
+	checkpoint, previous := dg.offset, uint64(0)
+	for {
+		position := counter.count - int64(buffered.Buffered())
+		record, errRecord := eventlog.ReadBinaryRecord(buffered)
+		if errors.Is(errRecord, io.EOF) || errors.Is(errRecord, io.ErrUnexpectedEOF) {
+			// end of log, or partial last record (torn)
+			return position, nil
+		} else if errRecord != nil {
+			// records are synced one at a time, so only the last one may be invalid (torn)
+			if _, errNext := buffered.Peek(1); errors.Is(errNext, io.EOF) {
+				return position, nil
+			} else if errNext != nil {
+				return 0, errNext
+			}
+
+			return 0, fmt.Errorf("corrupted log record after offset %d: %w", previous, errRecord)
+		} else if previous != 0 && record.Offset != previous+1 {
+			return 0, fmt.Errorf("offset %d follows offset %d in log", record.Offset, previous)
+		}
+
+		previous = record.Offset
+		if record.Offset <= checkpoint {
+			continue
+		} else if record.Offset != dg.offset+1 {
+			return 0, fmt.Errorf("missing records between offsets %d and %d", dg.offset, record.Offset)
+		} else if err := dg.apply(record); err != nil {
+			return 0, fmt.Errorf("offset %d: %w", record.Offset, err)
+		}
+
+		dg.offset = record.Offset
+	}
+}
+
+// countingReader counts bytes read
+type countingReader struct {
+	// reader is the source
+	reader io.Reader
+	// count is the number of bytes read so far
+	count int64
+}
+
+// Read reads from source and counts bytes
+func (cr *countingReader) Read(content []byte) (int, error) {
+	read, err := cr.reader.Read(content)
+	cr.count += int64(read)
+	return read, err
+}
+
+// apply changes the store for a record
+func (dg *DurableGraph[N, L]) apply(record eventlog.Record) error {
+	switch record.Operation {
+	case eventlog.AddNode, eventlog.RemoveNode:
+		node, errNode := dg.nodeCodec.DecodeNode(record.Node)
+		if errNode != nil {
+			return errNode
+		} else if record.Operation != eventlog.AddNode {
+			return dg.store.RemoveNode(node)
+		} else if dg.store.ValidateNode(node) != nil {
+			// older logs may have records the store refuses: they failed, and were never applied
+			return nil
+		}
+
+		return dg.store.AddNode(node)
+	case eventlog.AddLink, eventlog.RemoveLink:
+		source, errSource := dg.nodeCodec.DecodeNode(record.Source)
+		if errSource != nil {
+			return errSource
+		}
+
+		destination, errDestination := dg.nodeCodec.DecodeNode(record.Destination)
+		if errDestination != nil {
+			return errDestination
+		}
+
+		link, errLink := dg.linkCodec.DecodeLink(source, destination, record.Link)
+		if errLink != nil {
+			return errLink
+		} else if record.Operation != eventlog.AddLink {
+			return dg.store.RemoveLink(link)
+		} else if dg.store.ValidateLink(link) != nil {
+			// older logs may have records the store refuses: they failed, and were never applied
+			return nil
+		}
+
+		return dg.store.AddLink(link)
+	default:
+		return fmt.Errorf("unsupported operation %q", record.Operation)
+	}
+}
+
+// Offset returns the offset of the last committed change
+func (dg *DurableGraph[N, L]) Offset() uint64 {
+	dg.lock.Lock()
+	defer dg.lock.Unlock()
+	return dg.offset
+}
+
+// Neighbors returns the neighborhood of a node in the store
+func (dg *DurableGraph[N, L]) Neighbors(node N) (graphs.Neighborhood[N, L], error) {
+	return dg.store.Neighbors(node)
+}
+
+// AllNodes returns the nodes of the store
+func (dg *DurableGraph[N, L]) AllNodes() (graphs.NodesIterator[N], error) {
+	return dg.store.AllNodes()
+}
+
+// AddNode logs and adds a node, once validated by the store
+func (dg *DurableGraph[N, L]) AddNode(node N) error {
+	if err := dg.store.ValidateNode(node); err != nil {
+		return err
+	}
+
+	payload, errPayload := dg.nodeCodec.EncodeNode(node)
+	if errPayload != nil {
+		return errPayload
+	}
+
+	return dg.change(eventlog.Record{Operation: eventlog.AddNode, Node: payload}, func() error {
+		return dg.store.AddNode(node)
+	})
+}
+
+// RemoveNode logs and removes a node and its links
+func (dg *DurableGraph[N, L]) RemoveNode(node N) error {
+	payload, errPayload := dg.nodeCodec.EncodeNode(node)
+	if errPayload != nil {
+		return errPayload
+	}
+
+	return dg.change(eventlog.Record{Operation: eventlog.RemoveNode, Node: payload}, func() error {
+		return dg.store.RemoveNode(node)
+	})
+}
+
+// AddLink logs and adds a link, once validated by the store
+func (dg *DurableGraph[N, L]) AddLink(link L) error {
+	if err := dg.store.ValidateLink(link); err != nil {
+		return err
+	}
+
+	record, errRecord := dg.linkRecord(eventlog.AddLink, link)
+	if errRecord != nil {
+		return errRecord
+	}
+
+	return dg.change(record, func() error {
+		return dg.store.AddLink(link)
+	})
+}
+
+// RemoveLink logs and removes a link
+func (dg *DurableGraph[N, L]) RemoveLink(link L) error {
+	record, errRecord := dg.linkRecord(eventlog.RemoveLink, link)
+	if errRecord != nil {
+		return errRecord
+	}
+
+	return dg.change(record, func() error {
+		return dg.store.RemoveLink(link)
+	})
+}
+
+// Checkpoint makes all changes durable in the store, and empties the log
+func (dg *DurableGraph[N, L]) Checkpoint() error {
+	dg.lock.Lock()
+	defer dg.lock.Unlock()
+	if err := dg.usable(); err != nil {
+		return err
+	} else if err := dg.checkpoint(); err != nil {
+		dg.failure = err
+		return err
+	}
+
+	return nil
+}
+
+// Close makes a checkpoint and closes log and store.
+// After a failure, store is discarded instead: changes are recovered from the log at next opening
+func (dg *DurableGraph[N, L]) Close() error {
+	dg.lock.Lock()
+	defer dg.lock.Unlock()
+	if dg.closed {
+		return nil
+	}
+
+	dg.closed = true
+	if dg.failure != nil {
+		return errors.Join(dg.store.Discard(), dg.file.Close())
+	} else if err := dg.checkpoint(); err != nil {
+		return errors.Join(err, dg.store.Discard(), dg.file.Close())
+	}
+
+	return errors.Join(dg.store.Close(), dg.file.Close())
+}
+
+// linkRecord returns the record of a link operation
+func (dg *DurableGraph[N, L]) linkRecord(operation eventlog.Operation, link L) (eventlog.Record, error) {
+	record := eventlog.Record{Operation: operation}
+	if source, err := dg.nodeCodec.EncodeNode(link.Source()); err != nil {
+		return record, err
+	} else {
+		record.Source = source
+	}
+
+	if destination, err := dg.nodeCodec.EncodeNode(link.Destination()); err != nil {
+		return record, err
+	} else {
+		record.Destination = destination
+	}
+
+	if payload, err := dg.linkCodec.EncodeLink(link); err != nil {
+		return record, err
+	} else {
+		record.Link = payload
+	}
+
+	return record, nil
+}
+
+// change logs a record, and then applies the change. A checkpoint follows every checkpointEvery records
+func (dg *DurableGraph[N, L]) change(record eventlog.Record, apply func() error) error {
+	dg.lock.Lock()
+	defer dg.lock.Unlock()
+	if err := dg.usable(); err != nil {
+		return err
+	}
+
+	record.Offset = dg.offset + 1
+	record.Timestamp = dg.clock()
+	content := eventlog.AppendBinaryRecord(nil, record)
+	if _, err := dg.file.WriteAt(content, dg.size); err != nil {
+		dg.failure = err
+		return err
+	} else if err := dg.file.Sync(); err != nil {
+		dg.failure = err
+		return err
+	}
+
+	// change is committed: from here, it is recovered after a failure
+	dg.size += int64(len(content))
+	dg.offset = record.Offset
+	dg.pending++
+	if err := apply(); err != nil {
+		dg.failure = err
+		return err
+	} else if dg.checkpointEvery <= 0 || dg.pending < dg.checkpointEvery {
+		return nil
+	} else if err := dg.checkpoint(); err != nil {
+		dg.failure = err
+		return err
+	}
+
+	return nil
+}
+
+// checkpoint makes the store durable up to the last record, and starts a new log
+func (dg *DurableGraph[N, L]) checkpoint() error {
+	if err := dg.store.Checkpoint(dg.offset); err != nil {
+		return err
+	}
+
+	header := eventlog.AppendBinaryHeader(nil)
+	if err := dg.file.Truncate(0); err != nil {
+		return err
+	} else if _, err := dg.file.WriteAt(header, 0); err != nil {
+		return err
+	} else if err := dg.file.Sync(); err != nil {
+		return err
+	}
+
+	dg.size = int64(len(header))
+	dg.pending = 0
+	return nil
+}
+
+// usable returns an error if graph is closed or failed
+func (dg *DurableGraph[N, L]) usable() error {
+	if dg.closed {
+		return errors.New("closed graph")
+	} else if dg.failure != nil {
+		return fmt.Errorf("graph failed, reopen it to recover: %w", dg.failure)
+	}
+
+	return nil
+}
@@ -0,0 +1,310 @@
+package disk_test
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/zefrenchwan/nodz.git/graphs"
+	"github.com/zefrenchwan/nodz.git/internal"
+	"github.com/zefrenchwan/nodz.git/internal/local"
+	"github.com/zefrenchwan/nodz.git/storage"
+	"github.com/zefrenchwan/nodz.git/storage/disk"
+)
+
+type node = internal.IdNode
+type link = internal.ValuedLink[node, float64]
+
+// open opens a paged graph of valued links in a test directory
+func open(t *testing.T, path string, cachePages int) *disk.PagedGraph[node, link] {
+	t.Helper()
+	graph, err := disk.Open(path, storage.IdNodeCodec{}, storage.ValuedLinkCodec[node, float64]{}, disk.Options[node]{CachePages: cachePages})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return graph
+}
+
+// describe returns the links of a neighborhood as sorted strings, undirected links with sorted extremities
+func describe(t *testing.T, neighborhood graphs.Neighborhood[node, link]) string {
+	t.Helper()
+	links, errLinks := neighborhood.Links()
+	if errLinks != nil {
+		t.Fatal(errLinks)
+	}
+
+	result := make([]string, 0)
+	for has, err := links.Next(); has || err != nil; has, err = links.Next() {
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		l, _ := links.Value()
+		source, destination := l.Source().Id(), l.Destination().Id()
+		if !l.IsDirected() && source > destination {
+			source, destination = destination, source
+		}
+
+		result = append(result, fmt.Sprintf("%s>%s:%t:%v", source, destination, l.IsDirected(), l.Value()))
+	}
+
+	slices.Sort(result)
+	return fmt.Sprintf("in=%d out=%d und=%d %s", neighborhood.IncomingDegree(), neighborhood.OutgoingDegree(), neighborhood.UndirectedDegree(), strings.Join(result, ","))
+}
+
+// compare checks that both graphs have the same nodes and neighborhoods
+func compare(t *testing.T, expected *local.MapGraph[node, link], graph graphs.CentralStructureGraph[node, link]) {
+	t.Helper()
+	nodes, _ := graph.AllNodes()
+	count := 0
+	for has, err := nodes.Next(); has || err != nil; has, err = nodes.Next() {
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		count++
+		n, _ := nodes.Value()
+		if neighbors, _ := expected.Neighbors(n); neighbors == nil {
+			t.Fatalf("unexpected node %s", n.Id())
+		}
+	}
+
+	for n := range expected.All() {
+		count--
+		reference, _ := expected.Neighbors(n)
+		neighbors, err := graph.Neighbors(n)
+		if err != nil {
+			t.Fatal(err)
+		} else if neighbors == nil {
+			t.Fatalf("missing node %s", n.Id())
+		} else if want, got := describe(t, reference), describe(t, neighbors); want != got {
+			t.Fatalf("node %s: expected %s, got %s", n.Id(), want, got)
+		}
+	}
+
+	if count != 0 {
+		t.Fatalf("nodes count differs by %d", count)
+	}
+}
+
+func TestPagedGraphMatchesMapGraph(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "graph.nodz")
+	// a small cache forces evictions
+	graph := open(t, path, 8)
+	expected := local.NewMapGraph[node, link]()
+	random := rand.New(rand.NewSource(42))
+	nodeOf := func() node { return internal.NewIdNode(fmt.Sprintf("n%d", random.Intn(300))) }
+	for range 6000 {
+		a, b := nodeOf(), nodeOf()
+		value := float64(random.Intn(3))
+		var l link
+		if random.Intn(2) == 0 {
+			l = internal.NewDirectedValuedLink(a, b, value)
+		} else {
+			l = internal.NewUndirectedValuedLink(a, b, value)
+		}
+
+		var errGraph, errExpected error
+		switch operation := random.Intn(20); {
+		case operation < 13:
+			errGraph, errExpected = graph.AddLink(l), expected.AddLink(l)
+		case operation < 18:
+			errGraph, errExpected = graph.RemoveLink(l), expected.RemoveLink(l)
+		case operation < 19:
+			errGraph, errExpected = graph.RemoveNode(a), expected.RemoveNode(a)
+		default:
+			errGraph, errExpected = graph.AddNode(a), expected.AddNode(a)
+		}
+
+		if errGraph != nil || errExpected != nil {
+			t.Fatal(errGraph, errExpected)
+		}
+	}
+
+	compare(t, &expected, graph)
+	if found, err := graph.HasLink(internal.NewDirectedValuedLink(internal.NewIdNode("none"), internal.NewIdNode("n1"), 0.0)); err != nil || found {
+		t.Errorf("unexpected link: %v", err)
+	} else if stats := graph.CacheStats(); stats.Evictions == 0 || stats.Misses == 0 {
+		t.Errorf("small cache should evict pages, got %v", stats)
+	}
+
+	nodesCount, linksCount := graph.NodesCount(), graph.LinksCount()
+	if err := graph.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened := open(t, path, 0)
+	defer reopened.Close()
+	compare(t, &expected, reopened)
+	if reopened.NodesCount() != nodesCount || reopened.LinksCount() != linksCount {
+		t.Errorf("counts changed after reopening")
+	}
+
+	count := int64(0)
+	for range expected.Links() {
+		count++
+	}
+
+	if count != linksCount {
+		t.Errorf("expected %d links, got %d", count, linksCount)
+	}
+}
+
+func TestPagedGraphLoopsAndDuplicates(t *testing.T) {
+	graph := open(t, filepath.Join(t.TempDir(), "graph.nodz"), 0)
+	defer graph.Close()
+	a, b := internal.NewIdNode("a"), internal.NewIdNode("b")
+	for range 2 {
+		graph.AddLink(internal.NewDirectedValuedLink(a, a, 1.0))
+		graph.AddLink(internal.NewUndirectedValuedLink(b, b, 1.0))
+		graph.AddLink(internal.NewUndirectedValuedLink(a, b, 1.0))
+		graph.AddLink(internal.NewUndirectedValuedLink(b, a, 1.0))
+	}
+
+	if graph.LinksCount() != 3 || graph.NodesCount() != 2 {
+		t.Fatalf("unexpected counts %d %d", graph.NodesCount(), graph.LinksCount())
+	}
+
+	neighbors, _ := graph.Neighbors(a)
+	if description := describe(t, neighbors); description != "in=1 out=1 und=1 a>a:true:1,a>b:false:1" {
+		t.Errorf("unexpected neighborhood %s", description)
+	}
+
+	graph.RemoveLink(internal.NewUndirectedValuedLink(b, a, 1.0))
+	graph.RemoveNode(a)
+	if neighbors, _ := graph.Neighbors(a); neighbors != nil {
+		t.Error("removed node should have no neighborhood")
+	} else if neighbors, _ := graph.Neighbors(b); describe(t, neighbors) != "in=0 out=0 und=1 b>b:false:1" {
+		t.Errorf("unexpected neighborhood %s", describe(t, neighbors))
+	} else if graph.LinksCount() != 1 {
+		t.Errorf("unexpected links count %d", graph.LinksCount())
+	}
+}
+
+func TestPagedGraphAlgorithms(t *testing.T) {
+	graph := open(t, filepath.Join(t.TempDir(), "graph.nodz"), 16)
+	defer graph.Close()
+	expected := local.NewMapGraph[node, link]()
+	for index := range 2000 {
+		// components of 10 nodes, as paths
+		if index%10 == 0 {
+			continue
+		}
+
+		l := internal.NewUndirectedValuedLink(internal.NewIdNode(fmt.Sprint(index-1)), internal.NewIdNode(fmt.Sprint(index)), 1.0)
+		graph.AddLink(l)
+		expected.AddLink(l)
+	}
+
+	stats, err := graphs.ParallelConnectedComponentsSize(graph, graphs.ParallelOptions[node]{Workers: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reference, _ := graphs.ParallelConnectedComponentsSize(&expected, graphs.ParallelOptions[node]{Workers: 4})
+	if len(stats) != 200 || len(stats) != len(reference) {
+		t.Fatalf("unexpected components %v", stats)
+	}
+
+	for component, size := range stats {
+		if size != 10 || reference[component] != size {
+			t.Errorf("component %d: unexpected size %d", component, size)
+		}
+	}
+}
+
+func TestPagedGraphLargePayloads(t *testing.T) {
+	type propertiesLink = *internal.TypePropertiesLink[*internal.PropertiesNode]
+	path := filepath.Join(t.TempDir(), "graph.nodz")
+	linkCodec := storage.TypePropertiesLinkCodec[*internal.PropertiesNode]{}
+	graph, errOpen := disk.Open[*internal.PropertiesNode, propertiesLink](path, storage.PropertiesNodeCodec{}, linkCodec, disk.Options[*internal.PropertiesNode]{CachePages: 4})
+	if errOpen != nil {
+		t.Fatal(errOpen)
+	}
+
+	// payloads larger than a page go to overflow pages
+	large := strings.Repeat("x", 10000)
+	hub := internal.NewPropertiesNodeWithId("hub")
+	hub.SetProperty("content", large)
+	for index := range 50 {
+		other := internal.NewPropertiesNodeWithId(fmt.Sprint(index))
+		l := internal.NewTypePropertiesLink("knows", &hub, &other)
+		l.SetProperty("content", large)
+		if err := graph.AddLink(&l); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	removed := internal.NewPropertiesNodeWithId("0")
+	if err := graph.RemoveNode(&removed); err != nil {
+		t.Fatal(err)
+	} else if err := graph.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, errReopen := disk.Open[*internal.PropertiesNode, propertiesLink](path, storage.PropertiesNodeCodec{}, linkCodec, disk.Options[*internal.PropertiesNode]{})
+	if errReopen != nil {
+		t.Fatal(errReopen)
+	}
+
+	defer reopened.Close()
+	neighbors, _ := reopened.Neighbors(&hub)
+	if neighbors == nil || neighbors.OutgoingDegree() != 49 {
+		t.Fatalf("unexpected neighborhood %v", neighbors)
+	}
+
+	links, _ := neighbors.Links()
+	for has, err := links.Next(); has || err != nil; has, err = links.Next() {
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		l, _ := links.Value()
+		if value, _ := l.GetProperty("content"); value != large {
+			t.Fatal("truncated link payload")
+		} else if value, _ := l.Source().GetProperty("content"); value != large {
+			t.Fatal("truncated node payload")
+		}
+	}
+}
+
+func TestPagedGraphInvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "graph.nodz")
+	if err := os.WriteFile(path, []byte(strings.Repeat("not a graph", 1000)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := disk.Open(path, storage.IdNodeCodec{}, storage.ValuedLinkCodec[node, float64]{}, disk.Options[node]{}); err == nil {
+		t.Error("expected an error for an invalid file")
+	}
+}
+
+func TestPagedGraphRefusesLargeKeys(t *testing.T) {
+	graph := open(t, filepath.Join(t.TempDir(), "graph.nodz"), 4)
+	defer graph.Close()
+
+	a := internal.NewIdNode("a")
+	large := internal.NewIdNode(strings.Repeat("x", 2000))
+	if err := graph.AddNode(large); err == nil {
+		t.Fatal("key larger than the index limit should be refused")
+	} else if err := graph.AddLink(internal.NewDirectedValuedLink(a, large, 1.0)); err == nil {
+		t.Fatal("link to a refused node should be refused")
+	} else if err := graph.AddLink(internal.NewDirectedValuedLink(large, a, 1.0)); err == nil {
+		t.Fatal("link from a refused node should be refused")
+	}
+
+	// refused changes leave nothing behind
+	if count := graph.NodesCount(); count != 0 {
+		t.Errorf("expected no node, got %d", count)
+	}
+
+	nodes, _ := graph.AllNodes()
+	if has, err := nodes.Next(); err != nil || has {
+		t.Errorf("refused nodes should not be iterated, got %t, %v", has, err)
+	}
+}
@@ -0,0 +1,227 @@
+package edgelist_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"strings"
+	"testing"
+
+	"github.com/zefrenchwan/nodz.git/graphs"
+	"github.com/zefrenchwan/nodz.git/internal"
+	"github.com/zefrenchwan/nodz.git/internal/local"
+	"github.com/zefrenchwan/nodz.git/storage"
+	"github.com/zefrenchwan/nodz.git/storage/edgelist"
+)
+
+type testLink = internal.ValuedLink[internal.IdNode, float64]
+
+func nodeFactory(id string) (internal.IdNode, error) {
+	return internal.NewIdNode(id), nil
+}
+
+func directedFactory(source, destination internal.IdNode, weight float64) (testLink, error) {
+	return internal.NewDirectedValuedLink(source, destination, weight), nil
+}
+
+func undirectedFactory(source, destination internal.IdNode, weight float64) (testLink, error) {
+	return internal.NewUndirectedValuedLink(source, destination, weight), nil
+}
+
+func TestReadSNAPEdgeList(t *testing.T) {
+	content := `# Directed graph (each unordered pair of nodes is saved once)
+# FromNodeId	ToNodeId
+0	1
+0	2
+% konect comment
+1	2
+
+2	0
+`
+	graph := local.NewMapGraph[internal.IdNode, testLink]()
+	err := edgelist.ReadEdgeList(strings.NewReader(content), &graph, nodeFactory, directedFactory, edgelist.SNAPOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats, errStats := graphs.CalculateNetworkStatistics(&graph, func(n graphs.Neighborhood[internal.IdNode, testLink]) int64 {
+		return n.OutgoingDegree()
+	})
+
+	if errStats != nil {
+		t.Fail()
+	} else if stats.NodesSize != 3 {
+		t.Errorf("expected 3 nodes, got %d", stats.NodesSize)
+	} else if stats.DirectedSize != 4 {
+		t.Errorf("expected 4 links, got %d", stats.DirectedSize)
+	}
+}
+
+func TestReadGzipWeightedCSV(t *testing.T) {
+	var compressed bytes.Buffer
+	zipper := gzip.NewWriter(&compressed)
+	zipper.Write([]byte("source;target;weight\na;b;2.5\nb;c;0.5\n"))
+	zipper.Close()
+
+	graph := local.NewMapGraph[internal.IdNode, testLink]()
+	options := edgelist.CSVOptions(';', true)
+	if err := edgelist.ReadEdgeList(&compressed, &graph, nodeFactory, undirectedFactory, options); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := internal.NewUndirectedValuedLink(internal.NewIdNode("b"), internal.NewIdNode("a"), 2.5)
+	if !graph.HasLink(expected) {
+		t.Error("expected weighted link a - b")
+	}
+}
+
+func TestReadEdgeListErrors(t *testing.T) {
+	graph := local.NewMapGraph[internal.IdNode, testLink]()
+	err := edgelist.ReadEdgeList(strings.NewReader("a b\nc\n"), &graph, nodeFactory, directedFactory, edgelist.SNAPOptions())
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("expected error on line 2, got %v", err)
+	}
+
+	err = edgelist.ReadEdgeList(strings.NewReader("a b x\n"), &graph, nodeFactory, directedFactory, edgelist.Options{Weighted: true})
+	if err == nil {
+		t.Error("invalid weight should raise an error")
+	}
+}
+
+func TestEdgeListRoundTrip(t *testing.T) {
+	graph := local.NewMapGraph[internal.IdNode, testLink]()
+	graph.AddLink(internal.NewUndirectedValuedLink(internal.NewIdNode("a"), internal.NewIdNode("b"), 3.0))
+	graph.AddLink(internal.NewUndirectedValuedLink(internal.NewIdNode("b"), internal.NewIdNode("c"), 4.0))
+
+	var buffer bytes.Buffer
+	weigher := func(l testLink) float64 { return l.Value() }
+	options := edgelist.CSVOptions(',', true)
+	if err := edgelist.WriteEdgeList(&buffer, &graph, nil, weigher, options); err != nil {
+		t.Fatal(err)
+	}
+
+	// undirected links appear once
+	if lines := strings.Split(strings.TrimSpace(buffer.String()), "\n"); len(lines) != 3 {
+		t.Errorf("expected header and two links, got %v", lines)
+	}
+
+	copyGraph := local.NewMapGraph[internal.IdNode, testLink]()
+	if err := edgelist.ReadEdgeList(&buffer, &copyGraph, nodeFactory, undirectedFactory, options); err != nil {
+		t.Fatal(err)
+	}
+
+	if !copyGraph.HasLink(internal.NewUndirectedValuedLink(internal.NewIdNode("c"), internal.NewIdNode("b"), 4.0)) {
+		t.Error("missing link after round trip")
+	}
+}
+
+func TestAdjacencyListRoundTrip(t *testing.T) {
+	graph := local.NewMapGraph[internal.IdNode, testLink]()
+	graph.AddNode(internal.NewIdNode("isolated"))
+	graph.AddLink(internal.NewDirectedValuedLink(internal.NewIdNode("a"), internal.NewIdNode("b"), 1.0))
+	graph.AddLink(internal.NewDirectedValuedLink(internal.NewIdNode("a"), internal.NewIdNode("c"), 1.0))
+
+	var buffer bytes.Buffer
+	if err := edgelist.WriteAdjacencyList(&buffer, &graph, nil, edgelist.SNAPOptions()); err != nil {
+		t.Fatal(err)
+	}
+
+	copyGraph := local.NewMapGraph[internal.IdNode, testLink]()
+	if err := edgelist.ReadAdjacencyList(&buffer, &copyGraph, nodeFactory, directedFactory, edgelist.SNAPOptions()); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := copyGraph.Neighbors(internal.NewIdNode("isolated")); err != nil || n == nil {
+		t.Error("isolated node should be kept")
+	} else if n, err := copyGraph.Neighbors(internal.NewIdNode("a")); err != nil || n.OutgoingDegree() != 2 {
+		t.Error("a should have two outgoing links")
+	}
+}
+
+func TestCSVQuotedValues(t *testing.T) {
+	content := "source,target,weight\n\"Doe, Jane\",\"Smith, \"\"Bob\"\"\",2\n# comment, with \"quotes\"\nb,c,1\n"
+	graph := local.NewMapGraph[internal.IdNode, testLink]()
+	options := edgelist.CSVOptions(',', true)
+	if err := edgelist.ReadEdgeList(strings.NewReader(content), &graph, nodeFactory, directedFactory, options); err != nil {
+		t.Fatal(err)
+	} else if !graph.HasLink(internal.NewDirectedValuedLink(internal.NewIdNode("Doe, Jane"), internal.NewIdNode(`Smith, "Bob"`), 2.0)) {
+		t.Error("quoted values should keep delimiter and quotes")
+	}
+
+	var buffer bytes.Buffer
+	weigher := func(l testLink) float64 { return l.Value() }
+	if err := edgelist.WriteEdgeList(&buffer, &graph, nil, weigher, options); err != nil {
+		t.Fatal(err)
+	}
+
+	copyGraph := local.NewMapGraph[internal.IdNode, testLink]()
+	if err := edgelist.ReadEdgeList(&buffer, &copyGraph, nodeFactory, directedFactory, options); err != nil {
+		t.Fatal(err)
+	} else if !copyGraph.HasLink(internal.NewDirectedValuedLink(internal.NewIdNode("Doe, Jane"), internal.NewIdNode(`Smith, "Bob"`), 2.0)) {
+		t.Error("values with delimiter should be quoted when written")
+	}
+}
+
+func TestCSVEmptyFields(t *testing.T) {
+	options := edgelist.CSVOptions(',', true)
+	for content, expected := range map[string]string{
+		"source,target,weight\na,,2\n": "line 2: empty destination",
+		"source,target,weight\n,b,2\n": "line 2: empty source",
+	} {
+		graph := local.NewMapGraph[internal.IdNode, testLink]()
+		err := edgelist.ReadEdgeList(strings.NewReader(content), &graph, nodeFactory, directedFactory, options)
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected %q for %q, got %v", expected, content, err)
+		}
+	}
+
+	// trailing empty fields are ignored, so that weight is the default one
+	graph := local.NewMapGraph[internal.IdNode, testLink]()
+	content := "source,target,weight\na,b,\n"
+	if err := edgelist.ReadEdgeList(strings.NewReader(content), &graph, nodeFactory, directedFactory, options); err != nil {
+		t.Fatal(err)
+	} else if !graph.HasLink(internal.NewDirectedValuedLink(internal.NewIdNode("a"), internal.NewIdNode("b"), storage.DefaultLinkWeight)) {
+		t.Error("expected link a -> b")
+	}
+
+	err := edgelist.ReadAdjacencyList(strings.NewReader("a,,b\n"), &graph, nodeFactory, directedFactory, edgelist.Options{Delimiter: ','})
+	if err == nil || !strings.Contains(err.Error(), "line 1: empty neighbor") {
+		t.Errorf("expected empty neighbor error, got %v", err)
+	}
+}
+
+// plainNode is a node with no id
+type plainNode struct {
+	// label makes the node
+	label string
+}
+
+// SameNode compares labels
+func (p *plainNode) SameNode(other graphs.Node) bool {
+	o, ok := other.(*plainNode)
+	return ok && o.label == p.label
+}
+
+func TestNodesNamesWithoutIds(t *testing.T) {
+	graph := local.NewMapGraph[*plainNode, internal.ValuedLink[*plainNode, float64]]()
+	a, b := &plainNode{label: "a"}, &plainNode{label: "b"}
+	graph.AddLink(internal.NewDirectedValuedLink(a, b, 1.0))
+
+	names, err := storage.NewNodesNames(&graph, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for index, node := range names.Nodes() {
+		if names.Index(node) != index {
+			t.Errorf("node %s: expected index %d, got %d", node.label, index, names.Index(node))
+		} else if name, _ := names.Name(node); name != names.Names()[index] {
+			t.Errorf("node %s: unexpected name %s", node.label, name)
+		}
+	}
+
+	if index := names.Index(&plainNode{label: "b"}); index < 0 || names.Nodes()[index] != b {
+		t.Error("other instance of the same node should be found")
+	} else if names.Index(&plainNode{label: "c"}) != -1 {
+		t.Error("node not in graph should not be found")
+	}
+}
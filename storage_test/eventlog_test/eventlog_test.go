@@ -0,0 +1,267 @@
+package eventlog_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zefrenchwan/nodz.git/internal"
+	"github.com/zefrenchwan/nodz.git/internal/local"
+	"github.com/zefrenchwan/nodz.git/storage"
+	"github.com/zefrenchwan/nodz.git/storage/eventlog"
+	"github.com/zefrenchwan/nodz.git/storage/snapshot"
+)
+
+type node = *internal.LabelsPropertiesNode
+type link = *internal.TypePropertiesLink[*internal.LabelsPropertiesNode]
+
+var linkCodec = storage.TypePropertiesLinkCodec[*internal.LabelsPropertiesNode]{}
+
+// newClock returns a clock that moves one minute per call, from a fixed date
+func newClock() func() time.Time {
+	current := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	return func() time.Time {
+		current = current.Add(time.Minute)
+		return current
+	}
+}
+
+// writeLog logs changes on a new graph, and returns the graph and the log
+func writeLog(t *testing.T, format eventlog.Format) (*local.MapGraph[node, link], []byte) {
+	base := local.NewMapGraph[node, link]()
+	var buffer bytes.Buffer
+	options := eventlog.LogOptions{Format: format, Clock: newClock()}
+	graph, err := eventlog.NewLoggedGraph(&base, &buffer, storage.LabelsPropertiesNodeCodec{}, linkCodec, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	alice := internal.NewLabelsPropertiesNodeWithId("alice")
+	alice.AddLabel("person")
+	bob := internal.NewLabelsPropertiesNodeWithId("bob")
+	carol := internal.NewLabelsPropertiesNodeWithId("carol")
+	knowsBob := internal.NewTypePropertiesLink("knows", &alice, &bob)
+	knowsCarol := internal.NewTypePropertiesLink("knows", &alice, &carol)
+
+	// offsets 1 to 7
+	steps := []func() error{
+		func() error { return graph.AddNode(&alice) },
+		func() error { return graph.AddLink(&knowsBob) },
+		func() error { return graph.SetNodeProperty(&alice, "age", "42") },
+		func() error { return graph.SetLinkProperty(&knowsBob, "since", "2010") },
+		func() error { return graph.AddLink(&knowsCarol) },
+		func() error { return graph.RemoveLink(&knowsBob) },
+		func() error { return graph.RemoveNode(&carol) },
+	}
+
+	for _, step := range steps {
+		if err := step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if graph.Offset() != uint64(len(steps)) {
+		t.Errorf("invalid offset %d", graph.Offset())
+	}
+
+	return &base, buffer.Bytes()
+}
+
+func TestReplayNDJSON(t *testing.T) {
+	_, content := writeLog(t, eventlog.NDJSON)
+	if lines := strings.Count(string(content), "\n"); lines != 7 {
+		t.Fatalf("expected 7 lines, got %d", lines)
+	}
+
+	// full replay
+	result := local.NewMapGraph[node, link]()
+	last, err := eventlog.Replay(bytes.NewReader(content), &result, storage.LabelsPropertiesNodeCodec{}, linkCodec, eventlog.ReplayOptions{})
+	if err != nil {
+		t.Fatal(err)
+	} else if last != 7 {
+		t.Errorf("expected last offset 7, got %d", last)
+	}
+
+	alice := internal.NewLabelsPropertiesNodeWithId("alice")
+	bob := internal.NewLabelsPropertiesNodeWithId("bob")
+	carol := internal.NewLabelsPropertiesNodeWithId("carol")
+	if n, _ := result.Neighbors(&carol); n != nil {
+		t.Error("carol should be removed")
+	} else if n, _ := result.Neighbors(&alice); n == nil || n.OutgoingDegree() != 0 {
+		t.Error("alice should have no link")
+	} else if n, _ := result.Neighbors(&bob); n == nil {
+		t.Error("bob should stay")
+	}
+
+	// replay up to offset 4: link with its property, and alice's age
+	partial := local.NewMapGraph[node, link]()
+	if _, err := eventlog.Replay(bytes.NewReader(content), &partial, storage.LabelsPropertiesNodeCodec{}, linkCodec, eventlog.ReplayOptions{UpToOffset: 4}); err != nil {
+		t.Fatal(err)
+	}
+
+	neighbors, _ := partial.Neighbors(&alice)
+	links, _ := neighbors.Links()
+	if has, _ := links.Next(); !has {
+		t.Fatal("expecting link to bob")
+	}
+
+	current, _ := links.Value()
+	if since, _ := current.GetProperty("since"); since != "2010" {
+		t.Error("link property should be replayed")
+	} else if age, _ := current.Source().GetProperty("age"); age != "42" {
+		t.Error("node property should be replayed on the instance in the graph")
+	} else if labels := current.Source().Labels(); len(labels) != 1 {
+		t.Error("labels should be kept")
+	}
+
+	// replay up to a time: two first minutes, so alice and link
+	timed := local.NewMapGraph[node, link]()
+	upTo := time.Date(2024, 1, 1, 0, 2, 0, 0, time.UTC)
+	knowsBob := internal.NewTypePropertiesLink[node]("knows", &alice, &bob)
+	if last, err := eventlog.Replay(bytes.NewReader(content), &timed, storage.LabelsPropertiesNodeCodec{}, linkCodec, eventlog.ReplayOptions{UpTo: upTo}); err != nil {
+		t.Fatal(err)
+	} else if last != 2 {
+		t.Errorf("expected last offset 2, got %d", last)
+	} else if !timed.HasLink(&knowsBob) {
+		t.Error("expected link to bob")
+	}
+}
+
+func TestBinaryLogAndCompaction(t *testing.T) {
+	_, content := writeLog(t, eventlog.Binary)
+	reader, err := eventlog.NewRecordReader(bytes.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	} else if reader.Format() != eventlog.Binary {
+		t.Fatal("binary format should be detected")
+	}
+
+	// compact the first five records, then replay the others from the snapshot
+	var compacted bytes.Buffer
+	last, errCompact := eventlog.Compact(bytes.NewReader(content), &compacted, storage.LabelsPropertiesNodeCodec{}, linkCodec, eventlog.ReplayOptions{UpToOffset: 5})
+	if errCompact != nil {
+		t.Fatal(errCompact)
+	} else if last != 5 {
+		t.Fatalf("expected last offset 5, got %d", last)
+	}
+
+	restored, errLoad := snapshot.LoadMapGraph(&compacted, storage.LabelsPropertiesNodeCodec{}, linkCodec)
+	if errLoad != nil {
+		t.Fatal(errLoad)
+	}
+
+	carol := internal.NewLabelsPropertiesNodeWithId("carol")
+	if n, _ := restored.Neighbors(&carol); n == nil {
+		t.Fatal("carol should be in snapshot")
+	}
+
+	if last, err := eventlog.Replay(bytes.NewReader(content), restored, storage.LabelsPropertiesNodeCodec{}, linkCodec, eventlog.ReplayOptions{AfterOffset: 5}); err != nil {
+		t.Fatal(err)
+	} else if last != 7 {
+		t.Errorf("expected last offset 7, got %d", last)
+	} else if n, _ := restored.Neighbors(&carol); n != nil {
+		t.Error("carol should be removed after replay")
+	}
+
+	// corrupted log fails
+	corrupted := bytes.Clone(content)
+	corrupted[len(corrupted)-2] ^= 0xff
+	empty := local.NewMapGraph[node, link]()
+	if _, err := eventlog.Replay(bytes.NewReader(corrupted), &empty, storage.LabelsPropertiesNodeCodec{}, linkCodec, eventlog.ReplayOptions{}); err == nil {
+		t.Error("corruption should be detected")
+	}
+}
+
+func TestAppendToLog(t *testing.T) {
+	var buffer bytes.Buffer
+	first := local.NewMapGraph[internal.IdNode, internal.UndirectedSimpleLink[internal.IdNode]]()
+	codec := storage.UndirectedSimpleLinkCodec[internal.IdNode]{}
+	logged, _ := eventlog.NewLoggedGraph(&first, &buffer, storage.IdNodeCodec{}, codec, eventlog.LogOptions{Format: eventlog.Binary})
+	logged.AddLink(internal.NewUndirectedSimpleLink(internal.NewIdNode("a"), internal.NewIdNode("b")))
+
+	// new process appends to the same log
+	second := local.NewMapGraph[internal.IdNode, internal.UndirectedSimpleLink[internal.IdNode]]()
+	appended, _ := eventlog.NewLoggedGraph(&second, &buffer, storage.IdNodeCodec{}, codec, eventlog.LogOptions{Format: eventlog.Binary, LastOffset: logged.Offset()})
+	appended.AddNode(internal.NewIdNode("c"))
+
+	result := local.NewMapGraph[internal.IdNode, internal.UndirectedSimpleLink[internal.IdNode]]()
+	if last, err := eventlog.Replay(&buffer, &result, storage.IdNodeCodec{}, codec, eventlog.ReplayOptions{}); err != nil {
+		t.Fatal(err)
+	} else if last != 2 {
+		t.Errorf("expected last offset 2, got %d", last)
+	} else if n, _ := result.Neighbors(internal.NewIdNode("c")); n == nil {
+		t.Error("appended node should be replayed")
+	} else if !result.HasLink(internal.NewUndirectedSimpleLink(internal.NewIdNode("b"), internal.NewIdNode("a"))) {
+		t.Error("link should be replayed")
+	}
+}
+
+func TestLoggedGraphSkipsNoOps(t *testing.T) {
+	base := local.NewMapGraph[node, link]()
+	var buffer bytes.Buffer
+	graph, _ := eventlog.NewLoggedGraph(&base, &buffer, storage.LabelsPropertiesNodeCodec{}, linkCodec, eventlog.LogOptions{Format: eventlog.NDJSON, Clock: newClock()})
+
+	alice := internal.NewLabelsPropertiesNodeWithId("alice")
+	bob := internal.NewLabelsPropertiesNodeWithId("bob")
+	knowsBob := internal.NewTypePropertiesLink("knows", &alice, &bob)
+	graph.AddNode(&alice)
+	graph.AddLink(&knowsBob)
+	graph.SetNodeProperty(&alice, "age", "42")
+	if graph.Offset() != 3 {
+		t.Fatalf("expected offset 3, got %d", graph.Offset())
+	}
+
+	// other instances of the same node and link change the instances of the graph
+	otherAlice := internal.NewLabelsPropertiesNodeWithId("alice")
+	otherKnows := internal.NewTypePropertiesLink("knows", &otherAlice, &bob)
+	if err := graph.SetNodeProperty(&otherAlice, "city", "Paris"); err != nil {
+		t.Fatal(err)
+	} else if city, _ := alice.GetProperty("city"); city != "Paris" {
+		t.Error("property should be set on node of the graph")
+	} else if err := graph.SetLinkProperty(&otherKnows, "since", "2010"); err != nil {
+		t.Fatal(err)
+	} else if since, _ := knowsBob.GetProperty("since"); since != "2010" {
+		t.Error("property should be set on link of the graph")
+	} else if graph.Offset() != 5 {
+		t.Fatalf("expected offset 5, got %d", graph.Offset())
+	}
+
+	carol := internal.NewLabelsPropertiesNodeWithId("carol")
+	knowsCarol := internal.NewTypePropertiesLink("knows", &alice, &carol)
+	noOps := []func() error{
+		func() error { return graph.AddNode(&alice) },
+		func() error { return graph.AddLink(&knowsBob) },
+		func() error { return graph.SetNodeProperty(&alice, "age", "42") },
+		func() error { return graph.RemoveNodeProperty(&alice, "unknown") },
+		func() error { return graph.SetLinkProperty(&knowsBob, "since", "2010") },
+		func() error { return graph.RemoveLink(&knowsCarol) },
+		func() error { return graph.RemoveNode(&carol) },
+	}
+
+	for index, noOp := range noOps {
+		if err := noOp(); err != nil {
+			t.Fatalf("no op %d: %s", index, err)
+		}
+	}
+
+	if graph.Offset() != 5 {
+		t.Errorf("no op should not be logged, offset %d", graph.Offset())
+	} else if err := graph.SetNodeProperty(&carol, "age", "30"); err == nil {
+		t.Error("node not in graph should raise an error")
+	} else if err := graph.SetLinkProperty(&knowsCarol, "since", "2020"); err == nil {
+		t.Error("link not in graph should raise an error")
+	} else if graph.Offset() != 5 {
+		t.Errorf("failed changes should not be logged, offset %d", graph.Offset())
+	} else if _, found := carol.GetProperty("age"); found {
+		t.Error("node not in graph should not change")
+	}
+
+	// log replays to the same graph
+	result := local.NewMapGraph[node, link]()
+	if _, err := eventlog.Replay(&buffer, &result, storage.LabelsPropertiesNodeCodec{}, linkCodec, eventlog.ReplayOptions{}); err != nil {
+		t.Fatal(err)
+	} else if n, _ := result.Neighbors(&alice); n == nil || n.OutgoingDegree() != 1 {
+		t.Error("replayed graph should have alice and its link")
+	}
+}
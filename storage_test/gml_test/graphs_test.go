@@ -0,0 +1,176 @@
+package gml_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/zefrenchwan/nodz.git/internal"
+	"github.com/zefrenchwan/nodz.git/internal/local"
+	"github.com/zefrenchwan/nodz.git/storage/gml"
+)
+
+type neoNode = *internal.LabelsPropertiesNode
+type neoLink = *internal.TypePropertiesLink[*internal.LabelsPropertiesNode]
+
+func nodeFactory(id string) (neoNode, error) {
+	node := internal.NewLabelsPropertiesNodeWithId(id)
+	return &node, nil
+}
+
+func linkFactory(source, destination neoNode, weight float64) (neoLink, error) {
+	link := internal.NewTypePropertiesLink("link", source, destination)
+	return &link, nil
+}
+
+// findNode returns the node in the graph with that id, nil if not found
+func findNode(graph *local.MapGraph[neoNode, neoLink], id string) neoNode {
+	it, _ := graph.AllNodes()
+	for has, _ := it.Next(); has; has, _ = it.Next() {
+		if node, _ := it.Value(); node.Id() == id {
+			return node
+		}
+	}
+
+	return nil
+}
+
+func TestReadGML(t *testing.T) {
+	content := `Creator "test"
+# comment line
+graph [
+  directed 1
+  edge [ source 2 target 1 weight 2.5 kind "road" ]
+  node [ id 1 label "a" graphics [ x 1.5 y -2 ] ]
+  node [ id 2 label "b &amp; c" ]
+]`
+
+	graph := local.NewMapGraph[neoNode, neoLink]()
+	directed, err := gml.ReadGML(strings.NewReader(content), &graph, nodeFactory, linkFactory, linkFactory)
+	if err != nil {
+		t.Fatal(err)
+	} else if !directed {
+		t.Error("expected directed graph")
+	}
+
+	a := findNode(&graph, "a")
+	if a == nil {
+		t.Fatal("missing node a")
+	} else if x, _ := a.GetProperty("graphics.x"); x != "1.5" {
+		t.Errorf("expected nested attribute, got %q", x)
+	}
+
+	bc := findNode(&graph, "b & c")
+	if bc == nil {
+		t.Fatal("label should be unescaped")
+	}
+
+	neighbors, _ := graph.Neighbors(bc)
+	if neighbors == nil || neighbors.OutgoingDegree() != 1 {
+		t.Fatal("expected one outgoing link")
+	}
+
+	it, _ := neighbors.Links()
+	it.Next()
+	if link, _ := it.Value(); link == nil {
+		t.Fail()
+	} else if kind, _ := link.GetProperty("kind"); kind != "road" {
+		t.Error("edge attributes should be properties")
+	}
+}
+
+func TestReadGMLErrorPosition(t *testing.T) {
+	graph := local.NewMapGraph[neoNode, neoLink]()
+	_, err := gml.ReadGML(strings.NewReader("graph [\n  node [ id 1 ]\n  node [ id 2 ! ]\n]"), &graph, nodeFactory, linkFactory, linkFactory)
+	if err == nil || !strings.Contains(err.Error(), "line 3, column 15") {
+		t.Errorf("expected error at line 3 column 15, got %v", err)
+	}
+}
+
+func TestGMLRoundTrip(t *testing.T) {
+	alice := internal.NewLabelsPropertiesNodeWithId("alice")
+	alice.AddLabel("person")
+	alice.SetProperty("quote", `say "hi"`)
+	bob := internal.NewLabelsPropertiesNodeWithId("bob")
+	link := internal.NewTypePropertiesLink("knows", &alice, &bob)
+	link.SetProperty("since", "2010")
+	graph := local.NewMapGraph[neoNode, neoLink]()
+	graph.AddLink(&link)
+
+	var buffer bytes.Buffer
+	if err := gml.WriteGML(&buffer, &graph, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	result := local.NewMapGraph[neoNode, neoLink]()
+	if directed, err := gml.ReadGML(&buffer, &result, nodeFactory, linkFactory, linkFactory); err != nil {
+		t.Fatal(err)
+	} else if !directed {
+		t.Error("expected directed graph")
+	}
+
+	loaded := findNode(&result, "alice")
+	if loaded == nil {
+		t.Fatal("missing alice")
+	} else if q, _ := loaded.GetProperty("quote"); q != `say "hi"` {
+		t.Errorf("invalid property %q", q)
+	} else if labels := loaded.Labels(); len(labels) != 1 || labels[0] != "person" {
+		t.Errorf("invalid labels %v", labels)
+	} else if n, _ := result.Neighbors(loaded); n.OutgoingDegree() != 1 {
+		t.Error("alice should know bob")
+	}
+
+	invalid := internal.NewLabelsPropertiesNodeWithId("x")
+	invalid.SetProperty("not valid", "value")
+	graph.AddNode(&invalid)
+	if err := gml.WriteGML(&buffer, &graph, nil, nil); err == nil {
+		t.Error("invalid keys should raise an error")
+	}
+}
+
+func TestGMLNestedAttributesRoundTrip(t *testing.T) {
+	content := `graph [
+  node [ id 1 label "a" graphics [ x 1.5 y -2 fill "#ff0000" Line [ width 2 ] ] ]
+  node [ id 2 label "b" ]
+  edge [ source 1 target 2 graphics [ arrow "last" ] ]
+]`
+
+	graph := local.NewMapGraph[neoNode, neoLink]()
+	if _, err := gml.ReadGML(strings.NewReader(content), &graph, nodeFactory, linkFactory, linkFactory); err != nil {
+		t.Fatal(err)
+	}
+
+	var buffer bytes.Buffer
+	if err := gml.WriteGML(&buffer, &graph, nil, nil); err != nil {
+		t.Fatalf("read graph should be written back, got %v", err)
+	} else if !strings.Contains(buffer.String(), "graphics [") {
+		t.Errorf("dotted keys should be nested lists, got %s", buffer.String())
+	}
+
+	result := local.NewMapGraph[neoNode, neoLink]()
+	if _, err := gml.ReadGML(&buffer, &result, nodeFactory, linkFactory, linkFactory); err != nil {
+		t.Fatal(err)
+	}
+
+	a := findNode(&result, "a")
+	if a == nil {
+		t.Fatal("missing node a")
+	}
+
+	expected := map[string]string{"graphics.x": "1.5", "graphics.y": "-2", "graphics.fill": "#ff0000", "graphics.Line.width": "2"}
+	for key, value := range expected {
+		if current, _ := a.GetProperty(key); current != value {
+			t.Errorf("%s: expected %q, got %q", key, value, current)
+		}
+	}
+
+	neighbors, _ := result.Neighbors(a)
+	links, _ := neighbors.Links()
+	if has, _ := links.Next(); !has {
+		t.Fatal("missing edge")
+	} else if link, _ := links.Value(); link == nil {
+		t.Fatal("nil edge")
+	} else if arrow, _ := link.GetProperty("graphics.arrow"); arrow != "last" {
+		t.Errorf("edge nested attribute: expected last, got %q", arrow)
+	}
+}
@@ -0,0 +1,145 @@
+package ingestion_test
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/zefrenchwan/nodz.git/internal"
+	"github.com/zefrenchwan/nodz.git/internal/local"
+	"github.com/zefrenchwan/nodz.git/storage"
+	"github.com/zefrenchwan/nodz.git/storage/eventlog"
+	"github.com/zefrenchwan/nodz.git/storage/ingestion"
+)
+
+type node = internal.IdNode
+type link = internal.UndirectedSimpleLink[internal.IdNode]
+
+var linkCodec = storage.UndirectedSimpleLinkCodec[internal.IdNode]{}
+
+// addLink returns the record to add a link between two ids
+func addLink(offset uint64, source, destination string) eventlog.Record {
+	s, _ := storage.IdNodeCodec{}.EncodeNode(internal.NewIdNode(source))
+	d, _ := storage.IdNodeCodec{}.EncodeNode(internal.NewIdNode(destination))
+	return eventlog.Record{Offset: offset, Operation: eventlog.AddLink, Source: s, Destination: d}
+}
+
+// addNode returns the record to add a node
+func addNode(offset uint64, id string) eventlog.Record {
+	n, _ := storage.IdNodeCodec{}.EncodeNode(internal.NewIdNode(id))
+	return eventlog.Record{Offset: offset, Operation: eventlog.AddNode, Node: n}
+}
+
+// failingSource fails after its records, as a broken connection would
+type failingSource struct {
+	records []eventlog.Record
+	index   int
+}
+
+func (fs *failingSource) Next() (bool, error) {
+	if fs.index >= len(fs.records) {
+		return false, errors.New("connection lost")
+	}
+
+	fs.index++
+	return true, nil
+}
+
+func (fs *failingSource) Value() (eventlog.Record, error) {
+	return fs.records[fs.index-1], nil
+}
+
+func TestIngestChannelWithDuplicates(t *testing.T) {
+	records := make(chan eventlog.Record, 10)
+	records <- addNode(1, "a")
+	records <- addLink(2, "a", "b")
+	// redelivered records
+	records <- addNode(1, "a")
+	records <- addLink(2, "a", "b")
+	// same changes, new offsets
+	records <- addNode(3, "a")
+	records <- addLink(4, "b", "a")
+	records <- addNode(5, "c")
+	close(records)
+
+	g := local.NewMapGraph[node, link]()
+	checkpointer := &ingestion.MemoryCheckpointer{}
+	last, err := ingestion.Ingest(ingestion.NewChannelSource(records), &g, storage.IdNodeCodec{}, linkCodec, checkpointer, ingestion.Options{CheckpointEvery: 2})
+	if err != nil {
+		t.Fatal(err)
+	} else if last != 5 {
+		t.Errorf("expected last offset 5, got %d", last)
+	} else if saved, _ := checkpointer.Load(); saved != 5 {
+		t.Errorf("expected checkpoint 5, got %d", saved)
+	}
+
+	nodes, _ := g.AllNodes()
+	count := 0
+	for has, _ := nodes.Next(); has; has, _ = nodes.Next() {
+		count++
+	}
+
+	if count != 3 {
+		t.Errorf("expected 3 nodes, got %d", count)
+	} else if n, _ := g.Neighbors(internal.NewIdNode("a")); n.UndirectedDegree() != 1 {
+		t.Errorf("duplicate link should not be added, degree is %d", n.UndirectedDegree())
+	}
+}
+
+func TestIngestResumeFromCheckpoint(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "offset")
+	checkpointer, errCheckpointer := ingestion.NewFileCheckpointer(path)
+	if errCheckpointer != nil {
+		t.Fatal(errCheckpointer)
+	} else if offset, err := checkpointer.Load(); err != nil || offset != 0 {
+		t.Fatal("missing checkpoint should be 0")
+	}
+
+	all := []eventlog.Record{addNode(1, "a"), addLink(2, "a", "b"), addLink(3, "b", "c"), addNode(4, "d")}
+
+	// first process stops after two records
+	g := local.NewMapGraph[node, link]()
+	broken := &failingSource{records: all[:2]}
+	last, err := ingestion.Ingest(broken, &g, storage.IdNodeCodec{}, linkCodec, checkpointer, ingestion.Options{CheckpointEvery: 10})
+	if err == nil {
+		t.Fatal("source error should be returned")
+	} else if last != 2 {
+		t.Fatalf("expected last offset 2, got %d", last)
+	} else if saved, _ := checkpointer.Load(); saved != 2 {
+		t.Fatalf("checkpoint should be saved on failure, got %d", saved)
+	}
+
+	// restarted process reads the whole log, in binary format, and skips applied records
+	var buffer bytes.Buffer
+	writer, errWriter := eventlog.NewRecordWriter(&buffer, eventlog.Binary)
+	if errWriter != nil {
+		t.Fatal(errWriter)
+	}
+
+	for _, record := range all {
+		if err := writer.Write(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	source, errSource := ingestion.NewReaderSource(&buffer)
+	if errSource != nil {
+		t.Fatal(errSource)
+	}
+
+	restarted := local.NewMapGraph[node, link]()
+	if last, err := ingestion.Ingest(source, &restarted, storage.IdNodeCodec{}, linkCodec, checkpointer, ingestion.Options{}); err != nil {
+		t.Fatal(err)
+	} else if last != 4 {
+		t.Errorf("expected last offset 4, got %d", last)
+	}
+
+	if n, _ := restarted.Neighbors(internal.NewIdNode("a")); n != nil {
+		t.Error("records before checkpoint should be skipped")
+	} else if !restarted.HasLink(internal.NewUndirectedSimpleLink(internal.NewIdNode("c"), internal.NewIdNode("b"))) {
+		t.Error("records after checkpoint should be applied")
+	} else if saved, _ := checkpointer.Load(); saved != 4 {
+		t.Errorf("expected checkpoint 4, got %d", saved)
+	}
+}
@@ -0,0 +1,173 @@
+package jsongraph_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/zefrenchwan/nodz.git/internal"
+	"github.com/zefrenchwan/nodz.git/internal/local"
+	"github.com/zefrenchwan/nodz.git/storage/jsongraph"
+)
+
+type neoNode = *internal.LabelsPropertiesNode
+type neoLink = *internal.TypePropertiesLink[*internal.LabelsPropertiesNode]
+
+func neoNodeFactory(id string) (neoNode, error) {
+	node := internal.NewLabelsPropertiesNodeWithId(id)
+	return &node, nil
+}
+
+func neoLinkFactory(source, destination neoNode, weight float64) (neoLink, error) {
+	link := internal.NewTypePropertiesLink("knows", source, destination)
+	return &link, nil
+}
+
+// findNode returns the node in the graph with that id, nil if not found
+func findNode(graph *local.MapGraph[neoNode, neoLink], id string) neoNode {
+	it, _ := graph.AllNodes()
+	for has, _ := it.Next(); has; has, _ = it.Next() {
+		if node, _ := it.Value(); node.Id() == id {
+			return node
+		}
+	}
+
+	return nil
+}
+
+// buildNeoGraph returns a graph: alice -knows-> bob, with labels and properties
+func buildNeoGraph() local.MapGraph[neoNode, neoLink] {
+	alice := internal.NewLabelsPropertiesNodeWithId("alice")
+	alice.AddLabel("person")
+	alice.SetProperty("age", "42")
+	bob := internal.NewLabelsPropertiesNodeWithId("bob")
+	bob.AddLabel("person")
+	bob.AddLabel("admin")
+	link := internal.NewTypePropertiesLink("knows", &alice, &bob)
+	link.SetProperty("since", "2010")
+
+	graph := local.NewMapGraph[neoNode, neoLink]()
+	graph.AddLink(&link)
+	return graph
+}
+
+func TestNodeLinkIsValidNetworkxDocument(t *testing.T) {
+	graph := buildNeoGraph()
+	var buffer bytes.Buffer
+	options := jsongraph.Options[neoNode, neoLink]{Info: jsongraph.GraphInfo{Directed: true}}
+	if err := jsongraph.WriteNodeLink(&buffer, &graph, options); err != nil {
+		t.Fatal(err)
+	}
+
+	var document struct {
+		Directed   bool             `json:"directed"`
+		Multigraph bool             `json:"multigraph"`
+		Graph      map[string]any   `json:"graph"`
+		Nodes      []map[string]any `json:"nodes"`
+		Links      []map[string]any `json:"links"`
+	}
+
+	if err := json.Unmarshal(buffer.Bytes(), &document); err != nil {
+		t.Fatal(err)
+	} else if !document.Directed || document.Multigraph || document.Graph == nil {
+		t.Error("invalid graph information")
+	} else if len(document.Nodes) != 2 || len(document.Links) != 1 {
+		t.Errorf("expected 2 nodes and 1 link, got %v", buffer.String())
+	} else if document.Links[0]["source"] != "alice" || document.Links[0]["since"] != "2010" {
+		t.Errorf("invalid link %v", document.Links[0])
+	}
+}
+
+func TestNodeLinkRoundTrip(t *testing.T) {
+	graph := buildNeoGraph()
+	var buffer bytes.Buffer
+	options := jsongraph.Options[neoNode, neoLink]{Info: jsongraph.GraphInfo{Directed: true, Attributes: map[string]string{"name": "test"}}}
+	if err := jsongraph.WriteNodeLink(&buffer, &graph, options); err != nil {
+		t.Fatal(err)
+	}
+
+	result := local.NewMapGraph[neoNode, neoLink]()
+	info, err := jsongraph.ReadNodeLink(&buffer, &result, neoNodeFactory, neoLinkFactory)
+	if err != nil {
+		t.Fatal(err)
+	} else if !info.Directed || info.Attributes["name"] != "test" {
+		t.Error("invalid graph information")
+	}
+
+	bob := internal.NewLabelsPropertiesNodeWithId("bob")
+	neighbors, errNeighbors := result.Neighbors(&bob)
+	if errNeighbors != nil || neighbors == nil {
+		t.Fatal("bob should be in the graph")
+	} else if neighbors.IncomingDegree() != 1 {
+		t.Error("bob should have an incoming link")
+	} else if labels := findNode(&result, "bob").Labels(); strings.Join(labels, ",") != "admin,person" {
+		t.Errorf("invalid labels %v", labels)
+	}
+}
+
+func TestReadNetworkxEdgesAndNumericIds(t *testing.T) {
+	content := `{"directed": false, "multigraph": false, "graph": {},
+	"nodes": [{"id": 1, "age": 3}, {"id": 2}],
+	"edges": [{"source": 1, "target": 2, "weight": 2.5, "kind": "friend"}]}`
+
+	result := local.NewMapGraph[neoNode, neoLink]()
+	if _, err := jsongraph.ReadNodeLink(strings.NewReader(content), &result, neoNodeFactory, neoLinkFactory); err != nil {
+		t.Fatal(err)
+	}
+
+	one := internal.NewLabelsPropertiesNodeWithId("1")
+	if neighbors, err := result.Neighbors(&one); err != nil || neighbors == nil {
+		t.Fatal("node 1 should be in the graph")
+	} else if age, _ := findNode(&result, "1").GetProperty("age"); age != "3" {
+		t.Errorf("expected age 3, got %s", age)
+	} else if it, _ := neighbors.Links(); it == nil {
+		t.Fail()
+	} else if has, _ := it.Next(); !has {
+		t.Error("expected a link")
+	} else if link, _ := it.Value(); link == nil {
+		t.Fail()
+	} else if kind, _ := link.GetProperty("kind"); kind != "friend" {
+		t.Error("link properties should be set")
+	}
+}
+
+func TestJGFRoundTrip(t *testing.T) {
+	graph := buildNeoGraph()
+	var buffer bytes.Buffer
+	options := jsongraph.Options[neoNode, neoLink]{Info: jsongraph.GraphInfo{Directed: true}}
+	if err := jsongraph.WriteJGF(&buffer, &graph, options); err != nil {
+		t.Fatal(err)
+	}
+
+	result := local.NewMapGraph[neoNode, neoLink]()
+	if info, err := jsongraph.ReadJGF(&buffer, &result, neoNodeFactory, neoLinkFactory); err != nil {
+		t.Fatal(err)
+	} else if !info.Directed {
+		t.Error("expected directed graph")
+	}
+
+	alice := internal.NewLabelsPropertiesNodeWithId("alice")
+	if neighbors, err := result.Neighbors(&alice); err != nil || neighbors == nil {
+		t.Fatal("alice should be in the graph")
+	} else if neighbors.OutgoingDegree() != 1 {
+		t.Error("alice knows bob")
+	} else if age, _ := findNode(&result, "alice").GetProperty("age"); age != "42" {
+		t.Error("metadata should be properties")
+	}
+}
+
+func TestReadJGFVersionOne(t *testing.T) {
+	content := `{"graph": {"directed": true, "nodes": [{"id": "a", "label": "x"}, {"id": "b"}], "edges": [{"source": "a", "target": "b"}]}}`
+	result := local.NewMapGraph[neoNode, neoLink]()
+	if _, err := jsongraph.ReadJGF(strings.NewReader(content), &result, neoNodeFactory, neoLinkFactory); err != nil {
+		t.Fatal(err)
+	}
+
+	a := internal.NewLabelsPropertiesNodeWithId("a")
+	if neighbors, err := result.Neighbors(&a); err != nil || neighbors == nil || neighbors.OutgoingDegree() != 1 {
+		t.Error("a should link b")
+	} else if labels := findNode(&result, "a").Labels(); len(labels) != 1 || labels[0] != "x" {
+		t.Error("label should be read")
+	}
+}
@@ -0,0 +1,103 @@
+package matrixmarket_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/zefrenchwan/nodz.git/internal/local"
+	"github.com/zefrenchwan/nodz.git/storage/matrixmarket"
+)
+
+func TestReadSymmetricMatrix(t *testing.T) {
+	content := `%%MatrixMarket matrix coordinate integer symmetric
+% lower triangle only
+3 3 3
+1 1 4
+2 1 2
+3 2 -1
+`
+
+	matrix, header, err := matrixmarket.ReadMatrix(strings.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	} else if header.Field != matrixmarket.Integer || header.Symmetry != matrixmarket.Symmetric {
+		t.Error("invalid header")
+	}
+
+	expected := [][]float64{{4, 2, 0}, {2, 0, -1}, {0, -1, 0}}
+	for i, line := range expected {
+		for j, value := range line {
+			if v, _, _ := matrix.GetValue(i, j); v != value {
+				t.Errorf("expected %f at %d, %d, got %f", value, i, j, v)
+			}
+		}
+	}
+
+	// write back gives the same entries
+	var buffer bytes.Buffer
+	if err := matrixmarket.WriteMatrix(&buffer, matrix, header); err != nil {
+		t.Fatal(err)
+	}
+
+	written := "%%MatrixMarket matrix coordinate integer symmetric\n3 3 3\n1 1 4\n2 1 2\n3 2 -1\n"
+	if buffer.String() != written {
+		t.Errorf("unexpected content %q", buffer.String())
+	}
+}
+
+func TestRoundTripPattern(t *testing.T) {
+	matrix, _ := local.NewMapMatrix(4, 0.0)
+	matrix.SetValue(0, 3, 1)
+	matrix.SetValue(2, 1, 1)
+
+	var buffer bytes.Buffer
+	header := matrixmarket.Header{Field: matrixmarket.Pattern, Symmetry: matrixmarket.General}
+	if err := matrixmarket.WriteMatrix(&buffer, &matrix, header); err != nil {
+		t.Fatal(err)
+	}
+
+	result, _, errRead := matrixmarket.ReadMatrix(&buffer)
+	if errRead != nil {
+		t.Fatal(errRead)
+	} else if result.Size() != 4 {
+		t.Fatal("invalid size")
+	}
+
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 4; j++ {
+			expected, _, _ := matrix.GetValue(i, j)
+			if value, _, _ := result.GetValue(i, j); value != expected {
+				t.Errorf("invalid value at %d, %d", i, j)
+			}
+		}
+	}
+}
+
+func TestInvalidMatrices(t *testing.T) {
+	matrix, _ := local.NewMapMatrix(2, 0.0)
+	matrix.SetValue(0, 1, 1.5)
+
+	var buffer bytes.Buffer
+	if err := matrixmarket.WriteMatrix(&buffer, &matrix, matrixmarket.Header{Field: matrixmarket.Integer, Symmetry: matrixmarket.General}); err == nil {
+		t.Error("integer field should reject reals")
+	}
+
+	if err := matrixmarket.WriteMatrix(&buffer, &matrix, matrixmarket.Header{Field: matrixmarket.Real, Symmetry: matrixmarket.Symmetric}); err == nil {
+		t.Error("symmetric should reject non symmetric matrix")
+	}
+
+	contents := []string{
+		"%%MatrixMarket matrix array real general\n2 2\n1\n2\n3\n4\n",
+		"%%MatrixMarket matrix coordinate real general\n2 3 0\n",
+		"%%MatrixMarket matrix coordinate real general\n2 2 2\n1 1 1.0\n",
+		"%%MatrixMarket matrix coordinate complex general\n2 2 0\n",
+		"%%MatrixMarket matrix coordinate real general\n2 2 1\n3 1 1.0\n",
+	}
+
+	for _, content := range contents {
+		if _, _, err := matrixmarket.ReadMatrix(strings.NewReader(content)); err == nil {
+			t.Errorf("expecting error for %q", content)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package nauty_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/zefrenchwan/nodz.git/graphs"
+	"github.com/zefrenchwan/nodz.git/internal"
+	"github.com/zefrenchwan/nodz.git/internal/local"
+	"github.com/zefrenchwan/nodz.git/storage/nauty"
+)
+
+type simpleLink = internal.ValuedLink[internal.IdNode, float64]
+
+func newNode(id string) (internal.IdNode, error) {
+	return internal.NewIdNode(id), nil
+}
+
+func newDirected(source, destination internal.IdNode, weight float64) (simpleLink, error) {
+	return internal.NewDirectedValuedLink(source, destination, weight), nil
+}
+
+func newUndirected(source, destination internal.IdNode, weight float64) (simpleLink, error) {
+	return internal.NewUndirectedValuedLink(source, destination, weight), nil
+}
+
+func undirected(a, b string) simpleLink {
+	return internal.NewUndirectedValuedLink(internal.NewIdNode(a), internal.NewIdNode(b), 1.0)
+}
+
+func TestGraph6KnownValues(t *testing.T) {
+	// path 0 - 1 - 2
+	path, err := nauty.DecodeGraph6("Bg", newNode, newUndirected)
+	if err != nil {
+		t.Fatal(err)
+	} else if !path.HasLink(undirected("0", "1")) || !path.HasLink(undirected("2", "1")) {
+		t.Error("missing path link")
+	} else if path.HasLink(undirected("0", "2")) {
+		t.Error("unexpected link")
+	}
+
+	if encoded, err := nauty.EncodeGraph6(path); err != nil {
+		t.Fatal(err)
+	} else if encoded != "Bg" {
+		t.Errorf("expected Bg, got %s", encoded)
+	}
+
+	complete, _ := local.GenerateCompleteUndirectedGraph(4, internal.NewRandomIdNode, internal.NewUndirectedSimpleLink)
+	if encoded, err := nauty.EncodeGraph6(&complete); err != nil {
+		t.Fatal(err)
+	} else if encoded != "C~" {
+		t.Errorf("expected C~, got %s", encoded)
+	}
+}
+
+func TestSparse6KnownValue(t *testing.T) {
+	// example of the format definition: 7 nodes, edges 0-1, 0-2, 1-2 and 5-6
+	g, err := nauty.Decode(">>sparse6<<:Fa@x^", newNode, newDirected, newUndirected)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, pair := range [][2]string{{"0", "1"}, {"0", "2"}, {"1", "2"}, {"5", "6"}} {
+		if !g.HasLink(undirected(pair[0], pair[1])) {
+			t.Errorf("missing link %v", pair)
+		}
+	}
+
+	if encoded, err := nauty.EncodeSparse6(g); err != nil {
+		t.Fatal(err)
+	} else if encoded != ":Fa@x^" {
+		t.Errorf("expected :Fa@x^, got %s", encoded)
+	}
+}
+
+func TestRoundTrips(t *testing.T) {
+	generator := local.RandomGenerator[internal.IdNode, internal.UndirectedSimpleLink[internal.IdNode]]{}
+	for _, size := range []int{3, 8, 16, 70} {
+		graph, _ := generator.UndirectedBarabasiAlbertGraph(2, size, internal.NewRandomIdNode, internal.NewUndirectedSimpleLink)
+		for _, name := range []string{"graph6", "sparse6", "digraph6"} {
+			encoded, errEncode := encode(name, graph)
+			if errEncode != nil {
+				t.Fatal(errEncode)
+			}
+
+			decoded, errDecode := nauty.Decode(encoded, newNode, newDirected, newUndirected)
+			if errDecode != nil {
+				t.Fatalf("%s for %d nodes: %s", name, size, errDecode)
+			}
+
+			if again, _ := encode(name, decoded); again != encoded {
+				t.Errorf("%s for %d nodes: round trip differs", name, size)
+			}
+		}
+	}
+}
+
+// encode returns g in format name
+func encode[L graphs.Link[internal.IdNode]](name string, g graphs.CentralStructureGraph[internal.IdNode, L]) (string, error) {
+	switch name {
+	case "graph6":
+		return nauty.EncodeGraph6(g)
+	case "sparse6":
+		return nauty.EncodeSparse6(g)
+	default:
+		return nauty.EncodeDigraph6(g)
+	}
+}
+
+func TestDigraph6Direction(t *testing.T) {
+	a, b := internal.NewIdNode("a"), internal.NewIdNode("b")
+	graph := local.NewMapGraph[internal.IdNode, simpleLink]()
+	graph.AddLink(internal.NewDirectedValuedLink(a, b, 1.0))
+	graph.AddLink(internal.NewDirectedValuedLink(b, b, 1.0))
+
+	encoded, err := nauty.EncodeDigraph6(&graph)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, errDecode := nauty.DecodeDigraph6(encoded, newNode, newDirected)
+	if errDecode != nil {
+		t.Fatal(errDecode)
+	}
+
+	zero, one := internal.NewIdNode("0"), internal.NewIdNode("1")
+	if !decoded.HasLink(internal.NewDirectedValuedLink(zero, one, 1.0)) || !decoded.HasLink(internal.NewDirectedValuedLink(one, one, 1.0)) {
+		t.Error("missing directed link")
+	} else if decoded.HasLink(internal.NewDirectedValuedLink(one, zero, 1.0)) {
+		t.Error("unexpected reverse link")
+	}
+
+	if _, err := nauty.EncodeGraph6(&graph); err == nil {
+		t.Error("graph6 cannot encode loops")
+	}
+}
+
+func TestGraphsReader(t *testing.T) {
+	content := ">>graph6<<Bg\n\n:Fa@x^\ninvalid!\nC~\n"
+	reader, err := nauty.NewGraphsReader(strings.NewReader(content), newNode, newDirected, newUndirected)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sizes := make([]int, 0)
+	failures := 0
+	for has, errNext := reader.Next(); has; has, errNext = reader.Next() {
+		if errNext != nil {
+			failures++
+			continue
+		}
+
+		g, _ := reader.Value()
+		sizes = append(sizes, len(nodesOf(g)))
+	}
+
+	if failures != 1 {
+		t.Errorf("expected one invalid line, got %d", failures)
+	} else if len(sizes) != 3 || sizes[0] != 3 || sizes[1] != 7 || sizes[2] != 4 {
+		t.Errorf("invalid sizes %v", sizes)
+	}
+
+	// write them back
+	graphsList := make([]*local.MapGraph[internal.IdNode, simpleLink], 0)
+	reader, _ = nauty.NewGraphsReader(strings.NewReader("Bg\nC~\n"), newNode, newDirected, newUndirected)
+	for has, _ := reader.Next(); has; has, _ = reader.Next() {
+		g, _ := reader.Value()
+		graphsList = append(graphsList, g)
+	}
+
+	var buffer bytes.Buffer
+	iterator := local.NewSlicesIterator(graphsList)
+	if err := nauty.WriteGraphs(&buffer, &iterator, nauty.EncodeGraph6[internal.IdNode, simpleLink]); err != nil {
+		t.Fatal(err)
+	} else if buffer.String() != "Bg\nC~\n" {
+		t.Errorf("unexpected content %q", buffer.String())
+	}
+}
+
+func TestInvalidContent(t *testing.T) {
+	if _, err := nauty.DecodeGraph6("C", newNode, newUndirected); err == nil {
+		t.Error("missing data should fail")
+	}
+
+	failing := func(string) (internal.IdNode, error) { return internal.IdNode{}, errors.New("failure") }
+	if _, err := nauty.DecodeGraph6("Bg", failing, newUndirected); err == nil {
+		t.Error("node factory error should be returned")
+	}
+}
+
+func nodesOf(g graphs.CentralStructureGraph[internal.IdNode, simpleLink]) []internal.IdNode {
+	result := make([]internal.IdNode, 0)
+	it, _ := g.AllNodes()
+	for has, _ := it.Next(); has; has, _ = it.Next() {
+		node, _ := it.Value()
+		result = append(result, node)
+	}
+
+	return result
+}
@@ -0,0 +1,131 @@
+package pajek_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/zefrenchwan/nodz.git/internal"
+	"github.com/zefrenchwan/nodz.git/internal/local"
+	"github.com/zefrenchwan/nodz.git/storage/pajek"
+)
+
+type testLink = internal.ValuedLink[internal.IdNode, float64]
+
+func nodeFactory(id string) (internal.IdNode, error) {
+	return internal.NewIdNode(id), nil
+}
+
+func arcsFactory(source, destination internal.IdNode, weight float64) (testLink, error) {
+	return internal.NewDirectedValuedLink(source, destination, weight), nil
+}
+
+func edgesFactory(source, destination internal.IdNode, weight float64) (testLink, error) {
+	return internal.NewUndirectedValuedLink(source, destination, weight), nil
+}
+
+func TestReadPajek(t *testing.T) {
+	content := `% a small network
+*Vertices 4
+1 "first node" 0.1 0.2 0.5
+2 "b"
+3 "c"
+4
+*Arcs
+1 2 1.5
+*Edges
+2 3
+3 4 0.5
+`
+	graph := local.NewMapGraph[internal.IdNode, testLink]()
+	if err := pajek.ReadPajek(strings.NewReader(content), &graph, nodeFactory, arcsFactory, edgesFactory); err != nil {
+		t.Fatal(err)
+	}
+
+	first := internal.NewIdNode("first node")
+	b := internal.NewIdNode("b")
+	c := internal.NewIdNode("c")
+	if !graph.HasLink(internal.NewDirectedValuedLink(first, b, 1.5)) {
+		t.Error("missing arc")
+	} else if !graph.HasLink(internal.NewUndirectedValuedLink(c, b, 1.0)) {
+		t.Error("missing edge with default weight")
+	} else if !graph.HasLink(internal.NewUndirectedValuedLink(c, internal.NewIdNode("4"), 0.5)) {
+		t.Error("unlabelled vertex should use its number")
+	}
+}
+
+func TestReadPajekErrors(t *testing.T) {
+	graph := local.NewMapGraph[internal.IdNode, testLink]()
+	content := "*Vertices 1\n1 \"a\"\n*Arcs\n1 2\n"
+	if err := pajek.ReadPajek(strings.NewReader(content), &graph, nodeFactory, arcsFactory, edgesFactory); err == nil || !strings.Contains(err.Error(), "line 4") {
+		t.Errorf("expected undeclared vertex error on line 4, got %v", err)
+	}
+}
+
+func TestPajekRoundTrip(t *testing.T) {
+	graph := local.NewMapGraph[internal.IdNode, testLink]()
+	graph.AddNode(internal.NewIdNode("alone"))
+	graph.AddLink(internal.NewDirectedValuedLink(internal.NewIdNode("a"), internal.NewIdNode("b"), 2.0))
+	graph.AddLink(internal.NewUndirectedValuedLink(internal.NewIdNode("b"), internal.NewIdNode("c"), 3.0))
+
+	var buffer bytes.Buffer
+	weigher := func(l testLink) float64 { return l.Value() }
+	if err := pajek.WritePajek(&buffer, &graph, nil, weigher); err != nil {
+		t.Fatal(err)
+	}
+
+	result := local.NewMapGraph[internal.IdNode, testLink]()
+	if err := pajek.ReadPajek(&buffer, &result, nodeFactory, arcsFactory, edgesFactory); err != nil {
+		t.Fatal(err)
+	}
+
+	if !result.HasLink(internal.NewDirectedValuedLink(internal.NewIdNode("a"), internal.NewIdNode("b"), 2.0)) {
+		t.Error("missing arc after round trip")
+	} else if !result.HasLink(internal.NewUndirectedValuedLink(internal.NewIdNode("b"), internal.NewIdNode("c"), 3.0)) {
+		t.Error("missing edge after round trip")
+	} else if n, _ := result.Neighbors(internal.NewIdNode("alone")); n == nil {
+		t.Error("missing isolated node after round trip")
+	}
+}
+
+func TestReadPajekImplicitVerticesAndLists(t *testing.T) {
+	content := `*Vertices 5
+2 "b"
+*Arcslist
+1 2 3
+*Edgeslist
+4 5 1
+`
+	graph := local.NewMapGraph[internal.IdNode, testLink]()
+	if err := pajek.ReadPajek(strings.NewReader(content), &graph, nodeFactory, arcsFactory, edgesFactory); err != nil {
+		t.Fatal(err)
+	}
+
+	one, b, three := internal.NewIdNode("1"), internal.NewIdNode("b"), internal.NewIdNode("3")
+	four, five := internal.NewIdNode("4"), internal.NewIdNode("5")
+	if !graph.HasLink(internal.NewDirectedValuedLink(one, b, 1.0)) || !graph.HasLink(internal.NewDirectedValuedLink(one, three, 1.0)) {
+		t.Error("missing arcs of arcs list")
+	} else if !graph.HasLink(internal.NewUndirectedValuedLink(five, four, 1.0)) || !graph.HasLink(internal.NewUndirectedValuedLink(one, four, 1.0)) {
+		t.Error("missing edges of edges list")
+	}
+
+	// vertices with no line and no link
+	alone := local.NewMapGraph[internal.IdNode, testLink]()
+	if err := pajek.ReadPajek(strings.NewReader("*Vertices 3\n"), &alone, nodeFactory, arcsFactory, edgesFactory); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []string{"1", "2", "3"} {
+		if n, _ := alone.Neighbors(internal.NewIdNode(id)); n == nil {
+			t.Errorf("missing implicit vertex %s", id)
+		}
+	}
+}
+
+func TestReadPajekUnsupportedSection(t *testing.T) {
+	graph := local.NewMapGraph[internal.IdNode, testLink]()
+	content := "*Vertices 2\n*Matrix\n0 1\n0 0\n"
+	if err := pajek.ReadPajek(strings.NewReader(content), &graph, nodeFactory, arcsFactory, edgesFactory); err == nil || !strings.Contains(err.Error(), "unsupported section") {
+		t.Errorf("expected unsupported section error, got %v", err)
+	}
+}
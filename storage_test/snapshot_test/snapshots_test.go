@@ -0,0 +1,269 @@
+package snapshot_test
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/zefrenchwan/nodz.git/graphs"
+	"github.com/zefrenchwan/nodz.git/internal"
+	"github.com/zefrenchwan/nodz.git/internal/local"
+	"github.com/zefrenchwan/nodz.git/storage"
+	"github.com/zefrenchwan/nodz.git/storage/gexf"
+	"github.com/zefrenchwan/nodz.git/storage/snapshot"
+)
+
+type valuedLink = internal.ValuedLink[internal.IdNode, float64]
+
+func TestSnapshotRoundTripValuedLinks(t *testing.T) {
+	a := internal.NewIdNode("a")
+	b := internal.NewIdNode("b")
+	c := internal.NewIdNode("c")
+	graph := local.NewMapGraph[internal.IdNode, valuedLink]()
+	graph.AddNode(internal.NewIdNode("isolated"))
+	graph.AddLink(internal.NewDirectedValuedLink(a, b, 1.5))
+	graph.AddLink(internal.NewUndirectedValuedLink(b, c, -2.0))
+
+	var buffer bytes.Buffer
+	codec := storage.ValuedLinkCodec[internal.IdNode, float64]{}
+	if err := snapshot.Save(&buffer, &graph, storage.IdNodeCodec{}, codec); err != nil {
+		t.Fatal(err)
+	}
+
+	content := buffer.Bytes()
+	result, errLoad := snapshot.LoadMapGraph(bytes.NewReader(content), storage.IdNodeCodec{}, codec)
+	if errLoad != nil {
+		t.Fatal(errLoad)
+	}
+
+	if !result.HasLink(internal.NewDirectedValuedLink(a, b, 1.5)) {
+		t.Error("missing directed link")
+	} else if !result.HasLink(internal.NewUndirectedValuedLink(c, b, -2.0)) {
+		t.Error("missing undirected link")
+	} else if n, _ := result.Neighbors(internal.NewIdNode("isolated")); n == nil {
+		t.Error("missing isolated node")
+	} else if n, _ := result.Neighbors(b); n.IncomingDegree() != 1 || n.UndirectedDegree() != 1 {
+		t.Error("invalid degrees for b")
+	}
+
+	// generic load gives the same graph
+	generic := local.NewMapGraph[internal.IdNode, valuedLink]()
+	if err := snapshot.Load(bytes.NewReader(content), &generic, storage.IdNodeCodec{}, codec); err != nil {
+		t.Fatal(err)
+	} else if !generic.HasLink(internal.NewUndirectedValuedLink(b, c, -2.0)) {
+		t.Error("missing undirected link for generic load")
+	}
+}
+
+func TestSnapshotRoundTripPropertiesModel(t *testing.T) {
+	alice := internal.NewLabelsPropertiesNodeWithId("alice")
+	alice.AddLabel("person")
+	alice.SetProperty("age", "42")
+	bob := internal.NewLabelsPropertiesNodeWithId("bob")
+	link := internal.NewTypePropertiesLink("knows", &alice, &bob)
+	link.SetProperty("since", "2010")
+
+	graph := local.NewMapGraph[*internal.LabelsPropertiesNode, *internal.TypePropertiesLink[*internal.LabelsPropertiesNode]]()
+	graph.AddLink(&link)
+
+	var buffer bytes.Buffer
+	linkCodec := storage.TypePropertiesLinkCodec[*internal.LabelsPropertiesNode]{}
+	if err := snapshot.Save(&buffer, &graph, storage.LabelsPropertiesNodeCodec{}, linkCodec); err != nil {
+		t.Fatal(err)
+	}
+
+	result, errLoad := snapshot.LoadMapGraph(&buffer, storage.LabelsPropertiesNodeCodec{}, linkCodec)
+	if errLoad != nil {
+		t.Fatal(errLoad)
+	}
+
+	neighbors, _ := result.Neighbors(&alice)
+	if neighbors == nil || neighbors.OutgoingDegree() != 1 {
+		t.Fatal("alice should know bob")
+	}
+
+	it, _ := neighbors.Links()
+	if has, _ := it.Next(); !has {
+		t.Fatal("expected a link")
+	}
+
+	loaded, _ := it.Value()
+	if loaded.LinkType() != "knows" {
+		t.Error("invalid link type")
+	} else if since, _ := loaded.GetProperty("since"); since != "2010" {
+		t.Error("invalid link property")
+	} else if age, _ := loaded.Source().GetProperty("age"); age != "42" {
+		t.Error("invalid node property")
+	} else if labels := loaded.Source().Labels(); len(labels) != 1 || labels[0] != "person" {
+		t.Error("invalid node labels")
+	}
+}
+
+func TestSnapshotDetectsCorruption(t *testing.T) {
+	generator := local.RandomGenerator[internal.IdNode, internal.UndirectedSimpleLink[internal.IdNode]]{}
+	graph, _ := generator.UndirectedBarabasiAlbertGraph(3, 50, internal.NewRandomIdNode, internal.NewUndirectedSimpleLink)
+
+	var buffer bytes.Buffer
+	linkCodec := storage.UndirectedSimpleLinkCodec[internal.IdNode]{}
+	if err := snapshot.Save(&buffer, graph, storage.IdNodeCodec{}, linkCodec); err != nil {
+		t.Fatal(err)
+	}
+
+	content := buffer.Bytes()
+	result, errLoad := snapshot.LoadMapGraph(bytes.NewReader(content), storage.IdNodeCodec{}, linkCodec)
+	if errLoad != nil {
+		t.Fatal(errLoad)
+	}
+
+	counter := func(n graphs.Neighborhood[internal.IdNode, internal.UndirectedSimpleLink[internal.IdNode]]) int64 {
+		return n.UndirectedDegree()
+	}
+
+	expected, _ := graphs.CalculateNetworkStatistics(graph, counter)
+	if stats, _ := graphs.CalculateNetworkStatistics(result, counter); stats.NodesSize != expected.NodesSize || stats.UndirectedSize != expected.UndirectedSize {
+		t.Error("loaded graph differs")
+	}
+
+	corrupted := bytes.Clone(content)
+	corrupted[len(corrupted)/2] ^= 0xff
+	if _, err := snapshot.LoadMapGraph(bytes.NewReader(corrupted), storage.IdNodeCodec{}, linkCodec); err == nil {
+		t.Error("corruption should be detected")
+	}
+
+	if _, err := snapshot.LoadMapGraph(bytes.NewReader(content[:len(content)-2]), storage.IdNodeCodec{}, linkCodec); err == nil {
+		t.Error("truncation should be detected")
+	}
+}
+
+// gexfDocument is the part of a gexf file needed to rebuild a graph
+type gexfDocument struct {
+	// Nodes are the nodes, labels are ids
+	Nodes []struct {
+		Id    int    `xml:"id,attr"`
+		Label string `xml:"label,attr"`
+	} `xml:"graph>nodes>node"`
+	// Edges are the links, by index of their extremities
+	Edges []struct {
+		Source int     `xml:"source,attr"`
+		Target int     `xml:"target,attr"`
+		Type   string  `xml:"type,attr"`
+		Weight float64 `xml:"weight,attr"`
+	} `xml:"graph>edges>edge"`
+}
+
+// gexfWeightedLink writes a valued link as an edge with its value as weight
+func gexfWeightedLink(source, destination int, link valuedLink) string {
+	kind := "undirected"
+	if link.IsDirected() {
+		kind = "directed"
+	}
+
+	return fmt.Sprintf(`<edge source="%d" target="%d" type="%s" weight="%g"/>`, source, destination, kind, link.Value())
+}
+
+// loadGexf parses a gexf file written by gexfWeightedLink into a new map graph
+func loadGexf(content []byte) (*local.MapGraph[internal.IdNode, valuedLink], error) {
+	var document gexfDocument
+	if err := xml.Unmarshal(content, &document); err != nil {
+		return nil, err
+	}
+
+	loader := local.NewMapGraphLoader[internal.IdNode, valuedLink]()
+	indexes := make(map[int]int, len(document.Nodes))
+	nodes := make(map[int]internal.IdNode, len(document.Nodes))
+	for _, node := range document.Nodes {
+		nodes[node.Id] = internal.NewIdNode(node.Label)
+		indexes[node.Id] = loader.AppendNode(nodes[node.Id])
+	}
+
+	for _, edge := range document.Edges {
+		source, destination := nodes[edge.Source], nodes[edge.Target]
+		link := internal.NewUndirectedValuedLink(source, destination, edge.Weight)
+		if edge.Type == "directed" {
+			link = internal.NewDirectedValuedLink(source, destination, edge.Weight)
+		}
+
+		if err := loader.AppendLink(indexes[edge.Source], indexes[edge.Target], link); err != nil {
+			return nil, err
+		}
+	}
+
+	return loader.Graph(), nil
+}
+
+// countLinks returns the number of links of a graph
+func countLinks(graph *local.MapGraph[internal.IdNode, valuedLink]) int {
+	result := 0
+	for range graph.Links() {
+		result++
+	}
+
+	return result
+}
+
+func BenchmarkLoadMapGraph(b *testing.B) {
+	// loader avoids the lookups of AddLink to build the graph
+	loader := local.NewMapGraphLoader[internal.IdNode, valuedLink]()
+	nodes := make([]internal.IdNode, 0, 2000)
+	for index := range cap(nodes) {
+		nodes = append(nodes, internal.NewIdNode(fmt.Sprint(index)))
+		loader.AppendNode(nodes[index])
+	}
+
+	random := rand.New(rand.NewSource(7))
+	for range 10000 {
+		source, destination := random.Intn(len(nodes)), random.Intn(len(nodes))
+		link := internal.NewUndirectedValuedLink(nodes[source], nodes[destination], random.Float64())
+		if random.Intn(2) == 0 {
+			link = internal.NewDirectedValuedLink(nodes[source], nodes[destination], random.Float64())
+		}
+
+		if err := loader.AppendLink(source, destination, link); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	graph := loader.Graph()
+
+	var snapshotContent, gexfContent bytes.Buffer
+	codec := storage.ValuedLinkCodec[internal.IdNode, float64]{}
+	if err := snapshot.Save(&snapshotContent, graph, storage.IdNodeCodec{}, codec); err != nil {
+		b.Fatal(err)
+	}
+
+	label := func(node internal.IdNode, _ int) (string, map[string]string) { return node.Id(), nil }
+	if err := gexf.WriteDataGraph(&gexfContent, graph, label, gexfWeightedLink); err != nil {
+		b.Fatal(err)
+	}
+
+	// both formats load the same graph
+	fromSnapshot, errSnapshot := snapshot.LoadMapGraph(bytes.NewReader(snapshotContent.Bytes()), storage.IdNodeCodec{}, codec)
+	fromGexf, errGexf := loadGexf(gexfContent.Bytes())
+	if errSnapshot != nil || errGexf != nil {
+		b.Fatal(errSnapshot, errGexf)
+	} else if expected := countLinks(graph); countLinks(fromSnapshot) != expected || countLinks(fromGexf) != expected {
+		b.Fatalf("expected %d links, got %d from snapshot and %d from gexf", expected, countLinks(fromSnapshot), countLinks(fromGexf))
+	}
+
+	b.Run("snapshot", func(b *testing.B) {
+		b.SetBytes(int64(snapshotContent.Len()))
+		b.ReportAllocs()
+		for range b.N {
+			if _, err := snapshot.LoadMapGraph(bytes.NewReader(snapshotContent.Bytes()), storage.IdNodeCodec{}, codec); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("gexf", func(b *testing.B) {
+		b.SetBytes(int64(gexfContent.Len()))
+		b.ReportAllocs()
+		for range b.N {
+			if _, err := loadGexf(gexfContent.Bytes()); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
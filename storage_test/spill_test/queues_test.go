@@ -0,0 +1,88 @@
+package spill_test
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/zefrenchwan/nodz.git/storage/spill"
+)
+
+func TestQueueMatchesSlice(t *testing.T) {
+	workspace := newWorkspace(t, 4096)
+	queue, errQueue := spill.NewQueue[string](workspace, stringCodec{})
+	if errQueue != nil {
+		t.Fatal(errQueue)
+	}
+
+	defer queue.Close()
+	expected := make([]string, 0)
+	random := rand.New(rand.NewSource(5))
+	for index := range 30000 {
+		value := fmt.Sprintf("value %d", index)
+		if index%1000 == 0 {
+			// larger than a chunk
+			value = strings.Repeat("x", 10000) + value
+		}
+
+		switch operation := random.Intn(10); {
+		case operation < 6:
+			queue.AddLastValue(value)
+			expected = append(expected, value)
+		case operation < 7:
+			queue.AddNextValue(value)
+			expected = append([]string{value}, expected...)
+		default:
+			has, err := queue.Next()
+			if err != nil {
+				t.Fatal(err)
+			} else if has != (len(expected) != 0) {
+				t.Fatalf("step %d: expected %d values", index, len(expected))
+			} else if !has {
+				continue
+			} else if current, _ := queue.Value(); current != expected[0] {
+				t.Fatalf("step %d: expected %.20s, got %.20s", index, expected[0], current)
+			}
+
+			expected = expected[1:]
+		}
+	}
+
+	for _, value := range expected {
+		if has, err := queue.Next(); err != nil || !has {
+			t.Fatalf("missing values: %v", err)
+		} else if current, _ := queue.Value(); current != value {
+			t.Fatalf("expected %.20s, got %.20s", value, current)
+		}
+	}
+
+	if has, _ := queue.Next(); has {
+		t.Error("queue should be empty")
+	} else if _, err := queue.Value(); err == nil {
+		t.Error("empty queue has no value")
+	} else if workspace.Stats().Spills == 0 {
+		t.Error("small budget should spill")
+	}
+}
+
+func TestQueueHalt(t *testing.T) {
+	workspace := newWorkspace(t, 64)
+	queue, _ := spill.NewQueue[string](workspace, stringCodec{})
+	for index := range 100 {
+		queue.AddLastValue(fmt.Sprint(index))
+	}
+
+	if err := queue.Halt(); err != nil {
+		t.Fatal(err)
+	} else if has, _ := queue.Next(); has {
+		t.Error("halted queue should be empty")
+	}
+
+	queue.AddLastValue("again")
+	if has, _ := queue.Next(); !has {
+		t.Error("halted queue accepts new values")
+	} else if value, _ := queue.Value(); value != "again" {
+		t.Errorf("unexpected value %s", value)
+	}
+}
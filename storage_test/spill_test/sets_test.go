@@ -0,0 +1,212 @@
+package spill_test
+
+import (
+	"fmt"
+	"maps"
+	"math/rand"
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/zefrenchwan/nodz.git/graphs"
+	"github.com/zefrenchwan/nodz.git/internal"
+	"github.com/zefrenchwan/nodz.git/internal/local"
+	"github.com/zefrenchwan/nodz.git/storage"
+	"github.com/zefrenchwan/nodz.git/storage/spill"
+)
+
+// stringCodec encodes strings as bytes
+type stringCodec struct{}
+
+// Encode returns the bytes of value
+func (stringCodec) Encode(value string) ([]byte, error) {
+	return []byte(value), nil
+}
+
+// Decode returns bytes as a string
+func (stringCodec) Decode(content []byte) (string, error) {
+	return string(content), nil
+}
+
+// identity is the key of strings
+func identity(value string) string {
+	return value
+}
+
+// newWorkspace returns a workspace with a small memory budget, closed at the end of the test
+func newWorkspace(t *testing.T, budget int) *spill.Workspace {
+	t.Helper()
+	workspace, err := spill.NewWorkspace(spill.Options{Directory: t.TempDir(), MemoryBudget: budget})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { workspace.Close() })
+	return workspace
+}
+
+func TestSetMatchesMap(t *testing.T) {
+	workspace := newWorkspace(t, 4096)
+	set, errSet := spill.NewSet[string](workspace, stringCodec{}, identity)
+	if errSet != nil {
+		t.Fatal(errSet)
+	}
+
+	expected := make(map[string]bool)
+	random := rand.New(rand.NewSource(3))
+	for range 20000 {
+		value := fmt.Sprintf("value %d", random.Intn(3000))
+		switch operation := random.Intn(10); {
+		case operation < 6:
+			if err := set.Add(value); err != nil {
+				t.Fatal(err)
+			}
+
+			expected[value] = true
+		case operation < 9:
+			if err := set.Remove(value); err != nil {
+				t.Fatal(err)
+			}
+
+			delete(expected, value)
+		default:
+			if has, err := set.Has(value); err != nil {
+				t.Fatal(err)
+			} else if has != expected[value] {
+				t.Fatalf("%s: expected %t", value, expected[value])
+			}
+		}
+	}
+
+	if set.Size() != int64(len(expected)) {
+		t.Fatalf("expected size %d, got %d", len(expected), set.Size())
+	} else if stats := workspace.Stats(); stats.Spills == 0 || stats.Compactions == 0 {
+		t.Errorf("small budget should spill and compact, got %v", stats)
+	}
+
+	values, _ := set.ToIterator()
+	found := make([]string, 0)
+	for has, err := values.Next(); has || err != nil; has, err = values.Next() {
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		value, _ := values.Value()
+		found = append(found, value)
+	}
+
+	if want := slices.Sorted(maps.Keys(expected)); !slices.Equal(want, found) {
+		t.Fatalf("iterator: expected %d sorted values, got %d", len(want), len(found))
+	}
+
+	// peek and remove until empty
+	for empty, _ := set.IsEmpty(); !empty; empty, _ = set.IsEmpty() {
+		value, errPeek := set.Peek()
+		if errPeek != nil {
+			t.Fatal(errPeek)
+		} else if !expected[value] {
+			t.Fatalf("peeked %s, not in set", value)
+		} else if err := set.Remove(value); err != nil {
+			t.Fatal(err)
+		}
+
+		delete(expected, value)
+	}
+
+	if len(expected) != 0 {
+		t.Errorf("%d values never peeked", len(expected))
+	} else if _, err := set.Peek(); err == nil {
+		t.Error("empty set should not peek")
+	} else if err := set.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSetKeys(t *testing.T) {
+	workspace := newWorkspace(t, 0)
+	nodes, _ := spill.NewSet[internal.IdNode](workspace, spill.NodeCodec[internal.IdNode]{Codec: storage.IdNodeCodec{}}, nil)
+	if err := nodes.Add(internal.NewIdNode("a")); err != nil {
+		t.Fatal(err)
+	} else if has, _ := nodes.Has(internal.NewIdNode("a")); !has {
+		t.Error("ids are keys")
+	}
+
+	values, _ := spill.NewSet[string](workspace, stringCodec{}, nil)
+	if err := values.Add("a"); err == nil {
+		t.Error("values without id need a key function")
+	}
+}
+
+func TestConnectedComponentsOutOfCore(t *testing.T) {
+	graph := local.NewMapGraph[internal.IdNode, internal.UndirectedSimpleLink[internal.IdNode]]()
+	for index := range 1500 {
+		// components of 15 nodes, as paths
+		if index%15 != 0 {
+			source, destination := internal.NewIdNode(fmt.Sprint(index-1)), internal.NewIdNode(fmt.Sprint(index))
+			graph.AddLink(internal.NewUndirectedSimpleLink(source, destination))
+		}
+	}
+
+	workspace := newWorkspace(t, 2048)
+	codec := spill.NodeCodec[internal.IdNode]{Codec: storage.IdNodeCodec{}}
+	stats, err := graphs.ConnectedComponentsSize(&graph, spill.SetBuilder(workspace, codec, nil), spill.QueueBuilder(workspace, codec))
+	if err != nil {
+		t.Fatal(err)
+	} else if len(stats) != 100 {
+		t.Fatalf("expected 100 components, got %d", len(stats))
+	}
+
+	for component, size := range stats {
+		if size != 15 {
+			t.Errorf("component %d: unexpected size %d", component, size)
+		}
+	}
+
+	if workspace.Stats().Spills == 0 {
+		t.Error("small budget should spill")
+	}
+}
+
+func TestWorkspaceClose(t *testing.T) {
+	parent := t.TempDir()
+	workspace, _ := spill.NewWorkspace(spill.Options{Directory: parent, MemoryBudget: 1})
+	set, _ := spill.NewSet[string](workspace, stringCodec{}, identity)
+	for index := range 100 {
+		set.Add(fmt.Sprint(index))
+	}
+
+	if err := workspace.Close(); err != nil {
+		t.Fatal(err)
+	} else if entries, _ := os.ReadDir(parent); len(entries) != 0 {
+		t.Errorf("workspace should remove its files, got %d entries", len(entries))
+	} else if err := set.Add("closed"); err == nil {
+		t.Error("closed workspace should refuse spills")
+	} else if err := set.Close(); err != nil {
+		t.Errorf("closing a set after its workspace: %v", err)
+	}
+}
+
+func TestSetMergesRunsByTiers(t *testing.T) {
+	workspace := newWorkspace(t, 4096)
+	set, _ := spill.NewSet[string](workspace, stringCodec{}, identity)
+	// size of the entries in a run: key and value, with their sizes and removal flag
+	written := int64(0)
+	for index := range 20000 {
+		value := fmt.Sprintf("value %d", index)
+		written += int64(2*len(value) + 3)
+		if err := set.Add(value); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// merging all runs would write every element once per merge.
+	// Tiers write each element once per tier
+	stats := workspace.Stats()
+	if stats.Compactions == 0 {
+		t.Fatal("runs should be merged")
+	} else if amplification := float64(stats.SpilledBytes) / float64(written); amplification > 8 {
+		t.Errorf("each element should be written a logarithmic number of times, got %.1f", amplification)
+	} else if has, _ := set.Has("value 0"); !has {
+		t.Error("merged runs should keep elements")
+	}
+}
@@ -0,0 +1,535 @@
+package wal_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/zefrenchwan/nodz.git/graphs"
+	"github.com/zefrenchwan/nodz.git/internal"
+	"github.com/zefrenchwan/nodz.git/internal/local"
+	"github.com/zefrenchwan/nodz.git/storage"
+	"github.com/zefrenchwan/nodz.git/storage/disk"
+	"github.com/zefrenchwan/nodz.git/storage/eventlog"
+	"github.com/zefrenchwan/nodz.git/storage/wal"
+)
+
+type node = internal.IdNode
+type link = internal.ValuedLink[node, float64]
+
+// errCrashed is the error of writes after the injected failure
+var errCrashed = errors.New("crashed")
+
+// failure is the write that failed
+type failure struct {
+	// name of the file
+	name string
+	// operation is write, truncate or sync
+	operation string
+}
+
+// memoryFiles is an in memory file system that fails at a given write boundary (write, truncate or sync).
+// Once failed, all writes fail, as if process crashed
+type memoryFiles struct {
+	// files by name
+	files map[string]*memoryFile
+	// boundaries is the number of write boundaries so far
+	boundaries int
+	// failAt is the boundary to fail at, 0 for none
+	failAt int
+	// failed is the failed write, nil if none
+	failed *failure
+}
+
+// memoryFile is a file, with its content and its content as of last sync
+type memoryFile struct {
+	// files is the file system of the file
+	files *memoryFiles
+	// name of the file
+	name string
+	// content is the current content
+	content []byte
+	// synced is the content as of last sync
+	synced []byte
+}
+
+// newMemoryFiles returns an empty file system failing at failAt
+func newMemoryFiles(failAt int) *memoryFiles {
+	return &memoryFiles{files: make(map[string]*memoryFile), failAt: failAt}
+}
+
+// OpenFile returns a file, created if needed
+func (mf *memoryFiles) OpenFile(name string) (storage.File, error) {
+	if _, found := mf.files[name]; !found {
+		mf.files[name] = &memoryFile{files: mf, name: name}
+	}
+
+	return mf.files[name], nil
+}
+
+// Remove removes a file
+func (mf *memoryFiles) Remove(name string) error {
+	delete(mf.files, name)
+	return nil
+}
+
+// boundary counts a write boundary, and returns an error for the failing one and the next ones
+func (mf *memoryFiles) boundary(name, operation string) error {
+	mf.boundaries++
+	if mf.failed != nil {
+		return errCrashed
+	} else if mf.boundaries == mf.failAt {
+		mf.failed = &failure{name: name, operation: operation}
+		return errCrashed
+	}
+
+	return nil
+}
+
+// crash returns the files after a crash: synced content only, or all written content (last write torn)
+func (mf *memoryFiles) crash(keepWrites bool) *memoryFiles {
+	result := newMemoryFiles(0)
+	for name, file := range mf.files {
+		content := file.synced
+		if keepWrites {
+			content = file.content
+		}
+
+		result.files[name] = &memoryFile{files: result, name: name, content: slices.Clone(content), synced: slices.Clone(content)}
+	}
+
+	return result
+}
+
+// ReadAt reads content
+func (mf *memoryFile) ReadAt(content []byte, offset int64) (int, error) {
+	if offset >= int64(len(mf.content)) {
+		return 0, io.EOF
+	}
+
+	read := copy(content, mf.content[offset:])
+	if read < len(content) {
+		return read, io.EOF
+	}
+
+	return read, nil
+}
+
+// WriteAt writes content. Failing write is torn: half of it is written
+func (mf *memoryFile) WriteAt(content []byte, offset int64) (int, error) {
+	err := mf.files.boundary(mf.name, "write")
+	if errors.Is(err, errCrashed) && mf.files.boundaries != mf.files.failAt {
+		return 0, err
+	} else if err != nil {
+		content = content[:len(content)/2]
+	}
+
+	if end := offset + int64(len(content)); end > int64(len(mf.content)) {
+		mf.content = append(mf.content, make([]byte, end-int64(len(mf.content)))...)
+	}
+
+	copy(mf.content[offset:], content)
+	return len(content), err
+}
+
+// Size returns the size of the content
+func (mf *memoryFile) Size() (int64, error) {
+	return int64(len(mf.content)), nil
+}
+
+// Truncate changes the size of the content
+func (mf *memoryFile) Truncate(size int64) error {
+	if err := mf.files.boundary(mf.name, "truncate"); err != nil {
+		return err
+	} else if size <= int64(len(mf.content)) {
+		mf.content = mf.content[:size]
+	} else {
+		mf.content = append(mf.content, make([]byte, size-int64(len(mf.content)))...)
+	}
+
+	return nil
+}
+
+// Sync makes content durable
+func (mf *memoryFile) Sync() error {
+	if err := mf.files.boundary(mf.name, "sync"); err != nil {
+		return err
+	}
+
+	mf.synced = slices.Clone(mf.content)
+	return nil
+}
+
+// Close does nothing
+func (mf *memoryFile) Close() error {
+	return nil
+}
+
+// open opens a durable paged graph with a small cache and frequent checkpoints
+func open(files storage.FileSystem, path string) (*wal.DurableGraph[node, link], error) {
+	codec := storage.ValuedLinkCodec[node, float64]{}
+	store, errStore := disk.Open(path, storage.IdNodeCodec{}, codec, disk.Options[node]{CachePages: 6, FileSystem: files})
+	if errStore != nil {
+		return nil, errStore
+	}
+
+	graph, errGraph := wal.Open(path+"-wal", store, storage.IdNodeCodec{}, codec, wal.Options{CheckpointEvery: 15, FileSystem: files})
+	if errGraph != nil {
+		return nil, errors.Join(errGraph, store.Discard())
+	}
+
+	return graph, nil
+}
+
+// change is a change of a graph
+type change func(graphs.CentralStructureGraph[node, link]) error
+
+// workload returns random changes
+func workload(size int) []change {
+	random := rand.New(rand.NewSource(7))
+	result := make([]change, 0, size)
+	for range size {
+		a := internal.NewIdNode(fmt.Sprintf("node with a long enough id %d", random.Intn(40)))
+		b := internal.NewIdNode(fmt.Sprintf("node with a long enough id %d", random.Intn(40)))
+		l := internal.NewUndirectedValuedLink(a, b, float64(random.Intn(2)))
+		if random.Intn(3) == 0 {
+			l = internal.NewDirectedValuedLink(a, b, 1.0)
+		}
+
+		switch operation := random.Intn(10); {
+		case operation < 6:
+			result = append(result, func(g graphs.CentralStructureGraph[node, link]) error { return g.AddLink(l) })
+		case operation < 8:
+			result = append(result, func(g graphs.CentralStructureGraph[node, link]) error { return g.RemoveLink(l) })
+		case operation < 9:
+			result = append(result, func(g graphs.CentralStructureGraph[node, link]) error { return g.RemoveNode(a) })
+		default:
+			result = append(result, func(g graphs.CentralStructureGraph[node, link]) error { return g.AddNode(a) })
+		}
+	}
+
+	return result
+}
+
+// describe returns the links of a neighborhood as sorted strings
+func describe(t *testing.T, neighborhood graphs.Neighborhood[node, link]) string {
+	t.Helper()
+	links, _ := neighborhood.Links()
+	result := make([]string, 0)
+	for has, err := links.Next(); has || err != nil; has, err = links.Next() {
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		l, _ := links.Value()
+		source, destination := l.Source().Id(), l.Destination().Id()
+		if !l.IsDirected() && source > destination {
+			source, destination = destination, source
+		}
+
+		result = append(result, fmt.Sprintf("%s>%s:%t:%v", source, destination, l.IsDirected(), l.Value()))
+	}
+
+	slices.Sort(result)
+	return fmt.Sprintf("in=%d out=%d und=%d %s", neighborhood.IncomingDegree(), neighborhood.OutgoingDegree(), neighborhood.UndirectedDegree(), strings.Join(result, ","))
+}
+
+// same returns an error if graphs differ
+func same(t *testing.T, expected *local.MapGraph[node, link], graph graphs.CentralStructureGraph[node, link]) error {
+	t.Helper()
+	count := 0
+	nodes, _ := graph.AllNodes()
+	for has, err := nodes.Next(); has || err != nil; has, err = nodes.Next() {
+		if err != nil {
+			return err
+		}
+
+		count++
+	}
+
+	for n := range expected.All() {
+		count--
+		reference, _ := expected.Neighbors(n)
+		if neighbors, err := graph.Neighbors(n); err != nil {
+			return err
+		} else if neighbors == nil {
+			return fmt.Errorf("missing node %s", n.Id())
+		} else if want, got := describe(t, reference), describe(t, neighbors); want != got {
+			return fmt.Errorf("node %s: expected %s, got %s", n.Id(), want, got)
+		}
+	}
+
+	if count != 0 {
+		return fmt.Errorf("nodes count differs by %d", count)
+	}
+
+	return nil
+}
+
+// committed returns true if the change that failed is recovered.
+// A change is committed once its record is synced, and offset of graph then moves: a later failure (store, checkpoint) keeps it.
+// If sync of the log failed, record is there only if writes before the crash are kept
+func committed(failed *failure, keepWrites, moved bool) bool {
+	return moved || (keepWrites && strings.HasSuffix(failed.name, "-wal") && failed.operation == "sync")
+}
+
+func TestDurableGraphRecoversAtEveryWriteBoundary(t *testing.T) {
+	changes := workload(120)
+	for failAt := 1; ; failAt++ {
+		done := false
+		for _, keepWrites := range []bool{false, true} {
+			files := newMemoryFiles(failAt)
+			expected := local.NewMapGraph[node, link]()
+			graph, errOpen := open(files, "graph")
+			for index := 0; errOpen == nil && index < len(changes); index++ {
+				offset := graph.Offset()
+				if err := changes[index](graph); err == nil {
+					changes[index](&expected)
+				} else if !errors.Is(err, errCrashed) {
+					t.Fatalf("boundary %d: unexpected error %s", failAt, err)
+				} else if committed(files.failed, keepWrites, graph.Offset() != offset) {
+					changes[index](&expected)
+					break
+				} else {
+					break
+				}
+			}
+
+			if errOpen == nil && files.failed == nil {
+				if err := graph.Close(); err != nil && !errors.Is(err, errCrashed) {
+					t.Fatal(err)
+				}
+			}
+
+			if files.failed == nil {
+				// no write left to fail
+				done = true
+				break
+			}
+
+			recovered, errRecover := open(files.crash(keepWrites), "graph")
+			if errRecover != nil {
+				t.Fatalf("boundary %d (%v, keep writes %t): recovery failed: %s", failAt, *files.failed, keepWrites, errRecover)
+			} else if err := same(t, &expected, recovered); err != nil {
+				t.Fatalf("boundary %d (%v, keep writes %t): %s", failAt, *files.failed, keepWrites, err)
+			} else if err := recovered.Close(); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if done {
+			if failAt < 100 {
+				t.Fatalf("workload should have many write boundaries, got %d", failAt)
+			}
+
+			return
+		}
+	}
+}
+
+func TestDurableGraphRecoveryIsRestartable(t *testing.T) {
+	changes := workload(40)
+	files := newMemoryFiles(0)
+	expected := local.NewMapGraph[node, link]()
+	graph, errOpen := open(files, "graph")
+	if errOpen != nil {
+		t.Fatal(errOpen)
+	}
+
+	for _, change := range changes {
+		if err := change(graph); err != nil {
+			t.Fatal(err)
+		}
+
+		change(&expected)
+	}
+
+	// crash with pending records, then crash again at each write of the recovery
+	crashed := files.crash(false)
+	for failAt := 1; ; failAt++ {
+		attempt := crashed.crash(false)
+		attempt.failAt = failAt
+		recovered, errRecover := open(attempt, "graph")
+		if errRecover == nil {
+			// reads may also write (evicted pages), no more failure
+			if attempt.failAt = 0; attempt.failed != nil {
+				t.Fatalf("recovery should fail at boundary %d", failAt)
+			} else if err := same(t, &expected, recovered); err != nil {
+				t.Fatal(err)
+			}
+
+			recovered.Close()
+			return
+		}
+
+		final, errFinal := open(attempt.crash(false), "graph")
+		if errFinal != nil {
+			t.Fatalf("boundary %d: %s", failAt, errFinal)
+		} else if err := same(t, &expected, final); err != nil {
+			t.Fatalf("boundary %d: %s", failAt, err)
+		}
+	}
+}
+
+func TestDurableGraphOnDisk(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "graph.nodz")
+	graph, errOpen := open(nil, path)
+	if errOpen != nil {
+		t.Fatal(errOpen)
+	}
+
+	expected := local.NewMapGraph[node, link]()
+	for _, change := range workload(50) {
+		if err := change(graph); err != nil {
+			t.Fatal(err)
+		}
+
+		change(&expected)
+	}
+
+	offset := graph.Offset()
+	if offset != 50 {
+		t.Errorf("unexpected offset %d", offset)
+	} else if err := graph.Close(); err != nil {
+		t.Fatal(err)
+	} else if err := graph.AddNode(internal.NewIdNode("closed")); err == nil {
+		t.Error("closed graph should refuse changes")
+	}
+
+	reopened, errReopen := open(nil, path)
+	if errReopen != nil {
+		t.Fatal(errReopen)
+	}
+
+	defer reopened.Close()
+	if err := same(t, &expected, reopened); err != nil {
+		t.Fatal(err)
+	} else if reopened.Offset() != offset {
+		t.Errorf("offset should continue, got %d", reopened.Offset())
+	}
+}
+
+func TestDurableGraphRefusesChangesAfterFailure(t *testing.T) {
+	files := newMemoryFiles(0)
+	graph, errOpen := open(files, "graph")
+	if errOpen != nil {
+		t.Fatal(errOpen)
+	}
+
+	files.failAt = files.boundaries + 1
+	a := internal.NewIdNode("a")
+	if err := graph.AddNode(a); !errors.Is(err, errCrashed) {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	files.failed, files.failAt = nil, 0
+	if err := graph.AddNode(a); err == nil || !strings.Contains(err.Error(), "reopen") {
+		t.Errorf("failed graph should refuse changes, got %v", err)
+	} else if err := graph.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDurableGraphRefusesCorruptedLog(t *testing.T) {
+	files := newMemoryFiles(0)
+	graph, errOpen := open(files, "graph")
+	if errOpen != nil {
+		t.Fatal(errOpen)
+	}
+
+	// less changes than checkpoint interval: all records stay in log
+	for _, change := range workload(10) {
+		if err := change(graph); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	size := len(files.files["graph-wal"].synced)
+
+	// invalid checksum of last record is a torn write
+	torn := files.crash(false)
+	torn.files["graph-wal"].content[size-1] ^= 0xff
+	if recovered, err := open(torn, "graph"); err != nil {
+		t.Fatalf("torn last record should be dropped, got %v", err)
+	} else if recovered.Offset() != 9 {
+		t.Errorf("expected offset 9, got %d", recovered.Offset())
+	}
+
+	// invalid record followed by valid ones is a corruption
+	corrupted := files.crash(false)
+	corrupted.files["graph-wal"].content[size/2] ^= 0xff
+	if _, err := open(corrupted, "graph"); err == nil || !strings.Contains(err.Error(), "corrupted") {
+		t.Errorf("corrupted log should be refused, got %v", err)
+	} else if current := len(corrupted.files["graph-wal"].content); current != size {
+		t.Errorf("corrupted log should not be truncated, size %d became %d", size, current)
+	}
+}
+
+func TestDurableGraphRefusesInvalidChanges(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "graph.nodz")
+	graph, errOpen := open(nil, path)
+	if errOpen != nil {
+		t.Fatal(errOpen)
+	}
+
+	a := internal.NewIdNode("a")
+	large := internal.NewIdNode(strings.Repeat("x", 2000))
+	if err := graph.AddNode(large); err == nil {
+		t.Fatal("store should refuse a key larger than its limit")
+	} else if err := graph.AddLink(internal.NewDirectedValuedLink(a, large, 1.0)); err == nil {
+		t.Fatal("store should refuse a link to a refused node")
+	} else if err := graph.AddNode(a); err != nil {
+		t.Fatalf("refused change should not fail the graph, got %v", err)
+	} else if graph.Offset() != 1 {
+		t.Errorf("refused changes should not be logged, offset is %d", graph.Offset())
+	} else if err := graph.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, errReopen := open(nil, path)
+	if errReopen != nil {
+		t.Fatal(errReopen)
+	}
+
+	defer reopened.Close()
+	if neighbors, _ := reopened.Neighbors(a); neighbors == nil {
+		t.Error("valid change should be recovered")
+	}
+}
+
+func TestDurableGraphSkipsRefusedRecords(t *testing.T) {
+	files := newMemoryFiles(0)
+	graph, errOpen := open(files, "graph")
+	if errOpen != nil {
+		t.Fatal(errOpen)
+	}
+
+	a := internal.NewIdNode("a")
+	if err := graph.AddNode(a); err != nil {
+		t.Fatal(err)
+	}
+
+	// a record the store refuses, as in logs written without validation
+	crashed := files.crash(false)
+	large, _ := storage.IdNodeCodec{}.EncodeNode(internal.NewIdNode(strings.Repeat("x", 2000)))
+	log := crashed.files["graph-wal"]
+	log.content = eventlog.AppendBinaryRecord(log.content, eventlog.Record{Offset: 2, Operation: eventlog.AddNode, Node: large})
+	log.synced = slices.Clone(log.content)
+
+	recovered, errRecover := open(crashed, "graph")
+	if errRecover != nil {
+		t.Fatalf("refused record should be skipped, got %v", errRecover)
+	}
+
+	defer recovered.Close()
+	if recovered.Offset() != 2 {
+		t.Errorf("expected offset 2, got %d", recovered.Offset())
+	} else if neighbors, _ := recovered.Neighbors(a); neighbors == nil {
+		t.Error("valid record should be applied")
+	}
+}
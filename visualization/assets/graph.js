@@ -0,0 +1,648 @@
+"use strict";
+
+// default drawing of nodes, when mappers give no value
+const defaultColor = "#4a90d9", defaultSize = 5;
+
+// simulation parameters, the defaults of d3-force
+const alphaMin = 0.001, alphaDecay = 1 - Math.pow(alphaMin, 1 / 300), velocityDecay = 0.6;
+const charge = -30, linkDistance = 30, theta2 = 0.81;
+
+const canvas = document.getElementById("canvas");
+const context = canvas.getContext("2d");
+const tooltip = document.getElementById("tooltip");
+const statusBar = document.getElementById("status");
+
+// nodes by id, and links by key. Links refer to their nodes
+const nodes = new Map();
+const links = new Map();
+
+// alpha is the heat of the simulation, it decreases to alphaMin and then layout stops
+let alpha = 1, alphaTarget = 0, paused = false;
+
+// view transforms graph coordinates to canvas ones: scale, then translation
+const view = { k: 1, x: 0, y: 0 };
+
+// dirty is true when canvas should be drawn again
+let dirty = true;
+
+// hovered is the node under the pointer, found is the searched node
+let hovered = null, found = null;
+
+// loading is true while graph is loaded, events received meanwhile are applied after
+let loading = false, pending = [];
+
+// report shows a message in the header
+function report(message) {
+	statusBar.textContent = message;
+}
+
+// linkKey returns the key of a link. Undirected links have the same key in both directions
+function linkKey(link) {
+	if (link.directed) {
+		return link.source + "\u0000>" + link.target;
+	}
+
+	return link.source < link.target ? link.source + "\u0000-" + link.target : link.target + "\u0000-" + link.source;
+}
+
+// placeNode returns a new simulated node, near a given position or on a spiral around the center
+function placeNode(data, near) {
+	const index = nodes.size, radius = 10 * Math.sqrt(0.5 + index), angle = index * Math.PI * (3 - Math.sqrt(5));
+	const node = { id: data.id, x: radius * Math.cos(angle), y: radius * Math.sin(angle), vx: 0, vy: 0, fx: null, fy: null, degree: 0 };
+	if (near) {
+		node.x = near.x + Math.random() * 10 - 5;
+		node.y = near.y + Math.random() * 10 - 5;
+	}
+
+	return node;
+}
+
+// upsertNode adds a node or updates its drawing, and returns it
+function upsertNode(data, near) {
+	let node = nodes.get(data.id);
+	if (!node) {
+		node = placeNode(data, near);
+		nodes.set(data.id, node);
+	}
+
+	node.label = data.label || data.id;
+	node.color = data.color || defaultColor;
+	node.size = data.size > 0 ? data.size : defaultSize;
+	return node;
+}
+
+// addLink adds a link, creating missing extremities, or updates its weight
+function addLink(data) {
+	const key = linkKey(data);
+	const existing = links.get(key);
+	if (existing) {
+		existing.weight = data.weight;
+		return;
+	}
+
+	const source = nodes.get(data.source) || upsertNode({ id: data.source });
+	const target = nodes.get(data.target) || upsertNode({ id: data.target }, source);
+	source.degree++;
+	target.degree++;
+	links.set(key, { source: source, target: target, directed: data.directed, weight: data.weight });
+}
+
+// removeLink removes a link, if any
+function removeLink(data) {
+	const key = linkKey(data);
+	const link = links.get(key);
+	if (link) {
+		link.source.degree--;
+		link.target.degree--;
+		links.delete(key);
+	}
+}
+
+// removeNode removes a node and its links
+function removeNode(id) {
+	for (const [key, link] of links) {
+		if (link.source.id === id || link.target.id === id) {
+			link.source.degree--;
+			link.target.degree--;
+			links.delete(key);
+		}
+	}
+
+	nodes.delete(id);
+	if (hovered && hovered.id === id) {
+		hovered = null;
+		tooltip.hidden = true;
+	}
+}
+
+// apply changes the graph for an event of the server
+function apply(event) {
+	switch (event.type) {
+	case "node-added":
+	case "node-changed":
+		upsertNode(event.node);
+		break;
+	case "node-removed":
+		removeNode(event.node.id);
+		break;
+	case "link-added":
+	case "link-changed":
+		addLink(event.link);
+		break;
+	case "link-removed":
+		removeLink(event.link);
+		break;
+	}
+
+	for (const node of event.nodes || []) {
+		if (nodes.has(node.id)) {
+			upsertNode(node);
+		}
+	}
+
+	reheat(0.3);
+	refreshCounts();
+}
+
+// load replaces the graph with the one of the server, nodes keep their position
+async function load() {
+	loading = true;
+	try {
+		const response = await fetch("graph.json");
+		if (!response.ok) {
+			const content = await response.json().catch(() => null);
+			throw new Error(content && content.error ? content.error : response.statusText);
+		}
+
+		const graph = await response.json();
+		const previous = new Map(nodes);
+		nodes.clear();
+		links.clear();
+		for (const data of graph.nodes) {
+			const node = upsertNode(data);
+			const before = previous.get(data.id);
+			if (before) {
+				Object.assign(node, { x: before.x, y: before.y, vx: before.vx, vy: before.vy, fx: before.fx, fy: before.fy });
+			}
+		}
+
+		for (const data of graph.links) {
+			addLink(data);
+		}
+
+		document.title = graph.title;
+		document.getElementById("title").textContent = graph.title;
+		for (const event of pending) {
+			apply(event);
+		}
+
+		if (previous.size === 0) {
+			fit();
+		}
+
+		reheat(1);
+		refreshCounts();
+		return graph;
+	} finally {
+		loading = false;
+		pending = [];
+	}
+}
+
+// refreshCounts shows the size of the graph, and the ids for the search
+function refreshCounts() {
+	document.getElementById("counts").textContent = nodes.size + " nodes, " + links.size + " links";
+	const ids = document.getElementById("ids");
+	if (ids.children.length !== nodes.size && nodes.size <= 5000) {
+		ids.replaceChildren();
+		for (const id of nodes.keys()) {
+			const option = document.createElement("option");
+			option.value = id;
+			ids.appendChild(option);
+		}
+	}
+}
+
+// listen receives the changes of the graph, and loads it again at each connection
+function listen() {
+	const source = new EventSource("events");
+	source.addEventListener("open", () => {
+		report("live");
+		pending = [];
+		load().catch((err) => report(err.message));
+	});
+
+	source.addEventListener("message", (message) => {
+		const event = JSON.parse(message.data);
+		if (loading) {
+			pending.push(event);
+		} else {
+			apply(event);
+		}
+	});
+
+	source.addEventListener("error", () => report("disconnected, retrying"));
+}
+
+// reheat restarts the simulation
+function reheat(value) {
+	alpha = Math.max(alpha, value);
+	dirty = true;
+}
+
+// jiggle returns a tiny random value, to separate nodes at the same position
+function jiggle() {
+	return (Math.random() - 0.5) * 1e-6;
+}
+
+// quadtree returns a Barnes-Hut tree of the nodes: quads have a total charge and its center
+function quadtree(list) {
+	let x0 = Infinity, y0 = Infinity, x1 = -Infinity, y1 = -Infinity;
+	for (const node of list) {
+		x0 = Math.min(x0, node.x);
+		y0 = Math.min(y0, node.y);
+		x1 = Math.max(x1, node.x);
+		y1 = Math.max(y1, node.y);
+	}
+
+	const root = { x0: x0, y0: y0, width: Math.max(x1 - x0, y1 - y0, 1), node: null, children: null, charge: 0, x: 0, y: 0 };
+	for (const node of list) {
+		insert(root, node, 0);
+	}
+
+	accumulate(root);
+	return root;
+}
+
+// insert adds a node to a quad. Depth is limited, so that nodes at the same position share a leaf
+function insert(quad, node, depth) {
+	if (quad.children === null && (quad.node === null || depth > 32)) {
+		node.next = quad.node;
+		quad.node = node;
+		return;
+	}
+
+	if (quad.children === null) {
+		const previous = quad.node;
+		quad.node = null;
+		quad.children = [null, null, null, null];
+		for (let current = previous; current !== null;) {
+			const next = current.next;
+			insertChild(quad, current, depth);
+			current = next;
+		}
+	}
+
+	insertChild(quad, node, depth);
+}
+
+// insertChild adds a node to the child quad containing it
+function insertChild(quad, node, depth) {
+	const half = quad.width / 2;
+	const right = node.x >= quad.x0 + half ? 1 : 0, bottom = node.y >= quad.y0 + half ? 1 : 0;
+	const index = right + 2 * bottom;
+	if (quad.children[index] === null) {
+		quad.children[index] = { x0: quad.x0 + right * half, y0: quad.y0 + bottom * half, width: half, node: null, children: null, charge: 0, x: 0, y: 0 };
+	}
+
+	insert(quad.children[index], node, depth + 1);
+}
+
+// accumulate computes the charge and center of the quads
+function accumulate(quad) {
+	let total = 0, x = 0, y = 0;
+	if (quad.children === null) {
+		for (let node = quad.node; node !== null; node = node.next) {
+			total += charge;
+			x += charge * node.x;
+			y += charge * node.y;
+		}
+	} else {
+		for (const child of quad.children) {
+			if (child !== null) {
+				accumulate(child);
+				total += child.charge;
+				x += child.charge * child.x;
+				y += child.charge * child.y;
+			}
+		}
+	}
+
+	quad.charge = total;
+	quad.x = total === 0 ? 0 : x / total;
+	quad.y = total === 0 ? 0 : y / total;
+}
+
+// repulse applies the charge of a quad to a node, approximating far quads by their center
+function repulse(quad, node) {
+	let dx = quad.x - node.x, dy = quad.y - node.y, distance2 = dx * dx + dy * dy;
+	if (quad.children !== null && quad.width * quad.width / theta2 < distance2) {
+		const strength = quad.charge * alpha / Math.max(distance2, 1);
+		node.vx += dx * strength;
+		node.vy += dy * strength;
+		return;
+	}
+
+	if (quad.children !== null) {
+		for (const child of quad.children) {
+			if (child !== null) {
+				repulse(child, node);
+			}
+		}
+
+		return;
+	}
+
+	for (let other = quad.node; other !== null; other = other.next) {
+		if (other === node) {
+			continue;
+		}
+
+		dx = other.x - node.x || jiggle();
+		dy = other.y - node.y || jiggle();
+		distance2 = Math.max(dx * dx + dy * dy, 1);
+		node.vx += dx * charge * alpha / distance2;
+		node.vy += dy * charge * alpha / distance2;
+	}
+}
+
+// tick moves the nodes one step: charge, links and centering forces, as d3-force does
+function tick() {
+	alpha += (alphaTarget - alpha) * alphaDecay;
+	const list = Array.from(nodes.values());
+	if (list.length === 0) {
+		return;
+	}
+
+	const root = quadtree(list);
+	for (const node of list) {
+		repulse(root, node);
+	}
+
+	for (const link of links.values()) {
+		const source = link.source, target = link.target;
+		if (source === target) {
+			continue;
+		}
+
+		let dx = target.x + target.vx - source.x - source.vx || jiggle();
+		let dy = target.y + target.vy - source.y - source.vy || jiggle();
+		const distance = Math.sqrt(dx * dx + dy * dy);
+		const strength = 1 / Math.max(1, Math.min(source.degree, target.degree));
+		const shift = (distance - linkDistance) / distance * alpha * strength;
+		const bias = source.degree / Math.max(1, source.degree + target.degree);
+		dx *= shift;
+		dy *= shift;
+		target.vx -= dx * bias;
+		target.vy -= dy * bias;
+		source.vx += dx * (1 - bias);
+		source.vy += dy * (1 - bias);
+	}
+
+	let meanX = 0, meanY = 0;
+	for (const node of list) {
+		if (node.fx !== null) {
+			node.x = node.fx;
+			node.y = node.fy;
+			node.vx = node.vy = 0;
+		} else {
+			node.vx *= velocityDecay;
+			node.vy *= velocityDecay;
+			node.x += node.vx;
+			node.y += node.vy;
+		}
+
+		meanX += node.x / list.length;
+		meanY += node.y / list.length;
+	}
+
+	for (const node of list) {
+		node.x -= meanX;
+		node.y -= meanY;
+		if (node.fx !== null) {
+			node.fx -= meanX;
+			node.fy -= meanY;
+		}
+	}
+
+	dirty = true;
+}
+
+// resize matches the canvas buffer with its size on screen
+function resize() {
+	const ratio = window.devicePixelRatio || 1;
+	canvas.width = Math.round(canvas.clientWidth * ratio);
+	canvas.height = Math.round(canvas.clientHeight * ratio);
+	dirty = true;
+}
+
+// fit scales and centers the view on the graph
+function fit() {
+	let x0 = Infinity, y0 = Infinity, x1 = -Infinity, y1 = -Infinity;
+	for (const node of nodes.values()) {
+		x0 = Math.min(x0, node.x - node.size);
+		y0 = Math.min(y0, node.y - node.size);
+		x1 = Math.max(x1, node.x + node.size);
+		y1 = Math.max(y1, node.y + node.size);
+	}
+
+	const width = canvas.clientWidth, height = canvas.clientHeight;
+	if (nodes.size === 0) {
+		Object.assign(view, { k: 1, x: width / 2, y: height / 2 });
+	} else {
+		const k = Math.min(4, 0.9 * Math.min(width / (x1 - x0 || 1), height / (y1 - y0 || 1)));
+		Object.assign(view, { k: k, x: width / 2 - k * (x0 + x1) / 2, y: height / 2 - k * (y0 + y1) / 2 });
+	}
+
+	dirty = true;
+}
+
+// toGraph returns the graph coordinates of a pointer event
+function toGraph(event) {
+	const bounds = canvas.getBoundingClientRect();
+	return { x: (event.clientX - bounds.left - view.x) / view.k, y: (event.clientY - bounds.top - view.y) / view.k };
+}
+
+// nodeAt returns the node at a graph position, the last drawn first
+function nodeAt(position) {
+	let result = null;
+	for (const node of nodes.values()) {
+		const dx = node.x - position.x, dy = node.y - position.y, radius = node.size + 2 / view.k;
+		if (dx * dx + dy * dy <= radius * radius) {
+			result = node;
+		}
+	}
+
+	return result;
+}
+
+// draw paints the graph on the canvas
+function draw() {
+	const ratio = window.devicePixelRatio || 1;
+	context.setTransform(1, 0, 0, 1, 0, 0);
+	context.clearRect(0, 0, canvas.width, canvas.height);
+	context.setTransform(ratio * view.k, 0, 0, ratio * view.k, ratio * view.x, ratio * view.y);
+
+	context.strokeStyle = "#999";
+	context.fillStyle = "#999";
+	for (const link of links.values()) {
+		const source = link.source, target = link.target;
+		context.lineWidth = Math.min(6, Math.max(0.5, Math.sqrt(Math.abs(link.weight)))) / Math.sqrt(view.k);
+		context.beginPath();
+		context.moveTo(source.x, source.y);
+		context.lineTo(target.x, target.y);
+		context.stroke();
+		if (link.directed && source !== target) {
+			drawArrow(source, target);
+		}
+	}
+
+	context.lineWidth = 1 / view.k;
+	context.strokeStyle = "white";
+	for (const node of nodes.values()) {
+		context.beginPath();
+		context.arc(node.x, node.y, node.size, 0, 2 * Math.PI);
+		context.fillStyle = node.color;
+		context.fill();
+		context.stroke();
+	}
+
+	// labels are drawn when they may be read, and always for hovered and searched nodes
+	context.fillStyle = "#222";
+	context.font = 12 / view.k + "px system-ui, sans-serif";
+	for (const node of nodes.values()) {
+		if (node === hovered || node === found || (nodes.size <= 100 && view.k >= 0.8) || node.size * view.k >= 12) {
+			context.fillText(node.label, node.x + node.size + 2 / view.k, node.y + 4 / view.k);
+		}
+	}
+
+	if (found) {
+		context.strokeStyle = "#e15759";
+		context.lineWidth = 3 / view.k;
+		context.beginPath();
+		context.arc(found.x, found.y, found.size + 4 / view.k, 0, 2 * Math.PI);
+		context.stroke();
+	}
+}
+
+// drawArrow draws the head of a directed link, on the border of the target
+function drawArrow(source, target) {
+	const dx = target.x - source.x, dy = target.y - source.y, distance = Math.sqrt(dx * dx + dy * dy) || 1;
+	const ux = dx / distance, uy = dy / distance, length = 8 / Math.sqrt(view.k);
+	const x = target.x - ux * target.size, y = target.y - uy * target.size;
+	context.beginPath();
+	context.moveTo(x, y);
+	context.lineTo(x - ux * length - uy * length / 2, y - uy * length + ux * length / 2);
+	context.lineTo(x - ux * length + uy * length / 2, y - uy * length - ux * length / 2);
+	context.closePath();
+	context.fill();
+}
+
+// frame runs the simulation and draws the graph when needed
+function frame() {
+	if (!paused && (alpha >= alphaMin || alphaTarget > 0)) {
+		tick();
+	}
+
+	if (dirty) {
+		dirty = false;
+		draw();
+	}
+
+	requestAnimationFrame(frame);
+}
+
+// dragged is the node or the view moved by the pointer
+let dragged = null;
+
+canvas.addEventListener("pointerdown", (event) => {
+	const position = toGraph(event);
+	const node = nodeAt(position);
+	canvas.setPointerCapture(event.pointerId);
+	canvas.classList.add("dragging");
+	if (node) {
+		node.fx = node.x;
+		node.fy = node.y;
+		alphaTarget = 0.3;
+		reheat(0.3);
+		dragged = { node: node };
+	} else {
+		dragged = { x: event.clientX - view.x, y: event.clientY - view.y };
+	}
+});
+
+canvas.addEventListener("pointermove", (event) => {
+	const position = toGraph(event);
+	if (dragged && dragged.node) {
+		dragged.node.fx = position.x;
+		dragged.node.fy = position.y;
+	} else if (dragged) {
+		view.x = event.clientX - dragged.x;
+		view.y = event.clientY - dragged.y;
+		dirty = true;
+	}
+
+	const node = dragged && dragged.node ? dragged.node : nodeAt(position);
+	if (node !== hovered) {
+		hovered = node;
+		dirty = true;
+	}
+
+	if (node) {
+		tooltip.textContent = node.label + (node.label !== node.id ? "\nid: " + node.id : "") + "\nlinks: " + node.degree;
+		tooltip.style.left = event.clientX + 12 + "px";
+		tooltip.style.top = event.clientY + 12 + "px";
+	}
+
+	tooltip.hidden = node === null;
+});
+
+// release ends a drag. Dragged nodes are released too, so that layout moves them again
+function release() {
+	if (dragged && dragged.node) {
+		dragged.node.fx = null;
+		dragged.node.fy = null;
+		alphaTarget = 0;
+	}
+
+	dragged = null;
+	canvas.classList.remove("dragging");
+}
+
+canvas.addEventListener("pointerup", release);
+canvas.addEventListener("pointercancel", release);
+canvas.addEventListener("pointerleave", () => {
+	tooltip.hidden = true;
+	hovered = null;
+	dirty = true;
+});
+
+canvas.addEventListener("wheel", (event) => {
+	event.preventDefault();
+	const bounds = canvas.getBoundingClientRect();
+	const x = event.clientX - bounds.left, y = event.clientY - bounds.top;
+	const k = Math.min(20, Math.max(0.02, view.k * Math.pow(2, -event.deltaY * (event.deltaMode ? 0.05 : 0.002))));
+	view.x = x - (x - view.x) * k / view.k;
+	view.y = y - (y - view.y) * k / view.k;
+	view.k = k;
+	dirty = true;
+}, { passive: false });
+
+document.getElementById("search").addEventListener("input", (event) => {
+	found = nodes.get(event.target.value.trim()) || null;
+	if (found) {
+		const width = canvas.clientWidth, height = canvas.clientHeight;
+		view.x = width / 2 - view.k * found.x;
+		view.y = height / 2 - view.k * found.y;
+	}
+
+	dirty = true;
+});
+
+document.getElementById("fit").addEventListener("click", fit);
+
+document.getElementById("pause").addEventListener("click", (event) => {
+	paused = !paused;
+	event.target.textContent = paused ? "Resume" : "Pause";
+	if (!paused) {
+		reheat(0.3);
+	}
+});
+
+document.getElementById("reload").addEventListener("click", () => {
+	load().catch((err) => report(err.message));
+});
+
+window.addEventListener("resize", resize);
+
+// initial state: graph is loaded once, or at each connection to the events for live graphs
+resize();
+fit();
+load().then((graph) => {
+	if (graph.live) {
+		listen();
+	} else {
+		report("static graph");
+	}
+}).catch((err) => report(err.message));
+requestAnimationFrame(frame);
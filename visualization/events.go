@@ -0,0 +1,218 @@
+package visualization
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/zefrenchwan/nodz.git/graphs"
+	"github.com/zefrenchwan/nodz.git/internal/httpjson"
+)
+
+// clientBuffer is the number of events waiting to be sent to a page.
+// Slower pages are disconnected, they reconnect and reload the graph
+const clientBuffer = 256
+
+// keepAlive is the period of comments sent to idle pages, so that proxies keep the connection
+const keepAlive = 15 * time.Second
+
+// Event is a change of the graph, as sent to pages
+type Event struct {
+	// Sequence is the sequence of the graph event
+	Sequence uint64 `json:"sequence"`
+	// Type is one of node-added, node-removed, node-changed, link-added, link-removed, link-changed
+	Type string `json:"type"`
+	// Node is the node of node events
+	Node *Node `json:"node,omitempty"`
+	// Link is the link of link events
+	Link *Link `json:"link,omitempty"`
+	// Links are the links removed with a node
+	Links []Link `json:"links,omitempty"`
+	// Nodes are the nodes whose links changed, drawn again because mappers may depend on links
+	Nodes []Node `json:"nodes,omitempty"`
+}
+
+// newEvent returns the event to send for a graph event
+func (s *Server[N, L]) newEvent(event graphs.GraphEvent[N, L]) (Event, error) {
+	result := Event{Sequence: event.Sequence}
+	switch {
+	case event.Type == graphs.NodeAdded:
+		result.Type = "node-added"
+	case event.Type == graphs.NodeRemoved:
+		result.Type = "node-removed"
+	case event.Type == graphs.LinkAdded:
+		result.Type = "link-added"
+	case event.Type == graphs.LinkRemoved:
+		result.Type = "link-removed"
+	case event.Type == graphs.PropertyChanged && event.OnLink:
+		result.Type = "link-changed"
+	case event.Type == graphs.PropertyChanged:
+		result.Type = "node-changed"
+	default:
+		return result, fmt.Errorf("unknown event type %s", event.Type)
+	}
+
+	// extremities of changed links are drawn again, once each
+	changed := make([]N, 0)
+	if event.Type == graphs.LinkAdded || event.Type == graphs.LinkRemoved || event.OnLink {
+		drawn, errDrawn := s.drawLink(event.Link)
+		if errDrawn != nil {
+			return result, errDrawn
+		}
+
+		result.Link = &drawn
+		changed = append(changed, event.Link.Source(), event.Link.Destination())
+	} else {
+		drawn, errDrawn := s.drawNode(event.Node)
+		if errDrawn != nil {
+			return result, errDrawn
+		}
+
+		result.Node = &drawn
+	}
+
+	for _, link := range event.CascadedLinks {
+		drawn, errDrawn := s.drawLink(link)
+		if errDrawn != nil {
+			return result, errDrawn
+		}
+
+		result.Links = append(result.Links, drawn)
+		for _, extremity := range []N{link.Source(), link.Destination()} {
+			if !extremity.SameNode(event.Node) {
+				changed = append(changed, extremity)
+			}
+		}
+	}
+
+	drawnKeys := make(map[string]bool)
+	for _, node := range changed {
+		if drawn, errDrawn := s.drawNode(node); errDrawn != nil {
+			return result, errDrawn
+		} else if !drawnKeys[drawn.Id] {
+			drawnKeys[drawn.Id] = true
+			result.Nodes = append(result.Nodes, drawn)
+		}
+	}
+
+	return result, nil
+}
+
+// publish sends a graph event to the pages. Events that cannot be drawn are ignored
+func (s *Server[N, L]) publish(event graphs.GraphEvent[N, L]) {
+	if drawn, errDrawn := s.newEvent(event); errDrawn != nil {
+		return
+	} else if content, errContent := json.Marshal(drawn); errContent == nil {
+		s.clients.send([]byte(fmt.Sprintf("id: %d\ndata: %s\n\n", event.Sequence, content)))
+	}
+}
+
+// events streams the changes of the graph as server-sent events, until page leaves or server is closed
+func (s *Server[N, L]) events(w http.ResponseWriter, r *http.Request) {
+	flusher, canFlush := w.(http.Flusher)
+	if s.observable == nil {
+		httpjson.WriteError(w, httpjson.NewStatusError(http.StatusNotFound, "graph does not publish its changes"))
+		return
+	} else if !canFlush {
+		httpjson.WriteError(w, httpjson.NewStatusError(http.StatusInternalServerError, "streaming is not supported"))
+		return
+	}
+
+	messages := s.clients.add()
+	if messages == nil {
+		httpjson.WriteError(w, httpjson.NewStatusError(http.StatusServiceUnavailable, "server is closed"))
+		return
+	}
+
+	defer s.clients.remove(messages)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+
+	ticker := time.NewTicker(keepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+			fmt.Fprint(w, ": keep alive\n\n")
+			flusher.Flush()
+		case message, open := <-messages:
+			if !open {
+				return
+			}
+
+			w.Write(message)
+			flusher.Flush()
+		}
+	}
+}
+
+// broadcaster sends messages to all registered clients
+type broadcaster struct {
+	// lock protects clients and closed
+	lock sync.Mutex
+	// clients are the channels of the registered clients
+	clients map[chan []byte]bool
+	// closed is true once no client may register
+	closed bool
+}
+
+// newBroadcaster returns a broadcaster with no client
+func newBroadcaster() *broadcaster {
+	return &broadcaster{clients: make(map[chan []byte]bool)}
+}
+
+// add registers a client and returns its channel, nil if broadcaster is closed
+func (b *broadcaster) add() chan []byte {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.closed {
+		return nil
+	}
+
+	messages := make(chan []byte, clientBuffer)
+	b.clients[messages] = true
+	return messages
+}
+
+// remove unregisters a client and closes its channel, if not done yet
+func (b *broadcaster) remove(messages chan []byte) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.clients[messages] {
+		delete(b.clients, messages)
+		close(messages)
+	}
+}
+
+// send sends a message to all clients. Clients with a full buffer are removed
+func (b *broadcaster) send(message []byte) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	for messages := range b.clients {
+		select {
+		case messages <- message:
+		default:
+			delete(b.clients, messages)
+			close(messages)
+		}
+	}
+}
+
+// close removes all clients, and refuses new ones
+func (b *broadcaster) close() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.closed = true
+	for messages := range b.clients {
+		delete(b.clients, messages)
+		close(messages)
+	}
+}
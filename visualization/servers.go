@@ -0,0 +1,336 @@
+package visualization
+
+import (
+	"bufio"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"sync"
+
+	"github.com/zefrenchwan/nodz.git/graphs"
+	"github.com/zefrenchwan/nodz.git/internal/httpjson"
+)
+
+// defaultEventsBuffer is the size of the buffer of graph events, when not set
+const defaultEventsBuffer = 1024
+
+// Options configures the server
+type Options[N graphs.Node, L graphs.Link[N]] struct {
+	// Title is the title of the page, "nodz" if empty
+	Title string
+	// Key returns a unique key per node. If nil, nodes should implement WithId, and key is the id
+	Key func(N) string
+	// Label maps nodes to their label, nil for keys
+	Label LabelMapper[N]
+	// Color maps nodes to their colour, nil for default colour
+	Color ColorMapper[N]
+	// Size maps nodes to their radius, nil for default size
+	Size SizeMapper[N]
+	// Weight returns the weight of a link, nil for 1
+	Weight func(L) float64
+	// EventsBuffer is the number of graph events waiting to be sent, 1024 if not positive
+	EventsBuffer int
+}
+
+// Server is a http handler serving a force directed drawing of a graph.
+// If graph is observable, its changes are pushed to pages as server-sent events.
+// Graph is read while it changes, so it should be safe for concurrent use (local.SyncGraph, for instance)
+type Server[N graphs.Node, L graphs.Link[N]] struct {
+	// graph to draw
+	graph graphs.CentralStructureGraph[N, L]
+	// options of the server
+	options Options[N, L]
+	// mux routes requests to handlers
+	mux *http.ServeMux
+	// observable is the graph as observable, nil if graph does not publish its changes
+	observable graphs.Observable[N, L]
+	// observerId is the id of the subscription to graph events
+	observerId int
+	// clients receive the events of the graph
+	clients *broadcaster
+	// closeOnce ensures subscription is removed once
+	closeOnce sync.Once
+}
+
+//go:embed assets
+var assets embed.FS
+
+// NewServer returns a server drawing g.
+// If g implements graphs.Observable, server subscribes to its changes until Close is called
+func NewServer[N graphs.Node, L graphs.Link[N]](g graphs.CentralStructureGraph[N, L], options Options[N, L]) (*Server[N, L], error) {
+	if g == nil {
+		return nil, errors.New("nil graph")
+	} else if options.EventsBuffer <= 0 {
+		options.EventsBuffer = defaultEventsBuffer
+	}
+
+	if options.Title == "" {
+		options.Title = "nodz"
+	}
+
+	server := &Server[N, L]{
+		graph:   g,
+		options: options,
+		mux:     http.NewServeMux(),
+		clients: newBroadcaster(),
+	}
+
+	if observable, ok := g.(graphs.Observable[N, L]); ok {
+		id, errSubscribe := observable.Subscribe(server.publish, graphs.ObserverOptions[N]{BufferSize: options.EventsBuffer})
+		if errSubscribe != nil {
+			return nil, errSubscribe
+		}
+
+		server.observable = observable
+		server.observerId = id
+	}
+
+	content, _ := fs.Sub(assets, "assets")
+	files := http.FileServerFS(content)
+	server.mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			httpjson.WriteError(w, httpjson.NewStatusError(http.StatusMethodNotAllowed, "method %s not allowed for %s", r.Method, r.URL.Path))
+		} else {
+			files.ServeHTTP(w, r)
+		}
+	})
+
+	server.mux.HandleFunc("GET /graph.json", server.snapshot)
+	server.mux.HandleFunc("GET /events", server.events)
+	return server, nil
+}
+
+// ServeHTTP dispatches the request to the matching handler
+func (s *Server[N, L]) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Close unsubscribes from the graph, and ends the events streams.
+// Call it before shutting down the http server, because events streams never end otherwise
+func (s *Server[N, L]) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		if s.observable != nil {
+			err = s.observable.Unsubscribe(s.observerId)
+		}
+
+		s.clients.close()
+	})
+
+	return err
+}
+
+// Node is a node as drawn in the page
+type Node struct {
+	// Id is the key of the node
+	Id string `json:"id"`
+	// Label is the label of the node, if different from id
+	Label string `json:"label,omitempty"`
+	// Color is the css colour of the node, empty for default
+	Color string `json:"color,omitempty"`
+	// Size is the radius of the node, 0 for default
+	Size float64 `json:"size,omitempty"`
+}
+
+// Link is a link as drawn in the page
+type Link struct {
+	// Source is the key of the source node
+	Source string `json:"source"`
+	// Target is the key of the destination node
+	Target string `json:"target"`
+	// Directed is true for directed links
+	Directed bool `json:"directed"`
+	// Weight is the weight of the link
+	Weight float64 `json:"weight"`
+}
+
+// Graph is the json content of a graph, as d3 force simulations expect it (nodes, then links with ids).
+// It is streamed node per node, so this type is a description of the content more than a value to build
+type Graph struct {
+	// Title is the title of the page
+	Title string `json:"title"`
+	// Live is true if changes are pushed as events
+	Live bool `json:"live"`
+	// Nodes are the nodes of the graph
+	Nodes []Node `json:"nodes"`
+	// Links are the links of the graph
+	Links []Link `json:"links"`
+}
+
+// snapshot streams the current nodes and links of the graph.
+// Errors after the first written node cut the content, and the page reports invalid json
+func (s *Server[N, L]) snapshot(w http.ResponseWriter, r *http.Request) {
+	nodes, errNodes := s.graph.AllNodes()
+	if errNodes != nil {
+		httpjson.WriteError(w, errNodes)
+		return
+	}
+
+	title, _ := json.Marshal(s.options.Title)
+	w.Header().Set("Content-Type", "application/json")
+	counter := &countingWriter{writer: w}
+	writer := bufio.NewWriter(counter)
+	fmt.Fprintf(writer, `{"title":%s,"live":%t,"nodes":[`, title, s.observable != nil)
+
+	// fail writes the error if nothing was sent yet, and cuts the content otherwise
+	fail := func(err error) {
+		if counter.written == 0 {
+			httpjson.WriteError(w, err)
+		}
+	}
+
+	// links are collected while nodes are written, to go through the graph once
+	links := make([]Link, 0)
+	first := true
+	for node, errNode := range graphs.All(graphs.WithContext(r.Context(), nodes)) {
+		if errNode != nil {
+			fail(errNode)
+			return
+		}
+
+		drawn, errDrawn := s.drawNode(node)
+		if errDrawn != nil {
+			fail(errDrawn)
+			return
+		}
+
+		nodeLinks, errLinks := s.sourceLinks(node)
+		if errLinks != nil {
+			fail(errLinks)
+			return
+		}
+
+		links = append(links, nodeLinks...)
+		content, _ := json.Marshal(drawn)
+		if !first {
+			writer.WriteByte(',')
+		}
+
+		first = false
+		writer.Write(content)
+	}
+
+	if r.Context().Err() != nil {
+		return
+	}
+
+	writer.WriteString(`],"links":[`)
+	for index, link := range links {
+		content, _ := json.Marshal(link)
+		if index != 0 {
+			writer.WriteByte(',')
+		}
+
+		writer.Write(content)
+	}
+
+	writer.WriteString("]}\n")
+	writer.Flush()
+}
+
+// countingWriter counts the bytes written to a writer
+type countingWriter struct {
+	// writer receives the bytes
+	writer io.Writer
+	// written is the number of bytes written so far
+	written int
+}
+
+// Write writes to the writer, and counts the written bytes
+func (cw *countingWriter) Write(content []byte) (int, error) {
+	written, err := cw.writer.Write(content)
+	cw.written += written
+	return written, err
+}
+
+// key returns the key of a node
+func (s *Server[N, L]) key(node N) (string, error) {
+	if s.options.Key != nil {
+		return s.options.Key(node), nil
+	} else if withId, ok := any(node).(graphs.WithId); ok {
+		return withId.Id(), nil
+	}
+
+	return "", errors.New("node has no id, and no key function")
+}
+
+// drawNode applies the mappers to a node
+func (s *Server[N, L]) drawNode(node N) (Node, error) {
+	key, errKey := s.key(node)
+	if errKey != nil {
+		return Node{}, errKey
+	}
+
+	result := Node{Id: key}
+	if s.options.Label != nil {
+		if label := s.options.Label(node); label != key {
+			result.Label = label
+		}
+	}
+
+	if s.options.Color != nil {
+		result.Color = s.options.Color(node)
+	}
+
+	if s.options.Size != nil {
+		result.Size = max(0, s.options.Size(node))
+	}
+
+	return result, nil
+}
+
+// drawLink returns the drawing of a link
+func (s *Server[N, L]) drawLink(link L) (Link, error) {
+	source, errSource := s.key(link.Source())
+	if errSource != nil {
+		return Link{}, errSource
+	}
+
+	target, errTarget := s.key(link.Destination())
+	if errTarget != nil {
+		return Link{}, errTarget
+	}
+
+	result := Link{Source: source, Target: target, Directed: link.IsDirected(), Weight: 1}
+	if s.options.Weight != nil {
+		result.Weight = s.options.Weight(link)
+	}
+
+	return result, nil
+}
+
+// sourceLinks returns the links having node as their source.
+// Each link has one source, so that links of all nodes are all the links, with no duplicate
+func (s *Server[N, L]) sourceLinks(node N) ([]Link, error) {
+	neighbors, errNeighbors := s.graph.Neighbors(node)
+	if errNeighbors != nil {
+		return nil, errNeighbors
+	} else if neighbors == nil {
+		// node was removed meanwhile
+		return nil, nil
+	}
+
+	links, errLinks := neighbors.Links()
+	if errLinks != nil {
+		return nil, errLinks
+	}
+
+	result := make([]Link, 0)
+	for link, errLink := range graphs.All(links) {
+		if errLink != nil {
+			return nil, errLink
+		} else if !link.Source().SameNode(node) {
+			continue
+		} else if drawn, errDrawn := s.drawLink(link); errDrawn != nil {
+			return nil, errDrawn
+		} else {
+			result = append(result, drawn)
+		}
+	}
+
+	return result, nil
+}
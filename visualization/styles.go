@@ -0,0 +1,47 @@
+package visualization
+
+import (
+	"hash/fnv"
+	"math"
+
+	"github.com/zefrenchwan/nodz.git/graphs"
+)
+
+// LabelMapper returns the label of a node. Empty label means the key of the node
+type LabelMapper[N graphs.Node] func(node N) string
+
+// ColorMapper returns the css colour of a node, such as "#4a90d9" or "teal". Empty colour means the default one
+type ColorMapper[N graphs.Node] func(node N) string
+
+// SizeMapper returns the radius of a node, in pixels. Non positive size means the default one
+type SizeMapper[N graphs.Node] func(node N) float64
+
+// palette is a list of colours easy to distinguish
+var palette = []string{
+	"#4e79a7", "#f28e2b", "#e15759", "#76b7b2", "#59a14f",
+	"#edc948", "#b07aa1", "#ff9da7", "#9c755f", "#bab0ac",
+}
+
+// CategoryColors returns a colour mapper that gives the same colour to nodes of the same category.
+// Colours come from a palette of ten colours, so different categories may share a colour
+func CategoryColors[N graphs.Node](category func(N) string) ColorMapper[N] {
+	return func(node N) string {
+		hash := fnv.New32a()
+		hash.Write([]byte(category(node)))
+		return palette[hash.Sum32()%uint32(len(palette))]
+	}
+}
+
+// DegreeSizes returns a size mapper growing with the square root of the degree of the node in g.
+// Degree is read at each call, so that sizes follow changes of the graph
+func DegreeSizes[N graphs.Node, L graphs.Link[N]](g graphs.CentralStructureGraph[N, L], minimum, maximum float64) SizeMapper[N] {
+	return func(node N) float64 {
+		neighbors, errNeighbors := g.Neighbors(node)
+		if errNeighbors != nil || neighbors == nil {
+			return minimum
+		}
+
+		degree := neighbors.IncomingDegree() + neighbors.OutgoingDegree() + neighbors.UndirectedDegree()
+		return math.Min(maximum, minimum+math.Sqrt(float64(degree)))
+	}
+}
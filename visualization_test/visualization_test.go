@@ -0,0 +1,239 @@
+package visualization_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zefrenchwan/nodz.git/graphs"
+	"github.com/zefrenchwan/nodz.git/internal"
+	"github.com/zefrenchwan/nodz.git/internal/local"
+	"github.com/zefrenchwan/nodz.git/visualization"
+)
+
+type node = internal.IdNode
+type link = internal.ValuedLink[node, float64]
+
+// call sends a request to handler, and returns status and body
+func call(handler http.Handler, method, path string) (int, string) {
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(method, path, nil))
+	content, _ := io.ReadAll(recorder.Result().Body)
+	return recorder.Code, string(content)
+}
+
+func TestServerPage(t *testing.T) {
+	base := local.NewMapGraph[node, link]()
+	server, errServer := visualization.NewServer(&base, visualization.Options[node, link]{})
+	if errServer != nil {
+		t.Fatal(errServer)
+	}
+
+	defer server.Close()
+	for _, path := range []string{"/", "/graph.js", "/graph.css"} {
+		status, content := call(server, http.MethodGet, path)
+		if status != http.StatusOK || content == "" {
+			t.Errorf("%s: unexpected status %d", path, status)
+		} else if strings.Contains(content, "http://") || strings.Contains(content, "https://") || strings.Contains(content, "//cdn") {
+			t.Errorf("%s should not load external resources", path)
+		}
+	}
+
+	if status, _ := call(server, http.MethodPost, "/"); status != http.StatusMethodNotAllowed {
+		t.Errorf("unexpected status %d", status)
+	} else if status, content := call(server, http.MethodGet, "/events"); status != http.StatusNotFound || !strings.Contains(content, "does not publish") {
+		t.Errorf("static graph should have no events, got %d: %s", status, content)
+	}
+}
+
+func TestServerSnapshot(t *testing.T) {
+	base := local.NewMapGraph[node, link]()
+	a, b, c, d := internal.NewIdNode("a"), internal.NewIdNode("b"), internal.NewIdNode("c"), internal.NewIdNode("d")
+	base.AddLink(internal.NewUndirectedValuedLink(a, b, 2.0))
+	base.AddLink(internal.NewUndirectedValuedLink(b, c, 1.0))
+	base.AddLink(internal.NewDirectedValuedLink(c, a, 3.0))
+	base.AddNode(d)
+
+	server, errServer := visualization.NewServer(&base, visualization.Options[node, link]{
+		Title:  "sample",
+		Label:  func(n node) string { return strings.ToUpper(n.Id()) },
+		Color:  visualization.CategoryColors(func(n node) string { return n.Id() }),
+		Size:   visualization.DegreeSizes(&base, 4, 10),
+		Weight: func(l link) float64 { return l.Value() },
+	})
+
+	if errServer != nil {
+		t.Fatal(errServer)
+	}
+
+	defer server.Close()
+	status, content := call(server, http.MethodGet, "/graph.json")
+	var graph visualization.Graph
+	if status != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", status, content)
+	} else if err := json.Unmarshal([]byte(content), &graph); err != nil {
+		t.Fatalf("invalid json %s: %s", content, err)
+	} else if graph.Title != "sample" || graph.Live || len(graph.Nodes) != 4 || len(graph.Links) != 3 {
+		t.Fatalf("unexpected graph %s", content)
+	}
+
+	nodes := make(map[string]visualization.Node)
+	for _, n := range graph.Nodes {
+		nodes[n.Id] = n
+	}
+
+	if a := nodes["a"]; a.Label != "A" || a.Color == "" || a.Size <= 4 {
+		t.Errorf("unexpected node %v", a)
+	} else if d := nodes["d"]; d.Size != 4 {
+		t.Errorf("isolated node should have minimal size, got %v", d)
+	}
+
+	weights := 0.0
+	for _, l := range graph.Links {
+		weights += l.Weight
+		if l.Directed != (l.Source == "c" && l.Target == "a") {
+			t.Errorf("unexpected link %v", l)
+		}
+	}
+
+	if weights != 6 {
+		t.Errorf("each link should appear once, got %v", graph.Links)
+	}
+}
+
+// anonymous is a node with no id
+type anonymous struct {
+	// name of the node
+	name string
+}
+
+// SameNode compares names
+func (a anonymous) SameNode(other graphs.Node) bool {
+	o, ok := other.(anonymous)
+	return ok && o.name == a.name
+}
+
+func TestServerKeys(t *testing.T) {
+	base := local.NewMapGraph[anonymous, internal.UndirectedSimpleLink[anonymous]]()
+	base.AddNode(anonymous{"a"})
+	server, _ := visualization.NewServer(&base, visualization.Options[anonymous, internal.UndirectedSimpleLink[anonymous]]{})
+	defer server.Close()
+	if status, content := call(server, http.MethodGet, "/graph.json"); status != http.StatusInternalServerError || !strings.Contains(content, "no key function") {
+		t.Errorf("unexpected status %d: %s", status, content)
+	}
+
+	keyed, _ := visualization.NewServer(&base, visualization.Options[anonymous, internal.UndirectedSimpleLink[anonymous]]{
+		Key: func(a anonymous) string { return a.name },
+	})
+
+	defer keyed.Close()
+	if status, content := call(keyed, http.MethodGet, "/graph.json"); status != http.StatusOK || !strings.Contains(content, `{"id":"a"}`) {
+		t.Errorf("unexpected status %d: %s", status, content)
+	}
+}
+
+// nextEvent reads the next event of a server-sent events stream, ignoring comments
+func nextEvent(t *testing.T, reader *bufio.Reader) visualization.Event {
+	t.Helper()
+	for {
+		line, errLine := reader.ReadString('\n')
+		if errLine != nil {
+			t.Fatalf("stream ended: %s", errLine)
+		}
+
+		if data, found := strings.CutPrefix(line, "data: "); found {
+			var event visualization.Event
+			if err := json.Unmarshal([]byte(data), &event); err != nil {
+				t.Fatal(err)
+			}
+
+			return event
+		}
+	}
+}
+
+func TestServerLiveUpdates(t *testing.T) {
+	graph := internal.NewObservableGraph(local.NewSyncGraph[node, link]())
+	server, errServer := visualization.NewServer(graph, visualization.Options[node, link]{Size: visualization.DegreeSizes(graph, 1, 10)})
+	if errServer != nil {
+		t.Fatal(errServer)
+	}
+
+	testServer := httptest.NewServer(server)
+	defer testServer.Close()
+
+	response, errResponse := testServer.Client().Get(testServer.URL + "/events")
+	if errResponse != nil {
+		t.Fatal(errResponse)
+	}
+
+	defer response.Body.Close()
+	if contentType := response.Header.Get("Content-Type"); contentType != "text/event-stream" {
+		t.Fatalf("unexpected content type %s", contentType)
+	}
+
+	// first comment is sent once the page is registered, so that no change is missed after it
+	reader := bufio.NewReader(response.Body)
+	if line, err := reader.ReadString('\n'); err != nil || !strings.HasPrefix(line, ":") {
+		t.Fatalf("unexpected first line %q: %v", line, err)
+	}
+
+	a, b := internal.NewIdNode("a"), internal.NewIdNode("b")
+	graph.AddNode(a)
+	graph.AddLink(internal.NewDirectedValuedLink(a, b, 1.0))
+
+	expected := []string{"node-added", "node-added", "link-added", "node-removed"}
+	events := make([]visualization.Event, 0)
+	for range 3 {
+		events = append(events, nextEvent(t, reader))
+	}
+
+	// events are drawn asynchronously, so sizes are tested before next change
+	graph.RemoveNode(a)
+	events = append(events, nextEvent(t, reader))
+
+	for index, event := range events {
+		if event.Type != expected[index] || event.Sequence != uint64(index+1) {
+			t.Errorf("expected %s, got %v", expected[index], event)
+		}
+	}
+
+	if added := events[2]; added.Link == nil || added.Link.Source != "a" || !added.Link.Directed || len(added.Nodes) != 2 {
+		t.Errorf("unexpected link event %v", added)
+	} else if added.Nodes[0].Size != 2 {
+		t.Errorf("extremities should be drawn again, got %v", added.Nodes)
+	}
+
+	if removed := events[3]; removed.Node.Id != "a" || len(removed.Links) != 1 || len(removed.Nodes) != 1 || removed.Nodes[0].Id != "b" {
+		t.Errorf("unexpected removal %v", removed)
+	}
+
+	if _, content := call(server, http.MethodGet, "/graph.json"); !strings.Contains(content, `"live":true`) || !strings.Contains(content, `"id":"b"`) {
+		t.Errorf("unexpected snapshot %s", content)
+	}
+
+	// closing the server ends the streams
+	if err := server.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := io.ReadAll(reader)
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("unexpected error %s", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("stream should end once server is closed")
+	}
+}